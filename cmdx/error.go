@@ -0,0 +1,233 @@
+package cmdx
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// Exit codes shared by all commands. Commands may register additional exit codes for their
+// errors with RegisterExitCode.
+const (
+	// ExitCodeSuccess means that the command succeeded.
+	ExitCodeSuccess = 0
+	// ExitCodeFailure is the exit code of errors without a more specific exit code.
+	ExitCodeFailure = 1
+	// ExitCodeUsage means that the command was invoked with invalid arguments or flags.
+	ExitCodeUsage = 64
+)
+
+// Error is an error of a command with an exit code, an optional hint on how to resolve it, and
+// an optional body, for example the error response of an API, which is included in the JSON
+// output.
+type Error struct {
+	// Code is the exit code of the command.
+	Code int `json:"exit_code"`
+
+	// Message describes the error.
+	Message string `json:"message"`
+
+	// Hint helps the user resolve the error.
+	Hint string `json:"hint,omitempty"`
+
+	// Body is additional machine-readable information about the error.
+	Body interface{} `json:"body,omitempty"`
+
+	err error
+}
+
+var exitCodes = struct {
+	sync.RWMutex
+	codes []registeredExitCode
+}{}
+
+type registeredExitCode struct {
+	err  error
+	code int
+}
+
+// NewError returns an error with the exit code and the message.
+func NewError(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// WrapError returns an error with the exit code which wraps err. The message is the message of
+// err.
+func WrapError(code int, err error) *Error {
+	return &Error{Code: code, Message: err.Error(), err: err}
+}
+
+// WithHint sets the hint of the error.
+func (e *Error) WithHint(format string, args ...interface{}) *Error {
+	e.Hint = fmt.Sprintf(format, args...)
+	return e
+}
+
+// WithBody sets the body of the error.
+func (e *Error) WithBody(body interface{}) *Error {
+	e.Body = body
+	return e
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// RegisterExitCode sets the exit code of all errors which match err, as determined by
+// errors.Is. Call it in an init function, for example:
+//
+//	func init() {
+//		cmdx.RegisterExitCode(ErrPendingMigrations, 2)
+//	}
+func RegisterExitCode(err error, code int) {
+	exitCodes.Lock()
+	defer exitCodes.Unlock()
+	exitCodes.codes = append(exitCodes.codes, registeredExitCode{err: err, code: code})
+}
+
+// ExitCode returns the exit code for the error: the code of an *Error, the code registered
+// with RegisterExitCode, or ExitCodeFailure.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitCodeSuccess
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+
+	exitCodes.RLock()
+	defer exitCodes.RUnlock()
+	for _, c := range exitCodes.codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return ExitCodeFailure
+}
+
+// RenderError writes the error to w. With the JSON formats, the error is written as
+// {"error": {"message": ..., "exit_code": ..., "hint": ..., "body": ...}}, so that scripts can
+// parse it. Errors which were already reported, see FailSilently, are not written.
+func RenderError(w io.Writer, err error, f format) {
+	if err == nil || errors.Is(err, ErrNoPrintButFail) {
+		return
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		e = WrapError(ExitCode(err), err)
+	}
+
+	switch f {
+	case FormatJSON, FormatJSONPretty:
+		printJSON(w, struct {
+			Error *Error `json:"error"`
+		}{Error: e}, f == FormatJSONPretty)
+	default:
+		_, _ = fmt.Fprintln(w, e.Message)
+		if e.Hint != "" {
+			_, _ = fmt.Fprintf(w, "Hint: %s\n", e.Hint)
+		}
+		if e.Body != nil {
+			out, err := json.MarshalIndent(e.Body, "", "  ")
+			if err == nil {
+				_, _ = fmt.Fprintf(w, "\n%s\n", out)
+			}
+		}
+	}
+}
+
+// HandleError renders the error returned by the command to its error output, in JSON if the
+// command's --format flag is set to a JSON format, and returns the exit code. The error is not
+// rendered if the command silenced errors.
+func HandleError(cmd *cobra.Command, err error) int {
+	if cmd.SilenceErrors {
+		return ExitCode(err)
+	}
+
+	f := FormatDefault
+	if flag := cmd.Flags().Lookup(FlagFormat); flag != nil {
+		f = format(flag.Value.String())
+	}
+	RenderError(cmd.ErrOrStderr(), err, f)
+	return ExitCode(err)
+}
+
+// Execute runs the command and renders a returned error with HandleError. Errors of cobra,
+// such as unknown flags, have the exit code ExitCodeUsage. Use it in the main function:
+//
+//	func main() {
+//		os.Exit(cmdx.Execute(cmd.NewRootCmd()))
+//	}
+func Execute(root *cobra.Command) int {
+	setErrorFormat(FormatDefault)
+
+	// Errors are rendered by HandleError instead of cobra.
+	silence := root.SilenceErrors
+	root.SilenceErrors = true
+
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return WrapError(ExitCodeUsage, err).WithHint("Run '%s --help' for usage.", cmd.CommandPath())
+	})
+
+	cmd, err := root.ExecuteC()
+	if cmd == nil {
+		cmd = root
+	}
+	root.SilenceErrors = silence
+	return HandleError(cmd, err)
+}
+
+var osExit = os.Exit
+
+// errorFormat is the format of the --format flag of the running command, which fatal renders
+// errors in.
+var errorFormat = struct {
+	sync.RWMutex
+	f format
+}{f: FormatDefault}
+
+func setErrorFormat(f format) {
+	errorFormat.Lock()
+	defer errorFormat.Unlock()
+	errorFormat.f = f
+}
+
+func getErrorFormat() format {
+	errorFormat.RLock()
+	defer errorFormat.RUnlock()
+	return errorFormat.f
+}
+
+// fatal renders the error to os.Stderr in the format of the running command and exits with
+// its exit code, or ExitCodeFailure if the exit code of the error is ExitCodeSuccess.
+func fatal(err error) {
+	RenderError(os.Stderr, err, getErrorFormat())
+	code := ExitCode(err)
+	if code == ExitCodeSuccess {
+		code = ExitCodeFailure
+	}
+	osExit(code)
+}
+
+// withMessage returns an *Error with the message which keeps the exit code, hint, and body of
+// err.
+func withMessage(err error, message string) *Error {
+	e := &Error{Code: ExitCode(err), Message: message, err: err}
+	var cause *Error
+	if errors.As(err, &cause) {
+		e.Hint, e.Body = cause.Hint, cause.Body
+	}
+	return e
+}
@@ -0,0 +1,152 @@
+package cmdx
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	errRegistered := errors.New("registered")
+	RegisterExitCode(errRegistered, 3)
+
+	assert.Equal(t, ExitCodeSuccess, ExitCode(nil))
+	assert.Equal(t, ExitCodeFailure, ExitCode(errors.New("plain")))
+	assert.Equal(t, 3, ExitCode(errors.WithStack(errRegistered)))
+	assert.Equal(t, 4, ExitCode(errors.Wrap(NewError(4, "typed"), "wrapped")))
+	assert.Equal(t, 5, ExitCode(WrapError(5, errRegistered)), "the code of an *Error takes precedence")
+}
+
+func TestRenderError(t *testing.T) {
+	err := NewError(3, "unable to find client %q", "foo").
+		WithHint("List the clients with 'clients list'.").
+		WithBody(map[string]interface{}{"status_code": 404})
+
+	t.Run("format=default", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		RenderError(out, err, FormatDefault)
+		assert.Equal(t, `unable to find client "foo"
+Hint: List the clients with 'clients list'.
+
+{
+  "status_code": 404
+}
+`, out.String())
+	})
+
+	t.Run("format=json", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		RenderError(out, err, FormatJSON)
+		assert.JSONEq(t, `{"error":{"exit_code":3,"message":"unable to find client \"foo\"","hint":"List the clients with 'clients list'.","body":{"status_code":404}}}`, out.String())
+
+		out.Reset()
+		RenderError(out, errors.New("plain"), FormatJSONPretty)
+		assert.JSONEq(t, `{"error":{"exit_code":1,"message":"plain"}}`, out.String())
+	})
+
+	t.Run("case=silent", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		RenderError(out, errors.WithStack(ErrNoPrintButFail), FormatJSON)
+		RenderError(out, nil, FormatDefault)
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestExecute(t *testing.T) {
+	newRoot := func(err error) (*cobra.Command, *bytes.Buffer) {
+		root := &cobra.Command{Use: "root"}
+		sub := &cobra.Command{
+			Use:  "sub",
+			RunE: func(*cobra.Command, []string) error { return err },
+		}
+		RegisterFormatFlags(sub.Flags())
+		root.AddCommand(sub)
+
+		out := new(bytes.Buffer)
+		root.SetOut(new(bytes.Buffer))
+		root.SetErr(out)
+		return root, out
+	}
+
+	t.Run("case=json envelope", func(t *testing.T) {
+		root, out := newRoot(NewError(3, "not found").WithHint("Try again."))
+		root.SetArgs([]string{"sub", "--format", "json"})
+		assert.Equal(t, 3, Execute(root))
+
+		var envelope struct {
+			Error Error `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &envelope))
+		assert.Equal(t, Error{Code: 3, Message: "not found", Hint: "Try again."}, envelope.Error)
+	})
+
+	t.Run("case=usage", func(t *testing.T) {
+		root, out := newRoot(nil)
+		root.SetArgs([]string{"sub", "--unknown"})
+		assert.Equal(t, ExitCodeUsage, Execute(root))
+		assert.Contains(t, out.String(), "unknown flag: --unknown\nHint: Run 'root sub --help' for usage.")
+	})
+
+	t.Run("case=success", func(t *testing.T) {
+		root, out := newRoot(nil)
+		root.SetArgs([]string{"sub"})
+		assert.Equal(t, ExitCodeSuccess, Execute(root))
+		assert.Empty(t, out.String())
+	})
+}
+
+func TestMust(t *testing.T) {
+	var code int
+	exit := osExit
+	osExit = func(c int) { code = c }
+	defer func() { osExit = exit }()
+
+	Must(nil, "unused")
+	assert.Equal(t, 0, code)
+
+	Must(NewError(3, "typed"), "command failed: %s", "typed")
+	assert.Equal(t, 3, code)
+
+	Must(NewError(ExitCodeSuccess, "no exit code"), "command failed")
+	assert.Equal(t, ExitCodeFailure, code, "must never exit successfully")
+
+	Fatalf("failed with %d%%", 100)
+	assert.Equal(t, ExitCodeFailure, code)
+}
+
+func TestMustFormat(t *testing.T) {
+	var code int
+	exit := osExit
+	osExit = func(c int) { code = c }
+	defer func() { osExit = exit }()
+
+	stderr := os.Stderr
+	f, err := os.CreateTemp(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	os.Stderr = f
+	defer func() { os.Stderr = stderr }()
+
+	root := &cobra.Command{Use: "root"}
+	sub := &cobra.Command{
+		Use: "sub",
+		Run: func(*cobra.Command, []string) {
+			Must(NewError(3, "not found").WithHint("Try again."), "unable to get the client")
+		},
+	}
+	RegisterFormatFlags(sub.Flags())
+	root.AddCommand(sub)
+	root.SetArgs([]string{"sub", "--format", "json"})
+
+	assert.Equal(t, ExitCodeSuccess, Execute(root))
+	assert.Equal(t, 3, code)
+
+	out, err := os.ReadFile(f.Name())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"error":{"exit_code":3,"message":"unable to get the client","hint":"Try again."}}`, string(out))
+}
@@ -0,0 +1,232 @@
+package cmdx
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// FlagUpdateGolden is the name of the test flag which updates the golden files instead of
+// comparing against them. It is only available after RegisterGoldenFlags was called.
+const FlagUpdateGolden = "update"
+
+// EnvUpdateGolden is the environment variable which updates the golden files instead of comparing
+// against them if it is set to true, for example with
+//
+//	UPDATE_GOLDEN=true go test ./cmd/...
+const EnvUpdateGolden = "UPDATE_GOLDEN"
+
+// RegisterGoldenFlags registers the -update test flag on the default flag set. The flag is not
+// registered automatically, so that it does not conflict with update flags of other packages.
+// Call it from TestMain of test packages which want to use it:
+//
+//	func TestMain(m *testing.M) {
+//		cmdx.RegisterGoldenFlags()
+//		os.Exit(m.Run())
+//	}
+func RegisterGoldenFlags() {
+	if flag.Lookup(FlagUpdateGolden) == nil {
+		flag.Bool(FlagUpdateGolden, false, "Update the golden files of cmdx.Harness instead of comparing against them.")
+	}
+}
+
+func updateGolden() bool {
+	if update, err := strconv.ParseBool(os.Getenv(EnvUpdateGolden)); err == nil && update {
+		return true
+	}
+	f := flag.Lookup(FlagUpdateGolden)
+	return f != nil && f.Value.String() == "true"
+}
+
+type (
+	// Harness runs a cobra command in tests and captures its output. Create one with
+	// NewHarness.
+	Harness struct {
+		t         testing.TB
+		newCmd    func() *cobra.Command
+		ctx       context.Context
+		env       map[string]string
+		stdin     string
+		goldenDir string
+	}
+
+	// HarnessOption configures a Harness.
+	HarnessOption func(h *Harness)
+
+	// ExecResult is the result of running a command with a Harness.
+	ExecResult struct {
+		h *Harness
+
+		// Args are the arguments the command was run with.
+		Args []string
+		// Stdout is the output of the command.
+		Stdout string
+		// Stderr is the error output of the command.
+		Stderr string
+		// Err is the error returned by the command.
+		Err error
+		// ExitCode is the exit code for Err, see ExitCode.
+		ExitCode int
+	}
+)
+
+// WithEnv sets an environment variable while the command runs. The previous value is restored
+// afterwards.
+func WithEnv(key, value string) HarnessOption {
+	return func(h *Harness) {
+		h.env[key] = value
+	}
+}
+
+// WithStdin sets the input of the command.
+func WithStdin(stdin string) HarnessOption {
+	return func(h *Harness) {
+		h.stdin = stdin
+	}
+}
+
+// WithGoldenDir sets the directory of the golden files. Defaults to "testdata".
+func WithGoldenDir(dir string) HarnessOption {
+	return func(h *Harness) {
+		h.goldenDir = dir
+	}
+}
+
+// WithContext sets the context the command runs with. Defaults to a context which is canceled
+// when the test ends.
+func WithContext(ctx context.Context) HarnessOption {
+	return func(h *Harness) {
+		h.ctx = ctx
+	}
+}
+
+// NewHarness returns a harness which runs the command returned by newCmd. A new command is
+// created for every run, so that flags do not leak between runs:
+//
+//	h := cmdx.NewHarness(t, cmd.NewRootCmd, cmdx.WithEnv("LOG_LEVEL", "error"))
+//	h.Run("clients", "list", "--format", "json").AssertGolden("clients-list")
+func NewHarness(t testing.TB, newCmd func() *cobra.Command, opts ...HarnessOption) *Harness {
+	h := &Harness{
+		t:         t,
+		newCmd:    newCmd,
+		env:       map[string]string{},
+		goldenDir: "testdata",
+	}
+	for _, o := range opts {
+		o(h)
+	}
+	if h.ctx == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		h.ctx = ctx
+	}
+	return h
+}
+
+// Run runs the command with the arguments and the options of the harness.
+func (h *Harness) Run(args ...string) *ExecResult {
+	return h.RunWith(nil, args...)
+}
+
+// RunWith runs the command with the arguments and additional options which only apply to this
+// run.
+func (h *Harness) RunWith(opts []HarnessOption, args ...string) *ExecResult {
+	run := *h
+	run.env = make(map[string]string, len(h.env))
+	for k, v := range h.env {
+		run.env[k] = v
+	}
+	for _, o := range opts {
+		o(&run)
+	}
+
+	defer setEnv(h.t, run.env)()
+
+	stdout, stderr, err := ExecCtx(run.ctx, run.newCmd(), strings.NewReader(run.stdin), args...)
+	return &ExecResult{
+		h:        &run,
+		Args:     args,
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Err:      err,
+		ExitCode: ExitCode(err),
+	}
+}
+
+func setEnv(t testing.TB, env map[string]string) (restore func()) {
+	type previous struct {
+		value string
+		ok    bool
+	}
+	prev := make(map[string]previous, len(env))
+	for k, v := range env {
+		p, ok := os.LookupEnv(k)
+		prev[k] = previous{value: p, ok: ok}
+		require.NoError(t, os.Setenv(k, v))
+	}
+
+	return func() {
+		for k, p := range prev {
+			if p.ok {
+				_ = os.Setenv(k, p.value)
+			} else {
+				_ = os.Unsetenv(k)
+			}
+		}
+	}
+}
+
+// AssertSuccess asserts that the command succeeded.
+func (r *ExecResult) AssertSuccess() *ExecResult {
+	r.h.t.Helper()
+	assert.NoError(r.h.t, r.Err, "args: %v\nstdout: %s\nstderr: %s", r.Args, r.Stdout, r.Stderr)
+	return r
+}
+
+// AssertExitCode asserts that the command failed with the exit code.
+func (r *ExecResult) AssertExitCode(code int) *ExecResult {
+	r.h.t.Helper()
+	assert.Equal(r.h.t, code, r.ExitCode, "args: %v\nerror: %+v\nstdout: %s\nstderr: %s", r.Args, r.Err, r.Stdout, r.Stderr)
+	return r
+}
+
+// AssertGolden compares the output and the error output of the command with the golden files
+// "<name>.stdout.golden" and "<name>.stderr.golden" in the golden directory. If the tests run
+// with UPDATE_GOLDEN=true, or with the -update flag registered by RegisterGoldenFlags, the golden
+// files are written instead:
+//
+//	UPDATE_GOLDEN=true go test ./cmd/...
+func (r *ExecResult) AssertGolden(name string) *ExecResult {
+	r.h.t.Helper()
+	r.assertGolden(name+".stdout.golden", r.Stdout)
+	r.assertGolden(name+".stderr.golden", r.Stderr)
+	return r
+}
+
+func (r *ExecResult) assertGolden(name, actual string) {
+	t := r.h.t
+	t.Helper()
+	path := filepath.Join(r.h.goldenDir, name)
+
+	if updateGolden() {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(actual), 0644))
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		require.FailNowf(t, "golden file does not exist", "Run the tests with %s=true to create %s.", EnvUpdateGolden, path)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), actual, "The output does not match %s. Run the tests with %s=true to update it.", path, EnvUpdateGolden)
+}
@@ -0,0 +1,88 @@
+package cmdx
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGreetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use: "greet",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name, err := ioutil.ReadAll(cmd.InOrStdin())
+			if err != nil {
+				return err
+			}
+			if len(name) == 0 {
+				return NewError(3, "no name given").WithHint("Pipe a name to the command.")
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s %s!\n", os.Getenv("GREETING"), name)
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "greeted")
+			return nil
+		},
+	}
+	RegisterFormatFlags(cmd.Flags())
+	return cmd
+}
+
+func TestHarness(t *testing.T) {
+	h := NewHarness(t, newGreetCmd, WithEnv("GREETING", "Hello"), WithStdin("Ory"))
+
+	r := h.Run().AssertSuccess().AssertGolden("greet")
+	assert.Equal(t, "Hello Ory!\n", r.Stdout)
+	assert.Equal(t, "greeted\n", r.Stderr)
+
+	_, ok := os.LookupEnv("GREETING")
+	assert.False(t, ok, "the environment is restored after the run")
+
+	r = h.RunWith([]HarnessOption{WithStdin("")}).AssertExitCode(3)
+	assert.Equal(t, "no name given", r.Err.Error())
+
+	r = h.RunWith([]HarnessOption{WithEnv("GREETING", "Hi")}).AssertSuccess()
+	assert.Equal(t, "Hi Ory!\n", r.Stdout)
+
+	t.Run("case=missing golden file", func(t *testing.T) {
+		if updateGolden() {
+			t.Skip("golden files are being updated")
+		}
+		ft := &failingT{TB: t}
+		r := NewHarness(ft, newGreetCmd, WithGoldenDir(t.TempDir()), WithStdin("Ory")).Run()
+		func() {
+			defer func() { _ = recover() }()
+			r.AssertGolden("missing")
+		}()
+		require.True(t, ft.failed)
+	})
+}
+
+func TestUpdateGolden(t *testing.T) {
+	require.Nil(t, flag.Lookup(FlagUpdateGolden), "importing cmdx must not register test flags")
+
+	dir := t.TempDir()
+	defer setEnv(t, map[string]string{EnvUpdateGolden: "true"})()
+	NewHarness(t, newGreetCmd, WithGoldenDir(dir), WithStdin("Ory")).Run().AssertGolden("greet")
+
+	actual, err := ioutil.ReadFile(filepath.Join(dir, "greet.stderr.golden"))
+	require.NoError(t, err)
+	assert.Equal(t, "greeted\n", string(actual))
+}
+
+type failingT struct {
+	testing.TB
+	failed bool
+}
+
+func (t *failingT) Errorf(string, ...interface{}) { t.failed = true }
+
+func (t *failingT) FailNow() {
+	t.failed = true
+	panic("FailNow")
+}
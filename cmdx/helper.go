@@ -8,7 +8,6 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"testing"
 
 	"golang.org/x/sync/errgroup"
@@ -36,14 +35,15 @@ func FailSilently(cmd *cobra.Command) error {
 	return errors.WithStack(ErrNoPrintButFail)
 }
 
-// Must fatals with the optional message if err is not nil.
+// Must fatals with the optional message if err is not nil. It exits with the exit code of err,
+// see ExitCode, but never with ExitCodeSuccess, and prints the hint and body of err if it is an
+// *Error. The error is rendered in the format of the --format flag of the running command.
 func Must(err error, message string, args ...interface{}) {
 	if err == nil {
 		return
 	}
 
-	_, _ = fmt.Fprintf(os.Stderr, message+"\n", args...)
-	os.Exit(1)
+	fatal(withMessage(err, fmt.Sprintf(message, args...)))
 }
 
 // CheckResponse fatals if err is nil or the response.StatusCode does not match the expectedStatusCode
@@ -80,14 +80,12 @@ func FormatResponse(o interface{}) string {
 	return string(out)
 }
 
-// Fatalf prints to os.Stderr and exists with code 1.
+// Fatalf prints to os.Stderr, in the format of the running command, and exits with code 1.
 func Fatalf(message string, args ...interface{}) {
 	if len(args) > 0 {
-		_, _ = fmt.Fprintf(os.Stderr, message+"\n", args...)
-	} else {
-		_, _ = fmt.Fprintln(os.Stderr, message)
+		message = fmt.Sprintf(message, args...)
 	}
-	os.Exit(1)
+	fatal(&Error{Code: ExitCodeFailure, Message: message})
 }
 
 // ExpectDependency expects every dependency to be not nil or it fatals.
@@ -7,8 +7,10 @@ import (
 	"strings"
 	"text/tabwriter"
 
+	"github.com/ghodss/yaml"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"github.com/tidwall/gjson"
 )
 
 type (
@@ -36,9 +38,12 @@ const (
 	FormatTable      format = "table"
 	FormatJSON       format = "json"
 	FormatJSONPretty format = "json-pretty"
+	FormatYAML       format = "yaml"
+	FormatJSONPath   format = "jsonpath"
 	FormatDefault    format = "default"
 
-	FlagFormat = "format"
+	FlagFormat  = "format"
+	FlagColumns = "columns"
 
 	None = "<none>"
 )
@@ -71,12 +76,16 @@ func PrintRow(cmd *cobra.Command, row TableRow) {
 		printJSON(cmd.OutOrStdout(), row.Interface(), false)
 	case FormatJSONPretty:
 		printJSON(cmd.OutOrStdout(), row.Interface(), true)
+	case FormatYAML:
+		printYAML(cmd.OutOrStdout(), row.Interface())
+	case FormatJSONPath:
+		printJSONPath(cmd.OutOrStdout(), row.Interface(), getJSONPath(cmd))
 	case FormatTable, FormatDefault:
 		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 1, '\t', 0)
 
-		fields := row.Columns()
-		for i, h := range row.Header() {
-			fmt.Fprintf(w, "%s\t%s\t\n", h, fields[i])
+		header, fields := row.Header(), row.Columns()
+		for _, i := range selectColumns(cmd, header) {
+			fmt.Fprintf(w, "%s\t%s\t\n", header[i], fields[i])
 		}
 
 		w.Flush()
@@ -106,16 +115,25 @@ func PrintTable(cmd *cobra.Command, table Table) {
 		printJSON(cmd.OutOrStdout(), table.Interface(), false)
 	case FormatJSONPretty:
 		printJSON(cmd.OutOrStdout(), table.Interface(), true)
+	case FormatYAML:
+		printYAML(cmd.OutOrStdout(), table.Interface())
+	case FormatJSONPath:
+		printJSONPath(cmd.OutOrStdout(), table.Interface(), getJSONPath(cmd))
 	default:
 		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 1, '\t', 0)
 
-		for _, h := range table.Header() {
-			fmt.Fprintf(w, "%s\t", h)
+		header := table.Header()
+		columns := selectColumns(cmd, header)
+		for _, i := range columns {
+			fmt.Fprintf(w, "%s\t", header[i])
 		}
 		fmt.Fprintln(w)
 
 		for _, row := range table.Table() {
-			fmt.Fprintln(w, strings.Join(row, "\t")+"\t")
+			for _, i := range columns {
+				fmt.Fprintf(w, "%s\t", row[i])
+			}
+			fmt.Fprintln(w)
 		}
 
 		w.Flush()
@@ -141,6 +159,18 @@ func PrintJSONAble(cmd *cobra.Command, d interface{ String() string }) {
 			v = i
 		}
 		printJSON(cmd.OutOrStdout(), v, true)
+	case FormatYAML:
+		var v interface{} = d
+		if i, ok := d.(interface{ Interface() interface{} }); ok {
+			v = i
+		}
+		printYAML(cmd.OutOrStdout(), v)
+	case FormatJSONPath:
+		var v interface{} = d
+		if i, ok := d.(interface{ Interface() interface{} }); ok {
+			v = i
+		}
+		printJSONPath(cmd.OutOrStdout(), v, getJSONPath(cmd))
 	}
 }
 
@@ -171,7 +201,12 @@ func getFormat(cmd *cobra.Command) format {
 		return FormatJSON
 	case string(FormatJSONPretty):
 		return FormatJSONPretty
+	case string(FormatYAML):
+		return FormatYAML
 	default:
+		if strings.HasPrefix(f, string(FormatJSONPath)+"=") {
+			return FormatJSONPath
+		}
 		return FormatDefault
 	}
 }
@@ -186,11 +221,138 @@ func printJSON(w io.Writer, v interface{}, pretty bool) {
 	Must(err, "Error encoding JSON: %s", err)
 }
 
+func printYAML(w io.Writer, v interface{}) {
+	out, err := yaml.Marshal(v)
+	// unexpected error
+	Must(err, "Error encoding YAML: %s", err)
+	_, _ = w.Write(out)
+}
+
+// formatFlag is the value of the --format flag. Once parsed, errors passed to Must and Fatalf
+// are rendered in that format.
+type formatFlag struct {
+	value string
+}
+
+func newFormatFlag() *formatFlag {
+	return &formatFlag{value: string(FormatDefault)}
+}
+
+func (f *formatFlag) String() string {
+	return f.value
+}
+
+func (f *formatFlag) Set(value string) error {
+	f.value = value
+	setErrorFormat(format(value))
+	return nil
+}
+
+// Type is "string", so that the flag can be read with pflag.FlagSet.GetString.
+func (f *formatFlag) Type() string {
+	return "string"
+}
+
 func RegisterJSONFormatFlags(flags *pflag.FlagSet) {
-	flags.StringP(FlagFormat, FlagFormat[:1], string(FormatDefault), fmt.Sprintf("Set the output format. One of %s, %s, and %s.", FormatDefault, FormatJSON, FormatJSONPretty))
+	flags.VarP(newFormatFlag(), FlagFormat, FlagFormat[:1], fmt.Sprintf("Set the output format. One of %s, %s, %s, %s, and %s=<expression>, for example %s='{.items[*].id}'.", FormatDefault, FormatJSON, FormatJSONPretty, FormatYAML, FormatJSONPath, FormatJSONPath))
 }
 
 func RegisterFormatFlags(flags *pflag.FlagSet) {
 	RegisterNoiseFlags(flags)
-	flags.StringP(FlagFormat, FlagFormat[:1], string(FormatDefault), fmt.Sprintf("Set the output format. One of %s, %s, and %s.", FormatTable, FormatJSON, FormatJSONPretty))
+	flags.VarP(newFormatFlag(), FlagFormat, FlagFormat[:1], fmt.Sprintf("Set the output format. One of %s, %s, %s, %s, and %s=<expression>, for example %s='{.items[*].id}'.", FormatTable, FormatJSON, FormatJSONPretty, FormatYAML, FormatJSONPath, FormatJSONPath))
+	flags.StringSlice(FlagColumns, nil, "Only print the given columns of the table, for example --columns id,name.")
+}
+
+func getJSONPath(cmd *cobra.Command) string {
+	f, err := cmd.Flags().GetString(FlagFormat)
+	// unexpected error
+	Must(err, "flag access error: %s", err)
+	return strings.TrimPrefix(f, string(FormatJSONPath)+"=")
+}
+
+// selectColumns returns the indices of the columns selected with --columns, or all columns if
+// the flag is not set. Columns are matched case-insensitively.
+func selectColumns(cmd *cobra.Command, header []string) []int {
+	// ignore the error here as we use this function also when the flag might not be registered
+	selected, _ := cmd.Flags().GetStringSlice(FlagColumns)
+	if len(selected) == 0 {
+		columns := make([]int, len(header))
+		for i := range header {
+			columns[i] = i
+		}
+		return columns
+	}
+
+	columns := make([]int, 0, len(selected))
+	for _, s := range selected {
+		found := false
+		for i, h := range header {
+			if strings.EqualFold(strings.TrimSpace(s), h) {
+				columns = append(columns, i)
+				found = true
+				break
+			}
+		}
+		if !found {
+			Fatalf("Unknown column %q, expected one of: %s", s, strings.Join(header, ", "))
+		}
+	}
+	return columns
+}
+
+// jsonPathToGJSON converts a JSONPath expression, such as "{.items[*].id}" or "$.items[0].id",
+// to the equivalent GJSON path.
+func jsonPathToGJSON(expr string) string {
+	expr = strings.TrimSpace(expr)
+	expr = strings.TrimSuffix(strings.TrimPrefix(expr, "{"), "}")
+	expr = strings.TrimPrefix(expr, "$")
+	expr = strings.TrimPrefix(expr, ".")
+
+	var path strings.Builder
+	for len(expr) > 0 {
+		i := strings.Index(expr, "[")
+		if i < 0 {
+			path.WriteString(expr)
+			break
+		}
+		path.WriteString(expr[:i])
+		end := strings.Index(expr[i:], "]")
+		if end < 0 {
+			path.WriteString(expr[i:])
+			break
+		}
+		index := expr[i+1 : i+end]
+		if index == "*" {
+			index = "#"
+		}
+		if path.Len() > 0 {
+			path.WriteString(".")
+		}
+		path.WriteString(index)
+		expr = expr[i+end+1:]
+	}
+	return path.String()
+}
+
+func printJSONPath(w io.Writer, v interface{}, expr string) {
+	out, err := json.Marshal(v)
+	// unexpected error
+	Must(err, "Error encoding JSON: %s", err)
+
+	result := gjson.GetBytes(out, jsonPathToGJSON(expr))
+	if !result.Exists() {
+		Fatalf("The JSONPath expression %q did not match anything.", expr)
+	}
+
+	values := []gjson.Result{result}
+	if result.IsArray() {
+		values = result.Array()
+	}
+	for _, value := range values {
+		if value.Type == gjson.String {
+			_, _ = fmt.Fprintln(w, value.String())
+			continue
+		}
+		_, _ = fmt.Fprintln(w, value.Raw)
+	}
 }
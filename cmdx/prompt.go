@@ -0,0 +1,182 @@
+package cmdx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+)
+
+// FlagYes is the name of the flag which answers all prompts with yes.
+const FlagYes = "yes"
+
+// ErrNoAnswer is returned by the prompts if there is no more input to read the answer from, for
+// example because the input is not a terminal and nothing was piped in.
+var ErrNoAnswer = errors.New("unable to read the answer from the input, use --yes to answer prompts non-interactively")
+
+// RegisterYesFlag registers the --yes flag, which answers all prompts with yes or their default.
+func RegisterYesFlag(flags *pflag.FlagSet) {
+	flags.BoolP(FlagYes, "y", false, "Answer all prompts with yes or their default answer, for example when running in scripts.")
+}
+
+// Prompter asks the user questions on the command line.
+type Prompter struct {
+	in          io.Reader
+	out         io.Writer
+	r           *bufio.Reader
+	yes         bool
+	interactive bool
+}
+
+// NewPrompter returns a prompter which reads from the input and writes the prompts to the
+// error output of the command, so that they do not mix with the output of the command. If the
+// --yes flag was registered with RegisterYesFlag and is set, the prompts are skipped.
+func NewPrompter(cmd *cobra.Command) *Prompter {
+	yes, _ := cmd.Flags().GetBool(FlagYes)
+	return newPrompter(cmd.InOrStdin(), cmd.ErrOrStderr(), yes)
+}
+
+func newPrompter(in io.Reader, out io.Writer, yes bool) *Prompter {
+	return &Prompter{in: in, out: out, yes: yes, interactive: IsTerminal(in)}
+}
+
+// IsTerminal returns true if the reader is an interactive terminal.
+func IsTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
+
+func (p *Prompter) readLine() (string, error) {
+	if p.r == nil {
+		p.r = bufio.NewReader(p.in)
+	}
+	line, err := p.r.ReadString('\n')
+	if errors.Is(err, io.EOF) && line == "" {
+		return "", errors.WithStack(ErrNoAnswer)
+	} else if err != nil && !errors.Is(err, io.EOF) {
+		return "", errors.WithStack(err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// readAnswer reads the answer to a prompt. If the input is not a terminal, the answer is not
+// echoed, so it is written after the prompt instead.
+func (p *Prompter) readAnswer() (string, error) {
+	answer, err := p.readLine()
+	if err == nil && !p.interactive {
+		_, _ = fmt.Fprintln(p.out, answer)
+	}
+	return answer, err
+}
+
+// Confirm asks a yes/no question. An empty answer selects the default. With --yes, it returns
+// true without asking.
+func (p *Prompter) Confirm(question string, defaultYes bool) (bool, error) {
+	if p.yes {
+		return true, nil
+	}
+
+	options := "[y/N]"
+	if defaultYes {
+		options = "[Y/n]"
+	}
+	return p.confirm(question, options, &defaultYes)
+}
+
+// confirm asks the yes/no question until it is answered. An empty answer selects the default,
+// if there is one.
+func (p *Prompter) confirm(question, options string, defaultAnswer *bool) (bool, error) {
+	for {
+		_, _ = fmt.Fprintf(p.out, "%s %s: ", question, options)
+		answer, err := p.readAnswer()
+		if err != nil {
+			return false, err
+		}
+
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			if defaultAnswer != nil {
+				return *defaultAnswer, nil
+			}
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// Select asks the user to choose one of the options, either by its number or its name, and
+// returns the index of the chosen option. An empty answer selects the option at defaultIndex,
+// if it is not negative. With --yes, it returns defaultIndex without asking, or an error if
+// there is no default.
+func (p *Prompter) Select(question string, options []string, defaultIndex int) (int, error) {
+	if len(options) == 0 {
+		return -1, errors.New("there are no options to select from")
+	}
+	if defaultIndex >= len(options) {
+		return -1, errors.Errorf("the default option %d does not exist", defaultIndex)
+	}
+	if p.yes {
+		if defaultIndex < 0 {
+			return -1, errors.Errorf("unable to answer %q with --yes because there is no default option", question)
+		}
+		return defaultIndex, nil
+	}
+
+	for {
+		_, _ = fmt.Fprintln(p.out, question)
+		for k, o := range options {
+			marker := " "
+			if k == defaultIndex {
+				marker = "*"
+			}
+			_, _ = fmt.Fprintf(p.out, "%s %d) %s\n", marker, k+1, o)
+		}
+		_, _ = fmt.Fprint(p.out, "Enter a number: ")
+
+		answer, err := p.readAnswer()
+		if err != nil {
+			return -1, err
+		}
+		answer = strings.TrimSpace(answer)
+
+		if answer == "" && defaultIndex >= 0 {
+			return defaultIndex, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(options) {
+			return n - 1, nil
+		}
+		for k, o := range options {
+			if strings.EqualFold(o, answer) {
+				return k, nil
+			}
+		}
+		_, _ = fmt.Fprintf(p.out, "%q is not a valid option.\n", answer)
+	}
+}
+
+// Secret asks for a secret, such as a password. On a terminal, the input is not echoed.
+// Otherwise, for example when the secret is piped in, one line is read. The --yes flag does
+// not apply to secrets.
+func (p *Prompter) Secret(prompt string) (string, error) {
+	_, _ = fmt.Fprintf(p.out, "%s: ", prompt)
+
+	if f, ok := p.in.(*os.File); ok && p.interactive {
+		secret, err := term.ReadPassword(int(f.Fd()))
+		_, _ = fmt.Fprintln(p.out)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return string(secret), nil
+	}
+
+	return p.readLine()
+}
@@ -0,0 +1,102 @@
+package cmdx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPrompter(t *testing.T, input string, args ...string) (*Prompter, *bytes.Buffer) {
+	cmd := &cobra.Command{Use: "test"}
+	RegisterYesFlag(cmd.Flags())
+	require.NoError(t, cmd.Flags().Parse(args))
+
+	out := new(bytes.Buffer)
+	cmd.SetIn(strings.NewReader(input))
+	cmd.SetErr(out)
+	return NewPrompter(cmd), out
+}
+
+func TestPrompter(t *testing.T) {
+	t.Run("method=Confirm", func(t *testing.T) {
+		for _, tc := range []struct {
+			input      string
+			defaultYes bool
+			expected   bool
+		}{
+			{input: "y\n", expected: true},
+			{input: "YES\n", expected: true},
+			{input: "n\n", defaultYes: true, expected: false},
+			{input: "\n", defaultYes: true, expected: true},
+			{input: "\n", expected: false},
+			{input: "maybe\nyes\n", expected: true},
+			{input: "y", expected: true},
+		} {
+			p, out := newTestPrompter(t, tc.input)
+			confirmed, err := p.Confirm("Delete?", tc.defaultYes)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, confirmed, "%q", tc.input)
+			assert.Contains(t, out.String(), "Delete?")
+		}
+
+		// The piped answer is written after the prompt, because it is not echoed.
+		p, out := newTestPrompter(t, "maybe\ny\n")
+		_, err := p.Confirm("Delete?", false)
+		require.NoError(t, err)
+		assert.Equal(t, "Delete? [y/N]: maybe\nDelete? [y/N]: y\n", out.String())
+
+		p, _ = newTestPrompter(t, "")
+		_, err = p.Confirm("Delete?", false)
+		assert.True(t, errors.Is(err, ErrNoAnswer))
+
+		p, out = newTestPrompter(t, "", "--yes")
+		confirmed, err := p.Confirm("Delete?", false)
+		require.NoError(t, err)
+		assert.True(t, confirmed)
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("method=Select", func(t *testing.T) {
+		options := []string{"postgres", "mysql", "sqlite"}
+		for _, tc := range []struct {
+			input    string
+			expected int
+		}{
+			{input: "2\n", expected: 1},
+			{input: "SQLite\n", expected: 2},
+			{input: "\n", expected: 0},
+			{input: "4\n3\n", expected: 2},
+		} {
+			p, out := newTestPrompter(t, tc.input)
+			selected, err := p.Select("Database?", options, 0)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, selected, "%q", tc.input)
+			assert.Contains(t, out.String(), "* 1) postgres")
+		}
+
+		p, _ := newTestPrompter(t, "", "-y")
+		selected, err := p.Select("Database?", options, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, selected)
+
+		_, err = p.Select("Database?", options, -1)
+		assert.Error(t, err)
+	})
+
+	t.Run("method=Secret", func(t *testing.T) {
+		p, out := newTestPrompter(t, "s3cr3t\nsecond\n", "--yes")
+		secret, err := p.Secret("Password")
+		require.NoError(t, err)
+		assert.Equal(t, "s3cr3t", secret)
+		assert.Equal(t, "Password: ", out.String())
+
+		secret, err = p.Secret("Repeat")
+		require.NoError(t, err)
+		assert.Equal(t, "second", secret)
+	})
+}
@@ -0,0 +1,177 @@
+package cmdx
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type (
+	structColumn struct {
+		header string
+		index  []int
+		id     bool
+	}
+
+	// StructRow is a TableRow of a struct. See NewStructRow.
+	StructRow struct {
+		v       reflect.Value
+		columns []structColumn
+	}
+
+	// StructTable is a Table of a slice of structs. See NewStructTable.
+	StructTable struct {
+		v       reflect.Value
+		columns []structColumn
+	}
+)
+
+var (
+	_ TableRow = (*StructRow)(nil)
+	_ Table    = (*StructTable)(nil)
+)
+
+// NewStructRow returns a TableRow for a struct, or a pointer to one. The columns are the
+// exported fields with a "table" tag, which sets the header of the column, in the order of the
+// fields:
+//
+//	type Client struct {
+//		ID   string `json:"id" table:"ID,id"`
+//		Name string `json:"name" table:"NAME"`
+//	}
+//
+// The "id" option marks the column printed in quiet mode. Without it, the first column is
+// used. The struct itself is used for the JSON, YAML, and JSONPath formats.
+func NewStructRow(v interface{}) *StructRow {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	return &StructRow{v: rv, columns: structColumns(rv.Type())}
+}
+
+// NewStructTable returns a Table for a slice of structs, or of pointers to structs. See
+// NewStructRow for how the columns are defined.
+func NewStructTable(items interface{}) *StructTable {
+	rv := reflect.ValueOf(items)
+	if rv.Kind() != reflect.Slice {
+		panic(fmt.Sprintf("cmdx: expected a slice but got %s", rv.Kind()))
+	}
+	t := rv.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return &StructTable{v: rv, columns: structColumns(t)}
+}
+
+func structColumns(t reflect.Type) []structColumn {
+	if t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("cmdx: expected a struct but got %s", t.Kind()))
+	}
+
+	var columns []structColumn
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("table")
+		if !ok || tag == "-" || f.PkgPath != "" {
+			continue
+		}
+		parts := strings.Split(tag, ",")
+		c := structColumn{header: parts[0], index: f.Index}
+		if c.header == "" {
+			c.header = strings.ToUpper(f.Name)
+		}
+		for _, o := range parts[1:] {
+			if o == "id" {
+				c.id = true
+			}
+		}
+		columns = append(columns, c)
+	}
+	return columns
+}
+
+func structHeader(columns []structColumn) []string {
+	header := make([]string, len(columns))
+	for i, c := range columns {
+		header[i] = c.header
+	}
+	return header
+}
+
+func structValues(v reflect.Value, columns []structColumn) []string {
+	v = reflect.Indirect(v)
+	values := make([]string, len(columns))
+	for i, c := range columns {
+		values[i] = formatField(v.FieldByIndex(c.index))
+	}
+	return values
+}
+
+func formatField(f reflect.Value) string {
+	for f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface {
+		if f.IsNil() {
+			return None
+		}
+		f = f.Elem()
+	}
+	if s, ok := f.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	switch f.Kind() {
+	case reflect.Slice, reflect.Array:
+		if f.Len() == 0 {
+			return None
+		}
+		items := make([]string, f.Len())
+		for i := range items {
+			items[i] = formatField(f.Index(i))
+		}
+		return strings.Join(items, ", ")
+	case reflect.String:
+		if f.Len() == 0 {
+			return None
+		}
+	}
+	return fmt.Sprintf("%v", f.Interface())
+}
+
+func structID(v reflect.Value, columns []structColumn) string {
+	values := structValues(v, columns)
+	for i, c := range columns {
+		if c.id {
+			return values[i]
+		}
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (r *StructRow) Header() []string { return structHeader(r.columns) }
+
+func (r *StructRow) Columns() []string { return structValues(r.v, r.columns) }
+
+func (r *StructRow) Interface() interface{} { return r.v.Interface() }
+
+func (r *StructRow) ID() string { return structID(r.v, r.columns) }
+
+func (t *StructTable) Header() []string { return structHeader(t.columns) }
+
+func (t *StructTable) Table() [][]string {
+	rows := make([][]string, t.Len())
+	for i := range rows {
+		rows[i] = structValues(t.v.Index(i), t.columns)
+	}
+	return rows
+}
+
+func (t *StructTable) Interface() interface{} { return t.v.Interface() }
+
+func (t *StructTable) Len() int { return t.v.Len() }
+
+func (t *StructTable) IDs() []string {
+	ids := make([]string, t.Len())
+	for i := range ids {
+		ids[i] = structID(t.v.Index(i), t.columns)
+	}
+	return ids
+}
@@ -0,0 +1,93 @@
+package cmdx
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testClient struct {
+	Name     string   `json:"name" table:"NAME"`
+	ID       string   `json:"id" table:"ID,id"`
+	Scopes   []string `json:"scopes" table:"SCOPES"`
+	Owner    *string  `json:"owner" table:"OWNER"`
+	Internal string   `json:"-"`
+}
+
+func TestStructTable(t *testing.T) {
+	owner := "foo@example.com"
+	clients := []testClient{
+		{Name: "first", ID: "id-1", Scopes: []string{"openid", "offline"}, Owner: &owner},
+		{Name: "second", ID: "id-2"},
+	}
+
+	run := func(t *testing.T, args []string, f func(cmd *cobra.Command)) string {
+		cmd := &cobra.Command{Use: "x"}
+		RegisterFormatFlags(cmd.Flags())
+		out := new(bytes.Buffer)
+		cmd.SetOut(out)
+		require.NoError(t, cmd.Flags().Parse(args))
+		f(cmd)
+		return out.String()
+	}
+
+	t.Run("case=table", func(t *testing.T) {
+		tb := NewStructTable(clients)
+		assert.Equal(t, []string{"NAME", "ID", "SCOPES", "OWNER"}, tb.Header())
+		assert.Equal(t, [][]string{
+			{"first", "id-1", "openid, offline", owner},
+			{"second", "id-2", None, None},
+		}, tb.Table())
+		assert.Equal(t, []string{"id-1", "id-2"}, tb.IDs())
+
+		out := run(t, []string{"--quiet"}, func(cmd *cobra.Command) { PrintTable(cmd, tb) })
+		assert.Equal(t, "id-1\nid-2\n", out)
+	})
+
+	t.Run("case=row", func(t *testing.T) {
+		row := NewStructRow(&clients[0])
+		assert.Equal(t, "id-1", row.ID())
+		assert.Equal(t, []string{"first", "id-1", "openid, offline", owner}, row.Columns())
+	})
+
+	t.Run("case=column selection", func(t *testing.T) {
+		out := run(t, []string{"--columns", "id,name"}, func(cmd *cobra.Command) { PrintTable(cmd, NewStructTable(clients)) })
+		assert.Equal(t, "ID\tNAME\t\nid-1\tfirst\t\nid-2\tsecond\t\n", out)
+
+		out = run(t, []string{"--columns", "owner"}, func(cmd *cobra.Command) { PrintRow(cmd, NewStructRow(clients[0])) })
+		assert.Equal(t, "OWNER\t"+owner+"\t\n", out)
+	})
+
+	t.Run("case=jsonpath", func(t *testing.T) {
+		for _, tc := range []struct {
+			expr, expected string
+		}{
+			{expr: "{[*].id}", expected: "id-1\nid-2\n"},
+			{expr: "$[0].scopes", expected: "openid\noffline\n"},
+			{expr: "{[1]}", expected: `{"name":"second","id":"id-2","scopes":null,"owner":null}` + "\n"},
+		} {
+			t.Run("expr="+tc.expr, func(t *testing.T) {
+				out := run(t, []string{"--format", "jsonpath=" + tc.expr}, func(cmd *cobra.Command) { PrintTable(cmd, NewStructTable(clients)) })
+				assert.Equal(t, tc.expected, out)
+			})
+		}
+
+		out := run(t, []string{"--format", "jsonpath={.name}"}, func(cmd *cobra.Command) { PrintRow(cmd, NewStructRow(clients[0])) })
+		assert.Equal(t, "first\n", out)
+	})
+}
+
+func TestJSONPathToGJSON(t *testing.T) {
+	for expr, expected := range map[string]string{
+		"{.items[*].id}":  "items.#.id",
+		"$.items[0].name": "items.0.name",
+		"{[*].id}":        "#.id",
+		".name":           "name",
+		"{.a.b}":          "a.b",
+	} {
+		assert.Equal(t, expected, jsonPathToGJSON(expr), expr)
+	}
+}
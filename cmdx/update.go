@@ -0,0 +1,340 @@
+package cmdx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// EnvNoUpdateCheck disables the update check if set to any non-empty value.
+	EnvNoUpdateCheck = "NO_UPDATE_CHECK"
+
+	// DefaultUpdateCheckInterval is the minimum time between two update checks.
+	DefaultUpdateCheckInterval = 24 * time.Hour
+)
+
+// ErrInvalidSignature is returned if the signature of the release metadata is invalid.
+var ErrInvalidSignature = errors.New("the signature of the release metadata is invalid")
+
+type (
+	// Release is the metadata of the latest release.
+	Release struct {
+		// Version is the semantic version of the release, for example "v1.2.3".
+		Version string `json:"version"`
+
+		// URL is where the release can be downloaded.
+		URL string `json:"url,omitempty"`
+
+		// PublishedAt is when the release was published.
+		PublishedAt time.Time `json:"published_at,omitempty"`
+	}
+
+	// UpdateChecker checks if a newer version of the command is available. The release metadata
+	// is a JSON-encoded Release which is signed with minisign. The signature is expected at the
+	// URL of the metadata with the ".minisig" suffix. Create one with NewUpdateChecker.
+	UpdateChecker struct {
+		name        string
+		current     string
+		url         string
+		key         minisignKey
+		client      *http.Client
+		stateFile   string
+		interval    time.Duration
+		waitTimeout time.Duration
+		now         func() time.Time
+	}
+
+	// UpdateCheckerOption configures an UpdateChecker.
+	UpdateCheckerOption func(u *UpdateChecker)
+
+	updateCheckState struct {
+		CheckedAt time.Time `json:"checked_at"`
+	}
+
+	minisignKey struct {
+		id  [8]byte
+		key ed25519.PublicKey
+	}
+)
+
+// WithUpdateHTTPClient sets the HTTP client used to fetch the release metadata.
+func WithUpdateHTTPClient(c *http.Client) UpdateCheckerOption {
+	return func(u *UpdateChecker) {
+		u.client = c
+	}
+}
+
+// WithUpdateStateFile sets the file which records when the last check happened. Defaults to
+// "<name>/update-check.json" in the user's cache directory.
+func WithUpdateStateFile(path string) UpdateCheckerOption {
+	return func(u *UpdateChecker) {
+		u.stateFile = path
+	}
+}
+
+// WithUpdateCheckInterval sets the minimum time between two checks. Defaults to
+// DefaultUpdateCheckInterval.
+func WithUpdateCheckInterval(interval time.Duration) UpdateCheckerOption {
+	return func(u *UpdateChecker) {
+		u.interval = interval
+	}
+}
+
+// WithUpdateWaitTimeout sets how long the function returned by CheckInBackground waits for the
+// check to finish. Defaults to one second, so that the command is not delayed by a slow
+// network.
+func WithUpdateWaitTimeout(timeout time.Duration) UpdateCheckerOption {
+	return func(u *UpdateChecker) {
+		u.waitTimeout = timeout
+	}
+}
+
+// NewUpdateChecker returns an update checker for the command with the name and the current
+// version. The release metadata is fetched from metadataURL and verified with the minisign
+// public key, which is either the base64-encoded key or the content of a minisign public key
+// file.
+func NewUpdateChecker(name, currentVersion, metadataURL, publicKey string, opts ...UpdateCheckerOption) (*UpdateChecker, error) {
+	key, err := parseMinisignKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &UpdateChecker{
+		name:        name,
+		current:     currentVersion,
+		url:         metadataURL,
+		key:         key,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		interval:    DefaultUpdateCheckInterval,
+		waitTimeout: time.Second,
+		now:         time.Now,
+	}
+	for _, o := range opts {
+		o(u)
+	}
+
+	if u.stateFile == "" {
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		u.stateFile = filepath.Join(dir, name, "update-check.json")
+	}
+	return u, nil
+}
+
+// Check fetches and verifies the release metadata and returns the release if it is newer than
+// the current version, or nil otherwise.
+func (u *UpdateChecker) Check(ctx context.Context) (*Release, error) {
+	metadata, err := u.fetch(ctx, u.url)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := u.fetch(ctx, u.url+".minisig")
+	if err != nil {
+		return nil, err
+	}
+	if err := u.key.verify(metadata, signature); err != nil {
+		return nil, err
+	}
+
+	var release Release
+	if err := json.Unmarshal(metadata, &release); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	latest, current := canonicalVersion(release.Version), canonicalVersion(u.current)
+	if !semver.IsValid(latest) {
+		return nil, errors.Errorf("the latest version %q is not a semantic version", release.Version)
+	}
+	if semver.IsValid(current) && semver.Compare(latest, current) <= 0 {
+		return nil, nil
+	}
+	return &release, nil
+}
+
+// CheckInBackground checks for updates in the background, unless the last check happened less
+// than the check interval ago or EnvNoUpdateCheck is set. Call the returned function before the
+// command exits. It waits for the check to finish, at most for the wait timeout, and prints an
+// upgrade hint to w if a newer version is available. Errors are ignored, because the update
+// check must never break the command:
+//
+//	wait := checker.CheckInBackground(cmd.Context(), cmd.ErrOrStderr())
+//	defer wait()
+func (u *UpdateChecker) CheckInBackground(ctx context.Context, w io.Writer) (wait func()) {
+	if os.Getenv(EnvNoUpdateCheck) != "" || !u.due() {
+		return func() {}
+	}
+
+	result := make(chan *Release, 1)
+	go func() {
+		release, err := u.Check(ctx)
+		if err != nil {
+			release = nil
+		}
+		result <- release
+	}()
+
+	return func() {
+		select {
+		case release := <-result:
+			if release != nil {
+				u.printHint(w, release)
+			}
+		case <-time.After(u.waitTimeout):
+		case <-ctx.Done():
+		}
+	}
+}
+
+func (u *UpdateChecker) printHint(w io.Writer, release *Release) {
+	_, _ = fmt.Fprintf(w, "\nA new version of %s is available: %s (you have %s).\n", u.name, release.Version, u.current)
+	if release.URL != "" {
+		_, _ = fmt.Fprintf(w, "Download it from %s\n", release.URL)
+	}
+	_, _ = fmt.Fprintf(w, "Set %s=1 to disable this check.\n", EnvNoUpdateCheck)
+}
+
+// due returns true and records the check if the last check happened at least one interval ago.
+func (u *UpdateChecker) due() bool {
+	var state updateCheckState
+	if raw, err := ioutil.ReadFile(u.stateFile); err == nil {
+		_ = json.Unmarshal(raw, &state)
+	}
+
+	now := u.now()
+	if now.Sub(state.CheckedAt) < u.interval {
+		return false
+	}
+
+	raw, err := json.Marshal(updateCheckState{CheckedAt: now})
+	if err != nil {
+		return false
+	}
+	if err := os.MkdirAll(filepath.Dir(u.stateFile), 0700); err != nil {
+		return false
+	}
+	// If the state can not be recorded, the check would run on every invocation.
+	return ioutil.WriteFile(u.stateFile, raw, 0600) == nil
+}
+
+func (u *UpdateChecker) fetch(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res, err := u.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected status code %d but got %d when fetching %s", http.StatusOK, res.StatusCode, url)
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return body, nil
+}
+
+func canonicalVersion(v string) string {
+	if !strings.HasPrefix(v, "v") {
+		v = "v" + v
+	}
+	return v
+}
+
+// parseMinisignKey parses a minisign public key, which is the base64 encoding of the signature
+// algorithm "Ed", the 8-byte key ID, and the Ed25519 public key.
+func parseMinisignKey(key string) (k minisignKey, _ error) {
+	lines := nonCommentLines(key)
+	if len(lines) != 1 {
+		return k, errors.New("the minisign public key must contain exactly one key")
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(lines[0])
+	if err != nil {
+		return k, errors.Wrap(err, "unable to decode the minisign public key")
+	}
+	if len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+		return k, errors.New("the minisign public key is malformed")
+	}
+
+	copy(k.id[:], raw[2:10])
+	k.key = ed25519.PublicKey(raw[10:])
+	return k, nil
+}
+
+// verify verifies a minisign signature, which consists of an untrusted comment, the signature
+// of the message, a trusted comment, and the signature of the message signature and the
+// trusted comment. Both the legacy ("Ed") and the pre-hashed ("ED") signature algorithms are
+// supported.
+func (k minisignKey) verify(message, signature []byte) error {
+	var lines []string
+	s := bufio.NewScanner(bytes.NewReader(signature))
+	for s.Scan() {
+		lines = append(lines, strings.TrimSpace(s.Text()))
+	}
+	if len(lines) < 4 || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	if !bytes.Equal(sig[2:10], k.id[:]) {
+		return errors.Wrap(ErrInvalidSignature, "the release metadata was signed with a different key")
+	}
+
+	switch string(sig[:2]) {
+	case "Ed":
+	case "ED":
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	default:
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	if !ed25519.Verify(k.key, message, sig[10:]) {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(global) != ed25519.SignatureSize {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	trusted := append(append([]byte{}, sig[10:]...), strings.TrimPrefix(lines[2], "trusted comment: ")...)
+	if !ed25519.Verify(k.key, trusted, global) {
+		return errors.WithStack(ErrInvalidSignature)
+	}
+	return nil
+}
+
+func nonCommentLines(s string) []string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		l = strings.TrimSpace(l)
+		if l != "" && !strings.HasPrefix(l, "untrusted comment:") {
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
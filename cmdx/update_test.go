@@ -0,0 +1,134 @@
+package cmdx
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+type minisigner struct {
+	id  []byte
+	key ed25519.PrivateKey
+	pub string
+}
+
+func newMinisigner(t *testing.T) *minisigner {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	id := []byte("12345678")
+	return &minisigner{
+		id:  id,
+		key: key,
+		pub: "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), pub...)),
+	}
+}
+
+func (m *minisigner) sign(message []byte, prehash bool) []byte {
+	alg := "Ed"
+	if prehash {
+		alg = "ED"
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	}
+	sig := ed25519.Sign(m.key, message)
+	trusted := "timestamp:1634567890"
+	global := ed25519.Sign(m.key, append(append([]byte{}, sig...), trusted...))
+	return []byte(fmt.Sprintf("untrusted comment: signature\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte(alg), m.id...), sig...)),
+		trusted,
+		base64.StdEncoding.EncodeToString(global),
+	))
+}
+
+func TestUpdateChecker(t *testing.T) {
+	signer := newMinisigner(t)
+	metadata := []byte(`{"version":"v1.2.0","url":"https://example.com/releases/v1.2.0"}`)
+	signature := signer.sign(metadata, true)
+
+	var requests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/latest.json":
+			_, _ = w.Write(metadata)
+		case "/latest.json.minisig":
+			_, _ = w.Write(signature)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	newChecker := func(t *testing.T, version string, opts ...UpdateCheckerOption) *UpdateChecker {
+		opts = append([]UpdateCheckerOption{WithUpdateStateFile(filepath.Join(t.TempDir(), "state.json"))}, opts...)
+		u, err := NewUpdateChecker("ory", version, ts.URL+"/latest.json", signer.pub, opts...)
+		require.NoError(t, err)
+		return u
+	}
+
+	t.Run("method=Check", func(t *testing.T) {
+		release, err := newChecker(t, "v1.1.9").Check(context.Background())
+		require.NoError(t, err)
+		require.NotNil(t, release)
+		assert.Equal(t, "v1.2.0", release.Version)
+
+		release, err = newChecker(t, "1.2.0").Check(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, release)
+
+		release, err = newChecker(t, "v2.0.0-alpha.1").Check(context.Background())
+		require.NoError(t, err)
+		assert.Nil(t, release)
+	})
+
+	t.Run("case=signature", func(t *testing.T) {
+		u := newChecker(t, "v1.0.0")
+		require.NoError(t, u.key.verify(metadata, signer.sign(metadata, false)))
+
+		err := u.key.verify([]byte(`{"version":"v9.9.9"}`), signature)
+		assert.True(t, errors.Is(err, ErrInvalidSignature), "%+v", err)
+
+		other := newMinisigner(t)
+		err = u.key.verify(metadata, other.sign(metadata, true))
+		assert.True(t, errors.Is(err, ErrInvalidSignature), "%+v", err)
+
+		_, err = NewUpdateChecker("ory", "v1.0.0", ts.URL, "not a key")
+		assert.Error(t, err)
+	})
+
+	t.Run("method=CheckInBackground", func(t *testing.T) {
+		now := time.Now()
+		u := newChecker(t, "v1.0.0", WithUpdateWaitTimeout(5*time.Second))
+		u.now = func() time.Time { return now }
+
+		out := new(bytes.Buffer)
+		u.CheckInBackground(context.Background(), out)()
+		assert.Contains(t, out.String(), "A new version of ory is available: v1.2.0 (you have v1.0.0).")
+		assert.Contains(t, out.String(), "https://example.com/releases/v1.2.0")
+
+		before := atomic.LoadInt32(&requests)
+		out.Reset()
+		now = now.Add(time.Hour)
+		u.CheckInBackground(context.Background(), out)()
+		assert.Empty(t, out.String(), "checks at most once per interval")
+		assert.Equal(t, before, atomic.LoadInt32(&requests))
+
+		now = now.Add(DefaultUpdateCheckInterval)
+		u.CheckInBackground(context.Background(), out)()
+		assert.NotEmpty(t, out.String())
+	})
+}
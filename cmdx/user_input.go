@@ -1,15 +1,15 @@
 package cmdx
 
 import (
-	"bufio"
-	"fmt"
 	"io"
 	"os"
-	"strings"
 )
 
 // asks for confirmation with the question string s and reads the answer
 // pass nil to use os.Stdin and os.Stdout
+//
+// Unlike Prompter.Confirm, there is no default answer and it fatals if the answer can not be
+// read. Prefer Prompter.Confirm, which supports the --yes flag.
 func AskForConfirmation(s string, stdin io.Reader, stdout io.Writer) bool {
 	if stdin == nil {
 		stdin = os.Stdin
@@ -18,20 +18,7 @@ func AskForConfirmation(s string, stdin io.Reader, stdout io.Writer) bool {
 		stdout = os.Stdout
 	}
 
-	reader := bufio.NewReader(stdin)
-
-	for {
-		_, err := fmt.Fprintf(stdout, "%s [y/n]: ", s)
-		Must(err, "%s", err)
-
-		response, err := reader.ReadString('\n')
-		Must(err, "%s", err)
-
-		response = strings.ToLower(strings.TrimSpace(response))
-		if response == "y" || response == "yes" {
-			return true
-		} else if response == "n" || response == "no" {
-			return false
-		}
-	}
+	confirmed, err := newPrompter(stdin, stdout, false).confirm(s, "[y/n]", nil)
+	Must(err, "%s", err)
+	return confirmed
 }
@@ -28,6 +28,7 @@ func TestAskForConfirmation(t *testing.T) {
 		for _, input := range []string{
 			"y\n",
 			"yes\n",
+			"y",
 		} {
 			stdin := new(bytes.Buffer)
 
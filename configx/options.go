@@ -88,6 +88,15 @@ func WithUserProviders(providers ...koanf.Provider) OptionModifier {
 	}
 }
 
+// WithOverrideProviders adds providers whose values take precedence over config files,
+// flags, and environment variables. Only values set with WithValue or WithValues take
+// precedence over them.
+func WithOverrideProviders(providers ...koanf.Provider) OptionModifier {
+	return func(p *Provider) {
+		p.overrideProviders = append(p.overrideProviders, providers...)
+	}
+}
+
 func OmitKeysFromTracing(keys ...string) OptionModifier {
 	return func(p *Provider) {
 		p.excludeFieldsFromTracing = keys
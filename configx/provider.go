@@ -69,8 +69,9 @@ type Provider struct {
 	skipValidation bool
 	logger         *logrusx.Logger
 
-	providers     []koanf.Provider
-	userProviders []koanf.Provider
+	providers         []koanf.Provider
+	userProviders     []koanf.Provider
+	overrideProviders []koanf.Provider
 }
 
 const (
@@ -90,6 +91,7 @@ func RegisterConfigFlag(flags *pflag.FlagSet, fallback []string) {
 // 2. Config files (yaml, yml, toml, json)
 // 3. Command line flags
 // 4. Environment variables
+// 5. Override providers, see WithOverrideProviders
 func New(schema []byte, modifiers ...OptionModifier) (*Provider, error) {
 	validator, err := getSchema(schema)
 	if err != nil {
@@ -180,6 +182,8 @@ func (p *Provider) createProviders(ctx context.Context) (providers []koanf.Provi
 	}
 	providers = append(providers, envProvider)
 
+	providers = append(providers, p.overrideProviders...)
+
 	// Workaround for https://github.com/knadh/koanf/pull/47
 	for _, t := range p.forcedValues {
 		providers = append(providers, NewKoanfConfmap([]tuple{t}))
@@ -545,5 +549,8 @@ func (p *Provider) printHumanReadableValidationErrors(k *koanf.Koanf, w io.Write
 		_, _ = fmt.Fprintf(w, "Unable to unmarshal configuration: %+v", innerErr)
 	}
 
-	jsonschemax.FormatValidationErrorForCLI(w, conf, err)
+	// The schema was compiled before, so it is valid JSON.
+	messages := jsonschemax.NewMessages()
+	_ = messages.AddResource(p.validator.URL, p.schema)
+	jsonschemax.FormatValidationErrorForCLI(w, conf, err, jsonschemax.WithMessages(messages))
 }
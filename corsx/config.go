@@ -0,0 +1,108 @@
+package corsx
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/rs/cors"
+
+	"github.com/ory/x/configx"
+	"github.com/ory/x/logrusx"
+)
+
+// ErrDangerousOptions is returned by ValidateOptions if the options allow any website to make
+// authenticated cross-origin requests.
+var ErrDangerousOptions = errors.New("the CORS options allow credentials for any origin")
+
+// ValidateOptions rejects options which allow credentials, such as cookies, together with any
+// origin, including the "null" origin of sandboxed documents and local files.
+func ValidateOptions(opts cors.Options) error {
+	if !opts.AllowCredentials {
+		return nil
+	}
+	if len(opts.AllowedOrigins) == 0 && opts.AllowOriginFunc == nil && opts.AllowOriginRequestFunc == nil {
+		return errors.Wrap(ErrDangerousOptions, "no allowed origins are set, which allows all origins")
+	}
+	for _, o := range opts.AllowedOrigins {
+		if o == "*" || o == "null" {
+			return errors.Wrapf(ErrDangerousOptions, `the allowed origin "%s" must not be used together with credentials`, o)
+		}
+	}
+	return nil
+}
+
+// FromConfig returns a resolver which reads the CORS options "<prefix>.cors.*" from the
+// configuration on every request, so that changes, for example to
+// "serve.cors.allowed_origins", take effect without a restart. The options are only compiled
+// again if they changed. The allowed origins may contain wildcards and regular expressions, see
+// NewOriginMatcher, and rejected origins are logged at debug level. The AllowOriginFunc and
+// AllowOriginRequestFunc of defaults are always applied.
+//
+// Options rejected by ValidateOptions or NewOriginMatcher are logged once and not applied.
+// Instead, the last valid options are used, or CORS is disabled if there are none.
+func FromConfig(p *configx.Provider, prefix string, defaults cors.Options, l *logrusx.Logger) Resolver {
+	var (
+		// mu serializes compiling the options, state is read without locking.
+		mu    sync.Mutex
+		state atomic.Value
+	)
+	state.Store(new(configState))
+
+	return func(*http.Request) (*cors.Cors, bool) {
+		opts, enabled := p.CORS(prefix, defaults)
+		if !enabled {
+			return nil, false
+		}
+
+		if c, enabled, ok := state.Load().(*configState).resolve(opts); ok {
+			return c, enabled
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		current := state.Load().(*configState)
+		if c, enabled, ok := current.resolve(opts); ok {
+			return c, enabled
+		}
+
+		next := opts
+		next.AllowOriginFunc, next.AllowOriginRequestFunc = defaults.AllowOriginFunc, defaults.AllowOriginRequestFunc
+		err := ValidateOptions(next)
+		if err == nil {
+			next, err = WithOriginMatching(next, l)
+		}
+		if err != nil {
+			l.WithError(err).Error("The CORS configuration is invalid or insecure and was not applied. The last valid CORS configuration is used instead.")
+			state.Store(&configState{raw: current.raw, handler: current.handler, rejected: &opts})
+			return current.handler, current.handler != nil
+		}
+
+		c := cors.New(next)
+		state.Store(&configState{raw: &opts, handler: c})
+		return c, true
+	}
+}
+
+// configState is the last valid and the last rejected configuration of FromConfig. The options
+// are compared as read from the configuration, which never contains functions.
+type configState struct {
+	raw      *cors.Options
+	handler  *cors.Cors
+	rejected *cors.Options
+}
+
+// resolve returns the handler to use for opts, and false if opts are neither the last valid nor
+// the last rejected options.
+func (s *configState) resolve(opts cors.Options) (c *cors.Cors, enabled bool, ok bool) {
+	if s.raw != nil && reflect.DeepEqual(*s.raw, opts) {
+		return s.handler, true, true
+	}
+	if s.rejected != nil && reflect.DeepEqual(*s.rejected, opts) {
+		return s.handler, s.handler != nil, true
+	}
+	return nil, false, false
+}
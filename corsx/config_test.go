@@ -0,0 +1,95 @@
+package corsx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/configx"
+	"github.com/ory/x/logrusx"
+)
+
+func TestValidateOptions(t *testing.T) {
+	for _, tc := range []struct {
+		opts  cors.Options
+		valid bool
+	}{
+		{opts: cors.Options{AllowedOrigins: []string{"*"}}, valid: true},
+		{opts: cors.Options{AllowedOrigins: []string{"https://example.com"}, AllowCredentials: true}, valid: true},
+		{opts: cors.Options{AllowedOrigins: []string{"*"}, AllowCredentials: true}},
+		{opts: cors.Options{AllowedOrigins: []string{"https://example.com", "null"}, AllowCredentials: true}},
+		{opts: cors.Options{AllowCredentials: true}},
+	} {
+		err := ValidateOptions(tc.opts)
+		if tc.valid {
+			assert.NoError(t, err, "%+v", tc.opts)
+		} else {
+			assert.ErrorIs(t, err, ErrDangerousOptions, "%+v", tc.opts)
+		}
+	}
+}
+
+func TestFromConfig(t *testing.T) {
+	p, err := configx.New([]byte(`{}`), configx.WithValues(map[string]interface{}{
+		"serve.cors.enabled":         true,
+		"serve.cors.allowed_origins": []string{"https://a.example.com"},
+	}))
+	require.NoError(t, err)
+
+	l := logrusx.New("", "")
+	hook := test.NewLocal(l.Logger)
+	h := NewMiddleware(FromConfig(p, "serve", cors.Options{}, l)).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	allowed := func(origin string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Equal(t, "https://a.example.com", allowed("https://a.example.com"))
+	assert.Empty(t, allowed("https://b.example.com"))
+
+	require.NoError(t, p.Set("serve.cors.allowed_origins", []string{"https://b.example.com"}))
+	assert.Equal(t, "https://b.example.com", allowed("https://b.example.com"), "changes are applied without a restart")
+	assert.Empty(t, allowed("https://a.example.com"))
+
+	require.NoError(t, p.Set("serve.cors.allowed_origins", []string{"*"}))
+	require.NoError(t, p.Set("serve.cors.allow_credentials", true))
+	assert.Equal(t, "https://b.example.com", allowed("https://b.example.com"), "the last valid configuration is used")
+	assert.Empty(t, allowed("https://evil.example.com"))
+	assert.Len(t, hook.AllEntries(), 1, "the rejected configuration is logged once")
+
+	require.NoError(t, p.Set("serve.cors.enabled", false))
+	assert.Empty(t, allowed("https://b.example.com"))
+}
+
+func TestFromConfigCompilesOnce(t *testing.T) {
+	p, err := configx.New([]byte(`{}`), configx.WithValues(map[string]interface{}{
+		"serve.cors.enabled": true,
+	}))
+	require.NoError(t, err)
+
+	resolve := FromConfig(p, "serve", cors.Options{
+		AllowOriginFunc: func(origin string) bool { return origin == "https://a.example.com" },
+	}, logrusx.New("", ""))
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://a.example.com")
+
+	first, enabled := resolve(r)
+	require.True(t, enabled)
+	assert.True(t, first.OriginAllowed(r), "the origin function of the defaults is applied")
+
+	second, _ := resolve(r)
+	assert.Same(t, first, second, "unchanged options are not compiled again")
+
+	require.NoError(t, p.Set("serve.cors.max_age", 10))
+	third, _ := resolve(r)
+	assert.NotSame(t, first, third)
+}
@@ -0,0 +1,64 @@
+package corsx
+
+import (
+	"net/http"
+
+	"github.com/rs/cors"
+)
+
+type (
+	// Resolver returns the CORS handler for the request, for example depending on the tenant,
+	// host or route. If enabled is false, no CORS headers are set for the request. Resolvers
+	// should compile their options with cors.New once and not on every request.
+	Resolver func(r *http.Request) (c *cors.Cors, enabled bool)
+
+	// Middleware applies the CORS handler resolved for each request.
+	Middleware struct {
+		resolve Resolver
+	}
+)
+
+// NewMiddleware returns a middleware which resolves the CORS handler for every request.
+func NewMiddleware(resolve Resolver) *Middleware {
+	return &Middleware{resolve: resolve}
+}
+
+// StaticOptions returns a resolver which uses the same options for all requests.
+func StaticOptions(opts cors.Options, enabled bool) Resolver {
+	c := cors.New(opts)
+	return func(*http.Request) (*cors.Cors, bool) {
+		return c, enabled
+	}
+}
+
+// ByHost returns a resolver which uses the options of the request's host, for example the
+// domain of a tenant, and the fallback for all other hosts.
+func ByHost(hosts map[string]cors.Options, fallback Resolver) Resolver {
+	compiled := make(map[string]*cors.Cors, len(hosts))
+	for host, opts := range hosts {
+		compiled[host] = cors.New(opts)
+	}
+	return func(r *http.Request) (*cors.Cors, bool) {
+		if c, ok := compiled[r.Host]; ok {
+			return c, true
+		}
+		return fallback(r)
+	}
+}
+
+// ServeHTTP implements the negroni middleware interface.
+func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	c, enabled := m.resolve(r)
+	if !enabled || c == nil {
+		next(rw, r)
+		return
+	}
+	c.ServeHTTP(rw, r, next)
+}
+
+// Handler wraps the handler with the middleware.
+func (m *Middleware) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		m.ServeHTTP(rw, r, h.ServeHTTP)
+	})
+}
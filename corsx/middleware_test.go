@@ -0,0 +1,40 @@
+package corsx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/cors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	m := NewMiddleware(ByHost(map[string]cors.Options{
+		"tenant-a.example.com": {AllowedOrigins: []string{"https://a.example.com"}},
+		"tenant-b.example.com": {AllowedOrigins: []string{"https://b.example.com"}},
+	}, StaticOptions(cors.Options{}, false)))
+
+	h := m.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	for _, tc := range []struct {
+		host, origin, expected string
+	}{
+		{host: "tenant-a.example.com", origin: "https://a.example.com", expected: "https://a.example.com"},
+		{host: "tenant-a.example.com", origin: "https://b.example.com", expected: ""},
+		{host: "tenant-b.example.com", origin: "https://b.example.com", expected: "https://b.example.com"},
+		{host: "other.example.com", origin: "https://a.example.com", expected: ""},
+	} {
+		t.Run("host="+tc.host+"/origin="+tc.origin, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "http://"+tc.host+"/", nil)
+			r.Header.Set("Origin", tc.origin)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			assert.Equal(t, http.StatusNoContent, w.Code)
+			assert.Equal(t, tc.expected, w.Header().Get("Access-Control-Allow-Origin"))
+		})
+	}
+}
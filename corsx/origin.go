@@ -0,0 +1,149 @@
+package corsx
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/rs/cors"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/urlx"
+)
+
+// RegexPrefix marks an allowed origin as a regular expression, for example
+// "regex:https://(foo|bar)\.example\.com". The expression always has to match the whole origin.
+const RegexPrefix = "regex:"
+
+const maxPatternLength = 1024
+
+// OriginMatcher matches origins against a list of allowed origins, which may contain wildcards
+// and regular expressions.
+type OriginMatcher struct {
+	all      bool
+	exact    map[string]struct{}
+	origins  []string
+	patterns []*regexp.Regexp
+}
+
+// NewOriginMatcher compiles the allowed origins:
+//
+//   - "*" allows all origins.
+//   - A "*" within an origin, for example "https://*.example.com", matches one or more DNS labels,
+//     but no other characters, so that it can not match "https://evil.com/.example.com". If the
+//     wildcard is not followed by a dot, for example in "http://localhost:*", it matches a single
+//     label.
+//   - Origins with the prefix "regex:" are regular expressions which must match the whole origin.
+//   - Everything else must match exactly, ignoring case and default ports, so "https://example.org"
+//     also allows "https://example.org:443".
+//
+// Origins and subdomain wildcards are matched with urlx.MatchesOrigin.
+func NewOriginMatcher(origins []string) (*OriginMatcher, error) {
+	m := &OriginMatcher{exact: make(map[string]struct{})}
+	for _, o := range origins {
+		if len(o) > maxPatternLength {
+			return nil, errors.Errorf("the allowed origin must not be longer than %d characters", maxPatternLength)
+		}
+
+		switch {
+		case o == "*":
+			m.all = true
+		case strings.HasPrefix(o, RegexPrefix):
+			expr := strings.TrimPrefix(o, RegexPrefix)
+			if expr == "" {
+				return nil, errors.New("the regular expression of an allowed origin must not be empty")
+			}
+			re, err := regexp.Compile("(?i)^(?:" + expr + ")$")
+			if err != nil {
+				return nil, errors.Wrapf(err, `unable to compile the allowed origin "%s"`, o)
+			}
+			m.patterns = append(m.patterns, re)
+		case isOrigin(strings.Replace(o, "://*.", "://", 1)):
+			m.origins = append(m.origins, o)
+		case strings.Contains(o, "*"):
+			parts := strings.Split(strings.ToLower(o), "*")
+			expr := "^" + regexp.QuoteMeta(parts[0])
+			for _, part := range parts[1:] {
+				if strings.HasPrefix(part, ".") {
+					// A subdomain wildcard matches one or more labels.
+					expr += `[a-z0-9-]+(?:\.[a-z0-9-]+)*`
+				} else {
+					// Any other wildcard, for example for the port, matches a single label.
+					expr += `[a-z0-9-]+`
+				}
+				expr += regexp.QuoteMeta(part)
+			}
+			m.patterns = append(m.patterns, regexp.MustCompile(expr+"$"))
+		default:
+			// Values which are not an origin, like "null", are compared as they are.
+			m.exact[strings.ToLower(o)] = struct{}{}
+		}
+	}
+	return m, nil
+}
+
+// Match returns true if the origin is allowed.
+func (m *OriginMatcher) Match(origin string) bool {
+	if m.all {
+		return true
+	}
+	if len(m.origins) > 0 && isOrigin(origin) {
+		u, _ := url.Parse(origin)
+		for _, o := range m.origins {
+			if urlx.MatchesOrigin(o, u) {
+				return true
+			}
+		}
+	}
+	origin = strings.ToLower(origin)
+	if _, ok := m.exact[origin]; ok {
+		return true
+	}
+	for _, re := range m.patterns {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// isOrigin returns true if s is an origin in the form scheme://host[:port] without wildcards.
+func isOrigin(s string) bool {
+	if strings.Contains(s, "*") {
+		return false
+	}
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != "" && u.User == nil && u.Path == "" &&
+		u.RawQuery == "" && !u.ForceQuery && u.Fragment == ""
+}
+
+// WithOriginMatching replaces the allowed origins of the options with an OriginMatcher, so that
+// they can contain wildcards and regular expressions. If l is not nil, rejected origins are
+// logged at debug level to help troubleshooting misconfigured frontends.
+//
+// Validate the options with ValidateOptions before, because the allowed origins are no longer
+// visible afterwards.
+func WithOriginMatching(opts cors.Options, l *logrusx.Logger) (cors.Options, error) {
+	if len(opts.AllowedOrigins) == 0 {
+		return opts, nil
+	}
+
+	m, err := NewOriginMatcher(opts.AllowedOrigins)
+	if err != nil {
+		return opts, err
+	}
+
+	opts.AllowedOrigins = nil
+	opts.AllowOriginRequestFunc = func(r *http.Request, origin string) bool {
+		if m.Match(origin) {
+			return true
+		}
+		if l != nil {
+			l.WithRequest(r).WithField("origin", origin).Debug("Rejected the request origin because it is not an allowed CORS origin.")
+		}
+		return false
+	}
+	return opts, nil
+}
@@ -0,0 +1,82 @@
+package corsx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/rs/cors"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+)
+
+func TestOriginMatcher(t *testing.T) {
+	m, err := NewOriginMatcher([]string{
+		"https://exact.example.org",
+		"https://*.example.com",
+		"http://localhost:*",
+		`regex:https://(foo|bar)\.example\.net`,
+	})
+	require.NoError(t, err)
+
+	for origin, expected := range map[string]bool{
+		"https://exact.example.org":          true,
+		"https://EXACT.example.org":          true,
+		"https://exact.example.org:443":      true,
+		"https://exact.example.org:8443":     false,
+		"https://foo.example.com:443":        true,
+		"https://exact.example.org/path":     false,
+		"https://foo.example.com":            true,
+		"https://foo.bar.example.com":        true,
+		"https://example.com":                false,
+		"http://foo.example.com":             false,
+		"https://evil.com/.example.com":      false,
+		"https://evil.com?.example.com":      false,
+		"https://user@evil.com#.example.com": false,
+		"http://localhost:3000":              true,
+		"http://localhost:3000.evil.com":     false,
+		"https://foo.example.net":            true,
+		"https://baz.example.net":            false,
+		"https://foo.example.net.evil.com":   false,
+		"https://other.example.org":          false,
+	} {
+		assert.Equal(t, expected, m.Match(origin), origin)
+	}
+
+	all, err := NewOriginMatcher([]string{"*"})
+	require.NoError(t, err)
+	assert.True(t, all.Match("https://anything.example.com"))
+
+	for _, invalid := range []string{"regex:", "regex:(", "regex:" + string(make([]byte, 2000))} {
+		_, err := NewOriginMatcher([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestWithOriginMatching(t *testing.T) {
+	l := logrusx.New("", "", logrusx.ForceLevel(logrus.DebugLevel))
+	hook := test.NewLocal(l.Logger)
+
+	opts, err := WithOriginMatching(cors.Options{AllowedOrigins: []string{"https://*.example.com"}}, l)
+	require.NoError(t, err)
+	h := cors.New(opts).Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	allowed := func(origin string) string {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	assert.Equal(t, "https://foo.example.com", allowed("https://foo.example.com"))
+	assert.Empty(t, hook.AllEntries())
+
+	assert.Empty(t, allowed("https://evil.com"))
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "https://evil.com", hook.LastEntry().Data["origin"])
+}
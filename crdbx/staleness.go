@@ -0,0 +1,92 @@
+package crdbx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/dbal"
+)
+
+// FollowerReads, when used as the staleness, reads at follower_read_timestamp(). This is the
+// most recent timestamp at which any replica, not only the leaseholder, can serve the read.
+const FollowerReads time.Duration = -1
+
+// ConsistencyLevel is the consistency a caller, for example a listing endpoint, asks for.
+type ConsistencyLevel string
+
+const (
+	// ConsistencyLevelUnset means that the caller did not ask for a consistency level.
+	ConsistencyLevelUnset ConsistencyLevel = ""
+	// ConsistencyLevelStrong reads the most recent data.
+	ConsistencyLevelStrong ConsistencyLevel = "strong"
+	// ConsistencyLevelEventual reads data which may be slightly stale, but is cheap to read.
+	ConsistencyLevelEventual ConsistencyLevel = "eventual"
+)
+
+// ConsistencyLevelFromString parses the consistency level. Unknown values are ConsistencyLevelUnset.
+func ConsistencyLevelFromString(in string) ConsistencyLevel {
+	switch l := ConsistencyLevel(in); l {
+	case ConsistencyLevelStrong, ConsistencyLevelEventual:
+		return l
+	}
+	return ConsistencyLevelUnset
+}
+
+// ConsistencyLevelFromRequest returns the consistency level of the "consistency" query parameter.
+func ConsistencyLevelFromRequest(r *http.Request) ConsistencyLevel {
+	return ConsistencyLevelFromString(r.URL.Query().Get("consistency"))
+}
+
+// Staleness returns the staleness tolerance for the consistency level. Strong reads are never
+// stale, eventual reads use the configured staleness, and if the level is unset, fallback is
+// used instead.
+func (l ConsistencyLevel) Staleness(staleness time.Duration, fallback ConsistencyLevel) time.Duration {
+	if l == ConsistencyLevelUnset {
+		l = fallback
+	}
+	if l == ConsistencyLevelEventual {
+		return staleness
+	}
+	return 0
+}
+
+// AsOfSystemTime returns the AS OF SYSTEM TIME clause for reads which may be stale by up to
+// staleness. It returns an empty string if the staleness is zero, which means a strong read.
+func AsOfSystemTime(staleness time.Duration) string {
+	switch {
+	case staleness == FollowerReads:
+		return "AS OF SYSTEM TIME follower_read_timestamp()"
+	case staleness <= 0:
+		return ""
+	}
+	return fmt.Sprintf("AS OF SYSTEM TIME '-%ss'", strconv.FormatFloat(staleness.Seconds(), 'f', -1, 64))
+}
+
+// SetTransactionStaleness makes the reads of the transaction c stale by up to staleness, which
+// also makes the transaction read-only. It does nothing if the staleness is zero or if the
+// database is not CockroachDB.
+func SetTransactionStaleness(c *pop.Connection, staleness time.Duration) error {
+	clause := AsOfSystemTime(staleness)
+	if clause == "" || c.Dialect.Name() != dbal.DriverCockroachDB {
+		return nil
+	}
+	return errors.WithStack(c.RawQuery("SET TRANSACTION " + clause).Exec())
+}
+
+// StaleRead runs fn in a transaction whose reads may be stale by up to staleness. On
+// CockroachDB, such reads can be served by the nearest replica and do not conflict with writes.
+// On other databases, and if the staleness is zero, fn runs in a regular transaction.
+func StaleRead(ctx context.Context, c *pop.Connection, staleness time.Duration, fn func(tx *pop.Connection) error) error {
+	return c.WithContext(ctx).Transaction(func(tx *pop.Connection) error {
+		if err := SetTransactionStaleness(tx, staleness); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
@@ -0,0 +1,37 @@
+package crdbx
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAsOfSystemTime(t *testing.T) {
+	for _, tc := range []struct {
+		staleness time.Duration
+		expected  string
+	}{
+		{staleness: 0, expected: ""},
+		{staleness: -time.Second, expected: ""},
+		{staleness: FollowerReads, expected: "AS OF SYSTEM TIME follower_read_timestamp()"},
+		{staleness: 10 * time.Second, expected: "AS OF SYSTEM TIME '-10s'"},
+		{staleness: 4800 * time.Millisecond, expected: "AS OF SYSTEM TIME '-4.8s'"},
+	} {
+		t.Run("staleness="+tc.staleness.String(), func(t *testing.T) {
+			assert.Equal(t, tc.expected, AsOfSystemTime(tc.staleness))
+		})
+	}
+}
+
+func TestConsistencyLevel(t *testing.T) {
+	assert.Equal(t, ConsistencyLevelEventual, ConsistencyLevelFromRequest(httptest.NewRequest("GET", "/?consistency=eventual", nil)))
+	assert.Equal(t, ConsistencyLevelStrong, ConsistencyLevelFromRequest(httptest.NewRequest("GET", "/?consistency=strong", nil)))
+	assert.Equal(t, ConsistencyLevelUnset, ConsistencyLevelFromRequest(httptest.NewRequest("GET", "/?consistency=foo", nil)))
+
+	assert.Equal(t, 5*time.Second, ConsistencyLevelEventual.Staleness(5*time.Second, ConsistencyLevelStrong))
+	assert.Equal(t, time.Duration(0), ConsistencyLevelStrong.Staleness(5*time.Second, ConsistencyLevelEventual))
+	assert.Equal(t, 5*time.Second, ConsistencyLevelUnset.Staleness(5*time.Second, ConsistencyLevelEventual))
+	assert.Equal(t, time.Duration(0), ConsistencyLevelUnset.Staleness(5*time.Second, ConsistencyLevelStrong))
+}
@@ -0,0 +1,106 @@
+package dbal
+
+import (
+	"strings"
+	"sync"
+)
+
+// Capability is a feature which only some databases support.
+type Capability string
+
+const (
+	// CapabilityJSONB is set if the database has a binary JSON column type which can be indexed and queried.
+	CapabilityJSONB Capability = "jsonb"
+
+	// CapabilityUpsertOnConflict is set if the database supports `INSERT ... ON CONFLICT (...) DO UPDATE`.
+	CapabilityUpsertOnConflict Capability = "upsert_on_conflict"
+
+	// CapabilityUpsertOnDuplicateKey is set if the database supports `INSERT ... ON DUPLICATE KEY UPDATE`.
+	CapabilityUpsertOnDuplicateKey Capability = "upsert_on_duplicate_key"
+
+	// CapabilityAdvisoryLocks is set if the database supports application defined locks, for example
+	// `pg_advisory_lock` in PostgreSQL or `GET_LOCK` in MySQL.
+	CapabilityAdvisoryLocks Capability = "advisory_locks"
+
+	// CapabilityListenNotify is set if the database supports `LISTEN` and `NOTIFY`.
+	CapabilityListenNotify Capability = "listen_notify"
+)
+
+// DriverSQLite is the name under which the capabilities of SQLite are registered.
+const DriverSQLite = "sqlite"
+
+// Capabilities is a set of capabilities.
+type Capabilities []Capability
+
+// Has returns true if the set contains c.
+func (cs Capabilities) Has(c Capability) bool {
+	for _, have := range cs {
+		if have == c {
+			return true
+		}
+	}
+	return false
+}
+
+// CapabilitiesProvider can be implemented by a Driver to declare the capabilities of the database
+// it handles.
+type CapabilitiesProvider interface {
+	Capabilities() Capabilities
+}
+
+var (
+	capabilities = map[string]Capabilities{
+		DriverPostgreSQL:  {CapabilityJSONB, CapabilityUpsertOnConflict, CapabilityAdvisoryLocks, CapabilityListenNotify},
+		DriverCockroachDB: {CapabilityJSONB, CapabilityUpsertOnConflict},
+		DriverMySQL:       {CapabilityUpsertOnDuplicateKey, CapabilityAdvisoryLocks},
+		DriverSQLite:      {CapabilityUpsertOnConflict},
+	}
+	cmtx sync.RWMutex
+)
+
+func dialectName(dialect string) string {
+	if IsSQLite(dialect + "://") {
+		return DriverSQLite
+	}
+	if d := Canonicalize(dialect); d != UnknownDriver {
+		return d
+	}
+	return dialect
+}
+
+// RegisterCapabilities sets the capabilities of the dialect, replacing capabilities registered before.
+func RegisterCapabilities(dialect string, cs ...Capability) {
+	cmtx.Lock()
+	defer cmtx.Unlock()
+	capabilities[dialectName(dialect)] = cs
+}
+
+// GetCapabilities returns the capabilities of the dialect, for example "postgres", "pgx", "cockroach",
+// "mysql", or "sqlite3". Unknown dialects have no capabilities.
+func GetCapabilities(dialect string) Capabilities {
+	cmtx.RLock()
+	defer cmtx.RUnlock()
+	return capabilities[dialectName(dialect)]
+}
+
+// HasCapability returns true if the dialect has the capability.
+func HasCapability(dialect string, c Capability) bool {
+	return GetCapabilities(dialect).Has(c)
+}
+
+// GetCapabilitiesFor returns the capabilities of the database the DSN points to. Drivers registered
+// with RegisterDriver which implement CapabilitiesProvider take precedence over the capabilities
+// registered for the DSN scheme.
+func GetCapabilitiesFor(dsn string) Capabilities {
+	dmtx.Lock()
+	fs := append([]func() Driver{}, drivers...)
+	dmtx.Unlock()
+
+	for _, f := range fs {
+		d := f()
+		if p, ok := d.(CapabilitiesProvider); ok && d.CanHandle(dsn) {
+			return p.Capabilities()
+		}
+	}
+	return GetCapabilities(strings.SplitN(dsn, "://", 2)[0])
+}
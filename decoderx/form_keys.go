@@ -0,0 +1,220 @@
+package decoderx
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/x/jsonschemax"
+)
+
+// formField is a form field whose name was resolved against the JSON Schema paths.
+type formField struct {
+	// key is the name of the form field, for example `items[0][name]`.
+	key string
+
+	// path is the JSON Schema path, for example `items.#.name`.
+	path jsonschemax.Path
+
+	// segments of the destination in the JSON document, for example `items`, `0`, `name`.
+	segments []string
+
+	// index is true for the segments which are array indices.
+	index []bool
+}
+
+// target returns the destination of the form field in sjson syntax.
+func (f *formField) target() string {
+	escaped := make([]string, len(f.segments))
+	for i, s := range f.segments {
+		if f.index[i] {
+			escaped[i] = s
+			continue
+		}
+		s = strings.NewReplacer(`\`, `\\`, ".", `\.`, "*", `\*`, "?", `\?`).Replace(s)
+		if _, err := strconv.Atoi(s); err == nil {
+			s = ":" + s
+		}
+		escaped[i] = s
+	}
+	return strings.Join(escaped, ".")
+}
+
+// splitFormKey splits a form field name in dot-notation, bracket-notation, or a mix of both
+// into its segments: `traits[address][city]`, `traits.address.city`, and `traits[address].city`
+// are all split into `traits`, `address`, `city`. Trailing empty brackets (`tags[]`) are
+// removed.
+func splitFormKey(key string) ([]string, error) {
+	var (
+		segments []string
+		current  strings.Builder
+		inBrace  bool
+		closed   bool
+	)
+	for _, r := range key {
+		switch {
+		case r == '[' && !inBrace:
+			if current.Len() > 0 || (!closed && len(segments) == 0) {
+				segments = append(segments, current.String())
+			}
+			current.Reset()
+			inBrace, closed = true, false
+		case r == ']' && inBrace:
+			segments = append(segments, current.String())
+			current.Reset()
+			inBrace, closed = false, true
+		case r == '[' || r == ']':
+			return nil, errors.Errorf("unexpected %q", r)
+		case r == '.' && !inBrace:
+			if !closed {
+				segments = append(segments, current.String())
+			}
+			current.Reset()
+			closed = false
+		default:
+			if closed {
+				return nil, errors.New("expected a dot or a bracket after a closing bracket")
+			}
+			current.WriteRune(r)
+		}
+	}
+	if inBrace {
+		return nil, errors.New("missing closing bracket")
+	}
+	if !closed {
+		segments = append(segments, current.String())
+	}
+
+	if l := len(segments); l > 1 && strings.HasSuffix(key, "[]") {
+		segments = segments[:l-1]
+	}
+	for _, s := range segments {
+		if s == "" {
+			return nil, errors.New("empty names and empty brackets are only allowed at the end")
+		}
+	}
+	return segments, nil
+}
+
+// resolveFormFields resolves the form field names against the JSON Schema paths. Fields which
+// do not match a path are ignored. Numeric segments are array indices if the JSON Schema
+// defines an array at that position. Fields which set the same value more than once, which set
+// a value and one of its children, or whose array indices are not contiguous are rejected.
+func resolveFormFields(values url.Values, paths []jsonschemax.Path) ([]*formField, error) {
+	byName := make(map[string]jsonschemax.Path, len(paths))
+	prefixes := map[string]bool{}
+	for _, p := range paths {
+		byName[p.Name] = p
+		parts := strings.Split(p.Name, ".")
+		for i := range parts {
+			prefixes[strings.Join(parts[:i+1], ".")] = true
+		}
+	}
+
+	var fields []*formField
+	for key := range values {
+		segments, err := splitFormKey(key)
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to parse form field name %q: %s", key, err).WithDetail("name", key))
+		}
+
+		f := &formField{key: key, segments: segments, index: make([]bool, len(segments))}
+		schema := make([]string, 0, len(segments))
+		for i, s := range segments {
+			isArray := prefixes[strings.Join(append(schema, "#"), ".")]
+			if _, ok := parseIndex(s); ok && isArray {
+				schema = append(schema, "#")
+				f.index[i] = true
+				continue
+			} else if isArray && !prefixes[strings.Join(append(schema, s), ".")] {
+				return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Form field %q uses %q where an array index is expected.", key, s).WithDetail("name", key))
+			}
+			schema = append(schema, s)
+		}
+
+		path, ok := byName[strings.Join(schema, ".")]
+		if !ok {
+			continue
+		}
+		f.path = path
+		fields = append(fields, f)
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		return lessSegments(fields[i], fields[j])
+	})
+
+	if err := checkFormFields(fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// parseIndex parses an array index without sign and leading zeros.
+func parseIndex(s string) (int, bool) {
+	n, err := strconv.Atoi(s)
+	return n, err == nil && n >= 0 && strconv.Itoa(n) == s
+}
+
+func lessSegments(a, b *formField) bool {
+	for i := 0; i < len(a.segments) && i < len(b.segments); i++ {
+		if a.segments[i] == b.segments[i] {
+			continue
+		}
+		if a.index[i] && b.index[i] {
+			x, _ := parseIndex(a.segments[i])
+			y, _ := parseIndex(b.segments[i])
+			return x < y
+		}
+		return a.segments[i] < b.segments[i]
+	}
+	if len(a.segments) != len(b.segments) {
+		return len(a.segments) < len(b.segments)
+	}
+	return a.key < b.key
+}
+
+func checkFormFields(fields []*formField) error {
+	targets := make(map[string]*formField, len(fields))
+	for _, f := range fields {
+		target := strings.Join(f.segments, "\x00")
+		if other, ok := targets[target]; ok {
+			return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Form fields %q and %q refer to the same value.", other.key, f.key).WithDetail("name", f.key))
+		}
+		targets[target] = f
+	}
+
+	indices := map[string]map[int]bool{}
+	for _, f := range fields {
+		for i := range f.segments {
+			if i < len(f.segments)-1 {
+				if other, ok := targets[strings.Join(f.segments[:i+1], "\x00")]; ok {
+					return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Form fields %q and %q are ambiguous because the value of the first contains the second.", other.key, f.key).WithDetail("name", f.key))
+				}
+			}
+			if f.index[i] {
+				array := strings.Join(f.segments[:i], "\x00")
+				if indices[array] == nil {
+					indices[array] = map[int]bool{}
+				}
+				n, _ := parseIndex(f.segments[i])
+				indices[array][n] = true
+			}
+		}
+	}
+
+	for array, set := range indices {
+		for i := 0; i < len(set); i++ {
+			if !set[i] {
+				name := strings.ReplaceAll(array, "\x00", ".")
+				return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Form fields of array %q must use the indices 0 to %d but index %d is missing.", name, len(set)-1, i).WithDetail("name", name))
+			}
+		}
+	}
+	return nil
+}
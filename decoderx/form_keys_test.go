@@ -0,0 +1,170 @@
+package decoderx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/assertx"
+)
+
+func TestSplitFormKey(t *testing.T) {
+	for key, expected := range map[string][]string{
+		"name":                  {"name"},
+		"traits.address.city":   {"traits", "address", "city"},
+		"traits[address][city]": {"traits", "address", "city"},
+		"traits[address].city":  {"traits", "address", "city"},
+		"items[0].name":         {"items", "0", "name"},
+		"items.0.name":          {"items", "0", "name"},
+		"tags[]":                {"tags"},
+		"a[b.c]":                {"a", "b.c"},
+	} {
+		actual, err := splitFormKey(key)
+		require.NoError(t, err, key)
+		assert.Equal(t, expected, actual, key)
+	}
+
+	for _, key := range []string{"a[b", "a]b", "a[b]c", "[a]", "a..b", "a[][b]", "a[[b]]"} {
+		_, err := splitFormKey(key)
+		assert.Error(t, err, key)
+	}
+}
+
+func TestHTTPFormDecoderNestedFields(t *testing.T) {
+	schema := MustHTTPRawJSONSchemaCompiler([]byte(`{
+  "type": "object",
+  "properties": {
+    "traits": {
+      "type": "object",
+      "properties": {
+        "email": {"type": "string"},
+        "address": {
+          "type": "object",
+          "properties": {
+            "city": {"type": "string"},
+            "zip": {"type": "integer"}
+          }
+        }
+      }
+    },
+    "items": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "quantity": {"type": "integer"},
+          "tags": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    },
+    "scores": {"type": "array", "items": {"type": "number"}},
+    "labels": {"type": "array", "items": {"type": "string"}}
+  }
+}`))
+
+	for k, tc := range []struct {
+		d             string
+		values        url.Values
+		expected      string
+		expectedError string
+	}{
+		{
+			d: "bracket and dot notation",
+			values: url.Values{
+				"traits[email]":         {"foo@example.com"},
+				"traits[address][city]": {"Berlin"},
+				"traits.address.zip":    {"10115"},
+			},
+			expected: `{"traits": {"email": "foo@example.com", "address": {"city": "Berlin", "zip": 10115}}}`,
+		},
+		{
+			d: "arrays of objects",
+			values: url.Values{
+				"items[1].name":     {"Pear"},
+				"items[0].name":     {"Apple"},
+				"items[0].quantity": {"3"},
+				"items[1][tags][]":  {"green", "ripe"},
+				"items[10].name":    {"Banana"},
+				"items[2].name":     {"Plum"},
+				"items[3].name":     {"Plum"},
+				"items[4].name":     {"Plum"},
+				"items[5].name":     {"Plum"},
+				"items[6].name":     {"Plum"},
+				"items[7].name":     {"Plum"},
+				"items[8].name":     {"Plum"},
+				"items[9].name":     {"Plum"},
+			},
+			expected: `{"traits": {"address": {}}, "items": [
+  {"name": "Apple", "quantity": 3},
+  {"name": "Pear", "tags": ["green", "ripe"]},
+  {"name": "Plum"}, {"name": "Plum"}, {"name": "Plum"}, {"name": "Plum"},
+  {"name": "Plum"}, {"name": "Plum"}, {"name": "Plum"}, {"name": "Plum"},
+  {"name": "Banana"}
+]}`,
+		},
+		{
+			d: "arrays of primitives",
+			values: url.Values{
+				"scores[0]": {"1.5"},
+				"scores[1]": {"2"},
+				"labels[]":  {"a", "b"},
+			},
+			expected: `{"traits": {"address": {}}, "scores": [1.5, 2], "labels": ["a", "b"]}`,
+		},
+		{
+			d:             "the same value twice",
+			values:        url.Values{"traits[email]": {"a@example.com"}, "traits.email": {"b@example.com"}},
+			expectedError: `Form fields "traits.email" and "traits[email]" refer to the same value.`,
+		},
+		{
+			d:             "a value and its children",
+			values:        url.Values{"traits[address]": {"Berlin"}, "traits[address][city]": {"Berlin"}},
+			expectedError: `Form fields "traits[address]" and "traits[address][city]" are ambiguous`,
+		},
+		{
+			d:             "missing indices",
+			values:        url.Values{"items[0].name": {"Apple"}, "items[2].name": {"Pear"}},
+			expectedError: `Form fields of array "items" must use the indices 0 to 1 but index 1 is missing.`,
+		},
+		{
+			d:             "huge indices",
+			values:        url.Values{"items[1000000000].name": {"Apple"}},
+			expectedError: `index 0 is missing`,
+		},
+		{
+			d:             "name instead of index",
+			values:        url.Values{"items[first].name": {"Apple"}},
+			expectedError: `Form field "items[first].name" uses "first" where an array index is expected.`,
+		},
+		{
+			d:             "malformed name",
+			values:        url.Values{"items[0.name": {"Apple"}},
+			expectedError: `Unable to parse form field name "items[0.name": missing closing bracket`,
+		},
+		{
+			d:        "unknown fields are ignored",
+			values:   url.Values{"unknown[0]": {"x"}, "traits[unknown]": {"x"}},
+			expected: `{"traits": {"address": {}}}`,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			var destination json.RawMessage
+			err := NewHTTP().Decode(
+				newRequest(t, "POST", "/", bytes.NewBufferString(tc.values.Encode()), httpContentTypeURLEncodedForm),
+				&destination, schema, HTTPDecoderSetValidatePayloads(false))
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, fmt.Sprintf("%+v", err), tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assertx.EqualAsJSON(t, json.RawMessage(tc.expected), destination)
+		})
+	}
+}
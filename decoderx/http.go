@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,6 +17,8 @@ import (
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 
+	"google.golang.org/grpc/codes"
+
 	"github.com/ory/jsonschema/v3"
 
 	"github.com/ory/herodot"
@@ -26,7 +29,7 @@ import (
 )
 
 type (
-	// HTTP decodes json and form-data from HTTP Request Bodies.
+	// HTTP decodes JSON, XML, and form-data from HTTP Request Bodies.
 	HTTP struct{}
 
 	httpDecoderOptions struct {
@@ -40,8 +43,16 @@ type (
 		handleParseErrors         parseErrorStrategy
 		expectJSONFlattened       bool
 		queryAndBody              bool
+		maxMultipartMemory        int64
+		multipartFiles            MultipartFiles
+		maxBodySize               int64
+		streamingValidation       bool
 	}
 
+	// MultipartFiles collects the file parts of multipart/form-data request bodies by form
+	// field name. Use File.Open to read a file.
+	MultipartFiles map[string][]*multipart.FileHeader
+
 	// HTTPDecoderOption configures the HTTP decoder.
 	HTTPDecoderOption func(*httpDecoderOptions)
 
@@ -52,8 +63,14 @@ const (
 	httpContentTypeMultipartForm  = "multipart/form-data"
 	httpContentTypeURLEncodedForm = "application/x-www-form-urlencoded"
 	httpContentTypeJSON           = "application/json"
+	httpContentTypeXML            = "application/xml"
+	httpContentTypeTextXML        = "text/xml"
 )
 
+// DefaultMaxMultipartMemory is the default maximum size of a multipart/form-data request body
+// which is kept in memory. Larger file parts are stored in temporary files.
+const DefaultMaxMultipartMemory = 32 << 20
+
 const (
 	// ParseErrorIgnoreConversionErrors will ignore any errors caused by strconv.Parse* and use the
 	// raw form field value, which is a string, when such a parse error occurs.
@@ -81,6 +98,14 @@ const (
 
 var errKeyNotFound = errors.New("key not found")
 
+// ErrPayloadTooLarge is returned if the HTTP request body is larger than the maximum body size.
+var ErrPayloadTooLarge = &herodot.DefaultError{
+	CodeField:     http.StatusRequestEntityTooLarge,
+	GRPCCodeField: codes.ResourceExhausted,
+	StatusField:   http.StatusText(http.StatusRequestEntityTooLarge),
+	ErrorField:    "The request body is too large",
+}
+
 // HTTPFormDecoder configures the HTTP decoder to only accept form-data
 // (application/x-www-form-urlencoded, multipart/form-data)
 func HTTPFormDecoder() HTTPDecoderOption {
@@ -97,6 +122,55 @@ func HTTPJSONDecoder() HTTPDecoderOption {
 	}
 }
 
+// HTTPXMLDecoder configures the HTTP decoder to only accept XML
+// (application/xml, text/xml).
+func HTTPXMLDecoder() HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.allowedContentTypes = []string{httpContentTypeXML, httpContentTypeTextXML}
+	}
+}
+
+// HTTPDecoderMultipartMaxMemory sets the maximum size of a multipart/form-data request body
+// which is kept in memory. Defaults to DefaultMaxMultipartMemory.
+func HTTPDecoderMultipartMaxMemory(size int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxMultipartMemory = size
+	}
+}
+
+// HTTPDecoderMultipartFiles adds the file parts of multipart/form-data request bodies to files.
+//
+// The file names are also decoded like form fields, so that the JSON Schema can require a file
+// using `{"avatar": {"type": "string"}}`.
+func HTTPDecoderMultipartFiles(files MultipartFiles) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.multipartFiles = files
+	}
+}
+
+// HTTPDecoderMaxBodySize sets the maximum size of the HTTP request body in bytes. Requests
+// which announce a larger body in the Content-Length header are rejected before the body is
+// read, and reading other bodies stops once the limit is exceeded. Both fail with
+// ErrPayloadTooLarge. Defaults to no limit.
+func HTTPDecoderMaxBodySize(size int64) HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.maxBodySize = size
+	}
+}
+
+// HTTPDecoderStreamingValidation validates JSON request bodies against the JSON Schema while
+// they are read, so that payloads with unknown properties (if additional properties are not
+// allowed), wrong types, or too long strings and arrays are rejected without reading the rest
+// of the body. The payload is validated completely once it was read.
+//
+// If the request body is kept (see HTTPKeepRequestBody), it is read completely before it is
+// validated.
+func HTTPDecoderStreamingValidation() HTTPDecoderOption {
+	return func(o *httpDecoderOptions) {
+		o.streamingValidation = true
+	}
+}
+
 // HTTPKeepRequestBody configures the HTTP decoder to allow other
 // HTTP request body readers to read the body as well by keeping
 // the data in memory.
@@ -202,10 +276,12 @@ func newHTTPDecoderOptions(fs []HTTPDecoderOption) *httpDecoderOptions {
 	o := &httpDecoderOptions{
 		allowedContentTypes: []string{
 			httpContentTypeMultipartForm, httpContentTypeURLEncodedForm, httpContentTypeJSON,
+			httpContentTypeXML, httpContentTypeTextXML,
 		},
 		allowedHTTPMethods:        []string{"POST", "PUT", "PATCH"},
 		maxCircularReferenceDepth: 5,
 		handleParseErrors:         ParseErrorIgnoreConversionErrors,
+		maxMultipartMemory:        DefaultMaxMultipartMemory,
 	}
 
 	for _, f := range fs {
@@ -228,6 +304,10 @@ func (t *HTTP) validateRequest(r *http.Request, c *httpDecoderOptions) error {
 	}
 
 	if method != "GET" {
+		if c.maxBodySize > 0 && r.ContentLength > c.maxBodySize {
+			return errors.WithStack(ErrPayloadTooLarge.WithReasonf("The HTTP request body must not be larger than %d bytes but its HTTP Header \"Content-Length\" is %d.", c.maxBodySize, r.ContentLength))
+		}
+
 		if r.ContentLength == 0 && method != "GET" {
 			return errors.WithStack(herodot.ErrBadRequest.WithReasonf(`Unable to decode HTTP Request Body because its HTTP Header "Content-Length" is zero.`))
 		}
@@ -271,6 +351,10 @@ func (t *HTTP) Decode(r *http.Request, destination interface{}, opts ...HTTPDeco
 		return err
 	}
 
+	if c.maxBodySize > 0 && r.Body != nil {
+		r.Body = &limitedBody{ReadCloser: r.Body, remaining: c.maxBodySize}
+	}
+
 	if r.Method == "GET" {
 		return t.decodeForm(r, destination, c)
 	} else if httpx.HasContentType(r, httpContentTypeJSON) {
@@ -280,6 +364,8 @@ func (t *HTTP) Decode(r *http.Request, destination interface{}, opts ...HTTPDeco
 		return t.decodeJSON(r, destination, c, false)
 	} else if httpx.HasContentType(r, httpContentTypeMultipartForm, httpContentTypeURLEncodedForm) {
 		return t.decodeForm(r, destination, c)
+	} else if httpx.HasContentType(r, httpContentTypeXML, httpContentTypeTextXML) {
+		return t.decodeXML(r, destination, c)
 	}
 
 	return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to determine decoder for content type: %s", r.Header.Get("Content-Type")))
@@ -295,7 +381,9 @@ func (t *HTTP) requestBody(r *http.Request, o *httpDecoderOptions) (reader io.Re
 	}
 
 	bodyBytes, err := ioutil.ReadAll(r.Body)
-	if err != nil {
+	if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+		return nil, tooLarge
+	} else if err != nil {
 		return nil, errors.Wrapf(err, "unable to read body")
 	}
 
@@ -310,7 +398,7 @@ func (t *HTTP) decodeJSONForm(r *http.Request, destination interface{}, o *httpD
 		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode HTTP Form Body because no validation schema was provided. This is a code bug."))
 	}
 
-	paths, err := jsonschemax.ListPathsWithRecursion(o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	paths, err := jsonschemax.ListPathsWithRecursionAndArraysIncluded(o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
 	if err != nil {
 		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP Post Body Form parsing: %s", err).WithDebugf("%+v", err))
 	}
@@ -322,6 +410,9 @@ func (t *HTTP) decodeJSONForm(r *http.Request, destination interface{}, o *httpD
 
 	var interim json.RawMessage
 	if err := json.NewDecoder(reader).Decode(&interim); err != nil {
+		if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+			return tooLarge
+		}
 		return err
 	}
 
@@ -379,19 +470,37 @@ func (t *HTTP) decodeForm(r *http.Request, destination interface{}, o *httpDecod
 	}()
 
 	if err := r.ParseForm(); err != nil {
+		if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+			return tooLarge
+		}
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode HTTP %s form body: %s", strings.ToUpper(r.Method), err).WithDebug(err.Error()))
 	}
 
-	paths, err := jsonschemax.ListPathsWithRecursion(o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
-	if err != nil {
-		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP Post Body Form parsing: %s", err).WithDebugf("%+v", err))
-	}
-
 	values := r.PostForm
 	if r.Method == "GET" || o.queryAndBody {
 		values = r.Form
 	}
 
+	if r.Method != "GET" && httpx.HasContentType(r, httpContentTypeMultipartForm) {
+		if err := r.ParseMultipartForm(o.maxMultipartMemory); err != nil {
+			if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+				return tooLarge
+			}
+			return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode HTTP %s multipart form body: %s", strings.ToUpper(r.Method), err).WithDebug(err.Error()))
+		}
+
+		values = r.PostForm
+		if o.queryAndBody {
+			values = r.Form
+		}
+		values = addMultipartFiles(values, r.MultipartForm, o)
+	}
+
+	paths, err := jsonschemax.ListPathsWithRecursionAndArraysIncluded(o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP Post Body Form parsing: %s", err).WithDebugf("%+v", err))
+	}
+
 	raw, err := t.decodeURLValues(values, paths, o)
 	if err != nil && !errors.Is(err, errKeyNotFound) {
 		return err
@@ -404,131 +513,157 @@ func (t *HTTP) decodeForm(r *http.Request, destination interface{}, o *httpDecod
 	return t.validatePayload(raw, o)
 }
 
-func (t *HTTP) decodeURLValues(values url.Values, paths []jsonschemax.Path, o *httpDecoderOptions) (json.RawMessage, error) {
-	raw := json.RawMessage(`{}`)
-	for key := range values {
-		for _, path := range paths {
-			if key == path.Name {
-				var err error
-				switch path.Type.(type) {
-				case []string:
-					raw, err = sjson.SetBytes(raw, path.Name, values[key])
-				case []float64:
-					for k, v := range values[key] {
-						if f, err := strconv.ParseFloat(v, 64); err != nil {
-							switch o.handleParseErrors {
-							case ParseErrorIgnoreConversionErrors:
-								raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), v)
-							case ParseErrorUseEmptyValueOnConversionErrors:
-								raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), f)
-							case ParseErrorReturnOnConversionErrors:
-								return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a number.").
-									WithDetail("parse_error", err.Error()).
-									WithDetail("name", key).
-									WithDetailf("index", "%d", k).
-									WithDetail("value", v))
-							}
-						} else {
-							raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), f)
-						}
-					}
-				case []bool:
-					for k, v := range values[key] {
-						if f, err := strconv.ParseBool(v); err != nil {
-							switch o.handleParseErrors {
-							case ParseErrorIgnoreConversionErrors:
-								raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), v)
-							case ParseErrorUseEmptyValueOnConversionErrors:
-								raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), f)
-							case ParseErrorReturnOnConversionErrors:
-								return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a boolean.").
-									WithDetail("parse_error", err.Error()).
-									WithDetail("name", key).
-									WithDetailf("index", "%d", k).
-									WithDetail("value", v))
-							}
-						} else {
-							raw, err = sjson.SetBytes(raw, path.Name+"."+strconv.Itoa(k), f)
-						}
-					}
-				case []interface{}:
-					raw, err = sjson.SetBytes(raw, path.Name, values[key])
-				case bool:
-					v := values[key][len(values[key])-1]
-					if len(v) == 0 {
-						if !path.Required {
-							continue
-						}
-						v = "false"
-					}
+// addMultipartFiles adds the file names of the file parts to a copy of the values and collects
+// the file parts.
+func addMultipartFiles(values url.Values, form *multipart.Form, o *httpDecoderOptions) url.Values {
+	if len(form.File) == 0 {
+		return values
+	}
 
-					if f, err := strconv.ParseBool(v); err != nil {
-						switch o.handleParseErrors {
-						case ParseErrorIgnoreConversionErrors:
-							raw, err = sjson.SetBytes(raw, path.Name, v)
-						case ParseErrorUseEmptyValueOnConversionErrors:
-							raw, err = sjson.SetBytes(raw, path.Name, f)
-						case ParseErrorReturnOnConversionErrors:
-							return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a boolean.").
-								WithDetail("parse_error", err.Error()).
-								WithDetail("name", key).
-								WithDetail("value", values.Get(key)))
-						}
-					} else {
-						raw, err = sjson.SetBytes(raw, path.Name, f)
-					}
-				case float64:
-					v := values.Get(key)
-					if len(v) == 0 {
-						if !path.Required {
-							continue
-						}
-						v = "0.0"
-					}
+	withFiles := make(url.Values, len(values)+len(form.File))
+	for k, v := range values {
+		withFiles[k] = v
+	}
+	for k, files := range form.File {
+		for _, f := range files {
+			withFiles.Add(k, f.Filename)
+		}
+		if o.multipartFiles != nil {
+			o.multipartFiles[k] = append(o.multipartFiles[k], files...)
+		}
+	}
+	return withFiles
+}
 
-					if f, err := strconv.ParseFloat(v, 64); err != nil {
-						switch o.handleParseErrors {
-						case ParseErrorIgnoreConversionErrors:
-							raw, err = sjson.SetBytes(raw, path.Name, v)
-						case ParseErrorUseEmptyValueOnConversionErrors:
-							raw, err = sjson.SetBytes(raw, path.Name, f)
-						case ParseErrorReturnOnConversionErrors:
-							return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a number.").
-								WithDetail("parse_error", err.Error()).
-								WithDetail("name", key).
-								WithDetail("value", values.Get(key)))
-						}
-					} else {
-						raw, err = sjson.SetBytes(raw, path.Name, f)
-					}
-				case string:
-					v := values.Get(key)
-					if len(v) == 0 {
-						continue
-					}
+func (t *HTTP) decodeURLValues(values url.Values, paths []jsonschemax.Path, o *httpDecoderOptions) (json.RawMessage, error) {
+	fields, err := resolveFormFields(values, paths)
+	if err != nil {
+		return nil, err
+	}
 
-					raw, err = sjson.SetBytes(raw, path.Name, v)
-				case map[string]interface{}:
-					v := values.Get(key)
-					if len(v) == 0 && !path.Required {
-						continue
+	raw := json.RawMessage(`{}`)
+	for _, field := range fields {
+		key, path, name := field.key, field.path, field.target()
+		var err error
+		switch path.Type.(type) {
+		case []string:
+			raw, err = sjson.SetBytes(raw, name, values[key])
+		case []float64:
+			for k, v := range values[key] {
+				if f, err := strconv.ParseFloat(v, 64); err != nil {
+					switch o.handleParseErrors {
+					case ParseErrorIgnoreConversionErrors:
+						raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), v)
+					case ParseErrorUseEmptyValueOnConversionErrors:
+						raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), f)
+					case ParseErrorReturnOnConversionErrors:
+						return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a number.").
+							WithDetail("parse_error", err.Error()).
+							WithDetail("name", key).
+							WithDetailf("index", "%d", k).
+							WithDetail("value", v))
+					}
+				} else {
+					raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), f)
+				}
+			}
+		case []bool:
+			for k, v := range values[key] {
+				if f, err := strconv.ParseBool(v); err != nil {
+					switch o.handleParseErrors {
+					case ParseErrorIgnoreConversionErrors:
+						raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), v)
+					case ParseErrorUseEmptyValueOnConversionErrors:
+						raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), f)
+					case ParseErrorReturnOnConversionErrors:
+						return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a boolean.").
+							WithDetail("parse_error", err.Error()).
+							WithDetail("name", key).
+							WithDetailf("index", "%d", k).
+							WithDetail("value", v))
 					}
+				} else {
+					raw, err = sjson.SetBytes(raw, name+"."+strconv.Itoa(k), f)
+				}
+			}
+		case []interface{}:
+			raw, err = sjson.SetBytes(raw, name, values[key])
+		case bool:
+			v := values[key][len(values[key])-1]
+			if len(v) == 0 {
+				if !path.Required {
+					continue
+				}
+				v = "false"
+			}
 
-					raw, err = sjson.SetBytes(raw, path.Name, v)
-				case []map[string]interface{}:
-					raw, err = sjson.SetBytes(raw, path.Name, values[key])
+			if f, err := strconv.ParseBool(v); err != nil {
+				switch o.handleParseErrors {
+				case ParseErrorIgnoreConversionErrors:
+					raw, err = sjson.SetBytes(raw, name, v)
+				case ParseErrorUseEmptyValueOnConversionErrors:
+					raw, err = sjson.SetBytes(raw, name, f)
+				case ParseErrorReturnOnConversionErrors:
+					return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a boolean.").
+						WithDetail("parse_error", err.Error()).
+						WithDetail("name", key).
+						WithDetail("value", values.Get(key)))
 				}
+			} else {
+				raw, err = sjson.SetBytes(raw, name, f)
+			}
+		case float64:
+			v := values.Get(key)
+			if len(v) == 0 {
+				if !path.Required {
+					continue
+				}
+				v = "0.0"
+			}
 
-				if err != nil {
-					return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to type assert values from HTTP Post Body: %s", err))
+			if f, err := strconv.ParseFloat(v, 64); err != nil {
+				switch o.handleParseErrors {
+				case ParseErrorIgnoreConversionErrors:
+					raw, err = sjson.SetBytes(raw, name, v)
+				case ParseErrorUseEmptyValueOnConversionErrors:
+					raw, err = sjson.SetBytes(raw, name, f)
+				case ParseErrorReturnOnConversionErrors:
+					return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Expected value to be a number.").
+						WithDetail("parse_error", err.Error()).
+						WithDetail("name", key).
+						WithDetail("value", values.Get(key)))
 				}
-				break
+			} else {
+				raw, err = sjson.SetBytes(raw, name, f)
 			}
+		case string:
+			v := values.Get(key)
+			if len(v) == 0 {
+				continue
+			}
+
+			raw, err = sjson.SetBytes(raw, name, v)
+		case map[string]interface{}:
+			v := values.Get(key)
+			if len(v) == 0 && !path.Required {
+				continue
+			}
+
+			raw, err = sjson.SetBytes(raw, name, v)
+		case []map[string]interface{}:
+			raw, err = sjson.SetBytes(raw, name, values[key])
+		}
+
+		if err != nil {
+			return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to type assert values from HTTP Post Body: %s", err))
 		}
 	}
 
 	for _, path := range paths {
-		if path.TypeHint != jsonschemax.JSON {
+		if path.TypeHint != jsonschemax.JSON || strings.Contains(path.Name, "#") {
+			continue
+		}
+		if _, isArray := path.Type.([]interface{}); isArray {
 			continue
 		}
 
@@ -550,8 +685,17 @@ func (t *HTTP) decodeJSON(r *http.Request, destination interface{}, o *httpDecod
 		return err
 	}
 
-	raw, err := ioutil.ReadAll(reader)
-	if err != nil {
+	var raw []byte
+	if o.streamingValidation && o.jsonSchemaValidate && !isRetry {
+		raw, err = t.readAndValidateJSON(reader, o)
+	} else {
+		raw, err = ioutil.ReadAll(reader)
+	}
+	if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+		return tooLarge
+	} else if errors.As(err, new(*jsonschema.ValidationError)) || errors.As(err, new(*herodot.DefaultError)) {
+		return err
+	} else if err != nil {
 		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to read HTTP POST body: %s", err))
 	}
 
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -28,6 +30,22 @@ func newRequest(t *testing.T, method, url string, body io.Reader, ct string) *ht
 	return req
 }
 
+func newMultipartRequest(t *testing.T, fields map[string]string, files map[string]string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	for k, v := range files {
+		fw, err := mw.CreateFormFile(k, k+".txt")
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(v))
+		require.NoError(t, err)
+	}
+	require.NoError(t, mw.Close())
+	return newRequest(t, "POST", "/", &body, mw.FormDataContentType())
+}
+
 func TestHTTPFormDecoder(t *testing.T) {
 	for k, tc := range []struct {
 		d             string
@@ -404,6 +422,41 @@ func TestHTTPFormDecoder(t *testing.T) {
 			options:  []HTTPDecoderOption{HTTPJSONSchemaCompiler("stub/person.json", nil), HTTPDecoderSetIgnoreParseErrorsStrategy(ParseErrorUseEmptyValueOnConversionErrors)},
 			expected: `{"name": {"first": "12345"}}`,
 		},
+		{
+			d: "should pass xml with type assertions",
+			request: newRequest(t, "POST", "/", bytes.NewBufferString(`<?xml version="1.0"?>
+<person age="29"><name><first>Alice</first><last> Doe </last></name><ratio>0.9</ratio><consent>true</consent></person>`), "application/xml; charset=utf-8"),
+			options:  []HTTPDecoderOption{HTTPJSONSchemaCompiler("stub/person.json", nil)},
+			expected: `{"name": {"first": "Alice", "last": "Doe"}, "age": 29, "ratio": 0.9, "consent": true}`,
+		},
+		{
+			d:             "should fail xml if it is malformed",
+			request:       newRequest(t, "POST", "/", bytes.NewBufferString(`<person><name>`), httpContentTypeTextXML),
+			options:       []HTTPDecoderOption{HTTPJSONSchemaCompiler("stub/person.json", nil)},
+			expectedError: "Unable to decode XML payload",
+		},
+		{
+			d:             "should fail xml if only json is allowed",
+			request:       newRequest(t, "POST", "/", bytes.NewBufferString(`<person/>`), httpContentTypeXML),
+			options:       []HTTPDecoderOption{HTTPJSONDecoder(), HTTPJSONSchemaCompiler("stub/person.json", nil)},
+			expectedError: "Content-Type: application/xml",
+		},
+		{
+			d:             "should fail json if only xml is allowed",
+			request:       newRequest(t, "POST", "/", bytes.NewBufferString(`{}`), httpContentTypeJSON),
+			options:       []HTTPDecoderOption{HTTPXMLDecoder(), HTTPJSONSchemaCompiler("stub/person.json", nil)},
+			expectedError: "Content-Type: application/json",
+		},
+		{
+			d: "should pass multipart form with type assertions",
+			request: newMultipartRequest(t, map[string]string{
+				"name.first": "Alice",
+				"age":        "29",
+				"consent":    "true",
+			}, nil),
+			options:  []HTTPDecoderOption{HTTPJSONSchemaCompiler("stub/person.json", nil)},
+			expected: `{"name": {"first": "Alice"}, "age": 29, "consent": true}`,
+		},
 	} {
 		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
 			dec := NewHTTP()
@@ -449,3 +502,38 @@ func TestHTTPFormDecoder(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestHTTPMultipartFiles(t *testing.T) {
+	schema := MustHTTPRawJSONSchemaCompiler([]byte(`{
+  "type": "object",
+  "properties": {
+    "title": {"type": "string"},
+    "document": {"type": "string"}
+  },
+  "required": ["document"]
+}`))
+
+	t.Run("case=files are collected", func(t *testing.T) {
+		files := MultipartFiles{}
+		var destination json.RawMessage
+		require.NoError(t, NewHTTP().Decode(
+			newMultipartRequest(t, map[string]string{"title": "Contract"}, map[string]string{"document": "signed"}),
+			&destination, schema, HTTPDecoderMultipartFiles(files)))
+
+		assertx.EqualAsJSON(t, json.RawMessage(`{"title": "Contract", "document": "document.txt"}`), destination)
+		require.Len(t, files["document"], 1)
+		f, err := files["document"][0].Open()
+		require.NoError(t, err)
+		defer f.Close()
+		content, err := ioutil.ReadAll(f)
+		require.NoError(t, err)
+		assert.Equal(t, "signed", string(content))
+	})
+
+	t.Run("case=schema requires the file", func(t *testing.T) {
+		var destination json.RawMessage
+		err := NewHTTP().Decode(newMultipartRequest(t, map[string]string{"title": "Contract"}, nil), &destination, schema)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing properties")
+	})
+}
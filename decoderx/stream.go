@@ -0,0 +1,292 @@
+package decoderx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+	"github.com/ory/jsonschema/v3"
+)
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// limitedBody fails with errBodyTooLarge once more than remaining bytes were read.
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.exceeded {
+		return 0, errBodyTooLarge
+	}
+
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n, b.remaining, b.exceeded = int(b.remaining), 0, true
+		return n, errBodyTooLarge
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
+
+// bodyTooLarge returns ErrPayloadTooLarge if err was caused by a request body which exceeds
+// the maximum body size, and nil otherwise.
+func bodyTooLarge(err error, o *httpDecoderOptions) error {
+	if err == nil || !errors.Is(err, errBodyTooLarge) {
+		return nil
+	}
+	return errors.WithStack(ErrPayloadTooLarge.WithReasonf("The HTTP request body must not be larger than %d bytes.", o.maxBodySize))
+}
+
+// streamSchema is a schema and its location, because the compiler sets the location only for
+// root schemas and the targets of references.
+type streamSchema struct {
+	*jsonschema.Schema
+	url string
+	ptr string
+}
+
+// readAndValidateJSON reads a JSON document and validates it against the JSON Schema while
+// reading. Reading stops at the first value which violates a keyword that can be checked
+// without knowing the rest of the document: type, maxLength, maxItems, maxProperties,
+// properties, patternProperties, and additionalProperties. Keywords which are only defined in
+// anyOf, oneOf, not, or conditional schemas are ignored.
+//
+// The document is returned as read and must still be validated completely.
+func (t *HTTP) readAndValidateJSON(reader io.Reader, o *httpDecoderOptions) ([]byte, error) {
+	if o.jsonSchemaCompiler == nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("JSON Schema Validation is required but no compiler was provided."))
+	}
+
+	schema, err := o.jsonSchemaCompiler.Compile(o.jsonSchemaRef)
+	if err != nil {
+		return nil, errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to load JSON Schema from location: %s", o.jsonSchemaRef).WithDebug(err.Error()))
+	}
+
+	var buf bytes.Buffer
+	dec := json.NewDecoder(io.TeeReader(reader, &buf))
+	dec.UseNumber()
+
+	root := streamSchema{Schema: schema, url: schema.URL, ptr: schema.Ptr}
+	if err := walkJSON(dec, expandSchemas([]streamSchema{root}), "#"); err != nil {
+		var ve *jsonschema.ValidationError
+		if errors.As(err, &ve) {
+			return nil, errors.WithStack(&jsonschema.ValidationError{
+				Message:     "validation failed",
+				InstancePtr: "#",
+				SchemaURL:   root.url,
+				SchemaPtr:   root.ptr,
+				Causes:      []*jsonschema.ValidationError{ve},
+			})
+		} else if errors.Is(err, errBodyTooLarge) {
+			return nil, err
+		}
+		return nil, errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err))
+	}
+
+	if _, err := io.Copy(&buf, reader); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return buf.Bytes(), nil
+}
+
+// expandSchemas replaces references by their targets and adds the schemas of allOf, because
+// they apply to the same value.
+func expandSchemas(schemas []streamSchema) []streamSchema {
+	var (
+		expanded []streamSchema
+		seen     = map[string]bool{}
+	)
+
+	var expand func(s streamSchema)
+	expand = func(s streamSchema) {
+		if s.Schema == nil || seen[s.url+s.ptr] {
+			return
+		}
+		seen[s.url+s.ptr] = true
+
+		if s.Ref != nil {
+			expand(streamSchema{Schema: s.Ref, url: s.Ref.URL, ptr: s.Ref.Ptr})
+			return
+		}
+		if s.Always != nil {
+			return
+		}
+
+		expanded = append(expanded, s)
+		for i, sub := range s.AllOf {
+			expand(streamSchema{Schema: sub, url: s.url, ptr: fmt.Sprintf("%s/allOf/%d", s.ptr, i)})
+		}
+	}
+
+	for _, s := range schemas {
+		expand(s)
+	}
+	return expanded
+}
+
+func streamValidationError(s streamSchema, keyword, instancePtr, format string, a ...interface{}) *jsonschema.ValidationError {
+	return &jsonschema.ValidationError{
+		Message:     fmt.Sprintf(format, a...),
+		InstancePtr: instancePtr,
+		SchemaURL:   s.url,
+		SchemaPtr:   s.ptr + "/" + keyword,
+	}
+}
+
+func escapeStreamPointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+// walkJSON reads the next value from the decoder and validates it against all schemas.
+func walkJSON(dec *json.Decoder, schemas []streamSchema, instancePtr string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	var vType string
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			vType = "object"
+		} else if v == '[' {
+			vType = "array"
+		} else {
+			return errors.Errorf("unexpected %q", v)
+		}
+	case string:
+		vType = "string"
+	case json.Number:
+		vType = "number"
+	case bool:
+		vType = "boolean"
+	case nil:
+		vType = "null"
+	}
+
+	for _, s := range schemas {
+		if len(s.Types) > 0 && !matchesType(s.Types, vType, tok) {
+			return streamValidationError(s, "type", instancePtr, "expected %s, but got %s", strings.Join(s.Types, " or "), vType)
+		}
+		if v, ok := tok.(string); ok && s.MaxLength >= 0 {
+			if length := utf8.RuneCountInString(v); length > s.MaxLength {
+				return streamValidationError(s, "maxLength", instancePtr, "length must be <= %d, but got %d", s.MaxLength, length)
+			}
+		}
+	}
+
+	switch vType {
+	case "object":
+		return walkJSONObject(dec, schemas, instancePtr)
+	case "array":
+		return walkJSONArray(dec, schemas, instancePtr)
+	}
+	return nil
+}
+
+func matchesType(types []string, vType string, tok json.Token) bool {
+	for _, t := range types {
+		if t == vType {
+			return true
+		} else if t == "integer" && vType == "number" {
+			if _, ok := new(big.Int).SetString(tok.(json.Number).String(), 10); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func walkJSONObject(dec *json.Decoder, schemas []streamSchema, instancePtr string) error {
+	for count := 1; dec.More(); count++ {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		ptr := instancePtr + "/" + escapeStreamPointer(name)
+
+		var children []streamSchema
+		for _, s := range schemas {
+			if s.MaxProperties >= 0 && count > s.MaxProperties {
+				return streamValidationError(s, "maxProperties", instancePtr, "maximum %d properties allowed, but found more properties", s.MaxProperties)
+			}
+
+			matched := false
+			if property, ok := s.Properties[name]; ok {
+				matched = true
+				children = append(children, streamSchema{Schema: property, url: s.url, ptr: s.ptr + "/properties/" + escapeStreamPointer(name)})
+			}
+			for pattern, property := range s.PatternProperties {
+				if pattern.MatchString(name) {
+					matched = true
+					children = append(children, streamSchema{Schema: property, url: s.url, ptr: s.ptr + "/patternProperties/" + escapeStreamPointer(pattern.String())})
+				}
+			}
+			if matched {
+				continue
+			}
+
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if additional {
+					break
+				}
+				return streamValidationError(s, "additionalProperties", instancePtr, "additionalProperties %s not allowed", strconv.Quote(name))
+			case *jsonschema.Schema:
+				children = append(children, streamSchema{Schema: additional, url: s.url, ptr: s.ptr + "/additionalProperties"})
+			}
+		}
+
+		if err := walkJSON(dec, expandSchemas(children), ptr); err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
+
+func walkJSONArray(dec *json.Decoder, schemas []streamSchema, instancePtr string) error {
+	for i := 0; dec.More(); i++ {
+		var children []streamSchema
+		for _, s := range schemas {
+			if s.MaxItems >= 0 && i+1 > s.MaxItems {
+				return streamValidationError(s, "maxItems", instancePtr, "maximum %d items allowed, but found more items", s.MaxItems)
+			}
+
+			switch items := s.Items.(type) {
+			case *jsonschema.Schema:
+				children = append(children, streamSchema{Schema: items, url: s.url, ptr: s.ptr + "/items"})
+			case []*jsonschema.Schema:
+				if i < len(items) {
+					children = append(children, streamSchema{Schema: items[i], url: s.url, ptr: fmt.Sprintf("%s/items/%d", s.ptr, i)})
+				} else if additional, ok := s.AdditionalItems.(*jsonschema.Schema); ok {
+					children = append(children, streamSchema{Schema: additional, url: s.url, ptr: s.ptr + "/additionalItems"})
+				}
+			}
+		}
+
+		if err := walkJSON(dec, expandSchemas(children), fmt.Sprintf("%s/%d", instancePtr, i)); err != nil {
+			return err
+		}
+	}
+
+	_, err := dec.Token()
+	return err
+}
@@ -0,0 +1,233 @@
+package decoderx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+	"github.com/ory/jsonschema/v3"
+)
+
+// unreadableReader fails the test if the decoder reads beyond the expected position.
+type unreadableReader struct{ t *testing.T }
+
+func (r *unreadableReader) Read([]byte) (int, error) {
+	r.t.Error("the request body must not be read any further")
+	return 0, errors.New("the request body must not be read any further")
+}
+
+func TestHTTPDecoderMaxBodySize(t *testing.T) {
+	for k, tc := range []struct {
+		d           string
+		request     func() *http.Request
+		expectedErr bool
+	}{
+		{
+			d: "json",
+			request: func() *http.Request {
+				return newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": {"first": "`+strings.Repeat("a", 100)+`"}}`), httpContentTypeJSON)
+			},
+			expectedErr: true,
+		},
+		{
+			d: "json with unknown content length",
+			request: func() *http.Request {
+				r := newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": {"first": "`+strings.Repeat("a", 100)+`"}}`), httpContentTypeJSON)
+				r.ContentLength = -1
+				return r
+			},
+			expectedErr: true,
+		},
+		{
+			d: "json within the limit",
+			request: func() *http.Request {
+				return newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": {"first": "a"}}`), httpContentTypeJSON)
+			},
+		},
+		{
+			d: "form with unknown content length",
+			request: func() *http.Request {
+				r := newRequest(t, "POST", "/", bytes.NewBufferString("name.first="+strings.Repeat("a", 100)), httpContentTypeURLEncodedForm)
+				r.ContentLength = -1
+				return r
+			},
+			expectedErr: true,
+		},
+		{
+			d: "multipart form with unknown content length",
+			request: func() *http.Request {
+				r := newMultipartRequest(t, map[string]string{"name.first": strings.Repeat("a", 100)}, nil)
+				r.ContentLength = -1
+				return r
+			},
+			expectedErr: true,
+		},
+		{
+			d: "xml with unknown content length",
+			request: func() *http.Request {
+				r := newRequest(t, "POST", "/", bytes.NewBufferString("<person><name><first>"+strings.Repeat("a", 100)+"</first></name></person>"), httpContentTypeXML)
+				r.ContentLength = -1
+				return r
+			},
+			expectedErr: true,
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			var destination json.RawMessage
+			err := NewHTTP().Decode(tc.request(), &destination,
+				HTTPJSONSchemaCompiler("stub/person.json", nil),
+				HTTPDecoderSetValidatePayloads(false),
+				HTTPDecoderMaxBodySize(64),
+			)
+			if !tc.expectedErr {
+				require.NoError(t, err)
+				return
+			}
+
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrPayloadTooLarge), "%+v", err)
+			assert.Equal(t, http.StatusRequestEntityTooLarge, errors.Cause(err).(*herodot.DefaultError).StatusCode())
+		})
+	}
+}
+
+func TestLimitedBody(t *testing.T) {
+	b := &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcdef")), remaining: 4}
+	read, err := io.ReadAll(b)
+	assert.True(t, errors.Is(err, errBodyTooLarge))
+	assert.Equal(t, "abcd", string(read))
+
+	b = &limitedBody{ReadCloser: io.NopCloser(strings.NewReader("abcd")), remaining: 4}
+	read, err = io.ReadAll(b)
+	require.NoError(t, err)
+	assert.Equal(t, "abcd", string(read))
+}
+
+func TestHTTPDecoderStreamingValidation(t *testing.T) {
+	schema := MustHTTPRawJSONSchemaCompiler([]byte(`{
+  "definitions": {
+    "tag": {"type": "string", "maxLength": 5}
+  },
+  "type": "object",
+  "additionalProperties": false,
+  "maxProperties": 3,
+  "properties": {
+    "name": {"type": "string"},
+    "age": {"type": "integer"},
+    "tags": {"type": "array", "maxItems": 2, "items": {"$ref": "#/definitions/tag"}},
+    "meta": {
+      "type": "object",
+      "allOf": [{"patternProperties": {"^x-": {"type": "boolean"}}}]
+    }
+  },
+  "required": ["name"]
+}`))
+
+	for k, tc := range []struct {
+		d           string
+		prefix      string
+		instancePtr string
+		schemaPtr   string
+		message     string
+	}{
+		{
+			d:           "unknown property",
+			prefix:      `{"name": "foo", "unknown": `,
+			instancePtr: "#",
+			schemaPtr:   "#/additionalProperties",
+			message:     `additionalProperties "unknown" not allowed`,
+		},
+		{
+			d:           "wrong type",
+			prefix:      `{"age": 1.5, `,
+			instancePtr: "#/age",
+			schemaPtr:   "#/properties/age/type",
+			message:     "expected integer, but got number",
+		},
+		{
+			d:           "too many items",
+			prefix:      `{"tags": ["a", "b", "c", `,
+			instancePtr: "#/tags",
+			schemaPtr:   "#/properties/tags/maxItems",
+			message:     "maximum 2 items allowed, but found more items",
+		},
+		{
+			d:           "too long string in a referenced schema",
+			prefix:      `{"tags": ["abcdef", `,
+			instancePtr: "#/tags/0",
+			schemaPtr:   "#/definitions/tag/maxLength",
+			message:     "length must be <= 5, but got 6",
+		},
+		{
+			d:           "pattern property in allOf",
+			prefix:      `{"meta": {"x-debug": "yes", `,
+			instancePtr: "#/meta/x-debug",
+			schemaPtr:   "#/properties/meta/allOf/0/patternProperties/^x-/type",
+			message:     "expected boolean, but got string",
+		},
+		{
+			d:           "too many properties",
+			prefix:      `{"name": "foo", "age": 1, "tags": [], "meta": {`,
+			instancePtr: "#",
+			schemaPtr:   "#/maxProperties",
+			message:     "maximum 3 properties allowed, but found more properties",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d/description=%s", k, tc.d), func(t *testing.T) {
+			r := newRequest(t, "POST", "/", io.MultiReader(strings.NewReader(tc.prefix), &unreadableReader{t: t}), httpContentTypeJSON)
+
+			var destination json.RawMessage
+			err := NewHTTP().Decode(r, &destination, schema, HTTPDecoderStreamingValidation())
+			require.Error(t, err)
+
+			var ve *jsonschema.ValidationError
+			require.True(t, errors.As(err, &ve), "%+v", err)
+			require.Len(t, ve.Causes, 1)
+			assert.Equal(t, tc.instancePtr, ve.Causes[0].InstancePtr)
+			assert.Equal(t, tc.schemaPtr, ve.Causes[0].SchemaPtr)
+			assert.Equal(t, tc.message, ve.Causes[0].Message)
+		})
+	}
+
+	t.Run("case=valid payloads are still validated completely", func(t *testing.T) {
+		var destination json.RawMessage
+		err := NewHTTP().Decode(
+			newRequest(t, "POST", "/", bytes.NewBufferString(`{"age": 1}`), httpContentTypeJSON),
+			&destination, schema, HTTPDecoderStreamingValidation())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing properties")
+
+		require.NoError(t, NewHTTP().Decode(
+			newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": "foo", "tags": ["a"], "meta": {"x-debug": true}}`), httpContentTypeJSON),
+			&destination, schema, HTTPDecoderStreamingValidation()))
+		assert.JSONEq(t, `{"name": "foo", "tags": ["a"], "meta": {"x-debug": true}}`, string(destination))
+	})
+
+	t.Run("case=malformed payload", func(t *testing.T) {
+		var destination json.RawMessage
+		err := NewHTTP().Decode(
+			newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": }`), httpContentTypeJSON),
+			&destination, schema, HTTPDecoderStreamingValidation())
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, herodot.ErrBadRequest), "%+v", err)
+	})
+
+	t.Run("case=combined with the maximum body size", func(t *testing.T) {
+		r := newRequest(t, "POST", "/", bytes.NewBufferString(`{"name": "`+strings.Repeat("a", 100)+`"}`), httpContentTypeJSON)
+		r.ContentLength = -1
+
+		var destination json.RawMessage
+		err := NewHTTP().Decode(r, &destination, schema, HTTPDecoderStreamingValidation(), HTTPDecoderMaxBodySize(64))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrPayloadTooLarge), "%+v", err)
+	})
+}
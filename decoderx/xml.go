@@ -0,0 +1,122 @@
+package decoderx
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/herodot"
+
+	"github.com/ory/x/jsonschemax"
+)
+
+func (t *HTTP) decodeXML(r *http.Request, destination interface{}, o *httpDecoderOptions) error {
+	if o.jsonSchemaCompiler == nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithReasonf("Unable to decode HTTP XML Body because no validation schema was provided. This is a code bug."))
+	}
+
+	paths, err := jsonschemax.ListPathsWithRecursionAndArraysIncluded(o.jsonSchemaRef, o.jsonSchemaCompiler, o.maxCircularReferenceDepth)
+	if err != nil {
+		return errors.WithStack(herodot.ErrInternalServerError.WithTrace(err).WithReasonf("Unable to prepare JSON Schema for HTTP Post Body XML parsing: %s", err).WithDebugf("%+v", err))
+	}
+
+	reader, err := t.requestBody(r, o)
+	if err != nil {
+		return err
+	}
+
+	values, err := xmlToValues(reader)
+	if tooLarge := bodyTooLarge(err, o); tooLarge != nil {
+		return tooLarge
+	} else if err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode XML payload: %s", err).WithDebug(err.Error()))
+	}
+
+	if o.queryAndBody {
+		_ = r.ParseForm()
+		for k := range r.Form {
+			values.Set(k, r.Form.Get(k))
+		}
+	}
+
+	raw, err := t.decodeURLValues(values, paths, o)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw, destination); err != nil {
+		return errors.WithStack(herodot.ErrBadRequest.WithReasonf("Unable to decode JSON payload: %s", err))
+	}
+
+	return t.validatePayload(raw, o)
+}
+
+// xmlToValues converts an XML document to form values: the text of an element becomes the
+// value of the dot-separated names of its ancestors, without the root element, and attributes
+// are handled like child elements. Repeated elements become repeated values, so
+//
+//	<person id="1"><name><first>Alice</first></name><tag>a</tag><tag>b</tag></person>
+//
+// is converted to `id=1&name.first=Alice&tag=a&tag=b`. The values are typed according to the
+// JSON Schema just like form values.
+func xmlToValues(r io.Reader) (url.Values, error) {
+	type element struct {
+		name        string
+		text        strings.Builder
+		hasChildren bool
+	}
+
+	values := url.Values{}
+	dec := xml.NewDecoder(r)
+	var stack []*element
+	key := func(name string) string {
+		parts := make([]string, 0, len(stack))
+		for _, e := range stack[1:] {
+			parts = append(parts, e.name)
+		}
+		return strings.Join(append(parts, name), ".")
+	}
+
+	for {
+		tok, err := dec.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			if len(stack) > 0 {
+				stack[len(stack)-1].hasChildren = true
+			}
+			stack = append(stack, &element{name: tok.Name.Local})
+			for _, attr := range tok.Attr {
+				if attr.Name.Space == "xmlns" || attr.Name.Local == "xmlns" {
+					continue
+				}
+				values.Add(key(attr.Name.Local), attr.Value)
+			}
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(tok)
+			}
+		case xml.EndElement:
+			e := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 && !e.hasChildren {
+				values.Add(key(e.name), strings.TrimSpace(e.text.String()))
+			}
+		}
+	}
+
+	if len(stack) > 0 {
+		return nil, errors.New("unexpected end of the XML document")
+	}
+	return values, nil
+}
@@ -0,0 +1,103 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/httpx"
+)
+
+func TestFetcherCache(t *testing.T) {
+	var requests, notModified int32
+	body := `{"foo":"bar"}`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/etag":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+		case "/last-modified":
+			if r.Header.Get("If-Modified-Since") == "Wed, 21 Oct 2015 07:28:00 GMT" {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("Last-Modified", "Wed, 21 Oct 2015 07:28:00 GMT")
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(ts.Close)
+
+	for _, p := range []string{"/etag", "/last-modified"} {
+		t.Run("case="+p, func(t *testing.T) {
+			atomic.StoreInt32(&requests, 0)
+			atomic.StoreInt32(&notModified, 0)
+			f := NewFetcher(WithClient(ts.Client()), WithCache(httpx.NewMemoryCacheStore()))
+
+			for i := 0; i < 3; i++ {
+				actual, err := f.Fetch(ts.URL + p)
+				require.NoError(t, err)
+				assert.JSONEq(t, body, actual.String())
+
+				// modifying the result must not modify the cache
+				actual.Reset()
+			}
+			assert.EqualValues(t, 3, atomic.LoadInt32(&requests))
+			assert.EqualValues(t, 2, atomic.LoadInt32(&notModified))
+		})
+	}
+
+	t.Run("case=integrity pinned content is not revalidated", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		f := NewFetcher(WithClient(ts.Client()), WithCache(httpx.NewMemoryCacheStore()))
+
+		for i := 0; i < 3; i++ {
+			actual, err := f.Fetch(ts.URL+"/etag", WithIntegrity(sri(body)))
+			require.NoError(t, err)
+			assert.JSONEq(t, body, actual.String())
+		}
+		assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	})
+
+	t.Run("case=content not matching the integrity is not cached", func(t *testing.T) {
+		c := httpx.NewMemoryCacheStore()
+		f := NewFetcher(WithClient(ts.Client()), WithCache(c))
+
+		_, err := f.Fetch(ts.URL+"/etag", WithIntegrity(sri("something else")))
+		require.ErrorIs(t, err, ErrIntegrityMismatch)
+		_, ok := c.Get(ts.URL + "/etag")
+		assert.False(t, ok)
+	})
+
+	t.Run("case=disk cache", func(t *testing.T) {
+		atomic.StoreInt32(&requests, 0)
+		store, err := httpx.NewDiskCacheStore(t.TempDir())
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			actual, err := NewFetcher(WithClient(ts.Client()), WithCache(store)).Fetch(ts.URL+"/etag", WithIntegrity(sri(body)))
+			require.NoError(t, err)
+			assert.JSONEq(t, body, actual.String())
+		}
+		assert.EqualValues(t, 1, atomic.LoadInt32(&requests), "the cache is shared between fetchers")
+	})
+
+	t.Run("case=size limits apply before caching", func(t *testing.T) {
+		c := httpx.NewMemoryCacheStore()
+		f := NewFetcher(WithClient(ts.Client()), WithCache(c))
+
+		_, err := f.Fetch(ts.URL+"/etag", WithMaxBytes(5))
+		require.ErrorIs(t, err, ErrSourceTooLarge)
+		_, ok := c.Get(ts.URL + "/etag")
+		assert.False(t, ok)
+	})
+}
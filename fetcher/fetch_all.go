@@ -0,0 +1,105 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const defaultMaxConcurrency = 8
+
+type (
+	// FetchResult is the result of fetching one source with FetchAll.
+	FetchResult struct {
+		// Source is the fetched source.
+		Source string
+
+		// Body is the content of the source, or nil if fetching it failed.
+		Body *bytes.Buffer
+
+		// Err is the error fetching the source, if any.
+		Err error
+	}
+
+	// FetchResults are the results of FetchAll, in the order of the sources.
+	FetchResults []FetchResult
+
+	// FetchAllError is returned by FetchResults.Err if at least one source could not be fetched.
+	FetchAllError struct {
+		// Failed are the results of the sources which could not be fetched.
+		Failed FetchResults
+
+		// Total is the number of sources.
+		Total int
+	}
+)
+
+// WithMaxConcurrency sets how many sources FetchAll fetches at the same time. Defaults to 8.
+func WithMaxConcurrency(n int) func(*opts) {
+	return func(o *opts) {
+		o.maxConcurrency = n
+	}
+}
+
+// FetchAll fetches the sources concurrently, but at most as many at the same time as set with
+// WithMaxConcurrency. The options apply to every source, so that for example WithMaxBytes limits
+// the size of each source. Unlike Fetch, it does not stop at the first error but returns the
+// result of every source, so that all failures can be reported at once. Use FetchResults.Err to
+// check whether any source failed.
+func (f *Fetcher) FetchAll(ctx context.Context, sources []string, opts ...FetchOption) FetchResults {
+	results := make(FetchResults, len(sources))
+	sem := make(chan struct{}, f.maxConcurrency)
+
+	var wg sync.WaitGroup
+	for k, source := range sources {
+		wg.Add(1)
+		go func(k int, source string) {
+			defer wg.Done()
+
+			results[k].Source = source
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[k].Err = errors.Wrapf(ctx.Err(), "rule: %s", source)
+				return
+			}
+			results[k].Body, results[k].Err = f.FetchContext(ctx, source, opts...)
+		}(k, source)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Err returns a *FetchAllError if at least one source could not be fetched, or nil otherwise.
+func (r FetchResults) Err() error {
+	var failed FetchResults
+	for _, result := range r {
+		if result.Err != nil {
+			failed = append(failed, result)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &FetchAllError{Failed: failed, Total: len(r)}
+}
+
+func (e *FetchAllError) Error() string {
+	messages := make([]string, len(e.Failed))
+	for k, result := range e.Failed {
+		messages[k] = result.Err.Error()
+	}
+	return fmt.Sprintf("unable to fetch %d of %d sources: %s", len(e.Failed), e.Total, strings.Join(messages, "; "))
+}
+
+// Unwrap returns the error of the first failed source, so that errors.Is and errors.As can be
+// used to check for specific errors.
+func (e *FetchAllError) Unwrap() error {
+	return e.Failed[0].Err
+}
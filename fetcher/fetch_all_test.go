@@ -0,0 +1,110 @@
+package fetcher
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAll(t *testing.T) {
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"path":%q}`, r.URL.Path)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("case=all sources are fetched", func(t *testing.T) {
+		atomic.StoreInt32(&maxInFlight, 0)
+		var sources []string
+		for i := 0; i < 10; i++ {
+			sources = append(sources, fmt.Sprintf("%s/%d", ts.URL, i))
+		}
+
+		results := NewFetcher(WithClient(ts.Client()), WithMaxConcurrency(3)).FetchAll(context.Background(), sources)
+		require.NoError(t, results.Err())
+		require.Len(t, results, 10)
+		for i, result := range results {
+			assert.Equal(t, sources[i], result.Source)
+			assert.JSONEq(t, fmt.Sprintf(`{"path":"/%d"}`, i), result.Body.String())
+		}
+		assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(3))
+		assert.Greater(t, atomic.LoadInt32(&maxInFlight), int32(1))
+	})
+
+	t.Run("case=failures are reported per source", func(t *testing.T) {
+		results := NewFetcher(WithClient(ts.Client())).FetchAll(context.Background(), []string{
+			ts.URL + "/ok",
+			ts.URL + "/missing",
+			"base64://" + base64.StdEncoding.EncodeToString([]byte(`{}`)),
+			"unknown-scheme://foo",
+		})
+		require.Len(t, results, 4)
+		assert.NoError(t, results[0].Err)
+		assert.Error(t, results[1].Err)
+		assert.Nil(t, results[1].Body)
+		assert.NoError(t, results[2].Err)
+		assert.True(t, errors.Is(results[3].Err, ErrUnknownScheme))
+
+		err := results.Err()
+		var fetchErr *FetchAllError
+		require.True(t, errors.As(err, &fetchErr))
+		assert.Len(t, fetchErr.Failed, 2)
+		assert.Equal(t, 4, fetchErr.Total)
+		assert.Contains(t, err.Error(), "unable to fetch 2 of 4 sources")
+		assert.Contains(t, err.Error(), "unknown-scheme")
+	})
+
+	t.Run("case=canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		results := NewFetcher(WithClient(ts.Client())).FetchAll(ctx, []string{ts.URL + "/a", ts.URL + "/b"})
+		require.Len(t, results, 2)
+		for _, result := range results {
+			assert.True(t, errors.Is(result.Err, context.Canceled), "%+v", result.Err)
+		}
+	})
+
+	t.Run("case=options apply to every source", func(t *testing.T) {
+		small := "base64://" + base64.StdEncoding.EncodeToString([]byte(`{}`))
+		large := "base64://" + base64.StdEncoding.EncodeToString([]byte(`{"path":"/large"}`))
+		results := NewFetcher().FetchAll(context.Background(), []string{small, large, small},
+			WithMaxBytes(10), WithIntegrity(sri(`{}`)))
+		require.Len(t, results, 3)
+		assert.NoError(t, results[0].Err)
+		assert.True(t, errors.Is(results[1].Err, ErrSourceTooLarge), "%+v", results[1].Err)
+		assert.NoError(t, results[2].Err)
+
+		results = NewFetcher(WithClient(ts.Client())).FetchAll(context.Background(), []string{ts.URL + "/a"}, WithTimeout(time.Millisecond))
+		assert.True(t, errors.Is(results[0].Err, context.DeadlineExceeded), "%+v", results[0].Err)
+	})
+
+	t.Run("case=no sources", func(t *testing.T) {
+		results := NewFetcher().FetchAll(context.Background(), nil)
+		assert.Empty(t, results)
+		assert.NoError(t, results.Err())
+	})
+}
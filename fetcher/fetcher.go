@@ -2,41 +2,120 @@ package fetcher
 
 import (
 	"bytes"
+	"context"
 	"encoding/base64"
 	stderrors "errors"
 	"io"
+	"net"
 	"net/http"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/pkg/errors"
 
 	"github.com/ory/x/httpx"
+	"github.com/ory/x/objectstoragex"
 	"github.com/ory/x/stringsx"
 )
 
-// Fetcher is able to load file contents from http, https, file, and base64 locations.
+// Fetcher is able to load file contents from http, https, file, base64, s3, gs, and azblob
+// locations.
 type Fetcher struct {
-	hc *retryablehttp.Client
+	hc             *retryablehttp.Client
+	cache          httpx.CacheStore
+	resolver       *objectstoragex.Resolver
+	maxConcurrency int
 }
 
 type opts struct {
-	hc *retryablehttp.Client
+	c              *http.Client
+	resilient      []httpx.ResilientOptions
+	cache          httpx.CacheStore
+	objectStorage  []objectstoragex.Option
+	resolver       *objectstoragex.Resolver
+	maxConcurrency int
 }
 
+type fetchOpts struct {
+	integrity string
+	maxBytes  int64
+	timeout   time.Duration
+}
+
+// FetchOption configures a single call to Fetch.
+type FetchOption func(*fetchOpts)
+
 var ErrUnknownScheme = stderrors.New("unknown scheme")
 
+// ErrSourceTooLarge is returned if the source exceeds the limit set with WithMaxBytes.
+var ErrSourceTooLarge = stderrors.New("the source exceeds the size limit")
+
+// ErrObjectStorageNotAllowed is returned for object storage sources of schemes or buckets which
+// were not enabled with WithObjectStorage.
+var ErrObjectStorageNotAllowed = objectstoragex.ErrNotAllowed
+
 // WithClient sets the http.Client the fetcher uses.
 func WithClient(hc *http.Client) func(*opts) {
 	return func(o *opts) {
-		o.hc = httpx.NewResilientClient(httpx.ResilientClientWithClient(hc))
+		o.c = hc
+	}
+}
+
+// WithInternalIPsDisallowed refuses to fetch http, https, and object storage sources from
+// internal IP addresses such as loopback, private, and link-local addresses, except for the
+// allowed networks. Use this if sources are supplied by users, to prevent server-side request
+// forgery. See httpx.ResilientClientDisallowInternalIPs for details.
+//
+// It does not affect file and base64 sources.
+func WithInternalIPsDisallowed(allowed ...*net.IPNet) func(*opts) {
+	return func(o *opts) {
+		o.resilient = append(o.resilient, httpx.ResilientClientDisallowInternalIPs(allowed...))
+	}
+}
+
+// WithCache caches remote sources in the store, for example httpx.NewMemoryCacheStore or
+// httpx.NewDiskCacheStore, using the caching transport of httpx: cached content is used while it
+// is fresh according to its Cache-Control and Expires headers, and revalidated with
+// If-None-Match and If-Modified-Since requests if the server sent an ETag or Last-Modified header.
+func WithCache(store httpx.CacheStore) func(*opts) {
+	return func(o *opts) {
+		if store == nil {
+			store = httpx.NewMemoryCacheStore()
+		}
+		o.cache = store
+		o.resilient = append(o.resilient, httpx.ResilientClientWithCache(store))
+	}
+}
+
+// WithIntegrity requires the fetched content to match the integrity metadata, which uses the
+// format of Subresource Integrity, for example "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=".
+// Otherwise, Fetch returns ErrIntegrityMismatch. Cached content which matches the integrity
+// metadata is returned without revalidation.
+func WithIntegrity(metadata string) FetchOption {
+	return func(o *fetchOpts) {
+		o.integrity = metadata
+	}
+}
+
+// WithMaxBytes fails fetching sources larger than maxBytes with ErrSourceTooLarge.
+func WithMaxBytes(maxBytes int64) FetchOption {
+	return func(o *fetchOpts) {
+		o.maxBytes = maxBytes
+	}
+}
+
+// WithTimeout cancels fetching the source after the timeout, including retries.
+func WithTimeout(timeout time.Duration) FetchOption {
+	return func(o *fetchOpts) {
+		o.timeout = timeout
 	}
 }
 
 func newOpts() *opts {
 	return &opts{
-		hc: httpx.NewResilientClient(),
+		maxConcurrency: defaultMaxConcurrency,
 	}
 }
 
@@ -46,16 +125,78 @@ func NewFetcher(opts ...func(*opts)) *Fetcher {
 	for _, f := range opts {
 		f(o)
 	}
-	return &Fetcher{hc: o.hc}
+
+	if o.maxConcurrency < 1 {
+		o.maxConcurrency = 1
+	}
+
+	resilient := o.resilient
+	if o.c != nil {
+		resilient = append([]httpx.ResilientOptions{httpx.ResilientClientWithClient(o.c)}, resilient...)
+	}
+	return &Fetcher{
+		hc:             httpx.NewResilientClient(resilient...),
+		cache:          o.cache,
+		resolver:       newResolver(o),
+		maxConcurrency: o.maxConcurrency,
+	}
+}
+
+// Fetch fetches the file contents from the source. Objects of s3://<bucket>/<key>,
+// gs://<bucket>/<key>, and azblob://<container>/<blob> sources of the buckets enabled with
+// WithObjectStorage are fetched using the ambient credentials of the environment:
+//
+//	s3:     AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and AWS_REGION
+//	gs:     GOOGLE_APPLICATION_CREDENTIALS or the metadata server on Google Cloud
+//	azblob: AZURE_STORAGE_ACCOUNT and either AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN
+//
+// Without credentials, only public objects can be fetched.
+func (f *Fetcher) Fetch(source string, opts ...FetchOption) (*bytes.Buffer, error) {
+	return f.FetchContext(context.Background(), source, opts...)
+}
+
+// FetchContext is like Fetch but cancels fetching remote sources once the context is done.
+func (f *Fetcher) FetchContext(ctx context.Context, source string, opts ...FetchOption) (*bytes.Buffer, error) {
+	o := new(fetchOpts)
+	for _, opt := range opts {
+		opt(o)
+	}
+	integrity, err := parseIntegrity(o.integrity)
+	if err != nil {
+		return nil, err
+	}
+
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	b, err := f.fetch(ctx, source, o, integrity)
+	if err != nil {
+		return nil, err
+	}
+	// base64 and cached sources are not limited while reading them
+	if o.maxBytes > 0 && int64(b.Len()) > o.maxBytes {
+		return nil, errors.Wrapf(ErrSourceTooLarge, "limit of %d bytes exceeded when fetching: %s", o.maxBytes, source)
+	}
+	if err := verifyIntegrity(b.Bytes(), integrity); err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+	return b, nil
 }
 
-// Fetch fetches the file contents from the source.
-func (f *Fetcher) Fetch(source string) (*bytes.Buffer, error) {
+func (f *Fetcher) fetch(ctx context.Context, source string, o *fetchOpts, integrity []integrityHash) (*bytes.Buffer, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+
 	switch s := stringsx.SwitchPrefix(source); {
-	case s.HasPrefix("http://"), s.HasPrefix("https://"):
-		return f.fetchRemote(source)
+	case s.HasPrefix("http://"), s.HasPrefix("https://"),
+		s.HasPrefix("s3://"), s.HasPrefix("gs://"), s.HasPrefix("azblob://"):
+		return f.fetchRemote(ctx, source, o, integrity)
 	case s.HasPrefix("file://"):
-		return f.fetchFile(strings.Replace(source, "file://", "", 1))
+		return f.fetchFile(strings.Replace(source, "file://", "", 1), o)
 	case s.HasPrefix("base64://"):
 		src, err := base64.StdEncoding.DecodeString(strings.Replace(source, "base64://", "", 1))
 		if err != nil {
@@ -67,34 +208,120 @@ func (f *Fetcher) Fetch(source string) (*bytes.Buffer, error) {
 	}
 }
 
-func (f *Fetcher) fetchRemote(source string) (*bytes.Buffer, error) {
-	res, err := f.hc.Get(source)
+func (f *Fetcher) fetchRemote(ctx context.Context, source string, o *fetchOpts, integrity []integrityHash) (*bytes.Buffer, error) {
+	if o.maxBytes > 0 {
+		// limits the response before the caching transport reads it
+		ctx = httpx.ContextWithResponseSizeLimit(ctx, o.maxBytes)
+	}
+
+	var (
+		req *http.Request
+		err error
+	)
+	if objectstoragex.IsObjectStorageURL(source) {
+		req, err = f.resolver.NewRequest(ctx, source)
+	} else {
+		req, err = http.NewRequestWithContext(ctx, "GET", source, nil)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
+
+	// content matching the integrity metadata can not be outdated
+	if f.cache != nil && len(integrity) > 0 {
+		if b, ok := f.fetchCached(req, o, integrity); ok {
+			return b, nil
+		}
+	}
+
+	rreq, err := retryablehttp.FromRequest(req)
 	if err != nil {
 		return nil, errors.Wrapf(err, "rule: %s", source)
 	}
+	res, err := f.hc.Do(rreq)
+	if err != nil {
+		return nil, errors.Wrapf(sizeError(err), "rule: %s", source)
+	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusOK {
 		return nil, errors.Errorf("expected http response status code 200 but got %d when fetching: %s", res.StatusCode, source)
 	}
+	if o.maxBytes > 0 && res.ContentLength > o.maxBytes {
+		return nil, errors.Wrapf(ErrSourceTooLarge, "limit of %d bytes exceeded when fetching: %s", o.maxBytes, source)
+	}
+
+	b, err := f.decode(res.Body, o.maxBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rule: %s", source)
+	}
 
-	return f.decode(res.Body)
+	// content not matching the integrity metadata must not stay cached
+	if f.cache != nil && verifyIntegrity(b.Bytes(), integrity) != nil {
+		f.cache.Delete(httpx.CacheKey(req))
+	}
+	return b, nil
+}
+
+// fetchCached returns the cached content of the request, even if it is stale, if it matches the
+// integrity metadata.
+func (f *Fetcher) fetchCached(req *http.Request, o *fetchOpts, integrity []integrityHash) (*bytes.Buffer, bool) {
+	cr := req.Clone(req.Context())
+	cr.Header.Set("Cache-Control", "only-if-cached")
+
+	// the retrying client would retry the 504 response for content which is not cached
+	res, err := f.hc.HTTPClient.Do(cr)
+	if err != nil {
+		return nil, false
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, false
+	}
+
+	b, err := f.decode(res.Body, o.maxBytes)
+	if err != nil || verifyIntegrity(b.Bytes(), integrity) != nil {
+		return nil, false
+	}
+	return b, true
 }
 
-func (f *Fetcher) fetchFile(source string) (*bytes.Buffer, error) {
+func (f *Fetcher) fetchFile(source string, o *fetchOpts) (*bytes.Buffer, error) {
 	fp, err := os.Open(source)
 	if err != nil {
 		return nil, errors.Wrapf(err, "unable to fetch from source: %s", source)
 	}
 	defer fp.Close()
 
-	return f.decode(fp)
+	b, err := f.decode(fp, o.maxBytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to fetch from source: %s", source)
+	}
+	return b, nil
+}
+
+// sizeError returns ErrSourceTooLarge if the response exceeded the limit set with
+// httpx.ContextWithResponseSizeLimit.
+func sizeError(err error) error {
+	var tooLarge *httpx.ResponseTooLargeError
+	if errors.As(err, &tooLarge) {
+		return errors.Wrapf(ErrSourceTooLarge, "limit of %d bytes exceeded", tooLarge.Limit)
+	}
+	return err
 }
 
-func (f *Fetcher) decode(r io.Reader) (*bytes.Buffer, error) {
+// decode reads r, but at most maxBytes bytes if maxBytes is positive.
+func (f *Fetcher) decode(r io.Reader, maxBytes int64) (*bytes.Buffer, error) {
+	if maxBytes > 0 {
+		r = io.LimitReader(r, maxBytes+1)
+	}
+
 	var b bytes.Buffer
 	if _, err := io.Copy(&b, r); err != nil {
-		return nil, err
+		return nil, sizeError(err)
+	}
+	if maxBytes > 0 && int64(b.Len()) > maxBytes {
+		return nil, errors.Wrapf(ErrSourceTooLarge, "limit of %d bytes exceeded", maxBytes)
 	}
 	return &b, nil
 }
@@ -1,18 +1,24 @@
 package fetcher
 
 import (
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/gobuffalo/httptest"
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/httpx"
 )
 
 func TestFetcher(t *testing.T) {
@@ -67,3 +73,81 @@ func TestFetcher(t *testing.T) {
 		assert.Contains(t, err.Error(), "unknown-scheme")
 	})
 }
+
+func TestFetcherLimits(t *testing.T) {
+	router := httprouter.New()
+	router.GET("/", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		_, _ = w.Write([]byte(`{"foo":"bar"}`))
+	})
+	router.GET("/chunked", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		_, _ = w.Write([]byte(`{"foo":`))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(`"bar"}`))
+	})
+	router.GET("/slow", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	})
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	file := filepath.Join(t.TempDir(), "source.json")
+	require.NoError(t, ioutil.WriteFile(file, []byte(`{"foo":"bar"}`), 0600))
+
+	for _, source := range []string{
+		"base64://" + base64.StdEncoding.EncodeToString([]byte(`{"foo":"bar"}`)),
+		"file://" + file,
+		ts.URL,
+		ts.URL + "/chunked",
+	} {
+		t.Run("source="+source, func(t *testing.T) {
+			actual, err := NewFetcher().Fetch(source, WithMaxBytes(13))
+			require.NoError(t, err)
+			assert.Equal(t, `{"foo":"bar"}`, actual.String())
+
+			_, err = NewFetcher().Fetch(source, WithMaxBytes(12))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrSourceTooLarge), "%+v", err)
+		})
+	}
+
+	t.Run("case=timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := NewFetcher().Fetch(ts.URL+"/slow", WithTimeout(50*time.Millisecond))
+		require.Error(t, err)
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+	})
+
+	t.Run("case=canceled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := NewFetcher().FetchContext(ctx, ts.URL)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.Canceled), "%+v", err)
+	})
+}
+
+func TestFetcherInternalIPs(t *testing.T) {
+	router := httprouter.New()
+	router.GET("/", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		_, _ = w.Write([]byte(`{"foo":"bar"}`))
+	})
+	ts := httptest.NewServer(router)
+	t.Cleanup(ts.Close)
+
+	_, err := NewFetcher(WithInternalIPsDisallowed()).Fetch(ts.URL)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, httpx.ErrInternalIPAddress), "%+v", err)
+
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+	actual, err := NewFetcher(WithClient(ts.Client()), WithInternalIPsDisallowed(loopback)).Fetch(ts.URL)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"foo":"bar"}`, actual.String())
+
+	// file and base64 sources are not affected
+	_, err = NewFetcher(WithInternalIPsDisallowed()).Fetch("base64://" + base64.StdEncoding.EncodeToString([]byte(`{}`)))
+	require.NoError(t, err)
+}
@@ -0,0 +1,87 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	stderrors "errors"
+	"hash"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrIntegrityMismatch is returned if the fetched content does not match the integrity hashes.
+var ErrIntegrityMismatch = stderrors.New("the fetched content does not match the integrity hash")
+
+// integrityAlgorithms are the supported algorithms, from the weakest to the strongest.
+var integrityAlgorithms = []struct {
+	name string
+	new  func() hash.Hash
+}{
+	{name: "sha256", new: sha256.New},
+	{name: "sha384", new: sha512.New384},
+	{name: "sha512", new: sha512.New},
+}
+
+type integrityHash struct {
+	strength int
+	digest   []byte
+}
+
+// parseIntegrity parses integrity metadata like in Subresource Integrity, for example
+// "sha256-47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=". Several hashes are separated by
+// whitespace.
+func parseIntegrity(metadata string) ([]integrityHash, error) {
+	var hashes []integrityHash
+	for _, h := range strings.Fields(metadata) {
+		parts := strings.SplitN(h, "-", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("integrity hash %q must have the format <algorithm>-<base64 digest>", h)
+		}
+
+		strength := -1
+		for i, a := range integrityAlgorithms {
+			if a.name == parts[0] {
+				strength = i
+			}
+		}
+		if strength < 0 {
+			return nil, errors.Errorf("integrity hash %q uses an unsupported algorithm, supported are sha256, sha384, and sha512", h)
+		}
+
+		// options like "?foo" are allowed by the specification but have no meaning yet
+		digest, err := base64.StdEncoding.DecodeString(strings.SplitN(parts[1], "?", 2)[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to decode integrity hash %q", h)
+		}
+		hashes = append(hashes, integrityHash{strength: strength, digest: digest})
+	}
+	return hashes, nil
+}
+
+// verifyIntegrity returns ErrIntegrityMismatch if the content does not match any hash of the
+// strongest algorithm used, as in Subresource Integrity.
+func verifyIntegrity(content []byte, hashes []integrityHash) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	strongest := 0
+	for _, h := range hashes {
+		if h.strength > strongest {
+			strongest = h.strength
+		}
+	}
+
+	d := integrityAlgorithms[strongest].new()
+	_, _ = d.Write(content)
+	digest := d.Sum(nil)
+	for _, h := range hashes {
+		if h.strength == strongest && subtle.ConstantTimeCompare(h.digest, digest) == 1 {
+			return nil
+		}
+	}
+	return errors.WithStack(ErrIntegrityMismatch)
+}
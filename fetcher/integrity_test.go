@@ -0,0 +1,58 @@
+package fetcher
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sri(content string) string {
+	d := sha256.Sum256([]byte(content))
+	return "sha256-" + base64.StdEncoding.EncodeToString(d[:])
+}
+
+func TestIntegrity(t *testing.T) {
+	content := `{"foo":"zab"}`
+	source := "base64://" + base64.StdEncoding.EncodeToString([]byte(content))
+	sha512Sum := sha512.Sum512([]byte(content))
+	sha512Hash := "sha512-" + base64.StdEncoding.EncodeToString(sha512Sum[:])
+
+	for k, tc := range []struct {
+		integrity string
+		err       error
+		errMsg    string
+	}{
+		{integrity: ""},
+		{integrity: sri(content)},
+		{integrity: sri(content) + "?opt"},
+		{integrity: sri("other") + " " + sri(content)},
+		{integrity: sha512Hash},
+		// only the strongest algorithm is used
+		{integrity: sri(content) + " " + sha512Hash},
+		{integrity: sri("other"), err: ErrIntegrityMismatch},
+		{integrity: sri(content) + " sha512-" + base64.StdEncoding.EncodeToString([]byte("other")), err: ErrIntegrityMismatch},
+		{integrity: "md5-1B2M2Y8AsgTpgAmY7PhCfg==", errMsg: "unsupported algorithm"},
+		{integrity: "sha256", errMsg: "must have the format"},
+		{integrity: "sha256-!", errMsg: "unable to decode"},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			actual, err := NewFetcher().Fetch(source, WithIntegrity(tc.integrity))
+			if tc.err != nil {
+				require.ErrorIs(t, err, tc.err)
+				return
+			}
+			if tc.errMsg != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, content, actual.String())
+		})
+	}
+}
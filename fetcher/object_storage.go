@@ -0,0 +1,55 @@
+package fetcher
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/ory/x/objectstoragex"
+)
+
+// WithObjectStorage enables fetching s3, gs, or azblob sources of the buckets or containers,
+// using the ambient credentials of the environment. Object storage sources are refused by
+// default, because the ambient credentials usually grant access to private buckets, which users
+// must not be able to read if sources are supplied by users. WithInternalIPsDisallowed does not
+// protect against this.
+//
+// Use "*" to allow all buckets of the scheme, which is only safe if sources are never supplied
+// by users:
+//
+//	fetcher.NewFetcher(fetcher.WithObjectStorage("s3", "my-config-bucket"))
+func WithObjectStorage(scheme string, buckets ...string) func(*opts) {
+	return func(o *opts) {
+		o.objectStorage = append(o.objectStorage, objectstoragex.WithBuckets(scheme, buckets...))
+	}
+}
+
+// WithObjectStorageEndpoint sets the endpoint of the s3, gs, or azblob scheme, for example of S3
+// compatible storage or a storage emulator. Objects are requested from `<endpoint>/<bucket>/<key>`.
+func WithObjectStorageEndpoint(scheme string, endpoint *url.URL) func(*opts) {
+	return func(o *opts) {
+		o.objectStorage = append(o.objectStorage, objectstoragex.WithEndpoint(scheme, endpoint))
+	}
+}
+
+// WithObjectStorageResolver sets the resolver of object storage sources, for example to share it
+// with watcherx.WatchObjectStorage. WithObjectStorage and WithObjectStorageEndpoint are ignored.
+func WithObjectStorageResolver(r *objectstoragex.Resolver) func(*opts) {
+	return func(o *opts) {
+		o.resolver = r
+	}
+}
+
+// newResolver returns the resolver of object storage sources. Unlike sources, the token
+// endpoints are not supplied by users and the metadata server has an internal IP address, so
+// access tokens are requested with the client set with WithClient instead of the resilient
+// client.
+func newResolver(o *opts) *objectstoragex.Resolver {
+	if o.resolver != nil {
+		return o.resolver
+	}
+	tokenClient := http.DefaultClient
+	if o.c != nil {
+		tokenClient = o.c
+	}
+	return objectstoragex.NewResolver(append([]objectstoragex.Option{objectstoragex.WithTokenClient(tokenClient)}, o.objectStorage...)...)
+}
@@ -0,0 +1,119 @@
+package fetcher
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/objectstoragex"
+)
+
+func mapEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func newObjectServer(t *testing.T, requests chan<- *http.Request) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- r
+		_, _ = w.Write([]byte(`{"foo":"bar"}`))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestObjectStorage(t *testing.T) {
+	requests := make(chan *http.Request, 10)
+	ts := newObjectServer(t, requests)
+	endpoint, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	t.Run("case=s3", func(t *testing.T) {
+		f := NewFetcher(WithObjectStorageResolver(objectstoragex.NewResolver(
+			objectstoragex.WithBuckets("s3", "bucket"), objectstoragex.WithEndpoint("s3", endpoint), objectstoragex.WithEnv(mapEnv(nil)))))
+		actual, err := f.Fetch("s3://bucket/path/to/schema.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"foo":"bar"}`, actual.String())
+		r := <-requests
+		assert.Equal(t, "/bucket/path/to/schema.json", r.URL.Path)
+		assert.Empty(t, r.Header.Get("Authorization"))
+
+		f = NewFetcher(WithObjectStorageResolver(objectstoragex.NewResolver(
+			objectstoragex.WithBuckets("s3", "bucket"), objectstoragex.WithEndpoint("s3", endpoint),
+			objectstoragex.WithEnv(mapEnv(map[string]string{
+				"AWS_ACCESS_KEY_ID":     "id",
+				"AWS_SECRET_ACCESS_KEY": "secret",
+				"AWS_SESSION_TOKEN":     "token",
+				"AWS_REGION":            "eu-central-1",
+			})))))
+		_, err = f.Fetch("s3://bucket/path/with%20space.json")
+		require.NoError(t, err)
+		r = <-requests
+		assert.Equal(t, "/bucket/path/with space.json", r.URL.Path)
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=id/")
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-central-1/s3/aws4_request")
+		assert.Equal(t, "token", r.Header.Get("X-Amz-Security-Token"))
+	})
+
+	t.Run("case=gs", func(t *testing.T) {
+		f := NewFetcher(WithObjectStorage("gs", "*"), WithObjectStorageEndpoint("gs", endpoint))
+
+		actual, err := f.Fetch("gs://bucket/schema.json")
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"foo":"bar"}`, actual.String())
+		r := <-requests
+		assert.Equal(t, "/bucket/schema.json", r.URL.Path)
+	})
+
+	t.Run("case=azblob", func(t *testing.T) {
+		f := NewFetcher(WithObjectStorageResolver(objectstoragex.NewResolver(
+			objectstoragex.WithBuckets("azblob", "container"), objectstoragex.WithEndpoint("azblob", endpoint),
+			objectstoragex.WithEnv(mapEnv(map[string]string{
+				"AZURE_STORAGE_ACCOUNT": "account",
+				"AZURE_STORAGE_KEY":     base64.StdEncoding.EncodeToString([]byte("key")),
+			})))))
+		_, err = f.Fetch("azblob://container/schema.json")
+		require.NoError(t, err)
+		r := <-requests
+		assert.Equal(t, "/container/schema.json", r.URL.Path)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "SharedKeyLite account:"), r.Header.Get("Authorization"))
+	})
+
+	t.Run("case=invalid URL", func(t *testing.T) {
+		_, err := NewFetcher(WithObjectStorage("s3", "*")).Fetch("s3://bucket")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "s3://<bucket>/<key>")
+	})
+
+	t.Run("case=object storage is disabled by default", func(t *testing.T) {
+		for _, source := range []string{"s3://bucket/a.json", "gs://bucket/a.json", "azblob://container/a.json"} {
+			_, err := NewFetcher(WithObjectStorageEndpoint("s3", endpoint)).Fetch(source)
+			assert.True(t, errors.Is(err, ErrObjectStorageNotAllowed), "%s: %+v", source, err)
+		}
+		assert.Empty(t, requests)
+	})
+
+	t.Run("case=buckets are allowed explicitly", func(t *testing.T) {
+		f := NewFetcher(WithObjectStorage("s3", "bucket", "other"), WithObjectStorageEndpoint("s3", endpoint))
+		_, err := f.Fetch("s3://private/a.json")
+		assert.True(t, errors.Is(err, ErrObjectStorageNotAllowed), "%+v", err)
+		assert.Empty(t, requests)
+
+		_, err = f.Fetch("gs://bucket/a.json")
+		assert.True(t, errors.Is(err, ErrObjectStorageNotAllowed), "%+v", err)
+
+		for _, source := range []string{"s3://bucket/a.json", "s3://other/a.json"} {
+			_, err := f.Fetch(source)
+			require.NoError(t, err)
+			<-requests
+		}
+	})
+}
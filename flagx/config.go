@@ -0,0 +1,197 @@
+package flagx
+
+import (
+	"github.com/knadh/koanf"
+	"github.com/knadh/koanf/maps"
+	"github.com/pkg/errors"
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+
+	"github.com/ory/x/configx"
+	"github.com/ory/x/jsonschemax"
+)
+
+// configKeyAnnotation marks flags registered by RegisterConfigFlags with their config key.
+const configKeyAnnotation = "flagx_config_key"
+
+// RegisterConfigFlags registers a flag for each of the config keys, for example
+// "serve.public.port". The name of the flag is the key, the default value and the usage are
+// taken from the JSON schema of the config. Keys of the types string, integer, number, boolean,
+// and arrays of them are supported.
+//
+// Pass WithConfigFlags to configx.New to load the values of the flags into the config.
+func RegisterConfigFlags(flags *pflag.FlagSet, schema []byte, keys ...string) error {
+	paths, err := jsonschemax.ListPathsBytes(schema, -1)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	byName := make(map[string]jsonschemax.Path, len(paths))
+	for _, p := range paths {
+		byName[p.Name] = p
+	}
+
+	for _, key := range keys {
+		p, ok := byName[key]
+		if !ok {
+			return errors.Errorf("the config key %q does not exist in the JSON schema", key)
+		}
+		if err := registerConfigFlag(flags, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func registerConfigFlag(flags *pflag.FlagSet, p jsonschemax.Path) error {
+	usage := p.Description
+	if usage == "" {
+		usage = p.Title
+	}
+
+	var err error
+	switch def := p.Default; p.TypeHint {
+	case jsonschemax.String:
+		var v string
+		v, err = cast.ToStringE(orZero(def, ""))
+		flags.String(p.Name, v, usage)
+	case jsonschemax.Int:
+		var v int64
+		v, err = cast.ToInt64E(orZero(def, 0))
+		flags.Int64(p.Name, v, usage)
+	case jsonschemax.Float:
+		var v float64
+		v, err = cast.ToFloat64E(orZero(def, 0))
+		flags.Float64(p.Name, v, usage)
+	case jsonschemax.Bool:
+		var v bool
+		v, err = cast.ToBoolE(orZero(def, false))
+		flags.Bool(p.Name, v, usage)
+	case jsonschemax.StringSlice:
+		var v []string
+		v, err = cast.ToStringSliceE(orZero(def, []string{}))
+		flags.StringSlice(p.Name, v, usage)
+	case jsonschemax.IntSlice:
+		v := make([]int64, 0)
+		err = castSlice(def, func(i interface{}) (err error) {
+			var item int64
+			item, err = cast.ToInt64E(i)
+			v = append(v, item)
+			return err
+		})
+		flags.Int64Slice(p.Name, v, usage)
+	case jsonschemax.FloatSlice:
+		v := make([]float64, 0)
+		err = castSlice(def, func(i interface{}) (err error) {
+			var item float64
+			item, err = cast.ToFloat64E(i)
+			v = append(v, item)
+			return err
+		})
+		flags.Float64Slice(p.Name, v, usage)
+	case jsonschemax.BoolSlice:
+		v := make([]bool, 0)
+		err = castSlice(def, func(i interface{}) (err error) {
+			var item bool
+			item, err = cast.ToBoolE(i)
+			v = append(v, item)
+			return err
+		})
+		flags.BoolSlice(p.Name, v, usage)
+	default:
+		return errors.Errorf("the config key %q can not be set by a flag because its type is not supported", p.Name)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to use the default value of config key %q as the default of its flag", p.Name)
+	}
+
+	return flags.SetAnnotation(p.Name, configKeyAnnotation, []string{p.Name})
+}
+
+func orZero(v, zero interface{}) interface{} {
+	if v == nil {
+		return zero
+	}
+	return v
+}
+
+func castSlice(v interface{}, f func(item interface{}) error) error {
+	if v == nil {
+		return nil
+	}
+	items, ok := v.([]interface{})
+	if !ok {
+		return errors.Errorf("expected an array but got %T", v)
+	}
+	for _, i := range items {
+		if err := f(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WithConfigFlags loads the values of the flags registered with RegisterConfigFlags into the
+// config. Only flags which are set on the command line are loaded, so that the precedence is:
+//
+//	flag > environment variable > config file > default
+func WithConfigFlags(flags *pflag.FlagSet) configx.OptionModifier {
+	return configx.WithOverrideProviders(NewConfigProvider(flags))
+}
+
+// ConfigProvider is a koanf.Provider of the values of the flags registered with
+// RegisterConfigFlags. See WithConfigFlags.
+type ConfigProvider struct {
+	flags *pflag.FlagSet
+}
+
+var _ koanf.Provider = (*ConfigProvider)(nil)
+
+// NewConfigProvider returns a provider of the flags registered with RegisterConfigFlags.
+func NewConfigProvider(flags *pflag.FlagSet) *ConfigProvider {
+	return &ConfigProvider{flags: flags}
+}
+
+// ReadBytes is not supported by the flag provider.
+func (c *ConfigProvider) ReadBytes() ([]byte, error) {
+	return nil, errors.New("flag provider does not support this method")
+}
+
+// Read returns the values of the flags which are set on the command line.
+func (c *ConfigProvider) Read() (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	var err error
+	c.flags.Visit(func(f *pflag.Flag) {
+		keys := f.Annotations[configKeyAnnotation]
+		if len(keys) == 0 || err != nil {
+			return
+		}
+
+		var v interface{}
+		switch f.Value.Type() {
+		case "string":
+			v, err = c.flags.GetString(f.Name)
+		case "int64":
+			v, err = c.flags.GetInt64(f.Name)
+		case "float64":
+			v, err = c.flags.GetFloat64(f.Name)
+		case "bool":
+			v, err = c.flags.GetBool(f.Name)
+		case "stringSlice":
+			v, err = c.flags.GetStringSlice(f.Name)
+		case "int64Slice":
+			v, err = c.flags.GetInt64Slice(f.Name)
+		case "float64Slice":
+			v, err = c.flags.GetFloat64Slice(f.Name)
+		case "boolSlice":
+			v, err = c.flags.GetBoolSlice(f.Name)
+		default:
+			err = errors.Errorf("the flag %q has the unsupported type %s", f.Name, f.Value.Type())
+		}
+		values[keys[0]] = v
+	})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return maps.Unflatten(values, configx.Delimiter), nil
+}
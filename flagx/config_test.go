@@ -0,0 +1,94 @@
+package flagx
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/configx"
+)
+
+const configSchema = `{
+  "$id": "https://example.com/config.schema.json",
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "serve": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "integer", "default": 4433, "description": "The port to listen on."},
+        "host": {"type": "string", "default": "localhost"},
+        "tls": {"type": "boolean"}
+      }
+    },
+    "log": {
+      "type": "object",
+      "properties": {
+        "level": {"type": "string", "enum": ["debug", "info", "error"], "default": "info"}
+      }
+    },
+    "urls": {"type": "array", "items": {"type": "string"}, "default": ["https://a.example.com"]},
+    "weights": {"type": "array", "items": {"type": "number"}},
+    "object": {"type": "object", "properties": {}}
+  }
+}`
+
+func TestConfigFlags(t *testing.T) {
+	newFlags := func(t *testing.T) *pflag.FlagSet {
+		flags := NewFlagSet("test")
+		require.NoError(t, RegisterConfigFlags(flags, []byte(configSchema), "serve.port", "serve.host", "serve.tls", "log.level", "urls", "weights"))
+		return flags
+	}
+
+	t.Run("case=defaults and usage", func(t *testing.T) {
+		flags := newFlags(t)
+		assert.Equal(t, "4433", flags.Lookup("serve.port").DefValue)
+		assert.Equal(t, "The port to listen on.", flags.Lookup("serve.port").Usage)
+		assert.Equal(t, "[https://a.example.com]", flags.Lookup("urls").DefValue)
+		assert.Equal(t, "false", flags.Lookup("serve.tls").DefValue)
+
+		require.NoError(t, flags.Parse([]string{"--urls", "https://b.example.com"}))
+		urls, err := flags.GetStringSlice("urls")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"https://b.example.com"}, urls, "the default is replaced, not appended to")
+	})
+
+	t.Run("case=unsupported keys", func(t *testing.T) {
+		assert.Error(t, RegisterConfigFlags(NewFlagSet("test"), []byte(configSchema), "object"))
+		assert.Error(t, RegisterConfigFlags(NewFlagSet("test"), []byte(configSchema), "does.not.exist"))
+	})
+
+	t.Run("case=precedence", func(t *testing.T) {
+		dir := t.TempDir()
+		file := filepath.Join(dir, "config.yml")
+		require.NoError(t, ioutil.WriteFile(file, []byte("serve:\n  port: 1000\n  host: file.example.com\nlog:\n  level: debug\n"), 0600))
+
+		require.NoError(t, os.Setenv("SERVE_PORT", "2000"))
+		require.NoError(t, os.Setenv("SERVE_HOST", "env.example.com"))
+		defer os.Unsetenv("SERVE_PORT")
+		defer os.Unsetenv("SERVE_HOST")
+
+		flags := newFlags(t)
+		require.NoError(t, flags.Parse([]string{"--serve.port", "3000", "--weights", "0.5,1.5", "--serve.tls"}))
+
+		p, err := configx.New([]byte(configSchema),
+			configx.WithContext(context.Background()),
+			configx.WithConfigFiles(file),
+			WithConfigFlags(flags),
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, 3000, p.Int("serve.port"), "flag > env")
+		assert.Equal(t, "env.example.com", p.String("serve.host"), "env > file")
+		assert.Equal(t, "debug", p.String("log.level"), "file > default")
+		assert.Equal(t, []string{"https://a.example.com"}, p.Strings("urls"), "default")
+		assert.True(t, p.Bool("serve.tls"))
+		assert.Equal(t, []float64{0.5, 1.5}, p.Float64s("weights"))
+	})
+}
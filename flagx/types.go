@@ -0,0 +1,332 @@
+package flagx
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/ory/x/cmdx"
+)
+
+type (
+	// StringSliceMapValue is a flag of repeated key=value pairs, for example
+	// "--header Accept=text/html --header Accept=application/json". Values of the same key are
+	// collected in the order they were given. The value may contain "=".
+	StringSliceMapValue struct {
+		value   *map[string][]string
+		changed bool
+	}
+
+	// EscapedStringSliceValue is a flag of comma-separated values, where a comma which is part of
+	// a value is escaped with a backslash, for example "--scope a\,b,c" is ["a,b", "c"]. The flag
+	// may be repeated.
+	EscapedStringSliceValue struct {
+		value   *[]string
+		changed bool
+	}
+
+	// DurationValue is a time.Duration flag which accepts the units "d" (24 hours) and "w" (7
+	// days) in addition to the units of time.ParseDuration, for example "1d2h" or "2w". It is
+	// compatible with pflag.FlagSet.GetDuration and MustGetDuration.
+	DurationValue time.Duration
+)
+
+var (
+	_ pflag.Value      = (*StringSliceMapValue)(nil)
+	_ pflag.SliceValue = (*EscapedStringSliceValue)(nil)
+	_ pflag.Value      = (*DurationValue)(nil)
+)
+
+// StringSliceMap defines a flag of repeated key=value pairs. See StringSliceMapValue.
+func StringSliceMap(flags *pflag.FlagSet, name string, value map[string][]string, usage string) *map[string][]string {
+	return StringSliceMapP(flags, name, "", value, usage)
+}
+
+// StringSliceMapP is like StringSliceMap, but accepts a shorthand letter.
+func StringSliceMapP(flags *pflag.FlagSet, name, shorthand string, value map[string][]string, usage string) *map[string][]string {
+	p := new(map[string][]string)
+	*p = value
+	flags.VarP(&StringSliceMapValue{value: p}, name, shorthand, usage)
+	return p
+}
+
+func (v *StringSliceMapValue) Set(s string) error {
+	kv := strings.SplitN(s, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return errors.Errorf("expected key=value but got %q", s)
+	}
+	if !v.changed {
+		// The first value replaces the default.
+		*v.value = map[string][]string{}
+		v.changed = true
+	}
+	(*v.value)[kv[0]] = append((*v.value)[kv[0]], kv[1])
+	return nil
+}
+
+func (v *StringSliceMapValue) Type() string { return "stringSliceMap" }
+
+func (v *StringSliceMapValue) String() string {
+	keys := make([]string, 0, len(*v.value))
+	for k := range *v.value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		for _, value := range (*v.value)[k] {
+			pairs = append(pairs, k+"="+value)
+		}
+	}
+	return "[" + strings.Join(pairs, ",") + "]"
+}
+
+// GetStringSliceMap returns the value of a flag defined with StringSliceMap.
+func GetStringSliceMap(flags *pflag.FlagSet, name string) (map[string][]string, error) {
+	f := flags.Lookup(name)
+	if f == nil {
+		return nil, errors.Errorf("flag accessed but not defined: %s", name)
+	}
+	v, ok := f.Value.(*StringSliceMapValue)
+	if !ok {
+		return nil, errors.Errorf("trying to get stringSliceMap value of flag of type %s", f.Value.Type())
+	}
+	return *v.value, nil
+}
+
+// MustGetStringSliceMap returns a map[string][]string flag or fatals if an error occurs.
+func MustGetStringSliceMap(cmd *cobra.Command, name string) map[string][]string {
+	v, err := GetStringSliceMap(cmd.Flags(), name)
+	if err != nil {
+		cmdx.Fatalf(err.Error())
+	}
+	return v
+}
+
+// EscapedStringSlice defines a flag of comma-separated values with escaping. See
+// EscapedStringSliceValue.
+func EscapedStringSlice(flags *pflag.FlagSet, name string, value []string, usage string) *[]string {
+	return EscapedStringSliceP(flags, name, "", value, usage)
+}
+
+// EscapedStringSliceP is like EscapedStringSlice, but accepts a shorthand letter.
+func EscapedStringSliceP(flags *pflag.FlagSet, name, shorthand string, value []string, usage string) *[]string {
+	p := new([]string)
+	*p = value
+	flags.VarP(&EscapedStringSliceValue{value: p}, name, shorthand, usage)
+	return p
+}
+
+// SplitEscaped splits s at every comma which is not escaped with a backslash. A backslash
+// escapes the following character, so "\\" is a literal backslash.
+func SplitEscaped(s string) []string {
+	var (
+		items   []string
+		current strings.Builder
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == ',':
+			items = append(items, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if escaped {
+		current.WriteRune('\\')
+	}
+	return append(items, current.String())
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `,`, `\,`).Replace(s)
+}
+
+func (v *EscapedStringSliceValue) Set(s string) error {
+	items := SplitEscaped(s)
+	if !v.changed {
+		*v.value = items
+		v.changed = true
+	} else {
+		*v.value = append(*v.value, items...)
+	}
+	return nil
+}
+
+func (v *EscapedStringSliceValue) Type() string { return "escapedStringSlice" }
+
+func (v *EscapedStringSliceValue) String() string {
+	return "[" + strings.Join(v.GetSlice(), ",") + "]"
+}
+
+// Append adds a value without splitting it.
+func (v *EscapedStringSliceValue) Append(s string) error {
+	*v.value = append(*v.value, s)
+	return nil
+}
+
+// Replace replaces all values without splitting them.
+func (v *EscapedStringSliceValue) Replace(items []string) error {
+	*v.value = append([]string{}, items...)
+	return nil
+}
+
+// GetSlice returns the escaped values.
+func (v *EscapedStringSliceValue) GetSlice() []string {
+	items := make([]string, len(*v.value))
+	for i, item := range *v.value {
+		items[i] = escape(item)
+	}
+	return items
+}
+
+// GetEscapedStringSlice returns the value of a flag defined with EscapedStringSlice.
+func GetEscapedStringSlice(flags *pflag.FlagSet, name string) ([]string, error) {
+	f := flags.Lookup(name)
+	if f == nil {
+		return nil, errors.Errorf("flag accessed but not defined: %s", name)
+	}
+	v, ok := f.Value.(*EscapedStringSliceValue)
+	if !ok {
+		return nil, errors.Errorf("trying to get escapedStringSlice value of flag of type %s", f.Value.Type())
+	}
+	return *v.value, nil
+}
+
+// MustGetEscapedStringSlice returns a []string flag or fatals if an error occurs.
+func MustGetEscapedStringSlice(cmd *cobra.Command, name string) []string {
+	v, err := GetEscapedStringSlice(cmd.Flags(), name)
+	if err != nil {
+		cmdx.Fatalf(err.Error())
+	}
+	return v
+}
+
+// Duration defines a time.Duration flag which accepts days and weeks. See DurationValue.
+func Duration(flags *pflag.FlagSet, name string, value time.Duration, usage string) *time.Duration {
+	return DurationP(flags, name, "", value, usage)
+}
+
+// DurationP is like Duration, but accepts a shorthand letter.
+func DurationP(flags *pflag.FlagSet, name, shorthand string, value time.Duration, usage string) *time.Duration {
+	p := new(time.Duration)
+	*p = value
+	flags.VarP((*DurationValue)(p), name, shorthand, usage)
+	return p
+}
+
+var durationUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseDuration parses a duration like time.ParseDuration, but also accepts the units "d" (24
+// hours) and "w" (7 days) before the other units, for example "1w2d", "1d12h", or "-1.5d".
+func ParseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, errors.Errorf("invalid duration %q", s)
+	}
+
+	rest := s
+	sign := time.Duration(1)
+	if strings.HasPrefix(rest, "-") {
+		sign, rest = -1, rest[1:]
+	} else {
+		rest = strings.TrimPrefix(rest, "+")
+	}
+
+	var d time.Duration
+	for rest != "" {
+		i := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) && r != '.' })
+		if i <= 0 {
+			break
+		}
+		j := strings.IndexFunc(rest[i:], func(r rune) bool { return unicode.IsDigit(r) || r == '.' })
+		if j < 0 {
+			j = len(rest) - i
+		}
+		unit, ok := durationUnits[rest[i:i+j]]
+		if !ok {
+			break
+		}
+		n, err := strconv.ParseFloat(rest[:i], 64)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		d += time.Duration(n * float64(unit))
+		rest = rest[i+j:]
+	}
+
+	if rest != "" {
+		remainder, err := time.ParseDuration(rest)
+		if err != nil {
+			return 0, errors.Errorf("invalid duration %q", s)
+		}
+		d += remainder
+	}
+	return sign * d, nil
+}
+
+func (d *DurationValue) Set(s string) error {
+	v, err := ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = DurationValue(v)
+	return nil
+}
+
+// Type is "duration", so that the value can be read with pflag.FlagSet.GetDuration.
+func (d *DurationValue) Type() string { return "duration" }
+
+func (d *DurationValue) String() string { return time.Duration(*d).String() }
+
+// RegisterStringSliceMapCompletion completes the flag with "key=" for each of the keys.
+func RegisterStringSliceMapCompletion(cmd *cobra.Command, name string, keys ...string) error {
+	return cmd.RegisterFlagCompletionFunc(name, func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+		suggestions := make([]string, len(keys))
+		for i, k := range keys {
+			suggestions[i] = k + "="
+		}
+		return suggestions, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// RegisterDurationCompletion completes the flag with examples of durations.
+func RegisterDurationCompletion(cmd *cobra.Command, name string) error {
+	return cmd.RegisterFlagCompletionFunc(name, func(_ *cobra.Command, _ []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if _, err := strconv.ParseFloat(toComplete, 64); err == nil {
+			var suggestions []string
+			for _, unit := range []string{"s", "m", "h", "d", "w"} {
+				suggestions = append(suggestions, toComplete+unit)
+			}
+			return suggestions, cobra.ShellCompDirectiveNoFileComp
+		}
+		return []string{"30s", "5m", "1h", "1d", "1w"}, cobra.ShellCompDirectiveNoFileComp
+	})
+}
+
+// RegisterNoFileCompletion disables the completion of file names for the flags, which is the
+// default of shells for flags with values.
+func RegisterNoFileCompletion(cmd *cobra.Command, names ...string) error {
+	for _, name := range names {
+		if err := cmd.RegisterFlagCompletionFunc(name, cobra.NoFileCompletions); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
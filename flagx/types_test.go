@@ -0,0 +1,108 @@
+package flagx
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStringSliceMap(t *testing.T) {
+	flags := NewFlagSet("test")
+	StringSliceMapP(flags, "header", "H", map[string][]string{"X-Default": {"1"}}, "")
+	assert.Equal(t, "[X-Default=1]", flags.Lookup("header").DefValue)
+
+	require.NoError(t, flags.Parse([]string{"-H", "Accept=text/html", "--header", "Accept=application/json", "-H", "X-Query=a=b,c"}))
+	v, err := GetStringSliceMap(flags, "header")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{
+		"Accept":  {"text/html", "application/json"},
+		"X-Query": {"a=b,c"},
+	}, v)
+
+	assert.Error(t, NewFlagSet("test").Parse([]string{"--header", "no-value"}))
+	assert.Error(t, flags.Set("header", "=value"))
+
+	_, err = GetStringSliceMap(flags, "unknown")
+	assert.Error(t, err)
+}
+
+func TestEscapedStringSlice(t *testing.T) {
+	for in, expected := range map[string][]string{
+		`a,b`:      {"a", "b"},
+		`a\,b,c`:   {"a,b", "c"},
+		`a\\,b`:    {`a\`, "b"},
+		`a,,b`:     {"a", "", "b"},
+		`trail\`:   {`trail\`},
+		`x\y,z`:    {"xy", "z"},
+		``:         {""},
+		`one`:      {"one"},
+		`\,\,\,`:   {",,,"},
+		`a\\\,b`:   {`a\,b`},
+		`,leading`: {"", "leading"},
+	} {
+		assert.Equal(t, expected, SplitEscaped(in), in)
+	}
+
+	flags := NewFlagSet("test")
+	EscapedStringSlice(flags, "scope", []string{"default"}, "")
+	require.NoError(t, flags.Parse([]string{"--scope", `a\,b,c`, "--scope", "d"}))
+	v, err := GetEscapedStringSlice(flags, "scope")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a,b", "c", "d"}, v)
+	assert.Equal(t, `[a\,b,c,d]`, flags.Lookup("scope").Value.String())
+}
+
+func TestDuration(t *testing.T) {
+	for in, expected := range map[string]time.Duration{
+		"1d":     24 * time.Hour,
+		"1d2h":   26 * time.Hour,
+		"2w":     14 * 24 * time.Hour,
+		"1w1d1m": 8*24*time.Hour + time.Minute,
+		"1.5d":   36 * time.Hour,
+		"-1d12h": -36 * time.Hour,
+		"90m":    90 * time.Minute,
+		"1h30m":  90 * time.Minute,
+		"0":      0,
+		"500ms":  500 * time.Millisecond,
+	} {
+		d, err := ParseDuration(in)
+		require.NoError(t, err, in)
+		assert.Equal(t, expected, d, in)
+	}
+
+	for _, in := range []string{"", "d", "1x", "2h1d", "1d1d1"} {
+		_, err := ParseDuration(in)
+		assert.Error(t, err, in)
+	}
+
+	cmd := &cobra.Command{Use: "test"}
+	DurationP(cmd.Flags(), "ttl", "t", time.Hour, "")
+	require.NoError(t, cmd.Flags().Parse([]string{"--ttl", "1d2h"}))
+	assert.Equal(t, 26*time.Hour, MustGetDuration(cmd, "ttl"), "compatible with GetDuration")
+}
+
+func TestCompletion(t *testing.T) {
+	cmd := &cobra.Command{Use: "test", Run: func(*cobra.Command, []string) {}}
+	root := &cobra.Command{Use: "root"}
+	root.AddCommand(cmd)
+
+	StringSliceMap(cmd.Flags(), "header", nil, "")
+	Duration(cmd.Flags(), "ttl", 0, "")
+	require.NoError(t, RegisterStringSliceMapCompletion(cmd, "header", "Accept", "Authorization"))
+	require.NoError(t, RegisterDurationCompletion(cmd, "ttl"))
+
+	complete := func(args ...string) string {
+		out := new(strings.Builder)
+		root.SetOut(out)
+		root.SetArgs(append([]string{cobra.ShellCompRequestCmd, "test"}, args...))
+		require.NoError(t, root.Execute())
+		return out.String()
+	}
+
+	assert.Equal(t, "Accept=\nAuthorization=\n:6\n", complete("--header", ""))
+	assert.Contains(t, complete("--ttl", "3"), "3d\n3w\n:4\n")
+}
@@ -25,16 +25,38 @@ package healthx
 type swaggerHealthStatus struct {
 	// Status always contains "ok".
 	Status string `json:"status"`
+
+	// Probes lists the results of the readiness probes.
+	Probes []ProbeResult `json:"probes,omitempty"`
 }
 
 // swagger:model healthNotReadyStatus
 type swaggerNotReadyStatus struct {
 	// Errors contains a list of errors that caused the not ready status.
 	Errors map[string]string `json:"errors"`
+
+	// Probes lists the results of the readiness probes.
+	Probes []ProbeResult `json:"probes,omitempty"`
 }
 
 // swagger:model version
 type swaggerVersion struct {
 	// Version is the service's version.
 	Version string `json:"version"`
+
+	// GitHash is the git commit the service was built from.
+	GitHash string `json:"git_hash,omitempty"`
+
+	// BuildTime is when the service was built.
+	BuildTime string `json:"build_time,omitempty"`
+
+	// GoVersion is the Go version the service was built with.
+	GoVersion string `json:"go_version,omitempty"`
+
+	// SchemaVersion is the version of the database schema, for example the latest applied
+	// migration.
+	SchemaVersion string `json:"schema_version,omitempty"`
+
+	// Dependencies are the versions of selected dependencies.
+	Dependencies map[string]string `json:"dependencies,omitempty"`
 }
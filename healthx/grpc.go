@@ -0,0 +1,113 @@
+package healthx
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCWatchInterval is how often GRPCHealthServer.Watch re-evaluates the health status.
+const DefaultGRPCWatchInterval = 5 * time.Second
+
+// GRPCHealthServer implements the gRPC health checking protocol (grpc.health.v1) using the ready
+// checks, probes, and watchdog of a Handler, so that the instance reports the same health through
+// HTTP and gRPC.
+//
+// The empty service name reports the overall readiness. The name of a ready checker or probe
+// reports the status of that check only. All other names are unknown.
+type GRPCHealthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+
+	h *Handler
+
+	// WatchInterval is how often Watch re-evaluates the health status. Defaults to
+	// DefaultGRPCWatchInterval.
+	WatchInterval time.Duration
+}
+
+var _ grpc_health_v1.HealthServer = (*GRPCHealthServer)(nil)
+
+// NewGRPCHealthServer returns a gRPC health server backed by the handler. Register it with
+// grpc_health_v1.RegisterHealthServer.
+func NewGRPCHealthServer(h *Handler) *GRPCHealthServer {
+	return &GRPCHealthServer{h: h, WatchInterval: DefaultGRPCWatchInterval}
+}
+
+func (s *GRPCHealthServer) status(ctx context.Context, service string) (grpc_health_v1.HealthCheckResponse_ServingStatus, error) {
+	if service != "" && !s.knows(service) {
+		return grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN, nil
+	}
+	if s.h.IsDraining() {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+	}
+
+	r, err := http.NewRequestWithContext(ctx, "GET", ReadyCheckPath, nil)
+	if err != nil {
+		return grpc_health_v1.HealthCheckResponse_UNKNOWN, err
+	}
+	errs, _ := s.h.readiness(r, false)
+	if _, failed := errs[service]; failed || (service == "" && len(errs) > 0) {
+		return grpc_health_v1.HealthCheckResponse_NOT_SERVING, nil
+	}
+	return grpc_health_v1.HealthCheckResponse_SERVING, nil
+}
+
+func (s *GRPCHealthServer) knows(service string) bool {
+	if _, ok := s.h.ReadyChecks[service]; ok {
+		return true
+	}
+	for _, p := range s.h.Probes {
+		if p.Name == service {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns the current health status of the service.
+func (s *GRPCHealthServer) Check(ctx context.Context, req *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	st, err := s.status(ctx, req.GetService())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if st == grpc_health_v1.HealthCheckResponse_SERVICE_UNKNOWN {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", req.GetService())
+	}
+	return &grpc_health_v1.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch sends the health status of the service, and again every time it changes, until the
+// client cancels the stream.
+func (s *GRPCHealthServer) Watch(req *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	interval := s.WatchInterval
+	if interval <= 0 {
+		interval = DefaultGRPCWatchInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := stream.Context()
+	last := grpc_health_v1.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, err := s.status(ctx, req.GetService())
+		if err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+		if st != last {
+			if err := stream.Send(&grpc_health_v1.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.Error(codes.Canceled, "the stream was canceled")
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package healthx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+
+	"github.com/ory/herodot"
+)
+
+type watchStream struct {
+	grpc.ServerStream
+	ctx  context.Context
+	sent chan grpc_health_v1.HealthCheckResponse_ServingStatus
+}
+
+func (s *watchStream) Context() context.Context { return s.ctx }
+
+func (s *watchStream) Send(res *grpc_health_v1.HealthCheckResponse) error {
+	s.sent <- res.Status
+	return nil
+}
+
+func TestGRPCHealthServer(t *testing.T) {
+	var failing int32
+	handler := NewHandler(herodot.NewJSONWriter(nil), "", ReadyCheckers{
+		"database": func(*http.Request) error { return nil },
+	}, WithProbes(Probe{Name: "upstream", Check: func(context.Context) error {
+		if atomic.LoadInt32(&failing) == 1 {
+			return errors.New("upstream is down")
+		}
+		return nil
+	}}))
+	s := NewGRPCHealthServer(handler)
+	s.WatchInterval = 10 * time.Millisecond
+
+	check := func(t *testing.T, service string) grpc_health_v1.HealthCheckResponse_ServingStatus {
+		res, err := s.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		return res.Status
+	}
+
+	t.Run("case=check", func(t *testing.T) {
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, ""))
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, "database"))
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, "upstream"))
+
+		atomic.StoreInt32(&failing, 1)
+		defer atomic.StoreInt32(&failing, 0)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, check(t, ""))
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, check(t, "database"))
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, check(t, "upstream"))
+
+		_, err := s.Check(context.Background(), &grpc_health_v1.HealthCheckRequest{Service: "unknown"})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("case=watch", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := &watchStream{ctx: ctx, sent: make(chan grpc_health_v1.HealthCheckResponse_ServingStatus, 10)}
+		done := make(chan error)
+		go func() {
+			done <- s.Watch(&grpc_health_v1.HealthCheckRequest{Service: "upstream"}, stream)
+		}()
+
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, <-stream.sent)
+		atomic.StoreInt32(&failing, 1)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, <-stream.sent)
+		atomic.StoreInt32(&failing, 0)
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_SERVING, <-stream.sent)
+
+		cancel()
+		assert.Equal(t, codes.Canceled, status.Code(<-done))
+	})
+
+	t.Run("case=draining", func(t *testing.T) {
+		handler.Drain()
+		assert.Equal(t, grpc_health_v1.HealthCheckResponse_NOT_SERVING, check(t, ""))
+	})
+}
@@ -21,7 +21,10 @@
 package healthx
 
 import (
+	"context"
 	"net/http"
+	"sync/atomic"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 
@@ -33,6 +36,8 @@ const (
 	AliveCheckPath = "/health/alive"
 	// ReadyCheckPath is the path where information about the rady state of the instance is provided.
 	ReadyCheckPath = "/health/ready"
+	// StartupCheckPath is the path where information about the startup state of the instance is provided.
+	StartupCheckPath = "/health/startup"
 	// VersionPath is the path where information about the software version of the instance is provided.
 	VersionPath = "/version"
 )
@@ -42,6 +47,7 @@ func RoutesToObserve() []string {
 	return []string{
 		AliveCheckPath,
 		ReadyCheckPath,
+		StartupCheckPath,
 		VersionPath,
 	}
 }
@@ -62,6 +68,28 @@ type Handler struct {
 	H             herodot.Writer
 	VersionString string
 	ReadyChecks   ReadyCheckers
+
+	// Probes are readiness probes which run in parallel and are listed in the response.
+	Probes []Probe
+
+	// ProbeCacheTTL, if set, caches the results of the probes.
+	ProbeCacheTTL time.Duration
+
+	// Watchdog, if set, reports background workers which missed their deadline.
+	Watchdog *Watchdog
+
+	// BuildInfo is included in the version response.
+	BuildInfo BuildInfo
+
+	// SchemaVersion, if set, returns the schema version included in the version response.
+	SchemaVersion func(ctx context.Context) (string, error)
+
+	// Dependencies are the Go modules whose versions are included in the version response.
+	Dependencies []string
+
+	probeCache probeCache
+	started    int32
+	draining   int32
 }
 
 // NewHandler instantiates a handler.
@@ -69,18 +97,24 @@ func NewHandler(
 	h herodot.Writer,
 	version string,
 	readyChecks ReadyCheckers,
+	opts ...HandlerOption,
 ) *Handler {
-	return &Handler{
+	handler := &Handler{
 		H:             h,
 		VersionString: version,
 		ReadyChecks:   readyChecks,
 	}
+	for _, o := range opts {
+		o(handler)
+	}
+	return handler
 }
 
 // SetHealthRoutes registers this handler's routes for health checking.
 func (h *Handler) SetHealthRoutes(r *httprouter.Router, shareErrors bool) {
 	r.GET(AliveCheckPath, h.Alive)
 	r.GET(ReadyCheckPath, h.Ready(shareErrors))
+	r.GET(StartupCheckPath, h.Startup(shareErrors))
 }
 
 // SetHealthRoutes registers this handler's routes for health checking.
@@ -96,6 +130,7 @@ func (h *Handler) SetVersionRoutes(r *httprouter.Router) {
 //
 // This endpoint returns a 200 status code when the HTTP server is up running.
 // This status does currently not include checks whether the database connection is working.
+// It returns a 503 status code if a critical background worker stopped checking in.
 //
 // If the service supports TLS Edge Termination, this endpoint does not require the
 // `X-Forwarded-Proto` header to be set.
@@ -109,7 +144,15 @@ func (h *Handler) SetVersionRoutes(r *httprouter.Router) {
 //     Responses:
 //       200: healthStatus
 //       500: genericError
+//       503: healthNotReadyStatus
 func (h *Handler) Alive(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	if h.Watchdog != nil {
+		if missing := h.Watchdog.Missing(true); len(missing) > 0 {
+			h.H.WriteCode(rw, r, http.StatusServiceUnavailable, swaggerNotReadyStatus{Errors: missing})
+			return
+		}
+	}
+
 	h.H.Write(rw, r, &swaggerHealthStatus{
 		Status: "ok",
 	})
@@ -138,38 +181,135 @@ func (h *Handler) Alive(rw http.ResponseWriter, r *http.Request, _ httprouter.Pa
 //       503: healthNotReadyStatus
 func (h *Handler) Ready(shareErrors bool) httprouter.Handle {
 	return func(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-		var notReady = swaggerNotReadyStatus{
-			Errors: map[string]string{},
-		}
-
-		for n, c := range h.ReadyChecks {
-			if err := c(r); err != nil {
-				if shareErrors {
-					notReady.Errors[n] = err.Error()
-				} else {
-					notReady.Errors[n] = "error may contain sensitive information and was obfuscated"
-				}
-			}
+		if h.IsDraining() {
+			h.H.WriteCode(rw, r, http.StatusServiceUnavailable, swaggerNotReadyStatus{
+				Errors: map[string]string{"draining": "the instance is shutting down"},
+			})
+			return
 		}
 
-		if len(notReady.Errors) > 0 {
-			h.H.WriteCode(rw, r, http.StatusServiceUnavailable, notReady)
+		errs, probes := h.readiness(r, shareErrors)
+		if len(errs) > 0 {
+			h.H.WriteCode(rw, r, http.StatusServiceUnavailable, swaggerNotReadyStatus{
+				Errors: errs,
+				Probes: probes,
+			})
 			return
 		}
 
+		atomic.StoreInt32(&h.started, 1)
 		h.H.Write(rw, r, &swaggerHealthStatus{
 			Status: "ok",
+			Probes: probes,
 		})
 	}
 }
 
+// readiness runs the ready checks and probes, and collects the workers which missed their
+// deadline. It returns the errors keyed by their name, and the probe results.
+func (h *Handler) readiness(r *http.Request, shareErrors bool) (map[string]string, []ProbeResult) {
+	errs := map[string]string{}
+	obfuscate := func(err error) string {
+		if shareErrors {
+			return err.Error()
+		}
+		return "error may contain sensitive information and was obfuscated"
+	}
+
+	for n, c := range h.ReadyChecks {
+		if err := c(r); err != nil {
+			errs[n] = obfuscate(err)
+		}
+	}
+
+	if h.Watchdog != nil {
+		for n, err := range h.Watchdog.Missing(false) {
+			errs[n] = err
+		}
+	}
+
+	results := h.runProbes(r.Context())
+	probes := make([]ProbeResult, len(results))
+	for k, result := range results {
+		if result.err != nil {
+			errs[result.Name] = obfuscate(result.err)
+			result.Error = errs[result.Name]
+		}
+		probes[k] = result
+	}
+	return errs, probes
+}
+
+// Startup returns an ok status once the instance has started, which is either when MarkStarted
+// was called or when the instance was ready for the first time. Unlike readiness, the startup
+// status never changes back, which makes it suitable for Kubernetes startup probes.
+//
+// swagger:route GET /health/startup health isInstanceStarted
+//
+// Check startup status
+//
+// This endpoint returns a 200 status code once the service has started, and a 503 status code
+// while it is still starting, for example while migrations are being applied.
+//
+// If the service supports TLS Edge Termination, this endpoint does not require the
+// `X-Forwarded-Proto` header to be set.
+//
+//     Produces:
+//     - application/json
+//
+//     Responses:
+//       200: healthStatus
+//       503: healthNotReadyStatus
+func (h *Handler) Startup(shareErrors bool) httprouter.Handle {
+	ready := h.Ready(shareErrors)
+	return func(rw http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if atomic.LoadInt32(&h.started) == 1 {
+			h.H.Write(rw, r, &swaggerHealthStatus{
+				Status: "ok",
+			})
+			return
+		}
+		ready(rw, r, ps)
+	}
+}
+
+// MarkStarted marks the instance as started, for example after the initialization completed.
+func (h *Handler) MarkStarted() {
+	atomic.StoreInt32(&h.started, 1)
+}
+
+// Drain marks the instance as shutting down. The readiness endpoint then returns 503 so that
+// load balancers stop sending requests, while the liveness endpoint keeps returning 200 so that
+// the instance is not restarted.
+func (h *Handler) Drain() {
+	atomic.StoreInt32(&h.draining, 1)
+}
+
+// IsDraining returns true if Drain was called.
+func (h *Handler) IsDraining() bool {
+	return atomic.LoadInt32(&h.draining) == 1
+}
+
+// DrainAndWait calls Drain and waits for delay, which should be long enough for load balancers
+// to notice that the instance is no longer ready, before the server is shut down.
+func (h *Handler) DrainAndWait(ctx context.Context, delay time.Duration) error {
+	h.Drain()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
 // Version returns this service's versions.
 //
 // swagger:route GET /version version getVersion
 //
 // Get service version
 //
-// This endpoint returns the service version typically notated using semantic versioning.
+// This endpoint returns the service version typically notated using semantic versioning, together
+// with the build metadata, the Go version, and, if available, the schema version.
 //
 // If the service supports TLS Edge Termination, this endpoint does not require the
 // `X-Forwarded-Proto` header to be set.
@@ -183,7 +323,5 @@ func (h *Handler) Ready(shareErrors bool) httprouter.Handle {
 //	   Responses:
 // 			200: version
 func (h *Handler) Version(rw http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	h.H.Write(rw, r, &swaggerVersion{
-		Version: h.VersionString,
-	})
+	h.H.Write(rw, r, h.version(r.Context()))
 }
@@ -21,6 +21,7 @@
 package healthx
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
@@ -28,6 +29,7 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/stretchr/testify/assert"
@@ -82,3 +84,46 @@ func TestHealth(t *testing.T) {
 	require.NoError(t, json.NewDecoder(response.Body).Decode(&versionBody))
 	require.EqualValues(t, versionBody.Version, handler.VersionString)
 }
+
+func TestStartupAndDraining(t *testing.T) {
+	ready := errors.New("not ready")
+	handler := NewHandler(herodot.NewJSONWriter(nil), "", ReadyCheckers{
+		"test": func(r *http.Request) error {
+			return ready
+		},
+	})
+	router := httprouter.New()
+	handler.SetHealthRoutes(router, true)
+
+	code := func(path string) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusServiceUnavailable, code(StartupCheckPath))
+	assert.Equal(t, http.StatusOK, code(AliveCheckPath))
+
+	ready = nil
+	assert.Equal(t, http.StatusOK, code(StartupCheckPath))
+	ready = errors.New("not ready")
+	assert.Equal(t, http.StatusOK, code(StartupCheckPath), "the startup status never changes back")
+	assert.Equal(t, http.StatusServiceUnavailable, code(ReadyCheckPath))
+	ready = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	require.ErrorIs(t, handler.DrainAndWait(ctx, time.Hour), context.Canceled)
+	assert.True(t, handler.IsDraining())
+	assert.Equal(t, http.StatusServiceUnavailable, code(ReadyCheckPath))
+	assert.Equal(t, http.StatusOK, code(AliveCheckPath))
+	assert.Equal(t, http.StatusOK, code(StartupCheckPath))
+
+	started := NewHandler(herodot.NewJSONWriter(nil), "", ReadyCheckers{
+		"test": func(r *http.Request) error { return errors.New("not ready") },
+	})
+	started.MarkStarted()
+	w := httptest.NewRecorder()
+	started.Startup(true)(w, httptest.NewRequest("GET", StartupCheckPath, nil), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
@@ -0,0 +1,205 @@
+package healthx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// ProbeStatusOK is the status of a probe which succeeded.
+	ProbeStatusOK = "ok"
+	// ProbeStatusError is the status of a probe which failed.
+	ProbeStatusError = "error"
+	// ProbeStatusTimeout is the status of a probe which did not finish within its timeout.
+	ProbeStatusTimeout = "timeout"
+
+	// DefaultProbeTimeout is the timeout of probes which do not set one.
+	DefaultProbeTimeout = 5 * time.Second
+)
+
+type (
+	// Probe is a named readiness check of a dependency, for example the database, the
+	// migrations, or an upstream service.
+	Probe struct {
+		// Name identifies the probe in the health response.
+		Name string
+
+		// Check returns an error if the dependency is not ready. It must respect the context,
+		// which is canceled once the timeout is reached.
+		Check func(ctx context.Context) error
+
+		// Timeout is how long the check may take. Defaults to DefaultProbeTimeout.
+		Timeout time.Duration
+	}
+
+	// ProbeResult is the outcome of a probe.
+	//
+	// swagger:model healthProbeResult
+	ProbeResult struct {
+		// Name of the probe.
+		Name string `json:"name"`
+
+		// Status is one of "ok", "error", or "timeout".
+		Status string `json:"status"`
+
+		// LatencyMilliseconds is how long the probe took.
+		LatencyMilliseconds float64 `json:"latency_ms"`
+
+		// Error is the reason the probe failed.
+		Error string `json:"error,omitempty"`
+
+		err error
+	}
+
+	// HandlerOption configures a Handler.
+	HandlerOption func(*Handler)
+
+	probeCache struct {
+		sync.Mutex
+		results []ProbeResult
+		expires time.Time
+		// running is the run whose results will be cached, if the probes are running.
+		running *probeRun
+	}
+
+	// probeRun is a run of all probes. The results may be read once done is closed.
+	probeRun struct {
+		done    chan struct{}
+		results []ProbeResult
+	}
+
+	// detachedContext keeps the values of its parent, for example the tracing span, but is not
+	// canceled with it.
+	detachedContext struct {
+		context.Context
+	}
+)
+
+// WithProbes adds readiness probes. They run in parallel and are listed in the response of the
+// readiness endpoint.
+func WithProbes(probes ...Probe) HandlerOption {
+	return func(h *Handler) {
+		h.Probes = append(h.Probes, probes...)
+	}
+}
+
+// WithProbeCacheTTL caches the probe results for ttl, so that frequent readiness requests do
+// not put load on the dependencies.
+func WithProbeCacheTTL(ttl time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.ProbeCacheTTL = ttl
+	}
+}
+
+func runProbe(ctx context.Context, p Probe) ProbeResult {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Check(ctx)
+	}()
+
+	result := ProbeResult{Name: p.Name, Status: ProbeStatusOK}
+	select {
+	case result.err = <-done:
+		if result.err != nil {
+			result.Status = ProbeStatusError
+		}
+	case <-ctx.Done():
+		// The check does not respect the context, do not wait for it any longer.
+		result.err = ctx.Err()
+		result.Status = ProbeStatusTimeout
+	}
+	if result.err != nil && ctx.Err() == context.DeadlineExceeded {
+		result.Status = ProbeStatusTimeout
+	}
+	result.LatencyMilliseconds = float64(time.Since(start).Microseconds()) / 1000
+	return result
+}
+
+// runProbes runs all probes in parallel, or returns the cached results. The probes run with a
+// context which is not canceled with the request, so that a client going away does not fail
+// and cache the results of the probes. Concurrent requests share the run of the probes if the
+// results are cached.
+func (h *Handler) runProbes(ctx context.Context) []ProbeResult {
+	if len(h.Probes) == 0 {
+		return nil
+	}
+
+	var run *probeRun
+	if h.ProbeCacheTTL > 0 {
+		h.probeCache.Lock()
+		if time.Now().Before(h.probeCache.expires) {
+			results := h.probeCache.results
+			h.probeCache.Unlock()
+			return results
+		}
+		if h.probeCache.running == nil {
+			h.probeCache.running = h.startProbes(ctx)
+		}
+		run = h.probeCache.running
+		h.probeCache.Unlock()
+	} else {
+		run = h.startProbes(ctx)
+	}
+
+	select {
+	case <-run.done:
+		return run.results
+	case <-ctx.Done():
+		results := make([]ProbeResult, len(h.Probes))
+		for k, p := range h.Probes {
+			results[k] = ProbeResult{Name: p.Name, Status: ProbeStatusError, err: ctx.Err()}
+		}
+		return results
+	}
+}
+
+// startProbes runs all probes in parallel in the background and caches their results.
+func (h *Handler) startProbes(ctx context.Context) *probeRun {
+	ctx = detachedContext{ctx}
+	run := &probeRun{done: make(chan struct{}), results: make([]ProbeResult, len(h.Probes))}
+	probes := h.Probes
+
+	go func() {
+		defer close(run.done)
+
+		var wg sync.WaitGroup
+		for k := range probes {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				run.results[k] = runProbe(ctx, probes[k])
+			}(k)
+		}
+		wg.Wait()
+
+		if h.ProbeCacheTTL > 0 {
+			h.probeCache.Lock()
+			h.probeCache.results = run.results
+			h.probeCache.expires = time.Now().Add(h.ProbeCacheTTL)
+			h.probeCache.running = nil
+			h.probeCache.Unlock()
+		}
+	}()
+	return run
+}
+
+func (detachedContext) Deadline() (time.Time, bool) {
+	return time.Time{}, false
+}
+
+func (detachedContext) Done() <-chan struct{} {
+	return nil
+}
+
+func (detachedContext) Err() error {
+	return nil
+}
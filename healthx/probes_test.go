@@ -0,0 +1,137 @@
+package healthx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestProbes(t *testing.T) {
+	var calls int32
+	failing := int32(1)
+
+	h := NewHandler(herodot.NewJSONWriter(nil), "", nil,
+		WithProbes(
+			Probe{Name: "db", Check: func(ctx context.Context) error {
+				atomic.AddInt32(&calls, 1)
+				if atomic.LoadInt32(&failing) == 1 {
+					return errors.New("connection refused")
+				}
+				return nil
+			}},
+			Probe{Name: "upstream", Timeout: 20 * time.Millisecond, Check: func(ctx context.Context) error {
+				<-ctx.Done()
+				return ctx.Err()
+			}},
+			Probe{Name: "migrations", Check: func(ctx context.Context) error { return nil }},
+		),
+		WithProbeCacheTTL(time.Hour),
+	)
+
+	router := httprouter.New()
+	h.SetHealthRoutes(router, true)
+
+	get := func(t *testing.T, v interface{}) int {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", ReadyCheckPath, nil))
+		require.NoError(t, json.NewDecoder(w.Body).Decode(v))
+		return w.Code
+	}
+
+	start := time.Now()
+	var notReady swaggerNotReadyStatus
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, &notReady))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "probes must run with their timeouts")
+
+	assert.Equal(t, map[string]string{"db": "connection refused", "upstream": "context deadline exceeded"}, notReady.Errors)
+	require.Len(t, notReady.Probes, 3)
+	byName := map[string]ProbeResult{}
+	for _, p := range notReady.Probes {
+		byName[p.Name] = p
+	}
+	assert.Equal(t, ProbeStatusError, byName["db"].Status)
+	assert.Equal(t, "connection refused", byName["db"].Error)
+	assert.Equal(t, ProbeStatusTimeout, byName["upstream"].Status)
+	assert.GreaterOrEqual(t, byName["upstream"].LatencyMilliseconds, float64(20))
+	assert.Equal(t, ProbeStatusOK, byName["migrations"].Status)
+	assert.Empty(t, byName["migrations"].Error)
+
+	atomic.StoreInt32(&failing, 0)
+	assert.Equal(t, http.StatusServiceUnavailable, get(t, &notReady))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls), "results are cached")
+
+	h.Probes = h.Probes[:1]
+	h.probeCache.expires = time.Time{}
+	var ready swaggerHealthStatus
+	assert.Equal(t, http.StatusOK, get(t, &ready))
+	assert.Equal(t, "ok", ready.Status)
+	require.Len(t, ready.Probes, 1)
+	assert.Equal(t, ProbeStatusOK, ready.Probes[0].Status)
+}
+
+func TestProbesObfuscateErrors(t *testing.T) {
+	h := NewHandler(herodot.NewJSONWriter(nil), "", nil, WithProbes(Probe{Name: "db", Check: func(ctx context.Context) error {
+		return errors.New("password authentication failed for user admin")
+	}}))
+	router := httprouter.New()
+	h.SetHealthRoutes(router, false)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", ReadyCheckPath, nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.NotContains(t, w.Body.String(), "password")
+}
+
+func TestProbesAreDetachedFromRequests(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	h := NewHandler(herodot.NewJSONWriter(nil), "", nil,
+		WithProbes(Probe{Name: "db", Check: func(ctx context.Context) error {
+			atomic.AddInt32(&calls, 1)
+			select {
+			case <-release:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}}),
+		WithProbeCacheTTL(time.Hour),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results := h.runProbes(ctx)
+	require.Len(t, results, 1)
+	assert.Equal(t, ProbeStatusError, results[0].Status)
+	assert.ErrorIs(t, results[0].err, context.Canceled)
+
+	// the canceled request neither canceled nor cached the run, which concurrent requests share
+	done := make(chan []ProbeResult, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			done <- h.runProbes(context.Background())
+		}()
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		results := <-done
+		require.Len(t, results, 1)
+		assert.Equal(t, ProbeStatusOK, results[0].Status)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+	results = h.runProbes(context.Background())
+	assert.Equal(t, ProbeStatusOK, results[0].Status)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&calls), "results are cached")
+}
@@ -0,0 +1,81 @@
+package healthx
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+)
+
+// BuildInfo describes the build of the running binary. The values are usually set with
+// -ldflags at build time.
+type BuildInfo struct {
+	// GitHash is the git commit the binary was built from.
+	GitHash string
+
+	// BuildTime is when the binary was built.
+	BuildTime string
+}
+
+// WithBuildInfo adds the git commit and the build time to the version response.
+func WithBuildInfo(info BuildInfo) HandlerOption {
+	return func(h *Handler) {
+		h.BuildInfo = info
+	}
+}
+
+// WithSchemaVersion adds the schema version, for example the latest applied migration, to the
+// version response. If the callback fails, the schema version is omitted from the response,
+// so that the version endpoint does not depend on the database.
+func WithSchemaVersion(f func(ctx context.Context) (string, error)) HandlerOption {
+	return func(h *Handler) {
+		h.SchemaVersion = f
+	}
+}
+
+// WithDependencyVersions adds the versions of the given Go modules, as compiled into the
+// binary, to the version response. Modules which are not part of the binary are omitted.
+func WithDependencyVersions(modules ...string) HandlerOption {
+	return func(h *Handler) {
+		h.Dependencies = append(h.Dependencies, modules...)
+	}
+}
+
+func dependencyVersions(modules []string) map[string]string {
+	if len(modules) == 0 {
+		return nil
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+
+	versions := make(map[string]string, len(modules))
+	for _, m := range modules {
+		for _, dep := range info.Deps {
+			if dep.Path != m {
+				continue
+			}
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			versions[m] = dep.Version
+		}
+	}
+	return versions
+}
+
+func (h *Handler) version(ctx context.Context) *swaggerVersion {
+	v := &swaggerVersion{
+		Version:      h.VersionString,
+		GitHash:      h.BuildInfo.GitHash,
+		BuildTime:    h.BuildInfo.BuildTime,
+		GoVersion:    runtime.Version(),
+		Dependencies: dependencyVersions(h.Dependencies),
+	}
+	if h.SchemaVersion != nil {
+		if schema, err := h.SchemaVersion(ctx); err == nil {
+			v.SchemaVersion = schema
+		}
+	}
+	return v
+}
@@ -0,0 +1,57 @@
+package healthx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestVersion(t *testing.T) {
+	get := func(t *testing.T, h *Handler) (v swaggerVersion) {
+		router := httprouter.New()
+		h.SetVersionRoutes(router)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", VersionPath, nil))
+		require.Equal(t, http.StatusOK, w.Code)
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&v))
+		return v
+	}
+
+	t.Run("case=full", func(t *testing.T) {
+		v := get(t, NewHandler(herodot.NewJSONWriter(nil), "v1.2.3", nil,
+			WithBuildInfo(BuildInfo{GitHash: "abc123", BuildTime: "2021-01-01T00:00:00Z"}),
+			WithSchemaVersion(func(context.Context) (string, error) { return "20210101000000", nil }),
+			WithDependencyVersions("github.com/stretchr/testify", "example.com/not-a-dependency"),
+		))
+
+		assert.Equal(t, "v1.2.3", v.Version)
+		assert.Equal(t, "abc123", v.GitHash)
+		assert.Equal(t, "2021-01-01T00:00:00Z", v.BuildTime)
+		assert.Equal(t, runtime.Version(), v.GoVersion)
+		assert.Equal(t, "20210101000000", v.SchemaVersion)
+		assert.NotContains(t, v.Dependencies, "example.com/not-a-dependency")
+		if version, ok := v.Dependencies["github.com/stretchr/testify"]; ok {
+			assert.NotEmpty(t, version)
+		}
+	})
+
+	t.Run("case=schema version fails", func(t *testing.T) {
+		v := get(t, NewHandler(herodot.NewJSONWriter(nil), "v1.2.3", nil,
+			WithSchemaVersion(func(context.Context) (string, error) { return "", errors.New("database is down") }),
+		))
+
+		assert.Equal(t, "v1.2.3", v.Version)
+		assert.Empty(t, v.SchemaVersion)
+		assert.Empty(t, v.GitHash)
+	})
+}
@@ -0,0 +1,100 @@
+package healthx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type (
+	// Watchdog tracks background workers which have to check in regularly. A worker which
+	// misses its deadline degrades the readiness, or the liveness, of the instance.
+	Watchdog struct {
+		sync.RWMutex
+		workers map[string]*Worker
+		now     func() time.Time
+	}
+
+	// Worker is a background worker registered with a Watchdog.
+	Worker struct {
+		w        *Watchdog
+		name     string
+		interval time.Duration
+		liveness bool
+		last     time.Time
+	}
+
+	// WorkerOption configures a Worker.
+	WorkerOption func(*Worker)
+)
+
+// AffectsLiveness makes a missed deadline fail the liveness check instead of the readiness
+// check, so that the instance is restarted. Use it for workers which can not recover.
+func AffectsLiveness() WorkerOption {
+	return func(w *Worker) {
+		w.liveness = true
+	}
+}
+
+// NewWatchdog returns a new watchdog.
+func NewWatchdog() *Watchdog {
+	return &Watchdog{workers: make(map[string]*Worker), now: time.Now}
+}
+
+// WithWatchdog reports workers which missed their deadline in the readiness or liveness
+// response of the handler.
+func WithWatchdog(w *Watchdog) HandlerOption {
+	return func(h *Handler) {
+		h.Watchdog = w
+	}
+}
+
+// Register registers a worker which has to check in at least every interval. The deadline
+// starts with the registration. A worker with the same name is replaced.
+func (wd *Watchdog) Register(name string, interval time.Duration, opts ...WorkerOption) *Worker {
+	w := &Worker{w: wd, name: name, interval: interval}
+	for _, o := range opts {
+		o(w)
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+	w.last = wd.now()
+	wd.workers[name] = w
+	return w
+}
+
+// CheckIn resets the deadline of the worker.
+func (w *Worker) CheckIn() {
+	w.w.Lock()
+	defer w.w.Unlock()
+	w.last = w.w.now()
+}
+
+// Unregister removes the worker, for example because it finished.
+func (w *Worker) Unregister() {
+	w.w.Lock()
+	defer w.w.Unlock()
+	if w.w.workers[w.name] == w {
+		delete(w.w.workers, w.name)
+	}
+}
+
+// Missing returns the errors of the workers which missed their deadline, keyed by
+// "worker:<name>". If liveness is true, only workers affecting the liveness are returned,
+// otherwise only the others.
+func (wd *Watchdog) Missing(liveness bool) map[string]string {
+	wd.RLock()
+	defer wd.RUnlock()
+
+	now := wd.now()
+	missing := map[string]string{}
+	for name, w := range wd.workers {
+		if w.liveness != liveness || now.Sub(w.last) <= w.interval {
+			continue
+		}
+		missing["worker:"+name] = fmt.Sprintf("the background worker did not check in for %s, but must check in every %s",
+			now.Sub(w.last).Round(time.Second), w.interval)
+	}
+	return missing
+}
@@ -0,0 +1,69 @@
+package healthx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestWatchdog(t *testing.T) {
+	now := time.Now()
+	wd := NewWatchdog()
+	wd.now = func() time.Time { return now }
+
+	handler := NewHandler(herodot.NewJSONWriter(nil), "", nil, WithWatchdog(wd))
+	router := httprouter.New()
+	handler.SetHealthRoutes(router, true)
+
+	check := func(t *testing.T, path string, expectedCode int, expectedErrors ...string) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		require.Equal(t, expectedCode, w.Code, "%s", w.Body.String())
+
+		var body swaggerNotReadyStatus
+		require.NoError(t, json.NewDecoder(w.Body).Decode(&body))
+		errs := make([]string, 0, len(body.Errors))
+		for n := range body.Errors {
+			errs = append(errs, n)
+		}
+		assert.ElementsMatch(t, expectedErrors, errs)
+	}
+
+	queue := wd.Register("queue", time.Minute)
+	cleanup := wd.Register("cleanup", time.Hour, AffectsLiveness())
+
+	check(t, ReadyCheckPath, http.StatusOK)
+	check(t, AliveCheckPath, http.StatusOK)
+
+	now = now.Add(2 * time.Minute)
+	check(t, ReadyCheckPath, http.StatusServiceUnavailable, "worker:queue")
+	check(t, AliveCheckPath, http.StatusOK)
+	assert.Equal(t, "the background worker did not check in for 2m0s, but must check in every 1m0s", wd.Missing(false)["worker:queue"])
+
+	queue.CheckIn()
+	check(t, ReadyCheckPath, http.StatusOK)
+
+	now = now.Add(time.Hour)
+	queue.CheckIn()
+	check(t, ReadyCheckPath, http.StatusOK)
+	check(t, AliveCheckPath, http.StatusServiceUnavailable, "worker:cleanup")
+
+	cleanup.Unregister()
+	check(t, AliveCheckPath, http.StatusOK)
+
+	t.Run("case=replaced workers are not unregistered", func(t *testing.T) {
+		old := wd.Register("queue", time.Minute)
+		wd.Register("queue", time.Minute)
+		old.Unregister()
+		now = now.Add(2 * time.Minute)
+		check(t, ReadyCheckPath, http.StatusServiceUnavailable, "worker:queue")
+	})
+}
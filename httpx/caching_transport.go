@@ -0,0 +1,464 @@
+package httpx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// CacheHeader is set on responses served by the caching transport. Its value is "HIT" if the
+// response was served from the cache and "REVALIDATED" if it was served from the cache after
+// the origin confirmed it is still valid.
+const CacheHeader = "X-Httpx-Cache"
+
+// CacheStore is the storage backend of the caching transport. Implementations must be safe for
+// concurrent use.
+type CacheStore interface {
+	// Get returns the value stored at key and whether it exists.
+	Get(key string) ([]byte, bool)
+	// Set stores value at key.
+	Set(key string, value []byte)
+	// Delete removes key from the store.
+	Delete(key string)
+}
+
+type memoryCacheStore struct {
+	sync.RWMutex
+	entries map[string][]byte
+}
+
+// NewMemoryCacheStore returns an in-memory CacheStore.
+func NewMemoryCacheStore() CacheStore {
+	return &memoryCacheStore{entries: map[string][]byte{}}
+}
+
+func (s *memoryCacheStore) Get(key string) ([]byte, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	v, ok := s.entries[key]
+	return v, ok
+}
+
+func (s *memoryCacheStore) Set(key string, value []byte) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries[key] = value
+}
+
+func (s *memoryCacheStore) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+	delete(s.entries, key)
+}
+
+type diskCacheStore struct {
+	dir string
+}
+
+// NewDiskCacheStore returns a CacheStore which stores one file per key in dir, so that cached
+// responses survive restarts. The directory is created if it does not exist. Entries which can
+// not be read or written are treated as missing.
+func NewDiskCacheStore(dir string) (CacheStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &diskCacheStore{dir: dir}, nil
+}
+
+func (s *diskCacheStore) path(key string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%x", sha256.Sum256([]byte(key))))
+}
+
+func (s *diskCacheStore) Get(key string) ([]byte, bool) {
+	v, err := ioutil.ReadFile(s.path(key))
+	if err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+// Set replaces the file atomically, so that concurrent readers never see a partial entry.
+func (s *diskCacheStore) Set(key string, value []byte) {
+	tmp, err := ioutil.TempFile(s.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(value); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), s.path(key))
+}
+
+func (s *diskCacheStore) Delete(key string) {
+	_ = os.Remove(s.path(key))
+}
+
+type cachingTransport struct {
+	rt    http.RoundTripper
+	store CacheStore
+	now   func() time.Time
+}
+
+var _ http.RoundTripper = (*cachingTransport)(nil)
+
+// NewCachingTransport returns a RoundTripper implementing a private HTTP cache as described in
+// RFC 7234. GET responses are stored if they carry explicit freshness information
+// (Cache-Control max-age or Expires) or a validator (ETag or Last-Modified). Fresh responses are
+// served from the store, stale ones are revalidated using conditional requests. Unsafe requests
+// invalidate the cached response of their URL.
+//
+// Because the store may be shared by several callers, responses to requests with an
+// Authorization header are only stored if the response allows it explicitly using the
+// Cache-Control directives public, s-maxage, or must-revalidate (RFC 7234 Section 3.2).
+//
+// Requests with the Cache-Control directive only-if-cached are served from the store even if
+// the response is stale, and with a 504 Gateway Timeout response if none is stored, without
+// contacting the origin.
+//
+// If rt is nil, http.DefaultTransport is used. If store is nil, NewMemoryCacheStore is used.
+func NewCachingTransport(rt http.RoundTripper, store CacheStore) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	if store == nil {
+		store = NewMemoryCacheStore()
+	}
+	return &cachingTransport{rt: rt, store: store, now: time.Now}
+}
+
+type cacheEntry struct {
+	StoredAt time.Time `json:"stored_at"`
+	// Vary contains the request header values selected by the response's Vary header.
+	Vary     map[string]string `json:"vary,omitempty"`
+	Response []byte            `json:"response"`
+}
+
+// CacheKey returns the key of the cached response of the request in the CacheStore, for
+// example to delete a response which turned out to be invalid.
+func CacheKey(r *http.Request) string {
+	return r.URL.String()
+}
+
+func (t *cachingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != "" && r.Method != http.MethodGet {
+		res, err := t.rt.RoundTrip(r)
+		if err == nil && r.Method != http.MethodHead && r.Method != http.MethodOptions && res.StatusCode < 400 {
+			t.store.Delete(CacheKey(r))
+		}
+		return res, err
+	}
+
+	reqCC := parseCacheControl(r.Header)
+	if _, ok := reqCC["no-store"]; ok {
+		return t.rt.RoundTrip(r)
+	}
+
+	_, onlyIfCached := reqCC["only-if-cached"]
+	entry, cached := t.load(r)
+	if !cached {
+		if onlyIfCached {
+			return gatewayTimeout(r), nil
+		}
+		return t.fetch(r)
+	}
+
+	cachedRes, err := entry.response(r)
+	if err != nil {
+		t.store.Delete(CacheKey(r))
+		if onlyIfCached {
+			return gatewayTimeout(r), nil
+		}
+		return t.fetch(r)
+	}
+
+	if onlyIfCached || t.fresh(entry, cachedRes, reqCC) {
+		cachedRes.Header.Set(CacheHeader, "HIT")
+		return cachedRes, nil
+	}
+
+	etag, lastModified := cachedRes.Header.Get("ETag"), cachedRes.Header.Get("Last-Modified")
+	if etag == "" && lastModified == "" {
+		return t.fetch(r)
+	}
+
+	cr := r.Clone(r.Context())
+	if etag != "" {
+		cr.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		cr.Header.Set("If-Modified-Since", lastModified)
+	}
+
+	res, err := t.rt.RoundTrip(cr)
+	if err != nil {
+		if _, ok := parseCacheControl(cachedRes.Header)["must-revalidate"]; !ok && t.withinMaxStale(entry, cachedRes, reqCC) {
+			cachedRes.Header.Set(CacheHeader, "HIT")
+			return cachedRes, nil
+		}
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusNotModified {
+		return t.save(r, res)
+	}
+	_ = res.Body.Close()
+
+	for k, v := range res.Header {
+		cachedRes.Header[k] = v
+	}
+	cachedRes.Header.Del(CacheHeader)
+	if stored, err := t.save(r, cachedRes); err == nil {
+		cachedRes = stored
+	}
+	cachedRes.Header.Set(CacheHeader, "REVALIDATED")
+	return cachedRes, nil
+}
+
+// gatewayTimeout returns the response of only-if-cached requests without a stored response as
+// defined in RFC 7234 Section 5.2.1.7.
+func gatewayTimeout(r *http.Request) *http.Response {
+	return &http.Response{
+		Status:     "504 Gateway Timeout",
+		StatusCode: http.StatusGatewayTimeout,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    r,
+	}
+}
+
+func (t *cachingTransport) fetch(r *http.Request) (*http.Response, error) {
+	res, err := t.rt.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	return t.save(r, res)
+}
+
+func (t *cachingTransport) load(r *http.Request) (*cacheEntry, bool) {
+	raw, ok := t.store.Get(CacheKey(r))
+	if !ok {
+		return nil, false
+	}
+
+	var entry cacheEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return nil, false
+	}
+
+	for k, v := range entry.Vary {
+		if r.Header.Get(k) != v {
+			return nil, false
+		}
+	}
+	return &entry, true
+}
+
+// save stores res in the cache if it is cacheable. It consumes and replaces res.Body.
+func (t *cachingTransport) save(r *http.Request, res *http.Response) (*http.Response, error) {
+	if !cacheable(r, res) {
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	entry := cacheEntry{StoredAt: t.now(), Vary: map[string]string{}}
+	for _, field := range headerTokens(res.Header, "Vary") {
+		entry.Vary[http.CanonicalHeaderKey(field)] = r.Header.Get(field)
+	}
+
+	dump := *res
+	dump.Body = ioutil.NopCloser(bytes.NewReader(body))
+	dump.ContentLength = int64(len(body))
+	dump.TransferEncoding = nil
+	entry.Response, err = httputil.DumpResponse(&dump, true)
+	if err != nil {
+		return res, nil
+	}
+
+	raw, err := json.Marshal(&entry)
+	if err != nil {
+		return res, nil
+	}
+	t.store.Set(CacheKey(r), raw)
+	return res, nil
+}
+
+func (e *cacheEntry) response(r *http.Request) (*http.Response, error) {
+	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(e.Response)), r)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return res, nil
+}
+
+func cacheable(r *http.Request, res *http.Response) bool {
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent,
+		http.StatusMultipleChoices, http.StatusMovedPermanently, http.StatusNotFound,
+		http.StatusMethodNotAllowed, http.StatusGone, http.StatusRequestURITooLong,
+		http.StatusNotImplemented:
+	default:
+		return false
+	}
+
+	if _, ok := parseCacheControl(r.Header)["no-store"]; ok {
+		return false
+	}
+
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-store"]; ok {
+		return false
+	}
+	if r.Header.Get("Authorization") != "" && !sharable(cc) {
+		return false
+	}
+	for _, field := range headerTokens(res.Header, "Vary") {
+		if field == "*" {
+			return false
+		}
+	}
+
+	_, maxAge := cc["max-age"]
+	return maxAge ||
+		res.Header.Get("Expires") != "" ||
+		res.Header.Get("ETag") != "" ||
+		res.Header.Get("Last-Modified") != ""
+}
+
+// sharable reports whether a response to a request with an Authorization header may be stored
+// as defined in RFC 7234 Section 3.2.
+func sharable(cc map[string]string) bool {
+	for _, directive := range []string{"public", "s-maxage", "must-revalidate"} {
+		if _, ok := cc[directive]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// age returns the current age of the cached response as defined in RFC 7234 Section 4.2.3.
+func (t *cachingTransport) age(e *cacheEntry, res *http.Response) time.Duration {
+	age := t.now().Sub(e.StoredAt)
+	if v, err := strconv.ParseInt(res.Header.Get("Age"), 10, 64); err == nil && v > 0 {
+		age += time.Duration(v) * time.Second
+	}
+	return age
+}
+
+// lifetime returns the freshness lifetime of the response as defined in RFC 7234 Section 4.2.1.
+func lifetime(res *http.Response) time.Duration {
+	cc := parseCacheControl(res.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return 0
+	}
+	if v, ok := cc["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		return 0
+	}
+
+	expires, err := http.ParseTime(res.Header.Get("Expires"))
+	if err != nil {
+		return 0
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return 0
+	}
+	return expires.Sub(date)
+}
+
+func (t *cachingTransport) fresh(e *cacheEntry, res *http.Response, reqCC map[string]string) bool {
+	if _, ok := reqCC["no-cache"]; ok {
+		return false
+	}
+
+	lifetime, age := lifetime(res), t.age(e, res)
+	if v, ok := reqCC["max-age"]; ok {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil && time.Duration(seconds)*time.Second < lifetime {
+			lifetime = time.Duration(seconds) * time.Second
+		}
+	}
+	if v, ok := reqCC["min-fresh"]; ok {
+		if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+			age += time.Duration(seconds) * time.Second
+		}
+	}
+
+	return age < lifetime
+}
+
+// withinMaxStale reports whether the request accepts the stale response because of max-stale.
+func (t *cachingTransport) withinMaxStale(e *cacheEntry, res *http.Response, reqCC map[string]string) bool {
+	v, ok := reqCC["max-stale"]
+	if !ok {
+		return false
+	}
+	if v == "" {
+		return true
+	}
+	seconds, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return false
+	}
+	return t.age(e, res)-lifetime(res) <= time.Duration(seconds)*time.Second
+}
+
+func parseCacheControl(h http.Header) map[string]string {
+	cc := map[string]string{}
+	for _, directive := range headerTokens(h, "Cache-Control") {
+		if k, v, ok := cut(directive, "="); ok {
+			cc[strings.ToLower(k)] = strings.Trim(v, `"`)
+		} else {
+			cc[strings.ToLower(directive)] = ""
+		}
+	}
+	return cc
+}
+
+func headerTokens(h http.Header, key string) []string {
+	var tokens []string
+	for _, v := range h.Values(key) {
+		for _, token := range strings.Split(v, ",") {
+			if token = strings.TrimSpace(token); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+func cut(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+len(sep):]), true
+	}
+	return s, "", false
+}
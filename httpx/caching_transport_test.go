@@ -0,0 +1,203 @@
+package httpx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingTransport(t *testing.T) {
+	var calls, notModified int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		switch r.URL.Path {
+		case "/max-age":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("Cache-Control", "no-cache")
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				atomic.AddInt32(&notModified, 1)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		case "/public":
+			w.Header().Set("Cache-Control", "public, max-age=60")
+		case "/no-store":
+			w.Header().Set("Cache-Control", "no-store, max-age=60")
+		case "/vary":
+			w.Header().Set("Cache-Control", "max-age=60")
+			w.Header().Set("Vary", "Accept-Language")
+		}
+		_, _ = w.Write([]byte("hello " + r.Header.Get("Accept-Language")))
+	}))
+	t.Cleanup(ts.Close)
+
+	reset := func() {
+		atomic.StoreInt32(&calls, 0)
+		atomic.StoreInt32(&notModified, 0)
+	}
+
+	get := func(t *testing.T, c *http.Client, path string, header http.Header) (*http.Response, string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		res, err := c.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res, string(body)
+	}
+
+	t.Run("case=serves fresh responses from the cache", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+
+		res, body := get(t, c, "/max-age", nil)
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		assert.Equal(t, "hello ", body)
+
+		res, body = get(t, c, "/max-age", nil)
+		assert.Equal(t, "HIT", res.Header.Get(CacheHeader))
+		assert.Equal(t, "hello ", body)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+		res, _ = get(t, c, "/max-age", http.Header{"Cache-Control": {"no-cache"}})
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=expires stale responses", func(t *testing.T) {
+		reset()
+		ct := NewCachingTransport(nil, nil).(*cachingTransport)
+		now := time.Now()
+		ct.now = func() time.Time { return now }
+		c := &http.Client{Transport: ct}
+
+		get(t, c, "/max-age", nil)
+		now = now.Add(time.Minute)
+		res, _ := get(t, c, "/max-age", nil)
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=revalidates using etags", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+
+		get(t, c, "/etag", nil)
+		res, body := get(t, c, "/etag", nil)
+		assert.Equal(t, "REVALIDATED", res.Header.Get(CacheHeader))
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "hello ", body)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+		assert.EqualValues(t, 1, atomic.LoadInt32(&notModified))
+	})
+
+	t.Run("case=does not store no-store responses", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+
+		get(t, c, "/no-store", nil)
+		get(t, c, "/no-store", nil)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=does not store authorized responses unless they are public", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+		auth := http.Header{"Authorization": {"Bearer alice"}}
+
+		get(t, c, "/max-age", auth)
+		res, _ := get(t, c, "/max-age", http.Header{"Authorization": {"Bearer bob"}})
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		res, _ = get(t, c, "/max-age", nil)
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+
+		get(t, c, "/public", auth)
+		res, _ = get(t, c, "/public", auth)
+		assert.Equal(t, "HIT", res.Header.Get(CacheHeader))
+		assert.EqualValues(t, 4, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=respects vary", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+
+		_, body := get(t, c, "/vary", http.Header{"Accept-Language": {"en"}})
+		assert.Equal(t, "hello en", body)
+		_, body = get(t, c, "/vary", http.Header{"Accept-Language": {"de"}})
+		assert.Equal(t, "hello de", body)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=unsafe requests invalidate the cache", func(t *testing.T) {
+		reset()
+		c := NewResilientClient(ResilientClientWithCache(nil))
+
+		res, err := c.Get(ts.URL + "/max-age")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		res, err = c.Post(ts.URL+"/max-age", "text/plain", nil)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		res, err = c.Get(ts.URL + "/max-age")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		assert.Empty(t, res.Header.Get(CacheHeader))
+		assert.EqualValues(t, 3, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=only-if-cached", func(t *testing.T) {
+		reset()
+		c := &http.Client{Transport: NewCachingTransport(nil, nil)}
+		onlyIfCached := http.Header{"Cache-Control": {"only-if-cached"}}
+
+		res, _ := get(t, c, "/etag", onlyIfCached)
+		assert.Equal(t, http.StatusGatewayTimeout, res.StatusCode)
+		assert.EqualValues(t, 0, atomic.LoadInt32(&calls))
+
+		get(t, c, "/etag", nil)
+		res, body := get(t, c, "/etag", onlyIfCached)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, "HIT", res.Header.Get(CacheHeader), "stale responses are served without revalidation")
+		assert.Equal(t, "hello ", body)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+}
+
+func TestDiskCacheStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDiskCacheStore(dir)
+	require.NoError(t, err)
+
+	_, ok := s.Get("https://example.com/a")
+	assert.False(t, ok)
+
+	s.Set("https://example.com/a", []byte("a"))
+	v, ok := s.Get("https://example.com/a")
+	require.True(t, ok)
+	assert.Equal(t, "a", string(v))
+
+	s, err = NewDiskCacheStore(dir)
+	require.NoError(t, err)
+	v, ok = s.Get("https://example.com/a")
+	require.True(t, ok, "entries survive restarts")
+	assert.Equal(t, "a", string(v))
+
+	s.Delete("https://example.com/a")
+	_, ok = s.Get("https://example.com/a")
+	assert.False(t, ok)
+}
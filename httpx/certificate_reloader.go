@@ -0,0 +1,76 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/watcherx"
+)
+
+// CertificateReloader loads a TLS certificate from files and reloads it whenever the files change.
+type CertificateReloader struct {
+	certFile, keyFile string
+	l                 *logrusx.Logger
+
+	sync.RWMutex
+	cert *tls.Certificate
+}
+
+// NewCertificateReloader loads the PEM encoded certificate and key files and watches them for
+// changes until ctx is canceled. If reloading fails, the previous certificate is kept and the
+// error is logged.
+func NewCertificateReloader(ctx context.Context, certFile, keyFile string, l *logrusx.Logger) (*CertificateReloader, error) {
+	r := &CertificateReloader{certFile: certFile, keyFile: keyFile, l: l}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	for _, file := range []string{certFile, keyFile} {
+		c := make(watcherx.EventChannel)
+		if _, err := watcherx.WatchFile(ctx, file, c); err != nil {
+			return nil, err
+		}
+		go r.watch(c)
+	}
+
+	return r, nil
+}
+
+func (r *CertificateReloader) watch(c watcherx.EventChannel) {
+	for e := range c {
+		switch e.(type) {
+		case *watcherx.ChangeEvent:
+			if err := r.Reload(); err != nil {
+				r.l.WithError(err).WithField("file", e.Source()).Error("Unable to reload the TLS certificate, keeping the previous one.")
+				continue
+			}
+			r.l.WithField("file", e.Source()).Info("Reloaded the TLS certificate.")
+		case *watcherx.ErrorEvent:
+			r.l.WithError(e.(error)).WithField("file", e.Source()).Error("Unable to watch the TLS certificate.")
+		}
+	}
+}
+
+// Reload loads the certificate from the files.
+func (r *CertificateReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "unable to load X509 key pair from files")
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.cert = &cert
+	return nil
+}
+
+// GetCertificate returns the current certificate. It implements tls.Config.GetCertificate.
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.cert, nil
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrConcurrencyLimitExceeded is returned when a request waited longer than the queue timeout
+// for a free slot.
+var ErrConcurrencyLimitExceeded = errors.New("too many concurrent requests to host")
+
+type concurrencyLimitTransport struct {
+	rt           http.RoundTripper
+	maxPerHost   int
+	queueTimeout time.Duration
+
+	sync.Mutex
+	hosts map[string]*hostSemaphore
+}
+
+type hostSemaphore struct {
+	slots chan struct{}
+	refs  int
+}
+
+var _ http.RoundTripper = (*concurrencyLimitTransport)(nil)
+
+// NewConcurrencyLimitTransport returns a RoundTripper which allows at most maxPerHost requests
+// per host to be in flight at the same time. A request is in flight until its response body was
+// closed. Requests exceeding the limit are queued; if no slot becomes free within queueTimeout,
+// the request fails with ErrConcurrencyLimitExceeded. A queueTimeout of zero or less waits until
+// the request's context is done.
+//
+// If rt is nil, http.DefaultTransport is used.
+func NewConcurrencyLimitTransport(rt http.RoundTripper, maxPerHost int, queueTimeout time.Duration) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &concurrencyLimitTransport{rt: rt, maxPerHost: maxPerHost, queueTimeout: queueTimeout, hosts: map[string]*hostSemaphore{}}
+}
+
+func (t *concurrencyLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.maxPerHost <= 0 {
+		return t.rt.RoundTrip(r)
+	}
+
+	host := r.URL.Host
+	release, err := t.acquire(r, host)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := t.rt.RoundTrip(r)
+	if err != nil {
+		release()
+		return nil, err
+	}
+
+	res.Body = &releaseOnClose{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// acquire waits for a free slot for host and returns a function which releases it.
+func (t *concurrencyLimitTransport) acquire(r *http.Request, host string) (func(), error) {
+	t.Lock()
+	s, ok := t.hosts[host]
+	if !ok {
+		s = &hostSemaphore{slots: make(chan struct{}, t.maxPerHost)}
+		t.hosts[host] = s
+	}
+	s.refs++
+	t.Unlock()
+
+	unref := func() {
+		t.Lock()
+		defer t.Unlock()
+		if s.refs--; s.refs == 0 {
+			delete(t.hosts, host)
+		}
+	}
+
+	var timeout <-chan time.Time
+	if t.queueTimeout > 0 {
+		timer := time.NewTimer(t.queueTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case s.slots <- struct{}{}:
+		return func() {
+			<-s.slots
+			unref()
+		}, nil
+	case <-timeout:
+		unref()
+		return nil, errors.Wrapf(ErrConcurrencyLimitExceeded, "host %s", host)
+	case <-r.Context().Done():
+		unref()
+		return nil, errors.WithStack(r.Context().Err())
+	}
+}
+
+type releaseOnClose struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *releaseOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
@@ -0,0 +1,82 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencyLimitTransport(t *testing.T) {
+	var inflight, maxInflight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInflight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInflight, m, n) {
+				break
+			}
+		}
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("case=limits concurrent requests", func(t *testing.T) {
+		atomic.StoreInt32(&maxInflight, 0)
+		c := &http.Client{Transport: NewConcurrencyLimitTransport(nil, 2, 0)}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 6; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := c.Get(ts.URL)
+				if assert.NoError(t, err) {
+					assert.NoError(t, res.Body.Close())
+				}
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 2, atomic.LoadInt32(&maxInflight))
+	})
+
+	t.Run("case=fails after the queue timeout", func(t *testing.T) {
+		c := &http.Client{Transport: NewConcurrencyLimitTransport(nil, 1, 10*time.Millisecond)}
+
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+
+		_, err = c.Get(ts.URL)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrConcurrencyLimitExceeded), "%+v", err)
+
+		require.NoError(t, res.Body.Close())
+		res, err = c.Get(ts.URL)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("case=limits per host", func(t *testing.T) {
+		other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		t.Cleanup(other.Close)
+		tr := NewConcurrencyLimitTransport(nil, 1, 10*time.Millisecond)
+		c := &http.Client{Transport: tr}
+
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		res2, err := c.Get(other.URL)
+		require.NoError(t, err)
+		require.NoError(t, res2.Body.Close())
+		require.NoError(t, res.Body.Close())
+
+		assert.Empty(t, tr.(*concurrencyLimitTransport).hosts)
+	})
+}
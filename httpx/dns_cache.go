@@ -0,0 +1,237 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+// DNSResolver resolves hostnames. *net.Resolver implements this interface.
+type DNSResolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// DNSTTLResolver is a DNSResolver which also reports the TTL of the records it returned. If the
+// resolver of a DNSCache implements it, the record TTL is used instead of the default TTL.
+type DNSTTLResolver interface {
+	DNSResolver
+	LookupIPAddrTTL(ctx context.Context, host string) ([]net.IPAddr, time.Duration, error)
+}
+
+// DNSCache caches DNS lookups, including failed lookups of hosts which do not exist. Concurrent
+// lookups of the same host are collapsed into one.
+type DNSCache struct {
+	resolver    DNSResolver
+	ttl         time.Duration
+	minTTL      time.Duration
+	maxTTL      time.Duration
+	negativeTTL time.Duration
+	maxEntries  int
+	now         func() time.Time
+
+	group   singleflight.Group
+	mu      sync.Mutex
+	entries map[string]*dnsCacheEntry
+}
+
+type dnsCacheEntry struct {
+	addrs   []net.IPAddr
+	err     error
+	expires time.Time
+}
+
+// DNSCacheOption configures a DNSCache.
+type DNSCacheOption func(*DNSCache)
+
+// DNSCacheWithResolver sets the resolver. Defaults to net.DefaultResolver.
+func DNSCacheWithResolver(r DNSResolver) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.resolver = r
+	}
+}
+
+// DNSCacheWithTTL sets the TTL used for successful lookups if the resolver does not report
+// record TTLs, and the bounds record TTLs are clamped to. Defaults to 30 seconds, 1 second,
+// and 5 minutes.
+func DNSCacheWithTTL(ttl, min, max time.Duration) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.ttl = ttl
+		c.minTTL = min
+		c.maxTTL = max
+	}
+}
+
+// DNSCacheWithNegativeTTL sets how long hosts which do not exist are cached. Defaults to 5
+// seconds. Temporary errors such as timeouts are never cached.
+func DNSCacheWithNegativeTTL(ttl time.Duration) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.negativeTTL = ttl
+	}
+}
+
+// DNSCacheWithMaxEntries limits the number of cached hosts. Defaults to 1024.
+func DNSCacheWithMaxEntries(n int) DNSCacheOption {
+	return func(c *DNSCache) {
+		c.maxEntries = n
+	}
+}
+
+// NewDNSCache returns a new DNS cache.
+func NewDNSCache(opts ...DNSCacheOption) *DNSCache {
+	c := &DNSCache{
+		resolver:    net.DefaultResolver,
+		ttl:         30 * time.Second,
+		minTTL:      time.Second,
+		maxTTL:      5 * time.Minute,
+		negativeTTL: 5 * time.Second,
+		maxEntries:  1024,
+		now:         time.Now,
+		entries:     map[string]*dnsCacheEntry{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// LookupIPAddr returns the cached addresses of host, resolving it if the cache entry is missing
+// or expired.
+func (c *DNSCache) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expires) {
+		return e.addrs, e.err
+	}
+
+	ch := c.group.DoChan(host, func() (interface{}, error) {
+		// The lookup is shared between callers, so it must not be canceled by one of them.
+		return c.resolve(context.Background(), host), nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	case res := <-ch:
+		e := res.Val.(*dnsCacheEntry)
+		return e.addrs, e.err
+	}
+}
+
+func (c *DNSCache) resolve(ctx context.Context, host string) *dnsCacheEntry {
+	var (
+		addrs []net.IPAddr
+		ttl   = c.ttl
+		err   error
+	)
+	if r, ok := c.resolver.(DNSTTLResolver); ok {
+		addrs, ttl, err = r.LookupIPAddrTTL(ctx, host)
+	} else {
+		addrs, err = c.resolver.LookupIPAddr(ctx, host)
+	}
+
+	e := &dnsCacheEntry{addrs: addrs, err: err}
+	if err == nil && len(addrs) == 0 {
+		e.err = errors.WithStack(&net.DNSError{Err: "no such host", Name: host, IsNotFound: true})
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case e.err == nil:
+		if ttl < c.minTTL {
+			ttl = c.minTTL
+		} else if ttl > c.maxTTL {
+			ttl = c.maxTTL
+		}
+	case errors.As(e.err, &dnsErr) && dnsErr.IsNotFound:
+		ttl = c.negativeTTL
+	default:
+		return e
+	}
+
+	e.expires = c.now().Add(ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= c.maxEntries {
+		c.evict()
+	}
+	c.entries[host] = e
+	return e
+}
+
+// evict removes expired entries. If none expired, an arbitrary entry is removed.
+func (c *DNSCache) evict() {
+	now := c.now()
+	for host, e := range c.entries {
+		if !now.Before(e.expires) {
+			delete(c.entries, host)
+		}
+	}
+	for host := range c.entries {
+		if len(c.entries) < c.maxEntries {
+			return
+		}
+		delete(c.entries, host)
+	}
+}
+
+// CachingDialer dials using addresses resolved by a DNSCache.
+type CachingDialer struct {
+	dialer *net.Dialer
+	cache  *DNSCache
+}
+
+// NewCachingDialer returns a dialer which resolves hostnames using cache and connects using d.
+// The resolved addresses are tried in order until a connection is established. Because d dials
+// IP addresses only, d.Control sees the address being connected to, so NewSSRFSafeDialer can be
+// used as d. If d is nil, a dialer with the defaults of http.DefaultTransport is used.
+func NewCachingDialer(d *net.Dialer, cache *DNSCache) *CachingDialer {
+	if d == nil {
+		d = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	}
+	return &CachingDialer{dialer: d, cache: cache}
+}
+
+// DialContext connects to the address on the named network. It can be used as
+// http.Transport.DialContext.
+func (d *CachingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return d.dialer.DialContext(ctx, network, address)
+	}
+
+	addrs, err := d.cache.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var firstErr error
+	for _, addr := range addrs {
+		if (network == "tcp4" || network == "udp4") && addr.IP.To4() == nil ||
+			(network == "tcp6" || network == "udp6") && addr.IP.To4() != nil {
+			continue
+		}
+
+		conn, err := d.dialer.DialContext(ctx, network, net.JoinHostPort(addr.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if firstErr == nil {
+		firstErr = errors.WithStack(&net.DNSError{Err: "no suitable address found", Name: host})
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,174 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	calls int32
+	ttl   time.Duration
+	delay time.Duration
+	hosts map[string][]net.IPAddr
+	err   error
+}
+
+func (r *fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	addrs, _, err := r.LookupIPAddrTTL(ctx, host)
+	return addrs, err
+}
+
+func (r *fakeResolver) LookupIPAddrTTL(_ context.Context, host string) ([]net.IPAddr, time.Duration, error) {
+	atomic.AddInt32(&r.calls, 1)
+	time.Sleep(r.delay)
+	if r.err != nil {
+		return nil, 0, r.err
+	}
+	addrs, ok := r.hosts[host]
+	if !ok {
+		return nil, 0, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, r.ttl, nil
+}
+
+func TestDNSCache(t *testing.T) {
+	localhost := []net.IPAddr{{IP: net.IPv4(127, 0, 0, 1)}}
+
+	newCache := func(r DNSResolver, opts ...DNSCacheOption) (*DNSCache, *time.Time) {
+		now := time.Now()
+		c := NewDNSCache(append([]DNSCacheOption{DNSCacheWithResolver(r)}, opts...)...)
+		c.now = func() time.Time { return now }
+		return c, &now
+	}
+
+	t.Run("case=respects the record TTL", func(t *testing.T) {
+		r := &fakeResolver{ttl: time.Minute, hosts: map[string][]net.IPAddr{"example.test": localhost}}
+		c, now := newCache(r)
+
+		for i := 0; i < 3; i++ {
+			addrs, err := c.LookupIPAddr(context.Background(), "example.test")
+			require.NoError(t, err)
+			assert.Equal(t, localhost, addrs)
+		}
+		assert.EqualValues(t, 1, r.calls)
+
+		*now = now.Add(time.Minute)
+		_, err := c.LookupIPAddr(context.Background(), "example.test")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, r.calls)
+	})
+
+	t.Run("case=clamps the record TTL", func(t *testing.T) {
+		r := &fakeResolver{ttl: time.Hour, hosts: map[string][]net.IPAddr{"example.test": localhost}}
+		c, now := newCache(r, DNSCacheWithTTL(time.Second, time.Second, 10*time.Second))
+
+		_, err := c.LookupIPAddr(context.Background(), "example.test")
+		require.NoError(t, err)
+		*now = now.Add(10 * time.Second)
+		_, err = c.LookupIPAddr(context.Background(), "example.test")
+		require.NoError(t, err)
+		assert.EqualValues(t, 2, r.calls)
+	})
+
+	t.Run("case=caches hosts which do not exist", func(t *testing.T) {
+		r := &fakeResolver{hosts: map[string][]net.IPAddr{}}
+		c, now := newCache(r, DNSCacheWithNegativeTTL(time.Second))
+
+		for i := 0; i < 3; i++ {
+			_, err := c.LookupIPAddr(context.Background(), "example.test")
+			var dnsErr *net.DNSError
+			require.True(t, errors.As(err, &dnsErr))
+			assert.True(t, dnsErr.IsNotFound)
+		}
+		assert.EqualValues(t, 1, r.calls)
+
+		*now = now.Add(time.Second)
+		_, err := c.LookupIPAddr(context.Background(), "example.test")
+		require.Error(t, err)
+		assert.EqualValues(t, 2, r.calls)
+	})
+
+	t.Run("case=does not cache temporary errors", func(t *testing.T) {
+		r := &fakeResolver{err: &net.DNSError{Err: "i/o timeout", IsTimeout: true}}
+		c, _ := newCache(r)
+
+		for i := 0; i < 3; i++ {
+			_, err := c.LookupIPAddr(context.Background(), "example.test")
+			require.Error(t, err)
+		}
+		assert.EqualValues(t, 3, r.calls)
+	})
+
+	t.Run("case=collapses concurrent lookups", func(t *testing.T) {
+		r := &fakeResolver{ttl: time.Minute, delay: 50 * time.Millisecond, hosts: map[string][]net.IPAddr{"example.test": localhost}}
+		c, _ := newCache(r)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := c.LookupIPAddr(context.Background(), "example.test")
+				assert.NoError(t, err)
+			}()
+		}
+		wg.Wait()
+		assert.EqualValues(t, 1, r.calls)
+	})
+
+	t.Run("case=evicts entries", func(t *testing.T) {
+		r := &fakeResolver{ttl: time.Minute, hosts: map[string][]net.IPAddr{"a.test": localhost, "b.test": localhost, "c.test": localhost}}
+		c, _ := newCache(r, DNSCacheWithMaxEntries(2))
+
+		for _, host := range []string{"a.test", "b.test", "c.test"} {
+			_, err := c.LookupIPAddr(context.Background(), host)
+			require.NoError(t, err)
+		}
+		assert.Len(t, c.entries, 2)
+	})
+}
+
+func TestCachingDialer(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	r := &fakeResolver{ttl: time.Minute, hosts: map[string][]net.IPAddr{
+		"example.test": {{IP: net.ParseIP("::1")}, {IP: net.IPv4(127, 0, 0, 1)}},
+	}}
+	cache := NewDNSCache(DNSCacheWithResolver(r))
+	target := "http://example.test:" + u.Port()
+
+	t.Run("case=dials the resolved addresses", func(t *testing.T) {
+		c := NewResilientClient(ResilientClientWithDNSCache(cache), ResilientClientWithMaxRetry(0))
+		for i := 0; i < 3; i++ {
+			res, err := c.Get(target)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+			assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		}
+		assert.EqualValues(t, 1, r.calls)
+	})
+
+	t.Run("case=checks the resolved addresses for SSRF", func(t *testing.T) {
+		c := NewResilientClient(ResilientClientWithDNSCache(cache), ResilientClientDisallowInternalIPs(), ResilientClientWithMaxRetry(0))
+		_, err := c.Get(target)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+}
@@ -0,0 +1,193 @@
+package httpx
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IncomingRequest describes an incoming request as seen by the client, which may differ from
+// what the server sees if the request passed through reverse proxies.
+type IncomingRequest struct {
+	// ClientIP is the IP address of the client. It is nil if the address could not be determined.
+	ClientIP net.IP
+
+	// Scheme is the scheme used by the client, either "http" or "https".
+	Scheme string
+
+	// Host is the host (and optional port) the client sent the request to.
+	Host string
+
+	// Forwarded is true if any of the values were taken from forwarding headers.
+	Forwarded bool
+}
+
+// ForwardedParser derives the effective client IP, scheme, and host of incoming requests from
+// the `Forwarded` (RFC 7239) or `X-Forwarded-For`, `X-Forwarded-Proto`, and `X-Forwarded-Host`
+// headers. The headers are only honored if the request was received from a trusted proxy.
+type ForwardedParser struct {
+	trusted []*net.IPNet
+}
+
+// NewForwardedParser returns a parser which trusts forwarding headers set by proxies within the
+// given networks, specified in CIDR notation ("10.0.0.0/8") or as single IP addresses.
+func NewForwardedParser(trustedProxies ...string) (*ForwardedParser, error) {
+	p := &ForwardedParser{trusted: make([]*net.IPNet, 0, len(trustedProxies))}
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, errors.Errorf("unable to parse trusted proxy address %q", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			p.trusted = append(p.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, n, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to parse trusted proxy network %q", proxy)
+		}
+		p.trusted = append(p.trusted, n)
+	}
+	return p, nil
+}
+
+func (p *ForwardedParser) isTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.trusted {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+type forwardedElement struct {
+	ip    net.IP
+	proto string
+	host  string
+}
+
+// Parse returns the client-facing properties of r.
+//
+// The client IP is determined by walking the chain of forwarding proxies from the server
+// towards the client and picking the first address which is not a trusted proxy. The scheme and
+// host are taken from the same hop.
+func (p *ForwardedParser) Parse(r *http.Request) *IncomingRequest {
+	in := &IncomingRequest{
+		ClientIP: parseHostIP(r.RemoteAddr),
+		Scheme:   "http",
+		Host:     r.Host,
+	}
+	if r.TLS != nil {
+		in.Scheme = "https"
+	}
+
+	if !p.isTrusted(in.ClientIP) {
+		return in
+	}
+
+	elements := parseForwarded(r.Header)
+	if len(elements) == 0 {
+		elements = parseXForwarded(r.Header, in.ClientIP)
+	}
+
+	for i := len(elements) - 1; i >= 0; i-- {
+		e := elements[i]
+		if e.ip == nil {
+			// The chain is broken by an unknown or obfuscated address.
+			break
+		}
+
+		in.ClientIP = e.ip
+		in.Forwarded = true
+		if e.proto != "" {
+			in.Scheme = strings.ToLower(e.proto)
+		}
+		if e.host != "" {
+			in.Host = e.host
+		}
+
+		if !p.isTrusted(e.ip) {
+			break
+		}
+	}
+
+	return in
+}
+
+func parseHostIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.Trim(addr, "[]"))
+}
+
+// parseForwarded parses the RFC 7239 Forwarded header, for example:
+//
+//	Forwarded: for=192.0.2.60;proto=http;host=example.com, for="[2001:db8::1]:4711"
+func parseForwarded(h http.Header) []forwardedElement {
+	var elements []forwardedElement
+	for _, v := range h.Values("Forwarded") {
+		for _, element := range strings.Split(v, ",") {
+			var e forwardedElement
+			for _, pair := range strings.Split(element, ";") {
+				k, v, ok := cut(pair, "=")
+				if !ok {
+					continue
+				}
+				v = strings.Trim(v, `"`)
+				switch strings.ToLower(k) {
+				case "for":
+					e.ip = parseHostIP(v)
+				case "proto":
+					e.proto = v
+				case "host":
+					e.host = v
+				}
+			}
+			elements = append(elements, e)
+		}
+	}
+	return elements
+}
+
+func parseXForwarded(h http.Header, remote net.IP) []forwardedElement {
+	ips := headerTokens(h, "X-Forwarded-For")
+	protos := headerTokens(h, "X-Forwarded-Proto")
+	hosts := headerTokens(h, "X-Forwarded-Host")
+	if len(ips) == 0 && (len(protos) > 0 || len(hosts) > 0) {
+		// Only the scheme and host were forwarded, keep the connecting address.
+		ips = []string{remote.String()}
+	}
+
+	elements := make([]forwardedElement, len(ips))
+	for i, ip := range ips {
+		elements[i].ip = parseHostIP(ip)
+		// X-Forwarded-Proto and X-Forwarded-Host usually contain a single value set by the
+		// outermost proxy. If they contain one value per hop, use the matching one.
+		elements[i].proto = pickForwardedValue(protos, i, len(ips))
+		elements[i].host = pickForwardedValue(hosts, i, len(ips))
+	}
+	return elements
+}
+
+func pickForwardedValue(values []string, i, hops int) string {
+	switch {
+	case len(values) == 0:
+		return ""
+	case len(values) == hops:
+		return values[i]
+	default:
+		return values[0]
+	}
+}
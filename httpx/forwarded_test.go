@@ -0,0 +1,129 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForwardedParser(t *testing.T) {
+	p, err := NewForwardedParser("10.0.0.0/8", "fd00::/8", "192.168.1.1")
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		d         string
+		remote    string
+		tls       bool
+		header    http.Header
+		ip        string
+		scheme    string
+		host      string
+		forwarded bool
+	}{
+		{
+			d:      "direct request",
+			remote: "203.0.113.1:1234",
+			ip:     "203.0.113.1",
+			scheme: "http",
+			host:   "example.com",
+		},
+		{
+			d:      "direct TLS request",
+			remote: "203.0.113.1:1234",
+			tls:    true,
+			ip:     "203.0.113.1",
+			scheme: "https",
+			host:   "example.com",
+		},
+		{
+			d:      "ignores headers from untrusted peers",
+			remote: "203.0.113.1:1234",
+			header: http.Header{"X-Forwarded-For": {"198.51.100.1"}, "X-Forwarded-Proto": {"https"}, "Forwarded": {"for=198.51.100.1"}},
+			ip:     "203.0.113.1",
+			scheme: "http",
+			host:   "example.com",
+		},
+		{
+			d:         "x-forwarded headers from trusted proxy",
+			remote:    "10.0.0.1:1234",
+			header:    http.Header{"X-Forwarded-For": {"198.51.100.1"}, "X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"public.example.com"}},
+			ip:        "198.51.100.1",
+			scheme:    "https",
+			host:      "public.example.com",
+			forwarded: true,
+		},
+		{
+			d:         "skips trusted proxies in the chain but not spoofed entries",
+			remote:    "10.0.0.1:1234",
+			header:    http.Header{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1", "192.168.1.1"}},
+			ip:        "198.51.100.1",
+			scheme:    "http",
+			host:      "example.com",
+			forwarded: true,
+		},
+		{
+			d:         "only scheme forwarded",
+			remote:    "[fd00::1]:1234",
+			header:    http.Header{"X-Forwarded-Proto": {"https"}},
+			ip:        "fd00::1",
+			scheme:    "https",
+			host:      "example.com",
+			forwarded: true,
+		},
+		{
+			d:         "forwarded header",
+			remote:    "10.0.0.1:1234",
+			header:    http.Header{"Forwarded": {`for=192.0.2.60;proto=https;host=public.example.com, for="[fd00::17]:4711";proto=http;host=internal`}},
+			ip:        "192.0.2.60",
+			scheme:    "https",
+			host:      "public.example.com",
+			forwarded: true,
+		},
+		{
+			d:         "forwarded header takes precedence",
+			remote:    "10.0.0.1:1234",
+			header:    http.Header{"Forwarded": {`for="[2001:db8:cafe::17]:4711"`}, "X-Forwarded-For": {"198.51.100.1"}},
+			ip:        "2001:db8:cafe::17",
+			scheme:    "http",
+			host:      "example.com",
+			forwarded: true,
+		},
+		{
+			d:         "stops at obfuscated identifiers",
+			remote:    "10.0.0.1:1234",
+			header:    http.Header{"Forwarded": {`for=192.0.2.60, for=unknown, for=10.1.1.1`}},
+			ip:        "10.1.1.1",
+			scheme:    "http",
+			host:      "example.com",
+			forwarded: true,
+		},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			r, err := http.NewRequest("GET", "http://example.com/foo", nil)
+			require.NoError(t, err)
+			r.RemoteAddr = tc.remote
+			if tc.header != nil {
+				r.Header = tc.header
+			}
+			if tc.tls {
+				r.TLS = new(tls.ConnectionState)
+			}
+
+			in := p.Parse(r)
+			assert.Equal(t, tc.ip, in.ClientIP.String())
+			assert.Equal(t, tc.scheme, in.Scheme)
+			assert.Equal(t, tc.host, in.Host)
+			assert.Equal(t, tc.forwarded, in.Forwarded)
+		})
+	}
+
+	t.Run("case=rejects invalid proxies", func(t *testing.T) {
+		_, err := NewForwardedParser("not-an-ip")
+		assert.Error(t, err)
+		_, err = NewForwardedParser("10.0.0.0/64")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type hedgedTransport struct {
+	rt    http.RoundTripper
+	delay time.Duration
+}
+
+var _ http.RoundTripper = (*hedgedTransport)(nil)
+
+// NewHedgedTransport returns a RoundTripper which, for idempotent requests without a body
+// (GET, HEAD, OPTIONS), sends a second identical request to the same endpoint if the first
+// one did not complete within delay. Whichever response arrives first is returned and the
+// other request is canceled.
+//
+// Requests with other methods or with a body are passed through unchanged. If rt is nil,
+// http.DefaultTransport is used.
+func NewHedgedTransport(rt http.RoundTripper, delay time.Duration) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &hedgedTransport{rt: rt, delay: delay}
+}
+
+func isHedgeable(r *http.Request) bool {
+	switch r.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		return false
+	}
+	return r.Body == nil || r.Body == http.NoBody
+}
+
+type hedgedResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+}
+
+func (t *hedgedTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.delay <= 0 || !isHedgeable(r) {
+		return t.rt.RoundTrip(r)
+	}
+
+	results := make(chan hedgedResult, 2)
+	var cancels []context.CancelFunc
+	send := func() {
+		ctx, cancel := context.WithCancel(r.Context())
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := t.rt.RoundTrip(r.Clone(ctx))
+			results <- hedgedResult{attempt: attempt, resp: resp, err: err}
+		}()
+	}
+
+	// discard cancels all attempts except keep and releases their responses once they return.
+	discard := func(keep, remaining int) {
+		for i, cancel := range cancels {
+			if i != keep {
+				cancel()
+			}
+		}
+		go func() {
+			for i := 0; i < remaining; i++ {
+				if res := <-results; res.resp != nil {
+					_ = res.resp.Body.Close()
+				}
+			}
+		}()
+	}
+
+	send()
+	inflight := 1
+
+	timer := time.NewTimer(t.delay)
+	defer timer.Stop()
+
+	var lastErr error
+	for {
+		select {
+		case <-timer.C:
+			send()
+			inflight++
+		case res := <-results:
+			inflight--
+			if res.err != nil {
+				cancels[res.attempt]()
+				lastErr = res.err
+				if inflight == 0 {
+					return nil, lastErr
+				}
+				continue
+			}
+
+			discard(res.attempt, inflight)
+			res.resp.Body = &cancelOnClose{ReadCloser: res.resp.Body, cancel: cancels[res.attempt]}
+			return res.resp, nil
+		case <-r.Context().Done():
+			discard(-1, inflight)
+			return nil, r.Context().Err()
+		}
+	}
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
@@ -0,0 +1,69 @@
+package httpx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgedTransport(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+			_, _ = w.Write([]byte("slow"))
+			return
+		}
+		_, _ = w.Write([]byte("fast"))
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("case=uses the faster response", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		c := &http.Client{Transport: NewHedgedTransport(nil, 50*time.Millisecond)}
+
+		start := time.Now()
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "fast", string(body))
+		assert.Less(t, int64(time.Since(start)), int64(time.Second))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=does not hedge requests with a body", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 1)
+		c := &http.Client{Transport: NewHedgedTransport(nil, 50*time.Millisecond)}
+
+		res, err := c.Post(ts.URL, "text/plain", strings.NewReader("foo"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=resilient client", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		c := NewResilientClient(ResilientClientWithHedging(50 * time.Millisecond))
+
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "fast", string(body))
+	})
+}
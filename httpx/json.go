@@ -0,0 +1,117 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxJSONBodySize is the default request body limit of ReadJSON.
+const DefaultMaxJSONBodySize int64 = 1 << 20
+
+// WriteJSON encodes v as JSON and writes it with the given status code.
+func WriteJSON(w http.ResponseWriter, code int, v interface{}) error {
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(v); err != nil {
+		return errors.WithStack(err)
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	_, err := w.Write(b.Bytes())
+	return errors.WithStack(err)
+}
+
+type readJSONOptions struct {
+	maxBytes              int64
+	disallowUnknownFields bool
+	requireContentType    bool
+}
+
+// ReadJSONOption configures ReadJSON.
+type ReadJSONOption func(*readJSONOptions)
+
+// ReadJSONWithMaxBytes limits the request body to n bytes. Defaults to DefaultMaxJSONBodySize.
+func ReadJSONWithMaxBytes(n int64) ReadJSONOption {
+	return func(o *readJSONOptions) {
+		o.maxBytes = n
+	}
+}
+
+// ReadJSONDisallowUnknownFields fails if the body contains fields which are not present in the
+// destination struct.
+func ReadJSONDisallowUnknownFields() ReadJSONOption {
+	return func(o *readJSONOptions) {
+		o.disallowUnknownFields = true
+	}
+}
+
+// ReadJSONIgnoreContentType accepts request bodies regardless of their Content-Type header.
+func ReadJSONIgnoreContentType() ReadJSONOption {
+	return func(o *readJSONOptions) {
+		o.requireContentType = false
+	}
+}
+
+// ReadJSON decodes the JSON request body into v. The body must consist of a single JSON value
+// and must not exceed the size limit.
+//
+// All errors are returned as *Problem with the appropriate status code (400, 413, or 415), so
+// they can be rendered directly using WriteProblem.
+func ReadJSON(r *http.Request, v interface{}, opts ...ReadJSONOption) error {
+	o := &readJSONOptions{maxBytes: DefaultMaxJSONBodySize, requireContentType: true}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.requireContentType && !HasContentType(r, "application/json") && !HasContentType(r, "application/merge-patch+json") {
+		return NewProblem(http.StatusUnsupportedMediaType, fmt.Sprintf("The request body must be JSON but Content-Type was %q.", r.Header.Get("Content-Type")))
+	}
+
+	if r.Body == nil {
+		return NewProblem(http.StatusBadRequest, "The request body must not be empty.")
+	}
+
+	body := io.LimitReader(r.Body, o.maxBytes+1)
+	var counter countingReader
+	counter.r = body
+
+	dec := json.NewDecoder(&counter)
+	if o.disallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+
+	if err := dec.Decode(v); err != nil {
+		if counter.n > o.maxBytes {
+			return NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", o.maxBytes))
+		}
+		if errors.Is(err, io.EOF) {
+			return NewProblem(http.StatusBadRequest, "The request body must not be empty.")
+		}
+		return NewProblem(http.StatusBadRequest, "The request body is not valid JSON: "+err.Error()).WithCause(err)
+	}
+
+	_, err := dec.Token()
+	if counter.n > o.maxBytes {
+		return NewProblem(http.StatusRequestEntityTooLarge, fmt.Sprintf("The request body must not be larger than %d bytes.", o.maxBytes))
+	} else if !errors.Is(err, io.EOF) {
+		return NewProblem(http.StatusBadRequest, "The request body must contain a single JSON value.")
+	}
+
+	return nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/herodot"
+)
+
+func TestWriteJSON(t *testing.T) {
+	rec := httptest.NewRecorder()
+	require.NoError(t, WriteJSON(rec, http.StatusCreated, map[string]string{"foo": "bar"}))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"foo":"bar"}`, rec.Body.String())
+}
+
+func TestReadJSON(t *testing.T) {
+	type payload struct {
+		Foo string `json:"foo"`
+	}
+
+	newRequest := func(body, contentType string) *http.Request {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+
+	for _, tc := range []struct {
+		d, body, contentType string
+		opts                 []ReadJSONOption
+		status               int
+	}{
+		{d: "valid", body: `{"foo":"bar"}`, contentType: "application/json"},
+		{d: "valid with charset", body: `{"foo":"bar"}`, contentType: "application/json; charset=utf-8"},
+		{d: "ignores content type", body: `{"foo":"bar"}`, contentType: "text/plain", opts: []ReadJSONOption{ReadJSONIgnoreContentType()}},
+		{d: "wrong content type", body: `{"foo":"bar"}`, contentType: "text/plain", status: http.StatusUnsupportedMediaType},
+		{d: "empty", body: ``, contentType: "application/json", status: http.StatusBadRequest},
+		{d: "invalid", body: `{"foo":`, contentType: "application/json", status: http.StatusBadRequest},
+		{d: "trailing data", body: `{"foo":"bar"} {}`, contentType: "application/json", status: http.StatusBadRequest},
+		{d: "unknown fields allowed", body: `{"bar":"baz"}`, contentType: "application/json"},
+		{d: "unknown fields disallowed", body: `{"bar":"baz"}`, contentType: "application/json", opts: []ReadJSONOption{ReadJSONDisallowUnknownFields()}, status: http.StatusBadRequest},
+		{d: "too large", body: `{"foo":"` + strings.Repeat("a", 100) + `"}`, contentType: "application/json", opts: []ReadJSONOption{ReadJSONWithMaxBytes(32)}, status: http.StatusRequestEntityTooLarge},
+		{d: "trailing data too large", body: `{"foo":"bar"}` + strings.Repeat(" ", 100), contentType: "application/json", opts: []ReadJSONOption{ReadJSONWithMaxBytes(32)}, status: http.StatusRequestEntityTooLarge},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			var p payload
+			err := ReadJSON(newRequest(tc.body, tc.contentType), &p, tc.opts...)
+			if tc.status == 0 {
+				require.NoError(t, err)
+				return
+			}
+
+			var problem *Problem
+			require.True(t, errors.As(err, &problem), "%+v", err)
+			assert.Equal(t, tc.status, problem.Status)
+		})
+	}
+}
+
+func TestProblem(t *testing.T) {
+	t.Run("case=marshals extensions", func(t *testing.T) {
+		p := NewProblem(http.StatusConflict, "The resource exists already.").
+			WithType("https://example.com/problems/conflict").
+			WithExtension("id", "foo")
+
+		raw, err := json.Marshal(p)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"type":"https://example.com/problems/conflict","title":"Conflict","status":409,"detail":"The resource exists already.","id":"foo"}`, string(raw))
+
+		var actual Problem
+		require.NoError(t, json.Unmarshal(raw, &actual))
+		assert.Equal(t, p.Type, actual.Type)
+		assert.Equal(t, p.Status, actual.Status)
+		assert.Equal(t, map[string]interface{}{"id": "foo"}, actual.Extensions)
+	})
+
+	for _, tc := range []struct {
+		d        string
+		err      error
+		status   int
+		expected string
+	}{
+		{
+			d:        "problem",
+			err:      NewProblem(http.StatusBadRequest, "foo"),
+			status:   http.StatusBadRequest,
+			expected: `{"type":"about:blank","title":"Bad Request","status":400,"detail":"foo","instance":"/foo"}`,
+		},
+		{
+			d:        "herodot error",
+			err:      errors.WithStack(herodot.ErrNotFound.WithReason("The user does not exist.").WithDetail("id", "bar")),
+			status:   http.StatusNotFound,
+			expected: `{"type":"about:blank","title":"Not Found","status":404,"detail":"The user does not exist.","instance":"/foo","details":{"id":"bar"}}`,
+		},
+		{
+			d:        "generic error",
+			err:      errors.New("secret database error"),
+			status:   http.StatusInternalServerError,
+			expected: `{"type":"about:blank","title":"Internal Server Error","status":500,"instance":"/foo"}`,
+		},
+	} {
+		t.Run("case=writes "+tc.d, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			WriteProblem(rec, httptest.NewRequest("GET", "/foo", nil), tc.err)
+			assert.Equal(t, tc.status, rec.Code)
+			assert.Equal(t, ProblemContentType, rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.expected, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// RequestMetrics breaks down the latency of an outgoing HTTP request. Durations of phases which
+// did not happen, for example DNS resolution and connecting when a connection was reused, are zero.
+type RequestMetrics struct {
+	// Method is the request method.
+	Method string
+	// Host is the request host.
+	Host string
+	// StatusCode is the response status code or zero if the request failed.
+	StatusCode int
+	// Err is the error returned by the underlying transport, if any.
+	Err error
+
+	// ConnReused is true if an idle connection was reused.
+	ConnReused bool
+
+	// DNSLookup is the time spent resolving the hostname.
+	DNSLookup time.Duration
+	// Connect is the time spent establishing the TCP connection.
+	Connect time.Duration
+	// TLSHandshake is the time spent on the TLS handshake.
+	TLSHandshake time.Duration
+	// TimeToFirstByte is the time from starting the request until the first response byte arrived.
+	TimeToFirstByte time.Duration
+	// Total is the time from starting the request until the response headers were read.
+	Total time.Duration
+}
+
+type metricsTransport struct {
+	rt      http.RoundTripper
+	observe func(*RequestMetrics)
+}
+
+var _ http.RoundTripper = (*metricsTransport)(nil)
+
+// NewMetricsTransport returns a RoundTripper which records DNS, connect, TLS, and time to first
+// byte timings of every request using net/http/httptrace and passes them to observe once the
+// response headers were received or the request failed. Client traces already present in the
+// request context keep working.
+//
+// If rt is nil, http.DefaultTransport is used.
+func NewMetricsTransport(rt http.RoundTripper, observe func(*RequestMetrics)) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &metricsTransport{rt: rt, observe: observe}
+}
+
+func (t *metricsTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	var (
+		mu                                      sync.Mutex
+		dnsStart, connectStart, tlsStart, start time.Time
+		dnsLookup, connect, tlsHandshake, ttfb  time.Duration
+		reused                                  bool
+	)
+
+	since := func(t time.Time) time.Duration {
+		if t.IsZero() {
+			return 0
+		}
+		return time.Since(t)
+	}
+
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			dnsLookup = since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			mu.Lock()
+			defer mu.Unlock()
+			if connectStart.IsZero() {
+				connectStart = time.Now()
+			}
+		},
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				connect = since(connectStart)
+			}
+		},
+		TLSHandshakeStart: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			tlsHandshake = since(tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			reused = info.Reused
+		},
+		GotFirstResponseByte: func() {
+			mu.Lock()
+			defer mu.Unlock()
+			ttfb = since(start)
+		},
+	}
+
+	start = time.Now()
+	res, err := t.rt.RoundTrip(r.WithContext(httptrace.WithClientTrace(r.Context(), trace)))
+	total := time.Since(start)
+
+	mu.Lock()
+	m := &RequestMetrics{
+		Method:          r.Method,
+		Host:            r.URL.Host,
+		Err:             err,
+		ConnReused:      reused,
+		DNSLookup:       dnsLookup,
+		Connect:         connect,
+		TLSHandshake:    tlsHandshake,
+		TimeToFirstByte: ttfb,
+		Total:           total,
+	}
+	mu.Unlock()
+
+	if res != nil {
+		m.StatusCode = res.StatusCode
+	}
+	if m.Method == "" {
+		m.Method = http.MethodGet
+	}
+	if t.observe != nil {
+		t.observe(m)
+	}
+
+	return res, err
+}
+
+// NewOTelMetricsObserver returns an observer for NewMetricsTransport which records the request
+// timings as OpenTelemetry histograms in milliseconds using meter.
+func NewOTelMetricsObserver(meter metric.Meter) (func(*RequestMetrics), error) {
+	histogram := func(name, desc string) (metric.Float64Histogram, error) {
+		return meter.NewFloat64Histogram(name, metric.WithDescription(desc), metric.WithUnit(unit.Milliseconds))
+	}
+
+	total, err := histogram("http.client.duration", "Time until the response headers were received.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	dns, err := histogram("http.client.dns_lookup.duration", "Time spent resolving the hostname.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	connect, err := histogram("http.client.connect.duration", "Time spent establishing the TCP connection.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	tlsHandshake, err := histogram("http.client.tls_handshake.duration", "Time spent on the TLS handshake.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	ttfb, err := histogram("http.client.time_to_first_byte", "Time until the first response byte was received.")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	ms := func(d time.Duration) float64 {
+		return float64(d) / float64(time.Millisecond)
+	}
+
+	return func(m *RequestMetrics) {
+		ctx := context.Background()
+		attrs := []attribute.KeyValue{
+			semconv.HTTPMethodKey.String(m.Method),
+			semconv.NetPeerNameKey.String(m.Host),
+			semconv.HTTPStatusCodeKey.Int(m.StatusCode),
+		}
+
+		total.Record(ctx, ms(m.Total), attrs...)
+		if m.TimeToFirstByte > 0 {
+			ttfb.Record(ctx, ms(m.TimeToFirstByte), attrs...)
+		}
+		if m.DNSLookup > 0 {
+			dns.Record(ctx, ms(m.DNSLookup), attrs...)
+		}
+		if m.Connect > 0 {
+			connect.Record(ctx, ms(m.Connect), attrs...)
+		}
+		if m.TLSHandshake > 0 {
+			tlsHandshake.Record(ctx, ms(m.TLSHandshake), attrs...)
+		}
+	}, nil
+}
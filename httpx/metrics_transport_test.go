@@ -0,0 +1,92 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/metric/metrictest"
+)
+
+func TestMetricsTransport(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(ts.Close)
+
+	var mu sync.Mutex
+	var observed []*RequestMetrics
+	observe := func(m *RequestMetrics) {
+		mu.Lock()
+		defer mu.Unlock()
+		observed = append(observed, m)
+	}
+
+	c := &http.Client{Transport: NewMetricsTransport(ts.Client().Transport, observe)}
+	for i := 0; i < 2; i++ {
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	require.Len(t, observed, 2)
+	first, second := observed[0], observed[1]
+
+	assert.Equal(t, http.MethodGet, first.Method)
+	assert.Equal(t, u.Host, first.Host)
+	assert.Equal(t, http.StatusAccepted, first.StatusCode)
+	assert.NoError(t, first.Err)
+	assert.False(t, first.ConnReused)
+	assert.NotZero(t, first.Connect)
+	assert.NotZero(t, first.TLSHandshake)
+	assert.NotZero(t, first.TimeToFirstByte)
+	assert.True(t, first.Total >= first.TimeToFirstByte)
+
+	assert.True(t, second.ConnReused)
+	assert.Zero(t, second.Connect)
+	assert.Zero(t, second.TLSHandshake)
+
+	t.Run("case=reports errors", func(t *testing.T) {
+		observed = nil
+		c := NewResilientClient(ResilientClientWithMaxRetry(0), ResilientClientWithMetrics(observe))
+		_, err := c.Get("http://127.0.0.1:1")
+		require.Error(t, err)
+		require.Len(t, observed, 1)
+		assert.Error(t, observed[0].Err)
+		assert.Zero(t, observed[0].StatusCode)
+	})
+}
+
+func TestOTelMetricsObserver(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	mp := metrictest.NewMeterProvider()
+	observe, err := NewOTelMetricsObserver(mp.Meter("httpx"))
+	require.NoError(t, err)
+
+	res, err := NewResilientClient(ResilientClientWithMetrics(observe)).Get(ts.URL)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	recorded := map[string]metrictest.Measured{}
+	for _, m := range metrictest.AsStructs(mp.MeasurementBatches) {
+		recorded[m.Name] = m
+	}
+
+	require.Contains(t, recorded, "http.client.duration")
+	require.Contains(t, recorded, "http.client.connect.duration")
+	require.Contains(t, recorded, "http.client.time_to_first_byte")
+	assert.NotContains(t, recorded, "http.client.tls_handshake.duration")
+	assert.EqualValues(t, http.StatusNoContent, recorded["http.client.duration"].Labels["http.status_code"].AsInt64())
+	assert.Equal(t, "GET", recorded["http.client.duration"].Labels["http.method"].AsString())
+}
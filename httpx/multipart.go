@@ -0,0 +1,183 @@
+package httpx
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultipartPart is a single part of a multipart/form-data request.
+type MultipartPart struct {
+	// FieldName is the form field name.
+	FieldName string
+
+	// FileName is the file name. If empty, the part is sent as a regular form field.
+	FileName string
+
+	// ContentType is the part's content type. Defaults to "application/octet-stream" for files.
+	ContentType string
+
+	// Body is streamed as the part's content.
+	Body io.Reader
+
+	// Size is the length of Body in bytes, or -1 if unknown. If all part sizes are known, the
+	// request's Content-Length is set.
+	Size int64
+}
+
+// MultipartField returns a part for a regular form field.
+func MultipartField(name, value string) MultipartPart {
+	return MultipartPart{FieldName: name, Body: strings.NewReader(value), Size: int64(len(value))}
+}
+
+// MultipartFile returns a part for a file of unknown size.
+func MultipartFile(name, fileName, contentType string, body io.Reader) MultipartPart {
+	return MultipartPart{FieldName: name, FileName: fileName, ContentType: contentType, Body: body, Size: -1}
+}
+
+func (p *MultipartPart) header() textproto.MIMEHeader {
+	h := make(textproto.MIMEHeader)
+	if p.FileName == "" {
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"`, escapeQuotes(p.FieldName)))
+	} else {
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(p.FieldName), escapeQuotes(p.FileName)))
+	}
+
+	switch {
+	case p.ContentType != "":
+		h.Set("Content-Type", p.ContentType)
+	case p.FileName != "":
+		h.Set("Content-Type", "application/octet-stream")
+	}
+	return h
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+type multipartOptions struct {
+	progress func(written, total int64)
+}
+
+// MultipartOption configures NewRequestMultipart.
+type MultipartOption func(*multipartOptions)
+
+// MultipartWithProgress calls progress whenever body bytes have been sent. total is -1 if the
+// size of the request body is unknown.
+func MultipartWithProgress(progress func(written, total int64)) MultipartOption {
+	return func(o *multipartOptions) {
+		o.progress = progress
+	}
+}
+
+// NewRequestMultipart returns a new multipart/form-data *http.Request. The parts are streamed
+// from their readers while the request is sent and are never buffered in memory.
+//
+// If reading a part fails, sending the request fails with that error. Because the body can only
+// be read once, the request can not be retried. If the request is not sent, its body must be
+// closed to release the goroutine writing the parts.
+func NewRequestMultipart(method, url string, parts []MultipartPart, opts ...MultipartOption) (*http.Request, error) {
+	o := new(multipartOptions)
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+
+	size, err := multipartSize(mw.Boundary(), parts)
+	if err != nil {
+		return nil, err
+	}
+
+	var body io.ReadCloser = pr
+	if o.progress != nil {
+		body = &progressReader{ReadCloser: pr, total: size, progress: o.progress}
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.ContentLength = size
+
+	go func() {
+		_ = pw.CloseWithError(writeMultipart(mw, parts))
+	}()
+
+	return req, nil
+}
+
+func writeMultipart(mw *multipart.Writer, parts []MultipartPart) error {
+	for k := range parts {
+		w, err := mw.CreatePart(parts[k].header())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if parts[k].Body == nil {
+			continue
+		}
+		if _, err := io.Copy(w, parts[k].Body); err != nil {
+			return errors.Wrapf(err, "unable to read multipart field %q", parts[k].FieldName)
+		}
+	}
+	return errors.WithStack(mw.Close())
+}
+
+// multipartSize returns the encoded size of parts, or -1 if the size of any part is unknown.
+func multipartSize(boundary string, parts []MultipartPart) (int64, error) {
+	var c countingWriter
+	mw := multipart.NewWriter(&c)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	var size int64
+	for k := range parts {
+		if parts[k].Body != nil && parts[k].Size < 0 {
+			return -1, nil
+		} else if parts[k].Body != nil {
+			size += parts[k].Size
+		}
+		if _, err := mw.CreatePart(parts[k].header()); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return size + c.n, nil
+}
+
+type countingWriter struct {
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+type progressReader struct {
+	io.ReadCloser
+	written, total int64
+	progress       func(written, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.written += int64(n)
+		r.progress(r.written, r.total)
+	}
+	return n, err
+}
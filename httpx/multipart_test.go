@@ -0,0 +1,123 @@
+package httpx
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRequestMultipart(t *testing.T) {
+	type received struct {
+		contentLength int64
+		fields        map[string]string
+		contentTypes  map[string]string
+		fileNames     map[string]string
+	}
+
+	newServer := func(t *testing.T) (*httptest.Server, chan received) {
+		c := make(chan received, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mr, err := r.MultipartReader()
+			require.NoError(t, err)
+
+			rec := received{
+				contentLength: r.ContentLength,
+				fields:        map[string]string{},
+				contentTypes:  map[string]string{},
+				fileNames:     map[string]string{},
+			}
+			for {
+				p, err := mr.NextPart()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				require.NoError(t, err)
+				body, err := ioutil.ReadAll(p)
+				require.NoError(t, err)
+				rec.fields[p.FormName()] = string(body)
+				rec.contentTypes[p.FormName()] = p.Header.Get("Content-Type")
+				rec.fileNames[p.FormName()] = p.FileName()
+			}
+			c <- rec
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		t.Cleanup(ts.Close)
+		return ts, c
+	}
+
+	t.Run("case=streams parts with known size", func(t *testing.T) {
+		ts, c := newServer(t)
+		file := bytes.Repeat([]byte("a"), 1<<16)
+
+		var written, total int64
+		req, err := NewRequestMultipart("POST", ts.URL, []MultipartPart{
+			MultipartField("name", "foo"),
+			{FieldName: "file", FileName: `my "file".png`, ContentType: "image/png", Body: bytes.NewReader(file), Size: int64(len(file))},
+		}, MultipartWithProgress(func(w, t int64) {
+			written, total = w, t
+		}))
+		require.NoError(t, err)
+		assert.True(t, req.ContentLength > int64(len(file)))
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+		rec := <-c
+		assert.Equal(t, req.ContentLength, rec.contentLength)
+		assert.Equal(t, "foo", rec.fields["name"])
+		assert.Equal(t, string(file), rec.fields["file"])
+		assert.Equal(t, "image/png", rec.contentTypes["file"])
+		assert.Equal(t, `my "file".png`, rec.fileNames["file"])
+		assert.Equal(t, req.ContentLength, written)
+		assert.Equal(t, req.ContentLength, total)
+	})
+
+	t.Run("case=streams parts with unknown size", func(t *testing.T) {
+		ts, c := newServer(t)
+
+		var total int64
+		req, err := NewRequestMultipart("POST", ts.URL, []MultipartPart{
+			MultipartFile("file", "file.txt", "", ioutil.NopCloser(strings.NewReader("hello world"))),
+		}, MultipartWithProgress(func(_, t int64) {
+			total = t
+		}))
+		require.NoError(t, err)
+		assert.EqualValues(t, -1, req.ContentLength)
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		rec := <-c
+		assert.Equal(t, "hello world", rec.fields["file"])
+		assert.Equal(t, "application/octet-stream", rec.contentTypes["file"])
+		assert.EqualValues(t, -1, total)
+	})
+
+	t.Run("case=fails if a part can not be read", func(t *testing.T) {
+		req, err := NewRequestMultipart("POST", "http://localhost", []MultipartPart{
+			MultipartFile("file", "file.txt", "", io.MultiReader(strings.NewReader("hello"), &failingReader{})),
+		})
+		require.NoError(t, err)
+
+		_, err = ioutil.ReadAll(req.Body)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to read multipart field")
+	})
+}
+
+type failingReader struct{}
+
+func (*failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read failed")
+}
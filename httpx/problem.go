@@ -0,0 +1,185 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// ProblemContentType is the media type of Problem responses.
+const ProblemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details object. It implements error.
+type Problem struct {
+	// Type is a URI reference identifying the problem type. Defaults to "about:blank".
+	Type string `json:"type,omitempty"`
+
+	// Title is a short, human-readable summary of the problem type.
+	Title string `json:"title,omitempty"`
+
+	// Status is the HTTP status code.
+	Status int `json:"status,omitempty"`
+
+	// Detail is a human-readable explanation specific to this occurrence of the problem.
+	Detail string `json:"detail,omitempty"`
+
+	// Instance is a URI reference identifying the specific occurrence of the problem.
+	Instance string `json:"instance,omitempty"`
+
+	// Extensions are additional members which are rendered alongside the standard members.
+	Extensions map[string]interface{} `json:"-"`
+
+	cause error
+}
+
+var _ error = (*Problem)(nil)
+
+// NewProblem returns a problem with the given status code and detail. The title is set to the
+// status text.
+func NewProblem(status int, detail string) *Problem {
+	return &Problem{
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// WithType sets the problem type URI.
+func (p *Problem) WithType(typ string) *Problem {
+	p.Type = typ
+	return p
+}
+
+// WithInstance sets the problem instance URI.
+func (p *Problem) WithInstance(instance string) *Problem {
+	p.Instance = instance
+	return p
+}
+
+// WithExtension adds an extension member.
+func (p *Problem) WithExtension(key string, value interface{}) *Problem {
+	if p.Extensions == nil {
+		p.Extensions = map[string]interface{}{}
+	}
+	p.Extensions[key] = value
+	return p
+}
+
+// WithCause records the underlying error. It is not rendered.
+func (p *Problem) WithCause(err error) *Problem {
+	p.cause = err
+	return p
+}
+
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Title + ": " + p.Detail
+	}
+	return p.Title
+}
+
+// Unwrap returns the underlying error.
+func (p *Problem) Unwrap() error {
+	return p.cause
+}
+
+// MarshalJSON renders the standard members and the extensions as a single object.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		out[k] = v
+	}
+
+	typ := p.Type
+	if typ == "" {
+		typ = "about:blank"
+	}
+	out["type"] = typ
+
+	if p.Title != "" {
+		out["title"] = p.Title
+	}
+	if p.Status != 0 {
+		out["status"] = p.Status
+	}
+	if p.Detail != "" {
+		out["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		out["instance"] = p.Instance
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON parses the standard members and collects all other members as extensions.
+func (p *Problem) UnmarshalJSON(raw []byte) error {
+	type problem Problem
+	var std problem
+	if err := json.Unmarshal(raw, &std); err != nil {
+		return errors.WithStack(err)
+	}
+
+	var all map[string]interface{}
+	if err := json.Unmarshal(raw, &all); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, k := range []string{"type", "title", "status", "detail", "instance"} {
+		delete(all, k)
+	}
+	if len(all) > 0 {
+		std.Extensions = all
+	}
+
+	*p = Problem(std)
+	return nil
+}
+
+// ProblemFromError converts err to a problem. Problems are returned as is, errors carrying a
+// status code (such as herodot errors) keep their code, reason, and details, and all other
+// errors become an internal server error which does not reveal the error message.
+func ProblemFromError(err error) *Problem {
+	var p *Problem
+	if errors.As(err, &p) {
+		return p
+	}
+
+	var coder interface{ StatusCode() int }
+	if !errors.As(err, &coder) {
+		return NewProblem(http.StatusInternalServerError, "").WithCause(err)
+	}
+
+	p = NewProblem(coder.StatusCode(), "").WithCause(err)
+	if e, ok := coder.(interface{ Reason() string }); ok {
+		p.Detail = e.Reason()
+	}
+	if e, ok := coder.(interface{ Details() map[string]interface{} }); ok && len(e.Details()) > 0 {
+		p.WithExtension("details", e.Details())
+	}
+	return p
+}
+
+// WriteProblem renders err as an RFC 7807 problem using ProblemFromError. If the problem has no
+// instance, the request path is used.
+func WriteProblem(w http.ResponseWriter, r *http.Request, err error) {
+	p := ProblemFromError(err)
+	if p.Status == 0 {
+		p.Status = http.StatusInternalServerError
+	}
+	if p.Instance == "" && r != nil && r.URL != nil {
+		cp := *p
+		cp.Instance = r.URL.Path
+		p = &cp
+	}
+
+	body, merr := json.Marshal(p)
+	if merr != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, _ = w.Write(append(body, '\n'))
+}
@@ -4,10 +4,13 @@ import (
 	"context"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/go-retryablehttp"
+	"github.com/pkg/errors"
 
 	"github.com/ory/x/logrusx"
 )
@@ -19,6 +22,25 @@ type resilientOptions struct {
 	retryWaitMin time.Duration
 	retryWaitMax time.Duration
 	retryMax     int
+	hedgeDelay   time.Duration
+	retryPolicy  *RetryPolicy
+
+	noInternalIPs     bool
+	allowedIPNetworks []*net.IPNet
+
+	dnsCache    *DNSCache
+	dialContext func(ctx context.Context, network, address string) (net.Conn, error)
+	unixSockets map[string]string
+
+	maxBodySize         int64
+	maxDecompressedSize int64
+
+	cache CacheStore
+
+	observeMetrics func(*RequestMetrics)
+
+	maxConcurrentPerHost int
+	queueTimeout         time.Duration
 }
 
 func newResilientOptions() *resilientOptions {
@@ -70,13 +92,188 @@ func ResilientClientWithLogger(l *logrusx.Logger) ResilientOptions {
 	}
 }
 
+// ResilientClientWithHedging enables hedged requests: idempotent requests without a body
+// are sent a second time if no response arrived within delay, and the first response wins.
+// Use this for latency-sensitive GET requests only.
+func ResilientClientWithHedging(delay time.Duration) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.hedgeDelay = delay
+	}
+}
+
+// ResilientClientWithRetryPolicy configures the retry behavior using the given policy. The
+// policy's MaxAttempts takes precedence over ResilientClientWithMaxRetry.
+func ResilientClientWithRetryPolicy(p RetryPolicy) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.retryPolicy = &p
+	}
+}
+
+// ResilientClientDisallowInternalIPs refuses connections to internal IP addresses such as
+// loopback, private, link-local, and unique local addresses, except for the allowed networks.
+//
+// The resolved IP address is checked right before connecting, also when following
+// redirects, which guards against DNS rebinding. The client's transport is replaced with
+// NewSSRFSafeTransport; if it was an *http.Transport, its settings are retained.
+func ResilientClientDisallowInternalIPs(allowed ...*net.IPNet) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.noInternalIPs = true
+		o.allowedIPNetworks = allowed
+	}
+}
+
+// ResilientClientWithDNSCache resolves hostnames using cache, which can be shared between
+// clients. The client's transport is replaced with a clone which dials using NewCachingDialer;
+// if it was an *http.Transport, its settings are retained.
+func ResilientClientWithDNSCache(cache *DNSCache) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.dnsCache = cache
+	}
+}
+
+// ResilientClientWithDialContext sets the function used to establish connections. It takes
+// precedence over ResilientClientWithDNSCache. If internal IP addresses are disallowed, the
+// remote address of each connection is checked after it was established.
+func ResilientClientWithDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error)) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.dialContext = dial
+	}
+}
+
+// ResilientClientWithUnixSocket sends requests for host to the unix domain socket at target,
+// which is either a path or a URL such as "unix:///var/run/sidecar.sock". This is useful to
+// talk to sidecars, for example using "http://sidecar/health". Requests to other hosts are not
+// affected, and retries apply as usual. Because the socket is configured explicitly, it is not
+// subject to ResilientClientDisallowInternalIPs.
+func ResilientClientWithUnixSocket(host, target string) ResilientOptions {
+	return func(o *resilientOptions) {
+		if o.unixSockets == nil {
+			o.unixSockets = map[string]string{}
+		}
+		o.unixSockets[host] = strings.TrimPrefix(target, "unix://")
+	}
+}
+
+// ResilientClientWithResponseSizeLimit caps the size of response bodies at maxBody bytes and
+// the size of decompressed gzip response bodies at maxDecompressed bytes. Reading past a limit
+// fails with a *ResponseTooLargeError. See NewSizeLimitTransport for details.
+func ResilientClientWithResponseSizeLimit(maxBody, maxDecompressed int64) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.maxBodySize = maxBody
+		o.maxDecompressedSize = maxDecompressed
+	}
+}
+
+// ResilientClientWithCache caches responses in store according to their Cache-Control, Expires,
+// ETag, and Last-Modified headers. See NewCachingTransport for details.
+func ResilientClientWithCache(store CacheStore) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.cache = store
+		if o.cache == nil {
+			o.cache = NewMemoryCacheStore()
+		}
+	}
+}
+
+// ResilientClientWithMetrics calls observe with the DNS, connect, TLS, and time to first byte
+// timings of every attempt. See NewMetricsTransport for details.
+func ResilientClientWithMetrics(observe func(*RequestMetrics)) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.observeMetrics = observe
+	}
+}
+
+// ResilientClientWithConcurrencyLimit allows at most maxPerHost requests per host to be in flight
+// at the same time, including retries and hedged requests. Requests wait up to queueTimeout for
+// a free slot. See NewConcurrencyLimitTransport for details.
+func ResilientClientWithConcurrencyLimit(maxPerHost int, queueTimeout time.Duration) ResilientOptions {
+	return func(o *resilientOptions) {
+		o.maxConcurrentPerHost = maxPerHost
+		o.queueTimeout = queueTimeout
+	}
+}
+
+func (o *resilientOptions) dialer() func(ctx context.Context, network, address string) (net.Conn, error) {
+	var dial func(ctx context.Context, network, address string) (net.Conn, error)
+	if o.dialContext != nil {
+		dial = o.dialContext
+		if o.noInternalIPs {
+			dial = ssrfSafeDialContext(dial, o.allowedIPNetworks)
+		}
+	} else {
+		var d *net.Dialer
+		if o.noInternalIPs {
+			d = NewSSRFSafeDialer(o.allowedIPNetworks...)
+		} else {
+			d = &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		}
+		dial = d.DialContext
+		if o.dnsCache != nil {
+			dial = NewCachingDialer(d, o.dnsCache).DialContext
+		}
+	}
+
+	if len(o.unixSockets) > 0 {
+		dial = unixSocketDialContext(dial, o.unixSockets)
+	}
+	return dial
+}
+
 func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 	o := newResilientOptions()
 	for _, f := range opts {
 		f(o)
 	}
 
-	return &retryablehttp.Client{
+	if o.noInternalIPs || o.dnsCache != nil || o.dialContext != nil || len(o.unixSockets) > 0 {
+		c := *o.c
+		t, ok := c.Transport.(*http.Transport)
+		if !ok {
+			t = http.DefaultTransport.(*http.Transport)
+		}
+		t = t.Clone()
+		t.DialContext = o.dialer()
+		if o.noInternalIPs {
+			t.Proxy = nil
+			c.CheckRedirect = ssrfSafeCheckRedirect(c.CheckRedirect, o.allowedIPNetworks)
+		}
+		if len(o.unixSockets) > 0 {
+			t.Proxy = unixSocketProxy(t.Proxy, o.unixSockets)
+		}
+		c.Transport = t
+		o.c = &c
+	}
+
+	if o.observeMetrics != nil {
+		c := *o.c
+		c.Transport = NewMetricsTransport(c.Transport, o.observeMetrics)
+		o.c = &c
+	}
+
+	if o.maxConcurrentPerHost > 0 {
+		c := *o.c
+		c.Transport = NewConcurrencyLimitTransport(c.Transport, o.maxConcurrentPerHost, o.queueTimeout)
+		o.c = &c
+	}
+
+	// the size limit transport is always used, so that ContextWithResponseSizeLimit works
+	sized := *o.c
+	sized.Transport = NewSizeLimitTransport(sized.Transport, o.maxBodySize, o.maxDecompressedSize)
+	o.c = &sized
+
+	if o.hedgeDelay > 0 {
+		c := *o.c
+		c.Transport = NewHedgedTransport(c.Transport, o.hedgeDelay)
+		o.c = &c
+	}
+
+	if o.cache != nil {
+		c := *o.c
+		c.Transport = NewCachingTransport(c.Transport, o.cache)
+		o.c = &c
+	}
+
+	c := &retryablehttp.Client{
 		HTTPClient:   o.c,
 		Logger:       o.l,
 		RetryWaitMin: o.retryWaitMin,
@@ -85,4 +282,27 @@ func NewResilientClient(opts ...ResilientOptions) *retryablehttp.Client {
 		CheckRetry:   retryablehttp.DefaultRetryPolicy,
 		Backoff:      retryablehttp.DefaultBackoff,
 	}
+
+	if p := o.retryPolicy; p != nil {
+		c.RetryMax = p.maxAttempts() - 1
+		c.CheckRetry = p.CheckRetry
+		c.Backoff = p.Backoff
+	}
+
+	next := c.CheckRetry
+	c.CheckRetry = func(ctx context.Context, res *http.Response, err error) (bool, error) {
+		if isPermanentError(err) {
+			return false, err
+		}
+		return next(ctx, res, err)
+	}
+
+	return c
+}
+
+// isPermanentError reports whether err is caused by a client-side policy and retrying the
+// request would yield the same error.
+func isPermanentError(err error) bool {
+	var tooLarge *ResponseTooLargeError
+	return errors.Is(err, ErrInternalIPAddress) || errors.As(err, &tooLarge)
 }
@@ -0,0 +1,170 @@
+package httpx
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// RetryPolicy declaratively describes when and how the resilient client retries requests.
+//
+// The zero value retries idempotent requests on connection errors and on the status codes
+// in DefaultRetryableStatusCodes.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one. Defaults to 5.
+	MaxAttempts int
+
+	// RetryableStatusCodes are the response status codes which trigger a retry.
+	// Defaults to DefaultRetryableStatusCodes.
+	RetryableStatusCodes []int
+
+	// RetryableMethods are the request methods which may be retried.
+	// Defaults to DefaultRetryableMethods.
+	RetryableMethods []string
+
+	// RespectRetryAfter waits for the duration given in the `Retry-After` response header
+	// (delay-seconds or HTTP-date) instead of the computed backoff, if present.
+	RespectRetryAfter bool
+
+	// MaxRetryAfter caps the wait time taken from the `Retry-After` header. Zero means no cap.
+	MaxRetryAfter time.Duration
+
+	// OnAttempt, if set, is called after every attempt with its outcome and whether the
+	// request will be retried. Use it for logging or metrics.
+	OnAttempt func(ctx context.Context, res *http.Response, err error, retry bool)
+}
+
+var (
+	// DefaultRetryableStatusCodes are the status codes retried if RetryPolicy.RetryableStatusCodes is empty.
+	DefaultRetryableStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+
+	// DefaultRetryableMethods are the methods retried if RetryPolicy.RetryableMethods is empty.
+	DefaultRetryableMethods = []string{
+		http.MethodGet,
+		http.MethodHead,
+		http.MethodOptions,
+		http.MethodPut,
+		http.MethodDelete,
+	}
+)
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p.MaxAttempts <= 0 {
+		return 5
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryableStatus(code int) bool {
+	codes := p.RetryableStatusCodes
+	if len(codes) == 0 {
+		codes = DefaultRetryableStatusCodes
+	}
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RetryPolicy) retryableMethod(method string) bool {
+	if method == "" {
+		method = http.MethodGet
+	}
+	methods := p.RetryableMethods
+	if len(methods) == 0 {
+		methods = DefaultRetryableMethods
+	}
+	for _, m := range methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRetry implements retryablehttp.CheckRetry.
+func (p *RetryPolicy) CheckRetry(ctx context.Context, res *http.Response, err error) (retry bool, _ error) {
+	if p.OnAttempt != nil {
+		defer func() { p.OnAttempt(ctx, res, err, retry) }()
+	}
+
+	if ctx.Err() != nil {
+		return false, ctx.Err()
+	}
+
+	if err != nil {
+		// The *url.Error returned by http.Client carries the request method in its Op field.
+		if uerr, ok := err.(*url.Error); ok && !p.retryableMethod(uerr.Op) {
+			return false, nil
+		}
+		return retryablehttp.DefaultRetryPolicy(ctx, res, err)
+	}
+
+	if res.Request != nil && !p.retryableMethod(res.Request.Method) {
+		return false, nil
+	}
+
+	return p.retryableStatus(res.StatusCode), nil
+}
+
+// Backoff implements retryablehttp.Backoff using exponential backoff with full jitter: the wait
+// time is chosen at random between zero and min(max, min*2^attempt).
+func (p *RetryPolicy) Backoff(min, max time.Duration, attempt int, res *http.Response) time.Duration {
+	if p.RespectRetryAfter && res != nil {
+		if wait, ok := parseRetryAfter(res.Header.Get("Retry-After"), time.Now()); ok {
+			if p.MaxRetryAfter > 0 && wait > p.MaxRetryAfter {
+				wait = p.MaxRetryAfter
+			}
+			return wait
+		}
+	}
+
+	ceil := float64(min) * math.Pow(2, float64(attempt))
+	if ceil > float64(max) || math.IsInf(ceil, 0) {
+		ceil = float64(max)
+	}
+	if ceil <= 0 {
+		return 0
+	}
+
+	/* #nosec G404 - jitter does not need a cryptographically secure source */
+	return time.Duration(rand.Int63n(int64(ceil) + 1))
+}
+
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if at, err := http.ParseTime(v); err == nil {
+		if wait := at.Sub(now); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
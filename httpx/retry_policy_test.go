@@ -0,0 +1,103 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	newClient := func(p RetryPolicy) *http.Client {
+		return NewResilientClient(
+			ResilientClientWithMinxRetryWait(time.Millisecond),
+			ResilientClientWithMaxRetryWait(time.Millisecond),
+			ResilientClientWithRetryPolicy(p),
+		).StandardClient()
+	}
+
+	t.Run("case=retries until success and reports attempts", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		var attempts, retries int32
+		c := newClient(RetryPolicy{
+			RespectRetryAfter: true,
+			OnAttempt: func(_ context.Context, _ *http.Response, _ error, retry bool) {
+				atomic.AddInt32(&attempts, 1)
+				if retry {
+					atomic.AddInt32(&retries, 1)
+				}
+			},
+		})
+
+		res, err := c.Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.EqualValues(t, 3, atomic.LoadInt32(&attempts))
+		assert.EqualValues(t, 2, atomic.LoadInt32(&retries))
+	})
+
+	t.Run("case=gives up after max attempts", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		_, err := newClient(RetryPolicy{MaxAttempts: 2}).Get(ts.URL)
+		require.Error(t, err)
+		assert.EqualValues(t, 2, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=does not retry non-retryable methods", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		res, err := newClient(RetryPolicy{}).Post(ts.URL, "text/plain", strings.NewReader("foo"))
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("case=does not retry non-retryable status codes", func(t *testing.T) {
+		atomic.StoreInt32(&calls, 0)
+		res, err := newClient(RetryPolicy{RetryableStatusCodes: []int{http.StatusBadGateway}}).Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+	})
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{RespectRetryAfter: true, MaxRetryAfter: time.Minute}
+
+	for i := 0; i < 100; i++ {
+		wait := p.Backoff(time.Second, 10*time.Second, 3, nil)
+		assert.True(t, wait >= 0 && wait <= 8*time.Second, "%s", wait)
+
+		wait = p.Backoff(time.Second, 10*time.Second, 10, nil)
+		assert.True(t, wait >= 0 && wait <= 10*time.Second, "%s", wait)
+	}
+
+	res := &http.Response{Header: http.Header{"Retry-After": {"5"}}}
+	assert.Equal(t, 5*time.Second, p.Backoff(time.Second, 2*time.Second, 0, res))
+
+	res.Header.Set("Retry-After", "3600")
+	assert.Equal(t, time.Minute, p.Backoff(time.Second, 2*time.Second, 0, res))
+
+	res.Header.Set("Retry-After", time.Now().Add(30*time.Second).UTC().Format(http.TimeFormat))
+	wait := p.Backoff(time.Second, 2*time.Second, 0, res)
+	assert.True(t, wait > 20*time.Second && wait <= 30*time.Second, "%s", wait)
+}
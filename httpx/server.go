@@ -0,0 +1,202 @@
+package httpx
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/logrusx"
+)
+
+// Server wraps http.Server with graceful shutdown, connection draining, systemd socket
+// activation, and hot-reloadable TLS certificates.
+type Server struct {
+	*http.Server
+
+	l               *logrusx.Logger
+	drainTimeout    time.Duration
+	socketActivated bool
+	certFile        string
+	keyFile         string
+}
+
+// ServerOption configures a Server.
+type ServerOption func(*Server)
+
+// ServerWithDrainTimeout sets the time in-flight requests have to complete after shutdown was
+// initiated before their connections are closed forcefully. Defaults to 30 seconds.
+func ServerWithDrainTimeout(d time.Duration) ServerOption {
+	return func(s *Server) {
+		s.drainTimeout = d
+	}
+}
+
+// ServerWithLogger sets the logger used to report lifecycle events and certificate reloads.
+func ServerWithLogger(l *logrusx.Logger) ServerOption {
+	return func(s *Server) {
+		s.l = l
+	}
+}
+
+// ServerWithSystemdSocketActivation makes the server use the first socket passed by systemd
+// (see sd_listen_fds(3)) instead of listening on the configured address. If the process was
+// not socket activated, the server listens on its address as usual.
+func ServerWithSystemdSocketActivation() ServerOption {
+	return func(s *Server) {
+		s.socketActivated = true
+	}
+}
+
+// ServerWithTLSFiles serves TLS using the PEM encoded certificate and key files. The files are
+// watched and the certificate is reloaded without restarting the server whenever they change.
+func ServerWithTLSFiles(certFile, keyFile string) ServerOption {
+	return func(s *Server) {
+		s.certFile = certFile
+		s.keyFile = keyFile
+	}
+}
+
+// NewServer returns a new Server listening on addr.
+func NewServer(addr string, h http.Handler, opts ...ServerOption) *Server {
+	s := &Server{
+		Server: &http.Server{
+			Addr:              addr,
+			Handler:           h,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		drainTimeout: 30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.l == nil {
+		s.l = logrusx.New("", "")
+	}
+	return s
+}
+
+// Listen returns the listener the server will accept connections on.
+func (s *Server) Listen() (net.Listener, error) {
+	if s.socketActivated {
+		listeners, err := systemdListeners()
+		if err != nil {
+			return nil, err
+		}
+		if len(listeners) > 0 {
+			for _, l := range listeners[1:] {
+				_ = l.Close()
+			}
+			return listeners[0], nil
+		}
+	}
+
+	addr := s.Addr
+	if addr == "" {
+		addr = ":http"
+	}
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return l, nil
+}
+
+// ListenAndServe listens and serves requests until ctx is canceled. It then stops accepting
+// new connections and waits for in-flight requests to complete, up to the drain timeout.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	l, err := s.Listen()
+	if err != nil {
+		return err
+	}
+	return s.Serve(ctx, l)
+}
+
+// Serve serves requests on l until ctx is canceled. See ListenAndServe.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if s.certFile != "" || s.keyFile != "" {
+		reloader, err := NewCertificateReloader(ctx, s.certFile, s.keyFile, s.l)
+		if err != nil {
+			_ = l.Close()
+			return err
+		}
+
+		if s.TLSConfig == nil {
+			s.TLSConfig = new(tls.Config)
+		}
+		s.TLSConfig.GetCertificate = reloader.GetCertificate
+		l = tls.NewListener(l, s.TLSConfig)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		s.l.WithField("address", l.Addr().String()).Info("Starting the HTTP server.")
+		errs <- s.Server.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return errors.WithStack(err)
+	case <-ctx.Done():
+	}
+
+	s.l.WithField("drain_timeout", s.drainTimeout.String()).Info("Shutting down the HTTP server gracefully.")
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), s.drainTimeout)
+	defer cancelShutdown()
+
+	if err := s.Shutdown(shutdownCtx); err != nil {
+		s.l.WithError(err).Warn("Connections did not drain in time, closing them.")
+		_ = s.Close()
+		return errors.WithStack(err)
+	}
+
+	s.l.Info("HTTP server was shut down gracefully.")
+	return nil
+}
+
+// systemdListeners returns the sockets passed by systemd socket activation.
+func systemdListeners() ([]net.Listener, error) {
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+
+	// The first passed file descriptor is always 3 (SD_LISTEN_FDS_START).
+	const fdStart = 3
+	listeners := make([]net.Listener, 0, n)
+	for fd := fdStart; fd < fdStart+n; fd++ {
+		f := os.NewFile(uintptr(fd), "LISTEN_FD_"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		_ = f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				_ = l.Close()
+			}
+			return nil, errors.Wrapf(err, "unable to use socket activation file descriptor %d", fd)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
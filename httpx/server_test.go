@@ -0,0 +1,131 @@
+package httpx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/tlsx"
+)
+
+func writeCertificate(t *testing.T, certFile, keyFile string) *tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert, err := tlsx.CreateSelfSignedCertificate(key)
+	require.NoError(t, err)
+	block, err := tlsx.PEMBlockForKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+	require.NoError(t, ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+
+	tlsCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	return &tlsCert
+}
+
+func TestServer(t *testing.T) {
+	t.Run("case=drains in-flight requests on shutdown", func(t *testing.T) {
+		started := make(chan struct{})
+		s := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(200 * time.Millisecond)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		l, err := s.Listen()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Serve(ctx, l) }()
+
+		res := make(chan *http.Response)
+		go func() {
+			r, err := http.Get("http://" + l.Addr().String())
+			require.NoError(t, err)
+			res <- r
+		}()
+
+		<-started
+		cancel()
+
+		r := <-res
+		require.NoError(t, r.Body.Close())
+		assert.Equal(t, http.StatusNoContent, r.StatusCode)
+		require.NoError(t, <-done)
+
+		_, err = http.Get("http://" + l.Addr().String())
+		require.Error(t, err)
+	})
+
+	t.Run("case=closes connections after the drain timeout", func(t *testing.T) {
+		started := make(chan struct{})
+		s := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			time.Sleep(time.Second)
+		}), ServerWithDrainTimeout(50*time.Millisecond))
+
+		l, err := s.Listen()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() { done <- s.Serve(ctx, l) }()
+		go func() { _, _ = http.Get("http://" + l.Addr().String()) }()
+
+		<-started
+		cancel()
+		require.Error(t, <-done)
+	})
+
+	t.Run("case=reloads TLS certificates", func(t *testing.T) {
+		dir := t.TempDir()
+		certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+		first := writeCertificate(t, certFile, keyFile)
+
+		s := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		}), ServerWithTLSFiles(certFile, keyFile))
+
+		l, err := s.Listen()
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go func() { _ = s.Serve(ctx, l) }()
+
+		servedCertificate := func() []byte {
+			c := &http.Client{Transport: &http.Transport{
+				TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+				DisableKeepAlives: true,
+			}}
+			res, err := c.Get("https://" + l.Addr().String())
+			if err != nil {
+				return nil
+			}
+			defer res.Body.Close()
+			return res.TLS.PeerCertificates[0].Raw
+		}
+
+		require.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(first.Certificate[0], servedCertificate())
+		}, 5*time.Second, 10*time.Millisecond)
+
+		second := writeCertificate(t, certFile, keyFile)
+		require.Eventually(t, func() bool {
+			return assert.ObjectsAreEqual(second.Certificate[0], servedCertificate())
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+}
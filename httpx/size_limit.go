@@ -0,0 +1,150 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ResponseTooLargeError is returned when a response body exceeds the configured size limit.
+type ResponseTooLargeError struct {
+	// Limit is the limit in bytes that was exceeded.
+	Limit int64
+
+	// Decompressed is true if the limit on the decompressed body size was exceeded.
+	Decompressed bool
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	if e.Decompressed {
+		return fmt.Sprintf("decompressed response body exceeds the limit of %d bytes", e.Limit)
+	}
+	return fmt.Sprintf("response body exceeds the limit of %d bytes", e.Limit)
+}
+
+type responseSizeLimitKey struct{}
+
+// ContextWithResponseSizeLimit limits the response body of requests with the context to maxBody
+// bytes. The limit is enforced by the size limit transport, which every client created with
+// NewResilientClient uses, in addition to its own limit. Unlike reading the body with an
+// io.LimitReader, the limit also applies to transports which read the body before returning
+// the response, such as the caching transport.
+func ContextWithResponseSizeLimit(ctx context.Context, maxBody int64) context.Context {
+	return context.WithValue(ctx, responseSizeLimitKey{}, maxBody)
+}
+
+type sizeLimitTransport struct {
+	rt              http.RoundTripper
+	maxBody         int64
+	maxDecompressed int64
+}
+
+var _ http.RoundTripper = (*sizeLimitTransport)(nil)
+
+// NewSizeLimitTransport returns a RoundTripper which fails reading response bodies larger than
+// maxBody bytes with a *ResponseTooLargeError.
+//
+// If maxDecompressed is positive, gzip decompression is handled by this RoundTripper instead of
+// the underlying transport, and reading more than maxDecompressed bytes of decompressed content
+// fails with a *ResponseTooLargeError as well. This guards against decompression bombs. Requests
+// which set the Accept-Encoding header themselves are responsible for decompression and only
+// the maxBody limit applies.
+//
+// A limit of zero or less disables the respective check. If rt is nil, http.DefaultTransport is used.
+func NewSizeLimitTransport(rt http.RoundTripper, maxBody, maxDecompressed int64) http.RoundTripper {
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &sizeLimitTransport{rt: rt, maxBody: maxBody, maxDecompressed: maxDecompressed}
+}
+
+func (t *sizeLimitTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	decompress := t.maxDecompressed > 0 && r.Method != http.MethodHead && r.Header.Get("Accept-Encoding") == ""
+	if decompress {
+		r = r.Clone(r.Context())
+		r.Header.Set("Accept-Encoding", "gzip")
+	}
+
+	res, err := t.rt.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	maxBody := t.maxBody
+	if limit, ok := r.Context().Value(responseSizeLimitKey{}).(int64); ok && limit > 0 && (maxBody <= 0 || limit < maxBody) {
+		maxBody = limit
+	}
+	if maxBody > 0 {
+		if res.ContentLength > maxBody {
+			_ = res.Body.Close()
+			return nil, &ResponseTooLargeError{Limit: maxBody}
+		}
+		res.Body = newLimitedBody(res.Body, res.Body, maxBody, false)
+	}
+
+	if decompress && strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		res.Body = newLimitedBody(&gzipBody{body: res.Body}, res.Body, t.maxDecompressed, true)
+		res.Header.Del("Content-Encoding")
+		res.Header.Del("Content-Length")
+		res.ContentLength = -1
+		res.Uncompressed = true
+	}
+
+	return res, nil
+}
+
+// limitedBody reads at most limit bytes from r and fails with a *ResponseTooLargeError if r has more.
+type limitedBody struct {
+	r            io.Reader
+	c            io.Closer
+	remaining    int64
+	limit        int64
+	decompressed bool
+}
+
+func newLimitedBody(r io.Reader, c io.Closer, limit int64, decompressed bool) *limitedBody {
+	return &limitedBody{r: r, c: c, remaining: limit, limit: limit, decompressed: decompressed}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// Probe whether there is more data beyond the limit.
+		var probe [1]byte
+		n, err := b.r.Read(probe[:])
+		if n > 0 {
+			return 0, &ResponseTooLargeError{Limit: b.limit, Decompressed: b.decompressed}
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.r.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *limitedBody) Close() error {
+	return b.c.Close()
+}
+
+// gzipBody lazily decompresses body on the first read.
+type gzipBody struct {
+	body io.Reader
+	zr   *gzip.Reader
+	err  error
+}
+
+func (b *gzipBody) Read(p []byte) (int, error) {
+	if b.zr == nil && b.err == nil {
+		b.zr, b.err = gzip.NewReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.zr.Read(p)
+}
@@ -0,0 +1,106 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSizeLimitTransport(t *testing.T) {
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, err := zw.Write(bytes.Repeat([]byte("a"), 1<<20))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	require.Less(t, compressed.Len(), 4096)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/bomb":
+			w.Header().Set("Content-Encoding", "gzip")
+			_, _ = w.Write(compressed.Bytes())
+		case "/chunked":
+			w.(http.Flusher).Flush()
+			_, _ = w.Write(bytes.Repeat([]byte("a"), 1<<16))
+		default:
+			n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+			w.Header().Set("Content-Length", strconv.Itoa(n))
+			_, _ = w.Write(bytes.Repeat([]byte("a"), n))
+		}
+	}))
+	t.Cleanup(ts.Close)
+
+	assertTooLarge := func(t *testing.T, err error, decompressed bool) {
+		var e *ResponseTooLargeError
+		require.True(t, errors.As(err, &e), "%+v", err)
+		assert.Equal(t, decompressed, e.Decompressed)
+	}
+
+	c := &http.Client{Transport: NewSizeLimitTransport(nil, 4096, 8192)}
+
+	t.Run("case=allows small bodies", func(t *testing.T) {
+		res, err := c.Get(ts.URL + "/?n=4096")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Len(t, body, 4096)
+	})
+
+	t.Run("case=rejects large bodies by content length", func(t *testing.T) {
+		_, err := c.Get(ts.URL + "/?n=4097")
+		assertTooLarge(t, err, false)
+	})
+
+	t.Run("case=rejects large chunked bodies while reading", func(t *testing.T) {
+		res, err := c.Get(ts.URL + "/chunked")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		_, err = ioutil.ReadAll(res.Body)
+		assertTooLarge(t, err, false)
+	})
+
+	t.Run("case=rejects decompression bombs", func(t *testing.T) {
+		res, err := c.Get(ts.URL + "/bomb")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Empty(t, res.Header.Get("Content-Encoding"))
+		_, err = ioutil.ReadAll(res.Body)
+		assertTooLarge(t, err, true)
+	})
+
+	t.Run("case=resilient client", func(t *testing.T) {
+		res, err := NewResilientClient(ResilientClientWithResponseSizeLimit(0, 1<<20)).Get(ts.URL + "/bomb")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+		assert.Len(t, body, 1<<20)
+	})
+
+	t.Run("case=limits requests with the context", func(t *testing.T) {
+		c := NewResilientClient(ResilientClientWithCache(nil))
+		req, err := http.NewRequestWithContext(ContextWithResponseSizeLimit(context.Background(), 100), "GET", ts.URL+"/chunked", nil)
+		require.NoError(t, err)
+		res, err := c.HTTPClient.Do(req)
+		require.NoError(t, err)
+		_, err = ioutil.ReadAll(res.Body)
+		_ = res.Body.Close()
+		assertTooLarge(t, err, false)
+
+		req, err = http.NewRequestWithContext(ContextWithResponseSizeLimit(context.Background(), 1<<20), "GET", ts.URL+"/?n=4097", nil)
+		require.NoError(t, err)
+		res, err = (&http.Client{Transport: NewSizeLimitTransport(nil, 4096, 0)}).Do(req)
+		assertTooLarge(t, err, false)
+		assert.Nil(t, res, "the limit of the transport applies if it is lower")
+	})
+}
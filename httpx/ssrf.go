@@ -0,0 +1,162 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInternalIPAddress is returned when a connection to an internal IP address was refused.
+var ErrInternalIPAddress = errors.New("refusing to connect to an internal IP address")
+
+var internalNetworks = mustParseCIDRs(
+	"0.0.0.0/8",      // "this" network
+	"10.0.0.0/8",     // private
+	"100.64.0.0/10",  // carrier-grade NAT
+	"127.0.0.0/8",    // loopback
+	"169.254.0.0/16", // link-local
+	"172.16.0.0/12",  // private
+	"192.0.0.0/24",   // IETF protocol assignments
+	"192.168.0.0/16", // private
+	"198.18.0.0/15",  // benchmarking
+	"224.0.0.0/4",    // multicast
+	"240.0.0.0/4",    // reserved and broadcast
+	"::/128",         // unspecified
+	"::1/128",        // loopback
+	"64:ff9b::/96",   // IPv4/IPv6 translation
+	"fc00::/7",       // unique local
+	"fe80::/10",      // link-local
+	"ff00::/8",       // multicast
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for k, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[k] = n
+	}
+	return nets
+}
+
+// IsInternalIP reports whether ip is a loopback, private, link-local, unique local, multicast,
+// or otherwise non-public address. IPv4-mapped IPv6 addresses are checked as IPv4 addresses.
+func IsInternalIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range internalNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipAllowed(ip net.IP, allowed []*net.IPNet) bool {
+	if !IsInternalIP(ip) {
+		return true
+	}
+	for _, n := range allowed {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewSSRFSafeDialer returns a dialer which refuses to connect to internal IP addresses (see
+// IsInternalIP) unless they are part of one of the allowed networks.
+//
+// The check is performed on the resolved IP address right before the connection is
+// established, so a hostname is resolved exactly once per connection and the address that
+// was checked is the one that is dialed. This protects against DNS rebinding attacks.
+func NewSSRFSafeDialer(allowed ...*net.IPNet) *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return errors.WithStack(err)
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return errors.Errorf("unable to parse IP address %q", host)
+			}
+			if !ipAllowed(ip, allowed) {
+				return errors.Wrapf(ErrInternalIPAddress, "address %s", address)
+			}
+			return nil
+		},
+	}
+}
+
+// NewSSRFSafeTransport returns a clone of base which dials using NewSSRFSafeDialer. Proxies
+// configured via the environment are ignored because connecting through them would bypass
+// the IP address check. If base is nil, http.DefaultTransport is used.
+func NewSSRFSafeTransport(base *http.Transport, allowed ...*net.IPNet) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+	t.Proxy = nil
+	t.DialContext = NewSSRFSafeDialer(allowed...).DialContext
+	return t
+}
+
+// ssrfSafeCheckRedirect refuses redirects to internal IP literals early. Redirects to
+// hostnames are checked by the dialer once the hostname was resolved.
+func ssrfSafeCheckRedirect(next func(*http.Request, []*http.Request) error, allowed []*net.IPNet) func(*http.Request, []*http.Request) error {
+	return func(r *http.Request, via []*http.Request) error {
+		if err := checkURLIPLiteral(r.URL, allowed); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(r, via)
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+}
+
+func checkURLIPLiteral(u *url.URL, allowed []*net.IPNet) error {
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !ipAllowed(ip, allowed) {
+		return errors.Wrapf(ErrInternalIPAddress, "address %s", u.Host)
+	}
+	return nil
+}
+
+// ssrfSafeDialContext checks the remote address of connections established by dial. Unlike
+// NewSSRFSafeDialer, the check happens after the connection was established, because dial is
+// opaque.
+func ssrfSafeDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error), allowed []*net.IPNet) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		var ip net.IP
+		switch addr := conn.RemoteAddr().(type) {
+		case *net.TCPAddr:
+			ip = addr.IP
+		case *net.UDPAddr:
+			ip = addr.IP
+		}
+		if ip != nil && !ipAllowed(ip, allowed) {
+			_ = conn.Close()
+			return nil, errors.Wrapf(ErrInternalIPAddress, "address %s", conn.RemoteAddr())
+		}
+		return conn, nil
+	}
+}
@@ -0,0 +1,95 @@
+package httpx
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsInternalIP(t *testing.T) {
+	for ip, expected := range map[string]bool{
+		"127.0.0.1":        true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"100.64.0.1":       true,
+		"0.0.0.0":          true,
+		"::1":              true,
+		"::":               true,
+		"fe80::1":          true,
+		"fd00::1":          true,
+		"fc12:3456::1":     true,
+		"::ffff:127.0.0.1": true,
+		"::ffff:10.0.0.1":  true,
+		"8.8.8.8":          false,
+		"1.1.1.1":          false,
+		"2001:4860::8888":  false,
+		"::ffff:8.8.8.8":   false,
+	} {
+		t.Run("ip="+ip, func(t *testing.T) {
+			assert.Equal(t, expected, IsInternalIP(net.ParseIP(ip)))
+		})
+	}
+}
+
+func TestSSRFSafeClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if to := r.URL.Query().Get("to"); to != "" {
+			http.Redirect(w, r, to, http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	_, loopback, err := net.ParseCIDR("127.0.0.0/8")
+	require.NoError(t, err)
+
+	t.Run("case=refuses loopback", func(t *testing.T) {
+		_, err := NewResilientClient(ResilientClientDisallowInternalIPs()).Get(ts.URL)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+
+	t.Run("case=checks the resolved address instead of the hostname", func(t *testing.T) {
+		_, port, err := net.SplitHostPort(ts.Listener.Addr().String())
+		require.NoError(t, err)
+
+		_, err = NewResilientClient(ResilientClientDisallowInternalIPs()).Get("http://localhost:" + port)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+
+	t.Run("case=allows allowlisted networks", func(t *testing.T) {
+		res, err := NewResilientClient(ResilientClientDisallowInternalIPs(loopback)).Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+	})
+
+	for _, to := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://[fd00::1]/",
+		"http://[fe80::1]/",
+		"http://[::ffff:10.0.0.1]/",
+	} {
+		t.Run("case=refuses redirect to "+to, func(t *testing.T) {
+			_, err := NewResilientClient(ResilientClientDisallowInternalIPs(loopback)).Get(fmt.Sprintf("%s/?to=%s", ts.URL, to))
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+		})
+	}
+
+	t.Run("case=dialer refuses internal addresses", func(t *testing.T) {
+		_, err := NewSSRFSafeDialer().Dial("tcp", ts.Listener.Addr().String())
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+}
@@ -0,0 +1,34 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// unixSocketDialContext dials the unix domain socket configured for the address' host, and
+// falls back to dial for all other hosts.
+func unixSocketDialContext(dial func(ctx context.Context, network, address string) (net.Conn, error), sockets map[string]string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		if path, ok := sockets[host]; ok {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		return dial(ctx, network, address)
+	}
+}
+
+// unixSocketProxy bypasses the proxy for hosts which are served by a unix domain socket.
+func unixSocketProxy(proxy func(*http.Request) (*url.URL, error), sockets map[string]string) func(*http.Request) (*url.URL, error) {
+	return func(r *http.Request) (*url.URL, error) {
+		if _, ok := sockets[r.URL.Hostname()]; ok || proxy == nil {
+			return nil, nil
+		}
+		return proxy(r)
+	}
+}
@@ -0,0 +1,68 @@
+package httpx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResilientClientDialers(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(ts.Close)
+
+	t.Run("case=sends requests to unix domain sockets", func(t *testing.T) {
+		socket := filepath.Join(t.TempDir(), "sidecar.sock")
+		l, err := net.Listen("unix", socket)
+		require.NoError(t, err)
+
+		sidecar := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusAccepted)
+		})}
+		go func() { _ = sidecar.Serve(l) }()
+		t.Cleanup(func() { _ = sidecar.Close() })
+
+		c := NewResilientClient(
+			ResilientClientWithUnixSocket("sidecar", "unix://"+socket),
+			ResilientClientDisallowInternalIPs(),
+			ResilientClientWithMaxRetry(0),
+		)
+
+		res, err := c.Get("http://sidecar/health")
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		assert.Equal(t, http.StatusAccepted, res.StatusCode)
+
+		_, err = c.Get(ts.URL)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+
+	t.Run("case=uses the custom dialer", func(t *testing.T) {
+		var calls int32
+		dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+			atomic.AddInt32(&calls, 1)
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		}
+
+		res, err := NewResilientClient(ResilientClientWithDialContext(dial)).Get(ts.URL)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		assert.Equal(t, http.StatusNoContent, res.StatusCode)
+		assert.EqualValues(t, 1, atomic.LoadInt32(&calls))
+
+		_, err = NewResilientClient(ResilientClientWithDialContext(dial), ResilientClientDisallowInternalIPs(), ResilientClientWithMaxRetry(0)).Get(ts.URL)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrInternalIPAddress), "%+v", err)
+	})
+}
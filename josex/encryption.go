@@ -0,0 +1,93 @@
+package josex
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+)
+
+// ContentEncryption is the content encryption algorithm used by Encrypt.
+const ContentEncryption = jose.A256GCM
+
+// keyAlgorithm returns the key management algorithm for the key: RSA-OAEP-256 for RSA keys and
+// ECDH-ES+A256KW for elliptic curve keys.
+func keyAlgorithm(key interface{}) (jose.KeyAlgorithm, error) {
+	switch key.(type) {
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return jose.RSA_OAEP_256, nil
+	case *ecdsa.PublicKey, *ecdsa.PrivateKey:
+		return jose.ECDH_ES_A256KW, nil
+	}
+	return "", errors.Errorf("josex: unable to encrypt for key type %T, use an RSA or elliptic curve key", key)
+}
+
+// Encrypt encrypts the payload for the owner of the key, for example a public key from a JSON
+// Web Key Set, and returns the compact serialization of the JSON Web Encryption. The content is
+// encrypted with A256GCM and the content encryption key is wrapped with RSA-OAEP-256 or
+// ECDH-ES+A256KW, depending on the key type. The key ID is set in the header.
+func Encrypt(payload []byte, key *jose.JSONWebKey) (string, error) {
+	alg, err := keyAlgorithm(key.Key)
+	if err != nil {
+		return "", err
+	}
+
+	public := key.Public()
+	encrypter, err := jose.NewEncrypter(ContentEncryption,
+		jose.Recipient{Algorithm: alg, Key: public.Key, KeyID: key.KeyID}, nil)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	jwe, err := encrypter.Encrypt(payload)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	compact, err := jwe.CompactSerialize()
+	return compact, errors.WithStack(err)
+}
+
+// Decrypt decrypts the compact serialization of a JSON Web Encryption created by Encrypt with
+// the private key. Other key management or content encryption algorithms are rejected.
+func Decrypt(compact string, key *jose.JSONWebKey) ([]byte, error) {
+	jwe, err := jose.ParseEncrypted(compact)
+	if err != nil {
+		return nil, errors.Wrap(err, "josex: unable to parse the encrypted payload")
+	}
+
+	alg, err := keyAlgorithm(key.Key)
+	if err != nil {
+		return nil, err
+	}
+	if jwe.Header.Algorithm != string(alg) {
+		return nil, errors.Errorf(`josex: expected key management algorithm "%s" but got "%s"`, alg, jwe.Header.Algorithm)
+	}
+	if enc, _ := jwe.Header.ExtraHeaders[jose.HeaderKey("enc")].(string); enc != string(ContentEncryption) {
+		return nil, errors.Errorf(`josex: expected content encryption "%s" but got "%s"`, ContentEncryption, enc)
+	}
+
+	payload, err := jwe.Decrypt(key.Key)
+	return payload, errors.Wrap(err, "josex: unable to decrypt the payload")
+}
+
+// DecryptWithKeySet decrypts the compact serialization of a JSON Web Encryption with the
+// private key of the set whose key ID matches the "kid" header.
+func DecryptWithKeySet(compact string, set *jose.JSONWebKeySet) ([]byte, error) {
+	jwe, err := jose.ParseEncrypted(compact)
+	if err != nil {
+		return nil, errors.Wrap(err, "josex: unable to parse the encrypted payload")
+	}
+
+	keys := set.Key(jwe.Header.KeyID)
+	if len(keys) == 0 {
+		return nil, errors.Errorf(`josex: unable to find a key with ID "%s"`, jwe.Header.KeyID)
+	}
+	for k := range keys {
+		if keys[k].IsPublic() {
+			continue
+		}
+		return Decrypt(compact, &keys[k])
+	}
+	return nil, errors.Errorf(`josex: the key with ID "%s" is not a private key`, jwe.Header.KeyID)
+}
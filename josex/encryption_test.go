@@ -0,0 +1,71 @@
+package josex
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/square/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name string
+		key  jose.JSONWebKey
+		alg  string
+	}{
+		{name: "rsa", key: jose.JSONWebKey{Key: rsaKey, KeyID: "rsa"}, alg: "RSA-OAEP-256"},
+		{name: "ec", key: jose.JSONWebKey{Key: ecKey, KeyID: "ec"}, alg: "ECDH-ES+A256KW"},
+	} {
+		t.Run("key="+tc.name, func(t *testing.T) {
+			public := tc.key.Public()
+			compact, err := Encrypt([]byte("secret payload"), &public)
+			require.NoError(t, err)
+			assert.Len(t, strings.Split(compact, "."), 5)
+
+			jwe, err := jose.ParseEncrypted(compact)
+			require.NoError(t, err)
+			assert.Equal(t, tc.alg, jwe.Header.Algorithm)
+			assert.Equal(t, tc.key.KeyID, jwe.Header.KeyID)
+
+			payload, err := Decrypt(compact, &tc.key)
+			require.NoError(t, err)
+			assert.Equal(t, "secret payload", string(payload))
+
+			payload, err = DecryptWithKeySet(compact, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{public, tc.key}})
+			require.NoError(t, err)
+			assert.Equal(t, "secret payload", string(payload))
+
+			_, err = DecryptWithKeySet(compact, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{public}})
+			require.Error(t, err)
+
+			parts := strings.Split(compact, ".")
+			parts[3] = strings.Repeat("A", len(parts[3]))
+			_, err = Decrypt(strings.Join(parts, "."), &tc.key)
+			require.Error(t, err, "tampered ciphertexts must be rejected")
+		})
+	}
+
+	t.Run("case=algorithm mismatch", func(t *testing.T) {
+		ecPublic := jose.JSONWebKey{Key: &ecKey.PublicKey}
+		compact, err := Encrypt([]byte("payload"), &ecPublic)
+		require.NoError(t, err)
+		_, err = Decrypt(compact, &jose.JSONWebKey{Key: rsaKey})
+		require.Error(t, err)
+	})
+
+	t.Run("case=unsupported key", func(t *testing.T) {
+		_, err := Encrypt([]byte("payload"), &jose.JSONWebKey{Key: []byte("secret")})
+		require.Error(t, err)
+	})
+}
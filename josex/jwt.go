@@ -0,0 +1,156 @@
+package josex
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+	"github.com/square/go-jose/v3/jwt"
+)
+
+var (
+	// ErrInvalidToken is returned if the token is malformed or its key can not be resolved.
+	ErrInvalidToken = errors.New("josex: the token is malformed")
+
+	// ErrInvalidSignature is returned if the signature of the token is invalid.
+	ErrInvalidSignature = errors.New("josex: the token signature is invalid")
+
+	// ErrInvalidClaims is returned if the signature is valid, but the claims are rejected by the
+	// claims policy, for example because the token expired.
+	ErrInvalidClaims = errors.New("josex: the token claims are invalid")
+)
+
+type (
+	// KeyResolver returns the key to verify a token with. It is implemented by
+	// *jwksx.Resolver, and by KeySetResolver for a static key set.
+	KeyResolver interface {
+		Resolve(iss, kid, alg string) (*jose.JSONWebKey, error)
+	}
+
+	// KeySetResolver resolves keys from a static key set by their key ID.
+	KeySetResolver jose.JSONWebKeySet
+
+	// ClaimsPolicy configures how the claims of a token are validated.
+	ClaimsPolicy struct {
+		// Issuer, if set, must be the "iss" claim.
+		Issuer string
+
+		// Audience, if set, must be contained in the "aud" claim.
+		Audience string
+
+		// Leeway is the clock skew tolerated when validating "exp", "nbf" and "iat".
+		Leeway time.Duration
+
+		// RequiredClaims must be present in the token, for example "exp" or a custom claim.
+		RequiredClaims []string
+
+		// Validate, if set, validates the claims after the standard claims were validated.
+		Validate func(claims map[string]interface{}) error
+	}
+
+	// JWTVerifier verifies the signature and claims of JSON Web Tokens.
+	JWTVerifier struct {
+		Keys   KeyResolver
+		Policy ClaimsPolicy
+
+		// Now returns the current time. Defaults to time.Now.
+		Now func() time.Time
+	}
+)
+
+// Resolve returns the key with the key ID if its algorithm matches.
+func (r *KeySetResolver) Resolve(_, kid, alg string) (*jose.JSONWebKey, error) {
+	for _, k := range (*jose.JSONWebKeySet)(r).Key(kid) {
+		if k.Algorithm == "" || k.Algorithm == alg {
+			return &k, nil
+		}
+	}
+	return nil, errors.Errorf(`josex: unable to find a key with ID "%s" for algorithm "%s"`, kid, alg)
+}
+
+// SignJWT issues a JSON Web Token with the claims, for example a struct embedding jwt.Claims,
+// signed by the key. The algorithm and key ID are taken from the key.
+func SignJWT(key *jose.JSONWebKey, claims ...interface{}) (string, error) {
+	if key.Algorithm == "" {
+		return "", errors.New("josex: the signing key must have an algorithm")
+	}
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.SignatureAlgorithm(key.Algorithm), Key: key},
+		(&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	builder := jwt.Signed(signer)
+	for _, c := range claims {
+		builder = builder.Claims(c)
+	}
+	token, err := builder.CompactSerialize()
+	return token, errors.WithStack(err)
+}
+
+// Verify verifies the token and decodes its claims into dest, for example a struct embedding
+// jwt.Claims. Errors wrap ErrInvalidToken, ErrInvalidSignature or ErrInvalidClaims.
+func (v *JWTVerifier) Verify(token string, dest ...interface{}) error {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidToken, "%s", err)
+	}
+	if len(parsed.Headers) != 1 {
+		return errors.Wrap(ErrInvalidToken, "the token must have exactly one signature")
+	}
+
+	var unverified jwt.Claims
+	if err := parsed.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return errors.Wrapf(ErrInvalidToken, "%s", err)
+	}
+	header := parsed.Headers[0]
+	key, err := v.Keys.Resolve(unverified.Issuer, header.KeyID, header.Algorithm)
+	if err != nil {
+		return errors.Wrapf(ErrInvalidToken, "unable to resolve the key: %s", err)
+	}
+
+	var claims jwt.Claims
+	var raw map[string]interface{}
+	if err := parsed.Claims(key, &claims, &raw); err != nil {
+		return errors.Wrapf(ErrInvalidSignature, "%s", err)
+	}
+
+	if err := v.validate(claims, raw); err != nil {
+		return errors.Wrapf(ErrInvalidClaims, "%s", err)
+	}
+
+	if len(dest) > 0 {
+		// The signature was verified above.
+		if err := parsed.UnsafeClaimsWithoutVerification(dest...); err != nil {
+			return errors.Wrapf(ErrInvalidClaims, "%s", err)
+		}
+	}
+	return nil
+}
+
+func (v *JWTVerifier) validate(claims jwt.Claims, raw map[string]interface{}) error {
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	expected := jwt.Expected{Issuer: v.Policy.Issuer, Time: now()}
+	if v.Policy.Audience != "" {
+		expected.Audience = jwt.Audience{v.Policy.Audience}
+	}
+	if err := claims.ValidateWithLeeway(expected, v.Policy.Leeway); err != nil {
+		return err
+	}
+
+	for _, name := range v.Policy.RequiredClaims {
+		if _, ok := raw[name]; !ok {
+			return errors.Errorf(`the claim "%s" is required`, name)
+		}
+	}
+
+	if v.Policy.Validate != nil {
+		return v.Policy.Validate(raw)
+	}
+	return nil
+}
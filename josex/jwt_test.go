@@ -0,0 +1,107 @@
+package josex
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+	"github.com/square/go-jose/v3/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/jwksx"
+)
+
+// The key resolver of jwksx uses the same go-jose version.
+var _ KeyResolver = (*jwksx.Resolver)(nil)
+
+func TestJWT(t *testing.T) {
+	now := time.Now()
+	newKey := func(t *testing.T, kid string) jose.JSONWebKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		return jose.JSONWebKey{Key: k, KeyID: kid, Algorithm: string(jose.ES256), Use: "sig"}
+	}
+	key, other := newKey(t, "key"), newKey(t, "key")
+	keys := KeySetResolver(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{key.Public()}})
+
+	type customClaims struct {
+		jwt.Claims
+		Tenant string `json:"tenant,omitempty"`
+	}
+	sign := func(t *testing.T, key jose.JSONWebKey, claims customClaims) string {
+		token, err := SignJWT(&key, claims)
+		require.NoError(t, err)
+		return token
+	}
+	valid := customClaims{
+		Claims: jwt.Claims{
+			Issuer:   "https://issuer.example.com",
+			Audience: jwt.Audience{"api"},
+			Subject:  "user",
+			Expiry:   jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+		Tenant: "acme",
+	}
+
+	v := &JWTVerifier{
+		Keys: &keys,
+		Policy: ClaimsPolicy{
+			Issuer:         "https://issuer.example.com",
+			Audience:       "api",
+			Leeway:         10 * time.Second,
+			RequiredClaims: []string{"exp", "tenant"},
+			Validate: func(claims map[string]interface{}) error {
+				if claims["tenant"] == "blocked" {
+					return errors.New("the tenant is blocked")
+				}
+				return nil
+			},
+		},
+		Now: func() time.Time { return now },
+	}
+
+	var decoded customClaims
+	require.NoError(t, v.Verify(sign(t, key, valid), &decoded))
+	assert.Equal(t, "acme", decoded.Tenant)
+	assert.Equal(t, "user", decoded.Subject)
+
+	withClaims := func(f func(c *customClaims)) customClaims {
+		c := valid
+		f(&c)
+		return c
+	}
+	for k, tc := range []struct {
+		token    string
+		expected error
+	}{
+		{token: "not.a.token", expected: ErrInvalidToken},
+		{token: sign(t, other, valid), expected: ErrInvalidSignature},
+		{token: sign(t, key, withClaims(func(c *customClaims) { c.Issuer = "https://evil.example.com" })), expected: ErrInvalidClaims},
+		{token: sign(t, key, withClaims(func(c *customClaims) { c.Audience = jwt.Audience{"other"} })), expected: ErrInvalidClaims},
+		{token: sign(t, key, withClaims(func(c *customClaims) { c.Expiry = jwt.NewNumericDate(now.Add(-time.Minute)) })), expected: ErrInvalidClaims},
+		{token: sign(t, key, withClaims(func(c *customClaims) { c.Tenant = "" })), expected: ErrInvalidClaims},
+		{token: sign(t, key, withClaims(func(c *customClaims) { c.Tenant = "blocked" })), expected: ErrInvalidClaims},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			err := v.Verify(tc.token)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tc.expected), "%+v", err)
+		})
+	}
+
+	t.Run("case=leeway", func(t *testing.T) {
+		token := sign(t, key, withClaims(func(c *customClaims) { c.Expiry = jwt.NewNumericDate(now.Add(-5 * time.Second)) }))
+		require.NoError(t, v.Verify(token))
+	})
+
+	t.Run("case=unknown key", func(t *testing.T) {
+		err := v.Verify(sign(t, newKey(t, "unknown"), valid))
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+}
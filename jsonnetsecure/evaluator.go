@@ -0,0 +1,495 @@
+// Package jsonnetsecure evaluates untrusted Jsonnet snippets, such as data mappings, with
+// bounded concurrency and resource limits.
+package jsonnetsecure
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// DefaultTimeout is the default maximum duration of an evaluation.
+	DefaultTimeout = time.Second
+	// DefaultMaxStack is the default maximum stack depth of an evaluation.
+	DefaultMaxStack = 500
+	// DefaultMaxOutputSize is the default maximum size of the output of an evaluation in bytes.
+	DefaultMaxOutputSize = 1 << 20
+	// DefaultMemoryLimit is the default maximum memory of a worker process in bytes.
+	DefaultMemoryLimit = 1 << 30
+	// DefaultCacheSize is the default number of parsed snippets which every worker caches.
+	DefaultCacheSize = 256
+)
+
+var (
+	// ErrTimeout is returned if an evaluation takes longer than the timeout.
+	ErrTimeout = errors.New("the jsonnet evaluation timed out")
+	// ErrOutputTooLarge is returned if the output of an evaluation is larger than the limit.
+	ErrOutputTooLarge = errors.New("the output of the jsonnet evaluation is too large")
+	// ErrImportDenied is returned if a snippet imports a file.
+	ErrImportDenied = errors.New("imports are not allowed in jsonnet snippets")
+	// ErrWorkerExited is returned if the worker process exited during an evaluation, usually
+	// because the evaluation exceeded the memory limit.
+	ErrWorkerExited = errors.New("the jsonnet worker process exited during the evaluation")
+	// ErrClosed is returned by Evaluate after the Evaluator was closed.
+	ErrClosed = errors.New("the jsonnet evaluator is closed")
+)
+
+type (
+	// Evaluator evaluates Jsonnet snippets in a pool of worker processes. It is safe for
+	// concurrent use.
+	Evaluator struct {
+		command       []string
+		slots         chan struct{}
+		idle          chan *worker
+		timeout       time.Duration
+		maxStack      int
+		maxOutputSize int
+		memoryLimit   int
+		cacheSize     int
+		metrics       *metrics
+
+		mu     sync.Mutex
+		closed bool
+	}
+
+	// Option configures an Evaluator.
+	Option func(e *Evaluator)
+
+	// EvaluateOption configures a single evaluation.
+	EvaluateOption func(r *request)
+
+	metrics struct {
+		evaluations  *prometheus.CounterVec
+		duration     prometheus.Histogram
+		cache        *prometheus.CounterVec
+		inFlight     prometheus.Gauge
+		workerStarts prometheus.Counter
+	}
+)
+
+// WithWorkers sets the maximum number of concurrent evaluations, which is also the maximum
+// number of worker processes. Further evaluations wait for a free worker until their context
+// is done. Defaults to the number of CPUs.
+func WithWorkers(n int) Option {
+	return func(e *Evaluator) {
+		e.slots = make(chan struct{}, n)
+		e.idle = make(chan *worker, n)
+	}
+}
+
+// WithWorkerCommand sets the command which starts a worker process. The command must call
+// ServeWorker, usually by registering NewWorkerCmd with the root command of the application.
+// Defaults to the current executable with the argument "jsonnet-worker".
+func WithWorkerCommand(name string, args ...string) Option {
+	return func(e *Evaluator) {
+		e.command = append([]string{name}, args...)
+	}
+}
+
+// WithTimeout sets the maximum duration of an evaluation, including the time spent waiting for
+// a worker. Defaults to DefaultTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(e *Evaluator) {
+		e.timeout = d
+	}
+}
+
+// WithMaxStack sets the maximum stack depth, which bounds recursion. Defaults to
+// DefaultMaxStack.
+func WithMaxStack(n int) Option {
+	return func(e *Evaluator) {
+		e.maxStack = n
+	}
+}
+
+// WithMaxOutputSize sets the maximum size of the output in bytes. Defaults to
+// DefaultMaxOutputSize.
+func WithMaxOutputSize(n int) Option {
+	return func(e *Evaluator) {
+		e.maxOutputSize = n
+	}
+}
+
+// WithMemoryLimit sets the maximum memory of a worker process in bytes. A worker which exceeds
+// it is terminated by the Go runtime and its evaluation fails with ErrWorkerExited. The limit is
+// enforced with RLIMIT_DATA on Linux, and not at all on other systems or in binaries built with
+// the race detector, whose shadow memory would exceed the limit. Defaults to DefaultMemoryLimit.
+func WithMemoryLimit(n int) Option {
+	return func(e *Evaluator) {
+		e.memoryLimit = n
+	}
+}
+
+// WithCacheSize sets the number of parsed snippets which every worker caches, so that snippets
+// which are evaluated repeatedly are only parsed once. Set it to zero to disable the cache.
+// Defaults to DefaultCacheSize.
+func WithCacheSize(n int) Option {
+	return func(e *Evaluator) {
+		e.cacheSize = n
+	}
+}
+
+// WithMetrics registers the following metrics with r:
+//
+//	jsonnet_evaluations_total{result="success|error|timeout"}
+//	jsonnet_evaluation_duration_seconds
+//	jsonnet_cache_requests_total{result="hit|miss"}
+//	jsonnet_evaluations_in_flight
+//	jsonnet_worker_starts_total
+func WithMetrics(r prometheus.Registerer) Option {
+	return func(e *Evaluator) {
+		m := &metrics{
+			evaluations: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "jsonnet_evaluations_total",
+				Help: "Number of jsonnet evaluations by result.",
+			}, []string{"result"}),
+			duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+				Name:    "jsonnet_evaluation_duration_seconds",
+				Help:    "Duration of jsonnet evaluations, including the time spent waiting for a worker.",
+				Buckets: prometheus.ExponentialBuckets(0.0005, 4, 8),
+			}),
+			cache: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "jsonnet_cache_requests_total",
+				Help: "Number of lookups of parsed jsonnet snippets by result.",
+			}, []string{"result"}),
+			inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+				Name: "jsonnet_evaluations_in_flight",
+				Help: "Number of jsonnet evaluations which are currently running.",
+			}),
+			workerStarts: prometheus.NewCounter(prometheus.CounterOpts{
+				Name: "jsonnet_worker_starts_total",
+				Help: "Number of started jsonnet worker processes.",
+			}),
+		}
+		r.MustRegister(m.evaluations, m.duration, m.cache, m.inFlight, m.workerStarts)
+		e.metrics = m
+	}
+}
+
+// WithExtVar sets the external variable key to the string value.
+func WithExtVar(key, value string) EvaluateOption {
+	return func(r *request) {
+		r.ExtVars = setVar(r.ExtVars, key, value)
+	}
+}
+
+// WithExtCode sets the external variable key to the Jsonnet code, for example a JSON document.
+func WithExtCode(key, code string) EvaluateOption {
+	return func(r *request) {
+		r.ExtCode = setVar(r.ExtCode, key, code)
+	}
+}
+
+// WithTLAVar sets the top-level argument key to the string value.
+func WithTLAVar(key, value string) EvaluateOption {
+	return func(r *request) {
+		r.TLAVars = setVar(r.TLAVars, key, value)
+	}
+}
+
+// WithTLACode sets the top-level argument key to the Jsonnet code, for example a JSON document.
+func WithTLACode(key, code string) EvaluateOption {
+	return func(r *request) {
+		r.TLACode = setVar(r.TLACode, key, code)
+	}
+}
+
+func setVar(vars map[string]string, key, value string) map[string]string {
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	vars[key] = value
+	return vars
+}
+
+// NewEvaluator returns an Evaluator.
+//
+// Snippets are evaluated in long-running worker processes, so that the limits are enforced
+// without forking a process per evaluation: a worker whose evaluation exceeds the timeout is
+// killed, which bounds CPU time and frees the worker immediately, and the memory of a worker is
+// bounded by the memory limit. The stack depth bounds recursion, and the output size bounds
+// the response. Imports and native functions are not available to snippets.
+//
+// Workers are started on demand and reused. Call Close to stop them.
+func NewEvaluator(opts ...Option) *Evaluator {
+	e := &Evaluator{
+		slots:         make(chan struct{}, runtime.NumCPU()),
+		idle:          make(chan *worker, runtime.NumCPU()),
+		timeout:       DefaultTimeout,
+		maxStack:      DefaultMaxStack,
+		maxOutputSize: DefaultMaxOutputSize,
+		memoryLimit:   DefaultMemoryLimit,
+		cacheSize:     DefaultCacheSize,
+	}
+	for _, o := range opts {
+		o(e)
+	}
+	return e
+}
+
+// Evaluate evaluates the snippet and returns the resulting JSON document. The name is used in
+// error messages. Every evaluation uses a new VM, so variables never leak between
+// evaluations.
+//
+// If the timeout is exceeded or the context is done, the worker is killed and Evaluate returns
+// immediately.
+func (e *Evaluator) Evaluate(ctx context.Context, name, snippet string, opts ...EvaluateOption) (_ string, err error) {
+	start := time.Now()
+	defer func() { e.observe(start, err) }()
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	req := &request{Name: name, Snippet: snippet}
+	for _, o := range opts {
+		o(req)
+	}
+
+	select {
+	case e.slots <- struct{}{}:
+	case <-ctx.Done():
+		return "", contextError(ctx)
+	}
+	defer func() { <-e.slots }()
+
+	if e.metrics != nil {
+		e.metrics.inFlight.Inc()
+		defer e.metrics.inFlight.Dec()
+	}
+
+	w, err := e.worker(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var res response
+	if err := w.roundTrip(ctx, req, &res); err != nil {
+		return "", err
+	}
+	e.release(w)
+
+	if res.Cached {
+		e.cacheLookup("hit")
+	} else {
+		e.cacheLookup("miss")
+	}
+	return res.result()
+}
+
+// Close stops the idle workers. Evaluations which are still running are not interrupted, but
+// their workers are stopped when they finish.
+func (e *Evaluator) Close() error {
+	e.mu.Lock()
+	e.closed = true
+	e.mu.Unlock()
+
+	for {
+		select {
+		case w := <-e.idle:
+			w.kill()
+		default:
+			return nil
+		}
+	}
+}
+
+// worker returns an idle worker or starts a new one.
+func (e *Evaluator) worker(ctx context.Context) (*worker, error) {
+	e.mu.Lock()
+	closed := e.closed
+	e.mu.Unlock()
+	if closed {
+		return nil, errors.WithStack(ErrClosed)
+	}
+
+	select {
+	case w := <-e.idle:
+		return w, nil
+	default:
+	}
+
+	command := e.command
+	if len(command) == 0 {
+		executable, err := os.Executable()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		command = []string{executable, WorkerCommandName}
+	}
+
+	w, err := startWorker(ctx, command, &workerConfig{
+		MaxStack:      e.maxStack,
+		MaxOutputSize: e.maxOutputSize,
+		MemoryLimit:   e.memoryLimit,
+		CacheSize:     e.cacheSize,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if e.metrics != nil {
+		e.metrics.workerStarts.Inc()
+	}
+	return w, nil
+}
+
+// release returns the worker to the pool.
+func (e *Evaluator) release(w *worker) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		w.kill()
+		return
+	}
+	select {
+	case e.idle <- w:
+	default:
+		w.kill()
+	}
+}
+
+func (e *Evaluator) cacheLookup(result string) {
+	if e.metrics != nil {
+		e.metrics.cache.WithLabelValues(result).Inc()
+	}
+}
+
+func (e *Evaluator) observe(start time.Time, err error) {
+	if e.metrics == nil {
+		return
+	}
+	result := "success"
+	if errors.Is(err, ErrTimeout) {
+		result = "timeout"
+	} else if err != nil {
+		result = "error"
+	}
+	e.metrics.evaluations.WithLabelValues(result).Inc()
+	e.metrics.duration.Observe(time.Since(start).Seconds())
+}
+
+func contextError(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return errors.WithStack(ErrTimeout)
+	}
+	return errors.WithStack(ctx.Err())
+}
+
+// worker is a worker process.
+type worker struct {
+	cmd           *exec.Cmd
+	stdin         io.WriteCloser
+	stdout        io.ReadCloser
+	stderr        *limitedBuffer
+	maxOutputSize int
+	killOnce      sync.Once
+}
+
+func startWorker(ctx context.Context, command []string, config *workerConfig) (*worker, error) {
+	w := &worker{
+		cmd:           exec.Command(command[0], command[1:]...),
+		stderr:        &limitedBuffer{limit: 4096},
+		maxOutputSize: config.MaxOutputSize,
+	}
+	w.cmd.Stderr = w.stderr
+
+	var err error
+	if w.stdin, err = w.cmd.StdinPipe(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if w.stdout, err = w.cmd.StdoutPipe(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := w.cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "unable to start the jsonnet worker process")
+	}
+
+	var ack response
+	if err := w.roundTrip(ctx, config, &ack); err != nil {
+		return nil, err
+	}
+	if ack.Error != "" {
+		w.kill()
+		return nil, errors.Errorf("unable to configure the jsonnet worker process: %s", ack.Error)
+	}
+	return w, nil
+}
+
+// roundTrip sends the message to the worker and reads its response. If the context is done or
+// the worker fails, the worker is killed.
+func (w *worker) roundTrip(ctx context.Context, msg, res interface{}) error {
+	done := make(chan error, 1)
+	go func() {
+		if err := writeFrame(w.stdin, msg); err != nil {
+			done <- err
+			return
+		}
+		// JSON escaping at most sextuples the output, and the rest of the response is small
+		done <- readFrame(w.stdout, res, 6*w.maxOutputSize+1<<16)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			w.kill()
+			return w.exitError(err)
+		}
+		return nil
+	case <-ctx.Done():
+		w.kill()
+		<-done
+		return contextError(ctx)
+	}
+}
+
+func (w *worker) kill() {
+	w.killOnce.Do(func() {
+		_ = w.stdin.Close()
+		_ = w.cmd.Process.Kill()
+		_ = w.cmd.Wait()
+	})
+}
+
+// exitError returns ErrWorkerExited with the first line the worker wrote to stderr, for example
+// "fatal error: runtime: out of memory".
+func (w *worker) exitError(err error) error {
+	if reason := strings.SplitN(strings.TrimSpace(w.stderr.String()), "\n", 2)[0]; reason != "" {
+		return errors.Wrap(ErrWorkerExited, reason)
+	}
+	return errors.Wrap(ErrWorkerExited, err.Error())
+}
+
+// limitedBuffer keeps the first bytes written to it and discards the rest.
+type limitedBuffer struct {
+	mu    sync.Mutex
+	buf   bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+		} else {
+			b.buf.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
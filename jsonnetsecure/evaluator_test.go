@@ -0,0 +1,199 @@
+package jsonnetsecure
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	// the evaluators of the tests start this test binary as their workers
+	if len(os.Args) > 1 && os.Args[1] == WorkerCommandName {
+		if err := ServeWorker(os.Stdin, os.Stdout); err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func newEvaluator(t *testing.T, opts ...Option) *Evaluator {
+	e := NewEvaluator(opts...)
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+const mapping = `function(ctx) { email: ctx.identity.traits.email, source: std.extVar("source") }`
+
+func TestEvaluator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("case=evaluates with variables", func(t *testing.T) {
+		e := newEvaluator(t)
+		out, err := e.Evaluate(ctx, "mapping.jsonnet", mapping,
+			WithTLACode("ctx", `{"identity": {"traits": {"email": "foo@example.com"}}}`),
+			WithExtVar("source", "test"))
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"email": "foo@example.com", "source": "test"}`, out)
+
+		_, err = e.Evaluate(ctx, "mapping.jsonnet", mapping,
+			WithTLACode("ctx", `{"identity": {"traits": {"email": "foo@example.com"}}}`))
+		require.Error(t, err, "variables of previous evaluations must not leak")
+		assert.Contains(t, err.Error(), "source")
+	})
+
+	t.Run("case=syntax errors", func(t *testing.T) {
+		_, err := newEvaluator(t).Evaluate(ctx, "broken.jsonnet", `{`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "broken.jsonnet")
+	})
+
+	t.Run("case=imports are denied", func(t *testing.T) {
+		_, err := newEvaluator(t).Evaluate(ctx, "import.jsonnet", `import "/etc/passwd"`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ErrImportDenied.Error())
+	})
+
+	t.Run("case=stack depth is limited", func(t *testing.T) {
+		_, err := newEvaluator(t, WithMaxStack(20)).Evaluate(ctx, "recursion.jsonnet", `local f(n) = if n == 0 then 0 else 1 + f(n - 1); f(100)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "max stack frames exceeded")
+	})
+
+	t.Run("case=output size is limited", func(t *testing.T) {
+		_, err := newEvaluator(t, WithMaxOutputSize(10)).Evaluate(ctx, "large.jsonnet", `std.makeArray(100, function(i) i)`)
+		assert.True(t, errors.Is(err, ErrOutputTooLarge), "%+v", err)
+	})
+
+	t.Run("case=timeout", func(t *testing.T) {
+		e := newEvaluator(t, WithTimeout(500*time.Millisecond), WithWorkers(1))
+		start := time.Now()
+		_, err := e.Evaluate(ctx, "slow.jsonnet", `local f(n, acc) = if n == 0 then acc else f(n - 1, acc + 1) tailstrict; f(1e9, 0)`)
+		assert.True(t, errors.Is(err, ErrTimeout), "%+v", err)
+		assert.Less(t, int64(time.Since(start)), int64(2*time.Second))
+
+		// The worker of the slow evaluation was killed, so the only slot is free again.
+		out, err := e.Evaluate(ctx, "fast.jsonnet", `1`)
+		require.NoError(t, err)
+		assert.Equal(t, "1\n", out)
+	})
+
+	t.Run("case=memory is limited", func(t *testing.T) {
+		if runtime.GOOS != "linux" {
+			t.Skip("the memory limit is only tested on Linux")
+		}
+		if raceEnabled {
+			t.Skip("the memory limit is not set with the race detector")
+		}
+		e := newEvaluator(t, WithMemoryLimit(256<<20), WithTimeout(time.Minute), WithWorkers(1))
+		_, err := e.Evaluate(ctx, "hungry.jsonnet", `std.length(std.makeArray(50000000, function(i) "abc" + i))`)
+		require.True(t, errors.Is(err, ErrWorkerExited), "%+v", err)
+
+		out, err := e.Evaluate(ctx, "fast.jsonnet", `1`)
+		require.NoError(t, err)
+		assert.Equal(t, "1\n", out)
+	})
+
+	t.Run("case=invalid worker command", func(t *testing.T) {
+		// runs no tests and exits without serving
+		_, err := newEvaluator(t, WithWorkerCommand(os.Args[0], "-test.run=^$")).Evaluate(ctx, "one.jsonnet", `1`)
+		require.True(t, errors.Is(err, ErrWorkerExited), "%+v", err)
+	})
+
+	t.Run("case=closed", func(t *testing.T) {
+		e := NewEvaluator()
+		_, err := e.Evaluate(ctx, "one.jsonnet", `1`)
+		require.NoError(t, err)
+		require.NoError(t, e.Close())
+
+		_, err = e.Evaluate(ctx, "one.jsonnet", `1`)
+		assert.True(t, errors.Is(err, ErrClosed), "%+v", err)
+	})
+
+	t.Run("case=cancelled context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := newEvaluator(t).Evaluate(ctx, "cancelled.jsonnet", `1`)
+		assert.True(t, errors.Is(err, context.Canceled), "%+v", err)
+	})
+
+	t.Run("case=concurrent evaluations", func(t *testing.T) {
+		e := newEvaluator(t, WithWorkers(2))
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				out, err := e.Evaluate(ctx, "mapping.jsonnet", mapping,
+					WithTLACode("ctx", `{"identity": {"traits": {"email": "foo@example.com"}}}`),
+					WithExtVar("source", "concurrent"))
+				assert.NoError(t, err)
+				assert.JSONEq(t, `{"email": "foo@example.com", "source": "concurrent"}`, out)
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestEvaluatorMetrics(t *testing.T) {
+	ctx := context.Background()
+	reg := prometheus.NewPedanticRegistry()
+	e := newEvaluator(t, WithMetrics(reg))
+
+	for i := 0; i < 3; i++ {
+		_, err := e.Evaluate(ctx, "one.jsonnet", `1 + 1`)
+		require.NoError(t, err)
+	}
+	_, err := e.Evaluate(ctx, "error.jsonnet", `error "failed"`)
+	require.Error(t, err)
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP jsonnet_cache_requests_total Number of lookups of parsed jsonnet snippets by result.
+# TYPE jsonnet_cache_requests_total counter
+jsonnet_cache_requests_total{result="hit"} 2
+jsonnet_cache_requests_total{result="miss"} 2
+# HELP jsonnet_evaluations_in_flight Number of jsonnet evaluations which are currently running.
+# TYPE jsonnet_evaluations_in_flight gauge
+jsonnet_evaluations_in_flight 0
+# HELP jsonnet_evaluations_total Number of jsonnet evaluations by result.
+# TYPE jsonnet_evaluations_total counter
+jsonnet_evaluations_total{result="error"} 1
+jsonnet_evaluations_total{result="success"} 3
+# HELP jsonnet_worker_starts_total Number of started jsonnet worker processes.
+# TYPE jsonnet_worker_starts_total counter
+jsonnet_worker_starts_total 1
+`), "jsonnet_cache_requests_total", "jsonnet_evaluations_in_flight", "jsonnet_evaluations_total", "jsonnet_worker_starts_total"))
+}
+
+func TestASTCache(t *testing.T) {
+	c := newASTCache(2)
+	keys := [][32]byte{{1}, {2}, {3}}
+	for _, k := range keys[:2] {
+		c.add(k, nil)
+	}
+	_, ok := c.get(keys[0])
+	require.True(t, ok)
+	c.add(keys[2], nil)
+
+	_, ok = c.get(keys[1])
+	assert.False(t, ok, "the least recently used entry is evicted")
+	_, ok = c.get(keys[0])
+	assert.True(t, ok)
+
+	disabled := newASTCache(0)
+	disabled.add(keys[0], nil)
+	_, ok = disabled.get(keys[0])
+	assert.False(t, ok)
+}
@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package jsonnetsecure
+
+// raceEnabled is true if the race detector is enabled. Its shadow memory counts against
+// RLIMIT_DATA, so worker processes would exceed any realistic memory limit.
+const raceEnabled = true
@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package jsonnetsecure
+
+import (
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// setMemoryLimit limits the data segment and the private writable mappings of the process,
+// which is the memory the Go runtime allocates. Unlike RLIMIT_AS, RLIMIT_DATA does not count
+// the address space the Go runtime reserves without using it. The limit is not set in binaries
+// built with the race detector.
+func setMemoryLimit(limit int) error {
+	if limit <= 0 || raceEnabled {
+		return nil
+	}
+	l := uint64(limit)
+	return errors.Wrap(syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: l, Max: l}), "unable to limit the memory of the jsonnet worker process")
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package jsonnetsecure
+
+// setMemoryLimit does nothing, because other systems do not limit the memory of a process with
+// RLIMIT_DATA.
+func setMemoryLimit(int) error {
+	return nil
+}
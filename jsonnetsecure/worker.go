@@ -0,0 +1,258 @@
+package jsonnetsecure
+
+import (
+	"bufio"
+	"container/list"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+// WorkerCommandName is the name of the command returned by NewWorkerCmd.
+const WorkerCommandName = "jsonnet-worker"
+
+const errorKindOutputTooLarge = "output_too_large"
+
+type (
+	// workerConfig is the first message the evaluator sends to a worker.
+	workerConfig struct {
+		MaxStack      int `json:"max_stack"`
+		MaxOutputSize int `json:"max_output_size"`
+		MemoryLimit   int `json:"memory_limit"`
+		CacheSize     int `json:"cache_size"`
+	}
+
+	// request is an evaluation the evaluator sends to a worker.
+	request struct {
+		Name    string            `json:"name"`
+		Snippet string            `json:"snippet"`
+		ExtVars map[string]string `json:"ext_vars,omitempty"`
+		ExtCode map[string]string `json:"ext_code,omitempty"`
+		TLAVars map[string]string `json:"tla_vars,omitempty"`
+		TLACode map[string]string `json:"tla_code,omitempty"`
+	}
+
+	// response is the result of an evaluation the worker sends to the evaluator. The worker also
+	// acknowledges its configuration with an empty response.
+	response struct {
+		Output    string `json:"output,omitempty"`
+		Error     string `json:"error,omitempty"`
+		ErrorKind string `json:"error_kind,omitempty"`
+		Cached    bool   `json:"cached,omitempty"`
+	}
+)
+
+// NewWorkerCmd returns the hidden command which runs a worker process of the Evaluator. Register
+// it with the root command of the application, so that the evaluator can start workers with
+// the default worker command:
+//
+//	rootCmd.AddCommand(jsonnetsecure.NewWorkerCmd())
+//
+// The command must not write anything else to stdout, so it should not run persistent hooks of
+// parent commands which do.
+func NewWorkerCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    WorkerCommandName,
+		Short:  "Evaluates jsonnet snippets sent by the parent process",
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return ServeWorker(cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+}
+
+// ServeWorker runs a worker process of the Evaluator, which reads evaluations from r and writes
+// their results to w until r is closed. Usually, it is run by the command returned by
+// NewWorkerCmd.
+func ServeWorker(r io.Reader, w io.Writer) error {
+	in, out := bufio.NewReader(r), bufio.NewWriter(w)
+
+	var config workerConfig
+	if err := readFrame(in, &config, -1); err != nil {
+		return err
+	}
+	var ack response
+	if err := setMemoryLimit(config.MemoryLimit); err != nil {
+		ack.Error = err.Error()
+	}
+	if err := writeFrame(out, &ack); err != nil {
+		return err
+	}
+	if ack.Error != "" {
+		return errors.New(ack.Error)
+	}
+
+	s := &workerState{config: config, cache: newASTCache(config.CacheSize)}
+	for {
+		var req request
+		if err := readFrame(in, &req, -1); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := writeFrame(out, s.evaluate(&req)); err != nil {
+			return err
+		}
+	}
+}
+
+type workerState struct {
+	config workerConfig
+	cache  *astCache
+}
+
+func (s *workerState) evaluate(req *request) *response {
+	node, cached, err := s.parse(req.Name, req.Snippet)
+	if err != nil {
+		return &response{Error: err.Error()}
+	}
+
+	vm := jsonnet.MakeVM()
+	vm.MaxStack = s.config.MaxStack
+	vm.Importer(denyImporter{})
+	for k, v := range req.ExtVars {
+		vm.ExtVar(k, v)
+	}
+	for k, v := range req.ExtCode {
+		vm.ExtCode(k, v)
+	}
+	for k, v := range req.TLAVars {
+		vm.TLAVar(k, v)
+	}
+	for k, v := range req.TLACode {
+		vm.TLACode(k, v)
+	}
+
+	out, err := vm.Evaluate(node)
+	if err != nil {
+		return &response{Error: vm.ErrorFormatter.Format(err), Cached: cached}
+	}
+	if len(out) > s.config.MaxOutputSize {
+		return &response{
+			Error:     fmt.Sprintf("the output has %d bytes but at most %d bytes are allowed", len(out), s.config.MaxOutputSize),
+			ErrorKind: errorKindOutputTooLarge,
+			Cached:    cached,
+		}
+	}
+	return &response{Output: out, Cached: cached}
+}
+
+func (s *workerState) parse(name, snippet string) (ast.Node, bool, error) {
+	key := sha256.Sum256([]byte(name + "\x00" + snippet))
+	if node, ok := s.cache.get(key); ok {
+		return node, true, nil
+	}
+
+	node, err := jsonnet.SnippetToAST(name, snippet)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+	s.cache.add(key, node)
+	return node, false, nil
+}
+
+func (r *response) result() (string, error) {
+	switch {
+	case r.ErrorKind == errorKindOutputTooLarge:
+		return "", errors.Wrap(ErrOutputTooLarge, r.Error)
+	case r.Error != "":
+		return "", errors.New(r.Error)
+	}
+	return r.Output, nil
+}
+
+// writeFrame writes the message as JSON prefixed with its length.
+func writeFrame(w io.Writer, msg interface{}) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(payload)))
+	if _, err := w.Write(append(size[:], payload...)); err != nil {
+		return errors.WithStack(err)
+	}
+	if f, ok := w.(interface{ Flush() error }); ok {
+		return errors.WithStack(f.Flush())
+	}
+	return nil
+}
+
+// readFrame reads a message written by writeFrame. If limit is not negative, larger messages
+// are rejected before they are read.
+func readFrame(r io.Reader, msg interface{}, limit int) error {
+	var size [4]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return errors.WithStack(err)
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if limit >= 0 && int64(n) > int64(limit) {
+		return errors.Errorf("the message has %d bytes but at most %d bytes are allowed", n, limit)
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(json.Unmarshal(payload, msg))
+}
+
+type denyImporter struct{}
+
+func (denyImporter) Import(_, importedPath string) (jsonnet.Contents, string, error) {
+	return jsonnet.Contents{}, "", errors.Wrapf(ErrImportDenied, "unable to import %q", importedPath)
+}
+
+// astCache is a least recently used cache of parsed snippets.
+type astCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+}
+
+type astCacheEntry struct {
+	key  [sha256.Size]byte
+	node ast.Node
+}
+
+func newASTCache(size int) *astCache {
+	return &astCache{size: size, entries: map[[sha256.Size]byte]*list.Element{}, order: list.New()}
+}
+
+func (c *astCache) get(key [sha256.Size]byte) (ast.Node, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*astCacheEntry).node, true
+}
+
+func (c *astCache) add(key [sha256.Size]byte, node ast.Node) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		return
+	}
+	c.entries[key] = c.order.PushFront(&astCacheEntry{key: key, node: node})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*astCacheEntry).key)
+	}
+}
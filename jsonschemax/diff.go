@@ -0,0 +1,401 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/jsonschema/v3"
+
+	"github.com/ory/x/stringslice"
+)
+
+// ChangeKind is the kind of a schema change.
+type ChangeKind string
+
+const (
+	// ChangePropertyAdded means that a property was added.
+	ChangePropertyAdded ChangeKind = "property_added"
+	// ChangePropertyRemoved means that a property was removed. This is a breaking change.
+	ChangePropertyRemoved ChangeKind = "property_removed"
+	// ChangeRequiredAdded means that a property became required. This is a breaking change.
+	ChangeRequiredAdded ChangeKind = "required_added"
+	// ChangeRequiredRemoved means that a property is no longer required.
+	ChangeRequiredRemoved ChangeKind = "required_removed"
+	// ChangeTypeNarrowed means that types are no longer allowed. This is a breaking change.
+	ChangeTypeNarrowed ChangeKind = "type_narrowed"
+	// ChangeTypeWidened means that additional types are allowed.
+	ChangeTypeWidened ChangeKind = "type_widened"
+	// ChangeEnumNarrowed means that values are no longer allowed. This is a breaking change.
+	ChangeEnumNarrowed ChangeKind = "enum_narrowed"
+	// ChangeEnumWidened means that additional values are allowed.
+	ChangeEnumWidened ChangeKind = "enum_widened"
+	// ChangeConstraintTightened means that a constraint such as maxLength or minimum rejects
+	// values it accepted before. This is a breaking change.
+	ChangeConstraintTightened ChangeKind = "constraint_tightened"
+	// ChangeConstraintRelaxed means that a constraint accepts values it rejected before.
+	ChangeConstraintRelaxed ChangeKind = "constraint_relaxed"
+	// ChangeAdditionalPropertiesDenied means that properties which are not defined in the
+	// schema are no longer allowed. This is a breaking change.
+	ChangeAdditionalPropertiesDenied ChangeKind = "additional_properties_denied"
+)
+
+// ErrBreakingChanges is returned by CheckCompatibility if the new schema has breaking changes.
+var ErrBreakingChanges = errors.New("the schema has breaking changes")
+
+// Change is a difference between two JSON Schemas.
+type Change struct {
+	// Path is the changed path in dot-notation, see ListPathsWithArraysIncluded. It is empty for
+	// the root.
+	Path string `json:"path"`
+
+	// Kind is the kind of the change.
+	Kind ChangeKind `json:"kind"`
+
+	// Breaking is true if documents which are valid for the old schema may be invalid for the
+	// new schema.
+	Breaking bool `json:"breaking"`
+
+	// Message describes the change.
+	Message string `json:"message"`
+}
+
+func (c Change) String() string {
+	path := c.Path
+	if path == "" {
+		path = "(root)"
+	}
+	return fmt.Sprintf("%s: %s", path, c.Message)
+}
+
+// CompareSchemasBytes works like CompareSchemas but compiles the JSON Schemas itself.
+func CompareSchemasBytes(old, new json.RawMessage) ([]Change, error) {
+	oldSchema, err := compileBytes(old)
+	if err != nil {
+		return nil, err
+	}
+	newSchema, err := compileBytes(new)
+	if err != nil {
+		return nil, err
+	}
+	return CompareSchemas(oldSchema, newSchema), nil
+}
+
+// CompareSchemas returns the differences between the old and the new JSON Schema, sorted by
+// path. References and allOf are resolved, so moving a property into a definition is not a
+// change. Changes inside anyOf, oneOf and conditional schemas are not detected.
+func CompareSchemas(old, new *jsonschema.Schema) []Change {
+	d := &schemaDiff{visited: map[[2]*jsonschema.Schema]bool{}}
+	d.compare(nil, old, new)
+
+	sort.SliceStable(d.changes, func(i, j int) bool {
+		return d.changes[i].Path < d.changes[j].Path
+	})
+	return d.changes
+}
+
+// BreakingChanges returns the breaking changes.
+func BreakingChanges(changes []Change) []Change {
+	var breaking []Change
+	for _, c := range changes {
+		if c.Breaking {
+			breaking = append(breaking, c)
+		}
+	}
+	return breaking
+}
+
+// CheckCompatibility returns an error wrapping ErrBreakingChanges which lists all breaking
+// changes if documents which are valid for the old JSON Schema may be invalid for the new one.
+// Use it to gate changes of configuration or API schemas, for example in a test:
+//
+//	require.NoError(t, jsonschemax.CheckCompatibility(released, current))
+func CheckCompatibility(old, new json.RawMessage) error {
+	changes, err := CompareSchemasBytes(old, new)
+	if err != nil {
+		return err
+	}
+
+	breaking := BreakingChanges(changes)
+	if len(breaking) == 0 {
+		return nil
+	}
+	messages := make([]string, len(breaking))
+	for i, c := range breaking {
+		messages[i] = c.String()
+	}
+	return errors.Wrap(ErrBreakingChanges, strings.Join(messages, "; "))
+}
+
+func compileBytes(raw json.RawMessage) (*jsonschema.Schema, error) {
+	compiler := jsonschema.NewCompiler()
+	id := fmt.Sprintf("%x.json", sha256.Sum256(raw))
+	if err := compiler.AddResource(id, bytes.NewReader(raw)); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	schema, err := compiler.Compile(id)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return schema, nil
+}
+
+type schemaDiff struct {
+	changes []Change
+	visited map[[2]*jsonschema.Schema]bool
+}
+
+func (d *schemaDiff) add(path []string, kind ChangeKind, breaking bool, format string, args ...interface{}) {
+	d.changes = append(d.changes, Change{
+		Path:     strings.Join(path, "."),
+		Kind:     kind,
+		Breaking: breaking,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// flatSchema merges a schema with its references and allOf sub-schemas.
+type flatSchema struct {
+	*jsonschema.Schema
+	types      []string
+	properties map[string]*jsonschema.Schema
+	required   map[string]bool
+	denyOthers bool
+}
+
+func flatten(s *jsonschema.Schema) *flatSchema {
+	for s.Ref != nil {
+		s = s.Ref
+	}
+	f := &flatSchema{Schema: s, properties: map[string]*jsonschema.Schema{}, required: map[string]bool{}}
+	f.merge(s, map[*jsonschema.Schema]bool{})
+	return f
+}
+
+func (f *flatSchema) merge(s *jsonschema.Schema, seen map[*jsonschema.Schema]bool) {
+	for s.Ref != nil {
+		s = s.Ref
+	}
+	if seen[s] {
+		return
+	}
+	seen[s] = true
+
+	if len(f.types) == 0 {
+		f.types = s.Types
+	}
+	for name, sub := range s.Properties {
+		if _, ok := f.properties[name]; !ok {
+			f.properties[name] = sub
+		}
+	}
+	for _, name := range s.Required {
+		f.required[name] = true
+	}
+	if b, ok := s.AdditionalProperties.(bool); ok && !b {
+		f.denyOthers = true
+	}
+	for _, sub := range s.AllOf {
+		f.merge(sub, seen)
+	}
+}
+
+func (d *schemaDiff) compare(path []string, oldSchema, newSchema *jsonschema.Schema) {
+	key := [2]*jsonschema.Schema{oldSchema, newSchema}
+	if d.visited[key] {
+		return
+	}
+	d.visited[key] = true
+
+	o, n := flatten(oldSchema), flatten(newSchema)
+	d.compareTypes(path, o.types, n.types)
+	d.compareEnums(path, o.Enum, n.Enum)
+	d.compareConstraints(path, o.Schema, n.Schema)
+
+	if !o.denyOthers && n.denyOthers {
+		d.add(path, ChangeAdditionalPropertiesDenied, true, "additional properties are no longer allowed")
+	}
+
+	for _, name := range sortedKeys(o.properties) {
+		if _, ok := n.properties[name]; !ok {
+			d.add(appendPath(path, name), ChangePropertyRemoved, true, "the property was removed")
+		}
+	}
+	for _, name := range sortedKeys(n.properties) {
+		sub := appendPath(path, name)
+		if oldSub, ok := o.properties[name]; ok {
+			d.compare(sub, oldSub, n.properties[name])
+		} else {
+			d.add(sub, ChangePropertyAdded, false, "the property was added")
+		}
+		if n.required[name] && !o.required[name] {
+			d.add(sub, ChangeRequiredAdded, true, "the property is now required")
+		} else if !n.required[name] && o.required[name] {
+			d.add(sub, ChangeRequiredRemoved, false, "the property is no longer required")
+		}
+	}
+
+	if oldItems, ok := o.Items.(*jsonschema.Schema); ok {
+		if newItems, ok := n.Items.(*jsonschema.Schema); ok {
+			d.compare(appendPath(path, "#"), oldItems, newItems)
+		}
+	}
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), name)
+}
+
+func sortedKeys(m map[string]*jsonschema.Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// coversType returns true if a value of type t is valid for the types.
+func coversType(types []string, t string) bool {
+	return len(types) == 0 || stringslice.Has(types, t) || (t == "integer" && stringslice.Has(types, "number"))
+}
+
+func (d *schemaDiff) compareTypes(path []string, o, n []string) {
+	var removed, added []string
+	if len(o) == 0 {
+		if len(n) > 0 {
+			d.add(path, ChangeTypeNarrowed, true, "the type is now restricted to %s", strings.Join(n, ", "))
+		}
+		return
+	}
+	for _, t := range o {
+		if !coversType(n, t) {
+			removed = append(removed, t)
+		}
+	}
+	for _, t := range n {
+		if !coversType(o, t) {
+			added = append(added, t)
+		}
+	}
+	if len(removed) > 0 {
+		d.add(path, ChangeTypeNarrowed, true, "the types %s are no longer allowed", strings.Join(removed, ", "))
+	}
+	if len(added) > 0 {
+		d.add(path, ChangeTypeWidened, false, "the types %s are now allowed", strings.Join(added, ", "))
+	}
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, e := range values {
+		if reflect.DeepEqual(e, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *schemaDiff) compareEnums(path []string, o, n []interface{}) {
+	switch {
+	case o == nil && n == nil:
+		return
+	case o == nil:
+		d.add(path, ChangeEnumNarrowed, true, "the values are now restricted to %v", n)
+		return
+	case n == nil:
+		d.add(path, ChangeEnumWidened, false, "the values are no longer restricted")
+		return
+	}
+
+	var removed, added []interface{}
+	for _, v := range o {
+		if !containsValue(n, v) {
+			removed = append(removed, v)
+		}
+	}
+	for _, v := range n {
+		if !containsValue(o, v) {
+			added = append(added, v)
+		}
+	}
+	if len(removed) > 0 {
+		d.add(path, ChangeEnumNarrowed, true, "the values %v are no longer allowed", removed)
+	}
+	if len(added) > 0 {
+		d.add(path, ChangeEnumWidened, false, "the values %v are now allowed", added)
+	}
+}
+
+func (d *schemaDiff) compareConstraints(path []string, o, n *jsonschema.Schema) {
+	d.compareLowerBound(path, "minLength", o.MinLength, n.MinLength)
+	d.compareUpperBound(path, "maxLength", o.MaxLength, n.MaxLength)
+	d.compareLowerBound(path, "minItems", o.MinItems, n.MinItems)
+	d.compareUpperBound(path, "maxItems", o.MaxItems, n.MaxItems)
+	d.compareLowerBound(path, "minProperties", o.MinProperties, n.MinProperties)
+	d.compareUpperBound(path, "maxProperties", o.MaxProperties, n.MaxProperties)
+	d.compareFloatBound(path, "minimum", o.Minimum, n.Minimum, 1)
+	d.compareFloatBound(path, "exclusiveMinimum", o.ExclusiveMinimum, n.ExclusiveMinimum, 1)
+	d.compareFloatBound(path, "maximum", o.Maximum, n.Maximum, -1)
+	d.compareFloatBound(path, "exclusiveMaximum", o.ExclusiveMaximum, n.ExclusiveMaximum, -1)
+
+	oldPattern, newPattern := "", ""
+	if o.Pattern != nil {
+		oldPattern = o.Pattern.String()
+	}
+	if n.Pattern != nil {
+		newPattern = n.Pattern.String()
+	}
+	if oldPattern != newPattern {
+		// Whether a pattern accepts more or fewer values can not be decided in general.
+		d.add(path, ChangeConstraintTightened, newPattern != "", "the pattern changed from %q to %q", oldPattern, newPattern)
+	}
+}
+
+// compareLowerBound compares limits which are -1 if they are not specified.
+func (d *schemaDiff) compareLowerBound(path []string, keyword string, o, n int) {
+	if o < 0 {
+		o = 0
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > o {
+		d.add(path, ChangeConstraintTightened, true, "%s was raised from %d to %d", keyword, o, n)
+	} else if n < o {
+		d.add(path, ChangeConstraintRelaxed, false, "%s was lowered from %d to %d", keyword, o, n)
+	}
+}
+
+// compareUpperBound compares limits which are -1 if they are not specified.
+func (d *schemaDiff) compareUpperBound(path []string, keyword string, o, n int) {
+	switch {
+	case o == n:
+	case n >= 0 && (o < 0 || n < o):
+		d.add(path, ChangeConstraintTightened, true, "%s was lowered to %d", keyword, n)
+	default:
+		d.add(path, ChangeConstraintRelaxed, false, "%s was raised", keyword)
+	}
+}
+
+// compareFloatBound compares limits which are nil if they are not specified. The direction is 1
+// for lower bounds and -1 for upper bounds.
+func (d *schemaDiff) compareFloatBound(path []string, keyword string, o, n *big.Float, direction int) {
+	switch {
+	case o == nil && n == nil:
+	case o == nil:
+		d.add(path, ChangeConstraintTightened, true, "%s %s was added", keyword, n.String())
+	case n == nil:
+		d.add(path, ChangeConstraintRelaxed, false, "%s was removed", keyword)
+	case n.Cmp(o)*direction > 0:
+		d.add(path, ChangeConstraintTightened, true, "%s changed from %s to %s", keyword, o.String(), n.String())
+	case n.Cmp(o)*direction < 0:
+		d.add(path, ChangeConstraintRelaxed, false, "%s changed from %s to %s", keyword, o.String(), n.String())
+	}
+}
@@ -0,0 +1,133 @@
+package jsonschemax
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const diffOldSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "definitions": {
+    "port": {"type": "integer", "minimum": 1, "maximum": 65535}
+  },
+  "type": "object",
+  "properties": {
+    "dsn": {"type": "string"},
+    "log": {
+      "type": "object",
+      "properties": {
+        "level": {"type": "string", "enum": ["debug", "info", "error"]},
+        "format": {"type": "string"}
+      }
+    },
+    "serve": {
+      "type": "object",
+      "properties": {
+        "port": {"$ref": "#/definitions/port"},
+        "host": {"type": ["string", "null"], "maxLength": 255}
+      },
+      "required": ["port"]
+    },
+    "hooks": {
+      "type": "array",
+      "items": {"type": "object", "properties": {"url": {"type": "string"}}}
+    },
+    "retries": {"type": "number"}
+  }
+}`
+
+func TestCompareSchemas(t *testing.T) {
+	t.Run("case=identical schemas", func(t *testing.T) {
+		changes, err := CompareSchemasBytes([]byte(diffOldSchema), []byte(diffOldSchema))
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+		assert.NoError(t, CheckCompatibility([]byte(diffOldSchema), []byte(diffOldSchema)))
+	})
+
+	t.Run("case=moving a property into allOf is not a change", func(t *testing.T) {
+		changes, err := CompareSchemasBytes(
+			[]byte(`{"type": "object", "properties": {"a": {"type": "string"}, "b": {"type": "string"}}}`),
+			[]byte(`{"type": "object", "properties": {"a": {"type": "string"}}, "allOf": [{"properties": {"b": {"type": "string"}}}]}`))
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("case=changes", func(t *testing.T) {
+		newSchema := `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "dsn": {"type": "string", "minLength": 1},
+    "log": {
+      "type": "object",
+      "properties": {
+        "level": {"type": "string", "enum": ["info", "error", "warn"]},
+        "format": {"type": "string"},
+        "leak_sensitive_values": {"type": "boolean"}
+      }
+    },
+    "serve": {
+      "type": "object",
+      "properties": {
+        "port": {"type": "number", "minimum": 1024, "maximum": 65535},
+        "host": {"type": "string"}
+      },
+      "required": ["host"]
+    },
+    "hooks": {
+      "type": "array",
+      "items": {"type": "object", "properties": {"url": {"type": "string", "format": "uri"}, "method": {"type": "string"}}, "required": ["method"]}
+    },
+    "retries": {"type": "integer"}
+  },
+  "required": ["dsn"]
+}`
+		changes, err := CompareSchemasBytes([]byte(diffOldSchema), []byte(newSchema))
+		require.NoError(t, err)
+		assert.Equal(t, []Change{
+			{Path: "", Kind: ChangeAdditionalPropertiesDenied, Breaking: true, Message: "additional properties are no longer allowed"},
+			{Path: "dsn", Kind: ChangeConstraintTightened, Breaking: true, Message: "minLength was raised from 0 to 1"},
+			{Path: "dsn", Kind: ChangeRequiredAdded, Breaking: true, Message: "the property is now required"},
+			{Path: "hooks.#.method", Kind: ChangePropertyAdded, Message: "the property was added"},
+			{Path: "hooks.#.method", Kind: ChangeRequiredAdded, Breaking: true, Message: "the property is now required"},
+			{Path: "log.leak_sensitive_values", Kind: ChangePropertyAdded, Message: "the property was added"},
+			{Path: "log.level", Kind: ChangeEnumNarrowed, Breaking: true, Message: "the values [debug] are no longer allowed"},
+			{Path: "log.level", Kind: ChangeEnumWidened, Message: "the values [warn] are now allowed"},
+			{Path: "retries", Kind: ChangeTypeNarrowed, Breaking: true, Message: "the types number are no longer allowed"},
+			{Path: "serve.host", Kind: ChangeTypeNarrowed, Breaking: true, Message: "the types null are no longer allowed"},
+			{Path: "serve.host", Kind: ChangeConstraintRelaxed, Message: "maxLength was raised"},
+			{Path: "serve.host", Kind: ChangeRequiredAdded, Breaking: true, Message: "the property is now required"},
+			{Path: "serve.port", Kind: ChangeTypeWidened, Message: "the types number are now allowed"},
+			{Path: "serve.port", Kind: ChangeConstraintTightened, Breaking: true, Message: "minimum changed from 1 to 1024"},
+			{Path: "serve.port", Kind: ChangeRequiredRemoved, Message: "the property is no longer required"},
+		}, changes)
+
+		err = CheckCompatibility([]byte(diffOldSchema), []byte(newSchema))
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrBreakingChanges))
+		assert.Contains(t, err.Error(), "(root): additional properties are no longer allowed; dsn: minLength was raised from 0 to 1;")
+	})
+
+	t.Run("case=removed properties", func(t *testing.T) {
+		err := CheckCompatibility(
+			[]byte(`{"properties": {"a": {"type": "string"}, "b": {"properties": {"c": {}}}}}`),
+			[]byte(`{"properties": {"b": {}}}`))
+		require.Error(t, err)
+		assert.Equal(t, "a: the property was removed; b.c: the property was removed: the schema has breaking changes", err.Error())
+	})
+
+	t.Run("case=recursive schemas", func(t *testing.T) {
+		changes, err := CompareSchemasBytes([]byte(recursiveSchema), []byte(recursiveSchema))
+		require.NoError(t, err)
+		assert.Empty(t, changes)
+	})
+
+	t.Run("case=invalid schema", func(t *testing.T) {
+		_, err := CompareSchemasBytes([]byte(`{"type": 1}`), []byte(`{}`))
+		assert.Error(t, err)
+	})
+}
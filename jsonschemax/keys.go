@@ -93,6 +93,18 @@ type Path struct {
 	MultipleOf *big.Float
 
 	CustomProperties map[string]interface{}
+
+	defaultPointer string
+}
+
+// DefaultSchemaPointer returns the JSON Pointer of the schema which defines the default, for
+// example "#/allOf/0/then/properties/port" for a default of a conditional schema. It is empty
+// if the path has no default.
+//
+// If a path is defined more than once, the default of the schema itself wins over references,
+// allOf, then, else, oneOf and anyOf, in this order and in the order of their definition.
+func (p Path) DefaultSchemaPointer() string {
+	return p.defaultPointer
 }
 
 // ListPathsBytes works like ListPathsWithRecursion but prepares the JSON Schema itself.
@@ -113,6 +125,12 @@ func ListPathsWithRecursion(ref string, compiler *jsonschema.Compiler, maxRecurs
 	return runPathsFromCompiler(ref, compiler, int16(maxRecursion), false)
 }
 
+// ListPathsWithRecursionAndArraysIncluded works like ListPathsWithRecursion but includes arrays
+// with `#`.
+func ListPathsWithRecursionAndArraysIncluded(ref string, compiler *jsonschema.Compiler, maxRecursion uint8) ([]Path, error) {
+	return runPathsFromCompiler(ref, compiler, int16(maxRecursion), true)
+}
+
 // ListPaths lists all paths of a JSON Schema. Will return an error
 // if circular references are found.
 func ListPaths(ref string, compiler *jsonschema.Compiler) ([]Path, error) {
@@ -158,12 +176,12 @@ func runPathsFromCompiler(ref string, compiler *jsonschema.Compiler, maxRecursio
 
 func runPaths(schema *jsonschema.Schema, maxRecursion int16, includeArrays bool) ([]Path, error) {
 	pointers := map[string]bool{}
-	paths, err := listPaths(schema, nil, nil, pointers, 0, maxRecursion, includeArrays)
+	paths, err := listPaths(schema, nil, nil, schema.Ptr, pointers, 0, maxRecursion, includeArrays)
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
 
-	sort.Sort(paths)
+	sort.Stable(paths)
 	return makeUnique(paths)
 }
 
@@ -180,7 +198,7 @@ func makeUnique(in byName) (byName, error) {
 			return nil, errors.Errorf("multiple types %+v are not supported for path: %s", []interface{}{p.Type, vc.Type}, p.Name)
 		}
 
-		if vc.Default == nil {
+		if vc.Default == nil && p.Default != nil {
 			cache[p.Name] = p
 		}
 	}
@@ -206,7 +224,11 @@ func appendPointer(in map[string]bool, pointer *jsonschema.Schema) map[string]bo
 	return out
 }
 
-func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []string, pointers map[string]bool, currentRecursion int16, maxRecursion int16, includeArrays bool) (byName, error) {
+func escapePointer(name string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(name)
+}
+
+func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []string, ptr string, pointers map[string]bool, currentRecursion int16, maxRecursion int16, includeArrays bool) (byName, error) {
 	var pathType interface{}
 	var pathTypeHint TypeHint
 	var paths []Path
@@ -331,6 +353,9 @@ func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []s
 			Examples:    schema.Examples,
 			Required:    required,
 		}
+		if schema.Default != nil {
+			path.defaultPointer = ptr
+		}
 
 		for _, e := range schema.Extensions {
 			if enhancer, ok := e.(PathEnhancer); ok {
@@ -349,24 +374,46 @@ func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []s
 		currentRecursion++
 	}
 
+	// The order of the sub-schemas decides which default wins if a path is defined more than
+	// once: the schema itself and references come first, followed by allOf, then, else, oneOf
+	// and anyOf in the order of their definition. Defaults in if and not are used last.
 	if schema.Ref != nil {
-		path, err := listPaths(schema.Ref, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+		path, err := listPaths(schema.Ref, schema, parents, schema.Ref.Ptr, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	if schema.Not != nil {
-		path, err := listPaths(schema.Not, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	for name, sub := range schema.Properties {
+		path, err := listPaths(sub, schema, append(parents, name), ptr+"/properties/"+escapePointer(name), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	if schema.If != nil {
-		path, err := listPaths(schema.If, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	if schema.Items != nil && includeArrays {
+		switch t := schema.Items.(type) {
+		case []*jsonschema.Schema:
+			for i, sub := range t {
+				path, err := listPaths(sub, schema, append(parents, "#"), fmt.Sprintf("%s/items/%d", ptr, i), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+				if err != nil {
+					return nil, err
+				}
+				paths = append(paths, path...)
+			}
+		case *jsonschema.Schema:
+			path, err := listPaths(t, schema, append(parents, "#"), ptr+"/items", appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, path...)
+		}
+	}
+
+	for i, sub := range schema.AllOf {
+		path, err := listPaths(sub, schema, parents, fmt.Sprintf("%s/allOf/%d", ptr, i), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
@@ -374,7 +421,7 @@ func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []s
 	}
 
 	if schema.Then != nil {
-		path, err := listPaths(schema.Then, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+		path, err := listPaths(schema.Then, schema, parents, ptr+"/then", appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
@@ -382,63 +429,44 @@ func listPaths(schema *jsonschema.Schema, parent *jsonschema.Schema, parents []s
 	}
 
 	if schema.Else != nil {
-		path, err := listPaths(schema.Else, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+		path, err := listPaths(schema.Else, schema, parents, ptr+"/else", appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	for _, sub := range schema.AllOf {
-		path, err := listPaths(sub, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	for i, sub := range schema.OneOf {
+		path, err := listPaths(sub, schema, parents, fmt.Sprintf("%s/oneOf/%d", ptr, i), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	for _, sub := range schema.AnyOf {
-		path, err := listPaths(sub, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	for i, sub := range schema.AnyOf {
+		path, err := listPaths(sub, schema, parents, fmt.Sprintf("%s/anyOf/%d", ptr, i), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	for _, sub := range schema.OneOf {
-		path, err := listPaths(sub, schema, parents, appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	if schema.If != nil {
+		path, err := listPaths(schema.If, schema, parents, ptr+"/if", appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	for name, sub := range schema.Properties {
-		path, err := listPaths(sub, schema, append(parents, name), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
+	if schema.Not != nil {
+		path, err := listPaths(schema.Not, schema, parents, ptr+"/not", appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
 		if err != nil {
 			return nil, err
 		}
 		paths = append(paths, path...)
 	}
 
-	if schema.Items != nil && includeArrays {
-		switch t := schema.Items.(type) {
-		case []*jsonschema.Schema:
-			for _, sub := range t {
-				path, err := listPaths(sub, schema, append(parents, "#"), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
-				if err != nil {
-					return nil, err
-				}
-				paths = append(paths, path...)
-			}
-		case *jsonschema.Schema:
-			path, err := listPaths(t, schema, append(parents, "#"), appendPointer(pointers, schema), currentRecursion, maxRecursion, includeArrays)
-			if err != nil {
-				return nil, err
-			}
-			paths = append(paths, path...)
-		}
-	}
-
 	return paths, nil
 }
@@ -299,3 +299,41 @@ func TestListPaths(t *testing.T) {
 		})
 	}
 }
+
+func TestListPathsConditionalDefaults(t *testing.T) {
+	const schema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "type": "object",
+  "properties": {
+    "driver": {"type": "string", "default": "memory"},
+    "timeout": {"type": "string"}
+  },
+  "if": {"properties": {"driver": {"const": "postgres"}}},
+  "then": {"properties": {"pool": {"type": "integer", "default": 10}, "timeout": {"type": "string", "default": "10s"}}},
+  "else": {"properties": {"pool": {"type": "integer", "default": 1}}},
+  "allOf": [
+    {"properties": {"timeout": {"type": "string", "default": "5s"}}},
+    {"properties": {"timeout": {"type": "string", "default": "1s"}}}
+  ],
+  "oneOf": [
+    {"properties": {"tls": {"type": "boolean", "default": true}}},
+    {"properties": {"tls": {"type": "boolean", "default": false}}}
+  ]
+}`
+
+	for i := 0; i < 10; i++ {
+		paths, err := ListPathsBytes([]byte(schema), -1)
+		require.NoError(t, err)
+
+		defaults := map[string][]interface{}{}
+		for _, p := range paths {
+			defaults[p.Name] = []interface{}{p.Default, p.DefaultSchemaPointer()}
+		}
+		require.Equal(t, map[string][]interface{}{
+			"driver":  {"memory", "#/properties/driver"},
+			"pool":    {float64(10), "#/then/properties/pool"},
+			"timeout": {"5s", "#/allOf/0/properties/timeout"},
+			"tls":     {true, "#/oneOf/0/properties/tls"},
+		}, defaults)
+	}
+}
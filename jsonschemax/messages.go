@@ -0,0 +1,178 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/jsonschema/v3"
+)
+
+// MessagesKeyword is the keyword which defines custom error messages per keyword of a schema:
+//
+//	{
+//	  "type": "string",
+//	  "minLength": 8,
+//	  "x-messages": {
+//	    "minLength": "The password must have at least eight characters.",
+//	    "type": {
+//	      "en": "The password must be a string.",
+//	      "de": "Das Passwort muss eine Zeichenkette sein."
+//	    }
+//	  }
+//	}
+//
+// A message is either a string or an object mapping language tags to localized messages.
+const MessagesKeyword = "x-messages"
+
+// Messages resolves the custom error messages of validation errors which are defined with the
+// MessagesKeyword.
+type Messages struct {
+	docs map[string]interface{}
+}
+
+// FormatOption configures FormatError and FormatValidationErrorForCLI.
+type FormatOption func(o *formatOptions)
+
+type formatOptions struct {
+	messages  *Messages
+	languages []string
+}
+
+// WithMessages uses the custom error messages, localized for the preferred languages, for
+// example "de-CH" and "en". English is used if no preferred language is available.
+func WithMessages(m *Messages, languages ...string) FormatOption {
+	return func(o *formatOptions) {
+		o.messages = m
+		o.languages = languages
+	}
+}
+
+func newFormatOptions(opts []FormatOption) *formatOptions {
+	o := new(formatOptions)
+	for _, f := range opts {
+		f(o)
+	}
+	return o
+}
+
+// NewMessages returns empty Messages. Use AddResource to add the schemas which define messages.
+func NewMessages() *Messages {
+	return &Messages{docs: map[string]interface{}{}}
+}
+
+// AddResource adds a schema. The URL must be the URL the schema was added to the
+// jsonschema.Compiler with.
+func (m *Messages) AddResource(url string, raw []byte) error {
+	var doc interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return errors.WithStack(err)
+	}
+	m.docs[url] = doc
+	return nil
+}
+
+// Message returns the custom message for the validation error, localized for the preferred
+// languages. It returns false if the schema does not define a message for the keyword which
+// failed.
+func (m *Messages) Message(e *jsonschema.ValidationError, languages ...string) (string, bool) {
+	doc, ok := m.docs[e.SchemaURL]
+	if !ok {
+		return "", false
+	}
+
+	parent, keyword := splitSchemaPointer(e.SchemaPtr)
+	schema, ok := resolvePointer(doc, parent).(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+	messages, ok := schema[MessagesKeyword].(map[string]interface{})
+	if !ok {
+		return "", false
+	}
+
+	switch message := messages[keyword].(type) {
+	case string:
+		return message, true
+	case map[string]interface{}:
+		return localize(message, languages)
+	}
+	return "", false
+}
+
+// Localize returns a copy of the validation error and its causes in which the messages are
+// replaced by the custom messages, for example to return them in an API response.
+func (m *Messages) Localize(e *jsonschema.ValidationError, languages ...string) *jsonschema.ValidationError {
+	localized := *e
+	if message, ok := m.Message(e, languages...); ok {
+		localized.Message = message
+	}
+	localized.Causes = make([]*jsonschema.ValidationError, len(e.Causes))
+	for i, cause := range e.Causes {
+		localized.Causes[i] = m.Localize(cause, languages...)
+	}
+	return &localized
+}
+
+// splitSchemaPointer splits "#/properties/foo/minLength" into "#/properties/foo" and
+// "minLength".
+func splitSchemaPointer(pointer string) (string, string) {
+	i := strings.LastIndex(pointer, "/")
+	if i < 0 {
+		return pointer, ""
+	}
+	return pointer[:i], unescapePointer(pointer[i+1:])
+}
+
+func unescapePointer(token string) string {
+	return strings.NewReplacer("~1", "/", "~0", "~").Replace(token)
+}
+
+func resolvePointer(doc interface{}, pointer string) interface{} {
+	pointer = strings.TrimPrefix(pointer, "#")
+	if pointer == "" {
+		return doc
+	}
+
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		token = unescapePointer(token)
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			doc = v[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			doc = v[i]
+		default:
+			return nil
+		}
+	}
+	return doc
+}
+
+func localize(messages map[string]interface{}, languages []string) (string, bool) {
+	candidates := make([]string, 0, 2*len(languages)+1)
+	for _, l := range languages {
+		candidates = append(candidates, l)
+		if i := strings.IndexAny(l, "-_"); i > 0 {
+			candidates = append(candidates, l[:i])
+		}
+	}
+	candidates = append(candidates, "en")
+
+	for _, c := range candidates {
+		for tag, message := range messages {
+			if s, ok := message.(string); ok && strings.EqualFold(tag, c) {
+				return s, true
+			}
+		}
+	}
+	return "", false
+}
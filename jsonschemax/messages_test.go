@@ -0,0 +1,125 @@
+package jsonschemax
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/jsonschema/v3"
+)
+
+const messagesSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "definitions": {
+    "password": {
+      "type": "string",
+      "minLength": 8,
+      "x-messages": {
+        "minLength": {
+          "en": "The password must have at least eight characters.",
+          "de": "Das Passwort muss mindestens acht Zeichen haben."
+        }
+      }
+    }
+  },
+  "type": "object",
+  "required": ["email"],
+  "x-messages": {
+    "required": "Please fill in all required fields."
+  },
+  "properties": {
+    "email": {"type": "string", "format": "email", "x-messages": {"format": "Please enter a valid email address."}},
+    "password": {"$ref": "#/definitions/password"},
+    "age": {"type": "integer", "x-messages": {"type": {"de": "Das Alter muss eine Zahl sein."}}}
+  }
+}`
+
+func validateMessagesSchema(t *testing.T, doc string) *jsonschema.ValidationError {
+	c := jsonschema.NewCompiler()
+	require.NoError(t, c.AddResource("messages.json", bytes.NewBufferString(messagesSchema)))
+	schema, err := c.Compile("messages.json")
+	require.NoError(t, err)
+
+	var v interface{}
+	require.NoError(t, json.Unmarshal([]byte(doc), &v))
+	err = schema.ValidateInterface(v)
+	var e *jsonschema.ValidationError
+	require.True(t, errors.As(err, &e), "%+v", err)
+	return e
+}
+
+func findCause(e *jsonschema.ValidationError, schemaPtr string) *jsonschema.ValidationError {
+	if e.SchemaPtr == schemaPtr {
+		return e
+	}
+	for _, c := range e.Causes {
+		if found := findCause(c, schemaPtr); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func TestMessages(t *testing.T) {
+	m := NewMessages()
+	require.NoError(t, m.AddResource("messages.json", []byte(messagesSchema)))
+	assert.Error(t, m.AddResource("invalid.json", []byte("{")))
+
+	e := validateMessagesSchema(t, `{"email": "not-an-email", "password": "short", "age": "old"}`)
+
+	for _, tc := range []struct {
+		schemaPtr string
+		languages []string
+		expected  string
+		found     bool
+	}{
+		{schemaPtr: "#/properties/email/format", expected: "Please enter a valid email address.", found: true},
+		{schemaPtr: "#/definitions/password/minLength", expected: "The password must have at least eight characters.", found: true},
+		{schemaPtr: "#/definitions/password/minLength", languages: []string{"de-CH"}, expected: "Das Passwort muss mindestens acht Zeichen haben.", found: true},
+		{schemaPtr: "#/definitions/password/minLength", languages: []string{"fr", "DE"}, expected: "Das Passwort muss mindestens acht Zeichen haben.", found: true},
+		{schemaPtr: "#/properties/age/type", languages: []string{"de"}, expected: "Das Alter muss eine Zahl sein.", found: true},
+		{schemaPtr: "#/properties/age/type", languages: []string{"fr"}},
+		{schemaPtr: "#/properties/password/$ref"},
+	} {
+		cause := findCause(e, tc.schemaPtr)
+		require.NotNil(t, cause, tc.schemaPtr)
+		message, found := m.Message(cause, tc.languages...)
+		assert.Equal(t, tc.found, found, "%s %v", tc.schemaPtr, tc.languages)
+		assert.Equal(t, tc.expected, message, "%s %v", tc.schemaPtr, tc.languages)
+	}
+
+	t.Run("method=Localize", func(t *testing.T) {
+		localized := m.Localize(e, "de")
+		assert.Equal(t, "Das Passwort muss mindestens acht Zeichen haben.", findCause(localized, "#/definitions/password/minLength").Message)
+		assert.Equal(t, "Das Alter muss eine Zahl sein.", findCause(localized, "#/properties/age/type").Message)
+		assert.Equal(t, "length must be >= 8, but got 5", findCause(e, "#/definitions/password/minLength").Message, "the original error is not modified")
+	})
+
+	t.Run("unknown schema", func(t *testing.T) {
+		_, found := NewMessages().Message(findCause(e, "#/properties/email/format"))
+		assert.False(t, found)
+	})
+}
+
+func TestFormatErrorWithMessages(t *testing.T) {
+	m := NewMessages()
+	require.NoError(t, m.AddResource("messages.json", []byte(messagesSchema)))
+
+	e := validateMessagesSchema(t, `{"password": "short"}`)
+
+	pointer, message := FormatError(findCause(e, "#/required"), WithMessages(m))
+	assert.Equal(t, "email", pointer)
+	assert.Equal(t, "Please fill in all required fields.", message)
+
+	_, message = FormatError(findCause(e, "#/required"))
+	assert.Equal(t, "one or more required properties are missing", message)
+
+	var b bytes.Buffer
+	FormatValidationErrorForCLI(&b, []byte(`{"password": "short"}`), e, WithMessages(m, "de"))
+	assert.Contains(t, b.String(), "password: short")
+	assert.Contains(t, b.String(), "^-- Das Passwort muss mindestens acht Zeichen haben.")
+}
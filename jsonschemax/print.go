@@ -11,14 +11,16 @@ import (
 	"github.com/ory/jsonschema/v3"
 )
 
-func FormatValidationErrorForCLI(w io.Writer, conf []byte, err error) {
+// FormatValidationErrorForCLI writes the validation errors and the invalid values of the
+// configuration to w.
+func FormatValidationErrorForCLI(w io.Writer, conf []byte, err error, opts ...FormatOption) {
 	if err == nil {
 		return
 	}
 
 	if e := new(jsonschema.ValidationError); errors.As(err, &e) {
 		_, _ = fmt.Fprintln(w, "The configuration contains values or keys which are invalid:")
-		pointer, validation := FormatError(e)
+		pointer, validation := FormatError(e, opts...)
 
 		if pointer == "#" {
 			if len(e.Causes) == 0 {
@@ -36,13 +38,15 @@ func FormatValidationErrorForCLI(w io.Writer, conf []byte, err error) {
 		}
 
 		for _, cause := range e.Causes {
-			FormatValidationErrorForCLI(w, conf, cause)
+			FormatValidationErrorForCLI(w, conf, cause, opts...)
 		}
 		return
 	}
 }
 
-func FormatError(e *jsonschema.ValidationError) (string, string) {
+// FormatError returns the location of the validation error in dot-notation and its message.
+// Custom messages are used if WithMessages is passed.
+func FormatError(e *jsonschema.ValidationError, opts ...FormatOption) (string, string) {
 	var (
 		err     error
 		pointer string
@@ -59,6 +63,12 @@ func FormatError(e *jsonschema.ValidationError) (string, string) {
 		}
 	}
 
+	if o := newFormatOptions(opts); o.messages != nil {
+		if custom, ok := o.messages.Message(e, o.languages...); ok {
+			message = custom
+		}
+	}
+
 	// We can ignore the error as it will simply echo the pointer.
 	pointer, err = JSONPointerToDotNotation(pointer)
 	if err != nil {
@@ -0,0 +1,249 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/pkg/errors"
+)
+
+// Canonicalize returns the canonical form of the JSON document as defined by the JSON
+// Canonicalization Scheme (JCS, RFC 8785): object members are sorted by their UTF-16 encoded
+// names, there is no whitespace, and strings and numbers are serialized the way ECMAScript
+// does. Documents with duplicate object member names are rejected.
+//
+// The canonical form is reproducible, so it can be signed or hashed.
+func Canonicalize(raw []byte) ([]byte, error) {
+	return reencode(raw, true)
+}
+
+// CanonicalMarshal marshals v with encoding/json and returns its canonical form. See
+// Canonicalize.
+func CanonicalMarshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return Canonicalize(raw)
+}
+
+// SortKeys returns the JSON document without whitespace and with the members of all objects
+// sorted by their names. Unlike Canonicalize, numbers are kept as they are, so that precision
+// of large integers is not lost.
+func SortKeys(raw []byte) ([]byte, error) {
+	return reencode(raw, false)
+}
+
+// StableMarshal marshals v with encoding/json and sorts the members of all objects, including
+// the fields of structs, by their names. See SortKeys.
+func StableMarshal(v interface{}) ([]byte, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return SortKeys(raw)
+}
+
+func reencode(raw []byte, canonical bool) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var out bytes.Buffer
+	if err := writeValue(&out, dec, canonical); err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+	return out.Bytes(), nil
+}
+
+type member struct {
+	name  string
+	value []byte
+}
+
+func writeValue(out *bytes.Buffer, dec *json.Decoder, canonical bool) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			return writeObject(out, dec, canonical)
+		case '[':
+			out.WriteByte('[')
+			for i := 0; dec.More(); i++ {
+				if i > 0 {
+					out.WriteByte(',')
+				}
+				if err := writeValue(out, dec, canonical); err != nil {
+					return err
+				}
+			}
+			if _, err := dec.Token(); err != nil {
+				return errors.WithStack(err)
+			}
+			out.WriteByte(']')
+		default:
+			return errors.Errorf("unexpected delimiter %s", t)
+		}
+	case string:
+		writeString(out, t)
+	case json.Number:
+		if !canonical {
+			out.WriteString(t.String())
+			return nil
+		}
+		f, err := strconv.ParseFloat(t.String(), 64)
+		if err != nil {
+			return errors.Wrapf(err, "the number %s can not be represented as an IEEE 754 double", t)
+		}
+		out.WriteString(FormatNumber(f))
+	case bool:
+		out.WriteString(strconv.FormatBool(t))
+	case nil:
+		out.WriteString("null")
+	}
+	return nil
+}
+
+func writeObject(out *bytes.Buffer, dec *json.Decoder, canonical bool) error {
+	var members []member
+	seen := map[string]bool{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		name := tok.(string)
+		if seen[name] {
+			return errors.Errorf("duplicate object member %q", name)
+		}
+		seen[name] = true
+
+		var value bytes.Buffer
+		if err := writeValue(&value, dec, canonical); err != nil {
+			return err
+		}
+		members = append(members, member{name: name, value: value.Bytes()})
+	}
+	if _, err := dec.Token(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	sort.Slice(members, func(i, j int) bool {
+		return lessUTF16(members[i].name, members[j].name)
+	})
+
+	out.WriteByte('{')
+	for i, m := range members {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		writeString(out, m.name)
+		out.WriteByte(':')
+		out.Write(m.value)
+	}
+	out.WriteByte('}')
+	return nil
+}
+
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// writeString writes the string as JSON, escaping only what RFC 8785 requires.
+func writeString(out *bytes.Buffer, s string) {
+	const hex = "0123456789abcdef"
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\b':
+			out.WriteString(`\b`)
+		case '\f':
+			out.WriteString(`\f`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				out.WriteString(`\u00`)
+				out.WriteByte(hex[r>>4])
+				out.WriteByte(hex[r&0xf])
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+}
+
+// FormatNumber formats the number like ECMAScript's Number.prototype.toString, as required by
+// RFC 8785. It panics on NaN and infinity, which can not be represented in JSON.
+func FormatNumber(f float64) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic("jsonx: NaN and infinity can not be represented in JSON")
+	}
+	if f == 0 {
+		return "0"
+	}
+
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+
+	// The shortest representation which round-trips, as "d.ddde±xx".
+	e := strconv.FormatFloat(f, 'e', -1, 64)
+	mantissa, exp := e[:strings.IndexByte(e, 'e')], e[strings.IndexByte(e, 'e')+1:]
+	digits := strings.Replace(mantissa, ".", "", 1)
+	x, _ := strconv.Atoi(exp)
+	n := x + 1 // the position of the decimal point relative to the digits
+	k := len(digits)
+
+	switch {
+	case k <= n && n <= 21:
+		return sign + digits + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		return sign + digits[:n] + "." + digits[n:]
+	case -6 < n && n <= 0:
+		return sign + "0." + strings.Repeat("0", -n) + digits
+	}
+
+	var b strings.Builder
+	b.WriteString(sign)
+	b.WriteString(digits[:1])
+	if k > 1 {
+		b.WriteString(".")
+		b.WriteString(digits[1:])
+	}
+	b.WriteString("e")
+	if n-1 >= 0 {
+		b.WriteString("+")
+	}
+	b.WriteString(strconv.Itoa(n - 1))
+	return b.String()
+}
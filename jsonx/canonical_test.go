@@ -0,0 +1,90 @@
+package jsonx
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	t.Run("case=rfc 8785 example", func(t *testing.T) {
+		in := `{
+  "numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+  "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+  "literals": [null, true, false]
+}`
+		out, err := Canonicalize([]byte(in))
+		require.NoError(t, err)
+		assert.Equal(t, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`, string(out))
+	})
+
+	t.Run("case=sorting by utf-16 code units", func(t *testing.T) {
+		out, err := Canonicalize([]byte(`{"\u20ac":"Euro Sign","\r":"Carriage Return","\ufb33":"Hebrew Letter Dalet With Dagesh","1":"One","\ud83d\ude00":"Emoji: Grinning Face","\u0080":"Control","\u00f6":"Latin Small Letter O With Diaeresis"}`))
+		require.NoError(t, err)
+		assert.Equal(t, "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin Small Letter O With Diaeresis\",\"\u20ac\":\"Euro Sign\",\"\U0001F600\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}", string(out))
+	})
+
+	t.Run("case=invalid documents", func(t *testing.T) {
+		for _, in := range []string{
+			`{"a":1,"a":2}`,
+			`{"a":1} {}`,
+			`[1e400]`,
+			`{"a":`,
+			``,
+		} {
+			_, err := Canonicalize([]byte(in))
+			assert.Error(t, err, in)
+		}
+	})
+
+	t.Run("method=CanonicalMarshal", func(t *testing.T) {
+		out, err := CanonicalMarshal(struct {
+			Z    string            `json:"z"`
+			A    float64           `json:"a"`
+			HTML string            `json:"html"`
+			Map  map[string]uint64 `json:"map"`
+		}{Z: "z", A: 1.0, HTML: "<a>&", Map: map[string]uint64{"b": 2, "a": 1}})
+		require.NoError(t, err)
+		assert.Equal(t, `{"a":1,"html":"<a>&","map":{"a":1,"b":2},"z":"z"}`, string(out))
+	})
+}
+
+func TestStableMarshal(t *testing.T) {
+	out, err := StableMarshal(struct {
+		Z  string `json:"z"`
+		ID uint64 `json:"id"`
+		A  []int  `json:"a"`
+	}{Z: "z", ID: math.MaxUint64, A: []int{3, 1}})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":[3,1],"id":18446744073709551615,"z":"z"}`, string(out), "large integers are kept")
+
+	out, err = SortKeys([]byte(`{ "b": 1.50, "a": { "d": 1e3, "c": null } }`))
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":{"c":null,"d":1e3},"b":1.50}`, string(out))
+}
+
+func TestFormatNumber(t *testing.T) {
+	for expected, f := range map[string]float64{
+		"0":                       0,
+		"-1":                      -1,
+		"1e+21":                   1e21,
+		"100000000000000000000":   1e20,
+		"1e-7":                    1e-7,
+		"0.000001":                1e-6,
+		"9007199254740992":        9007199254740992,
+		"-9007199254740992":       -9007199254740992,
+		"295147905179352830000":   295147905179352825856,
+		"5e-324":                  math.SmallestNonzeroFloat64,
+		"1.7976931348623157e+308": math.MaxFloat64,
+		"0.1":                     0.1,
+		"123.456":                 123.456,
+		"1.5e-7":                  1.5e-7,
+	} {
+		assert.Equal(t, expected, FormatNumber(f))
+	}
+
+	assert.Equal(t, "0", FormatNumber(math.Copysign(0, -1)))
+	assert.Panics(t, func() { FormatNumber(math.NaN()) })
+}
@@ -0,0 +1,551 @@
+package jsonx
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrDeniedPath is returned if a patch changes a path which is denied by WithDeniedPaths.
+	ErrDeniedPath = errors.New("the patch changes a path which must not be changed")
+
+	// ErrInvalidPatch is returned if a patch document is malformed or can not be applied.
+	ErrInvalidPatch = errors.New("the patch is invalid")
+
+	// ErrPatchTestFailed is returned if a "test" operation of a JSON Patch fails.
+	ErrPatchTestFailed = errors.New("the patch test operation failed")
+)
+
+type (
+	// PatchOperation is an operation of a JSON Patch (RFC 6902). The changes made by a patch
+	// are also returned as patch operations.
+	PatchOperation struct {
+		Op    string          `json:"op"`
+		Path  string          `json:"path"`
+		From  string          `json:"from,omitempty"`
+		Value json.RawMessage `json:"value,omitempty"`
+	}
+
+	// PatchOption configures ApplyJSONPatch and ApplyMergePatch.
+	PatchOption func(o *patchOptions)
+
+	patchOptions struct {
+		denied [][]string
+		dryRun bool
+	}
+)
+
+// WithDeniedPaths denies changes to the JSON pointers (RFC 6901), for example read-only fields
+// such as "/id". Changes to children of the paths are denied as well. The segment "*" matches
+// any object member or array element, so "/addresses/*/verified" denies changing the
+// "verified" field of all addresses.
+func WithDeniedPaths(paths ...string) PatchOption {
+	return func(o *patchOptions) {
+		for _, p := range paths {
+			o.denied = append(o.denied, parsePointer(p))
+		}
+	}
+}
+
+// WithDryRun returns the changes the patch would make without changing the value.
+func WithDryRun() PatchOption {
+	return func(o *patchOptions) {
+		o.dryRun = true
+	}
+}
+
+// ApplyJSONPatch applies the JSON Patch (RFC 6902) to v, which must be a pointer to a value
+// which can be marshaled to and unmarshaled from JSON, and returns the changes it made. If the
+// patch fails or changes a denied path, v is left unchanged. This is the typical body of a
+// PATCH endpoint with the content type "application/json-patch+json":
+//
+//	changes, err := jsonx.ApplyJSONPatch(body, &identity, jsonx.WithDeniedPaths("/id"))
+func ApplyJSONPatch(patch []byte, v interface{}, opts ...PatchOption) ([]PatchOperation, error) {
+	var ops []PatchOperation
+	if err := json.Unmarshal(patch, &ops); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+
+	return applyPatch(v, opts, func(doc interface{}) (interface{}, error) {
+		for i, op := range ops {
+			var err error
+			if doc, err = applyOperation(doc, op); err != nil {
+				return nil, errors.WithMessagef(err, "operation %d (%s %s)", i, op.Op, op.Path)
+			}
+		}
+		return doc, nil
+	})
+}
+
+// ApplyMergePatch applies the JSON Merge Patch (RFC 7396) to v, which must be a pointer to a
+// value which can be marshaled to and unmarshaled from JSON, and returns the changes it made.
+// If the patch changes a denied path, v is left unchanged. This is the typical body of a PATCH
+// endpoint with the content type "application/merge-patch+json".
+func ApplyMergePatch(patch []byte, v interface{}, opts ...PatchOption) ([]PatchOperation, error) {
+	p, err := decodeDocument(patch)
+	if err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+
+	return applyPatch(v, opts, func(doc interface{}) (interface{}, error) {
+		return mergePatch(doc, p), nil
+	})
+}
+
+func applyPatch(v interface{}, opts []PatchOption, apply func(doc interface{}) (interface{}, error)) ([]PatchOperation, error) {
+	o := new(patchOptions)
+	for _, f := range opts {
+		f(o)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return nil, errors.Errorf("expected a non-nil pointer but got %T", v)
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	original, err := decodeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+	patched, err := decodeDocument(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if patched, err = apply(patched); err != nil {
+		return nil, err
+	}
+
+	changes := diff(nil, original, patched, nil)
+	for _, c := range changes {
+		if err := o.checkDenied(c, original, patched); err != nil {
+			return nil, err
+		}
+	}
+	if o.dryRun {
+		return changes, nil
+	}
+
+	out, err := json.Marshal(patched)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// Unmarshal into a new value, because unmarshaling merges into existing values, so that
+	// removed fields would survive.
+	result := reflect.New(rv.Elem().Type())
+	if err := json.Unmarshal(out, result.Interface()); err != nil {
+		return nil, errors.Wrap(ErrInvalidPatch, err.Error())
+	}
+	rv.Elem().Set(result.Elem())
+	return changes, nil
+}
+
+func decodeDocument(raw []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return doc, nil
+}
+
+// parsePointer parses a JSON pointer (RFC 6901) into its unescaped segments.
+func parsePointer(p string) []string {
+	if p == "" {
+		return []string{}
+	}
+	segments := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, s := range segments {
+		segments[i] = strings.ReplaceAll(strings.ReplaceAll(s, "~1", "/"), "~0", "~")
+	}
+	return segments
+}
+
+func formatPointer(segments []string) string {
+	var b strings.Builder
+	for _, s := range segments {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+func (o *patchOptions) checkDenied(c PatchOperation, original, patched interface{}) error {
+	changed := parsePointer(c.Path)
+	for _, denied := range o.denied {
+		n := len(denied)
+		if len(changed) < n {
+			n = len(changed)
+		}
+		if !matchSegments(denied[:n], changed[:n]) {
+			continue
+		}
+		if len(changed) >= len(denied) {
+			return errors.Wrapf(ErrDeniedPath, "path %s", c.Path)
+		}
+
+		// The change replaces an ancestor of the denied path, which is only a problem if the
+		// denied path exists below it.
+		rest := denied[len(changed):]
+		before, _ := resolve(original, changed)
+		after, _ := resolve(patched, changed)
+		if containsPath(before, rest) || containsPath(after, rest) {
+			return errors.Wrapf(ErrDeniedPath, "path %s", formatPointer(denied))
+		}
+	}
+	return nil
+}
+
+func matchSegments(pattern, segments []string) bool {
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != segments[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func containsPath(doc interface{}, segments []string) bool {
+	if len(segments) == 0 {
+		return true
+	}
+	switch d := doc.(type) {
+	case map[string]interface{}:
+		if segments[0] == "*" {
+			for _, v := range d {
+				if containsPath(v, segments[1:]) {
+					return true
+				}
+			}
+			return false
+		}
+		v, ok := d[segments[0]]
+		return ok && containsPath(v, segments[1:])
+	case []interface{}:
+		if segments[0] == "*" {
+			for _, v := range d {
+				if containsPath(v, segments[1:]) {
+					return true
+				}
+			}
+			return false
+		}
+		i, err := strconv.Atoi(segments[0])
+		return err == nil && i >= 0 && i < len(d) && containsPath(d[i], segments[1:])
+	}
+	return false
+}
+
+func resolve(doc interface{}, segments []string) (interface{}, error) {
+	for _, s := range segments {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			v, ok := d[s]
+			if !ok {
+				return nil, errors.Wrapf(ErrInvalidPatch, "the path %s does not exist", formatPointer(segments))
+			}
+			doc = v
+		case []interface{}:
+			i, err := arrayIndex(s, len(d)-1)
+			if err != nil {
+				return nil, err
+			}
+			doc = d[i]
+		default:
+			return nil, errors.Wrapf(ErrInvalidPatch, "the path %s does not exist", formatPointer(segments))
+		}
+	}
+	return doc, nil
+}
+
+func arrayIndex(s string, max int) (int, error) {
+	i, err := strconv.Atoi(s)
+	if err != nil || i < 0 || i > max || (len(s) > 1 && s[0] == '0') {
+		return 0, errors.Wrapf(ErrInvalidPatch, "the array index %q is out of bounds", s)
+	}
+	return i, nil
+}
+
+// setAt returns doc with the value added or replaced at the path.
+func setAt(doc interface{}, segments []string, value interface{}, insert bool) (interface{}, error) {
+	if len(segments) == 0 {
+		return value, nil
+	}
+
+	parent, err := resolve(doc, segments[:len(segments)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := segments[len(segments)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last]; !insert && !ok {
+			return nil, errors.Wrapf(ErrInvalidPatch, "the path %s does not exist", formatPointer(segments))
+		}
+		p[last] = value
+		return doc, nil
+	case []interface{}:
+		if !insert {
+			i, err := arrayIndex(last, len(p)-1)
+			if err != nil {
+				return nil, err
+			}
+			p[i] = value
+			return doc, nil
+		}
+
+		i := len(p)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(p)); err != nil {
+				return nil, err
+			}
+		}
+		arr := append(p[:i:i], append([]interface{}{value}, p[i:]...)...)
+		return setAt(doc, segments[:len(segments)-1], arr, false)
+	}
+	return nil, errors.Wrapf(ErrInvalidPatch, "the parent of %s is not an object or array", formatPointer(segments))
+}
+
+func removeAt(doc interface{}, segments []string) (interface{}, error) {
+	if len(segments) == 0 {
+		return nil, errors.Wrap(ErrInvalidPatch, "the root can not be removed")
+	}
+
+	parent, err := resolve(doc, segments[:len(segments)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := segments[len(segments)-1]
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if _, ok := p[last]; !ok {
+			return nil, errors.Wrapf(ErrInvalidPatch, "the path %s does not exist", formatPointer(segments))
+		}
+		delete(p, last)
+		return doc, nil
+	case []interface{}:
+		i, err := arrayIndex(last, len(p)-1)
+		if err != nil {
+			return nil, err
+		}
+		arr := append(p[:i:i], p[i+1:]...)
+		return setAt(doc, segments[:len(segments)-1], arr, false)
+	}
+	return nil, errors.Wrapf(ErrInvalidPatch, "the parent of %s is not an object or array", formatPointer(segments))
+}
+
+func applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	path := parsePointer(op.Path)
+	value := func() (interface{}, error) {
+		if len(op.Value) == 0 {
+			return nil, errors.Wrapf(ErrInvalidPatch, "the %s operation requires a value", op.Op)
+		}
+		return decodeDocument(op.Value)
+	}
+
+	switch op.Op {
+	case "add", "replace":
+		v, err := value()
+		if err != nil {
+			return nil, err
+		}
+		return setAt(doc, path, v, op.Op == "add")
+	case "remove":
+		return removeAt(doc, path)
+	case "move", "copy":
+		from := parsePointer(op.From)
+		v, err := resolve(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "move" {
+			if len(path) > len(from) && matchSegments(from, path[:len(from)]) {
+				return nil, errors.Wrap(ErrInvalidPatch, "a value can not be moved into one of its children")
+			}
+			if doc, err = removeAt(doc, from); err != nil {
+				return nil, err
+			}
+		} else {
+			v = deepCopy(v)
+		}
+		return setAt(doc, path, v, true)
+	case "test":
+		expected, err := value()
+		if err != nil {
+			return nil, err
+		}
+		actual, err := resolve(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(expected, actual) {
+			return nil, errors.WithStack(ErrPatchTestFailed)
+		}
+		return doc, nil
+	}
+	return nil, errors.Wrapf(ErrInvalidPatch, "unknown operation %q", op.Op)
+}
+
+func deepCopy(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		c := make(map[string]interface{}, len(vv))
+		for k, item := range vv {
+			c[k] = deepCopy(item)
+		}
+		return c
+	case []interface{}:
+		c := make([]interface{}, len(vv))
+		for i, item := range vv {
+			c[i] = deepCopy(item)
+		}
+		return c
+	}
+	return v
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+// jsonEqual compares two decoded JSON values, comparing numbers by their value.
+func jsonEqual(a, b interface{}) bool {
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k, v := range av {
+			other, ok := bv[k]
+			if !ok || !jsonEqual(v, other) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !jsonEqual(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case json.Number:
+		bv, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		x, okx := new(big.Rat).SetString(av.String())
+		y, oky := new(big.Rat).SetString(bv.String())
+		return okx && oky && x.Cmp(y) == 0
+	}
+	return a == b
+}
+
+// diff returns the operations which turn a into b.
+func diff(path []string, a, b interface{}, ops []PatchOperation) []PatchOperation {
+	if jsonEqual(a, b) {
+		return ops
+	}
+
+	at := func(s string) []string {
+		return append(append(make([]string, 0, len(path)+1), path...), s)
+	}
+
+	switch av := a.(type) {
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(av)+len(bv))
+		for k := range av {
+			keys = append(keys, k)
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			x, inA := av[k]
+			y, inB := bv[k]
+			switch {
+			case !inB:
+				ops = append(ops, PatchOperation{Op: "remove", Path: formatPointer(at(k))})
+			case !inA:
+				ops = append(ops, PatchOperation{Op: "add", Path: formatPointer(at(k)), Value: mustMarshal(y)})
+			default:
+				ops = diff(at(k), x, y, ops)
+			}
+		}
+		return ops
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(av)
+		if len(bv) < n {
+			n = len(bv)
+		}
+		for i := 0; i < n; i++ {
+			ops = diff(at(strconv.Itoa(i)), av[i], bv[i], ops)
+		}
+		for i := n; i < len(bv); i++ {
+			ops = append(ops, PatchOperation{Op: "add", Path: formatPointer(at(strconv.Itoa(i))), Value: mustMarshal(bv[i])})
+		}
+		// Remove from the end, so that the indices stay valid.
+		for i := len(av) - 1; i >= n; i-- {
+			ops = append(ops, PatchOperation{Op: "remove", Path: formatPointer(at(strconv.Itoa(i)))})
+		}
+		return ops
+	}
+
+	return append(ops, PatchOperation{Op: "replace", Path: formatPointer(path), Value: mustMarshal(b)})
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	out, err := json.Marshal(v)
+	if err != nil {
+		// Values decoded from JSON can always be encoded again.
+		panic(err)
+	}
+	return out
+}
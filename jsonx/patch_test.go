@@ -0,0 +1,212 @@
+package jsonx
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type patchAddress struct {
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+}
+
+type patchIdentity struct {
+	ID        string                 `json:"id"`
+	Name      string                 `json:"name,omitempty"`
+	Tags      []string               `json:"tags,omitempty"`
+	Addresses []patchAddress         `json:"addresses,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+func newPatchIdentity() *patchIdentity {
+	return &patchIdentity{
+		ID:        "id-1",
+		Name:      "Alice",
+		Tags:      []string{"a", "b"},
+		Addresses: []patchAddress{{Value: "alice@example.com", Verified: true}},
+		Metadata:  map[string]interface{}{"plan": "free", "seats": 1},
+	}
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	t.Run("case=operations", func(t *testing.T) {
+		i := newPatchIdentity()
+		changes, err := ApplyJSONPatch([]byte(`[
+  {"op": "test", "path": "/name", "value": "Alice"},
+  {"op": "replace", "path": "/name", "value": "Bob"},
+  {"op": "add", "path": "/tags/1", "value": "inserted"},
+  {"op": "add", "path": "/tags/-", "value": "last"},
+  {"op": "remove", "path": "/tags/0"},
+  {"op": "copy", "from": "/metadata/plan", "path": "/metadata/previous_plan"},
+  {"op": "move", "from": "/metadata/seats", "path": "/metadata/licenses"},
+  {"op": "add", "path": "/addresses/-", "value": {"value": "bob@example.com"}}
+]`), i)
+		require.NoError(t, err)
+
+		assert.Equal(t, &patchIdentity{
+			ID:   "id-1",
+			Name: "Bob",
+			Tags: []string{"inserted", "b", "last"},
+			Addresses: []patchAddress{
+				{Value: "alice@example.com", Verified: true},
+				{Value: "bob@example.com"},
+			},
+			Metadata: map[string]interface{}{"plan": "free", "previous_plan": "free", "licenses": float64(1)},
+		}, i)
+
+		assert.Equal(t, []PatchOperation{
+			{Op: "add", Path: "/addresses/1", Value: json.RawMessage(`{"value":"bob@example.com"}`)},
+			{Op: "add", Path: "/metadata/licenses", Value: json.RawMessage(`1`)},
+			{Op: "add", Path: "/metadata/previous_plan", Value: json.RawMessage(`"free"`)},
+			{Op: "remove", Path: "/metadata/seats"},
+			{Op: "replace", Path: "/name", Value: json.RawMessage(`"Bob"`)},
+			{Op: "replace", Path: "/tags/0", Value: json.RawMessage(`"inserted"`)},
+			{Op: "add", Path: "/tags/2", Value: json.RawMessage(`"last"`)},
+		}, changes)
+	})
+
+	t.Run("case=removed fields are cleared", func(t *testing.T) {
+		i := newPatchIdentity()
+		_, err := ApplyJSONPatch([]byte(`[{"op": "remove", "path": "/tags"}, {"op": "remove", "path": "/metadata"}]`), i)
+		require.NoError(t, err)
+		assert.Nil(t, i.Tags)
+		assert.Nil(t, i.Metadata)
+	})
+
+	t.Run("case=errors leave the value unchanged", func(t *testing.T) {
+		for _, tc := range []struct {
+			patch    string
+			expected error
+		}{
+			{patch: `[{"op": "test", "path": "/name", "value": "Bob"}, {"op": "remove", "path": "/name"}]`, expected: ErrPatchTestFailed},
+			{patch: `[{"op": "remove", "path": "/does-not-exist"}]`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "replace", "path": "/tags/5", "value": "x"}]`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "add", "path": "/tags/01", "value": "x"}]`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "add", "path": "/name"}]`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "move", "from": "/metadata", "path": "/metadata/nested"}]`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "unknown", "path": "/name"}]`, expected: ErrInvalidPatch},
+			{patch: `{"op": "remove"}`, expected: ErrInvalidPatch},
+			{patch: `[{"op": "replace", "path": "/tags", "value": "not an array"}]`, expected: ErrInvalidPatch},
+		} {
+			i := newPatchIdentity()
+			_, err := ApplyJSONPatch([]byte(tc.patch), i)
+			assert.True(t, errors.Is(err, tc.expected), "%s: %+v", tc.patch, err)
+			assert.Equal(t, newPatchIdentity(), i, tc.patch)
+		}
+	})
+
+	t.Run("case=test compares numbers by value", func(t *testing.T) {
+		_, err := ApplyJSONPatch([]byte(`[{"op": "test", "path": "/metadata/seats", "value": 1.0}]`), newPatchIdentity())
+		assert.NoError(t, err)
+	})
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	i := newPatchIdentity()
+	changes, err := ApplyMergePatch([]byte(`{"name": "Bob", "tags": null, "metadata": {"plan": null, "tier": "gold"}}`), i)
+	require.NoError(t, err)
+
+	assert.Equal(t, &patchIdentity{
+		ID:        "id-1",
+		Name:      "Bob",
+		Addresses: []patchAddress{{Value: "alice@example.com", Verified: true}},
+		Metadata:  map[string]interface{}{"seats": float64(1), "tier": "gold"},
+	}, i)
+	assert.Equal(t, []PatchOperation{
+		{Op: "remove", Path: "/metadata/plan"},
+		{Op: "add", Path: "/metadata/tier", Value: json.RawMessage(`"gold"`)},
+		{Op: "replace", Path: "/name", Value: json.RawMessage(`"Bob"`)},
+		{Op: "remove", Path: "/tags"},
+	}, changes)
+
+	_, err = ApplyMergePatch([]byte(`{`), newPatchIdentity())
+	assert.True(t, errors.Is(err, ErrInvalidPatch))
+}
+
+func TestPatchOptions(t *testing.T) {
+	denied := WithDeniedPaths("/id", "/addresses/*/verified", "/metadata/plan")
+
+	for _, tc := range []struct {
+		name   string
+		apply  func(i *patchIdentity) error
+		denied bool
+	}{
+		{
+			name: "replace read-only field",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyJSONPatch([]byte(`[{"op": "replace", "path": "/id", "value": "id-2"}]`), i, denied)
+				return err
+			},
+			denied: true,
+		},
+		{
+			name: "merge read-only field",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyMergePatch([]byte(`{"addresses": [{"value": "alice@example.com", "verified": false}]}`), i, denied)
+				return err
+			},
+			denied: true,
+		},
+		{
+			name: "remove parent of read-only field",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyJSONPatch([]byte(`[{"op": "remove", "path": "/metadata"}]`), i, denied)
+				return err
+			},
+			denied: true,
+		},
+		{
+			name: "add element with read-only field",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyJSONPatch([]byte(`[{"op": "add", "path": "/addresses/-", "value": {"value": "x", "verified": true}}]`), i, denied)
+				return err
+			},
+			denied: true,
+		},
+		{
+			name: "add element without read-only field",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyMergePatch([]byte(`{"name": "Bob", "metadata": {"seats": 2}}`), i, denied)
+				return err
+			},
+		},
+		{
+			name: "replace with the same value",
+			apply: func(i *patchIdentity) error {
+				_, err := ApplyJSONPatch([]byte(`[{"op": "replace", "path": "/id", "value": "id-1"}]`), i, denied)
+				return err
+			},
+		},
+	} {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			i := newPatchIdentity()
+			err := tc.apply(i)
+			if tc.denied {
+				assert.True(t, errors.Is(err, ErrDeniedPath), "%+v", err)
+				assert.Equal(t, newPatchIdentity(), i)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("case=dry run", func(t *testing.T) {
+		i := newPatchIdentity()
+		changes, err := ApplyMergePatch([]byte(`{"name": "Bob"}`), i, WithDryRun())
+		require.NoError(t, err)
+		assert.Equal(t, []PatchOperation{{Op: "replace", Path: "/name", Value: json.RawMessage(`"Bob"`)}}, changes)
+		assert.Equal(t, newPatchIdentity(), i)
+	})
+
+	t.Run("case=escaped pointers", func(t *testing.T) {
+		doc := map[string]interface{}{"a/b": map[string]interface{}{"c~d": 1}}
+		changes, err := ApplyJSONPatch([]byte(`[{"op": "replace", "path": "/a~1b/c~0d", "value": 2}]`), &doc, WithDeniedPaths("/other"))
+		require.NoError(t, err)
+		assert.Equal(t, []PatchOperation{{Op: "replace", Path: "/a~1b/c~0d", Value: json.RawMessage(`2`)}}, changes)
+		assert.Equal(t, float64(2), doc["a/b"].(map[string]interface{})["c~d"])
+	})
+}
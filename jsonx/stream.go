@@ -0,0 +1,273 @@
+package jsonx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultMaxElementSize is the default maximum size of an element of a stream in bytes.
+const DefaultMaxElementSize = 16 << 20
+
+type (
+	// ElementError is an error of a single element of a stream, for example because it is not
+	// valid JSON or because the callback failed to process it.
+	ElementError struct {
+		// Index is the zero-based index of the element in the stream.
+		Index int
+		// Line is the line of the element for NDJSON streams, starting at 1, or 0 for arrays.
+		Line int
+		// Err is the cause of the error.
+		Err error
+	}
+
+	// StreamResult summarizes a stream.
+	StreamResult struct {
+		// Processed is the number of elements which were processed successfully.
+		Processed int
+		// Failed is the number of elements which failed and were skipped by the error handler.
+		Failed int
+	}
+
+	// StreamOption configures StreamElements.
+	StreamOption func(o *streamOptions)
+
+	streamOptions struct {
+		onError        func(err *ElementError) error
+		maxElementSize int
+	}
+)
+
+func (e *ElementError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("element %d on line %d: %s", e.Index, e.Line, e.Err)
+	}
+	return fmt.Sprintf("element %d: %s", e.Index, e.Err)
+}
+
+func (e *ElementError) Unwrap() error {
+	return e.Err
+}
+
+// WithErrorHandler sets the handler of element errors. If it returns nil, the element is
+// skipped and the stream continues, otherwise the stream is aborted with the returned error.
+// By default, the first element error aborts the stream.
+//
+// Syntax errors and elements larger than the maximum element size in JSON arrays can not be
+// recovered from, because the end of the element is unknown, and always abort the stream. In
+// NDJSON streams, the malformed or too large line is passed to the handler.
+func WithErrorHandler(h func(err *ElementError) error) StreamOption {
+	return func(o *streamOptions) {
+		o.onError = h
+	}
+}
+
+// WithMaxElementSize sets the maximum size of an element in bytes. Larger elements are element
+// errors, and are not read into memory completely. Defaults to DefaultMaxElementSize.
+func WithMaxElementSize(size int) StreamOption {
+	return func(o *streamOptions) {
+		o.maxElementSize = size
+	}
+}
+
+// StreamElements calls fn for each element of a JSON array or of newline-delimited JSON
+// (NDJSON) without loading the whole document into memory, so that import endpoints can handle
+// payloads of several gigabytes. Streams which start with "[" are decoded as an array, all
+// others as NDJSON:
+//
+//	res, err := jsonx.StreamElements(r.Context(), r.Body, func(i int, raw json.RawMessage) error {
+//		var identity Identity
+//		if err := json.Unmarshal(raw, &identity); err != nil {
+//			return err
+//		}
+//		return m.CreateIdentity(r.Context(), &identity)
+//	}, jsonx.WithErrorHandler(func(err *jsonx.ElementError) error {
+//		failures = append(failures, err)
+//		return nil
+//	}))
+//
+// The context is checked between elements.
+func StreamElements(ctx context.Context, r io.Reader, fn func(index int, raw json.RawMessage) error, opts ...StreamOption) (*StreamResult, error) {
+	o := &streamOptions{
+		onError:        func(err *ElementError) error { return err },
+		maxElementSize: DefaultMaxElementSize,
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	br := bufio.NewReader(r)
+	first, err := peekNonSpace(br)
+	if errors.Is(err, io.EOF) {
+		return &StreamResult{}, nil
+	} else if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	s := &stream{ctx: ctx, fn: fn, o: o, res: new(StreamResult)}
+	if first == '[' {
+		err = s.array(br)
+	} else {
+		err = s.ndjson(br)
+	}
+	return s.res, err
+}
+
+type stream struct {
+	ctx   context.Context
+	fn    func(index int, raw json.RawMessage) error
+	o     *streamOptions
+	res   *StreamResult
+	index int
+}
+
+func peekNonSpace(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return 0, err
+		}
+		switch b[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = br.ReadByte()
+		default:
+			return b[0], nil
+		}
+	}
+}
+
+// element processes one element and returns an error if the stream must be aborted.
+func (s *stream) element(line int, raw json.RawMessage, err error) error {
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return errors.WithStack(ctxErr)
+	}
+
+	index := s.index
+	s.index++
+	if err == nil {
+		err = s.fn(index, raw)
+	}
+	if err == nil {
+		s.res.Processed++
+		return nil
+	}
+
+	if err := s.o.onError(&ElementError{Index: index, Line: line, Err: err}); err != nil {
+		return err
+	}
+	s.res.Failed++
+	return nil
+}
+
+func (s *stream) array(r io.Reader) error {
+	lr := &elementLimitReader{r: r, limit: 2*int64(s.o.maxElementSize) + 1}
+	dec := json.NewDecoder(lr)
+	if _, err := dec.Token(); err != nil {
+		return errors.WithStack(err)
+	}
+
+	for {
+		// The decoder also reads the separator and whitespace in front of the element, and the
+		// byte after it to find the end of numbers, so it may read up to twice the maximum
+		// element size. The size of the element is checked exactly once it was decoded.
+		start := dec.InputOffset()
+		lr.limit = start + 2*int64(s.o.maxElementSize) + 1
+		if !dec.More() {
+			break
+		}
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, errElementTooLarge) || len(raw) > s.o.maxElementSize {
+			return errors.WithStack(&ElementError{Index: s.index, Err: errors.Errorf("the element is larger than %d bytes", s.o.maxElementSize)})
+		} else if err != nil {
+			return errors.Wrapf(err, "unable to decode element %d at offset %d", s.index, start)
+		}
+
+		if err := s.element(0, raw, nil); err != nil {
+			return err
+		}
+	}
+
+	if _, err := dec.Token(); err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+func (s *stream) ndjson(br *bufio.Reader) error {
+	for line := 1; ; line++ {
+		raw, tooLong, err := s.readLine(br)
+		if errors.Is(err, io.EOF) && len(raw) == 0 && !tooLong {
+			return nil
+		} else if err != nil && !errors.Is(err, io.EOF) {
+			return errors.WithStack(err)
+		}
+		eof := errors.Is(err, io.EOF)
+
+		raw = bytes.TrimSpace(raw)
+		switch {
+		case tooLong:
+			err = s.element(line, nil, errors.Errorf("the element is larger than %d bytes", s.o.maxElementSize))
+		case len(raw) == 0:
+			err = nil
+		case !json.Valid(raw):
+			err = s.element(line, nil, errors.New("the line is not valid JSON"))
+		default:
+			err = s.element(line, append(json.RawMessage{}, raw...), nil)
+		}
+		if err != nil {
+			return err
+		}
+		if eof {
+			return nil
+		}
+	}
+}
+
+// readLine reads a line and discards the rest of it if it is longer than the maximum element
+// size.
+func (s *stream) readLine(br *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, isPrefix, err := br.ReadLine()
+		if err != nil {
+			return line, tooLong, err
+		}
+		if !tooLong {
+			if len(line)+len(chunk) > s.o.maxElementSize {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if !isPrefix {
+			return line, tooLong, nil
+		}
+	}
+}
+
+var errElementTooLarge = errors.New("the element exceeds the maximum element size")
+
+// elementLimitReader fails reads beyond the limit, which is an offset of the input, so that the
+// decoder does not buffer elements which are larger than the maximum element size.
+type elementLimitReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (r *elementLimitReader) Read(p []byte) (int, error) {
+	if r.read >= r.limit {
+		return 0, errElementTooLarge
+	}
+	if int64(len(p)) > r.limit-r.read {
+		p = p[:r.limit-r.read]
+	}
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+	return n, err
+}
@@ -0,0 +1,180 @@
+package jsonx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(t *testing.T, in string, opts ...StreamOption) ([]string, *StreamResult, error) {
+	var elements []string
+	res, err := StreamElements(context.Background(), strings.NewReader(in), func(i int, raw json.RawMessage) error {
+		var v struct {
+			ID int `json:"id"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return err
+		}
+		elements = append(elements, fmt.Sprintf("%d:%d", i, v.ID))
+		return nil
+	}, opts...)
+	return elements, res, err
+}
+
+func TestStreamElements(t *testing.T) {
+	var skipped []*ElementError
+	skip := WithErrorHandler(func(err *ElementError) error {
+		skipped = append(skipped, err)
+		return nil
+	})
+
+	for _, tc := range []struct {
+		name, in string
+	}{
+		{name: "array", in: ` [{"id": 1}, {"id": 2},
+{"id": 3}] `},
+		{name: "ndjson", in: "{\"id\": 1}\n{\"id\": 2}\r\n\n{\"id\": 3}"},
+	} {
+		t.Run("format="+tc.name, func(t *testing.T) {
+			elements, res, err := collect(t, tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"0:1", "1:2", "2:3"}, elements)
+			assert.Equal(t, &StreamResult{Processed: 3}, res)
+		})
+	}
+
+	t.Run("case=empty", func(t *testing.T) {
+		for _, in := range []string{"", "  \n", "[]"} {
+			elements, res, err := collect(t, in)
+			require.NoError(t, err)
+			assert.Empty(t, elements)
+			assert.Equal(t, &StreamResult{}, res)
+		}
+	})
+
+	t.Run("case=element errors abort by default", func(t *testing.T) {
+		elements, res, err := collect(t, `[{"id": 1}, {"id": "two"}, {"id": 3}]`)
+		var elementErr *ElementError
+		require.True(t, errors.As(err, &elementErr), "%+v", err)
+		assert.Equal(t, 1, elementErr.Index)
+		assert.Equal(t, []string{"0:1"}, elements)
+		assert.Equal(t, &StreamResult{Processed: 1}, res)
+	})
+
+	t.Run("case=recovery in arrays", func(t *testing.T) {
+		skipped = nil
+		elements, res, err := collect(t, `[{"id": 1}, {"id": "two"}, {"id": 3}]`, skip)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0:1", "2:3"}, elements)
+		assert.Equal(t, &StreamResult{Processed: 2, Failed: 1}, res)
+		require.Len(t, skipped, 1)
+		assert.Equal(t, 1, skipped[0].Index)
+
+		_, _, err = collect(t, `[{"id": 1}, {"id": ]`, skip)
+		assert.Error(t, err, "syntax errors in arrays can not be recovered from")
+	})
+
+	t.Run("case=too large elements abort arrays", func(t *testing.T) {
+		var read int
+		in := `[{"id": 1}, {"id": 2, "padding": "` + strings.Repeat("x", 1<<20) + `"}, {"id": 3}]`
+		r := &countingReader{r: strings.NewReader(in), n: &read}
+		var elements []int
+		res, err := StreamElements(context.Background(), r, func(i int, raw json.RawMessage) error {
+			elements = append(elements, i)
+			return nil
+		}, skip, WithMaxElementSize(64))
+
+		var elementErr *ElementError
+		require.True(t, errors.As(err, &elementErr), "%+v", err)
+		assert.Equal(t, 1, elementErr.Index)
+		assert.Contains(t, elementErr.Error(), "larger than 64 bytes")
+		assert.Equal(t, []int{0}, elements)
+		assert.Equal(t, &StreamResult{Processed: 1}, res)
+		assert.Less(t, read, 64<<10, "the element must not be read completely")
+
+		elements = nil
+		_, err = StreamElements(context.Background(), strings.NewReader(`[{"id": 1},   `+strings.Repeat("1", 64)+`]`), func(i int, raw json.RawMessage) error {
+			elements = append(elements, i)
+			return nil
+		}, WithMaxElementSize(64))
+		require.NoError(t, err, "elements of the maximum size are allowed")
+		assert.Equal(t, []int{0, 1}, elements)
+	})
+
+	t.Run("case=recovery in ndjson", func(t *testing.T) {
+		skipped = nil
+		in := strings.Join([]string{
+			`{"id": 1}`,
+			`{"id": `,
+			`{"id": 3, "padding": "` + strings.Repeat("x", 5000) + `"}`,
+			`{"id": "four"}`,
+			`{"id": 5}`,
+		}, "\n")
+		elements, res, err := collect(t, in, skip, WithMaxElementSize(4096))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"0:1", "4:5"}, elements)
+		assert.Equal(t, &StreamResult{Processed: 2, Failed: 3}, res)
+
+		require.Len(t, skipped, 3)
+		for k, line := range []int{2, 3, 4} {
+			assert.Equal(t, line, skipped[k].Line)
+		}
+		assert.Equal(t, "element 1 on line 2: the line is not valid JSON", skipped[0].Error())
+	})
+
+	t.Run("case=handler aborts", func(t *testing.T) {
+		abort := errors.New("too many errors")
+		_, _, err := collect(t, "{\"id\": \"one\"}\n{\"id\": 2}", WithErrorHandler(func(*ElementError) error { return abort }))
+		assert.True(t, errors.Is(err, abort))
+	})
+
+	t.Run("case=context", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var count int
+		_, err := StreamElements(ctx, strings.NewReader("{}\n{}\n{}"), func(int, json.RawMessage) error {
+			count++
+			cancel()
+			return nil
+		})
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("case=large stream", func(t *testing.T) {
+		const n = 100000
+		r, w := io.Pipe()
+		go func() {
+			_, _ = io.WriteString(w, "[")
+			for i := 0; i < n; i++ {
+				if i > 0 {
+					_, _ = io.WriteString(w, ",")
+				}
+				_, _ = fmt.Fprintf(w, `{"id":%d}`, i)
+			}
+			_, _ = io.WriteString(w, "]")
+			_ = w.Close()
+		}()
+
+		res, err := StreamElements(context.Background(), r, func(int, json.RawMessage) error { return nil })
+		require.NoError(t, err)
+		assert.Equal(t, n, res.Processed)
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	*r.n += n
+	return n, err
+}
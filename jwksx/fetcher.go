@@ -1,65 +1,215 @@
 package jwksx
 
 import (
-	"encoding/json"
+	"io"
+	"math/rand"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
-	"gopkg.in/square/go-jose.v2"
+	"github.com/square/go-jose/v3"
+	"golang.org/x/sync/singleflight"
 )
 
-// Fetcher is a small helper for fetching JSON Web Keys from remote endpoints.
-type Fetcher struct {
-	sync.RWMutex
-	remote string
-	c      *http.Client
-	keys   map[string]jose.JSONWebKey
+type (
+	// Fetcher is a small helper for fetching JSON Web Keys from remote endpoints.
+	//
+	// Fetched keys are cached for the max-age of the Cache-Control response header, or the
+	// default TTL if it is not set. Before the cache expires, it is refreshed in the background,
+	// so that requests are not blocked by the refresh. If fetching fails after the cache expired,
+	// the stale keys are used for a grace period.
+	//
+	// An unknown key ID makes the fetcher fetch the keys again, because the remote might have
+	// rotated its keys. To prevent forged tokens from flooding the remote with requests, the keys
+	// are fetched at most once per minimum refresh interval, and concurrent fetches are merged.
+	Fetcher struct {
+		sync.RWMutex
+		remote      string
+		c           *http.Client
+		keys        map[string]jose.JSONWebKey
+		defaultTTL  time.Duration
+		grace       time.Duration
+		minInterval time.Duration
+		now         func() time.Time
+		flight      singleflight.Group
+
+		refreshAt  time.Time
+		expiresAt  time.Time
+		fetchedAt  time.Time
+		fetchErr   error
+		refreshing bool
+	}
+	// FetcherOption configures a Fetcher.
+	FetcherOption func(*Fetcher)
+)
+
+// WithHTTPClient sets the HTTP client used to fetch the keys.
+func WithHTTPClient(c *http.Client) FetcherOption {
+	return func(f *Fetcher) {
+		f.c = c
+	}
+}
+
+// WithDefaultTTL sets how long keys are cached if the response has no max-age. Defaults to one hour.
+func WithDefaultTTL(ttl time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		f.defaultTTL = ttl
+	}
+}
+
+// WithStaleGracePeriod sets how long expired keys are used if they can not be fetched again.
+// Defaults to one hour.
+func WithStaleGracePeriod(grace time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		f.grace = grace
+	}
+}
+
+// WithMinRefreshInterval sets how long the fetcher waits before it fetches the keys again,
+// regardless of unknown key IDs or failed fetches. Defaults to ten seconds.
+func WithMinRefreshInterval(interval time.Duration) FetcherOption {
+	return func(f *Fetcher) {
+		f.minInterval = interval
+	}
 }
 
 // NewFetcher returns a new fetcher that can download JSON Web Keys from remote endpoints.
-func NewFetcher(remote string) *Fetcher {
-	return &Fetcher{
-		remote: remote,
-		c:      http.DefaultClient,
-		keys:   make(map[string]jose.JSONWebKey),
+func NewFetcher(remote string, opts ...FetcherOption) *Fetcher {
+	f := &Fetcher{
+		remote:      remote,
+		c:           http.DefaultClient,
+		keys:        make(map[string]jose.JSONWebKey),
+		defaultTTL:  time.Hour,
+		grace:       time.Hour,
+		minInterval: 10 * time.Second,
+		now:         time.Now,
+	}
+	for _, o := range opts {
+		o(f)
 	}
+	return f
 }
 
 // GetKey retrieves a JSON Web Key from the cache, fetches it from a remote if it is not yet cached or returns an error.
 func (f *Fetcher) GetKey(kid string) (*jose.JSONWebKey, error) {
+	now := f.now()
+
+	f.Lock()
+	k, found := f.keys[kid]
+	expiresAt := f.expiresAt
+	fresh := now.Before(expiresAt)
+	refresh := fresh && !f.refreshing && !now.Before(f.refreshAt)
+	if refresh {
+		f.refreshing = true
+	}
+	recent := now.Before(f.fetchedAt.Add(f.minInterval))
+	fetchErr := f.fetchErr
+	f.Unlock()
+
+	if refresh {
+		go func() {
+			// Errors are ignored because the keys are fetched again once they expired.
+			_ = f.fetch()
+			f.Lock()
+			f.refreshing = false
+			f.Unlock()
+		}()
+	}
+	if found && (fresh || (recent && fetchErr == nil)) {
+		return &k, nil
+	}
+
+	if recent {
+		// The keys were fetched moments ago, so fetching them again would not help.
+		if found && now.Before(expiresAt.Add(f.grace)) {
+			return &k, nil
+		}
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		return nil, errors.Errorf("unable to find JSON Web Key with ID: %s", kid)
+	}
+
+	if err := f.fetch(); err != nil {
+		if found && now.Before(expiresAt.Add(f.grace)) {
+			// Serve the stale key while the remote is unavailable.
+			return &k, nil
+		}
+		return nil, err
+	}
+
 	f.RLock()
+	defer f.RUnlock()
 	if k, ok := f.keys[kid]; ok {
-		f.RUnlock()
 		return &k, nil
 	}
-	f.RUnlock()
 
+	return nil, errors.Errorf("unable to find JSON Web Key with ID: %s", kid)
+}
+
+// fetch fetches the keys, or waits for the fetch which is already in progress.
+func (f *Fetcher) fetch() error {
+	_, err, _ := f.flight.Do(f.remote, func() (interface{}, error) {
+		start := f.now()
+		err := f.doFetch()
+		f.Lock()
+		f.fetchedAt, f.fetchErr = start, err
+		f.Unlock()
+		return nil, err
+	})
+	return err
+}
+
+func (f *Fetcher) doFetch() error {
 	res, err := f.c.Get(f.remote)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
 	defer res.Body.Close()
 	if res.StatusCode != http.StatusOK {
-		return nil, errors.Errorf("expected status code 200 but got %d when requesting %s", res.StatusCode, f.remote)
+		return errors.Errorf("expected status code 200 but got %d when requesting %s", res.StatusCode, f.remote)
 	}
 
-	var set jose.JSONWebKeySet
-	if err := json.NewDecoder(res.Body).Decode(&set); err != nil {
-		return nil, errors.WithStack(err)
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return errors.WithStack(err)
 	}
+	set, err := UnmarshalKeySet(body)
+	if err != nil {
+		return err
+	}
+
+	ttl := f.ttl(res.Header.Get("Cache-Control"))
+	now := f.now()
 
+	f.Lock()
+	defer f.Unlock()
+	f.keys = make(map[string]jose.JSONWebKey, len(set.Keys))
 	for _, k := range set.Keys {
-		f.Lock()
 		f.keys[k.KeyID] = k
-		f.Unlock()
 	}
+	f.expiresAt = now.Add(ttl)
+	// Refresh after 75 to 90 percent of the TTL. The jitter prevents many instances from
+	// refreshing at the same time.
+	f.refreshAt = now.Add(time.Duration(float64(ttl) * (0.75 + 0.15*rand.Float64()))) // #nosec G404 - no security relevance
+	return nil
+}
 
-	f.RLock()
-	defer f.RUnlock()
-	if k, ok := f.keys[kid]; ok {
-		return &k, nil
+// ttl returns the max-age of the Cache-Control header, or the default TTL if it is not set.
+func (f *Fetcher) ttl(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == "no-cache" || directive == "no-store":
+			return 0
+		case strings.HasPrefix(directive, "max-age="):
+			if seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age=")); err == nil && seconds >= 0 {
+				return time.Duration(seconds) * time.Second
+			}
+		}
 	}
-
-	return nil, errors.Errorf("unable to find JSON Web Key with ID: %s", kid)
+	return f.defaultTTL
 }
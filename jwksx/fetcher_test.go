@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -46,7 +49,143 @@ func TestFetcher(t *testing.T) {
 	assert.EqualValues(t, secret, fmt.Sprintf("%s", k.Key))
 	assert.Equal(t, 1, called)
 
+	_, err = f.GetKey("does-not-exist")
+	require.Error(t, err)
+	assert.Equal(t, 1, called, "the keys were fetched moments ago")
+
+	f.now = func() time.Time { return time.Now().Add(time.Minute) }
 	_, err = f.GetKey("does-not-exist")
 	require.Error(t, err)
 	assert.Equal(t, 2, called)
 }
+
+func TestFetcherRateLimit(t *testing.T) {
+	var called int32
+	release := make(chan struct{})
+	var h http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&called, 1)
+		<-release
+		w.Write([]byte(keys))
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	var mu sync.Mutex
+	now := time.Now()
+	f := NewFetcher(ts.URL, WithMinRefreshInterval(time.Minute))
+	f.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+
+	var wg sync.WaitGroup
+	for k := 0; k < 10; k++ {
+		wg.Add(1)
+		go func(k int) {
+			defer wg.Done()
+			_, _ = f.GetKey(fmt.Sprintf("forged-%d", k))
+		}(k)
+	}
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&called) == 1 }, time.Second, 10*time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&called), "concurrent fetches are merged")
+
+	for k := 0; k < 10; k++ {
+		_, err := f.GetKey(fmt.Sprintf("forged-%d", k))
+		require.Error(t, err)
+	}
+	assert.EqualValues(t, 1, atomic.LoadInt32(&called), "unknown key IDs do not fetch the keys again within the interval")
+
+	mu.Lock()
+	now = now.Add(time.Minute)
+	mu.Unlock()
+	_, err := f.GetKey("forged")
+	require.Error(t, err)
+	assert.EqualValues(t, 2, atomic.LoadInt32(&called))
+}
+
+func TestFetcherCache(t *testing.T) {
+	var called int32
+	var fail int32
+	var h http.HandlerFunc = func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&called, 1)
+		if atomic.LoadInt32(&fail) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=100")
+		w.Write([]byte(keys))
+	}
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	var mu sync.Mutex
+	now := time.Now()
+	advance := func(d time.Duration) {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(d)
+	}
+
+	f := NewFetcher(ts.URL, WithStaleGracePeriod(time.Minute))
+	f.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	}
+	const kid = "7d5f5ad0674ec2f2960b1a34f33370a0f71471fa0e3ef0c0a692977d276dafe8"
+
+	_, err := f.GetKey(kid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&called))
+
+	advance(50 * time.Second)
+	_, err = f.GetKey(kid)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&called), "the keys are cached for max-age")
+
+	advance(45 * time.Second)
+	_, err = f.GetKey(kid)
+	require.NoError(t, err, "the cached key is returned while refreshing")
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&called) == 2 }, time.Second, 10*time.Millisecond,
+		"the keys are refreshed in the background before they expire")
+	assert.Eventually(t, func() bool {
+		f.RLock()
+		defer f.RUnlock()
+		return !f.refreshing
+	}, time.Second, 10*time.Millisecond)
+
+	atomic.StoreInt32(&fail, 1)
+	advance(130 * time.Second)
+	_, err = f.GetKey(kid)
+	require.NoError(t, err, "stale keys are served within the grace period")
+	assert.EqualValues(t, 3, atomic.LoadInt32(&called))
+
+	advance(time.Minute)
+	_, err = f.GetKey(kid)
+	require.Error(t, err, "stale keys are not served after the grace period")
+
+	atomic.StoreInt32(&fail, 0)
+	_, err = f.GetKey(kid)
+	require.Error(t, err, "the keys are not fetched again within the minimum refresh interval")
+
+	advance(10 * time.Second)
+	_, err = f.GetKey(kid)
+	require.NoError(t, err)
+}
+
+func TestFetcherTTL(t *testing.T) {
+	f := NewFetcher("", WithDefaultTTL(time.Minute))
+	for in, expected := range map[string]time.Duration{
+		"":                           time.Minute,
+		"max-age=30":                 30 * time.Second,
+		"public, Max-Age=10":         10 * time.Second,
+		"no-store":                   0,
+		"max-age=foo":                time.Minute,
+		"must-revalidate, max-age=0": 0,
+	} {
+		assert.Equal(t, expected, f.ttl(in), in)
+	}
+}
@@ -31,12 +31,14 @@ import (
 
 	"github.com/pkg/errors"
 
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
 	"github.com/google/uuid"
 	"github.com/square/go-jose/v3"
 	"golang.org/x/crypto/ed25519"
 )
 
-// GenerateSigningKeys generates a JSON Web Key Set for signing.
+// GenerateSigningKeys generates a JSON Web Key Set for signing. Keys for ES256K are on the
+// secp256k1 curve, see ES256K for how to use them.
 func GenerateSigningKeys(id, alg string, bits int) (*jose.JSONWebKeySet, error) {
 	if id == "" {
 		id = uuid.New().String()
@@ -64,7 +66,7 @@ func GenerateSigningKeys(id, alg string, bits int) (*jose.JSONWebKeySet, error)
 func GenerateSigningKeysAvailableAlgorithms() []string {
 	return []string{
 		string(jose.HS256), string(jose.HS384), string(jose.HS512),
-		string(jose.ES256), string(jose.ES384), string(jose.ES512), string(jose.EdDSA),
+		string(jose.ES256), string(jose.ES384), string(jose.ES512), string(ES256K), string(jose.EdDSA),
 		string(jose.RS256), string(jose.RS384), string(jose.RS512), string(jose.PS256), string(jose.PS384), string(jose.PS512),
 	}
 }
@@ -72,11 +74,12 @@ func GenerateSigningKeysAvailableAlgorithms() []string {
 // generate generates keypair for corresponding SignatureAlgorithm.
 func generate(alg jose.SignatureAlgorithm, bits int) (crypto.PrivateKey, error) {
 	switch alg {
-	case jose.ES256, jose.ES384, jose.ES512, jose.EdDSA:
+	case jose.ES256, jose.ES384, jose.ES512, ES256K, jose.EdDSA:
 		keylen := map[jose.SignatureAlgorithm]int{
 			jose.ES256: 256,
 			jose.ES384: 384,
 			jose.ES512: 521, // sic!
+			ES256K:     256,
 			jose.EdDSA: 256,
 		}
 		if bits != 0 && bits != keylen[alg] {
@@ -141,6 +144,10 @@ func generate(alg jose.SignatureAlgorithm, bits int) (crypto.PrivateKey, error)
 			return nil, errors.Wrapf(err, "jwks: unable to generate key")
 		}
 		return key, nil
+	case ES256K:
+		// The cryptographic operations are implemented using constant-time algorithms.
+		key, err := secp256k1.GeneratePrivateKey()
+		return key, errors.Wrapf(err, "jwks: unable to generate key")
 	default:
 		return nil, errors.Errorf(`jwksx: available algorithms are "%+v" but unknown algorithm was requested: "%s"`, GenerateSigningKeysAvailableAlgorithms(), alg)
 	}
@@ -0,0 +1,141 @@
+package jwksx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+)
+
+// Thumbprint returns the base64url encoded SHA-256 JWK thumbprint (RFC 7638) of the key, which
+// is a stable identifier of the key and can be used as its key ID.
+func Thumbprint(key *jose.JSONWebKey) (string, error) {
+	// go-jose does not compute thumbprints of symmetric keys and keys on the secp256k1 curve,
+	// and its template for Ed25519 keys is malformed, so these are computed here.
+	var input string
+	if pub, ok := secp256k1PublicKey(key.Key); ok {
+		x, y := secp256k1Coordinates(pub)
+		input = fmt.Sprintf(`{"crv":"secp256k1","kty":"EC","x":"%s","y":"%s"}`, x, y)
+	}
+	switch k := key.Key.(type) {
+	case []byte:
+		input = fmt.Sprintf(`{"k":"%s","kty":"oct"}`, base64.RawURLEncoding.EncodeToString(k))
+	case ed25519.PublicKey:
+		input = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(k))
+	case ed25519.PrivateKey:
+		input = fmt.Sprintf(`{"crv":"Ed25519","kty":"OKP","x":"%s"}`, base64.RawURLEncoding.EncodeToString(k.Public().(ed25519.PublicKey)))
+	}
+	if input != "" {
+		sum := sha256.Sum256([]byte(input))
+		return base64.RawURLEncoding.EncodeToString(sum[:]), nil
+	}
+
+	sum, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(sum), nil
+}
+
+// ParseKey parses a JSON Web Key or a PEM encoded public or private key, for example an
+// Ed25519 key in PKIX or PKCS #8 format. If the key has no algorithm, it is inferred from the
+// key type, and if it has no key ID, its thumbprint is used. Keys on the secp256k1 curve, which
+// ES256K uses, are supported as well.
+func ParseKey(data []byte) (*jose.JSONWebKey, error) {
+	var key jose.JSONWebKey
+	if block, _ := pem.Decode(data); block != nil {
+		parsed, err := parsePEMKey(block)
+		if err != nil {
+			return nil, err
+		}
+		key = jose.JSONWebKey{Key: parsed, Use: "sig"}
+	} else if parsed, err := UnmarshalKey(data); err != nil {
+		return nil, errors.Wrap(err, "jwksx: unable to parse key as PEM or JSON Web Key")
+	} else {
+		key = *parsed
+	}
+
+	// Keys on the secp256k1 curve were validated when they were parsed.
+	if _, ok := secp256k1PublicKey(key.Key); !ok && !key.Valid() {
+		return nil, errors.New("jwksx: the key is not valid")
+	}
+
+	if key.Algorithm == "" {
+		alg, err := algorithmOf(key.Key)
+		if err != nil {
+			return nil, err
+		}
+		key.Algorithm = alg
+	}
+	if key.KeyID == "" {
+		kid, err := Thumbprint(&key)
+		if err != nil {
+			return nil, err
+		}
+		key.KeyID = kid
+	}
+	return &key, nil
+}
+
+func parsePEMKey(block *pem.Block) (interface{}, error) {
+	if key, ok, err := parseSecp256k1PEMKey(block); ok {
+		return key, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		return key, errors.Wrap(err, "jwksx: unable to parse public key")
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "jwksx: unable to parse private key")
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "jwksx: unable to parse private key")
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		return key, errors.Wrap(err, "jwksx: unable to parse private key")
+	}
+	return nil, errors.Errorf(`jwksx: unsupported PEM block type "%s"`, block.Type)
+}
+
+// algorithmOf returns the default signing algorithm of the key.
+func algorithmOf(key interface{}) (string, error) {
+	var curve elliptic.Curve
+	switch k := key.(type) {
+	case ed25519.PublicKey, ed25519.PrivateKey:
+		return string(jose.EdDSA), nil
+	case *rsa.PublicKey, *rsa.PrivateKey:
+		return string(jose.RS256), nil
+	case []byte:
+		return string(jose.HS256), nil
+	case *secp256k1.PublicKey, *secp256k1.PrivateKey:
+		return string(ES256K), nil
+	case *ecdsa.PublicKey:
+		curve = k.Curve
+	case *ecdsa.PrivateKey:
+		curve = k.Curve
+	default:
+		return "", errors.Errorf("jwksx: unsupported key type %T", key)
+	}
+
+	switch curve {
+	case elliptic.P256():
+		return string(jose.ES256), nil
+	case elliptic.P384():
+		return string(jose.ES384), nil
+	case elliptic.P521():
+		return string(jose.ES512), nil
+	}
+	return "", errors.Errorf(`jwksx: unsupported elliptic curve "%s"`, curve.Params().Name)
+}
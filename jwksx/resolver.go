@@ -0,0 +1,126 @@
+package jwksx
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+
+	"github.com/ory/x/stringslice"
+)
+
+var (
+	// ErrUnknownIssuer is returned if the issuer is not configured.
+	ErrUnknownIssuer = errors.New("jwksx: the issuer is not trusted")
+
+	// ErrAlgorithmNotAllowed is returned if the algorithm is not allowed for the issuer or does
+	// not match the algorithm of the key.
+	ErrAlgorithmNotAllowed = errors.New("jwksx: the algorithm is not allowed")
+
+	// ErrKeyNotFound is returned if no key with the key ID was found.
+	ErrKeyNotFound = errors.New("jwksx: unable to find a JSON Web Key with the key ID")
+)
+
+type (
+	// Issuer is an identity provider whose tokens are verified with the keys of its JWKS URL.
+	Issuer struct {
+		// Issuer is the "iss" claim of the tokens.
+		Issuer string `json:"issuer"`
+
+		// JWKSURL is the URL of the issuer's JSON Web Key Set.
+		JWKSURL string `json:"jwks_url"`
+
+		// AllowedAlgorithms are the signing algorithms accepted from this issuer, for example
+		// "RS256". Must not be empty.
+		AllowedAlgorithms []string `json:"allowed_algorithms"`
+	}
+
+	// Resolver selects the key to verify a token with from the key sets of several issuers,
+	// using the "iss" claim and the "kid" header of the token.
+	Resolver struct {
+		issuers []Issuer
+		sources map[string]*Fetcher
+	}
+)
+
+// NewResolver returns a resolver for the issuers. The options are passed to the fetcher of
+// each issuer's key set.
+func NewResolver(issuers []Issuer, opts ...FetcherOption) (*Resolver, error) {
+	r := &Resolver{issuers: issuers, sources: make(map[string]*Fetcher, len(issuers))}
+	for _, i := range issuers {
+		if i.Issuer == "" || i.JWKSURL == "" {
+			return nil, errors.New("jwksx: the issuer and its JWKS URL must be set")
+		}
+		if len(i.AllowedAlgorithms) == 0 {
+			return nil, errors.Errorf("jwksx: no algorithms are allowed for issuer %s", i.Issuer)
+		}
+		if _, ok := r.sources[i.Issuer]; ok {
+			return nil, errors.Errorf("jwksx: issuer %s is configured more than once", i.Issuer)
+		}
+		r.sources[i.Issuer] = NewFetcher(i.JWKSURL, opts...)
+	}
+	return r, nil
+}
+
+// Resolve returns the key with the key ID from the key set of the issuer, if the algorithm is
+// allowed for the issuer and matches the algorithm of the key.
+func (r *Resolver) Resolve(iss, kid, alg string) (*jose.JSONWebKey, error) {
+	var issuer *Issuer
+	for k := range r.issuers {
+		if r.issuers[k].Issuer == iss {
+			issuer = &r.issuers[k]
+			break
+		}
+	}
+	if issuer == nil {
+		return nil, errors.Wrapf(ErrUnknownIssuer, "issuer %s", iss)
+	}
+
+	if !stringslice.Has(issuer.AllowedAlgorithms, alg) {
+		return nil, errors.Wrapf(ErrAlgorithmNotAllowed, "algorithm %s for issuer %s", alg, iss)
+	}
+
+	key, err := r.sources[iss].GetKey(kid)
+	if err != nil {
+		return nil, errors.Wrapf(ErrKeyNotFound, "key ID %s of issuer %s: %s", kid, iss, err)
+	}
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return nil, errors.Wrapf(ErrAlgorithmNotAllowed, "algorithm %s does not match the key's algorithm %s", alg, key.Algorithm)
+	}
+	return key, nil
+}
+
+// Verify verifies the signature of a compact JSON Web Signature, for example a JSON Web Token,
+// with the key resolved from its "iss" claim and "kid" header, and returns the payload.
+func (r *Resolver) Verify(compact string) ([]byte, error) {
+	jws, err := jose.ParseSigned(compact)
+	if err != nil {
+		return nil, errors.Wrap(err, "jwksx: unable to parse the token")
+	}
+	if len(jws.Signatures) != 1 {
+		return nil, errors.New("jwksx: the token must have exactly one signature")
+	}
+	header := jws.Signatures[0].Header
+
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(jws.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		return nil, errors.Wrap(err, "jwksx: unable to decode the token claims")
+	}
+
+	key, err := r.Resolve(claims.Issuer, header.KeyID, header.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	var verificationKey interface{} = key
+	if _, ok := secp256k1PublicKey(key.Key); ok {
+		if verificationKey, err = NewES256KVerifier(key); err != nil {
+			return nil, err
+		}
+	}
+
+	payload, err := jws.Verify(verificationKey)
+	return payload, errors.Wrap(err, "jwksx: unable to verify the token signature")
+}
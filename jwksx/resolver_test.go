@@ -0,0 +1,90 @@
+package jwksx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolver(t *testing.T) {
+	newIssuer := func(t *testing.T, kid string, alg jose.SignatureAlgorithm) (*httptest.Server, jose.Signer) {
+		set, err := GenerateSigningKeys(kid, string(alg), 0)
+		require.NoError(t, err)
+		pub, ok := publicKey(set.Keys[0])
+		require.True(t, ok)
+		public, err := MarshalKeySet(&jose.JSONWebKeySet{Keys: []jose.JSONWebKey{pub}})
+		require.NoError(t, err)
+
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, err := w.Write(public)
+			require.NoError(t, err)
+		}))
+		t.Cleanup(ts.Close)
+
+		var key interface{} = set.Keys[0]
+		if alg == ES256K {
+			key, err = NewES256KSigner(&set.Keys[0])
+			require.NoError(t, err)
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, nil)
+		require.NoError(t, err)
+		return ts, signer
+	}
+	sign := func(t *testing.T, signer jose.Signer, iss string) string {
+		jws, err := signer.Sign([]byte(fmt.Sprintf(`{"iss":"%s","sub":"foo"}`, iss)))
+		require.NoError(t, err)
+		token, err := jws.CompactSerialize()
+		require.NoError(t, err)
+		return token
+	}
+
+	a, signerA := newIssuer(t, "key-a", jose.RS256)
+	b, signerB := newIssuer(t, "key-b", jose.ES256)
+	k, signerK := newIssuer(t, "key-k", ES256K)
+
+	r, err := NewResolver([]Issuer{
+		{Issuer: "https://a.example.com", JWKSURL: a.URL, AllowedAlgorithms: []string{"RS256"}},
+		{Issuer: "https://b.example.com", JWKSURL: b.URL, AllowedAlgorithms: []string{"ES256"}},
+		{Issuer: "https://k.example.com", JWKSURL: k.URL, AllowedAlgorithms: []string{"ES256K"}},
+	})
+	require.NoError(t, err)
+
+	payload, err := r.Verify(sign(t, signerA, "https://a.example.com"))
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `"sub":"foo"`)
+
+	_, err = r.Verify(sign(t, signerB, "https://b.example.com"))
+	require.NoError(t, err)
+
+	payload, err = r.Verify(sign(t, signerK, "https://k.example.com"))
+	require.NoError(t, err)
+	assert.Contains(t, string(payload), `"sub":"foo"`)
+
+	for k, tc := range []struct {
+		token    string
+		expected error
+	}{
+		{token: sign(t, signerA, "https://c.example.com"), expected: ErrUnknownIssuer},
+		{token: sign(t, signerB, "https://a.example.com"), expected: ErrAlgorithmNotAllowed},
+		{token: sign(t, signerA, "https://b.example.com"), expected: ErrAlgorithmNotAllowed},
+		{token: sign(t, signerK, "https://b.example.com"), expected: ErrAlgorithmNotAllowed},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			_, err := r.Verify(tc.token)
+			require.Error(t, err)
+			assert.True(t, errors.Is(err, tc.expected), "%+v", err)
+		})
+	}
+
+	_, err = r.Resolve("https://a.example.com", "key-b", "RS256")
+	require.ErrorIs(t, err, ErrKeyNotFound)
+
+	_, err = NewResolver([]Issuer{{Issuer: "https://a.example.com", JWKSURL: a.URL}})
+	require.Error(t, err, "allowed algorithms are required")
+}
@@ -0,0 +1,189 @@
+package jwksx
+
+import (
+	"sync"
+	"time"
+
+	"github.com/square/go-jose/v3"
+)
+
+type (
+	// KeyManager manages the keys of a JSON Web Key Set used for signing. It rotates the signing
+	// key and keeps serving previous keys for verification during an overlap period, so that
+	// tokens signed with a previous key remain valid until they expire. A new key is published
+	// for verification before it becomes the signing key, so that verifiers which cache the key
+	// set know it before the first token is signed with it.
+	KeyManager struct {
+		sync.RWMutex
+		alg        string
+		bits       int
+		interval   time.Duration
+		overlap    time.Duration
+		prepublish time.Duration
+		now        func() time.Time
+
+		// keys are ordered from the oldest to the newest key. The signing key is the newest key
+		// which is active, and newer keys are published, but not used for signing yet.
+		keys []managedKey
+	}
+	managedKey struct {
+		key         jose.JSONWebKey
+		activatesAt time.Time
+		// retiresAt is zero until a newer key was created.
+		retiresAt time.Time
+	}
+	// KeyManagerOption configures a KeyManager.
+	KeyManagerOption func(*KeyManager)
+)
+
+// WithRotationInterval rotates the signing key once it is older than interval. By default, the
+// signing key is only rotated by calling Rotate.
+func WithRotationInterval(interval time.Duration) KeyManagerOption {
+	return func(m *KeyManager) {
+		m.interval = interval
+	}
+}
+
+// WithOverlap sets how long a previous signing key is kept in the verification set after it was
+// rotated. It should be at least the lifespan of the tokens signed with it. Defaults to one hour.
+func WithOverlap(overlap time.Duration) KeyManagerOption {
+	return func(m *KeyManager) {
+		m.overlap = overlap
+	}
+}
+
+// WithPrepublish sets how long a new key is published in the verification set before it becomes
+// the signing key. It should be at least how long verifiers cache the key set, for example the
+// TTL of a Fetcher. Defaults to one hour, the default TTL of a Fetcher.
+func WithPrepublish(prepublish time.Duration) KeyManagerOption {
+	return func(m *KeyManager) {
+		m.prepublish = prepublish
+	}
+}
+
+// WithClock sets the function returning the current time, which is useful in tests.
+func WithClock(now func() time.Time) KeyManagerOption {
+	return func(m *KeyManager) {
+		m.now = now
+	}
+}
+
+// NewKeyManager returns a KeyManager with a new signing key for the algorithm, which is used for
+// signing right away. See GenerateSigningKeys for the supported algorithms and key lengths.
+func NewKeyManager(alg string, bits int, opts ...KeyManagerOption) (*KeyManager, error) {
+	m := &KeyManager{
+		alg:        alg,
+		bits:       bits,
+		overlap:    time.Hour,
+		prepublish: time.Hour,
+		now:        time.Now,
+	}
+	for _, o := range opts {
+		o(m)
+	}
+
+	set, err := GenerateSigningKeys("", m.alg, m.bits)
+	if err != nil {
+		return nil, err
+	}
+	m.keys = []managedKey{{key: set.Keys[0], activatesAt: m.now()}}
+	return m, nil
+}
+
+// Rotate generates a new key and publishes it for verification. It becomes the signing key once
+// the prepublish period has passed, and the previous signing key is kept for verification until
+// the overlap period after that ends.
+func (m *KeyManager) Rotate() (*jose.JSONWebKey, error) {
+	set, err := GenerateSigningKeys("", m.alg, m.bits)
+	if err != nil {
+		return nil, err
+	}
+	key := set.Keys[0]
+
+	m.Lock()
+	defer m.Unlock()
+	m.rotate(key)
+	return &key, nil
+}
+
+func (m *KeyManager) rotate(key jose.JSONWebKey) {
+	now := m.now()
+	activatesAt := now.Add(m.prepublish)
+	for k := range m.keys {
+		if m.keys[k].retiresAt.IsZero() {
+			m.keys[k].retiresAt = activatesAt.Add(m.overlap)
+		}
+	}
+	m.keys = append(m.keys, managedKey{key: key, activatesAt: activatesAt})
+	m.prune(now)
+}
+
+// prune removes the keys whose overlap period ended.
+func (m *KeyManager) prune(now time.Time) {
+	active := m.keys[:0]
+	for _, k := range m.keys {
+		if k.retiresAt.IsZero() || now.Before(k.retiresAt) {
+			active = append(active, k)
+		}
+	}
+	m.keys = active
+}
+
+// signing returns the newest key which is active.
+func (m *KeyManager) signing(now time.Time) managedKey {
+	for k := len(m.keys) - 1; k > 0; k-- {
+		if !m.keys[k].activatesAt.After(now) {
+			return m.keys[k]
+		}
+	}
+	return m.keys[0]
+}
+
+// SigningKey returns the current signing key, which contains the private key. If the rotation
+// interval ends within the prepublish period, the next key is generated and published, so that
+// it becomes the signing key once the interval has passed.
+func (m *KeyManager) SigningKey() (*jose.JSONWebKey, error) {
+	now := m.now()
+	m.RLock()
+	current, newest := m.signing(now), m.keys[len(m.keys)-1]
+	m.RUnlock()
+
+	if m.interval <= 0 || newest.key.KeyID != current.key.KeyID || now.Sub(current.activatesAt) < m.interval-m.prepublish {
+		key := current.key
+		return &key, nil
+	}
+
+	set, err := GenerateSigningKeys("", m.alg, m.bits)
+	if err != nil {
+		return nil, err
+	}
+
+	m.Lock()
+	defer m.Unlock()
+	// Another caller might have rotated the key in the meantime.
+	if m.keys[len(m.keys)-1].key.KeyID == current.key.KeyID {
+		m.rotate(set.Keys[0])
+	}
+	key := m.signing(now).key
+	return &key, nil
+}
+
+// VerificationKeys returns the keys which can be used to verify signatures: the signing key, the
+// next key if it was published already, and the previous keys whose overlap period has not
+// ended. Asymmetric keys only contain the public key and can be published. Symmetric keys, for
+// example for HS256, are secret.
+func (m *KeyManager) VerificationKeys() *jose.JSONWebKeySet {
+	m.Lock()
+	defer m.Unlock()
+	m.prune(m.now())
+
+	set := &jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(m.keys))}
+	for _, k := range m.keys {
+		key := k.key
+		if pub, ok := publicKey(key); ok {
+			key = pub
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	return set
+}
@@ -0,0 +1,85 @@
+package jwksx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/square/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyManager(t *testing.T) {
+	now := time.Now()
+	clock := func() time.Time { return now }
+
+	keyIDs := func(set *jose.JSONWebKeySet) (ids []string) {
+		for _, k := range set.Keys {
+			assert.True(t, k.IsPublic(), "verification keys must not contain private keys")
+			ids = append(ids, k.KeyID)
+		}
+		return ids
+	}
+
+	m, err := NewKeyManager(string(jose.ES256), 0,
+		WithOverlap(time.Hour), WithPrepublish(10*time.Minute), WithRotationInterval(24*time.Hour), WithClock(clock))
+	require.NoError(t, err)
+	signingKeyID := func() string {
+		key, err := m.SigningKey()
+		require.NoError(t, err)
+		assert.False(t, key.IsPublic())
+		return key.KeyID
+	}
+
+	first, err := m.SigningKey()
+	require.NoError(t, err)
+	assert.Equal(t, []string{first.KeyID}, keyIDs(m.VerificationKeys()))
+
+	second, err := m.Rotate()
+	require.NoError(t, err)
+	assert.Equal(t, first.KeyID, signingKeyID(), "the new key is published before it signs")
+	assert.Equal(t, []string{first.KeyID, second.KeyID}, keyIDs(m.VerificationKeys()))
+
+	now = now.Add(10 * time.Minute)
+	assert.Equal(t, second.KeyID, signingKeyID(), "the new key signs once the prepublish period passed")
+	assert.Equal(t, []string{first.KeyID, second.KeyID}, keyIDs(m.VerificationKeys()))
+
+	now = now.Add(time.Hour)
+	assert.Equal(t, []string{second.KeyID}, keyIDs(m.VerificationKeys()), "the first key is retired after the overlap")
+
+	// The second key became active ten minutes after the start, so the next key is published ten
+	// minutes before its rotation interval ends.
+	now = now.Add(23*time.Hour - 10*time.Minute)
+	assert.Equal(t, second.KeyID, signingKeyID())
+	set := m.VerificationKeys()
+	require.Len(t, set.Keys, 2)
+	third := set.Keys[1].KeyID
+	assert.NotEqual(t, second.KeyID, third, "the next key is published before the interval passed")
+
+	now = now.Add(10 * time.Minute)
+	assert.Equal(t, third, signingKeyID(), "the key is rotated once the interval passed")
+	assert.Equal(t, []string{second.KeyID, third}, keyIDs(m.VerificationKeys()))
+
+	t.Run("case=symmetric keys", func(t *testing.T) {
+		m, err := NewKeyManager(string(jose.HS256), 0)
+		require.NoError(t, err)
+		set := m.VerificationKeys()
+		require.Len(t, set.Keys, 1)
+		assert.NotNil(t, set.Keys[0].Key)
+	})
+
+	t.Run("case=secp256k1 keys", func(t *testing.T) {
+		m, err := NewKeyManager(string(ES256K), 0)
+		require.NoError(t, err)
+		set := m.VerificationKeys()
+		require.Len(t, set.Keys, 1)
+		raw, err := MarshalKeySet(set)
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), `"d"`, "only the public key is published")
+	})
+
+	t.Run("case=unknown algorithm", func(t *testing.T) {
+		_, err := NewKeyManager("foo", 0)
+		require.Error(t, err)
+	})
+}
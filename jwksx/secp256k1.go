@@ -0,0 +1,308 @@
+package jwksx
+
+import (
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+)
+
+// ES256K is ECDSA using the secp256k1 curve and SHA-256 as defined in RFC 8812.
+//
+// go-jose does not implement the curve, so keys on it use the *secp256k1.PrivateKey and
+// *secp256k1.PublicKey types of github.com/decred/dcrd/dcrec/secp256k1/v4, which the methods of
+// jose.JSONWebKey do not support. Encode and decode them with MarshalKeySet and UnmarshalKeySet,
+// and sign and verify with NewES256KSigner and NewES256KVerifier.
+const ES256K jose.SignatureAlgorithm = "ES256K"
+
+const secp256k1Curve = "secp256k1"
+
+// oidSecp256k1 identifies the curve in PKIX, PKCS #8 and SEC 1 encoded keys.
+var oidSecp256k1 = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
+
+type secp256k1JSONWebKey struct {
+	Use string `json:"use,omitempty"`
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Crv string `json:"crv"`
+	Alg string `json:"alg,omitempty"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	D   string `json:"d,omitempty"`
+}
+
+// secp256k1PublicKey returns the public key of a key on the secp256k1 curve.
+func secp256k1PublicKey(key interface{}) (*secp256k1.PublicKey, bool) {
+	switch k := key.(type) {
+	case *secp256k1.PublicKey:
+		return k, true
+	case *secp256k1.PrivateKey:
+		return k.PubKey(), true
+	}
+	return nil, false
+}
+
+// secp256k1Coordinates returns the base64url encoded coordinates of the public key.
+func secp256k1Coordinates(pub *secp256k1.PublicKey) (x, y string) {
+	point := pub.SerializeUncompressed()
+	return base64.RawURLEncoding.EncodeToString(point[1:33]), base64.RawURLEncoding.EncodeToString(point[33:])
+}
+
+func marshalSecp256k1Key(key *jose.JSONWebKey, pub *secp256k1.PublicKey) ([]byte, error) {
+	raw := secp256k1JSONWebKey{Use: key.Use, Kty: "EC", Kid: key.KeyID, Crv: secp256k1Curve, Alg: key.Algorithm}
+	raw.X, raw.Y = secp256k1Coordinates(pub)
+	if priv, ok := key.Key.(*secp256k1.PrivateKey); ok {
+		raw.D = base64.RawURLEncoding.EncodeToString(priv.Serialize())
+	}
+	out, err := json.Marshal(&raw)
+	return out, errors.WithStack(err)
+}
+
+func unmarshalSecp256k1Key(raw *secp256k1JSONWebKey) (*jose.JSONWebKey, error) {
+	x, errX := base64.RawURLEncoding.DecodeString(raw.X)
+	y, errY := base64.RawURLEncoding.DecodeString(raw.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, errors.New("jwksx: the coordinates of the secp256k1 key are invalid")
+	}
+	pub, err := secp256k1.ParsePubKey(append(append([]byte{0x04}, x...), y...))
+	if err != nil {
+		return nil, errors.Wrap(err, "jwksx: the secp256k1 public key is invalid")
+	}
+
+	key := &jose.JSONWebKey{Key: pub, KeyID: raw.Kid, Algorithm: raw.Alg, Use: raw.Use}
+	if raw.D != "" {
+		d, err := base64.RawURLEncoding.DecodeString(raw.D)
+		if err != nil || len(d) != 32 {
+			return nil, errors.New("jwksx: the secp256k1 private key is invalid")
+		}
+		priv := secp256k1.PrivKeyFromBytes(d)
+		if priv.Key.IsZero() || !priv.PubKey().IsEqual(pub) {
+			return nil, errors.New("jwksx: the secp256k1 private key does not match its public key")
+		}
+		key.Key = priv
+	}
+	return key, nil
+}
+
+// MarshalKey encodes the key as a JSON Web Key. Unlike the MarshalJSON method of the key, it
+// supports keys on the secp256k1 curve.
+func MarshalKey(key *jose.JSONWebKey) ([]byte, error) {
+	if pub, ok := secp256k1PublicKey(key.Key); ok {
+		return marshalSecp256k1Key(key, pub)
+	}
+	out, err := key.MarshalJSON()
+	return out, errors.WithStack(err)
+}
+
+// UnmarshalKey decodes a JSON Web Key. Unlike the UnmarshalJSON method of the key, it supports
+// keys on the secp256k1 curve.
+func UnmarshalKey(data []byte) (*jose.JSONWebKey, error) {
+	var raw secp256k1JSONWebKey
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if raw.Kty == "EC" && raw.Crv == secp256k1Curve {
+		return unmarshalSecp256k1Key(&raw)
+	}
+
+	var key jose.JSONWebKey
+	if err := key.UnmarshalJSON(data); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &key, nil
+}
+
+// MarshalKeySet encodes the key set as JSON, supporting keys on the secp256k1 curve.
+func MarshalKeySet(set *jose.JSONWebKeySet) ([]byte, error) {
+	raw := struct {
+		Keys []json.RawMessage `json:"keys"`
+	}{Keys: make([]json.RawMessage, len(set.Keys))}
+	for k := range set.Keys {
+		key, err := MarshalKey(&set.Keys[k])
+		if err != nil {
+			return nil, err
+		}
+		raw.Keys[k] = key
+	}
+	out, err := json.Marshal(&raw)
+	return out, errors.WithStack(err)
+}
+
+// UnmarshalKeySet decodes a JSON Web Key Set, supporting keys on the secp256k1 curve.
+func UnmarshalKeySet(data []byte) (*jose.JSONWebKeySet, error) {
+	var raw struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	set := &jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, len(raw.Keys))}
+	for k, data := range raw.Keys {
+		key, err := UnmarshalKey(data)
+		if err != nil {
+			return nil, err
+		}
+		set.Keys[k] = *key
+	}
+	return set, nil
+}
+
+// publicKey returns the public key of an asymmetric key, and false for symmetric keys. Unlike
+// the Public method of the key, it supports keys on the secp256k1 curve.
+func publicKey(key jose.JSONWebKey) (jose.JSONWebKey, bool) {
+	if pub, ok := secp256k1PublicKey(key.Key); ok {
+		key.Key = pub
+		return key, true
+	}
+	pub := key.Public()
+	return pub, pub.Valid()
+}
+
+type es256kSigner struct {
+	key    *secp256k1.PrivateKey
+	public jose.JSONWebKey
+}
+
+var _ jose.OpaqueSigner = (*es256kSigner)(nil)
+
+// NewES256KSigner returns a signer which signs with the secp256k1 private key using ES256K.
+// Pass it as the key of jose.NewSigner:
+//
+//	jose.NewSigner(jose.SigningKey{Algorithm: jwksx.ES256K, Key: signer}, nil)
+func NewES256KSigner(key *jose.JSONWebKey) (jose.OpaqueSigner, error) {
+	priv, ok := key.Key.(*secp256k1.PrivateKey)
+	if !ok {
+		return nil, errors.Errorf("jwksx: expected a secp256k1 private key but got %T", key.Key)
+	}
+	public, _ := publicKey(*key)
+	return &es256kSigner{key: priv, public: public}, nil
+}
+
+func (s *es256kSigner) Public() *jose.JSONWebKey {
+	public := s.public
+	return &public
+}
+
+func (s *es256kSigner) Algs() []jose.SignatureAlgorithm {
+	return []jose.SignatureAlgorithm{ES256K}
+}
+
+// SignPayload returns the signature as the concatenated R and S values (RFC 7518 Section 3.4).
+func (s *es256kSigner) SignPayload(payload []byte, alg jose.SignatureAlgorithm) ([]byte, error) {
+	if alg != ES256K {
+		return nil, errors.WithStack(jose.ErrUnsupportedAlgorithm)
+	}
+	hash := sha256.Sum256(payload)
+	// The compact signature is the recovery code followed by R and S.
+	return ecdsa.SignCompact(s.key, hash[:], false)[1:], nil
+}
+
+type es256kVerifier struct {
+	key *secp256k1.PublicKey
+}
+
+var _ jose.OpaqueVerifier = (*es256kVerifier)(nil)
+
+// NewES256KVerifier returns a verifier for ES256K signatures of the secp256k1 key. Pass it to
+// the Verify method of a jose.JSONWebSignature.
+func NewES256KVerifier(key *jose.JSONWebKey) (jose.OpaqueVerifier, error) {
+	pub, ok := secp256k1PublicKey(key.Key)
+	if !ok {
+		return nil, errors.Errorf("jwksx: expected a secp256k1 key but got %T", key.Key)
+	}
+	return &es256kVerifier{key: pub}, nil
+}
+
+func (v *es256kVerifier) VerifyPayload(payload []byte, signature []byte, alg jose.SignatureAlgorithm) error {
+	if alg != ES256K {
+		return errors.WithStack(jose.ErrUnsupportedAlgorithm)
+	}
+	if len(signature) != 64 {
+		return errors.WithStack(jose.ErrCryptoFailure)
+	}
+
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(signature[:32]) || s.SetByteSlice(signature[32:]) || r.IsZero() || s.IsZero() {
+		return errors.WithStack(jose.ErrCryptoFailure)
+	}
+	hash := sha256.Sum256(payload)
+	if !ecdsa.NewSignature(&r, &s).Verify(hash[:], v.key) {
+		return errors.WithStack(jose.ErrCryptoFailure)
+	}
+	return nil
+}
+
+type pkixPublicKey struct {
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.ObjectIdentifier
+	}
+	PublicKey asn1.BitString
+}
+
+type pkcs8PrivateKey struct {
+	Version   int
+	Algorithm struct {
+		Algorithm  asn1.ObjectIdentifier
+		Parameters asn1.ObjectIdentifier
+	}
+	PrivateKey []byte
+}
+
+type sec1PrivateKey struct {
+	Version    int
+	PrivateKey []byte
+	Curve      asn1.ObjectIdentifier `asn1:"optional,explicit,tag:0"`
+	PublicKey  asn1.BitString        `asn1:"optional,explicit,tag:1"`
+}
+
+// parseSecp256k1PEMKey parses a PKIX, PKCS #8 or SEC 1 encoded key on the secp256k1 curve, which
+// the x509 package does not support. It returns false if the key is not on the curve.
+func parseSecp256k1PEMKey(block *pem.Block) (interface{}, bool, error) {
+	switch block.Type {
+	case "PUBLIC KEY":
+		var raw pkixPublicKey
+		if _, err := asn1.Unmarshal(block.Bytes, &raw); err != nil || !raw.Algorithm.Parameters.Equal(oidSecp256k1) {
+			return nil, false, nil
+		}
+		key, err := secp256k1.ParsePubKey(raw.PublicKey.RightAlign())
+		return key, true, errors.Wrap(err, "jwksx: unable to parse public key")
+	case "PRIVATE KEY":
+		var raw pkcs8PrivateKey
+		if _, err := asn1.Unmarshal(block.Bytes, &raw); err != nil || !raw.Algorithm.Parameters.Equal(oidSecp256k1) {
+			return nil, false, nil
+		}
+		key, err := parseSecp256k1PrivateKey(raw.PrivateKey)
+		return key, true, err
+	case "EC PRIVATE KEY":
+		var raw sec1PrivateKey
+		if _, err := asn1.Unmarshal(block.Bytes, &raw); err != nil || !raw.Curve.Equal(oidSecp256k1) {
+			return nil, false, nil
+		}
+		key, err := parseSecp256k1PrivateKey(block.Bytes)
+		return key, true, err
+	}
+	return nil, false, nil
+}
+
+// parseSecp256k1PrivateKey parses a SEC 1 encoded private key.
+func parseSecp256k1PrivateKey(der []byte) (*secp256k1.PrivateKey, error) {
+	var raw sec1PrivateKey
+	if _, err := asn1.Unmarshal(der, &raw); err != nil {
+		return nil, errors.Wrap(err, "jwksx: unable to parse private key")
+	}
+	if raw.Version != 1 || len(raw.PrivateKey) != 32 {
+		return nil, errors.New("jwksx: unable to parse private key: the secp256k1 private key is invalid")
+	}
+	key := secp256k1.PrivKeyFromBytes(raw.PrivateKey)
+	if key.Key.IsZero() {
+		return nil, errors.New("jwksx: unable to parse private key: the secp256k1 private key is invalid")
+	}
+	return key, nil
+}
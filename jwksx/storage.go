@@ -0,0 +1,202 @@
+package jwksx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"github.com/square/go-jose/v3"
+)
+
+type (
+	// Cipher encrypts and decrypts key sets at rest. Implement it to use a key management
+	// service, or use NewAESGCMCipher with a key from the configuration.
+	Cipher interface {
+		Encrypt(plaintext []byte) ([]byte, error)
+		Decrypt(ciphertext []byte) ([]byte, error)
+	}
+
+	// AESGCMCipher encrypts with AES-256-GCM.
+	AESGCMCipher struct {
+		keys [][]byte
+	}
+
+	// FileStore persists a key set in a file, encrypted with a Cipher.
+	FileStore struct {
+		path   string
+		cipher Cipher
+	}
+
+	// encryptedKeySet is the format of encrypted key sets.
+	encryptedKeySet struct {
+		Algorithm  string `json:"alg"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+)
+
+var (
+	// ErrUnableToDecrypt is returned if a key set can not be decrypted with any of the keys.
+	ErrUnableToDecrypt = errors.New("jwksx: unable to decrypt the key set")
+
+	// ErrPlaintextKeySet is returned if a key set which should be encrypted is stored as
+	// plaintext. It is not accepted silently, because anyone able to write the file could
+	// otherwise inject keys. Use FileStore.Migrate to encrypt key sets stored by earlier versions.
+	ErrPlaintextKeySet = errors.New("jwksx: the key set is not encrypted")
+)
+
+// NewAESGCMCipher returns a cipher for 32 byte keys. The first key encrypts, and all keys are
+// tried for decryption, so that the key can be rotated by prepending a new one.
+func NewAESGCMCipher(keys ...[]byte) (*AESGCMCipher, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("jwksx: at least one encryption key is required")
+	}
+	for _, k := range keys {
+		if len(k) != 32 {
+			return nil, errors.Errorf("jwksx: encryption keys must be 32 bytes long but got %d bytes", len(k))
+		}
+	}
+	return &AESGCMCipher{keys: keys}, nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	return aead, errors.WithStack(err)
+}
+
+// Encrypt encrypts the plaintext with the first key. The nonce is prepended to the ciphertext.
+func (c *AESGCMCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(c.keys[0])
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Decrypt decrypts the ciphertext with the first key that works.
+func (c *AESGCMCipher) Decrypt(ciphertext []byte) ([]byte, error) {
+	for _, k := range c.keys {
+		aead, err := newGCM(k)
+		if err != nil {
+			return nil, err
+		}
+		if len(ciphertext) < aead.NonceSize() {
+			break
+		}
+		if plaintext, err := aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, errors.WithStack(ErrUnableToDecrypt)
+}
+
+// EncryptKeySet encodes the key set as JSON and encrypts it.
+func EncryptKeySet(set *jose.JSONWebKeySet, c Cipher) ([]byte, error) {
+	plaintext, err := MarshalKeySet(set)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := c.Encrypt(plaintext)
+	if err != nil {
+		return nil, err
+	}
+	out, err := json.Marshal(&encryptedKeySet{Algorithm: "A256GCM", Ciphertext: ciphertext})
+	return out, errors.WithStack(err)
+}
+
+// DecryptKeySet decrypts a key set encrypted by EncryptKeySet. Key sets stored as plaintext JSON
+// are rejected with ErrPlaintextKeySet.
+func DecryptKeySet(data []byte, c Cipher) (*jose.JSONWebKeySet, error) {
+	var probe struct {
+		encryptedKeySet
+		Keys json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, errors.Wrap(err, "jwksx: unable to decode the key set")
+	}
+	if probe.Keys != nil {
+		return nil, errors.WithStack(ErrPlaintextKeySet)
+	}
+	if probe.Algorithm != "A256GCM" {
+		return nil, errors.Errorf("jwksx: unsupported key set encryption algorithm %q", probe.Algorithm)
+	}
+
+	plaintext, err := c.Decrypt(probe.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	set, err := UnmarshalKeySet(plaintext)
+	return set, errors.WithMessage(err, "jwksx: unable to decode the decrypted key set")
+}
+
+// NewFileStore returns a store which persists a key set encrypted in the file at path.
+func NewFileStore(path string, c Cipher) *FileStore {
+	return &FileStore{path: path, cipher: c}
+}
+
+// Save encrypts the key set and replaces the file.
+func (s *FileStore) Save(set *jose.JSONWebKeySet) error {
+	out, err := EncryptKeySet(set, s.cipher)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that the key set is never partially written.
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return errors.WithStack(err)
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(tmp.Name(), s.path))
+}
+
+// Load reads and decrypts the key set. A key set stored as plaintext is rejected with
+// ErrPlaintextKeySet.
+func (s *FileStore) Load() (*jose.JSONWebKeySet, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return DecryptKeySet(data, s.cipher)
+}
+
+// Migrate encrypts a key set stored as plaintext, for example by an earlier version, and replaces
+// the file. Key sets which are encrypted already are left untouched. Run it once, for example
+// from a migration command, after making sure that the file was not tampered with.
+func (s *FileStore) Migrate() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if _, err := DecryptKeySet(data, s.cipher); err == nil {
+		return nil
+	} else if !errors.Is(err, ErrPlaintextKeySet) {
+		return err
+	}
+
+	set, err := UnmarshalKeySet(data)
+	if err != nil {
+		return errors.WithMessage(err, "jwksx: unable to decode the plaintext key set")
+	}
+	return errors.WithMessage(s.Save(set), "jwksx: unable to encrypt the plaintext key set")
+}
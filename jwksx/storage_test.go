@@ -0,0 +1,104 @@
+package jwksx
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/square/go-jose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	oldKey := bytes.Repeat([]byte("a"), 32)
+	newKey := bytes.Repeat([]byte("b"), 32)
+
+	set, err := GenerateSigningKeys("key", string(jose.ES256), 0)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	c, err := NewAESGCMCipher(oldKey)
+	require.NoError(t, err)
+	s := NewFileStore(path, c)
+
+	require.NoError(t, s.Save(set))
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), `"kid"`, "the key set must be encrypted")
+
+	loaded, err := s.Load()
+	require.NoError(t, err)
+	require.Len(t, loaded.Keys, 1)
+	assert.Equal(t, "key", loaded.Keys[0].KeyID)
+	assert.False(t, loaded.Keys[0].IsPublic())
+
+	t.Run("case=rotated encryption keys", func(t *testing.T) {
+		c, err := NewAESGCMCipher(newKey, oldKey)
+		require.NoError(t, err)
+		loaded, err := NewFileStore(path, c).Load()
+		require.NoError(t, err)
+		assert.Equal(t, "key", loaded.Keys[0].KeyID)
+
+		c, err = NewAESGCMCipher(newKey)
+		require.NoError(t, err)
+		_, err = NewFileStore(path, c).Load()
+		require.ErrorIs(t, err, ErrUnableToDecrypt)
+	})
+
+	t.Run("case=plaintext key sets are rejected", func(t *testing.T) {
+		plain, err := json.Marshal(set)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, plain, 0600))
+
+		_, err = s.Load()
+		require.ErrorIs(t, err, ErrPlaintextKeySet)
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, plain, raw, "loading must not modify the file")
+	})
+
+	t.Run("case=plaintext key sets are migrated explicitly", func(t *testing.T) {
+		plain, err := json.Marshal(set)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path, plain, 0600))
+
+		require.NoError(t, s.Migrate())
+		raw, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.NotContains(t, string(raw), `"kid"`, "the key set must have been encrypted")
+
+		loaded, err := s.Load()
+		require.NoError(t, err)
+		assert.Equal(t, "key", loaded.Keys[0].KeyID)
+
+		// migrating an encrypted key set does nothing
+		require.NoError(t, s.Migrate())
+		migrated, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, raw, migrated)
+
+		c, err := NewAESGCMCipher(newKey)
+		require.NoError(t, err)
+		require.ErrorIs(t, NewFileStore(path, c).Migrate(), ErrUnableToDecrypt)
+	})
+
+	t.Run("case=secp256k1 keys", func(t *testing.T) {
+		set, err := GenerateSigningKeys("key", string(ES256K), 0)
+		require.NoError(t, err)
+		require.NoError(t, s.Save(set))
+
+		loaded, err := s.Load()
+		require.NoError(t, err)
+		assert.Equal(t, set.Keys[0].Key, loaded.Keys[0].Key)
+	})
+
+	t.Run("case=invalid keys", func(t *testing.T) {
+		_, err := NewAESGCMCipher([]byte("short"))
+		require.Error(t, err)
+		_, err = NewAESGCMCipher()
+		require.Error(t, err)
+	})
+}
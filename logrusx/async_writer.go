@@ -0,0 +1,187 @@
+package logrusx
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncPolicy decides what happens when the queue of an AsyncWriter is full.
+type AsyncPolicy int
+
+const (
+	// AsyncDrop drops entries if the queue is full, which never stalls the caller.
+	AsyncDrop AsyncPolicy = iota
+	// AsyncBlock waits until the queue has room, which never loses entries.
+	AsyncBlock
+)
+
+// AsyncWriterConfig configures NewAsyncWriter.
+type AsyncWriterConfig struct {
+	// QueueSize is the maximum number of queued entries. Defaults to 1024.
+	QueueSize int
+
+	// BatchSize is the maximum number of entries written to the underlying writer at once.
+	// Defaults to 64.
+	BatchSize int
+
+	// Policy decides what happens when the queue is full. Defaults to AsyncDrop.
+	Policy AsyncPolicy
+}
+
+// WithAsyncWriter writes log entries asynchronously, so that a slow output does not stall
+// request handling. The logger's output is wrapped using NewAsyncWriter. Queued entries are
+// flushed before the logger exits because of a fatal entry. Call
+//
+//	l.Logrus().Out.(*logrusx.AsyncWriter).Close()
+//
+// to flush them when the application shuts down. Entries sent to a log/slog handler using
+// WithSlogHandler are written by the handler instead, so they are not written asynchronously.
+func WithAsyncWriter(c AsyncWriterConfig) Option {
+	return func(o *options) {
+		o.async = &c
+	}
+}
+
+// AsyncWriter queues writes and writes them in batches to the underlying writer using a
+// background goroutine.
+type AsyncWriter struct {
+	w       io.Writer
+	c       AsyncWriterConfig
+	queue   chan asyncItem
+	done    chan struct{}
+	dropped uint64
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+type asyncItem struct {
+	p     []byte
+	flush chan struct{}
+}
+
+var _ io.WriteCloser = (*AsyncWriter)(nil)
+
+// NewAsyncWriter returns an AsyncWriter writing to w.
+func NewAsyncWriter(w io.Writer, c AsyncWriterConfig) *AsyncWriter {
+	if c.QueueSize <= 0 {
+		c.QueueSize = 1024
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 64
+	}
+
+	a := &AsyncWriter{
+		w:     w,
+		c:     c,
+		queue: make(chan asyncItem, c.QueueSize),
+		done:  make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Write queues p. It never returns an error; entries which can not be queued are counted, see
+// Dropped. After Close, p is written synchronously. Empty writes, which logrus makes for entries
+// its formatter dropped, are ignored.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return a.w.Write(p)
+	}
+
+	// The caller may reuse p, logrus for example uses a buffer pool.
+	item := asyncItem{p: append([]byte(nil), p...)}
+	if a.c.Policy == AsyncBlock {
+		a.queue <- item
+		return len(p), nil
+	}
+
+	select {
+	case a.queue <- item:
+	default:
+		atomic.AddUint64(&a.dropped, 1)
+	}
+	return len(p), nil
+}
+
+// Dropped returns the number of entries which were dropped because the queue was full.
+func (a *AsyncWriter) Dropped() uint64 {
+	return atomic.LoadUint64(&a.dropped)
+}
+
+// Flush blocks until all entries queued before the call have been written.
+func (a *AsyncWriter) Flush() {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.closed {
+		return
+	}
+
+	flushed := make(chan struct{})
+	a.queue <- asyncItem{flush: flushed}
+	<-flushed
+}
+
+// Close writes all queued entries and stops the background goroutine. Writes after Close are
+// synchronous.
+func (a *AsyncWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	<-a.done
+	return nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+
+	var batch bytes.Buffer
+	for item := range a.queue {
+		batch.Reset()
+		var flushed []chan struct{}
+
+		for n := 0; ; {
+			if item.flush != nil {
+				flushed = append(flushed, item.flush)
+			} else {
+				batch.Write(item.p)
+				n++
+			}
+			if n >= a.c.BatchSize {
+				break
+			}
+
+			var ok bool
+			select {
+			case item, ok = <-a.queue:
+			default:
+			}
+			if !ok {
+				break
+			}
+		}
+
+		if batch.Len() > 0 {
+			// Like logrus, write errors are not returned to the caller.
+			_, _ = a.w.Write(batch.Bytes())
+		}
+		for _, f := range flushed {
+			close(f)
+		}
+	}
+}
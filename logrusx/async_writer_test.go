@@ -0,0 +1,128 @@
+package logrusx_test
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/logrusx"
+)
+
+type blockingWriter struct {
+	sync.Mutex
+	unblock chan struct{}
+	b       bytes.Buffer
+	writes  int
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.unblock
+	w.Lock()
+	defer w.Unlock()
+	w.writes++
+	return w.b.Write(p)
+}
+
+func (w *blockingWriter) String() string {
+	w.Lock()
+	defer w.Unlock()
+	return w.b.String()
+}
+
+func TestAsyncWriter(t *testing.T) {
+	t.Run("case=writes all entries in order", func(t *testing.T) {
+		out := &blockingWriter{unblock: make(chan struct{})}
+		close(out.unblock)
+
+		w := NewAsyncWriter(out, AsyncWriterConfig{Policy: AsyncBlock, QueueSize: 2, BatchSize: 4})
+		var expected strings.Builder
+		for i := 0; i < 100; i++ {
+			line := strings.Repeat("x", i) + "\n"
+			expected.WriteString(line)
+			_, err := w.Write([]byte(line))
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, expected.String(), out.String())
+		assert.Less(t, out.writes, 100)
+		assert.Zero(t, w.Dropped())
+	})
+
+	t.Run("case=drops entries if the queue is full", func(t *testing.T) {
+		out := &blockingWriter{unblock: make(chan struct{})}
+		w := NewAsyncWriter(out, AsyncWriterConfig{Policy: AsyncDrop, QueueSize: 2, BatchSize: 1})
+
+		for i := 0; i < 10; i++ {
+			_, err := w.Write([]byte("foo\n"))
+			require.NoError(t, err)
+		}
+
+		// At most one entry is being written and two are queued.
+		assert.GreaterOrEqual(t, w.Dropped(), uint64(7))
+
+		close(out.unblock)
+		w.Flush()
+		assert.Equal(t, 10-int(w.Dropped()), strings.Count(out.String(), "foo"))
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("case=ignores empty writes", func(t *testing.T) {
+		out := &blockingWriter{unblock: make(chan struct{})}
+		w := NewAsyncWriter(out, AsyncWriterConfig{Policy: AsyncBlock, QueueSize: 1, BatchSize: 1})
+
+		// Would block on the full queue if empty writes were queued.
+		for i := 0; i < 10; i++ {
+			n, err := w.Write(nil)
+			require.NoError(t, err)
+			assert.Zero(t, n)
+		}
+		assert.Zero(t, w.Dropped())
+
+		close(out.unblock)
+		require.NoError(t, w.Close())
+		assert.Zero(t, out.writes)
+	})
+
+	t.Run("case=copies the written bytes", func(t *testing.T) {
+		var b bytes.Buffer
+		w := NewAsyncWriter(&b, AsyncWriterConfig{})
+		p := []byte("foo\n")
+		_, _ = w.Write(p)
+		copy(p, "bar\n")
+		w.Flush()
+		assert.Equal(t, "foo\n", b.String())
+		require.NoError(t, w.Close())
+	})
+
+	t.Run("case=writes synchronously after close", func(t *testing.T) {
+		var b bytes.Buffer
+		w := NewAsyncWriter(&b, AsyncWriterConfig{})
+		require.NoError(t, w.Close())
+		require.NoError(t, w.Close())
+		_, _ = w.Write([]byte("foo\n"))
+		assert.Equal(t, "foo\n", b.String())
+	})
+
+	t.Run("case=flushes before fatal exit", func(t *testing.T) {
+		var b bytes.Buffer
+		var code int
+		logger := logrus.New()
+		logger.Out = &b
+		l := New("logrusx-async", "v0.0.0", UseLogger(logger), WithExitFunc(func(c int) { code = c }), WithAsyncWriter(AsyncWriterConfig{}))
+
+		_, ok := l.Logrus().Out.(*AsyncWriter)
+		require.True(t, ok)
+
+		l.Info("foo")
+		l.Fatal("bar")
+		assert.Equal(t, 1, code)
+		assert.Contains(t, b.String(), "msg=foo")
+		assert.Contains(t, b.String(), "msg=bar")
+	})
+}
@@ -0,0 +1,163 @@
+package logrusx
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+// GCPFormatter formats entries as structured JSON understood by Google Cloud Logging. The
+// severity, trace, span, and source location are written to the special fields documented at
+// https://cloud.google.com/logging/docs/structured-logging.
+type GCPFormatter struct {
+	// ProjectID is used to construct the "logging.googleapis.com/trace" field from the
+	// "trace_id" field. Defaults to the GOOGLE_CLOUD_PROJECT environment variable.
+	ProjectID string
+}
+
+var _ logrus.Formatter = (*GCPFormatter)(nil)
+
+// gcpSeverity maps logrus levels to Google Cloud Logging severities.
+func gcpSeverity(l logrus.Level) string {
+	switch l {
+	case logrus.PanicLevel:
+		return "ALERT"
+	case logrus.FatalLevel:
+		return "CRITICAL"
+	case logrus.ErrorLevel:
+		return "ERROR"
+	case logrus.WarnLevel:
+		return "WARNING"
+	case logrus.InfoLevel:
+		return "INFO"
+	}
+	return "DEBUG"
+}
+
+// Format implements logrus.Formatter.
+func (f *GCPFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(e.Data)+6)
+	for k, v := range e.Data {
+		data[k] = jsonValue(v)
+	}
+
+	data["severity"] = gcpSeverity(e.Level)
+	data["message"] = e.Message
+	data["time"] = e.Time.Format(time.RFC3339Nano)
+
+	if traceID, ok := e.Data["trace_id"].(string); ok && f.ProjectID != "" {
+		data["logging.googleapis.com/trace"] = fmt.Sprintf("projects/%s/traces/%s", f.ProjectID, traceID)
+	}
+	if spanID, ok := e.Data["span_id"].(string); ok {
+		data["logging.googleapis.com/spanId"] = spanID
+	}
+	if e.HasCaller() {
+		data["logging.googleapis.com/sourceLocation"] = map[string]interface{}{
+			"file":     e.Caller.File,
+			"line":     fmt.Sprintf("%d", e.Caller.Line),
+			"function": e.Caller.Function,
+		}
+	}
+
+	return marshalLine(data)
+}
+
+// EMFMetric is a field value which the AWSFormatter exports as a CloudWatch metric.
+type EMFMetric struct {
+	Value float64
+	// Unit is a CloudWatch unit, for example "Milliseconds" or "Count".
+	Unit string
+}
+
+// MarshalJSON writes the value only, which is where CloudWatch expects it.
+func (m EMFMetric) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.Value)
+}
+
+// AWSFormatter formats entries as JSON understood by Amazon CloudWatch Logs. Fields with an
+// EMFMetric value are additionally declared using the CloudWatch Embedded Metric Format, see
+// https://docs.aws.amazon.com/AmazonCloudWatch/latest/monitoring/CloudWatch_Embedded_Metric_Format_Specification.html.
+type AWSFormatter struct {
+	// Namespace of the metrics. Defaults to the "service_name" field.
+	Namespace string
+
+	// Dimensions are the fields used as metric dimensions. Defaults to "service_name".
+	Dimensions []string
+}
+
+var _ logrus.Formatter = (*AWSFormatter)(nil)
+
+// Format implements logrus.Formatter.
+func (f *AWSFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	data := make(logrus.Fields, len(e.Data)+4)
+	type metric struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit,omitempty"`
+	}
+	var metrics []metric
+	for k, v := range e.Data {
+		if m, ok := v.(EMFMetric); ok {
+			metrics = append(metrics, metric{Name: k, Unit: m.Unit})
+		}
+		data[k] = jsonValue(v)
+	}
+
+	data["level"] = e.Level.String()
+	data["msg"] = e.Message
+	data["time"] = e.Time.Format(time.RFC3339Nano)
+	if e.HasCaller() {
+		data["func"] = e.Caller.Function
+		data["file"] = fmt.Sprintf("%s:%d", e.Caller.File, e.Caller.Line)
+	}
+
+	if len(metrics) > 0 {
+		namespace := f.Namespace
+		if namespace == "" {
+			namespace, _ = e.Data["service_name"].(string)
+		}
+
+		dimensions := []string{}
+		candidates := f.Dimensions
+		if candidates == nil {
+			candidates = []string{"service_name"}
+		}
+		for _, d := range candidates {
+			if _, ok := e.Data[d]; ok {
+				dimensions = append(dimensions, d)
+			}
+		}
+
+		data["_aws"] = map[string]interface{}{
+			"Timestamp": e.Time.UnixNano() / int64(time.Millisecond),
+			"CloudWatchMetrics": []interface{}{map[string]interface{}{
+				"Namespace":  namespace,
+				"Dimensions": [][]string{dimensions},
+				"Metrics":    metrics,
+			}},
+		}
+	}
+
+	return marshalLine(data)
+}
+
+// jsonValue converts errors to strings because they usually do not marshal to JSON, which
+// matches logrus.JSONFormatter.
+func jsonValue(v interface{}) interface{} {
+	if err, ok := v.(error); ok {
+		if _, ok := v.(json.Marshaler); !ok {
+			return err.Error()
+		}
+	}
+	return v
+}
+
+func marshalLine(data logrus.Fields) ([]byte, error) {
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal fields to JSON")
+	}
+	return append(out, '\n'), nil
+}
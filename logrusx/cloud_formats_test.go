@@ -0,0 +1,91 @@
+package logrusx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	. "github.com/ory/x/logrusx"
+)
+
+func TestCloudFormats(t *testing.T) {
+	decode := func(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(b.Bytes(), &entry), b.String())
+		return entry
+	}
+
+	traceID, _ := trace.TraceIDFromHex("0123456789abcdef0123456789abcdef")
+	spanID, _ := trace.SpanIDFromHex("0123456789abcdef")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+
+	t.Run("format=gcp", func(t *testing.T) {
+		var b bytes.Buffer
+		l := New("logrusx-gcp", "v0.0.0", ForceFormatter(&GCPFormatter{ProjectID: "my-project"}), ForceLevel(logrus.DebugLevel), ReportCaller(true))
+		l.Logrus().Out = &b
+
+		l.WithContext(ctx).WithError(errors.New("oops")).Warn("foo bar")
+
+		entry := decode(t, &b)
+		assert.Equal(t, "WARNING", entry["severity"])
+		assert.Equal(t, "foo bar", entry["message"])
+		assert.Equal(t, map[string]interface{}{"message": "oops"}, entry["error"])
+		assert.Equal(t, "logrusx-gcp", entry["service_name"])
+		assert.Equal(t, "projects/my-project/traces/0123456789abcdef0123456789abcdef", entry["logging.googleapis.com/trace"])
+		assert.Equal(t, "0123456789abcdef", entry["logging.googleapis.com/spanId"])
+		require.Contains(t, entry, "logging.googleapis.com/sourceLocation")
+		assert.Contains(t, entry["logging.googleapis.com/sourceLocation"].(map[string]interface{})["file"], "cloud_formats_test.go")
+	})
+
+	t.Run("format=gcp without project", func(t *testing.T) {
+		var b bytes.Buffer
+		l := New("logrusx-gcp", "v0.0.0", ForceFormat("gcp"))
+		l.Logrus().Out = &b
+
+		l.WithContext(ctx).Error("foo bar")
+
+		entry := decode(t, &b)
+		assert.Equal(t, "ERROR", entry["severity"])
+		assert.NotContains(t, entry, "logging.googleapis.com/trace")
+		assert.NotContains(t, entry, "logging.googleapis.com/sourceLocation")
+	})
+
+	t.Run("format=aws", func(t *testing.T) {
+		var b bytes.Buffer
+		l := New("logrusx-aws", "v0.0.0", ForceFormat("aws"), ForceLevel(logrus.DebugLevel))
+		l.Logrus().Out = &b
+
+		l.Info("foo bar")
+
+		entry := decode(t, &b)
+		assert.Equal(t, "info", entry["level"])
+		assert.Equal(t, "foo bar", entry["msg"])
+		assert.NotContains(t, entry, "_aws")
+	})
+
+	t.Run("format=aws with metrics", func(t *testing.T) {
+		var b bytes.Buffer
+		l := New("logrusx-aws", "v0.0.0", ForceFormat("aws"), ForceLevel(logrus.DebugLevel))
+		l.Logrus().Out = &b
+
+		l.WithField("latency", EMFMetric{Value: 12.5, Unit: "Milliseconds"}).Info("Request completed.")
+
+		entry := decode(t, &b)
+		assert.Equal(t, 12.5, entry["latency"])
+
+		aws := entry["_aws"].(map[string]interface{})
+		assert.NotZero(t, aws["Timestamp"])
+		assert.Equal(t, []interface{}{map[string]interface{}{
+			"Namespace":  "logrusx-aws",
+			"Dimensions": []interface{}{[]interface{}{"service_name"}},
+			"Metrics":    []interface{}{map[string]interface{}{"Name": "latency", "Unit": "Milliseconds"}},
+		}}, aws["CloudWatchMetrics"])
+	})
+}
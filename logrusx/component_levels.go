@@ -0,0 +1,120 @@
+package logrusx
+
+import (
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/ory/x/stringsx"
+)
+
+// ComponentKey is the field which contains the name of the component an entry was logged by.
+// See WithComponent.
+const ComponentKey = "component"
+
+// ForceComponentLevels overrides the log level of the given components, taking precedence over
+// the "log.levels" configuration and the LOG_LEVELS environment variable.
+func ForceComponentLevels(levels map[string]logrus.Level) Option {
+	return func(o *options) {
+		o.componentLevels = levels
+	}
+}
+
+// WithComponent returns a logger for the named component. Its entries are filtered using the
+// component's log level, if one was configured, for example using "log.levels: proxy=debug".
+func (l *Logger) WithComponent(name string) *Logger {
+	return l.WithField(ComponentKey, name)
+}
+
+// ParseComponentLevels parses per-component log levels in the form "proxy=debug,sql=warn".
+func ParseComponentLevels(s string) (map[string]logrus.Level, error) {
+	levels := map[string]logrus.Level{}
+	for _, pair := range stringsx.Splitx(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, errors.Errorf("invalid component log level %q, expected the format component=level", pair)
+		}
+
+		level, err := logrus.ParseLevel(strings.TrimSpace(kv[1]))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		levels[strings.TrimSpace(kv[0])] = level
+	}
+	return levels, nil
+}
+
+// IsLevelEnabled returns true if entries of the level are logged by this logger, taking the
+// level of its component into account. Use it instead of the method of the logrus.Logger, which
+// reports the most verbose level of all components if component levels are configured.
+func (l *Logger) IsLevelEnabled(level logrus.Level) bool {
+	if f := componentFilter(l.Logger); f != nil {
+		return level <= f.level(l.Data)
+	}
+	return l.Logger.IsLevelEnabled(level)
+}
+
+// setComponentLevels configures the component level overrides. Because logrus checks the level
+// before an entry is created, the logger's level is raised to the most verbose level of all
+// components. Entries below the level of their component are then dropped by the pipeline
+// before any hook fires.
+func setComponentLevels(l *logrus.Logger, o *options) {
+	var f *componentLevelFilter
+	defer func() { o.pipeline.setFilter(f) }()
+
+	levels := o.componentLevels
+	if levels == nil {
+		var err error
+		levels, err = ParseComponentLevels(stringsx.Coalesce(o.c.String("log.levels"), os.Getenv("LOG_LEVELS")))
+		if err != nil {
+			l.WithError(err).Warn("got invalid \"log.levels\", ignoring component log levels")
+			return
+		}
+	}
+	if len(levels) == 0 {
+		return
+	}
+
+	f = &componentLevelFilter{base: uint32(l.Level), levels: levels}
+	l.Level = f.loggerLevel()
+}
+
+type componentLevelFilter struct {
+	// base is the level of entries without a component override. It is accessed atomically
+	// because it can be changed at runtime, see NewLevelHandler.
+	base   uint32
+	levels map[string]logrus.Level
+}
+
+// loggerLevel returns the most verbose of the base and all component levels.
+func (f *componentLevelFilter) loggerLevel() logrus.Level {
+	level := logrus.Level(atomic.LoadUint32(&f.base))
+	for _, l := range f.levels {
+		if l > level {
+			level = l
+		}
+	}
+	return level
+}
+
+// level returns the level of entries with the fields.
+func (f *componentLevelFilter) level(data logrus.Fields) logrus.Level {
+	if component, ok := data[ComponentKey].(string); ok {
+		if l, ok := f.levels[component]; ok {
+			return l
+		}
+	}
+	return logrus.Level(atomic.LoadUint32(&f.base))
+}
+
+func (f *componentLevelFilter) enabled(e *logrus.Entry) bool {
+	return e.Level <= f.level(e.Data)
+}
@@ -1,6 +1,8 @@
 package logrusx
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/sirupsen/logrus/hooks/test"
@@ -62,3 +64,95 @@ func TestConfigSchema(t *testing.T) {
 		assert.Len(t, h.Entries, 0)
 	})
 }
+
+func TestComponentLevels(t *testing.T) {
+	load := func(t *testing.T, levels string) *koanf.Koanf {
+		k := koanf.New(".")
+		require.NoError(t, k.Load(rawbytes.Provider([]byte(`{"log":{"level":"info","levels":"`+levels+`"}}`)), json.Parser()))
+		return k
+	}
+
+	h := &test.Hook{}
+	l := New("foo", "bar", WithHook(h), WithConfigurator(load(t, "proxy=debug, sql=warn")))
+	proxy, sql := l.WithComponent("proxy"), l.WithComponent("sql")
+
+	log := func() []string {
+		var b bytes.Buffer
+		l.Logger.Out = &b
+		l.Debug("app debug")
+		l.Info("app info")
+		proxy.Debug("proxy debug")
+		sql.Info("sql info")
+		sql.Warn("sql warn")
+		return strings.Split(strings.TrimSpace(b.String()), "\n")
+	}
+
+	h.Reset()
+	lines := log()
+	require.Len(t, lines, 3, "%v", lines)
+	assert.Contains(t, lines[0], "app info")
+	assert.Contains(t, lines[1], "proxy debug")
+	assert.Contains(t, lines[2], "sql warn")
+
+	t.Run("case=filters entries before hooks fire", func(t *testing.T) {
+		entries := h.AllEntries()
+		require.Len(t, entries, 3)
+		for k, message := range []string{"app info", "proxy debug", "sql warn"} {
+			assert.Equal(t, message, entries[k].Message)
+		}
+	})
+
+	t.Run("case=filters hooks added after construction", func(t *testing.T) {
+		late := &test.Hook{}
+		proxy.AddHook(late)
+		log()
+		entries := late.AllEntries()
+		require.Len(t, entries, 3)
+		for k, message := range []string{"app info", "proxy debug", "sql warn"} {
+			assert.Equal(t, message, entries[k].Message)
+		}
+	})
+
+	t.Run("case=reports enabled levels of the component", func(t *testing.T) {
+		assert.False(t, l.IsLevelEnabled(logrus.DebugLevel))
+		assert.True(t, l.IsLevelEnabled(logrus.InfoLevel))
+		assert.True(t, proxy.IsLevelEnabled(logrus.DebugLevel))
+		assert.False(t, proxy.IsLevelEnabled(logrus.TraceLevel))
+		assert.False(t, sql.IsLevelEnabled(logrus.InfoLevel))
+		assert.True(t, l.WithComponent("other").IsLevelEnabled(logrus.InfoLevel))
+	})
+
+	t.Run("case=reloads the component levels", func(t *testing.T) {
+		l.UseConfig(load(t, "sql=info"))
+		h.Reset()
+		lines := log()
+		require.Len(t, lines, 3, "%v", lines)
+		assert.Contains(t, lines[0], "app info")
+		assert.Contains(t, lines[1], "sql info")
+		assert.Contains(t, lines[2], "sql warn")
+		assert.Len(t, h.AllEntries(), 3)
+	})
+
+	t.Run("case=ignores invalid component levels", func(t *testing.T) {
+		l.UseConfig(load(t, "sql"))
+		assert.Equal(t, logrus.InfoLevel, l.Logger.Level)
+		assert.Contains(t, h.LastEntry().Message, "got invalid \"log.levels\"")
+		assert.True(t, proxy.IsLevelEnabled(logrus.InfoLevel))
+		assert.False(t, proxy.IsLevelEnabled(logrus.DebugLevel))
+
+		h.Reset()
+		l.Debug("app debug")
+		assert.Empty(t, h.AllEntries())
+	})
+}
+
+func TestParseComponentLevels(t *testing.T) {
+	levels, err := ParseComponentLevels(" proxy = debug,sql=warn, ")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]logrus.Level{"proxy": logrus.DebugLevel, "sql": logrus.WarnLevel}, levels)
+
+	_, err = ParseComponentLevels("proxy=verbose")
+	require.Error(t, err)
+	_, err = ParseComponentLevels("=debug")
+	require.Error(t, err)
+}
@@ -0,0 +1,238 @@
+package logrusx
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// GenesisHash is the previous hash of the first entry of a hash chain.
+var GenesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+// HashChainConfig configures NewHashChainWriter.
+type HashChainConfig struct {
+	// PrevHash continues an existing chain, for example after a restart. Defaults to
+	// GenesisHash, which starts a new chain.
+	PrevHash string
+
+	// AnchorEvery writes an anchor statement after this many entries. Zero disables anchors.
+	AnchorEvery uint64
+
+	// OnAnchor is called with every anchor statement's sequence number and hash. Store them
+	// outside of the log, so that removing entries from the end of the log can be detected.
+	OnAnchor func(seq uint64, hash string)
+}
+
+// WithHashChain makes the audit trail tamper-evident. Each entry receives a sequence number,
+// the hash of the previous entry, and its own hash, which covers the entry and the previous
+// hash. Modified, inserted, reordered, or removed entries can be detected using
+// VerifyHashChain.
+//
+// The chain is computed by a writer wrapping the logger's output, see NewHashChainWriter. If
+// the output is replaced later on, it must be wrapped using NewHashChainWriter as well. Entries
+// sent to a log/slog handler using WithSlogHandler do not pass the logger's output and are not
+// chained.
+func WithHashChain(c HashChainConfig) Option {
+	return func(o *options) {
+		o.hashChain = &c
+	}
+}
+
+type hashChainWriter struct {
+	sync.Mutex
+	w        io.Writer
+	c        HashChainConfig
+	prevHash string
+	seq      uint64
+	now      func() time.Time
+}
+
+// NewHashChainWriter returns a writer which adds the hash chain fields "seq", "prev_hash", and
+// "hash" to every log line written to it and writes the result to w. JSON lines (including
+// GELF) receive the fields as object members, all other lines as trailing key=value pairs.
+//
+// Logrus writes each entry with a single call while holding its lock, so the order of the
+// chain matches the order of the output.
+func NewHashChainWriter(w io.Writer, c HashChainConfig) io.Writer {
+	if c.PrevHash == "" {
+		c.PrevHash = GenesisHash
+	}
+	return &hashChainWriter{w: w, c: c, prevHash: c.PrevHash, now: time.Now}
+}
+
+func (w *hashChainWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	var out bytes.Buffer
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		line = bytes.TrimRight(line, "\n")
+		if len(line) == 0 {
+			continue
+		}
+
+		w.seq++
+		hash := chainHash(w.prevHash, w.seq, line)
+		out.Write(appendChainFields(line, w.seq, w.prevHash, hash))
+		out.WriteByte('\n')
+		w.prevHash = hash
+
+		if w.c.AnchorEvery > 0 && w.seq%w.c.AnchorEvery == 0 {
+			out.Write(anchorStatement(isJSONLine(line), w.seq, hash, w.now()))
+			out.WriteByte('\n')
+			if w.c.OnAnchor != nil {
+				w.c.OnAnchor(w.seq, hash)
+			}
+		}
+	}
+
+	if _, err := w.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func chainHash(prevHash string, seq uint64, line []byte) string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s\n%d\n", prevHash, seq)
+	_, _ = h.Write(line)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isJSONLine(line []byte) bool {
+	return len(line) > 1 && line[0] == '{' && line[len(line)-1] == '}'
+}
+
+func appendChainFields(line []byte, seq uint64, prevHash, hash string) []byte {
+	if isJSONLine(line) {
+		fields := fmt.Sprintf(`"seq":%d,"prev_hash":"%s","hash":"%s"}`, seq, prevHash, hash)
+		if len(bytes.TrimSpace(line[1:len(line)-1])) > 0 {
+			fields = "," + fields
+		}
+		return append(line[:len(line)-1:len(line)-1], fields...)
+	}
+	return append(line[:len(line):len(line)], fmt.Sprintf(" seq=%d prev_hash=%s hash=%s", seq, prevHash, hash)...)
+}
+
+const anchorMessage = "Audit log hash chain anchor."
+
+func anchorStatement(asJSON bool, seq uint64, hash string, now time.Time) []byte {
+	if asJSON {
+		out, _ := json.Marshal(map[string]interface{}{
+			"msg":         anchorMessage,
+			"time":        now.Format(time.RFC3339),
+			"anchor_seq":  seq,
+			"anchor_hash": hash,
+		})
+		return out
+	}
+	return []byte(fmt.Sprintf(`time=%s msg="%s" anchor_seq=%d anchor_hash=%s`, now.Format(time.RFC3339), anchorMessage, seq, hash))
+}
+
+var (
+	jsonChainFields  = regexp.MustCompile(`,?"seq":(\d+),"prev_hash":"([0-9a-f]{64})","hash":"([0-9a-f]{64})"}$`)
+	textChainFields  = regexp.MustCompile(` seq=(\d+) prev_hash=([0-9a-f]{64}) hash=([0-9a-f]{64})$`)
+	textAnchorFields = regexp.MustCompile(` anchor_seq=(\d+) anchor_hash=([0-9a-f]{64})$`)
+)
+
+// VerifyHashChain reads a log written using WithHashChain and verifies the hash chain and all
+// anchor statements. The chain must start at prevHash, which is GenesisHash unless
+// HashChainConfig.PrevHash was used. It returns the hash of the last entry, which can be
+// compared with the last anchor stored outside of the log.
+func VerifyHashChain(r io.Reader, prevHash string) (string, error) {
+	if prevHash == "" {
+		prevHash = GenesisHash
+	}
+
+	var seq uint64
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; s.Scan(); line++ {
+		l := s.Bytes()
+		if len(l) == 0 {
+			continue
+		}
+
+		fields := textChainFields
+		if isJSONLine(l) {
+			fields = jsonChainFields
+		}
+		loc := fields.FindSubmatchIndex(l)
+		if loc == nil {
+			anchorSeq, anchorHash, ok := parseAnchorStatement(l)
+			if !ok {
+				return "", errors.Errorf("line %d: hash chain fields are missing", line)
+			}
+			if anchorSeq != seq || anchorHash != prevHash {
+				return "", errors.Errorf("line %d: anchor statement does not match the hash chain", line)
+			}
+			continue
+		}
+
+		original := append([]byte{}, l[:loc[0]]...)
+		if isJSONLine(l) {
+			original = append(original, '}')
+		}
+
+		entrySeq, err := strconv.ParseUint(string(l[loc[2]:loc[3]]), 10, 64)
+		if err != nil {
+			return "", errors.Wrapf(err, "line %d", line)
+		}
+		if entrySeq != seq+1 {
+			return "", errors.Errorf("line %d: expected sequence number %d but got %d", line, seq+1, entrySeq)
+		}
+		if string(l[loc[4]:loc[5]]) != prevHash {
+			return "", errors.Errorf("line %d: previous hash does not match the hash of the previous entry", line)
+		}
+
+		hash := chainHash(prevHash, entrySeq, original)
+		if string(l[loc[6]:loc[7]]) != hash {
+			return "", errors.Errorf("line %d: hash does not match the entry", line)
+		}
+
+		seq, prevHash = entrySeq, hash
+	}
+
+	if err := s.Err(); err != nil {
+		return "", errors.WithStack(err)
+	}
+	return prevHash, nil
+}
+
+func parseAnchorStatement(line []byte) (uint64, string, bool) {
+	if isJSONLine(line) {
+		var anchor struct {
+			Message string  `json:"msg"`
+			Seq     *uint64 `json:"anchor_seq"`
+			Hash    string  `json:"anchor_hash"`
+		}
+		if err := json.Unmarshal(line, &anchor); err != nil || anchor.Message != anchorMessage || anchor.Seq == nil {
+			return 0, "", false
+		}
+		return *anchor.Seq, anchor.Hash, true
+	}
+
+	m := textAnchorFields.FindSubmatch(line)
+	if m == nil || !bytes.Contains(line, []byte(anchorMessage)) {
+		return 0, "", false
+	}
+	seq, err := strconv.ParseUint(string(m[1]), 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return seq, string(m[2]), true
+}
@@ -0,0 +1,111 @@
+package logrusx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/logrusx"
+)
+
+func TestHashChain(t *testing.T) {
+	newAudit := func(t *testing.T, format string, c HashChainConfig) (*Logger, *bytes.Buffer) {
+		var b bytes.Buffer
+		l := NewAudit("logrusx-hash-chain", "v0.0.0", ForceFormat(format), ForceLevel(logrus.DebugLevel))
+		l.Logrus().Out = NewHashChainWriter(&b, c)
+		return l, &b
+	}
+
+	writeEntries := func(l *Logger, n int) {
+		for i := 0; i < n; i++ {
+			l.WithField("i", i).Info("Access granted.")
+		}
+	}
+
+	for _, format := range []string{"json", "text", "gelf"} {
+		t.Run("format="+format, func(t *testing.T) {
+			var anchors []string
+			l, b := newAudit(t, format, HashChainConfig{AnchorEvery: 2, OnAnchor: func(_ uint64, hash string) {
+				anchors = append(anchors, hash)
+			}})
+			writeEntries(l, 5)
+
+			lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+			require.Len(t, lines, 7, b.String())
+			require.Len(t, anchors, 2)
+
+			head, err := VerifyHashChain(strings.NewReader(b.String()), "")
+			require.NoError(t, err)
+			assert.Len(t, head, 64)
+
+			t.Run("case=detects modified entries", func(t *testing.T) {
+				_, err := VerifyHashChain(strings.NewReader(strings.Replace(b.String(), "granted", "denied", 1)), "")
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "line 1")
+			})
+
+			t.Run("case=detects removed entries", func(t *testing.T) {
+				tampered := strings.Join(append(lines[:3:3], lines[4:]...), "\n")
+				_, err := VerifyHashChain(strings.NewReader(tampered), "")
+				require.Error(t, err)
+			})
+
+			t.Run("case=detects reordered entries", func(t *testing.T) {
+				tampered := strings.Join([]string{lines[1], lines[0]}, "\n")
+				_, err := VerifyHashChain(strings.NewReader(tampered), "")
+				require.Error(t, err)
+			})
+
+			t.Run("case=detects forged anchors", func(t *testing.T) {
+				tampered := strings.Join([]string{lines[0], lines[2]}, "\n")
+				_, err := VerifyHashChain(strings.NewReader(tampered), "")
+				require.Error(t, err)
+			})
+		})
+	}
+
+	t.Run("case=adds chain fields to json entries", func(t *testing.T) {
+		l, b := newAudit(t, "json", HashChainConfig{})
+		writeEntries(l, 2)
+
+		var entries []map[string]interface{}
+		dec := json.NewDecoder(b)
+		for dec.More() {
+			var entry map[string]interface{}
+			require.NoError(t, dec.Decode(&entry))
+			entries = append(entries, entry)
+		}
+
+		require.Len(t, entries, 2)
+		assert.Equal(t, "audit", entries[0]["audience"])
+		assert.EqualValues(t, 1, entries[0]["seq"])
+		assert.Equal(t, GenesisHash, entries[0]["prev_hash"])
+		assert.EqualValues(t, 2, entries[1]["seq"])
+		assert.Equal(t, entries[0]["hash"], entries[1]["prev_hash"])
+	})
+
+	t.Run("case=continues an existing chain", func(t *testing.T) {
+		l, b := newAudit(t, "json", HashChainConfig{})
+		writeEntries(l, 2)
+		head, err := VerifyHashChain(bytes.NewReader(b.Bytes()), "")
+		require.NoError(t, err)
+
+		l, b = newAudit(t, "json", HashChainConfig{PrevHash: head})
+		writeEntries(l, 2)
+		_, err = VerifyHashChain(bytes.NewReader(b.Bytes()), "")
+		require.Error(t, err)
+		_, err = VerifyHashChain(bytes.NewReader(b.Bytes()), head)
+		require.NoError(t, err)
+	})
+
+	t.Run("case=option wraps the output", func(t *testing.T) {
+		l := NewAudit("logrusx-hash-chain", "v0.0.0", WithHashChain(HashChainConfig{}))
+		assert.NotEqual(t, os.Stderr, l.Logrus().Out)
+	})
+}
@@ -14,6 +14,7 @@ import (
 
 	"go.opentelemetry.io/contrib/instrumentation/net/http/httptrace/otelhttptrace"
 	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ory/x/errorsx"
 )
@@ -21,6 +22,7 @@ import (
 type Logger struct {
 	*logrus.Entry
 	leakSensitive bool
+	redaction     *RedactionRules
 	opts          []Option
 	name          string
 	version       string
@@ -42,9 +44,18 @@ func (l *Logger) NewEntry() *Logger {
 	return &ll
 }
 
+// WithContext returns a logger which logs with the given context. If the context contains a
+// valid OpenTelemetry span, the "trace_id" and "span_id" fields are added so that logs can be
+// correlated with traces.
 func (l *Logger) WithContext(ctx context.Context) *Logger {
 	ll := *l
-	ll.Entry = l.Logger.WithContext(ctx)
+	ll.Entry = l.Entry.WithContext(ctx)
+	if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+		ll.Entry = ll.Entry.WithFields(logrus.Fields{
+			"trace_id": spanCtx.TraceID().String(),
+			"span_id":  spanCtx.SpanID().String(),
+		})
+	}
 	return &ll
 }
 
@@ -52,10 +63,16 @@ func (l *Logger) HTTPHeadersRedacted(h http.Header) map[string]interface{} {
 	headers := map[string]interface{}{}
 	if cookie := l.maybeRedact(h.Get("Cookie")); cookie != nil {
 		headers["cookie"] = cookie
+		if l.redaction.redactsHeader("Cookie") {
+			headers["cookie"] = Redacted
+		}
 	}
 
 	if auth := l.maybeRedact(h.Get("Authorization")); auth != nil {
 		headers["authorization"] = auth
+		if l.redaction.redactsHeader("Authorization") {
+			headers["authorization"] = Redacted
+		}
 	}
 
 	for key := range h {
@@ -63,6 +80,10 @@ func (l *Logger) HTTPHeadersRedacted(h http.Header) map[string]interface{} {
 			strings.ToLower(key) == "authorization" {
 			continue
 		}
+		if l.redaction.redactsHeader(key) {
+			headers[strings.ToLower(key)] = Redacted
+			continue
+		}
 		headers[strings.ToLower(key)] = h.Get(key)
 	}
 
@@ -136,7 +157,7 @@ func (l *Logger) WithError(err error) *Logger {
 	}
 
 	ctx := map[string]interface{}{"message": err.Error()}
-	if l.Entry.Logger.IsLevelEnabled(logrus.TraceLevel) {
+	if l.IsLevelEnabled(logrus.TraceLevel) {
 		if e, ok := err.(errorsx.StackTracer); ok {
 			ctx["trace"] = fmt.Sprintf("%+v", e.StackTrace())
 		} else {
@@ -0,0 +1,183 @@
+package logrusx
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type (
+	levelHandler struct {
+		l     *logrus.Logger
+		token string
+		now   func() time.Time
+
+		sync.Mutex
+		reset    *time.Timer
+		resetAt  time.Time
+		previous logrus.Level
+	}
+	// LevelHandlerOption configures NewLevelHandler.
+	LevelHandlerOption func(*levelHandler)
+
+	levelState struct {
+		Level    string     `json:"level"`
+		ResetAt  *time.Time `json:"reset_at,omitempty"`
+		Duration string     `json:"duration,omitempty"`
+	}
+)
+
+// LevelHandlerWithToken requires requests to carry the token in the "Authorization: Bearer"
+// header.
+func LevelHandlerWithToken(token string) LevelHandlerOption {
+	return func(h *levelHandler) {
+		h.token = token
+	}
+}
+
+// NewLevelHandler returns an administrative handler which reports and changes the log level
+// at runtime. GET returns the current level:
+//
+//	{"level": "info"}
+//
+// PUT changes it, optionally only for the given duration, after which the previous level is
+// restored:
+//
+//	{"level": "debug", "duration": "15m"}
+//
+// Component level overrides (see ForceComponentLevels) remain in effect. The handler should
+// not be exposed publicly; use LevelHandlerWithToken to protect it.
+func NewLevelHandler(l *Logger, opts ...LevelHandlerOption) http.Handler {
+	h := &levelHandler{l: l.Logrus(), now: time.Now}
+	for _, o := range opts {
+		o(h)
+	}
+	return h
+}
+
+func (h *levelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeLevelError(w, http.StatusUnauthorized, "a valid bearer token is required")
+			return
+		}
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		var req levelState
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			writeLevelError(w, http.StatusBadRequest, "unable to decode the request body: "+err.Error())
+			return
+		}
+
+		level, err := logrus.ParseLevel(req.Level)
+		if err != nil {
+			writeLevelError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		var d time.Duration
+		if req.Duration != "" {
+			if d, err = time.ParseDuration(req.Duration); err != nil || d <= 0 {
+				writeLevelError(w, http.StatusBadRequest, "duration must be a positive duration such as \"15m\"")
+				return
+			}
+		}
+
+		h.setLevel(level, d)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST")
+		writeLevelError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(h.state())
+}
+
+func (h *levelHandler) setLevel(level logrus.Level, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	previous := baseLevel(h.l)
+	if h.reset != nil {
+		// A temporary level is active, so the level to restore is still the one from before.
+		h.reset.Stop()
+		previous = h.previous
+		h.reset = nil
+		h.resetAt = time.Time{}
+	}
+
+	setBaseLevel(h.l, level)
+	if d <= 0 {
+		return
+	}
+
+	h.previous = previous
+	h.resetAt = h.now().Add(d)
+	var reset *time.Timer
+	reset = time.AfterFunc(d, func() {
+		h.Lock()
+		defer h.Unlock()
+		if h.reset != reset {
+			return
+		}
+		h.reset = nil
+		h.resetAt = time.Time{}
+		setBaseLevel(h.l, h.previous)
+	})
+	h.reset = reset
+}
+
+func (h *levelHandler) state() *levelState {
+	h.Lock()
+	defer h.Unlock()
+
+	s := &levelState{Level: baseLevel(h.l).String()}
+	if !h.resetAt.IsZero() {
+		resetAt := h.resetAt
+		s.ResetAt = &resetAt
+	}
+	return s
+}
+
+func writeLevelError(w http.ResponseWriter, code int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// componentFilter returns the component level filter installed by setComponentLevels, if any.
+func componentFilter(l *logrus.Logger) *componentLevelFilter {
+	if p := pipelineOf(l); p != nil {
+		return p.componentFilter()
+	}
+	return nil
+}
+
+// baseLevel returns the level of entries without a component level override.
+func baseLevel(l *logrus.Logger) logrus.Level {
+	if f := componentFilter(l); f != nil {
+		return logrus.Level(atomic.LoadUint32(&f.base))
+	}
+	return l.GetLevel()
+}
+
+func setBaseLevel(l *logrus.Logger, level logrus.Level) {
+	if f := componentFilter(l); f != nil {
+		atomic.StoreUint32(&f.base, uint32(level))
+		l.SetLevel(f.loggerLevel())
+		return
+	}
+	l.SetLevel(level)
+}
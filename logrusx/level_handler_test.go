@@ -0,0 +1,108 @@
+package logrusx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/logrusx"
+)
+
+func TestLevelHandler(t *testing.T) {
+	do := func(t *testing.T, h http.Handler, method, body, token string) (int, map[string]interface{}) {
+		req := httptest.NewRequest(method, "/admin/log/level", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		var res map[string]interface{}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res), rec.Body.String())
+		return rec.Code, res
+	}
+
+	t.Run("case=reports and changes the level", func(t *testing.T) {
+		l := New("logrusx-level", "v0.0.0", ForceLevel(logrus.InfoLevel))
+		h := NewLevelHandler(l)
+
+		code, res := do(t, h, "GET", "", "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "info", res["level"])
+
+		code, res = do(t, h, "PUT", `{"level":"debug"}`, "")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "debug", res["level"])
+		assert.Equal(t, logrus.DebugLevel, l.Logrus().GetLevel())
+	})
+
+	t.Run("case=rejects invalid requests", func(t *testing.T) {
+		h := NewLevelHandler(New("logrusx-level", "v0.0.0"))
+		for _, tc := range []struct {
+			method, body string
+			code         int
+		}{
+			{method: "PUT", body: `{"level":"loud"}`, code: http.StatusBadRequest},
+			{method: "PUT", body: `{"level":"debug","duration":"-1m"}`, code: http.StatusBadRequest},
+			{method: "PUT", body: `{`, code: http.StatusBadRequest},
+			{method: "DELETE", code: http.StatusMethodNotAllowed},
+		} {
+			code, res := do(t, h, tc.method, tc.body, "")
+			assert.Equal(t, tc.code, code, "%+v", tc)
+			assert.NotEmpty(t, res["error"])
+		}
+	})
+
+	t.Run("case=requires the token", func(t *testing.T) {
+		h := NewLevelHandler(New("logrusx-level", "v0.0.0"), LevelHandlerWithToken("secret"))
+
+		code, _ := do(t, h, "GET", "", "")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = do(t, h, "GET", "", "wrong")
+		assert.Equal(t, http.StatusUnauthorized, code)
+		code, _ = do(t, h, "GET", "", "secret")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("case=restores the level after the duration", func(t *testing.T) {
+		l := New("logrusx-level", "v0.0.0", ForceLevel(logrus.WarnLevel))
+		h := NewLevelHandler(l)
+
+		_, res := do(t, h, "PUT", `{"level":"debug","duration":"10s"}`, "")
+		assert.NotEmpty(t, res["reset_at"])
+
+		// Changing the level again keeps restoring the level from before the first change.
+		_, res = do(t, h, "PUT", `{"level":"trace","duration":"50ms"}`, "")
+		assert.Equal(t, "trace", res["level"])
+
+		require.Eventually(t, func() bool {
+			return l.Logrus().GetLevel() == logrus.WarnLevel
+		}, time.Second, 10*time.Millisecond)
+		_, res = do(t, h, "GET", "", "")
+		assert.Equal(t, "warning", res["level"])
+		assert.NotContains(t, res, "reset_at")
+	})
+
+	t.Run("case=keeps component levels", func(t *testing.T) {
+		var b bytes.Buffer
+		l := New("logrusx-level", "v0.0.0", ForceLevel(logrus.WarnLevel), ForceComponentLevels(map[string]logrus.Level{"sql": logrus.ErrorLevel}))
+		l.Logrus().Out = &b
+		h := NewLevelHandler(l)
+
+		_, res := do(t, h, "PUT", `{"level":"debug"}`, "")
+		assert.Equal(t, "debug", res["level"])
+
+		l.Debug("visible")
+		l.WithComponent("sql").Warn("invisible")
+		assert.Contains(t, b.String(), "visible")
+		assert.NotContains(t, b.String(), "invisible")
+	})
+}
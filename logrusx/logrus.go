@@ -23,7 +23,14 @@ type (
 		exitFunc      func(int)
 		leakSensitive bool
 		hooks         []logrus.Hook
+		sampling      *SamplingConfig
+		redaction     *RedactionRules
+		hashChain     *HashChainConfig
+		async         *AsyncWriterConfig
 		c             configurator
+
+		componentLevels map[string]logrus.Level
+		pipeline        *pipeline
 	}
 	Option           func(*options)
 	nullConfigurator struct{}
@@ -56,14 +63,38 @@ func newLogger(parent *logrus.Logger, o *options) *logrus.Logger {
 		l.ExitFunc = o.exitFunc
 	}
 
+	o.pipeline = usePipeline(l)
 	for _, hook := range o.hooks {
-		l.AddHook(hook)
+		o.pipeline.addHook(hook)
+	}
+	if o.sampling != nil {
+		o.pipeline.setSampler(newSampler(*o.sampling))
+	}
+	if o.redaction != nil {
+		o.pipeline.setRedaction(o.redaction)
 	}
 
 	setLevel(l, o)
 	setFormatter(l, o)
 
-	l.ReportCaller = o.reportCaller || l.IsLevelEnabled(logrus.TraceLevel)
+	if o.hashChain != nil {
+		l.Out = NewHashChainWriter(l.Out, *o.hashChain)
+	}
+	if o.async != nil {
+		w := NewAsyncWriter(l.Out, *o.async)
+		l.Out = w
+
+		exit := l.ExitFunc
+		if exit == nil {
+			exit = os.Exit
+		}
+		l.ExitFunc = func(code int) {
+			_ = w.Close()
+			exit(code)
+		}
+	}
+
+	l.ReportCaller = o.reportCaller || baseLevel(l) == logrus.TraceLevel
 	return l
 }
 
@@ -79,6 +110,8 @@ func setLevel(l *logrus.Logger, o *options) {
 			l.Level = logrus.InfoLevel
 		}
 	}
+
+	setComponentLevels(l, o)
 }
 
 func setFormatter(l *logrus.Logger, o *options) {
@@ -95,6 +128,10 @@ func setFormatter(l *logrus.Logger, o *options) {
 			l.Formatter = &logrus.JSONFormatter{PrettyPrint: true}
 		case format.AddCase("gelf"):
 			l.Formatter = new(gelf.GelfFormatter)
+		case format.AddCase("gcp"):
+			l.Formatter = &GCPFormatter{ProjectID: os.Getenv("GOOGLE_CLOUD_PROJECT")}
+		case format.AddCase("aws"):
+			l.Formatter = new(AWSFormatter)
 		default:
 			unknownFormat = true
 			fallthrough
@@ -110,6 +147,10 @@ func setFormatter(l *logrus.Logger, o *options) {
 			l.WithError(format.ToUnknownCaseErr()).Warn("got unknown \"log.format\", falling back to \"text\"")
 		}
 	}
+
+	if o.pipeline.drops() {
+		l.Formatter = &pipelineFormatter{Formatter: l.Formatter}
+	}
 }
 
 func ForceLevel(level logrus.Level) Option {
@@ -191,6 +232,7 @@ func New(name string, version string, opts ...Option) *Logger {
 		name:          name,
 		version:       version,
 		leakSensitive: o.leakSensitive || o.c.Bool("log.leak_sensitive_values"),
+		redaction:     o.redaction,
 		Entry: newLogger(o.l, o).WithFields(logrus.Fields{
 			"audience": "application", "service_name": name, "service_version": version}),
 	}
@@ -203,6 +245,7 @@ func NewAudit(name string, version string, opts ...Option) *Logger {
 func (l *Logger) UseConfig(c configurator) {
 	l.leakSensitive = l.leakSensitive || c.Bool("log.leak_sensitive_values")
 	o := newOptions(append(l.opts, WithConfigurator(c)))
+	o.pipeline = usePipeline(l.Entry.Logger)
 	setLevel(l.Entry.Logger, o)
 	setFormatter(l.Entry.Logger, o)
 }
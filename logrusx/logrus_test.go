@@ -2,6 +2,7 @@ package logrusx_test
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -11,6 +12,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/ory/herodot"
 
@@ -159,6 +161,24 @@ func TestTextLogger(t *testing.T) {
 				l.WithField("foo", "bar").Info("baz!")
 			},
 		},
+		{
+			l:      debugger,
+			expect: []string{"audience=application", "service_name=logrusx-server", "baz!", "trace_id=4bf92f3577b34da6a3ce929d0e0e4736", "span_id=00f067aa0ba902b7"},
+			call: func(l *Logger) {
+				traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+				spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+				ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID}))
+				l.WithContext(ctx).Info("baz!")
+			},
+		},
+		{
+			l:         debugger,
+			expect:    []string{"audience=application", "baz!"},
+			notExpect: []string{"trace_id", "span_id"},
+			call: func(l *Logger) {
+				l.WithContext(context.Background()).Info("baz!")
+			},
+		},
 	} {
 		t.Run("case="+strconv.Itoa(k), func(t *testing.T) {
 			var b bytes.Buffer
@@ -0,0 +1,148 @@
+package logrusx
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sirupsen/logrus"
+)
+
+// pipeline is the only hook the logger registers with logrus. It drops entries below the level
+// of their component or not selected by sampling before any hook fires, applies the redaction
+// rules, and then fires the hooks added with WithHook or Logger.AddHook. Dropped entries are
+// marked so that the pipelineFormatter writes nothing.
+type pipeline struct {
+	sync.RWMutex
+	filter    *componentLevelFilter
+	sampler   *sampler
+	redaction *RedactionRules
+	hooks     logrus.LevelHooks
+}
+
+var _ logrus.Hook = (*pipeline)(nil)
+
+func newPipeline() *pipeline {
+	return &pipeline{hooks: logrus.LevelHooks{}}
+}
+
+// pipelineOf returns the pipeline installed by newLogger, if any.
+func pipelineOf(l *logrus.Logger) *pipeline {
+	for _, h := range l.Hooks[logrus.PanicLevel] {
+		if p, ok := h.(*pipeline); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+// usePipeline returns the pipeline of the logger, and installs one if there is none.
+func usePipeline(l *logrus.Logger) *pipeline {
+	if p := pipelineOf(l); p != nil {
+		return p
+	}
+	p := newPipeline()
+	l.AddHook(p)
+	return p
+}
+
+func (p *pipeline) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (p *pipeline) addHook(hook logrus.Hook) {
+	p.Lock()
+	defer p.Unlock()
+
+	// Copy the hooks, so that entries which are being logged keep firing the previous ones.
+	hooks := make(logrus.LevelHooks, len(p.hooks))
+	for level, hs := range p.hooks {
+		hooks[level] = hs
+	}
+	hooks.Add(hook)
+	p.hooks = hooks
+}
+
+func (p *pipeline) setFilter(f *componentLevelFilter) {
+	p.Lock()
+	defer p.Unlock()
+	p.filter = f
+}
+
+func (p *pipeline) setSampler(s *sampler) {
+	p.Lock()
+	defer p.Unlock()
+	p.sampler = s
+}
+
+func (p *pipeline) setRedaction(r *RedactionRules) {
+	p.Lock()
+	defer p.Unlock()
+	p.redaction = r
+}
+
+// drops returns true if the pipeline drops entries, which the formatter must then skip.
+func (p *pipeline) drops() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.filter != nil || p.sampler != nil
+}
+
+func (p *pipeline) componentFilter() *componentLevelFilter {
+	p.RLock()
+	defer p.RUnlock()
+	return p.filter
+}
+
+func (p *pipeline) Fire(e *logrus.Entry) error {
+	p.RLock()
+	filter, sampler, redaction, hooks := p.filter, p.sampler, p.redaction, p.hooks
+	p.RUnlock()
+
+	// The sampler counts entries, so it only sees entries which pass the component levels.
+	if filter != nil && !filter.enabled(e) || sampler != nil && !sampler.sample(e) {
+		drop(e)
+		return nil
+	}
+	if redaction != nil {
+		redaction.redactEntry(e)
+	}
+	return hooks.Fire(e.Level, e)
+}
+
+type droppedKey struct{}
+
+// drop marks the entry so that it is not written. Logrus writes the same entry it passed to the
+// hooks, and its context is never shared with other entries.
+func drop(e *logrus.Entry) {
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	e.Context = context.WithValue(ctx, droppedKey{}, true)
+}
+
+func dropped(e *logrus.Entry) bool {
+	return e.Context != nil && e.Context.Value(droppedKey{}) != nil
+}
+
+// pipelineFormatter writes nothing for entries dropped by the pipeline.
+type pipelineFormatter struct {
+	logrus.Formatter
+}
+
+func (f *pipelineFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	if dropped(e) {
+		return nil, nil
+	}
+	return f.Formatter.Format(e)
+}
+
+// AddHook adds a hook which fires for the entries which are written, after the component levels,
+// sampling, and redaction rules were applied. Hooks added to the logrus.Logger directly fire for all entries instead.
+func (l *Logger) AddHook(hook logrus.Hook) {
+	if p := pipelineOf(l.Logger); p != nil {
+		p.addHook(hook)
+		return
+	}
+	l.Logger.AddHook(hook)
+}
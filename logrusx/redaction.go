@@ -0,0 +1,117 @@
+package logrusx
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Redacted replaces values removed by redaction rules.
+const Redacted = "[REDACTED]"
+
+// RedactionRules remove sensitive values from log entries. Unlike the redaction of sensitive
+// values controlled by "log.leak_sensitive_values", the rules are always applied.
+type RedactionRules struct {
+	// Fields are matched against the keys of all fields, including keys of nested maps. The
+	// values of matching fields are replaced.
+	Fields []*regexp.Regexp
+
+	// Headers are the names of HTTP headers whose values are replaced in
+	// Logger.HTTPHeadersRedacted and Logger.WithRequest.
+	Headers []string
+
+	// Values are matched against the message and all string values. Matching parts are
+	// replaced.
+	Values []*regexp.Regexp
+}
+
+// WithRedactionRules applies the redaction rules to all log entries. The entries are redacted
+// before any hook fires, regardless of the order of the options.
+func WithRedactionRules(r RedactionRules) Option {
+	return func(o *options) {
+		o.redaction = &r
+	}
+}
+
+func (r *RedactionRules) redactsHeader(key string) bool {
+	if r == nil {
+		return false
+	}
+	key = http.CanonicalHeaderKey(key)
+	for _, h := range r.Headers {
+		if http.CanonicalHeaderKey(h) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RedactionRules) redactsField(key string) bool {
+	for _, f := range r.Fields {
+		if f.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RedactionRules) redactString(s string) string {
+	for _, v := range r.Values {
+		s = v.ReplaceAllString(s, Redacted)
+	}
+	return s
+}
+
+// redactValue returns a redacted copy of v. Maps are copied instead of modified because they
+// may be shared with other entries.
+func (r *RedactionRules) redactValue(v interface{}) interface{} {
+	switch vv := v.(type) {
+	case string:
+		return r.redactString(vv)
+	case error:
+		if len(r.Values) == 0 {
+			return v
+		}
+		return r.redactString(vv.Error())
+	case logrus.Fields:
+		return logrus.Fields(r.redactMap(vv))
+	case map[string]interface{}:
+		return r.redactMap(vv)
+	case map[string]string:
+		out := make(map[string]string, len(vv))
+		for k, v := range vv {
+			if r.redactsField(k) {
+				out[k] = Redacted
+			} else {
+				out[k] = r.redactString(v)
+			}
+		}
+		return out
+	case []string:
+		out := make([]string, len(vv))
+		for k, v := range vv {
+			out[k] = r.redactString(v)
+		}
+		return out
+	}
+	return v
+}
+
+func (r *RedactionRules) redactMap(m map[string]interface{}) map[string]interface{} {
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if r.redactsField(k) {
+			out[k] = Redacted
+		} else {
+			out[k] = r.redactValue(v)
+		}
+	}
+	return out
+}
+
+// redactEntry replaces the message and the fields of the entry with redacted copies.
+func (r *RedactionRules) redactEntry(e *logrus.Entry) {
+	e.Message = r.redactString(e.Message)
+	e.Data = r.redactMap(e.Data)
+}
@@ -0,0 +1,86 @@
+package logrusx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/logrusx"
+)
+
+func TestRedactionRules(t *testing.T) {
+	newLogger := func(opts ...Option) (*Logger, *bytes.Buffer) {
+		var b bytes.Buffer
+		l := New("logrusx-redaction", "v0.0.0", append([]Option{ForceFormat("json"), ForceLevel(logrus.DebugLevel), LeakSensitive(), WithRedactionRules(RedactionRules{
+			Fields:  []*regexp.Regexp{regexp.MustCompile(`(?i)(password|secret)`)},
+			Headers: []string{"cookie", "X-Api-Key"},
+			Values:  []*regexp.Regexp{regexp.MustCompile(`ory_st_[A-Za-z0-9]+`)},
+		})}, opts...)...)
+		l.Logrus().Out = &b
+		return l, &b
+	}
+
+	decode := func(t *testing.T, b *bytes.Buffer) map[string]interface{} {
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal(b.Bytes(), &entry), b.String())
+		return entry
+	}
+
+	t.Run("case=redacts fields and values", func(t *testing.T) {
+		l, b := newLogger()
+		l.WithField("password", "hunter2").
+			WithField("identity", map[string]interface{}{"client_secret": "foo", "email": "foo@bar.com"}).
+			WithField("token", "Bearer ory_st_abcd1234").
+			Info("Issued ory_st_abcd1234.")
+
+		entry := decode(t, b)
+		assert.Equal(t, Redacted, entry["password"])
+		assert.Equal(t, map[string]interface{}{"client_secret": Redacted, "email": "foo@bar.com"}, entry["identity"])
+		assert.Equal(t, "Bearer "+Redacted, entry["token"])
+		assert.Equal(t, "Issued "+Redacted+".", entry["msg"])
+		assert.Equal(t, "logrusx-redaction", entry["service_name"])
+	})
+
+	t.Run("case=redacts headers even if leaking sensitive values", func(t *testing.T) {
+		l, b := newLogger()
+		r := *fakeRequest
+		r.Header = fakeRequest.Header.Clone()
+		r.Header.Set("Cookie", "session=secret")
+		r.Header.Set("X-Api-Key", "foo")
+
+		l.WithRequest(&r).Info("request")
+
+		headers := decode(t, b)["http_request"].(map[string]interface{})["headers"].(map[string]interface{})
+		assert.Equal(t, Redacted, headers["cookie"])
+		assert.Equal(t, Redacted, headers["x-api-key"])
+		assert.Equal(t, "application/json", headers["accept"])
+	})
+
+	t.Run("case=redacts before hooks added earlier fire", func(t *testing.T) {
+		h := new(test.Hook)
+		l := New("logrusx-redaction", "v0.0.0", WithHook(h), WithRedactionRules(RedactionRules{
+			Fields: []*regexp.Regexp{regexp.MustCompile(`(?i)password`)},
+			Values: []*regexp.Regexp{regexp.MustCompile(`ory_st_[A-Za-z0-9]+`)},
+		}))
+		l.Logrus().Out = new(bytes.Buffer)
+
+		l.WithField("password", "hunter2").Info("Issued ory_st_abcd1234.")
+		require.Len(t, h.AllEntries(), 1)
+		assert.Equal(t, Redacted, h.LastEntry().Data["password"])
+		assert.Equal(t, "Issued "+Redacted+".", h.LastEntry().Message)
+	})
+
+	t.Run("case=does not modify shared fields", func(t *testing.T) {
+		l, b := newLogger()
+		fields := map[string]interface{}{"secret": "foo"}
+		l.WithField("nested", fields).Info("foo")
+		assert.Equal(t, map[string]interface{}{"secret": Redacted}, decode(t, b)["nested"])
+		assert.Equal(t, "foo", fields["secret"])
+	})
+}
@@ -0,0 +1,130 @@
+package logrusx
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// SamplingConfig configures which log entries are dropped to reduce the log volume of hot
+// code paths. Entries of the panic and fatal levels are never dropped.
+type SamplingConfig struct {
+	// MaxIdentical is the maximum number of entries with the same level and message which are
+	// logged per Period. Zero disables the limit. Once the limit is exceeded, identical entries
+	// are dropped until the period ends, and the next logged entry carries the number of dropped
+	// entries in the "sampling_dropped" field.
+	MaxIdentical int
+
+	// Period is the time window of MaxIdentical. Defaults to one second.
+	Period time.Duration
+
+	// Rates maps levels to the fraction of entries which are logged, for example
+	// {logrus.DebugLevel: 0.01} logs 1% of debug entries. Levels which are not present are
+	// logged in full.
+	Rates map[logrus.Level]float64
+}
+
+// WithSampling drops log entries according to the sampling configuration. Entries are dropped
+// before any hook fires, so hooks only receive the sampled entries.
+func WithSampling(c SamplingConfig) Option {
+	return func(o *options) {
+		o.sampling = &c
+	}
+}
+
+type sampler struct {
+	c      SamplingConfig
+	now    func() time.Time
+	random func() float64
+
+	sync.Mutex
+	windowStart time.Time
+	counters    map[samplingKey]*samplingCounter
+}
+
+type samplingKey struct {
+	level   logrus.Level
+	message string
+}
+
+type samplingCounter struct {
+	windowStart time.Time
+	logged      int
+	dropped     int
+}
+
+func newSampler(c SamplingConfig) *sampler {
+	if c.Period <= 0 {
+		c.Period = time.Second
+	}
+	return &sampler{
+		c:        c,
+		now:      time.Now,
+		random:   rand.Float64,
+		counters: map[samplingKey]*samplingCounter{},
+	}
+}
+
+// sample returns false if the entry is dropped. Otherwise it adds the number of identical
+// entries dropped before to the fields of the entry, which belong to the entry alone.
+func (f *sampler) sample(e *logrus.Entry) bool {
+	if e.Level <= logrus.FatalLevel {
+		return true
+	}
+
+	if rate, ok := f.c.Rates[e.Level]; ok && f.random() >= rate {
+		return false
+	}
+
+	if f.c.MaxIdentical <= 0 {
+		return true
+	}
+
+	dropped, ok := f.allow(samplingKey{level: e.Level, message: e.Message})
+	if !ok {
+		return false
+	}
+	if dropped > 0 {
+		e.Data["sampling_dropped"] = dropped
+	}
+	return true
+}
+
+// allow reports whether an entry with the given key may be logged, and how many identical
+// entries were dropped since the last one was logged.
+func (f *sampler) allow(key samplingKey) (int, bool) {
+	f.Lock()
+	defer f.Unlock()
+
+	now := f.now()
+	if now.Sub(f.windowStart) >= f.c.Period {
+		// Forget counters which were idle for a whole period.
+		for k, c := range f.counters {
+			if now.Sub(c.windowStart) >= 2*f.c.Period && c.dropped == 0 {
+				delete(f.counters, k)
+			}
+		}
+		f.windowStart = now
+	}
+
+	c, ok := f.counters[key]
+	if !ok {
+		c = &samplingCounter{windowStart: now}
+		f.counters[key] = c
+	} else if now.Sub(c.windowStart) >= f.c.Period {
+		c.windowStart = now
+		c.logged = 0
+	}
+
+	if c.logged >= f.c.MaxIdentical {
+		c.dropped++
+		return 0, false
+	}
+
+	c.logged++
+	dropped := c.dropped
+	c.dropped = 0
+	return dropped, true
+}
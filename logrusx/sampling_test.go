@@ -0,0 +1,90 @@
+package logrusx
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampling(t *testing.T) {
+	newLogger := func(c SamplingConfig) (*Logger, *sampler, *bytes.Buffer) {
+		var b bytes.Buffer
+		l := New("foo", "bar", ForceFormat("text"), ForceLevel(logrus.TraceLevel), WithSampling(c))
+		l.Logrus().Out = &b
+		return l, pipelineOf(l.Logrus()).sampler, &b
+	}
+
+	t.Run("case=limits identical messages", func(t *testing.T) {
+		l, f, b := newLogger(SamplingConfig{MaxIdentical: 2, Period: time.Second})
+		now := time.Now()
+		f.now = func() time.Time { return now }
+
+		for i := 0; i < 5; i++ {
+			l.Info("request handled")
+		}
+		l.Info("other message")
+		l.Warn("request handled")
+		assert.Equal(t, 3, strings.Count(b.String(), "request handled"), b.String())
+		assert.Contains(t, b.String(), "other message")
+		assert.Contains(t, b.String(), "level=warning")
+
+		b.Reset()
+		now = now.Add(time.Second)
+		l.Info("request handled")
+		assert.Contains(t, b.String(), "sampling_dropped=3")
+
+		b.Reset()
+		l.Info("request handled")
+		assert.Contains(t, b.String(), "request handled")
+		assert.NotContains(t, b.String(), "sampling_dropped")
+	})
+
+	t.Run("case=samples levels", func(t *testing.T) {
+		l, f, b := newLogger(SamplingConfig{Rates: map[logrus.Level]float64{logrus.DebugLevel: 0.25}})
+		values := []float64{0.1, 0.3, 0.5, 0.2}
+		f.random = func() float64 {
+			v := values[0]
+			values = values[1:]
+			return v
+		}
+
+		for i := 0; i < 4; i++ {
+			l.Debug("debug message")
+		}
+		l.Info("info message")
+		assert.Equal(t, 2, strings.Count(b.String(), "debug message"))
+		assert.Contains(t, b.String(), "info message")
+	})
+
+	t.Run("case=drops entries before hooks fire", func(t *testing.T) {
+		l, f, b := newLogger(SamplingConfig{MaxIdentical: 1})
+		now := time.Now()
+		f.now = func() time.Time { return now }
+		h := new(test.Hook)
+		l.AddHook(h)
+
+		for i := 0; i < 3; i++ {
+			l.Info("request handled")
+		}
+		assert.Len(t, h.AllEntries(), 1)
+
+		now = now.Add(time.Second)
+		l.Info("request handled")
+		require.Len(t, h.AllEntries(), 2)
+		assert.Equal(t, 2, h.LastEntry().Data["sampling_dropped"])
+		assert.Equal(t, 2, strings.Count(b.String(), "request handled"))
+	})
+
+	t.Run("case=keeps sampling after the config was reloaded", func(t *testing.T) {
+		l, f, _ := newLogger(SamplingConfig{MaxIdentical: 1})
+		l.UseConfig(new(nullConfigurator))
+		assert.Same(t, f, pipelineOf(l.Logrus()).sampler)
+		assert.IsType(t, new(pipelineFormatter), l.Logrus().Formatter)
+	})
+}
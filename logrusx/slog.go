@@ -0,0 +1,77 @@
+//go:build go1.21
+// +build go1.21
+
+package logrusx
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// WithSlogHandler sends all log entries to the given log/slog handler instead of formatting
+// them with logrus. The Logger API stays the same, but encoding and writing the entries is
+// done by the handler, for example slog.NewJSONHandler.
+//
+// The handler is installed as the logrus formatter, so it is not a separate backend: logrus
+// still creates every entry, and levels, component levels, sampling, redaction, and hooks apply
+// as before. The handler writes to its own output instead of the logger's output, so
+// WithHashChain and WithAsyncWriter, which wrap the logger's output, have no effect, and
+// replacing Logrus().Out does not redirect the entries.
+//
+// The handler's own level check applies in addition to the logrus level. This option replaces
+// ForceFormatter and ForceFormat.
+//
+// This option only exists when building with Go 1.21 or newer, which added log/slog. The rest of
+// the package supports the older Go versions of the module.
+func WithSlogHandler(h slog.Handler) Option {
+	return func(o *options) {
+		o.formatter = &slogFormatter{h: h}
+	}
+}
+
+type slogFormatter struct {
+	h slog.Handler
+}
+
+var slogLevels = map[logrus.Level]slog.Level{
+	logrus.PanicLevel: slog.LevelError + 8,
+	logrus.FatalLevel: slog.LevelError + 4,
+	logrus.ErrorLevel: slog.LevelError,
+	logrus.WarnLevel:  slog.LevelWarn,
+	logrus.InfoLevel:  slog.LevelInfo,
+	logrus.DebugLevel: slog.LevelDebug,
+	logrus.TraceLevel: slog.LevelDebug - 4,
+}
+
+// Format passes the entry to the slog handler and returns nil, so that logrus writes nothing.
+func (f *slogFormatter) Format(e *logrus.Entry) ([]byte, error) {
+	ctx := e.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	level := slogLevels[e.Level]
+	if !f.h.Enabled(ctx, level) {
+		return nil, nil
+	}
+
+	var pc uintptr
+	if e.Caller != nil {
+		pc = e.Caller.PC
+	}
+
+	r := slog.NewRecord(e.Time, level, e.Message, pc)
+	keys := make([]string, 0, len(e.Data))
+	for k := range e.Data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		r.AddAttrs(slog.Any(k, e.Data[k]))
+	}
+
+	return nil, f.h.Handle(ctx, r)
+}
@@ -0,0 +1,61 @@
+//go:build go1.21
+// +build go1.21
+
+package logrusx_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/logrusx"
+)
+
+func TestSlogHandler(t *testing.T) {
+	var b bytes.Buffer
+	l := New("logrusx-slog", "v0.0.0", ForceLevel(logrus.DebugLevel), WithSlogHandler(slog.NewJSONHandler(&b, &slog.HandlerOptions{Level: slog.LevelDebug})))
+
+	l.WithField("foo", "bar").WithError(errors.New("some error")).Warn("An error occurred.")
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(b.Bytes(), &entry), b.String())
+	assert.Equal(t, "WARN", entry["level"])
+	assert.Equal(t, "An error occurred.", entry["msg"])
+	assert.Equal(t, "bar", entry["foo"])
+	assert.Equal(t, "logrusx-slog", entry["service_name"])
+	assert.Equal(t, map[string]interface{}{"message": "some error"}, entry["error"])
+
+	b.Reset()
+	l.Trace("not enabled")
+	assert.Empty(t, b.String())
+}
+
+func BenchmarkBackends(b *testing.B) {
+	for _, tc := range []struct {
+		name string
+		opt  Option
+	}{
+		{name: "logrus_text", opt: ForceFormat("text")},
+		{name: "logrus_json", opt: ForceFormat("json")},
+		{name: "slog_json", opt: WithSlogHandler(slog.NewJSONHandler(io.Discard, nil))},
+		{name: "slog_text", opt: WithSlogHandler(slog.NewTextHandler(io.Discard, nil))},
+	} {
+		b.Run("backend="+tc.name, func(b *testing.B) {
+			l := New("logrusx-bench", "v0.0.0", ForceLevel(logrus.InfoLevel), tc.opt)
+			l.Logrus().Out = io.Discard
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				l.WithField("request_id", "id1234").WithField("status", 200).Info("request handled")
+			}
+		})
+	}
+}
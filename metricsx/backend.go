@@ -0,0 +1,217 @@
+package metricsx
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+
+	analytics "github.com/ory/analytics-go/v4"
+
+	"github.com/ory/x/tracing"
+)
+
+const (
+	// BackendSegment sends the events to Segment.
+	BackendSegment = "segment"
+	// BackendOTLP sends the events as OpenTelemetry logs to an OTLP/HTTP endpoint.
+	BackendOTLP = "otlp"
+	// BackendNone discards all events.
+	BackendNone = "none"
+)
+
+const (
+	// EventIdentify describes the instance. Its properties are the traits of the instance.
+	EventIdentify EventType = "identify"
+	// EventTrack records something that happened, for example memory statistics.
+	EventTrack EventType = "track"
+	// EventPage records a handled request.
+	EventPage EventType = "page"
+)
+
+type (
+	// EventType is the type of a telemetry event.
+	EventType string
+
+	// Event is an anonymized telemetry event.
+	Event struct {
+		Type       EventType              `json:"type"`
+		Name       string                 `json:"name,omitempty"`
+		UserID     string                 `json:"user_id"`
+		Properties map[string]interface{} `json:"properties,omitempty"`
+		Timestamp  time.Time              `json:"timestamp"`
+	}
+
+	// Backend sends telemetry events to an analytics sink.
+	Backend interface {
+		// Send delivers a batch of events. If it returns an error, the batch is retried later.
+		Send(ctx context.Context, events []Event) error
+
+		// Close releases the resources of the backend.
+		Close() error
+	}
+
+	noopBackend struct{}
+
+	segmentBackend struct {
+		writeKey string
+		config   analytics.Config
+		context  *analytics.Context
+	}
+
+	// segmentCallback records the first error of the messages sent by a Segment client.
+	segmentCallback struct {
+		next analytics.Callback
+
+		sync.Mutex
+		err error
+	}
+
+	otlpBackend struct {
+		exporter *tracing.LogExporter
+	}
+)
+
+// NewNoopBackend returns a backend which discards all events.
+func NewNoopBackend() Backend {
+	return noopBackend{}
+}
+
+func (noopBackend) Send(context.Context, []Event) error { return nil }
+
+func (noopBackend) Close() error { return nil }
+
+// NewSegmentBackend returns a backend which sends the events to Segment. The analytics context
+// is attached to every event.
+//
+// Every batch is sent with its own Segment client, which is closed once the batch was sent,
+// so that Send reports whether the events were delivered. The client does not retry failed
+// requests, the Buffer does.
+func NewSegmentBackend(writeKey string, config analytics.Config, ac *analytics.Context) (Backend, error) {
+	// validates the configuration
+	c, err := analytics.NewWithConfig(writeKey, config)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	_ = c.Close()
+	return &segmentBackend{writeKey: writeKey, config: config, context: ac}, nil
+}
+
+func (b *segmentBackend) Send(ctx context.Context, events []Event) error {
+	callback := &segmentCallback{next: b.config.Callback}
+	config := b.config
+	config.Callback = callback
+	c, err := analytics.NewWithConfig(b.writeKey, config)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	for _, e := range events {
+		var m analytics.Message
+		switch e.Type {
+		case EventIdentify:
+			m = analytics.Identify{UserId: e.UserID, Traits: e.Properties, Context: b.context, Timestamp: e.Timestamp}
+		case EventTrack:
+			m = analytics.Track{UserId: e.UserID, Event: e.Name, Properties: e.Properties, Context: b.context, Timestamp: e.Timestamp}
+		case EventPage:
+			m = analytics.Page{UserId: e.UserID, Name: e.Name, Properties: e.Properties, Context: b.context, Timestamp: e.Timestamp}
+		default:
+			continue
+		}
+		if err := c.Enqueue(m); err != nil {
+			_ = c.Close()
+			return errors.WithStack(err)
+		}
+	}
+
+	// Closing the client sends the queued messages and waits until they were sent. Once the
+	// client is closed it no longer retries, so failed messages are reported right away.
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		_ = c.Close()
+	}()
+	select {
+	case <-closed:
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+	return callback.error()
+}
+
+func (b *segmentBackend) Close() error {
+	return nil
+}
+
+func (c *segmentCallback) Success(m analytics.Message) {
+	if c.next != nil {
+		c.next.Success(m)
+	}
+}
+
+func (c *segmentCallback) Failure(m analytics.Message, err error) {
+	c.Lock()
+	if c.err == nil {
+		c.err = errors.Wrap(err, "unable to send the events to Segment")
+	}
+	c.Unlock()
+	if c.next != nil {
+		c.next.Failure(m, err)
+	}
+}
+
+func (c *segmentCallback) error() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+// NewOTLPBackend returns a backend which sends the events as OpenTelemetry log records to the
+// collector configured by c. The attributes describe the resource, for example
+// "service.name".
+func NewOTLPBackend(c *tracing.OTLPConfig, attributes map[string]string) (Backend, error) {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]attribute.KeyValue, len(keys))
+	for i, k := range keys {
+		attrs[i] = attribute.String(k, attributes[k])
+	}
+
+	exporter, err := tracing.NewLogExporter(c, resource.NewSchemaless(attrs...), "github.com/ory/x/metricsx")
+	if err != nil {
+		return nil, err
+	}
+	return &otlpBackend{exporter: exporter}, nil
+}
+
+func (b *otlpBackend) Send(ctx context.Context, events []Event) error {
+	records := make([]tracing.LogRecord, len(events))
+	for k, e := range events {
+		fields := make(map[string]interface{}, len(e.Properties)+2)
+		for n, v := range e.Properties {
+			fields[n] = v
+		}
+		fields["event.type"] = string(e.Type)
+		fields["user.id"] = e.UserID
+		records[k] = tracing.LogRecord{
+			Time:    e.Timestamp,
+			Level:   logrus.InfoLevel,
+			Name:    string(e.Type),
+			Message: e.Name,
+			Fields:  fields,
+		}
+	}
+	return b.exporter.Export(ctx, records)
+}
+
+func (b *otlpBackend) Close() error {
+	return nil
+}
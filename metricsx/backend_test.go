@@ -0,0 +1,117 @@
+package metricsx
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	collogs "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	common "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+
+	analytics "github.com/ory/analytics-go/v4"
+
+	"github.com/ory/x/tracing"
+)
+
+func defaultTestContext() *analytics.Context {
+	return &analytics.Context{Traits: analytics.NewTraits()}
+}
+
+func TestOTLPBackend(t *testing.T) {
+	var received collogs.ExportLogsServiceRequest
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.NoError(t, proto.Unmarshal(body, &received))
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(ts.Close)
+
+	b, err := NewOTLPBackend(&tracing.OTLPConfig{ServerURL: ts.URL + "/v1/logs"}, map[string]string{"service.name": "test"})
+	require.NoError(t, err)
+	now := time.Now()
+	require.NoError(t, b.Send(context.Background(), []Event{{
+		Type:       EventTrack,
+		Name:       "memstats",
+		UserID:     "cluster",
+		Properties: map[string]interface{}{"alloc": uint64(10), "nonInteraction": 1},
+		Timestamp:  now,
+	}}))
+
+	require.Len(t, received.ResourceLogs, 1)
+	rl := received.ResourceLogs[0]
+	assert.Equal(t, "service.name", rl.Resource.Attributes[0].Key)
+	assert.Equal(t, "test", rl.Resource.Attributes[0].Value.GetStringValue())
+
+	records := rl.InstrumentationLibraryLogs[0].Logs
+	require.Len(t, records, 1)
+	assert.Equal(t, uint64(now.UnixNano()), records[0].TimeUnixNano)
+	assert.Equal(t, "memstats", records[0].Body.GetStringValue())
+
+	attributes := map[string]interface{}{}
+	for _, kv := range records[0].Attributes {
+		switch v := kv.Value.Value.(type) {
+		case *common.AnyValue_StringValue:
+			attributes[kv.Key] = v.StringValue
+		case *common.AnyValue_IntValue:
+			attributes[kv.Key] = v.IntValue
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"event.type":     "track",
+		"user.id":        "cluster",
+		"alloc":          int64(10),
+		"nonInteraction": int64(1),
+	}, attributes)
+
+	status = http.StatusServiceUnavailable
+	assert.Error(t, b.Send(context.Background(), []Event{{Type: EventTrack}}))
+}
+
+func TestSegmentBackend(t *testing.T) {
+	var received int32
+	status := int32(http.StatusOK)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var batch struct {
+			Messages []json.RawMessage `json:"batch"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&batch))
+		atomic.AddInt32(&received, int32(len(batch.Messages)))
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	t.Cleanup(ts.Close)
+
+	b, err := NewSegmentBackend("key", analytics.Config{
+		Endpoint: ts.URL,
+		Logger:   analytics.StdLogger(log.New(ioutil.Discard, "", 0)),
+	}, defaultTestContext())
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = b.Close() })
+
+	events := []Event{
+		{Type: EventIdentify, UserID: "cluster"},
+		{Type: EventTrack, Name: "memstats", UserID: "cluster"},
+	}
+	require.NoError(t, b.Send(context.Background(), events))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&received), "the events were sent when Send returns")
+
+	atomic.StoreInt32(&status, http.StatusServiceUnavailable)
+	assert.Error(t, b.Send(context.Background(), events), "failures are reported to the buffer")
+}
+
+func TestNoopBackend(t *testing.T) {
+	b := NewNoopBackend()
+	assert.NoError(t, b.Send(context.Background(), []Event{{Type: EventTrack}}))
+	assert.NoError(t, b.Close())
+}
@@ -0,0 +1,405 @@
+package metricsx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pborman/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/logrusx"
+)
+
+const spillPrefix = "metricsx-"
+
+type (
+	// Buffer collects telemetry events in memory and sends them to the backend in batches. If the
+	// backend is unavailable, or too many events are buffered, the events are spilled to disk,
+	// if a spill directory is configured, and sent once the backend is available again.
+	//
+	// Enqueue never does I/O. Events are written to disk in the background, and the oldest
+	// events are dropped if the spill directory grows beyond its limit or events are enqueued
+	// faster than they can be written.
+	Buffer struct {
+		b Backend
+		l *logrusx.Logger
+
+		batchSize    int
+		maxBuffered  int
+		interval     time.Duration
+		spillDir     string
+		maxSpillSize int64
+
+		mu       sync.Mutex
+		events   []Event
+		spilling []Event
+		// flush serializes sending and all access to the spill directory.
+		flush sync.Mutex
+
+		trigger chan struct{}
+		spill   chan struct{}
+		stop    chan struct{}
+		stopped chan struct{}
+		once    sync.Once
+	}
+
+	// BufferOption configures a Buffer.
+	BufferOption func(*Buffer)
+)
+
+// WithBatchSize sets how many events are sent at once. Defaults to 100.
+func WithBatchSize(n int) BufferOption {
+	return func(b *Buffer) {
+		b.batchSize = n
+	}
+}
+
+// WithMaxBuffered sets how many events are kept in memory before they are spilled to disk, or,
+// without a spill directory, the oldest events are dropped. Defaults to 1000. While events are
+// written to disk, up to twice as many events are kept in memory.
+func WithMaxBuffered(n int) BufferOption {
+	return func(b *Buffer) {
+		b.maxBuffered = n
+	}
+}
+
+// WithFlushInterval sets how often buffered events are sent. Defaults to one minute.
+func WithFlushInterval(d time.Duration) BufferOption {
+	return func(b *Buffer) {
+		b.interval = d
+	}
+}
+
+// WithSpillDirectory sets the directory where events are stored while they can not be sent.
+func WithSpillDirectory(dir string) BufferOption {
+	return func(b *Buffer) {
+		b.spillDir = dir
+	}
+}
+
+// WithMaxSpillSize sets how many bytes the spilled events may use on disk. If the limit is
+// exceeded, the oldest spilled events are dropped. Defaults to 16 MiB.
+func WithMaxSpillSize(bytes int64) BufferOption {
+	return func(b *Buffer) {
+		b.maxSpillSize = bytes
+	}
+}
+
+// NewBuffer returns a buffer which sends the events to the backend in the background until it
+// is closed.
+func NewBuffer(backend Backend, l *logrusx.Logger, opts ...BufferOption) *Buffer {
+	b := &Buffer{
+		b:            backend,
+		l:            l,
+		batchSize:    100,
+		maxBuffered:  1000,
+		interval:     time.Minute,
+		maxSpillSize: 16 << 20,
+		trigger:      make(chan struct{}, 1),
+		spill:        make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		stopped:      make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(b)
+	}
+	go b.loop()
+	return b
+}
+
+func (b *Buffer) loop() {
+	defer close(b.stopped)
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-b.spill:
+			b.writePending()
+			continue
+		case <-ticker.C:
+		case <-b.trigger:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), b.interval)
+		if err := b.Flush(ctx); err != nil {
+			b.l.WithError(err).Debug("Could not commit anonymized telemetry data")
+		}
+		cancel()
+	}
+}
+
+// Enqueue buffers the event. It never blocks on the backend or the disk.
+func (b *Buffer) Enqueue(e Event) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now().UTC()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.events = append(b.events, e)
+	if len(b.events) > b.maxBuffered {
+		if b.spillDir != "" && b.spilling == nil {
+			// The events are written to disk in the background.
+			b.spilling, b.events = b.events, nil
+			select {
+			case b.spill <- struct{}{}:
+			default:
+			}
+		} else {
+			b.events = append([]Event(nil), b.events[len(b.events)-b.maxBuffered:]...)
+		}
+	}
+	if len(b.events) >= b.batchSize {
+		select {
+		case b.trigger <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// writePending writes the events handed over by Enqueue to disk.
+func (b *Buffer) writePending() {
+	b.flush.Lock()
+	defer b.flush.Unlock()
+
+	b.mu.Lock()
+	events := b.spilling
+	b.spilling = nil
+	b.mu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	if err := b.writeSpill(events); err != nil {
+		b.l.WithError(err).Debug("Unable to spill telemetry events to disk.")
+	}
+}
+
+// writeSpill writes the events to a new file in the spill directory and drops the oldest
+// spilled events if the directory exceeds its limit. b.flush must be held.
+func (b *Buffer) writeSpill(events []Event) error {
+	if err := os.MkdirAll(b.spillDir, 0700); err != nil {
+		return errors.WithStack(err)
+	}
+
+	name := filepath.Join(b.spillDir, fmt.Sprintf("%s%020d-%s.jsonl", spillPrefix, time.Now().UnixNano(), uuid.New()))
+	if err := writeSpillFile(name, events); err != nil {
+		return err
+	}
+	return b.pruneSpill()
+}
+
+// writeSpillFile atomically replaces the file with the events, so that a partially written
+// file is never read.
+func writeSpillFile(name string, events []Event) error {
+	f, err := os.CreateTemp(filepath.Dir(name), ".spill-*")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range events {
+		if err := enc.Encode(e); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := f.Close(); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(os.Rename(f.Name(), name))
+}
+
+// pruneSpill removes the oldest spilled files until the spilled events fit into the limit.
+// b.flush must be held.
+func (b *Buffer) pruneSpill() error {
+	files, err := b.spilled()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(files))
+	var total int64
+	for k, name := range files {
+		fi, err := os.Stat(name)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		sizes[k] = fi.Size()
+		total += sizes[k]
+	}
+
+	for k := 0; k < len(files) && total > b.maxSpillSize; k++ {
+		if err := os.Remove(files[k]); err != nil {
+			return errors.WithStack(err)
+		}
+		total -= sizes[k]
+		b.l.WithField("file", files[k]).Debug("Dropped spilled telemetry events because the spill directory exceeds its limit.")
+	}
+	return nil
+}
+
+func (b *Buffer) spilled() ([]string, error) {
+	if b.spillDir == "" {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(b.spillDir, spillPrefix+"*.jsonl"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func readSpill(name string) ([]Event, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer f.Close()
+
+	var events []Event
+	s := bufio.NewScanner(f)
+	s.Buffer(make([]byte, 64*1024), 1024*1024)
+	for s.Scan() {
+		if strings.TrimSpace(s.Text()) == "" {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			// A corrupted line is skipped.
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, errors.WithStack(s.Err())
+}
+
+// send sends the events in batches and returns how many events were sent.
+func (b *Buffer) send(ctx context.Context, events []Event) (int, error) {
+	sent := 0
+	for sent < len(events) {
+		n := b.batchSize
+		if n <= 0 || n > len(events)-sent {
+			n = len(events) - sent
+		}
+		if err := b.b.Send(ctx, events[sent:sent+n]); err != nil {
+			return sent, err
+		}
+		sent += n
+	}
+	return sent, nil
+}
+
+// Flush sends the spilled and the buffered events. Events which could not be sent are kept
+// for the next attempt, and events which were sent are never sent again.
+func (b *Buffer) Flush(ctx context.Context) error {
+	b.flush.Lock()
+	defer b.flush.Unlock()
+
+	files, err := b.spilled()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		var events []Event
+		if events, err = readSpill(name); err != nil {
+			break
+		}
+		var sent int
+		if sent, err = b.send(ctx, events); err != nil {
+			// Only the events which were not sent are kept.
+			if sent > 0 {
+				if werr := writeSpillFile(name, events[sent:]); werr != nil {
+					b.l.WithError(werr).Debug("Unable to spill telemetry events to disk.")
+				}
+			}
+			break
+		}
+		if err = errors.WithStack(os.Remove(name)); err != nil {
+			break
+		}
+	}
+
+	b.mu.Lock()
+	events := append(b.spilling, b.events...)
+	b.spilling, b.events = nil, nil
+	b.mu.Unlock()
+
+	// The buffered events are sent only after all spilled events, which are older.
+	if err == nil {
+		var sent int
+		if sent, err = b.send(ctx, events); err == nil {
+			return nil
+		}
+		events = events[sent:]
+	}
+	if len(events) == 0 {
+		return err
+	}
+
+	if b.spillDir != "" {
+		werr := b.writeSpill(events)
+		if werr == nil {
+			return err
+		}
+		b.l.WithError(werr).Debug("Unable to spill telemetry events to disk.")
+	}
+
+	b.mu.Lock()
+	b.events = append(events, b.events...)
+	if drop := len(b.events) - b.maxBuffered; drop > 0 {
+		b.events = append([]Event(nil), b.events[drop:]...)
+	}
+	b.mu.Unlock()
+	return err
+}
+
+// Discard drops all buffered and spilled events, for example because the user opted out.
+func (b *Buffer) Discard() {
+	b.flush.Lock()
+	defer b.flush.Unlock()
+
+	b.mu.Lock()
+	b.events, b.spilling = nil, nil
+	b.mu.Unlock()
+
+	files, _ := b.spilled()
+	for _, name := range files {
+		_ = os.Remove(name)
+	}
+}
+
+// Close stops the background flushing, sends the remaining events in one last attempt within
+// the deadline of the context, and closes the backend. Events which could not be sent are
+// spilled to disk if a spill directory is configured.
+func (b *Buffer) Close(ctx context.Context) error {
+	var err error
+	b.once.Do(func() {
+		close(b.stop)
+		<-b.stopped
+
+		err = b.Flush(ctx)
+		if cerr := b.b.Close(); err == nil {
+			err = errors.WithStack(cerr)
+		}
+	})
+	return err
+}
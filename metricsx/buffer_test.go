@@ -0,0 +1,235 @@
+package metricsx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+)
+
+type recordingBackend struct {
+	sync.Mutex
+	failing bool
+	// failAfter makes the backend fail after that many batches were sent, if positive.
+	failAfter int
+	batches   [][]Event
+	closed    bool
+}
+
+func (b *recordingBackend) Send(_ context.Context, events []Event) error {
+	b.Lock()
+	defer b.Unlock()
+	if b.failing || (b.failAfter > 0 && len(b.batches) >= b.failAfter) {
+		return errors.New("backend is unavailable")
+	}
+	b.batches = append(b.batches, append([]Event(nil), events...))
+	return nil
+}
+
+func (b *recordingBackend) Close() error {
+	b.Lock()
+	defer b.Unlock()
+	b.closed = true
+	return nil
+}
+
+func (b *recordingBackend) setFailing(failing bool) {
+	b.Lock()
+	defer b.Unlock()
+	b.failing = failing
+	b.failAfter = 0
+}
+
+func (b *recordingBackend) names() (names []string) {
+	b.Lock()
+	defer b.Unlock()
+	for _, batch := range b.batches {
+		for _, e := range batch {
+			names = append(names, e.Name)
+		}
+	}
+	return names
+}
+
+func TestBuffer(t *testing.T) {
+	l := logrusx.New("", "")
+	ctx := context.Background()
+
+	t.Run("case=sends in batches", func(t *testing.T) {
+		backend := new(recordingBackend)
+		buf := NewBuffer(backend, l, WithBatchSize(2), WithFlushInterval(time.Hour))
+		for _, n := range []string{"a", "b", "c"} {
+			buf.Enqueue(Event{Type: EventTrack, Name: n})
+		}
+		require.NoError(t, buf.Close(ctx))
+
+		assert.Equal(t, []string{"a", "b", "c"}, backend.names())
+		assert.Len(t, backend.batches, 2)
+		assert.True(t, backend.closed)
+		assert.False(t, backend.batches[0][0].Timestamp.IsZero())
+	})
+
+	t.Run("case=flushes once the batch is full", func(t *testing.T) {
+		backend := new(recordingBackend)
+		buf := NewBuffer(backend, l, WithBatchSize(2), WithFlushInterval(time.Hour))
+		t.Cleanup(func() { _ = buf.Close(ctx) })
+
+		buf.Enqueue(Event{Type: EventTrack, Name: "a"})
+		buf.Enqueue(Event{Type: EventTrack, Name: "b"})
+		assert.Eventually(t, func() bool { return len(backend.names()) == 2 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("case=keeps events while the backend is unavailable", func(t *testing.T) {
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithMaxBuffered(2), WithFlushInterval(time.Hour))
+		for _, n := range []string{"a", "b", "c"} {
+			buf.Enqueue(Event{Type: EventTrack, Name: n})
+		}
+		require.Error(t, buf.Flush(ctx))
+
+		backend.setFailing(false)
+		require.NoError(t, buf.Close(ctx))
+		assert.Equal(t, []string{"b", "c"}, backend.names(), "the oldest event is dropped without a spill directory")
+	})
+
+	t.Run("case=spills to disk", func(t *testing.T) {
+		dir := t.TempDir()
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithMaxBuffered(2), WithSpillDirectory(dir), WithFlushInterval(time.Hour))
+		for _, n := range []string{"a", "b", "c", "d"} {
+			buf.Enqueue(Event{Type: EventTrack, Name: n})
+		}
+		require.Error(t, buf.Close(ctx))
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+		require.NoError(t, err)
+		assert.NotEmpty(t, files)
+
+		// The next process sends the events spilled by the previous one.
+		backend = new(recordingBackend)
+		buf = NewBuffer(backend, l, WithSpillDirectory(dir), WithFlushInterval(time.Hour))
+		require.NoError(t, buf.Close(ctx))
+		assert.Equal(t, []string{"a", "b", "c", "d"}, backend.names())
+
+		files, err = filepath.Glob(filepath.Join(dir, "*.jsonl"))
+		require.NoError(t, err)
+		assert.Empty(t, files)
+	})
+
+	t.Run("case=does not send spilled batches twice", func(t *testing.T) {
+		dir := t.TempDir()
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithSpillDirectory(dir), WithBatchSize(2), WithFlushInterval(time.Hour))
+		for _, n := range []string{"a", "b", "c", "d", "e"} {
+			buf.Enqueue(Event{Type: EventTrack, Name: n})
+		}
+		require.Error(t, buf.Flush(ctx))
+
+		backend = &recordingBackend{failAfter: 1}
+		buf = NewBuffer(backend, l, WithSpillDirectory(dir), WithBatchSize(2), WithFlushInterval(time.Hour))
+		buf.Enqueue(Event{Type: EventTrack, Name: "f"})
+		require.Error(t, buf.Flush(ctx))
+		assert.Equal(t, []string{"a", "b"}, backend.names())
+
+		backend.setFailing(false)
+		require.NoError(t, buf.Close(ctx))
+		assert.Equal(t, []string{"a", "b", "c", "d", "e", "f"}, backend.names())
+	})
+
+	t.Run("case=limits the spill directory", func(t *testing.T) {
+		dir := t.TempDir()
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithSpillDirectory(dir), WithMaxSpillSize(512), WithFlushInterval(time.Hour))
+		for k := 0; k < 20; k++ {
+			buf.Enqueue(Event{Type: EventTrack, Name: strconv.Itoa(k)})
+			require.Error(t, buf.Flush(ctx))
+		}
+
+		files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+		require.NoError(t, err)
+		var size int64
+		for _, name := range files {
+			fi, err := os.Stat(name)
+			require.NoError(t, err)
+			size += fi.Size()
+		}
+		assert.LessOrEqual(t, size, int64(512))
+		assert.NotEmpty(t, files)
+
+		backend.setFailing(false)
+		require.NoError(t, buf.Close(ctx))
+		names := backend.names()
+		assert.Less(t, len(names), 20)
+		assert.Equal(t, "19", names[len(names)-1], "the newest events are kept")
+	})
+
+	t.Run("case=spills in the background", func(t *testing.T) {
+		dir := t.TempDir()
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithMaxBuffered(2), WithSpillDirectory(dir), WithFlushInterval(time.Hour))
+		t.Cleanup(func() { _ = buf.Close(ctx) })
+		for _, n := range []string{"a", "b", "c"} {
+			buf.Enqueue(Event{Type: EventTrack, Name: n})
+		}
+		assert.Eventually(t, func() bool {
+			files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+			return len(files) == 1
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("case=discards buffered and spilled events", func(t *testing.T) {
+		dir := t.TempDir()
+		backend := &recordingBackend{failing: true}
+		buf := NewBuffer(backend, l, WithSpillDirectory(dir), WithFlushInterval(time.Hour))
+		buf.Enqueue(Event{Type: EventTrack, Name: "a"})
+		require.Error(t, buf.Flush(ctx))
+		buf.Enqueue(Event{Type: EventTrack, Name: "b"})
+
+		buf.Discard()
+		backend.setFailing(false)
+		require.NoError(t, buf.Close(ctx))
+		assert.Empty(t, backend.names())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestServiceConsent(t *testing.T) {
+	backend := new(recordingBackend)
+	sw := &Service{
+		o:       &Options{ClusterID: "cluster"},
+		buf:     NewBuffer(backend, logrusx.New("", ""), WithFlushInterval(time.Hour)),
+		context: defaultTestContext(),
+		mem:     new(MemoryStatistics),
+	}
+
+	sw.Identify()
+	sw.OptOut()
+	assert.True(t, sw.OptedOut())
+	sw.enqueue(Event{Type: EventTrack, Name: "dropped"})
+
+	sw.OptIn()
+	assert.False(t, sw.OptedOut())
+	sw.enqueue(Event{Type: EventTrack, Name: "sent"})
+
+	require.NoError(t, sw.Shutdown(context.Background()))
+	names := backend.names()
+	assert.Contains(t, names, "sent")
+	assert.NotContains(t, names, "dropped")
+	for _, batch := range backend.batches {
+		for _, e := range batch {
+			assert.Equal(t, "cluster", e.UserID)
+		}
+	}
+}
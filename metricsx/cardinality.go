@@ -0,0 +1,284 @@
+package metricsx
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/ory/x/stringslice"
+)
+
+const (
+	// OverflowLabelValue replaces the label values of aggregated series which exceed the
+	// cardinality limit of their metric.
+	OverflowLabelValue = "other"
+
+	// LimitedSeriesMetric is the gauge reporting how many series were dropped or aggregated
+	// per metric during the last scrape.
+	LimitedSeriesMetric = "metricsx_cardinality_limited_series"
+)
+
+type (
+	// CardinalityLimits restrict the series of a metric.
+	CardinalityLimits struct {
+		// MaxSeries is the maximum number of distinct label sets. Series with new label sets
+		// beyond the limit are dropped, or aggregated if Aggregate is true. Zero means unlimited.
+		MaxSeries int
+
+		// AllowedLabels, if not nil, is the allowlist of label keys. All other labels are
+		// removed, and series which then have the same labels are aggregated.
+		AllowedLabels []string
+
+		// Aggregate series beyond MaxSeries into one series whose label values are
+		// OverflowLabelValue instead of dropping them.
+		Aggregate bool
+	}
+
+	// CardinalityGuard is a prometheus.Gatherer which enforces CardinalityLimits on the
+	// metrics of another gatherer, to protect Prometheus from unbounded label values.
+	//
+	// The first MaxSeries label sets seen for a metric are kept for the lifetime of the guard,
+	// so that series do not flap between scrapes. The guard does not bound the memory used by
+	// the metrics of the gatherer, see SeriesLimiter for that. Series whose label values are all
+	// OverflowLabelValue are always kept. Counters, gauges, and histograms are
+	// aggregated by adding their values. Summaries lose their quantiles when aggregated.
+	CardinalityGuard struct {
+		g         prometheus.Gatherer
+		defaults  CardinalityLimits
+		perMetric map[string]CardinalityLimits
+
+		sync.Mutex
+		seen    map[string]map[string]struct{}
+		limited map[string]int
+	}
+
+	// CardinalityOption configures a CardinalityGuard.
+	CardinalityOption func(*CardinalityGuard)
+)
+
+var _ prometheus.Gatherer = (*CardinalityGuard)(nil)
+
+// WithMetricLimits overrides the default limits for the metric with the given name.
+func WithMetricLimits(name string, limits CardinalityLimits) CardinalityOption {
+	return func(g *CardinalityGuard) {
+		g.perMetric[name] = limits
+	}
+}
+
+// NewCardinalityGuard wraps the gatherer and applies the default limits to all metrics.
+func NewCardinalityGuard(g prometheus.Gatherer, defaults CardinalityLimits, opts ...CardinalityOption) *CardinalityGuard {
+	guard := &CardinalityGuard{
+		g:         g,
+		defaults:  defaults,
+		perMetric: make(map[string]CardinalityLimits),
+		seen:      make(map[string]map[string]struct{}),
+		limited:   make(map[string]int),
+	}
+	for _, o := range opts {
+		o(guard)
+	}
+	return guard
+}
+
+// WithCardinalityLimits applies the limits to the metrics exposed by Prometheus.Handler. The
+// MaxSeries limits of the request metrics are enforced when requests are recorded, so that they
+// do not use unbounded memory. Other metrics of the registry are limited only when they are
+// gathered; create them with NewLimitedCounterVec, NewLimitedGaugeVec, or
+// NewLimitedHistogramVec to limit them when they are recorded as well.
+func WithCardinalityLimits(defaults CardinalityLimits, opts ...CardinalityOption) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.guard = &cardinalityOptions{defaults: defaults, opts: opts}
+	}
+}
+
+type cardinalityOptions struct {
+	defaults CardinalityLimits
+	opts     []CardinalityOption
+}
+
+// Limited returns how many series were dropped or aggregated per metric during the last
+// Gather.
+func (g *CardinalityGuard) Limited() map[string]int {
+	g.Lock()
+	defer g.Unlock()
+
+	limited := make(map[string]int, len(g.limited))
+	for name, n := range g.limited {
+		limited[name] = n
+	}
+	return limited
+}
+
+// Gather implements prometheus.Gatherer.
+func (g *CardinalityGuard) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.g.Gather()
+
+	g.Lock()
+	defer g.Unlock()
+
+	g.limited = make(map[string]int)
+	for _, mf := range families {
+		g.limit(mf)
+	}
+
+	if report := g.report(); report != nil {
+		families = append(families, report)
+	}
+	return families, err
+}
+
+func (g *CardinalityGuard) limitsFor(name string) CardinalityLimits {
+	if l, ok := g.perMetric[name]; ok {
+		return l
+	}
+	return g.defaults
+}
+
+func (g *CardinalityGuard) admit(name, signature string, max int) bool {
+	seen, ok := g.seen[name]
+	if !ok {
+		seen = make(map[string]struct{})
+		g.seen[name] = seen
+	}
+	if _, ok := seen[signature]; ok {
+		return true
+	}
+	if len(seen) >= max {
+		return false
+	}
+	seen[signature] = struct{}{}
+	return true
+}
+
+func (g *CardinalityGuard) limit(mf *dto.MetricFamily) {
+	name := mf.GetName()
+	limits := g.limitsFor(name)
+	if limits.MaxSeries <= 0 && limits.AllowedLabels == nil {
+		return
+	}
+
+	metrics := make([]*dto.Metric, 0, len(mf.Metric))
+	index := make(map[string]*dto.Metric, len(mf.Metric))
+	for _, m := range mf.Metric {
+		limited := false
+		if limits.AllowedLabels != nil {
+			allowed := m.Label[:0]
+			for _, l := range m.Label {
+				if stringslice.Has(limits.AllowedLabels, l.GetName()) {
+					allowed = append(allowed, l)
+				}
+			}
+			limited = len(allowed) != len(m.Label)
+			m.Label = allowed
+		}
+
+		signature := labelSignature(m.Label)
+		if limits.MaxSeries > 0 && !isOverflow(m.Label) && !g.admit(name, signature, limits.MaxSeries) {
+			limited = true
+			if !limits.Aggregate {
+				g.limited[name]++
+				continue
+			}
+			overflow := make([]*dto.LabelPair, len(m.Label))
+			for k, l := range m.Label {
+				overflow[k] = &dto.LabelPair{Name: l.Name, Value: stringPtr(OverflowLabelValue)}
+			}
+			m.Label = overflow
+			signature = labelSignature(m.Label)
+		}
+		if limited {
+			g.limited[name]++
+		}
+
+		if existing, ok := index[signature]; ok {
+			mergeMetric(existing, m)
+			continue
+		}
+		index[signature] = m
+		metrics = append(metrics, m)
+	}
+	mf.Metric = metrics
+}
+
+func (g *CardinalityGuard) report() *dto.MetricFamily {
+	if len(g.limited) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(g.limited))
+	for name := range g.limited {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mf := &dto.MetricFamily{
+		Name: stringPtr(LimitedSeriesMetric),
+		Help: stringPtr("The number of series which were dropped or aggregated because they exceeded the cardinality limits, per metric."),
+		Type: dto.MetricType_GAUGE.Enum(),
+	}
+	for _, name := range names {
+		value := float64(g.limited[name])
+		mf.Metric = append(mf.Metric, &dto.Metric{
+			Label: []*dto.LabelPair{{Name: stringPtr("metric"), Value: stringPtr(name)}},
+			Gauge: &dto.Gauge{Value: &value},
+		})
+	}
+	return mf
+}
+
+// isOverflow returns true for the series which aggregates the series beyond the limit, see
+// SeriesLimiter.
+func isOverflow(labels []*dto.LabelPair) bool {
+	for _, l := range labels {
+		if l.GetValue() != OverflowLabelValue {
+			return false
+		}
+	}
+	return len(labels) > 0
+}
+
+func labelSignature(labels []*dto.LabelPair) string {
+	pairs := make([]string, len(labels))
+	for k, l := range labels {
+		pairs[k] = l.GetName() + "\xff" + l.GetValue()
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\xfe")
+}
+
+func mergeMetric(dst, src *dto.Metric) {
+	switch {
+	case dst.Counter != nil && src.Counter != nil:
+		dst.Counter.Value = float64Ptr(dst.Counter.GetValue() + src.Counter.GetValue())
+	case dst.Gauge != nil && src.Gauge != nil:
+		dst.Gauge.Value = float64Ptr(dst.Gauge.GetValue() + src.Gauge.GetValue())
+	case dst.Untyped != nil && src.Untyped != nil:
+		dst.Untyped.Value = float64Ptr(dst.Untyped.GetValue() + src.Untyped.GetValue())
+	case dst.Histogram != nil && src.Histogram != nil:
+		dst.Histogram.SampleCount = uint64Ptr(dst.Histogram.GetSampleCount() + src.Histogram.GetSampleCount())
+		dst.Histogram.SampleSum = float64Ptr(dst.Histogram.GetSampleSum() + src.Histogram.GetSampleSum())
+		for _, sb := range src.Histogram.Bucket {
+			for _, db := range dst.Histogram.Bucket {
+				if db.GetUpperBound() == sb.GetUpperBound() {
+					db.CumulativeCount = uint64Ptr(db.GetCumulativeCount() + sb.GetCumulativeCount())
+					db.Exemplar = nil
+				}
+			}
+		}
+	case dst.Summary != nil && src.Summary != nil:
+		dst.Summary.SampleCount = uint64Ptr(dst.Summary.GetSampleCount() + src.Summary.GetSampleCount())
+		dst.Summary.SampleSum = float64Ptr(dst.Summary.GetSampleSum() + src.Summary.GetSampleSum())
+		// Quantiles can not be aggregated.
+		dst.Summary.Quantile = nil
+	}
+	dst.TimestampMs = nil
+}
+
+func stringPtr(s string) *string { return &s }
+
+func float64Ptr(f float64) *float64 { return &f }
+
+func uint64Ptr(u uint64) *uint64 { return &u }
@@ -0,0 +1,126 @@
+package metricsx
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func gatherSeries(t *testing.T, g prometheus.Gatherer, name string) map[string]*dto.Metric {
+	families, err := g.Gather()
+	require.NoError(t, err)
+
+	series := map[string]*dto.Metric{}
+	for _, mf := range families {
+		if mf.GetName() != name {
+			continue
+		}
+		for _, m := range mf.Metric {
+			series[labelSignature(m.Label)] = m
+		}
+	}
+	return series
+}
+
+func TestCardinalityGuard(t *testing.T) {
+	t.Run("case=drops series beyond the limit", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"user"})
+		reg.MustRegister(c)
+		for k := 0; k < 5; k++ {
+			c.WithLabelValues(strconv.Itoa(k)).Inc()
+		}
+
+		g := NewCardinalityGuard(reg, CardinalityLimits{MaxSeries: 3})
+		assert.Len(t, gatherSeries(t, g, "requests_total"), 3)
+		assert.Equal(t, map[string]int{"requests_total": 2}, g.Limited())
+
+		report := gatherSeries(t, g, LimitedSeriesMetric)
+		require.Len(t, report, 1)
+		for _, m := range report {
+			assert.Equal(t, float64(2), m.Gauge.GetValue())
+		}
+
+		// The admitted series stay the same across scrapes.
+		c.WithLabelValues("new").Inc()
+		series := gatherSeries(t, g, "requests_total")
+		assert.Len(t, series, 3)
+		assert.NotContains(t, series, labelSignature([]*dto.LabelPair{{Name: stringPtr("user"), Value: stringPtr("new")}}))
+	})
+
+	t.Run("case=aggregates series beyond the limit", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		h := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "duration_seconds", Buckets: []float64{1}}, []string{"path"})
+		reg.MustRegister(h)
+		h.WithLabelValues("/a").Observe(0.5)
+		h.WithLabelValues("/b").Observe(0.5)
+		h.WithLabelValues("/c").Observe(2)
+
+		g := NewCardinalityGuard(reg, CardinalityLimits{}, WithMetricLimits("duration_seconds", CardinalityLimits{MaxSeries: 1, Aggregate: true}))
+		series := gatherSeries(t, g, "duration_seconds")
+		require.Len(t, series, 2)
+
+		other := series[labelSignature([]*dto.LabelPair{{Name: stringPtr("path"), Value: stringPtr(OverflowLabelValue)}})]
+		require.NotNil(t, other)
+		assert.Equal(t, uint64(2), other.Histogram.GetSampleCount())
+		assert.Equal(t, 2.5, other.Histogram.GetSampleSum())
+		assert.Equal(t, uint64(1), other.Histogram.Bucket[0].GetCumulativeCount())
+	})
+
+	t.Run("case=removes labels which are not allowed", func(t *testing.T) {
+		reg := prometheus.NewRegistry()
+		c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"method", "user"})
+		reg.MustRegister(c)
+		c.WithLabelValues("GET", "a").Add(1)
+		c.WithLabelValues("GET", "b").Add(2)
+		c.WithLabelValues("POST", "a").Add(4)
+
+		g := NewCardinalityGuard(reg, CardinalityLimits{AllowedLabels: []string{"method"}})
+		series := gatherSeries(t, g, "requests_total")
+		require.Len(t, series, 2)
+		assert.Equal(t, float64(3), series[labelSignature([]*dto.LabelPair{{Name: stringPtr("method"), Value: stringPtr("GET")}})].Counter.GetValue())
+		assert.Equal(t, float64(4), series[labelSignature([]*dto.LabelPair{{Name: stringPtr("method"), Value: stringPtr("POST")}})].Counter.GetValue())
+		assert.Equal(t, map[string]int{"requests_total": 3}, g.Limited())
+	})
+
+	t.Run("case=applies to the prometheus handler", func(t *testing.T) {
+		p := NewPrometheus("guarded", WithCardinalityLimits(CardinalityLimits{}, WithMetricLimits("guarded_http_requests_total", CardinalityLimits{AllowedLabels: []string{"code"}})))
+		p.requests.WithLabelValues("GET", "/a", "200").Inc()
+		p.requests.WithLabelValues("POST", "/b", "200").Inc()
+
+		w := httptest.NewRecorder()
+		p.Handler().ServeHTTP(w, httptest.NewRequest("GET", PrometheusPath, nil))
+		body, err := ioutil.ReadAll(w.Body)
+		require.NoError(t, err)
+		assert.Contains(t, string(body), `guarded_http_requests_total{code="200"} 2`)
+		assert.Contains(t, string(body), LimitedSeriesMetric+`{metric="guarded_http_requests_total"} 2`)
+	})
+
+	t.Run("case=limits the request metrics when they are recorded", func(t *testing.T) {
+		p := NewPrometheus("recorded", WithCardinalityLimits(CardinalityLimits{MaxSeries: 2}))
+		for k := 0; k < 5; k++ {
+			p.requests.WithLabelValues("GET", "/"+strconv.Itoa(k), "200").Inc()
+			p.duration.WithLabelValues("GET", "/"+strconv.Itoa(k), "200").Observe(1)
+		}
+		assert.Equal(t, 3, testutil.CollectAndCount(p.requests.CounterVec))
+		assert.Equal(t, 3, testutil.CollectAndCount(p.duration.HistogramVec))
+
+		// the guard keeps the overflow series even though it exceeds the limit
+		series := gatherSeries(t, p.gatherer, "recorded_http_requests_total")
+		require.Len(t, series, 3)
+		other := series[labelSignature([]*dto.LabelPair{
+			{Name: stringPtr("code"), Value: stringPtr(OverflowLabelValue)},
+			{Name: stringPtr("method"), Value: stringPtr(OverflowLabelValue)},
+			{Name: stringPtr("route"), Value: stringPtr(OverflowLabelValue)},
+		})]
+		require.NotNil(t, other)
+		assert.Equal(t, float64(3), other.Counter.GetValue())
+	})
+}
@@ -33,8 +33,11 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/pkg/errors"
+
 	"github.com/ory/x/httpx"
 
 	"google.golang.org/grpc"
@@ -46,7 +49,7 @@ import (
 
 	"github.com/ory/x/cmdx"
 	"github.com/ory/x/logrusx"
-	"github.com/ory/x/resilience"
+	"github.com/ory/x/tracing"
 
 	"github.com/pborman/uuid"
 
@@ -58,14 +61,14 @@ var lock sync.Mutex
 
 // Service helps with providing context on metrics.
 type Service struct {
-	optOut bool
+	optOut int32
 	salt   string
 
 	o       *Options
 	context *analytics.Context
 
-	c analytics.Client
-	l *logrusx.Logger
+	buf *Buffer
+	l   *logrusx.Logger
 
 	mem *MemoryStatistics
 }
@@ -94,6 +97,16 @@ type Options struct {
 	// WriteKey is the segment API key.
 	WriteKey string
 
+	// Backend overrides the backend the events are sent to. If nil, the backend is chosen with
+	// the configuration key "sqa.backend", which is one of "segment" (default), "otlp", or
+	// "none". The OTLP backend sends the events to the endpoint configured with
+	// "sqa.otlp.endpoint".
+	Backend Backend
+
+	// BufferOptions configure how the events are buffered, for example whether they are spilled
+	// to disk while the backend is unavailable.
+	BufferOptions []BufferOption
+
 	// WhitelistedPaths represents a list of paths that can be transmitted in clear text to segment.
 	WhitelistedPaths []string
 
@@ -160,35 +173,8 @@ func New(
 		o.MemoryInterval = time.Hour * 12
 	}
 
-	segment, err := analytics.NewWithConfig(o.WriteKey, *o.Config)
-	if err != nil {
-		l.WithError(err).Fatalf("Unable to initialise software quality assurance features.")
-		return nil
-	}
-
+	optOut := IsOptedOut(cmd, c)
 	var oi analytics.OSInfo
-
-	optOut, err := cmd.Flags().GetBool("sqa-opt-out")
-	if err != nil {
-		cmdx.Must(err, `Unable to get command line flag "sqa-opt-out": %s`, err)
-	}
-
-	if !optOut {
-		optOut = c.Bool("sqa.opt_out")
-	}
-
-	if !optOut {
-		optOut = c.Bool("sqa_opt_out")
-	}
-
-	if !optOut {
-		optOut, _ = strconv.ParseBool(os.Getenv("SQA_OPT_OUT"))
-	}
-
-	if !optOut {
-		optOut, _ = strconv.ParseBool(os.Getenv("SQA-OPT-OUT"))
-	}
-
 	if !optOut {
 		l.Info("Software quality assurance features are enabled. Learn more at: https://www.ory.sh/docs/ecosystem/sqa")
 		oi = analytics.OSInfo{
@@ -197,12 +183,10 @@ func New(
 	}
 
 	m := &Service{
-		optOut: optOut,
-		salt:   uuid.New(),
-		o:      o,
-		c:      segment,
-		l:      l,
-		mem:    new(MemoryStatistics),
+		salt: uuid.New(),
+		o:    o,
+		l:    l,
+		mem:  new(MemoryStatistics),
 		context: &analytics.Context{
 			IP: net.IPv4(0, 0, 0, 0),
 			App: analytics.AppInfo{
@@ -218,6 +202,20 @@ func New(
 			UserAgent: "github.com/ory/x/metricsx.Service/v0.0.1",
 		},
 	}
+	if optOut {
+		m.optOut = 1
+	}
+
+	backend := o.Backend
+	if backend == nil {
+		var err error
+		backend, err = newBackend(c, o, m.context)
+		if err != nil {
+			l.WithError(err).Fatalf("Unable to initialise software quality assurance features.")
+			return nil
+		}
+	}
+	m.buf = NewBuffer(backend, l, o.BufferOptions...)
 
 	instance = m
 
@@ -227,34 +225,90 @@ func New(
 	return m
 }
 
-// Identify enables reporting to segment.
-func (sw *Service) Identify() {
-	if err := resilience.Retry(sw.l, time.Minute*5, time.Hour*24*30, func() error {
-		return sw.c.Enqueue(analytics.Identify{
-			UserId:  sw.o.ClusterID,
-			Traits:  sw.context.Traits,
-			Context: sw.context,
+// IsOptedOut returns true if the user opted out of telemetry with the "--sqa-opt-out" flag, the
+// configuration key "sqa.opt_out", or the environment variable "SQA_OPT_OUT".
+func IsOptedOut(cmd *cobra.Command, c *configx.Provider) bool {
+	optOut, err := cmd.Flags().GetBool("sqa-opt-out")
+	if err != nil {
+		cmdx.Must(err, `Unable to get command line flag "sqa-opt-out": %s`, err)
+	}
+
+	if !optOut {
+		optOut = c.Bool("sqa.opt_out")
+	}
+
+	if !optOut {
+		optOut = c.Bool("sqa_opt_out")
+	}
+
+	if !optOut {
+		optOut, _ = strconv.ParseBool(os.Getenv("SQA_OPT_OUT"))
+	}
+
+	if !optOut {
+		optOut, _ = strconv.ParseBool(os.Getenv("SQA-OPT-OUT"))
+	}
+
+	return optOut
+}
+
+func newBackend(c *configx.Provider, o *Options, ac *analytics.Context) (Backend, error) {
+	switch name := c.StringF("sqa.backend", BackendSegment); name {
+	case BackendSegment:
+		return NewSegmentBackend(o.WriteKey, *o.Config, ac)
+	case BackendOTLP:
+		endpoint := c.String("sqa.otlp.endpoint")
+		if endpoint == "" {
+			return nil, errors.New(`the configuration key "sqa.otlp.endpoint" must be set to use the OTLP backend`)
+		}
+		return NewOTLPBackend(&tracing.OTLPConfig{ServerURL: endpoint}, map[string]string{
+			"service.name":    o.Service,
+			"service.version": o.BuildVersion,
 		})
-	}); err != nil {
-		sw.l.WithError(err).Debug("Could not commit anonymized environment information")
+	case BackendNone:
+		return NewNoopBackend(), nil
+	default:
+		return nil, errors.Errorf(`unknown telemetry backend "%s", expected one of "segment", "otlp", or "none"`, name)
 	}
 }
 
-// ObserveMemory commits memory statistics to segment.
-func (sw *Service) ObserveMemory() {
-	if sw.optOut {
+// OptedOut returns true if telemetry is disabled.
+func (sw *Service) OptedOut() bool {
+	return atomic.LoadInt32(&sw.optOut) == 1
+}
+
+// OptOut disables telemetry and discards all events which were not sent yet.
+func (sw *Service) OptOut() {
+	atomic.StoreInt32(&sw.optOut, 1)
+	sw.buf.Discard()
+}
+
+// OptIn enables telemetry.
+func (sw *Service) OptIn() {
+	if atomic.CompareAndSwapInt32(&sw.optOut, 1, 0) {
+		go sw.Identify()
+	}
+}
+
+func (sw *Service) enqueue(e Event) {
+	if sw.OptedOut() {
 		return
 	}
+	e.UserID = sw.o.ClusterID
+	sw.buf.Enqueue(e)
+}
+
+// Identify reports the anonymized environment information.
+func (sw *Service) Identify() {
+	sw.enqueue(Event{Type: EventIdentify, Properties: sw.context.Traits})
+}
 
+// ObserveMemory reports memory statistics in the configured interval.
+func (sw *Service) ObserveMemory() {
 	for {
-		sw.mem.Update()
-		if err := sw.c.Enqueue(analytics.Track{
-			UserId:     sw.o.ClusterID,
-			Event:      "memstats",
-			Properties: analytics.Properties(sw.mem.ToMap()),
-			Context:    sw.context,
-		}); err != nil {
-			sw.l.WithError(err).Debug("Could not commit anonymized telemetry data")
+		if !sw.OptedOut() {
+			sw.mem.Update()
+			sw.enqueue(Event{Type: EventTrack, Name: "memstats", Properties: sw.mem.ToMap()})
 		}
 		time.Sleep(sw.o.MemoryInterval)
 	}
@@ -265,19 +319,15 @@ type negroniMiddleware interface {
 	Status() int
 }
 
-// ServeHTTP is a middleware for sending meta information to segment.
+// ServeHTTP is a middleware for sending meta information to the telemetry backend.
 func (sw *Service) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	var start time.Time
-	if !sw.optOut {
-		start = time.Now()
-	}
-
-	next(rw, r)
-
-	if sw.optOut {
+	if sw.OptedOut() {
+		next(rw, r)
 		return
 	}
 
+	start := time.Now()
+	next(rw, r)
 	latency := time.Since(start) / time.Millisecond
 
 	scheme := "https:"
@@ -291,9 +341,9 @@ func (sw *Service) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.
 	// Collecting request info
 	stat, size := httpx.GetResponseMeta(rw)
 
-	if err := sw.c.Enqueue(analytics.Page{
-		UserId: sw.o.ClusterID,
-		Name:   path,
+	sw.enqueue(Event{
+		Type: EventPage,
+		Name: path,
 		Properties: analytics.
 			NewProperties().
 			SetURL(scheme+"//"+sw.o.ClusterID+path+"?"+query).
@@ -303,30 +353,21 @@ func (sw *Service) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.
 			Set("size", size).
 			Set("latency", latency).
 			Set("method", r.Method),
-		Context: sw.context,
-	}); err != nil {
-		sw.l.WithError(err).Debug("Could not commit anonymized telemetry data")
-		// do nothing...
-	}
+	})
 }
 
 func (sw *Service) UnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
-	var start time.Time
-	if !sw.optOut {
-		start = time.Now()
+	if sw.OptedOut() {
+		return handler(ctx, req)
 	}
 
+	start := time.Now()
 	resp, err := handler(ctx, req)
-
-	if sw.optOut {
-		return resp, err
-	}
-
 	latency := time.Since(start) / time.Millisecond
 
-	if err := sw.c.Enqueue(analytics.Page{
-		UserId: sw.o.ClusterID,
-		Name:   info.FullMethod,
+	sw.enqueue(Event{
+		Type: EventPage,
+		Name: info.FullMethod,
 		Properties: analytics.
 			NewProperties().
 			SetURL("grpc://"+sw.o.ClusterID+info.FullMethod).
@@ -334,11 +375,7 @@ func (sw *Service) UnaryInterceptor(ctx context.Context, req interface{}, info *
 			SetName(info.FullMethod).
 			Set("status", status.Code(err)).
 			Set("latency", latency),
-		Context: sw.context,
-	}); err != nil {
-		sw.l.WithError(err).Debug("Could not commit anonymized telemetry data")
-		// do nothing...
-	}
+	})
 
 	return resp, err
 }
@@ -349,8 +386,19 @@ func (sw *Service) StreamInterceptor(srv interface{}, stream grpc.ServerStream,
 	return handler(srv, stream)
 }
 
+// Close sends the buffered events, waiting at most ten seconds, and closes the backend.
 func (sw *Service) Close() error {
-	return sw.c.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return sw.Shutdown(ctx)
+}
+
+// Shutdown sends the buffered events within the deadline of the context and closes the backend.
+func (sw *Service) Shutdown(ctx context.Context) error {
+	if sw.OptedOut() {
+		sw.buf.Discard()
+	}
+	return sw.buf.Close(ctx)
 }
 
 func (sw *Service) anonymizePath(path string, salt string) string {
@@ -0,0 +1,237 @@
+package metricsx
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/urfave/negroni"
+)
+
+const (
+	// PrometheusPath is the path of the Prometheus exposition endpoint.
+	PrometheusPath = "/metrics"
+
+	// UnmatchedRoute is the route label of requests which did not match a registered route.
+	UnmatchedRoute = "{unmatched}"
+)
+
+type (
+	// Prometheus records HTTP request metrics and exposes them, together with the Go runtime
+	// and process metrics, in the Prometheus exposition format.
+	//
+	// Requests are labeled with their route template, for example "/clients/:id", instead of
+	// their raw path, so that IDs in paths do not create a new series per request.
+	Prometheus struct {
+		registry *prometheus.Registry
+		gatherer prometheus.Gatherer
+		requests *LimitedCounterVec
+		duration *LimitedHistogramVec
+		inFlight prometheus.Gauge
+		routers  []*httprouter.Router
+		route    func(r *http.Request) string
+	}
+
+	// PrometheusOption configures Prometheus.
+	PrometheusOption func(*prometheusOptions)
+
+	prometheusOptions struct {
+		registry *prometheus.Registry
+		buckets  []float64
+		labels   prometheus.Labels
+		route    func(r *http.Request) string
+		guard    *cardinalityOptions
+	}
+)
+
+// WithRegistry registers the metrics with the given registry instead of a new one.
+func WithRegistry(r *prometheus.Registry) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.registry = r
+	}
+}
+
+// WithDurationBuckets sets the buckets of the request duration histogram in seconds. Defaults to
+// prometheus.DefBuckets.
+func WithDurationBuckets(buckets ...float64) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.buckets = buckets
+	}
+}
+
+// WithConstLabels adds labels to all request metrics, for example the version of the service.
+func WithConstLabels(labels prometheus.Labels) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.labels = labels
+	}
+}
+
+// WithRouteFunc determines the route label of requests which do not match any router
+// registered with RegisterRouter. The returned value must have a low cardinality.
+func WithRouteFunc(f func(r *http.Request) string) PrometheusOption {
+	return func(o *prometheusOptions) {
+		o.route = f
+	}
+}
+
+// NewPrometheus creates the request metrics prefixed with namespace, for example "kratos".
+// Unless WithRegistry is used, they are registered together with the Go runtime and process
+// collectors with a new registry.
+func NewPrometheus(namespace string, opts ...PrometheusOption) *Prometheus {
+	o := &prometheusOptions{buckets: prometheus.DefBuckets}
+	for _, f := range opts {
+		f(o)
+	}
+	if o.registry == nil {
+		o.registry = prometheus.NewRegistry()
+		o.registry.MustRegister(
+			prometheus.NewGoCollector(),
+			prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+		)
+	}
+
+	// the request metrics are limited when they are recorded, so that they do not grow unbounded
+	var guard *CardinalityGuard
+	maxSeries := func(string, string) int { return 0 }
+	if o.guard != nil {
+		guard = NewCardinalityGuard(o.registry, o.guard.defaults, o.guard.opts...)
+		maxSeries = func(subsystem, name string) int {
+			return guard.limitsFor(prometheus.BuildFQName(namespace, subsystem, name)).MaxSeries
+		}
+	}
+
+	p := &Prometheus{
+		registry: o.registry,
+		gatherer: o.registry,
+		route:    o.route,
+		requests: NewLimitedCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   "http",
+			Name:        "requests_total",
+			Help:        "The number of handled HTTP requests.",
+			ConstLabels: o.labels,
+		}, []string{"method", "route", "code"}, maxSeries("http", "requests_total")),
+		duration: NewLimitedHistogramVec(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   "http",
+			Name:        "request_duration_seconds",
+			Help:        "The duration of handled HTTP requests in seconds.",
+			Buckets:     o.buckets,
+			ConstLabels: o.labels,
+		}, []string{"method", "route", "code"}, maxSeries("http", "request_duration_seconds")),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   "http",
+			Name:        "requests_in_flight",
+			Help:        "The number of HTTP requests currently being handled.",
+			ConstLabels: o.labels,
+		}),
+	}
+	p.registry.MustRegister(p.requests, p.duration, p.inFlight)
+
+	if guard != nil {
+		p.gatherer = guard
+	}
+	return p
+}
+
+// Registry returns the registry of the metrics. Register application specific metrics with it
+// to expose them on the same endpoint.
+func (p *Prometheus) Registry() *prometheus.Registry {
+	return p.registry
+}
+
+// RegisterRouter uses the routes of the router to determine the route label of requests.
+func (p *Prometheus) RegisterRouter(router *httprouter.Router) {
+	p.routers = append(p.routers, router)
+}
+
+// Handler returns the Prometheus exposition endpoint.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.gatherer, promhttp.HandlerOpts{Registry: p.registry})
+}
+
+// SetRoutes registers the exposition endpoint at PrometheusPath.
+func (p *Prometheus) SetRoutes(r *httprouter.Router) {
+	r.Handler("GET", PrometheusPath, p.Handler())
+}
+
+// ServeHTTP is a middleware which records the request count, duration, and in-flight requests.
+func (p *Prometheus) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	p.inFlight.Inc()
+	defer p.inFlight.Dec()
+
+	nrw, ok := rw.(negroni.ResponseWriter)
+	if !ok {
+		nrw = negroni.NewResponseWriter(rw)
+	}
+
+	start := time.Now()
+	next(nrw, r)
+
+	status := nrw.Status()
+	if status == 0 {
+		status = http.StatusOK
+	}
+	labels := prometheus.Labels{"method": methodLabel(r.Method), "route": p.routeOf(r), "code": strconv.Itoa(status)}
+	p.requests.With(labels).Inc()
+	p.duration.With(labels).Observe(time.Since(start).Seconds())
+}
+
+// methodLabel returns the method label of the request. Non-standard methods are recorded as
+// "other", so that clients can not create a new series per request.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "other"
+}
+
+func (p *Prometheus) routeOf(r *http.Request) string {
+	for _, router := range p.routers {
+		if handle, params, _ := router.Lookup(r.Method, r.URL.Path); handle != nil {
+			return routeTemplate(router, r.Method, r.URL.Path, params)
+		}
+	}
+	if p.route != nil {
+		return p.route(r)
+	}
+	return UnmatchedRoute
+}
+
+// routeTemplate replaces the values of the path parameters with their names, which yields the
+// route the request matched, for example "/clients/:id" or "/files/*path".
+func routeTemplate(router *httprouter.Router, method, path string, params httprouter.Params) string {
+	const probe = "\x00"
+
+	parts := strings.Split(path, "/")
+	next := 0
+	for _, param := range params {
+		if strings.HasPrefix(param.Value, "/") {
+			// A catch-all parameter is always the last one and matches the rest of the path.
+			return strings.TrimSuffix(strings.Join(parts, "/"), param.Value) + "/*" + param.Key
+		}
+		for k := next; k < len(parts); k++ {
+			if parts[k] != param.Value {
+				continue
+			}
+			// A static segment may have the same value as the parameter, so make sure the
+			// parameter actually matched this segment.
+			candidate := strings.Split(path, "/")
+			candidate[k] = probe
+			if _, p, _ := router.Lookup(method, strings.Join(candidate, "/")); p.ByName(param.Key) != probe {
+				continue
+			}
+			parts[k] = ":" + param.Key
+			next = k + 1
+			break
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,83 @@
+package metricsx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+)
+
+func TestPrometheus(t *testing.T) {
+	router := httprouter.New()
+	noop := func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {}
+	router.GET("/clients/:id", noop)
+	router.GET("/users/:id/users", noop)
+	router.GET("/files/*path", noop)
+	router.GET("/missing", func(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+
+	p := NewPrometheus("test")
+	p.RegisterRouter(router)
+	p.SetRoutes(router)
+
+	n := negroni.New()
+	n.Use(p)
+	n.UseHandler(router)
+	ts := httptest.NewServer(n)
+	t.Cleanup(ts.Close)
+
+	for _, path := range []string{"/clients/1", "/clients/2", "/users/users/users", "/files/a/b", "/missing", "/not-routed/1"} {
+		res, err := http.Get(ts.URL + path)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+	}
+
+	for _, tc := range []struct {
+		route, code string
+		expected    float64
+	}{
+		{route: "/clients/:id", code: "200", expected: 2},
+		{route: "/users/:id/users", code: "200", expected: 1},
+		{route: "/files/*path", code: "200", expected: 1},
+		{route: "/missing", code: "404", expected: 1},
+		{route: UnmatchedRoute, code: "404", expected: 1},
+	} {
+		t.Run("route="+tc.route, func(t *testing.T) {
+			assert.Equal(t, tc.expected, testutil.ToFloat64(p.requests.WithLabelValues("GET", tc.route, tc.code)))
+		})
+	}
+	assert.Equal(t, float64(0), testutil.ToFloat64(p.inFlight))
+
+	res, err := http.Get(ts.URL + PrometheusPath)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), `test_http_requests_total{code="200",method="GET",route="/clients/:id"} 2`)
+	assert.Contains(t, string(body), `test_http_request_duration_seconds_bucket`)
+	assert.Contains(t, string(body), `go_goroutines`)
+}
+
+func TestPrometheusRouteFunc(t *testing.T) {
+	p := NewPrometheus("test", WithRouteFunc(func(r *http.Request) string { return "custom" }))
+	p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/anything", nil), func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.requests.WithLabelValues("GET", "custom", "418")))
+}
+
+func TestPrometheusMethods(t *testing.T) {
+	p := NewPrometheus("test", WithRouteFunc(func(r *http.Request) string { return "custom" }))
+	for _, method := range []string{"GET", "PROPFIND", "FOO1", "FOO2"} {
+		p.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/anything", nil), func(w http.ResponseWriter, r *http.Request) {})
+	}
+	assert.Equal(t, float64(1), testutil.ToFloat64(p.requests.WithLabelValues("GET", "custom", "200")))
+	assert.Equal(t, float64(3), testutil.ToFloat64(p.requests.WithLabelValues("other", "custom", "200")))
+}
@@ -0,0 +1,213 @@
+package metricsx
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// SeriesLimiter limits the label sets a metric vector records, so that unbounded label values
+	// do not grow the memory of the process or the series exposed to Prometheus. The first
+	// maxSeries label sets are recorded as they are, and all others are recorded as one series
+	// whose label values are OverflowLabelValue.
+	SeriesLimiter struct {
+		max        int
+		labelNames []string
+		limited    uint64
+
+		sync.RWMutex
+		seen map[string]struct{}
+	}
+
+	// LimitedCounterVec is a prometheus.CounterVec which maps the label values of new series
+	// beyond the limit to OverflowLabelValue when they are recorded. Curried vectors are not
+	// limited.
+	LimitedCounterVec struct {
+		*prometheus.CounterVec
+		limiter *SeriesLimiter
+	}
+
+	// LimitedGaugeVec is a prometheus.GaugeVec which maps the label values of new series beyond
+	// the limit to OverflowLabelValue when they are recorded. Curried vectors are not limited.
+	LimitedGaugeVec struct {
+		*prometheus.GaugeVec
+		limiter *SeriesLimiter
+	}
+
+	// LimitedHistogramVec is a prometheus.HistogramVec which maps the label values of new
+	// series beyond the limit to OverflowLabelValue when they are recorded. Curried vectors are
+	// not limited.
+	LimitedHistogramVec struct {
+		*prometheus.HistogramVec
+		limiter *SeriesLimiter
+	}
+)
+
+// NewSeriesLimiter returns a limiter of maxSeries label sets of the label names. Zero means
+// unlimited.
+func NewSeriesLimiter(labelNames []string, maxSeries int) *SeriesLimiter {
+	return &SeriesLimiter{max: maxSeries, labelNames: labelNames, seen: make(map[string]struct{})}
+}
+
+// Limited returns how many recordings were mapped to the overflow series.
+func (l *SeriesLimiter) Limited() uint64 {
+	return atomic.LoadUint64(&l.limited)
+}
+
+// LabelValues returns the label values to record. Label values which do not match the label
+// names are returned as they are, so that the vector reports the error.
+func (l *SeriesLimiter) LabelValues(lvs ...string) []string {
+	if len(lvs) != len(l.labelNames) || l.admit(strings.Join(lvs, "\xff")) {
+		return lvs
+	}
+	overflow := make([]string, len(lvs))
+	for k := range overflow {
+		overflow[k] = OverflowLabelValue
+	}
+	return overflow
+}
+
+// Labels returns the labels to record. Labels which do not match the label names are returned
+// as they are, so that the vector reports the error.
+func (l *SeriesLimiter) Labels(labels prometheus.Labels) prometheus.Labels {
+	if len(labels) != len(l.labelNames) {
+		return labels
+	}
+	lvs := make([]string, len(l.labelNames))
+	for k, name := range l.labelNames {
+		value, ok := labels[name]
+		if !ok {
+			return labels
+		}
+		lvs[k] = value
+	}
+	if l.admit(strings.Join(lvs, "\xff")) {
+		return labels
+	}
+	overflow := make(prometheus.Labels, len(labels))
+	for name := range labels {
+		overflow[name] = OverflowLabelValue
+	}
+	return overflow
+}
+
+func (l *SeriesLimiter) admit(signature string) bool {
+	if l.max <= 0 {
+		return true
+	}
+
+	l.RLock()
+	_, ok := l.seen[signature]
+	l.RUnlock()
+	if ok {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.seen[signature]; ok {
+		return true
+	}
+	if len(l.seen) >= l.max {
+		atomic.AddUint64(&l.limited, 1)
+		return false
+	}
+	l.seen[signature] = struct{}{}
+	return true
+}
+
+// NewLimitedCounterVec creates a counter vector which records at most maxSeries series and the
+// overflow series.
+func NewLimitedCounterVec(opts prometheus.CounterOpts, labelNames []string, maxSeries int) *LimitedCounterVec {
+	return &LimitedCounterVec{CounterVec: prometheus.NewCounterVec(opts, labelNames), limiter: NewSeriesLimiter(labelNames, maxSeries)}
+}
+
+// Limited returns how many recordings were mapped to the overflow series.
+func (v *LimitedCounterVec) Limited() uint64 {
+	return v.limiter.Limited()
+}
+
+// WithLabelValues works like prometheus.CounterVec.WithLabelValues.
+func (v *LimitedCounterVec) WithLabelValues(lvs ...string) prometheus.Counter {
+	return v.CounterVec.WithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// With works like prometheus.CounterVec.With.
+func (v *LimitedCounterVec) With(labels prometheus.Labels) prometheus.Counter {
+	return v.CounterVec.With(v.limiter.Labels(labels))
+}
+
+// GetMetricWithLabelValues works like prometheus.CounterVec.GetMetricWithLabelValues.
+func (v *LimitedCounterVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Counter, error) {
+	return v.CounterVec.GetMetricWithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// GetMetricWith works like prometheus.CounterVec.GetMetricWith.
+func (v *LimitedCounterVec) GetMetricWith(labels prometheus.Labels) (prometheus.Counter, error) {
+	return v.CounterVec.GetMetricWith(v.limiter.Labels(labels))
+}
+
+// NewLimitedGaugeVec creates a gauge vector which records at most maxSeries series and the
+// overflow series.
+func NewLimitedGaugeVec(opts prometheus.GaugeOpts, labelNames []string, maxSeries int) *LimitedGaugeVec {
+	return &LimitedGaugeVec{GaugeVec: prometheus.NewGaugeVec(opts, labelNames), limiter: NewSeriesLimiter(labelNames, maxSeries)}
+}
+
+// Limited returns how many recordings were mapped to the overflow series.
+func (v *LimitedGaugeVec) Limited() uint64 {
+	return v.limiter.Limited()
+}
+
+// WithLabelValues works like prometheus.GaugeVec.WithLabelValues.
+func (v *LimitedGaugeVec) WithLabelValues(lvs ...string) prometheus.Gauge {
+	return v.GaugeVec.WithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// With works like prometheus.GaugeVec.With.
+func (v *LimitedGaugeVec) With(labels prometheus.Labels) prometheus.Gauge {
+	return v.GaugeVec.With(v.limiter.Labels(labels))
+}
+
+// GetMetricWithLabelValues works like prometheus.GaugeVec.GetMetricWithLabelValues.
+func (v *LimitedGaugeVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Gauge, error) {
+	return v.GaugeVec.GetMetricWithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// GetMetricWith works like prometheus.GaugeVec.GetMetricWith.
+func (v *LimitedGaugeVec) GetMetricWith(labels prometheus.Labels) (prometheus.Gauge, error) {
+	return v.GaugeVec.GetMetricWith(v.limiter.Labels(labels))
+}
+
+// NewLimitedHistogramVec creates a histogram vector which records at most maxSeries series and
+// the overflow series.
+func NewLimitedHistogramVec(opts prometheus.HistogramOpts, labelNames []string, maxSeries int) *LimitedHistogramVec {
+	return &LimitedHistogramVec{HistogramVec: prometheus.NewHistogramVec(opts, labelNames), limiter: NewSeriesLimiter(labelNames, maxSeries)}
+}
+
+// Limited returns how many recordings were mapped to the overflow series.
+func (v *LimitedHistogramVec) Limited() uint64 {
+	return v.limiter.Limited()
+}
+
+// WithLabelValues works like prometheus.HistogramVec.WithLabelValues.
+func (v *LimitedHistogramVec) WithLabelValues(lvs ...string) prometheus.Observer {
+	return v.HistogramVec.WithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// With works like prometheus.HistogramVec.With.
+func (v *LimitedHistogramVec) With(labels prometheus.Labels) prometheus.Observer {
+	return v.HistogramVec.With(v.limiter.Labels(labels))
+}
+
+// GetMetricWithLabelValues works like prometheus.HistogramVec.GetMetricWithLabelValues.
+func (v *LimitedHistogramVec) GetMetricWithLabelValues(lvs ...string) (prometheus.Observer, error) {
+	return v.HistogramVec.GetMetricWithLabelValues(v.limiter.LabelValues(lvs...)...)
+}
+
+// GetMetricWith works like prometheus.HistogramVec.GetMetricWith.
+func (v *LimitedHistogramVec) GetMetricWith(labels prometheus.Labels) (prometheus.Observer, error) {
+	return v.HistogramVec.GetMetricWith(v.limiter.Labels(labels))
+}
@@ -0,0 +1,69 @@
+package metricsx
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesLimiter(t *testing.T) {
+	t.Run("case=maps series beyond the limit to the overflow series", func(t *testing.T) {
+		c := NewLimitedCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"user", "method"}, 2)
+		for k := 0; k < 5; k++ {
+			c.WithLabelValues(strconv.Itoa(k), "GET").Inc()
+		}
+		c.With(prometheus.Labels{"user": "0", "method": "GET"}).Inc()
+		c.With(prometheus.Labels{"user": "5", "method": "GET"}).Inc()
+
+		assert.Equal(t, 3, testutil.CollectAndCount(c))
+		assert.Equal(t, float64(2), testutil.ToFloat64(c.CounterVec.WithLabelValues("0", "GET")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(c.CounterVec.WithLabelValues("1", "GET")))
+		assert.Equal(t, float64(4), testutil.ToFloat64(c.CounterVec.WithLabelValues(OverflowLabelValue, OverflowLabelValue)))
+		assert.EqualValues(t, 4, c.Limited())
+	})
+
+	t.Run("case=histograms and gauges", func(t *testing.T) {
+		h := NewLimitedHistogramVec(prometheus.HistogramOpts{Name: "duration_seconds"}, []string{"path"}, 1)
+		h.WithLabelValues("/a").Observe(1)
+		h.WithLabelValues("/b").Observe(1)
+		_, err := h.GetMetricWith(prometheus.Labels{"path": "/c"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, testutil.CollectAndCount(h))
+
+		g := NewLimitedGaugeVec(prometheus.GaugeOpts{Name: "sessions"}, []string{"tenant"}, 1)
+		g.WithLabelValues("a").Set(1)
+		g.WithLabelValues("b").Set(2)
+		gauge, err := g.GetMetricWithLabelValues("c")
+		require.NoError(t, err)
+		gauge.Add(3)
+		assert.Equal(t, float64(5), testutil.ToFloat64(g.GaugeVec.WithLabelValues(OverflowLabelValue)))
+	})
+
+	t.Run("case=unlimited", func(t *testing.T) {
+		c := NewLimitedCounterVec(prometheus.CounterOpts{Name: "requests_total"}, []string{"user"}, 0)
+		for k := 0; k < 100; k++ {
+			c.WithLabelValues(strconv.Itoa(k)).Inc()
+		}
+		assert.Equal(t, 100, testutil.CollectAndCount(c))
+		assert.Zero(t, c.Limited())
+	})
+
+	t.Run("case=concurrent", func(t *testing.T) {
+		l := NewSeriesLimiter([]string{"user"}, 10)
+		var wg sync.WaitGroup
+		for k := 0; k < 50; k++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				l.LabelValues(strconv.Itoa(k))
+			}(k)
+		}
+		wg.Wait()
+		assert.EqualValues(t, 40, l.Limited())
+	})
+}
@@ -0,0 +1,157 @@
+package networkx
+
+import (
+	"context"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/sqlcon"
+)
+
+var (
+	// ErrLeaseHeld is returned if the lease is held by another holder and has not expired yet.
+	ErrLeaseHeld = errors.New("the lease is held by another holder")
+
+	// ErrLeaseLost is returned if the lease expired and was acquired by another holder, or was
+	// released, in the meantime.
+	ErrLeaseLost = errors.New("the lease was lost")
+)
+
+// Lease is a lock with a time to live which is shared by all processes using the same database,
+// for example to run a background job on only one replica.
+//
+// Expiry is determined by the clocks of the processes, which should thus be synchronized.
+type Lease struct {
+	Name   string `json:"name" db:"name"`
+	Holder string `json:"holder" db:"holder"`
+
+	// Token is a fencing token which is incremented every time the lease is acquired. Pass it
+	// along with writes to other systems and reject writes with a lower token than the highest
+	// one seen, so that a holder whose lease expired without noticing can not cause harm.
+	Token int64 `json:"token" db:"token"`
+
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"`
+}
+
+func (l Lease) TableName() string {
+	return "network_leases"
+}
+
+// AcquireLease acquires the lease with the given name for ttl. It returns ErrLeaseHeld if
+// another holder has a lease which has not expired yet.
+func (m *Manager) AcquireLease(ctx context.Context, name string, ttl time.Duration) (*Lease, error) {
+	lease := &Lease{
+		Name:      name,
+		Holder:    uuid.Must(uuid.NewV4()).String(),
+		Token:     1,
+		ExpiresAt: time.Now().UTC().Add(ttl),
+	}
+
+	if err := m.c.WithContext(ctx).Transaction(func(tx *pop.Connection) error {
+		var existing Lease
+		if err := sqlcon.HandleError(tx.Where("name = ?", name).First(&existing)); errors.Is(err, sqlcon.ErrNoRows) {
+			return sqlcon.HandleError(tx.RawQuery("INSERT INTO network_leases (name, holder, token, expires_at) VALUES (?, ?, ?, ?)",
+				lease.Name, lease.Holder, lease.Token, lease.ExpiresAt).Exec())
+		} else if err != nil {
+			return err
+		}
+
+		lease.Token = existing.Token + 1
+		count, err := tx.RawQuery("UPDATE network_leases SET holder = ?, token = ?, expires_at = ? WHERE name = ? AND token = ? AND expires_at < ?",
+			lease.Holder, lease.Token, lease.ExpiresAt, name, existing.Token, time.Now().UTC()).ExecWithCount()
+		if err != nil {
+			return sqlcon.HandleError(err)
+		} else if count == 0 {
+			return errors.WithStack(ErrLeaseHeld)
+		}
+		return nil
+	}); errors.Is(err, sqlcon.ErrUniqueViolation) {
+		// Another holder acquired the lease concurrently.
+		return nil, errors.WithStack(ErrLeaseHeld)
+	} else if err != nil {
+		return nil, err
+	}
+
+	return lease, nil
+}
+
+// RenewLease extends the lease by ttl. It returns ErrLeaseLost if the lease is no longer held.
+func (m *Manager) RenewLease(ctx context.Context, lease *Lease, ttl time.Duration) error {
+	expiresAt := time.Now().UTC().Add(ttl)
+	count, err := m.c.WithContext(ctx).RawQuery("UPDATE network_leases SET expires_at = ? WHERE name = ? AND holder = ? AND token = ? AND expires_at >= ?",
+		expiresAt, lease.Name, lease.Holder, lease.Token, time.Now().UTC()).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	} else if count == 0 {
+		return errors.WithStack(ErrLeaseLost)
+	}
+
+	lease.ExpiresAt = expiresAt
+	return nil
+}
+
+// ReleaseLease releases the lease so that it can be acquired by another holder right away. It
+// returns ErrLeaseLost if the lease is no longer held.
+func (m *Manager) ReleaseLease(ctx context.Context, lease *Lease) error {
+	// The row is kept so that the fencing token keeps increasing.
+	count, err := m.c.WithContext(ctx).RawQuery("UPDATE network_leases SET expires_at = ? WHERE name = ? AND holder = ? AND token = ?",
+		time.Unix(0, 0).UTC(), lease.Name, lease.Holder, lease.Token).ExecWithCount()
+	if err != nil {
+		return sqlcon.HandleError(err)
+	} else if count == 0 {
+		return errors.WithStack(ErrLeaseLost)
+	}
+	return nil
+}
+
+// RunExclusive runs fn while holding the lease with the given name, for example to run a
+// singleton background job. The lease is renewed every third of ttl. If renewing fails, the
+// context passed to fn is canceled. It returns ErrLeaseHeld without running fn if the lease is
+// held by another holder.
+func (m *Manager) RunExclusive(ctx context.Context, name string, ttl time.Duration, fn func(ctx context.Context, lease *Lease) error) error {
+	lease, err := m.AcquireLease(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	renewed := make(chan error, 1)
+	go func() {
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				renewed <- nil
+				return
+			case <-ticker.C:
+				if err := m.RenewLease(ctx, &Lease{Name: lease.Name, Holder: lease.Holder, Token: lease.Token}, ttl); err != nil {
+					if ctx.Err() != nil {
+						renewed <- nil
+						return
+					}
+					m.l.WithError(err).WithField("lease", name).Error("Unable to renew the lease, aborting.")
+					cancel()
+					renewed <- err
+					return
+				}
+			}
+		}
+	}()
+
+	err = fn(ctx, lease)
+	cancel()
+	if renewErr := <-renewed; renewErr != nil && err == nil {
+		err = renewErr
+	}
+
+	if releaseErr := m.ReleaseLease(context.Background(), lease); releaseErr != nil && !errors.Is(releaseErr, ErrLeaseLost) {
+		m.l.WithError(releaseErr).WithField("lease", name).Warn("Unable to release the lease, it is released once it expires.")
+	}
+	return err
+}
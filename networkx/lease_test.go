@@ -0,0 +1,77 @@
+package networkx
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/dbal"
+	"github.com/ory/x/logrusx"
+)
+
+func TestLease(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := pop.NewConnection(&pop.ConnectionDetails{URL: dbal.SQLiteInMemory})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+
+	m := NewManager(c, logrusx.New("", ""), nil)
+	require.NoError(t, m.MigrateUp(ctx))
+
+	first, err := m.AcquireLease(ctx, "job", time.Minute)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, first.Token)
+
+	_, err = m.AcquireLease(ctx, "job", time.Minute)
+	require.ErrorIs(t, err, ErrLeaseHeld)
+
+	other, err := m.AcquireLease(ctx, "other-job", time.Minute)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, other.Token)
+
+	require.NoError(t, m.RenewLease(ctx, first, time.Minute))
+	require.NoError(t, m.ReleaseLease(ctx, first))
+	require.ErrorIs(t, m.RenewLease(ctx, first, time.Minute), ErrLeaseLost)
+
+	second, err := m.AcquireLease(ctx, "job", time.Millisecond)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, second.Token)
+	assert.NotEqual(t, first.Holder, second.Holder)
+
+	time.Sleep(10 * time.Millisecond)
+	third, err := m.AcquireLease(ctx, "job", time.Minute)
+	require.NoError(t, err, "expired leases can be acquired")
+	assert.EqualValues(t, 3, third.Token)
+
+	require.ErrorIs(t, m.RenewLease(ctx, second, time.Minute), ErrLeaseLost)
+	require.ErrorIs(t, m.ReleaseLease(ctx, second), ErrLeaseLost)
+	require.NoError(t, m.ReleaseLease(ctx, third))
+
+	t.Run("case=run exclusive", func(t *testing.T) {
+		var ran bool
+		require.NoError(t, m.RunExclusive(ctx, "exclusive", 30*time.Millisecond, func(ctx context.Context, lease *Lease) error {
+			ran = true
+			err := m.RunExclusive(ctx, "exclusive", time.Minute, func(context.Context, *Lease) error {
+				t.Fatal("must not run while the lease is held")
+				return nil
+			})
+			require.ErrorIs(t, err, ErrLeaseHeld)
+
+			// Outlive the ttl to make sure the lease is renewed.
+			time.Sleep(100 * time.Millisecond)
+			return ctx.Err()
+		}))
+		assert.True(t, ran)
+
+		expected := errors.New("job failed")
+		require.ErrorIs(t, m.RunExclusive(ctx, "exclusive", time.Minute, func(context.Context, *Lease) error {
+			return expected
+		}), expected)
+	})
+}
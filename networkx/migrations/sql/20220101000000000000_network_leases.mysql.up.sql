@@ -0,0 +1,7 @@
+CREATE TABLE `network_leases` (
+`name` VARCHAR(255) NOT NULL,
+PRIMARY KEY(`name`),
+`holder` VARCHAR(64) NOT NULL,
+`token` BIGINT NOT NULL,
+`expires_at` DATETIME(6) NOT NULL
+) ENGINE=InnoDB;
\ No newline at end of file
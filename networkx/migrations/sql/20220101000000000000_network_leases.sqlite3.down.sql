@@ -0,0 +1 @@
+DROP TABLE "network_leases";
\ No newline at end of file
@@ -0,0 +1,6 @@
+CREATE TABLE "network_leases" (
+"name" TEXT PRIMARY KEY,
+"holder" TEXT NOT NULL,
+"token" INTEGER NOT NULL,
+"expires_at" DATETIME NOT NULL
+);
\ No newline at end of file
@@ -0,0 +1,222 @@
+package objectstoragex
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const gcsReadOnlyScope = "https://www.googleapis.com/auth/devstorage.read_only"
+
+type (
+	// gcsStorage requests objects from Google Cloud Storage. Requests are authorized with an
+	// access token of the service account or user credentials file referenced by the
+	// GOOGLE_APPLICATION_CREDENTIALS environment variable, or of the metadata server on Google
+	// Cloud. Without credentials, only public objects can be requested.
+	gcsStorage struct {
+		endpoint *url.URL
+		hc       *http.Client
+		getenv   func(string) string
+		now      func() time.Time
+
+		sync.Mutex
+		token  string
+		expiry time.Time
+		// noMetadataServer is set once the metadata server was found to be unavailable.
+		noMetadataServer bool
+	}
+
+	gcsCredentials struct {
+		Type         string `json:"type"`
+		ClientEmail  string `json:"client_email"`
+		PrivateKey   string `json:"private_key"`
+		PrivateKeyID string `json:"private_key_id"`
+		TokenURI     string `json:"token_uri"`
+		ClientID     string `json:"client_id"`
+		ClientSecret string `json:"client_secret"`
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	gcsToken struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+)
+
+func (s *gcsStorage) objectURL(bucket, key string) (*url.URL, error) {
+	if s.endpoint != nil {
+		return endpointURL(s.endpoint, bucket, key), nil
+	}
+	if host := s.getenv("STORAGE_EMULATOR_HOST"); host != "" {
+		if !strings.Contains(host, "://") {
+			host = "http://" + host
+		}
+		endpoint, err := url.Parse(host)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse STORAGE_EMULATOR_HOST")
+		}
+		return endpointURL(endpoint, bucket, key), nil
+	}
+	return &url.URL{Scheme: "https", Host: "storage.googleapis.com", Path: path.Join("/", bucket, key)}, nil
+}
+
+func (s *gcsStorage) authorize(r *http.Request) error {
+	if s.endpoint != nil || s.getenv("STORAGE_EMULATOR_HOST") != "" {
+		// emulators do not require credentials
+		return nil
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// refresh the token a minute before it expires
+	if s.token == "" || s.now().Add(time.Minute).After(s.expiry) {
+		token, err := s.fetchToken(r)
+		if err != nil {
+			return err
+		}
+		if token == nil {
+			return nil
+		}
+		s.token, s.expiry = token.AccessToken, s.now().Add(time.Duration(token.ExpiresIn)*time.Second)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+s.token)
+	return nil
+}
+
+// fetchToken returns a new access token, or nil if there are no credentials.
+func (s *gcsStorage) fetchToken(r *http.Request) (*gcsToken, error) {
+	if file := s.getenv("GOOGLE_APPLICATION_CREDENTIALS"); file != "" {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		var c gcsCredentials
+		if err := json.Unmarshal(raw, &c); err != nil {
+			return nil, errors.Wrap(err, "unable to decode GOOGLE_APPLICATION_CREDENTIALS")
+		}
+		return s.exchangeToken(r, &c)
+	}
+
+	if s.noMetadataServer {
+		return nil, nil
+	}
+	host := s.getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+	// outside of Google Cloud, the request might only fail after a long DNS timeout
+	ctx, cancel := context.WithTimeout(r.Context(), 3*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	res, err := s.hc.Do(req)
+	if err != nil {
+		// not running on Google Cloud
+		s.noMetadataServer = true
+		return nil, nil
+	}
+	return decodeGCSToken(res)
+}
+
+func (s *gcsStorage) exchangeToken(r *http.Request, c *gcsCredentials) (*gcsToken, error) {
+	form := url.Values{}
+	tokenURI := c.TokenURI
+	if tokenURI == "" {
+		tokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	switch c.Type {
+	case "service_account":
+		key, err := parsePrivateKey(c.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+			(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", c.PrivateKeyID))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		now := s.now()
+		assertion, err := jwt.Signed(signer).Claims(jwt.Claims{
+			Issuer:   c.ClientEmail,
+			Audience: jwt.Audience{tokenURI},
+			IssuedAt: jwt.NewNumericDate(now),
+			Expiry:   jwt.NewNumericDate(now.Add(time.Hour)),
+		}).Claims(map[string]interface{}{"scope": gcsReadOnlyScope}).CompactSerialize()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+		form.Set("assertion", assertion)
+	case "authorized_user":
+		form.Set("grant_type", "refresh_token")
+		form.Set("client_id", c.ClientID)
+		form.Set("client_secret", c.ClientSecret)
+		form.Set("refresh_token", c.RefreshToken)
+	default:
+		return nil, errors.Errorf("GOOGLE_APPLICATION_CREDENTIALS has unsupported type %q, supported are service_account and authorized_user", c.Type)
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), "POST", tokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	res, err := s.hc.Do(req)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to fetch Google Cloud access token")
+	}
+	return decodeGCSToken(res)
+}
+
+func decodeGCSToken(res *http.Response) (*gcsToken, error) {
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("expected http response status code 200 but got %d when fetching Google Cloud access token", res.StatusCode)
+	}
+
+	var t gcsToken
+	if err := json.NewDecoder(res.Body).Decode(&t); err != nil {
+		return nil, errors.Wrap(err, "unable to decode Google Cloud access token")
+	}
+	if t.AccessToken == "" {
+		return nil, errors.New("the Google Cloud access token response contains no access token")
+	}
+	return &t, nil
+}
+
+// parsePrivateKey parses a PEM encoded PKCS #8 or PKCS #1 private key.
+func parsePrivateKey(raw string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(raw))
+	if block == nil {
+		return nil, errors.New("the private key of GOOGLE_APPLICATION_CREDENTIALS is not PEM encoded")
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the private key of GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	return key, nil
+}
@@ -0,0 +1,119 @@
+package objectstoragex
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestGCSCredentials(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var tokenRequests int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		switch r.URL.Path {
+		case "/token":
+			require.NoError(t, r.ParseForm())
+			switch r.Form.Get("grant_type") {
+			case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+				token, err := jwt.ParseSigned(r.Form.Get("assertion"))
+				require.NoError(t, err)
+				var claims map[string]interface{}
+				require.NoError(t, token.Claims(&key.PublicKey, &claims))
+				assert.Equal(t, "objects@example.iam.gserviceaccount.com", claims["iss"])
+				assert.Equal(t, gcsReadOnlyScope, claims["scope"])
+			case "refresh_token":
+				assert.Equal(t, "refresh", r.Form.Get("refresh_token"))
+			default:
+				t.Errorf("unexpected grant type %s", r.Form.Get("grant_type"))
+			}
+		case "/computeMetadata/v1/instance/service-accounts/default/token":
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+		}
+		_ = json.NewEncoder(w).Encode(&gcsToken{AccessToken: "access-" + r.URL.Path, ExpiresIn: 3600})
+	}))
+	t.Cleanup(ts.Close)
+
+	credentials := func(t *testing.T, c gcsCredentials) string {
+		file := filepath.Join(t.TempDir(), "credentials.json")
+		raw, err := json.Marshal(c)
+		require.NoError(t, err)
+		require.NoError(t, ioutil.WriteFile(file, raw, 0600))
+		return file
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name: "service account",
+			env: map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": credentials(t, gcsCredentials{
+				Type:        "service_account",
+				ClientEmail: "objects@example.iam.gserviceaccount.com",
+				PrivateKey:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+				TokenURI:    ts.URL + "/token",
+			})},
+			expected: "Bearer access-/token",
+		},
+		{
+			name: "authorized user",
+			env: map[string]string{"GOOGLE_APPLICATION_CREDENTIALS": credentials(t, gcsCredentials{
+				Type:         "authorized_user",
+				RefreshToken: "refresh",
+				TokenURI:     ts.URL + "/token",
+			})},
+			expected: "Bearer access-/token",
+		},
+		{
+			name:     "metadata server",
+			env:      map[string]string{"GCE_METADATA_HOST": strings.TrimPrefix(ts.URL, "http://")},
+			expected: "Bearer access-/computeMetadata/v1/instance/service-accounts/default/token",
+		},
+	} {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&tokenRequests, 0)
+			s := &gcsStorage{hc: ts.Client(), getenv: mapEnv(tc.env), now: time.Now}
+
+			for i := 0; i < 2; i++ {
+				r, err := http.NewRequest("GET", "https://storage.googleapis.com/bucket/key", nil)
+				require.NoError(t, err)
+				require.NoError(t, s.authorize(r))
+				assert.Equal(t, tc.expected, r.Header.Get("Authorization"))
+			}
+			// the token is reused until it expires
+			assert.EqualValues(t, 1, atomic.LoadInt32(&tokenRequests))
+		})
+	}
+
+	t.Run("case=no credentials", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		host := strings.TrimPrefix(ts.URL, "http://")
+		ts.Close()
+
+		s := &gcsStorage{hc: http.DefaultClient, getenv: mapEnv(map[string]string{"GCE_METADATA_HOST": host}), now: time.Now}
+		r, err := http.NewRequest("GET", "https://storage.googleapis.com/bucket/key", nil)
+		require.NoError(t, err)
+		require.NoError(t, s.authorize(r))
+		assert.Empty(t, r.Header.Get("Authorization"))
+	})
+}
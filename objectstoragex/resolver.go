@@ -0,0 +1,197 @@
+// Package objectstoragex resolves object storage URLs like s3://<bucket>/<key>,
+// gs://<bucket>/<key>, and azblob://<container>/<blob> to HTTP requests which are authorized with
+// the ambient credentials of the environment, without depending on the SDKs of the cloud
+// providers:
+//
+//	s3:     AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN, and AWS_REGION
+//	gs:     GOOGLE_APPLICATION_CREDENTIALS or the metadata server on Google Cloud
+//	azblob: AZURE_STORAGE_ACCOUNT and either AZURE_STORAGE_KEY or AZURE_STORAGE_SAS_TOKEN
+//
+// Without credentials, only public objects can be requested.
+package objectstoragex
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrUnknownScheme is returned for URLs which are not object storage URLs.
+	ErrUnknownScheme = errors.New("the URL is not an object storage URL")
+
+	// ErrNotAllowed is returned for objects of schemes or buckets which were not allowed with
+	// WithBuckets.
+	ErrNotAllowed = errors.New("the object storage URL is not allowed")
+)
+
+type (
+	// Resolver resolves object storage URLs to authorized HTTP requests. It is safe for
+	// concurrent use, and should be reused because it caches access tokens.
+	Resolver struct {
+		storages map[string]objectStorage
+		buckets  map[string][]string
+	}
+
+	// Option configures a Resolver.
+	Option func(o *options)
+
+	options struct {
+		buckets     map[string][]string
+		endpoints   map[string]*url.URL
+		tokenClient *http.Client
+		getenv      func(string) string
+	}
+
+	// objectStorage resolves and authorizes requests for objects of a storage service.
+	objectStorage interface {
+		// objectURL returns the HTTP URL of the object.
+		objectURL(bucket, key string) (*url.URL, error)
+
+		// authorize adds the ambient credentials, if any, to the request.
+		authorize(r *http.Request) error
+	}
+)
+
+// Schemes are the schemes of the supported object storage services.
+var Schemes = []string{"s3", "gs", "azblob"}
+
+// WithBuckets allows requesting objects of the buckets or containers of the scheme ("s3", "gs",
+// or "azblob"). Objects of other buckets are refused with ErrNotAllowed, because the ambient
+// credentials usually grant access to private buckets, which users must not be able to read if
+// URLs are supplied by users.
+//
+// Use "*" to allow all buckets of the scheme, which is only safe if URLs are never supplied by
+// users.
+func WithBuckets(scheme string, buckets ...string) Option {
+	return func(o *options) {
+		o.buckets[scheme] = append(o.buckets[scheme], buckets...)
+	}
+}
+
+// WithEndpoint sets the endpoint of the scheme, for example of S3 compatible storage or a storage
+// emulator. Objects are requested from `<endpoint>/<bucket>/<key>`.
+func WithEndpoint(scheme string, endpoint *url.URL) Option {
+	return func(o *options) {
+		o.endpoints[scheme] = endpoint
+	}
+}
+
+// WithTokenClient sets the client which requests Google Cloud access tokens. It must be able to
+// reach the metadata server, which has an internal IP address. Defaults to http.DefaultClient.
+func WithTokenClient(c *http.Client) Option {
+	return func(o *options) {
+		o.tokenClient = c
+	}
+}
+
+// WithEnv sets the function which looks up the environment variables with credentials.
+// Defaults to os.Getenv.
+func WithEnv(getenv func(string) string) Option {
+	return func(o *options) {
+		o.getenv = getenv
+	}
+}
+
+// NewResolver returns a Resolver. Without WithBuckets, it refuses all objects.
+func NewResolver(opts ...Option) *Resolver {
+	o := &options{
+		buckets:     map[string][]string{},
+		endpoints:   map[string]*url.URL{},
+		tokenClient: http.DefaultClient,
+		getenv:      os.Getenv,
+	}
+	for _, f := range opts {
+		f(o)
+	}
+
+	return &Resolver{
+		storages: map[string]objectStorage{
+			"s3": &s3Storage{
+				endpoint: o.endpoints["s3"],
+				getenv:   o.getenv,
+				now:      time.Now,
+			},
+			"gs": &gcsStorage{
+				endpoint: o.endpoints["gs"],
+				hc:       o.tokenClient,
+				getenv:   o.getenv,
+				now:      time.Now,
+			},
+			"azblob": &azblobStorage{
+				endpoint: o.endpoints["azblob"],
+				getenv:   o.getenv,
+				now:      time.Now,
+			},
+		},
+		buckets: o.buckets,
+	}
+}
+
+// IsObjectStorageURL returns true if the URL has the scheme of an object storage service.
+func IsObjectStorageURL(rawURL string) bool {
+	for _, scheme := range Schemes {
+		if strings.HasPrefix(rawURL, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// NewRequest returns the authorized GET request of the object. Conditional headers like
+// If-None-Match may be added to the request afterwards, because they are not signed.
+func (r *Resolver) NewRequest(ctx context.Context, rawURL string) (*http.Request, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	storage, ok := r.storages[u.Scheme]
+	if !ok {
+		return nil, errors.WithStack(ErrUnknownScheme)
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return nil, errors.Errorf("expected a URL like %s://<bucket>/<key>", u.Scheme)
+	}
+	if !r.allowed(u.Scheme, bucket) {
+		return nil, errors.Wrapf(ErrNotAllowed, "%s bucket %q is not allowed", u.Scheme, bucket)
+	}
+
+	object, err := storage.objectURL(bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", object.String(), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := storage.authorize(req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// allowed returns true if objects of the bucket may be requested.
+func (r *Resolver) allowed(scheme, bucket string) bool {
+	for _, b := range r.buckets[scheme] {
+		if b == "*" || b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// endpointURL returns the URL of the object at `<endpoint>/<bucket>/<key>`.
+func endpointURL(endpoint *url.URL, bucket, key string) *url.URL {
+	object := *endpoint
+	object.Path = path.Join("/", object.Path, bucket, key)
+	object.RawPath = ""
+	return &object
+}
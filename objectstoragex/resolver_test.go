@@ -0,0 +1,121 @@
+package objectstoragex
+
+import (
+	"context"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func mapEnv(env map[string]string) func(string) string {
+	return func(key string) string {
+		return env[key]
+	}
+}
+
+func TestResolver(t *testing.T) {
+	ctx := context.Background()
+	endpoint, err := url.Parse("http://127.0.0.1:9000/prefix")
+	require.NoError(t, err)
+
+	t.Run("case=s3", func(t *testing.T) {
+		r := NewResolver(WithBuckets("s3", "bucket"), WithEndpoint("s3", endpoint), WithEnv(mapEnv(nil)))
+		req, err := r.NewRequest(ctx, "s3://bucket/path/to/schema.json")
+		require.NoError(t, err)
+		assert.Equal(t, "GET", req.Method)
+		assert.Equal(t, "/prefix/bucket/path/to/schema.json", req.URL.Path)
+		assert.Empty(t, req.Header.Get("Authorization"))
+
+		r = NewResolver(WithBuckets("s3", "bucket"), WithEndpoint("s3", endpoint), WithEnv(mapEnv(map[string]string{
+			"AWS_ACCESS_KEY_ID":     "id",
+			"AWS_SECRET_ACCESS_KEY": "secret",
+			"AWS_SESSION_TOKEN":     "token",
+			"AWS_REGION":            "eu-central-1",
+		})))
+		req, err = r.NewRequest(ctx, "s3://bucket/path/with%20space.json")
+		require.NoError(t, err)
+		assert.Equal(t, "/prefix/bucket/path/with space.json", req.URL.Path)
+		assert.Contains(t, req.Header.Get("Authorization"), "Credential=id/")
+		assert.Contains(t, req.Header.Get("Authorization"), "/eu-central-1/s3/aws4_request")
+		assert.Equal(t, "token", req.Header.Get("X-Amz-Security-Token"))
+	})
+
+	t.Run("case=gs", func(t *testing.T) {
+		r := NewResolver(WithBuckets("gs", "*"), WithEndpoint("gs", endpoint))
+		req, err := r.NewRequest(ctx, "gs://bucket/schema.json")
+		require.NoError(t, err)
+		assert.Equal(t, "/prefix/bucket/schema.json", req.URL.Path)
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+
+	t.Run("case=azblob", func(t *testing.T) {
+		r := NewResolver(WithBuckets("azblob", "container"), WithEndpoint("azblob", endpoint), WithEnv(mapEnv(map[string]string{
+			"AZURE_STORAGE_ACCOUNT": "account",
+			"AZURE_STORAGE_KEY":     base64.StdEncoding.EncodeToString([]byte("key")),
+		})))
+		req, err := r.NewRequest(ctx, "azblob://container/schema.json")
+		require.NoError(t, err)
+		assert.Equal(t, "/prefix/container/schema.json", req.URL.Path)
+		assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "SharedKeyLite account:"), req.Header.Get("Authorization"))
+		assert.Equal(t, azblobVersion, req.Header.Get("X-Ms-Version"))
+
+		r = NewResolver(WithBuckets("azblob", "container"), WithEndpoint("azblob", endpoint), WithEnv(mapEnv(map[string]string{
+			"AZURE_STORAGE_SAS_TOKEN": "?sv=2020-10-02&sig=abc",
+		})))
+		req, err = r.NewRequest(ctx, "azblob://container/schema.json")
+		require.NoError(t, err)
+		assert.Equal(t, "abc", req.URL.Query().Get("sig"))
+		assert.Empty(t, req.Header.Get("Authorization"))
+
+		_, err = NewResolver(WithBuckets("azblob", "*"), WithEnv(mapEnv(nil))).NewRequest(ctx, "azblob://container/blob")
+		require.Error(t, err)
+	})
+
+	t.Run("case=invalid URL", func(t *testing.T) {
+		_, err := NewResolver(WithBuckets("s3", "*")).NewRequest(ctx, "s3://bucket")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "s3://<bucket>/<key>")
+	})
+
+	t.Run("case=unknown scheme", func(t *testing.T) {
+		_, err := NewResolver().NewRequest(ctx, "https://example.com/a.json")
+		assert.True(t, errors.Is(err, ErrUnknownScheme), "%+v", err)
+	})
+
+	t.Run("case=all buckets are refused by default", func(t *testing.T) {
+		for _, source := range []string{"s3://bucket/a.json", "gs://bucket/a.json", "azblob://container/a.json"} {
+			_, err := NewResolver(WithEndpoint("s3", endpoint)).NewRequest(ctx, source)
+			assert.True(t, errors.Is(err, ErrNotAllowed), "%s: %+v", source, err)
+		}
+	})
+
+	t.Run("case=buckets are allowed explicitly", func(t *testing.T) {
+		r := NewResolver(WithBuckets("s3", "bucket", "other"), WithEndpoint("s3", endpoint))
+		for _, source := range []string{"s3://private/a.json", "gs://bucket/a.json"} {
+			_, err := r.NewRequest(ctx, source)
+			assert.True(t, errors.Is(err, ErrNotAllowed), "%s: %+v", source, err)
+		}
+		for _, source := range []string{"s3://bucket/a.json", "s3://other/a.json"} {
+			_, err := r.NewRequest(ctx, source)
+			require.NoError(t, err)
+		}
+	})
+}
+
+func TestIsObjectStorageURL(t *testing.T) {
+	for in, expected := range map[string]bool{
+		"s3://bucket/key":         true,
+		"gs://bucket/key":         true,
+		"azblob://container/blob": true,
+		"https://example.com/key": false,
+		"file:///etc/config.yaml": false,
+		"s3:/bucket/key":          false,
+	} {
+		assert.Equal(t, expected, IsObjectStorageURL(in), "%s", in)
+	}
+}
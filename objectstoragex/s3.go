@@ -0,0 +1,154 @@
+package objectstoragex
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// emptySHA256 is the hex encoded SHA-256 checksum of an empty payload.
+const emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// s3Storage requests objects from Amazon S3. Requests are signed with AWS Signature Version 4
+// using the credentials of the AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and AWS_SESSION_TOKEN
+// environment variables. Without credentials, only public objects can be requested.
+type s3Storage struct {
+	endpoint *url.URL
+	getenv   func(string) string
+	now      func() time.Time
+}
+
+func (s *s3Storage) region() string {
+	if region := s.getenv("AWS_REGION"); region != "" {
+		return region
+	}
+	if region := s.getenv("AWS_DEFAULT_REGION"); region != "" {
+		return region
+	}
+	return "us-east-1"
+}
+
+func (s *s3Storage) objectURL(bucket, key string) (*url.URL, error) {
+	endpoint := s.endpoint
+	for _, env := range []string{"AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"} {
+		if endpoint != nil {
+			break
+		}
+		if raw := s.getenv(env); raw != "" {
+			u, err := url.Parse(raw)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to parse %s", env)
+			}
+			endpoint = u
+		}
+	}
+
+	var object *url.URL
+	switch {
+	case endpoint != nil:
+		object = endpointURL(endpoint, bucket, key)
+	case strings.Contains(bucket, "."):
+		// the certificate of virtual hosted buckets does not match bucket names with dots
+		object = &url.URL{Scheme: "https", Host: "s3." + s.region() + ".amazonaws.com", Path: "/" + bucket + "/" + key}
+	default:
+		object = &url.URL{Scheme: "https", Host: bucket + ".s3." + s.region() + ".amazonaws.com", Path: "/" + key}
+	}
+
+	// the signature requires the path to be escaped like AWS does
+	object.RawPath = awsEscape(object.Path, false)
+	return object, nil
+}
+
+func (s *s3Storage) authorize(r *http.Request) error {
+	id, secret := s.getenv("AWS_ACCESS_KEY_ID"), s.getenv("AWS_SECRET_ACCESS_KEY")
+	if id == "" || secret == "" {
+		return nil
+	}
+	signAWSv4(r, id, secret, s.getenv("AWS_SESSION_TOKEN"), s.region(), s.now())
+	return nil
+}
+
+// signAWSv4 signs the request without payload for the S3 service.
+func signAWSv4(r *http.Request, accessKeyID, secretAccessKey, sessionToken, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	scope := amzDate[:8] + "/" + region + "/s3/aws4_request"
+
+	r.Header.Set("X-Amz-Date", amzDate)
+	r.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+	if sessionToken != "" {
+		r.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": r.URL.Host}
+	for name, values := range r.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := r.URL.Query()
+	params := make([]string, 0, len(query))
+	for k, values := range query {
+		for _, v := range values {
+			params = append(params, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	sort.Strings(params)
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hashedRequest[:])
+
+	key := []byte("AWS4" + secretAccessKey)
+	for _, part := range []string{amzDate[:8], region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	r.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// awsEscape escapes all but the unreserved characters, and slashes unless escapeSlash is set.
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' && !escapeSlash {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
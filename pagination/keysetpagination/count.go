@@ -0,0 +1,132 @@
+package keysetpagination
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+type (
+	// Accuracy describes how accurate a TotalCount is.
+	Accuracy string
+
+	// TotalCount is the total number of rows of a list.
+	TotalCount struct {
+		// Count is the number of rows.
+		Count int64 `json:"count"`
+
+		// Accuracy describes whether Count is exact, a lower bound, or an estimate.
+		Accuracy Accuracy `json:"accuracy"`
+	}
+
+	// CountStrategy counts the rows of a list query. The query must not be paginated, because
+	// the total count is independent of the page.
+	//
+	// Counting is expensive for large tables, so it is usually done only for the first page, or
+	// only if the client asks for it.
+	CountStrategy func(q *pop.Query, model interface{}) (*TotalCount, error)
+
+	rowCount struct {
+		Count int64 `db:"row_count"`
+	}
+)
+
+const (
+	// AccuracyExact is the accuracy of an exact count.
+	AccuracyExact Accuracy = "exact"
+
+	// AccuracyLowerBound is the accuracy of a count which stopped at its bound, so the list
+	// has at least Count rows.
+	AccuracyLowerBound Accuracy = "lower_bound"
+
+	// AccuracyEstimate is the accuracy of a count estimated by the query planner.
+	AccuracyEstimate Accuracy = "estimate"
+)
+
+const (
+	// HeaderTotalCount is the header of an exact total count.
+	HeaderTotalCount = "X-Total-Count"
+
+	// HeaderTotalCountLowerBound is the header of a total count which is a lower bound.
+	HeaderTotalCountLowerBound = "X-Total-Count-Lower-Bound"
+
+	// HeaderTotalCountEstimate is the header of an estimated total count.
+	HeaderTotalCountEstimate = "X-Total-Count-Estimate"
+)
+
+// ExactCount counts all rows with COUNT(*).
+func ExactCount() CountStrategy {
+	return func(q *pop.Query, model interface{}) (*TotalCount, error) {
+		count, err := q.Count(model)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		return &TotalCount{Count: int64(count), Accuracy: AccuracyExact}, nil
+	}
+}
+
+// BoundedCount counts at most limit rows, which is cheap even for large lists. If there are more
+// rows, the count is limit with AccuracyLowerBound, for example to render "more than 1000".
+func BoundedCount(limit int) CountStrategy {
+	return func(q *pop.Query, model interface{}) (*TotalCount, error) {
+		tmp := pop.Q(q.Connection)
+		q.Clone(tmp)
+		sql, args := tmp.Limit(limit + 1).ToSQL(pop.NewModel(model, q.Connection.Context()))
+
+		var res rowCount
+		if err := q.Connection.Store.GetContext(q.Connection.Context(), &res,
+			fmt.Sprintf("SELECT COUNT(*) AS row_count FROM (%s) a", sql), args...); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		if res.Count > int64(limit) {
+			return &TotalCount{Count: int64(limit), Accuracy: AccuracyLowerBound}, nil
+		}
+		return &TotalCount{Count: res.Count, Accuracy: AccuracyExact}, nil
+	}
+}
+
+// EstimatedCount returns the row count of the table which PostgreSQL keeps for the query planner
+// (pg_class.reltuples). The estimate is updated by VACUUM and ANALYZE, and ignores the conditions
+// of the query, so it is only meaningful for unfiltered lists.
+//
+// For other databases, and for tables which were not analyzed yet, fallback is used.
+func EstimatedCount(fallback CountStrategy) CountStrategy {
+	return func(q *pop.Query, model interface{}) (*TotalCount, error) {
+		if q.Connection.Dialect.Name() != "postgres" {
+			return fallback(q, model)
+		}
+
+		var res struct {
+			Count *float64 `db:"row_count"`
+		}
+		if err := q.Connection.Store.GetContext(q.Connection.Context(), &res,
+			"SELECT (SELECT reltuples FROM pg_class WHERE oid = to_regclass($1)) AS row_count",
+			pop.NewModel(model, q.Connection.Context()).TableName()); err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		// reltuples is -1 if the table was never analyzed, and NULL if it does not exist
+		if res.Count == nil || *res.Count < 0 {
+			return fallback(q, model)
+		}
+		return &TotalCount{Count: int64(*res.Count), Accuracy: AccuracyEstimate}, nil
+	}
+}
+
+// Header sets the header of the total count: X-Total-Count if it is exact,
+// X-Total-Count-Lower-Bound if it is a lower bound, and X-Total-Count-Estimate if it is an
+// estimate. Clients relying on X-Total-Count are therefore never misled by an inexact count.
+func (c *TotalCount) Header(w http.ResponseWriter) {
+	name := HeaderTotalCount
+	switch c.Accuracy {
+	case AccuracyLowerBound:
+		name = HeaderTotalCountLowerBound
+	case AccuracyEstimate:
+		name = HeaderTotalCountEstimate
+	}
+	w.Header().Set(name, strconv.FormatInt(c.Count, 10))
+}
@@ -0,0 +1,77 @@
+//go:build sqlite
+// +build sqlite
+
+package keysetpagination
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountSQLite(t *testing.T) {
+	c, err := pop.NewConnection(&pop.ConnectionDetails{URL: "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite")})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+	t.Cleanup(func() { _ = c.Close() })
+
+	require.NoError(t, c.RawQuery("CREATE TABLE items (id TEXT PRIMARY KEY, created_at DATETIME NOT NULL)").Exec())
+	for i := 0; i < 20; i++ {
+		require.NoError(t, c.RawQuery("INSERT INTO items (id, created_at) VALUES (?, ?)", fmt.Sprintf("%02d", i), time.Now()).Exec())
+	}
+
+	for _, tc := range []struct {
+		name     string
+		strategy CountStrategy
+		query    func() *pop.Query
+		expected TotalCount
+	}{
+		{
+			name:     "exact",
+			strategy: ExactCount(),
+			query:    c.Q,
+			expected: TotalCount{Count: 20, Accuracy: AccuracyExact},
+		},
+		{
+			name:     "exact with condition",
+			strategy: ExactCount(),
+			query:    func() *pop.Query { return c.Where("id < ?", "05") },
+			expected: TotalCount{Count: 5, Accuracy: AccuracyExact},
+		},
+		{
+			name:     "bounded above bound",
+			strategy: BoundedCount(10),
+			query:    c.Q,
+			expected: TotalCount{Count: 10, Accuracy: AccuracyLowerBound},
+		},
+		{
+			name:     "bounded at bound",
+			strategy: BoundedCount(20),
+			query:    c.Q,
+			expected: TotalCount{Count: 20, Accuracy: AccuracyExact},
+		},
+		{
+			name:     "bounded with condition",
+			strategy: BoundedCount(10),
+			query:    func() *pop.Query { return c.Where("id < ?", "05").Order("id DESC") },
+			expected: TotalCount{Count: 5, Accuracy: AccuracyExact},
+		},
+		{
+			name:     "estimate falls back",
+			strategy: EstimatedCount(BoundedCount(10)),
+			query:    c.Q,
+			expected: TotalCount{Count: 10, Accuracy: AccuracyLowerBound},
+		},
+	} {
+		t.Run("strategy="+tc.name, func(t *testing.T) {
+			actual, err := tc.strategy(tc.query(), &[]item{})
+			require.NoError(t, err)
+			assert.Equal(t, &tc.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package keysetpagination
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTotalCountHeader(t *testing.T) {
+	for _, tc := range []struct {
+		count    TotalCount
+		expected string
+	}{
+		{count: TotalCount{Count: 10, Accuracy: AccuracyExact}, expected: HeaderTotalCount},
+		{count: TotalCount{Count: 10}, expected: HeaderTotalCount},
+		{count: TotalCount{Count: 10, Accuracy: AccuracyLowerBound}, expected: HeaderTotalCountLowerBound},
+		{count: TotalCount{Count: 10, Accuracy: AccuracyEstimate}, expected: HeaderTotalCountEstimate},
+	} {
+		t.Run("accuracy="+string(tc.count.Accuracy), func(t *testing.T) {
+			w := httptest.NewRecorder()
+			tc.count.Header(w)
+			assert.Len(t, w.Header(), 1)
+			assert.Equal(t, "10", w.Header().Get(tc.expected))
+		})
+	}
+}
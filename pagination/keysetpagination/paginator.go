@@ -0,0 +1,202 @@
+// Package keysetpagination implements keyset pagination, also known as seek pagination. Instead
+// of skipping rows with an offset, a page starts after the sort key of the last row of the
+// previous page, which is efficient with an index on the sort key and stable while rows are
+// inserted.
+package keysetpagination
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/dbal"
+)
+
+type (
+	// Order is the sort order of a column.
+	Order string
+
+	// Column is a column of the sort key.
+	Column struct {
+		// Name is the name of the column, optionally qualified with the table name.
+		Name string
+
+		// Order is the sort order of the column. Defaults to OrderAscending.
+		Order Order
+	}
+
+	// Paginator builds the SQL for keyset pagination with a composite sort key, for example
+	// `created_at DESC, id ASC`. The sort key must be unique, which is usually achieved by
+	// adding the primary key as the last column, and its columns must not be NULL.
+	Paginator struct {
+		columns []Column
+	}
+)
+
+const (
+	OrderAscending  Order = "ASC"
+	OrderDescending Order = "DESC"
+)
+
+var identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// NewPaginator returns a Paginator for the sort key.
+func NewPaginator(columns ...Column) (*Paginator, error) {
+	if len(columns) == 0 {
+		return nil, errors.New("the sort key must have at least one column")
+	}
+
+	p := &Paginator{columns: make([]Column, len(columns))}
+	for k, c := range columns {
+		if !identifier.MatchString(c.Name) {
+			return nil, errors.Errorf("the sort key column %q is not a valid identifier", c.Name)
+		}
+		switch o := Order(strings.ToUpper(string(c.Order))); o {
+		case "":
+			c.Order = OrderAscending
+		case OrderAscending, OrderDescending:
+			c.Order = o
+		default:
+			return nil, errors.Errorf("the sort key column %q has the unknown order %q", c.Name, c.Order)
+		}
+		p.columns[k] = c
+	}
+	return p, nil
+}
+
+// ParseColumns parses a sort key like `created_at DESC, id`.
+func ParseColumns(sortKey string) ([]Column, error) {
+	var columns []Column
+	for _, part := range strings.Split(sortKey, ",") {
+		fields := strings.Fields(part)
+		switch len(fields) {
+		case 1:
+			columns = append(columns, Column{Name: fields[0]})
+		case 2:
+			columns = append(columns, Column{Name: fields[0], Order: Order(fields[1])})
+		default:
+			return nil, errors.Errorf("unable to parse the sort key column %q", strings.TrimSpace(part))
+		}
+	}
+	return columns, nil
+}
+
+// Columns returns the columns of the sort key.
+func (p *Paginator) Columns() []Column {
+	return append([]Column(nil), p.columns...)
+}
+
+// quote quotes the identifier for the dialect, for example "postgres", "cockroach", "mysql", or
+// "sqlite3".
+func quote(dialect, name string) string {
+	q := `"`
+	if dbal.Canonicalize(dialect) == dbal.DriverMySQL {
+		q = "`"
+	}
+
+	parts := strings.Split(name, ".")
+	for k, part := range parts {
+		parts[k] = q + part + q
+	}
+	return strings.Join(parts, ".")
+}
+
+// OrderBy returns the ORDER BY clause of the sort key without the ORDER BY keywords.
+func (p *Paginator) OrderBy(dialect string) string {
+	clauses := make([]string, len(p.columns))
+	for k, c := range p.columns {
+		clauses[k] = quote(dialect, c.Name) + " " + string(c.Order)
+	}
+	return strings.Join(clauses, ", ")
+}
+
+func (c Column) operator() string {
+	if c.Order == OrderDescending {
+		return "<"
+	}
+	return ">"
+}
+
+// uniformOrder returns true if all columns have the same order.
+func (p *Paginator) uniformOrder() bool {
+	for _, c := range p.columns[1:] {
+		if c.Order != p.columns[0].Order {
+			return false
+		}
+	}
+	return true
+}
+
+// Where returns the condition selecting the rows after the keyset, which are the values of the
+// sort key columns of the last row of the previous page, and its arguments. The condition uses
+// `?` placeholders.
+//
+// If all columns have the same order, the condition is a row value comparison like
+// `("created_at", "id") > (?, ?)`, which all supported databases can answer using an index on the
+// sort key. Otherwise, it is expanded to
+// `("created_at" < ?) OR ("created_at" = ? AND "id" > ?)`.
+func (p *Paginator) Where(dialect string, keyset []interface{}) (string, []interface{}, error) {
+	if len(keyset) != len(p.columns) {
+		return "", nil, errors.Errorf("expected a keyset with %d values but got %d", len(p.columns), len(keyset))
+	}
+
+	if len(p.columns) == 1 {
+		return fmt.Sprintf("%s %s ?", quote(dialect, p.columns[0].Name), p.columns[0].operator()), keyset, nil
+	}
+
+	if p.uniformOrder() {
+		names := make([]string, len(p.columns))
+		for k, c := range p.columns {
+			names[k] = quote(dialect, c.Name)
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.columns)), ", ")
+		return fmt.Sprintf("(%s) %s (%s)", strings.Join(names, ", "), p.columns[0].operator(), placeholders), keyset, nil
+	}
+
+	var (
+		disjunction []string
+		args        []interface{}
+	)
+	for k, c := range p.columns {
+		conjunction := make([]string, 0, k+1)
+		for _, prev := range p.columns[:k] {
+			conjunction = append(conjunction, quote(dialect, prev.Name)+" = ?")
+		}
+		conjunction = append(conjunction, fmt.Sprintf("%s %s ?", quote(dialect, c.Name), c.operator()))
+
+		disjunction = append(disjunction, "("+strings.Join(conjunction, " AND ")+")")
+		args = append(args, keyset[:k+1]...)
+	}
+	return "(" + strings.Join(disjunction, " OR ") + ")", args, nil
+}
+
+// Keyset returns the keyset values in the order of the sort key columns, for example from the
+// keyset of a page token. It returns an error if a column is missing.
+func (p *Paginator) Keyset(values map[string]string) ([]interface{}, error) {
+	keyset := make([]interface{}, len(p.columns))
+	for k, c := range p.columns {
+		v, ok := values[c.Name]
+		if !ok {
+			return nil, errors.Errorf("the keyset has no value for the sort key column %q", c.Name)
+		}
+		keyset[k] = v
+	}
+	return keyset, nil
+}
+
+// Paginate orders the query by the sort key, limits it to pageSize rows, and selects only rows
+// after the keyset unless it is empty, which selects the first page.
+func (p *Paginator) Paginate(q *pop.Query, keyset []interface{}, pageSize int) (*pop.Query, error) {
+	dialect := q.Connection.Dialect.Name()
+	if len(keyset) > 0 {
+		where, args, err := p.Where(dialect, keyset)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(where, args...)
+	}
+	return q.Order(p.OrderBy(dialect)).Limit(pageSize), nil
+}
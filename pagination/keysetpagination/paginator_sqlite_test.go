@@ -0,0 +1,74 @@
+//go:build sqlite
+// +build sqlite
+
+package keysetpagination
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type item struct {
+	ID        string    `db:"id"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (item) TableName() string {
+	return "items"
+}
+
+func TestPaginateSQLite(t *testing.T) {
+	c, err := pop.NewConnection(&pop.ConnectionDetails{URL: "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite")})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+	t.Cleanup(func() { _ = c.Close() })
+
+	require.NoError(t, c.RawQuery("CREATE TABLE items (id TEXT PRIMARY KEY, created_at DATETIME NOT NULL)").Exec())
+
+	// several items share a creation time, so that the second column is needed
+	start := time.Date(2021, 11, 1, 0, 0, 0, 0, time.UTC)
+	var expected []string
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 3; j++ {
+			id := fmt.Sprintf("%d-%d", i, j)
+			require.NoError(t, c.RawQuery("INSERT INTO items (id, created_at) VALUES (?, ?)", id, start.Add(time.Duration(i)*time.Hour)).Exec())
+		}
+	}
+	for i := 4; i >= 0; i-- {
+		for j := 0; j < 3; j++ {
+			expected = append(expected, fmt.Sprintf("%d-%d", i, j))
+		}
+	}
+
+	p, err := NewPaginator(Column{Name: "created_at", Order: OrderDescending}, Column{Name: "id"})
+	require.NoError(t, err)
+
+	var (
+		actual []string
+		keyset []interface{}
+	)
+	for page := 0; ; page++ {
+		require.Less(t, page, 10, "pagination does not terminate")
+
+		q, err := p.Paginate(c.Q(), keyset, 4)
+		require.NoError(t, err)
+		var items []item
+		require.NoError(t, q.All(&items))
+		if len(items) == 0 {
+			break
+		}
+
+		for _, i := range items {
+			actual = append(actual, i.ID)
+		}
+		last := items[len(items)-1]
+		keyset = []interface{}{last.CreatedAt, last.ID}
+	}
+	assert.Equal(t, expected, actual)
+}
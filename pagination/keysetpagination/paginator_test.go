@@ -0,0 +1,114 @@
+package keysetpagination
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPaginator(t *testing.T) {
+	p, err := NewPaginator(Column{Name: "created_at", Order: "desc"}, Column{Name: "items.id"})
+	require.NoError(t, err)
+	assert.Equal(t, []Column{{Name: "created_at", Order: OrderDescending}, {Name: "items.id", Order: OrderAscending}}, p.Columns())
+
+	for _, columns := range [][]Column{
+		nil,
+		{{Name: "id; DROP TABLE items"}},
+		{{Name: `"id"`}},
+		{{Name: "id", Order: "random"}},
+	} {
+		_, err := NewPaginator(columns...)
+		assert.Error(t, err, "%+v", columns)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("created_at DESC, id")
+	require.NoError(t, err)
+	assert.Equal(t, []Column{{Name: "created_at", Order: OrderDescending}, {Name: "id"}}, columns)
+
+	for _, sortKey := range []string{"", "created_at DESC,", "created_at DESC NULLS LAST"} {
+		_, err := ParseColumns(sortKey)
+		assert.Error(t, err, sortKey)
+	}
+}
+
+func TestPaginator(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		columns []Column
+		dialect string
+		orderBy string
+		where   string
+		args    []interface{}
+	}{
+		{
+			name:    "single column",
+			columns: []Column{{Name: "id"}},
+			dialect: "postgres",
+			orderBy: `"id" ASC`,
+			where:   `"id" > ?`,
+			args:    []interface{}{"a"},
+		},
+		{
+			name:    "single descending column",
+			columns: []Column{{Name: "id", Order: OrderDescending}},
+			dialect: "mysql",
+			orderBy: "`id` DESC",
+			where:   "`id` < ?",
+			args:    []interface{}{"a"},
+		},
+		{
+			name:    "uniform order",
+			columns: []Column{{Name: "created_at", Order: OrderDescending}, {Name: "id", Order: OrderDescending}},
+			dialect: "cockroach",
+			orderBy: `"created_at" DESC, "id" DESC`,
+			where:   `("created_at", "id") < (?, ?)`,
+			args:    []interface{}{"a", "b"},
+		},
+		{
+			name:    "mixed order",
+			columns: []Column{{Name: "created_at", Order: OrderDescending}, {Name: "id"}},
+			dialect: "sqlite3",
+			orderBy: `"created_at" DESC, "id" ASC`,
+			where:   `(("created_at" < ?) OR ("created_at" = ? AND "id" > ?))`,
+			args:    []interface{}{"a", "a", "b"},
+		},
+		{
+			name:    "mixed order with three columns",
+			columns: []Column{{Name: "i.nid"}, {Name: "i.created_at", Order: OrderDescending}, {Name: "i.id"}},
+			dialect: "mysql",
+			orderBy: "`i`.`nid` ASC, `i`.`created_at` DESC, `i`.`id` ASC",
+			where:   "((`i`.`nid` > ?) OR (`i`.`nid` = ? AND `i`.`created_at` < ?) OR (`i`.`nid` = ? AND `i`.`created_at` = ? AND `i`.`id` > ?))",
+			args:    []interface{}{"a", "a", "b", "a", "b", "c"},
+		},
+	} {
+		t.Run("case="+tc.name, func(t *testing.T) {
+			p, err := NewPaginator(tc.columns...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.orderBy, p.OrderBy(tc.dialect))
+
+			keyset := []interface{}{"a", "b", "c"}[:len(tc.columns)]
+			where, args, err := p.Where(tc.dialect, keyset)
+			require.NoError(t, err)
+			assert.Equal(t, tc.where, where)
+			assert.Equal(t, tc.args, args)
+		})
+	}
+
+	t.Run("case=keyset", func(t *testing.T) {
+		p, err := NewPaginator(Column{Name: "created_at", Order: OrderDescending}, Column{Name: "id"})
+		require.NoError(t, err)
+
+		keyset, err := p.Keyset(map[string]string{"id": "b", "created_at": "a"})
+		require.NoError(t, err)
+		assert.Equal(t, []interface{}{"a", "b"}, keyset)
+
+		_, err = p.Keyset(map[string]string{"id": "b"})
+		assert.Error(t, err)
+
+		_, _, err = p.Where("postgres", []interface{}{"a"})
+		assert.Error(t, err)
+	})
+}
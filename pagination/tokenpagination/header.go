@@ -0,0 +1,39 @@
+package tokenpagination
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+func link(u *url.URL, rel, token string, pageSize int) string {
+	q := u.Query()
+	q.Set(PageSizeParameter, strconv.Itoa(pageSize))
+	if token == "" {
+		q.Del(PageTokenParameter)
+	} else {
+		q.Set(PageTokenParameter, token)
+	}
+
+	l := *u
+	l.RawQuery = q.Encode()
+	return fmt.Sprintf("<%s>; rel=\"%s\"", l.String(), rel)
+}
+
+// Header sets the Link header (RFC 5988) with the "first" page and, if next is not nil, the
+// "next" page of the list at u. The page token of next is encoded with the codec.
+func (c *Codec) Header(w http.ResponseWriter, u *url.URL, current, next *Token) error {
+	links := []string{link(u, "first", "", current.PageSize)}
+	if next != nil {
+		token, err := c.Encode(next)
+		if err != nil {
+			return err
+		}
+		links = append(links, link(u, "next", token, next.PageSize))
+	}
+
+	w.Header().Set("Link", strings.Join(links, ","))
+	return nil
+}
@@ -0,0 +1,43 @@
+package tokenpagination
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeader(t *testing.T) {
+	c := newCodec(t, [][]byte{secret})
+	u, err := url.Parse("http://example.com/items?state=active&page_token=old")
+	require.NoError(t, err)
+	current := &Token{PageSize: 10, Filters: url.Values{"state": {"active"}}}
+
+	t.Run("case=last page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		require.NoError(t, c.Header(w, u, current, nil))
+		assert.Equal(t, `<http://example.com/items?page_size=10&state=active>; rel="first"`, w.Header().Get("Link"))
+	})
+
+	t.Run("case=next page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		require.NoError(t, c.Header(w, u, current, current.Next(map[string]string{"id": "10"})))
+
+		matches := regexp.MustCompile(`^<http://example.com/items\?page_size=10&state=active>; rel="first",<(http://example.com/items\?[^>]+)>; rel="next"$`).
+			FindStringSubmatch(w.Header().Get("Link"))
+		require.Len(t, matches, 2, w.Header().Get("Link"))
+
+		next, err := url.Parse(matches[1])
+		require.NoError(t, err)
+		token, err := c.Decode(next.Query().Get(PageTokenParameter))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"id": "10"}, token.Keyset)
+		assert.Equal(t, "active", next.Query().Get("state"))
+
+		// the URL is not modified
+		assert.Equal(t, "old", u.Query().Get(PageTokenParameter))
+	})
+}
@@ -0,0 +1,51 @@
+package tokenpagination
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// PageTokenParameter is the query parameter of the page token.
+	PageTokenParameter = "page_token"
+
+	// PageSizeParameter is the query parameter of the page size.
+	PageSizeParameter = "page_size"
+)
+
+// Parse returns the token of the page requested with the page_token and page_size query
+// parameters. Without a page token, it returns the token of the first page with the filters.
+// Otherwise, the filters must match the filters of the page token, because the keyset position
+// is meaningless for other filters.
+//
+// The page size defaults to the page size of the page token or the default page size of the
+// codec, and is capped at the maximum page size. Invalid page sizes are ignored.
+func (c *Codec) Parse(r *http.Request, filters url.Values) (*Token, error) {
+	q := r.URL.Query()
+
+	t := &Token{PageSize: c.defaultPageSize, Filters: filters}
+	if raw := q.Get(PageTokenParameter); raw != "" {
+		var err error
+		t, err = c.Decode(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !t.MatchesFilters(filters) {
+			return nil, errors.WithStack(ErrFiltersChanged)
+		}
+	}
+
+	if size, err := strconv.Atoi(q.Get(PageSizeParameter)); err == nil && size > 0 {
+		t.PageSize = size
+	}
+	if t.PageSize > c.maxPageSize {
+		t.PageSize = c.maxPageSize
+	}
+	if t.PageSize < 1 {
+		t.PageSize = 1
+	}
+	return t, nil
+}
@@ -0,0 +1,64 @@
+package tokenpagination
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	c := newCodec(t, [][]byte{secret}, WithPageSize(10, 50))
+	filters := url.Values{"state": {"active"}}
+
+	request := func(query url.Values) *http.Request {
+		return &http.Request{URL: &url.URL{Path: "/items", RawQuery: query.Encode()}}
+	}
+
+	t.Run("case=first page", func(t *testing.T) {
+		actual, err := c.Parse(request(url.Values{}), filters)
+		require.NoError(t, err)
+		assert.Equal(t, &Token{PageSize: 10, Filters: filters}, actual)
+	})
+
+	for _, tc := range []struct {
+		size     string
+		expected int
+	}{
+		{size: "20", expected: 20},
+		{size: "1000", expected: 50},
+		{size: "-1", expected: 10},
+		{size: "foo", expected: 10},
+	} {
+		t.Run("case=page size "+tc.size, func(t *testing.T) {
+			actual, err := c.Parse(request(url.Values{PageSizeParameter: {tc.size}}), filters)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual.PageSize)
+		})
+	}
+
+	t.Run("case=next page", func(t *testing.T) {
+		token, err := c.Encode(&Token{Keyset: map[string]string{"id": "10"}, PageSize: 20, Filters: filters})
+		require.NoError(t, err)
+
+		actual, err := c.Parse(request(url.Values{PageTokenParameter: {token}}), filters)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"id": "10"}, actual.Keyset)
+		assert.Equal(t, 20, actual.PageSize)
+
+		actual, err = c.Parse(request(url.Values{PageTokenParameter: {token}, PageSizeParameter: {"5"}}), filters)
+		require.NoError(t, err)
+		assert.Equal(t, 5, actual.PageSize)
+
+		_, err = c.Parse(request(url.Values{PageTokenParameter: {token}}), url.Values{"state": {"inactive"}})
+		assert.True(t, errors.Is(err, ErrFiltersChanged), "%+v", err)
+	})
+
+	t.Run("case=invalid token", func(t *testing.T) {
+		_, err := c.Parse(request(url.Values{PageTokenParameter: {"foo"}}), filters)
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+}
@@ -0,0 +1,276 @@
+// Package tokenpagination implements pagination using opaque page tokens. A page token encodes
+// the keyset position, the page size, and the filters of a list request. Tokens are signed, so
+// that clients can not tamper with them, and expire. Optionally, tokens are encrypted, so that
+// clients can not read them either.
+package tokenpagination
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	versionSigned    byte = 1
+	versionEncrypted byte = 2
+
+	macSize = sha256.Size
+)
+
+var (
+	// ErrInvalidToken is returned if a page token is malformed or its signature is invalid.
+	ErrInvalidToken = errors.New("the page token is invalid")
+
+	// ErrTokenExpired is returned if a page token expired.
+	ErrTokenExpired = errors.New("the page token expired")
+
+	// ErrFiltersChanged is returned by Parse if the filters of the request differ from the
+	// filters the page token was issued for.
+	ErrFiltersChanged = errors.New("the filters of the request do not match the page token")
+)
+
+type (
+	// Token is the content of a page token.
+	Token struct {
+		// Keyset is the position of the page, usually the sort key values of the last item of
+		// the previous page by column. It is empty for the first page.
+		Keyset map[string]string `json:"k,omitempty"`
+
+		// PageSize is the number of items per page.
+		PageSize int `json:"s"`
+
+		// Filters are the filters of the list request.
+		Filters url.Values `json:"f,omitempty"`
+
+		// ExpiresAt is when the token expires. It is set by Codec.Encode if it is zero.
+		ExpiresAt time.Time `json:"-"`
+	}
+
+	// tokenPayload is the encoded form of Token, with the expiry as Unix time to keep tokens
+	// short.
+	tokenPayload struct {
+		*Token
+		ExpiresAt int64 `json:"e"`
+	}
+
+	// Codec encodes and decodes page tokens.
+	Codec struct {
+		secrets         [][]byte
+		encryptionKeys  [][]byte
+		aeads           []cipher.AEAD
+		ttl             time.Duration
+		defaultPageSize int
+		maxPageSize     int
+		now             func() time.Time
+	}
+
+	// CodecOption configures a Codec.
+	CodecOption func(*Codec)
+)
+
+// WithTTL sets how long page tokens are valid. Defaults to one hour.
+func WithTTL(ttl time.Duration) CodecOption {
+	return func(c *Codec) {
+		c.ttl = ttl
+	}
+}
+
+// WithPageSize sets the default and the maximum page size used by Parse. Defaults to 100 and
+// 1000.
+func WithPageSize(defaultPageSize, maxPageSize int) CodecOption {
+	return func(c *Codec) {
+		c.defaultPageSize = defaultPageSize
+		c.maxPageSize = maxPageSize
+	}
+}
+
+// WithEncryption encrypts page tokens with AES-GCM using the first key, so that clients can not
+// read the filters and internal IDs of the keyset position. Page tokens encrypted with any of
+// the keys are accepted, so that keys can be rotated without invalidating the page tokens of
+// ongoing pagination. Keys must be 16, 24, or 32 bytes long to select AES-128, AES-192, or
+// AES-256.
+//
+// Page tokens which were only signed are still accepted, so that encryption can be enabled
+// without invalidating them.
+func WithEncryption(keys ...[]byte) CodecOption {
+	return func(c *Codec) {
+		c.encryptionKeys = keys
+	}
+}
+
+// WithClock sets the function returning the current time, which is useful in tests.
+func WithClock(now func() time.Time) CodecOption {
+	return func(c *Codec) {
+		c.now = now
+	}
+}
+
+// NewCodec returns a Codec which signs page tokens with the first secret and accepts page tokens
+// signed with any of the secrets, so that secrets can be rotated without invalidating the page
+// tokens of ongoing pagination.
+func NewCodec(secrets [][]byte, opts ...CodecOption) (*Codec, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one secret is required to sign page tokens")
+	}
+	for _, secret := range secrets {
+		if len(secret) < 16 {
+			return nil, errors.New("page token secrets must be at least 16 bytes long")
+		}
+	}
+
+	c := &Codec{
+		secrets:         secrets,
+		ttl:             time.Hour,
+		defaultPageSize: 100,
+		maxPageSize:     1000,
+		now:             time.Now,
+	}
+	for _, o := range opts {
+		o(c)
+	}
+
+	for _, key := range c.encryptionKeys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to use page token encryption key")
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		c.aeads = append(c.aeads, aead)
+	}
+	return c, nil
+}
+
+// Next returns the token of the page after the given keyset position, with the same page size
+// and filters.
+func (t *Token) Next(keyset map[string]string) *Token {
+	return &Token{
+		Keyset:   keyset,
+		PageSize: t.PageSize,
+		Filters:  t.Filters,
+	}
+}
+
+// IsFirstPage returns true if the token has no keyset position.
+func (t *Token) IsFirstPage() bool {
+	return len(t.Keyset) == 0
+}
+
+// MatchesFilters returns true if the token was issued for the filters.
+func (t *Token) MatchesFilters(filters url.Values) bool {
+	if len(t.Filters) == 0 && len(filters) == 0 {
+		return true
+	}
+	return reflect.DeepEqual(t.Filters, filters)
+}
+
+// Encode returns the opaque page token. If the token has no expiry, it expires after the TTL of
+// the codec.
+func (c *Codec) Encode(t *Token) (string, error) {
+	expiresAt := t.ExpiresAt
+	if expiresAt.IsZero() {
+		expiresAt = c.now().Add(c.ttl)
+	}
+
+	payload, err := json.Marshal(&tokenPayload{Token: t, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	var raw []byte
+	if len(c.aeads) > 0 {
+		aead := c.aeads[0]
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", errors.WithStack(err)
+		}
+		raw = append([]byte{versionEncrypted}, nonce...)
+		// the version is authenticated as additional data
+		raw = aead.Seal(raw, nonce, payload, []byte{versionEncrypted})
+	} else {
+		raw = append([]byte{versionSigned}, payload...)
+		raw = append(raw, sign(c.secrets[0], raw)...)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// Decode verifies and decodes the page token. It returns ErrInvalidToken if the token is
+// malformed or was not signed or encrypted with any of the secrets or keys, and ErrTokenExpired
+// if it expired.
+func (c *Codec) Decode(token string) (*Token, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.WithStack(ErrInvalidToken)
+	}
+
+	var plaintext []byte
+	switch raw[0] {
+	case versionSigned:
+		if len(raw) < 1+macSize {
+			return nil, errors.WithStack(ErrInvalidToken)
+		}
+		signed, mac := raw[:len(raw)-macSize], raw[len(raw)-macSize:]
+		if !c.verify(signed, mac) {
+			return nil, errors.WithStack(ErrInvalidToken)
+		}
+		plaintext = signed[1:]
+	case versionEncrypted:
+		var ok bool
+		if plaintext, ok = c.decrypt(raw); !ok {
+			return nil, errors.WithStack(ErrInvalidToken)
+		}
+	default:
+		return nil, errors.WithStack(ErrInvalidToken)
+	}
+
+	payload := tokenPayload{Token: new(Token)}
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, errors.WithStack(ErrInvalidToken)
+	}
+
+	t := payload.Token
+	t.ExpiresAt = time.Unix(payload.ExpiresAt, 0)
+	if !c.now().Before(t.ExpiresAt) {
+		return nil, errors.WithStack(ErrTokenExpired)
+	}
+	return t, nil
+}
+
+func (c *Codec) decrypt(raw []byte) ([]byte, bool) {
+	for _, aead := range c.aeads {
+		if len(raw) < 1+aead.NonceSize()+aead.Overhead() {
+			continue
+		}
+		nonce, ciphertext := raw[1:1+aead.NonceSize()], raw[1+aead.NonceSize():]
+		if plaintext, err := aead.Open(nil, nonce, ciphertext, raw[:1]); err == nil {
+			return plaintext, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Codec) verify(data, mac []byte) bool {
+	for _, secret := range c.secrets {
+		if hmac.Equal(sign(secret, data), mac) {
+			return true
+		}
+	}
+	return false
+}
+
+func sign(secret, data []byte) []byte {
+	h := hmac.New(sha256.New, secret)
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
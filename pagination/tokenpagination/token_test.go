@@ -0,0 +1,178 @@
+package tokenpagination
+
+import (
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	secret    = []byte("0123456789abcdef0123456789abcdef")
+	oldSecret = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newCodec(t *testing.T, secrets [][]byte, opts ...CodecOption) *Codec {
+	c, err := NewCodec(secrets, opts...)
+	require.NoError(t, err)
+	return c
+}
+
+func TestCodec(t *testing.T) {
+	now := time.Date(2021, 11, 1, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+
+	t.Run("case=round trip", func(t *testing.T) {
+		c := newCodec(t, [][]byte{secret}, WithClock(clock))
+		expected := &Token{
+			Keyset:   map[string]string{"created_at": "2021-11-01T10:00:00Z", "id": "42"},
+			PageSize: 50,
+			Filters:  url.Values{"state": {"active"}},
+		}
+
+		token, err := c.Encode(expected)
+		require.NoError(t, err)
+		actual, err := c.Decode(token)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected.Keyset, actual.Keyset)
+		assert.Equal(t, expected.PageSize, actual.PageSize)
+		assert.Equal(t, expected.Filters, actual.Filters)
+		assert.Equal(t, now.Add(time.Hour).Unix(), actual.ExpiresAt.Unix())
+		assert.False(t, actual.IsFirstPage())
+	})
+
+	t.Run("case=expired", func(t *testing.T) {
+		c := newCodec(t, [][]byte{secret}, WithClock(clock), WithTTL(time.Minute))
+		token, err := c.Encode(&Token{PageSize: 10})
+		require.NoError(t, err)
+
+		later := newCodec(t, [][]byte{secret}, WithClock(func() time.Time { return now.Add(time.Minute) }))
+		_, err = later.Decode(token)
+		assert.True(t, errors.Is(err, ErrTokenExpired), "%+v", err)
+	})
+
+	t.Run("case=tampered", func(t *testing.T) {
+		c := newCodec(t, [][]byte{secret}, WithClock(clock))
+		token, err := c.Encode(&Token{PageSize: 10, Keyset: map[string]string{"id": "1"}})
+		require.NoError(t, err)
+
+		raw, err := base64.RawURLEncoding.DecodeString(token)
+		require.NoError(t, err)
+		for i := range raw {
+			tampered := append([]byte(nil), raw...)
+			tampered[i] ^= 1
+			_, err := c.Decode(base64.RawURLEncoding.EncodeToString(tampered))
+			require.True(t, errors.Is(err, ErrInvalidToken), "byte %d: %+v", i, err)
+		}
+
+		for _, token := range []string{"", "not base64!", base64.RawURLEncoding.EncodeToString([]byte{versionSigned})} {
+			_, err := c.Decode(token)
+			assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+		}
+	})
+
+	t.Run("case=secret rotation", func(t *testing.T) {
+		old := newCodec(t, [][]byte{oldSecret}, WithClock(clock))
+		token, err := old.Encode(&Token{PageSize: 10})
+		require.NoError(t, err)
+
+		_, err = newCodec(t, [][]byte{secret, oldSecret}, WithClock(clock)).Decode(token)
+		assert.NoError(t, err)
+
+		_, err = newCodec(t, [][]byte{secret}, WithClock(clock)).Decode(token)
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+
+	t.Run("case=invalid secrets", func(t *testing.T) {
+		_, err := NewCodec(nil)
+		assert.Error(t, err)
+		_, err = NewCodec([][]byte{[]byte("short")})
+		assert.Error(t, err)
+	})
+}
+
+func TestToken(t *testing.T) {
+	first := &Token{PageSize: 10, Filters: url.Values{"q": {"foo"}}}
+	assert.True(t, first.IsFirstPage())
+
+	next := first.Next(map[string]string{"id": "10"})
+	assert.Equal(t, &Token{Keyset: map[string]string{"id": "10"}, PageSize: 10, Filters: url.Values{"q": {"foo"}}}, next)
+
+	assert.True(t, first.MatchesFilters(url.Values{"q": {"foo"}}))
+	assert.False(t, first.MatchesFilters(url.Values{"q": {"bar"}}))
+	assert.False(t, first.MatchesFilters(nil))
+	assert.True(t, (&Token{}).MatchesFilters(url.Values{}))
+}
+
+func TestEncryption(t *testing.T) {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	oldKey := []byte("fedcba9876543210")
+	expected := &Token{Keyset: map[string]string{"id": "internal-id-42"}, PageSize: 10, Filters: url.Values{"email": {"foo@example.com"}}}
+
+	c := newCodec(t, [][]byte{secret}, WithEncryption(key, oldKey))
+	token, err := c.Encode(expected)
+	require.NoError(t, err)
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	require.NoError(t, err)
+	assert.Equal(t, versionEncrypted, raw[0])
+	assert.NotContains(t, string(raw), "internal-id-42")
+	assert.NotContains(t, string(raw), "foo@example.com")
+
+	actual, err := c.Decode(token)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Keyset, actual.Keyset)
+	assert.Equal(t, expected.Filters, actual.Filters)
+
+	t.Run("case=nonces are random", func(t *testing.T) {
+		other, err := c.Encode(expected)
+		require.NoError(t, err)
+		assert.NotEqual(t, token, other)
+	})
+
+	t.Run("case=tampered", func(t *testing.T) {
+		for i := range raw {
+			tampered := append([]byte(nil), raw...)
+			tampered[i] ^= 1
+			_, err := c.Decode(base64.RawURLEncoding.EncodeToString(tampered))
+			require.True(t, errors.Is(err, ErrInvalidToken), "byte %d: %+v", i, err)
+		}
+		_, err := c.Decode(base64.RawURLEncoding.EncodeToString(raw[:10]))
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+
+	t.Run("case=key rotation", func(t *testing.T) {
+		old := newCodec(t, [][]byte{secret}, WithEncryption(oldKey))
+		token, err := old.Encode(expected)
+		require.NoError(t, err)
+
+		_, err = c.Decode(token)
+		assert.NoError(t, err)
+
+		_, err = newCodec(t, [][]byte{secret}, WithEncryption(key)).Decode(token)
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+
+	t.Run("case=signed tokens are accepted", func(t *testing.T) {
+		token, err := newCodec(t, [][]byte{secret}).Encode(expected)
+		require.NoError(t, err)
+
+		_, err = c.Decode(token)
+		assert.NoError(t, err)
+	})
+
+	t.Run("case=encrypted tokens require the key", func(t *testing.T) {
+		_, err := newCodec(t, [][]byte{secret}).Decode(token)
+		assert.True(t, errors.Is(err, ErrInvalidToken), "%+v", err)
+	})
+
+	t.Run("case=invalid key", func(t *testing.T) {
+		_, err := NewCodec([][]byte{secret}, WithEncryption([]byte("short")))
+		assert.Error(t, err)
+	})
+}
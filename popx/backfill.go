@@ -0,0 +1,168 @@
+package popx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// BackfillBatch processes up to limit rows which come after checkpoint, for example all rows
+// with an ID greater than the checkpoint, and returns the checkpoint of the last processed row
+// and the number of processed rows. The checkpoint is empty for the first batch.
+//
+// The batch runs in its own transaction, which also records the returned checkpoint. If the
+// batch fails, neither its changes nor the checkpoint are committed.
+type BackfillBatch func(ctx context.Context, tx *pop.Connection, checkpoint string, limit int) (next string, processed int, err error)
+
+// Backfill is a long-running data migration which processes the rows of a table in batches,
+// for example to populate a new column. Unlike a schema migration, it does not hold a
+// transaction for its whole duration, and it continues from the last checkpoint if it is run
+// again after it was interrupted.
+type Backfill struct {
+	// Name identifies the backfill and its checkpoint.
+	Name string
+
+	// BatchSize is the maximum number of rows processed in one batch. Defaults to 1000.
+	BatchSize int
+
+	// Interval is the minimum time between the start of two batches, which limits the load
+	// on the database. If zero, batches run back to back.
+	Interval time.Duration
+
+	// Batch processes one batch. The backfill is completed once a batch processes fewer rows
+	// than the batch size.
+	Batch BackfillBatch
+}
+
+// BackfillStatus is the progress of a backfill.
+type BackfillStatus struct {
+	Name       string `json:"name" db:"name"`
+	Checkpoint string `json:"checkpoint" db:"checkpoint"`
+	Processed  int64  `json:"processed" db:"processed_rows"`
+	Completed  bool   `json:"completed" db:"completed"`
+}
+
+func (m *Migrator) backfillTableName(ctx context.Context, c *pop.Connection) string {
+	return m.migrationTableName(ctx, c) + "_backfills"
+}
+
+func (m *Migrator) createBackfillTable(ctx context.Context, c *pop.Connection) error {
+	// #nosec G201 - the table name is derived from the migration table name
+	return errors.Wrap(c.RawQuery(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (name VARCHAR(255) NOT NULL PRIMARY KEY, checkpoint VARCHAR(255) NOT NULL, processed_rows BIGINT NOT NULL, completed BOOLEAN NOT NULL, updated_at TIMESTAMP NOT NULL)",
+		m.backfillTableName(ctx, c))).Exec(), "unable to create the backfill table")
+}
+
+// BackfillStatus returns the progress of the backfill with the given name. If it never ran,
+// the returned status has no checkpoint and is not completed.
+func (m *Migrator) BackfillStatus(ctx context.Context, name string) (*BackfillStatus, error) {
+	c := m.Connection.WithContext(ctx)
+	if err := m.createBackfillTable(ctx, c); err != nil {
+		return nil, err
+	}
+	return m.backfillStatus(ctx, c, name)
+}
+
+func (m *Migrator) backfillStatus(ctx context.Context, c *pop.Connection, name string) (*BackfillStatus, error) {
+	var status BackfillStatus
+	// #nosec G201 - the table name is derived from the migration table name
+	if err := c.RawQuery(fmt.Sprintf("SELECT name, checkpoint, processed_rows, completed FROM %s WHERE name = ?",
+		m.backfillTableName(ctx, c)), name).First(&status); errors.Is(err, sql.ErrNoRows) {
+		return &BackfillStatus{Name: name}, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "unable to load the checkpoint of backfill %s", name)
+	}
+	return &status, nil
+}
+
+// ResetBackfill removes the checkpoint of the backfill with the given name, so that it starts
+// from the beginning the next time it runs.
+func (m *Migrator) ResetBackfill(ctx context.Context, name string) error {
+	c := m.Connection.WithContext(ctx)
+	if err := m.createBackfillTable(ctx, c); err != nil {
+		return err
+	}
+	// #nosec G201 - the table name is derived from the migration table name
+	return errors.Wrapf(c.RawQuery(fmt.Sprintf("DELETE FROM %s WHERE name = ?", m.backfillTableName(ctx, c)), name).Exec(),
+		"unable to reset backfill %s", name)
+}
+
+// RunBackfill runs the backfill until it is completed or the context is canceled. It starts
+// from the last checkpoint and does nothing if the backfill was already completed.
+func (m *Migrator) RunBackfill(ctx context.Context, b Backfill) error {
+	if b.Name == "" || b.Batch == nil {
+		return errors.New("the backfill must have a name and a batch function")
+	}
+	limit := b.BatchSize
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	c := m.Connection.WithContext(ctx)
+	if err := m.createBackfillTable(ctx, c); err != nil {
+		return err
+	}
+	status, err := m.backfillStatus(ctx, c, b.Name)
+	if err != nil {
+		return err
+	}
+	l := m.l.WithField("backfill", b.Name)
+	if status.Completed {
+		l.Debug("The backfill was already completed, skipping.")
+		return nil
+	}
+	if status.Processed > 0 {
+		l.WithField("checkpoint", status.Checkpoint).Infof("Resuming the backfill after %d processed rows.", status.Processed)
+	}
+
+	table := m.backfillTableName(ctx, c)
+	// #nosec G201 - the table name is derived from the migration table name
+	save := fmt.Sprintf("UPDATE %s SET checkpoint = ?, processed_rows = ?, completed = ?, updated_at = ? WHERE name = ?", table)
+	if status.Checkpoint == "" && status.Processed == 0 {
+		// #nosec G201 - the table name is derived from the migration table name
+		if err := c.RawQuery(fmt.Sprintf("DELETE FROM %s WHERE name = ?", table), b.Name).Exec(); err != nil {
+			return errors.Wrapf(err, "unable to initialize the checkpoint of backfill %s", b.Name)
+		}
+		// #nosec G201 - the table name is derived from the migration table name
+		if err := c.RawQuery(fmt.Sprintf("INSERT INTO %s (name, checkpoint, processed_rows, completed, updated_at) VALUES (?, ?, ?, ?, ?)", table),
+			b.Name, "", 0, false, time.Now().UTC()).Exec(); err != nil {
+			return errors.Wrapf(err, "unable to initialize the checkpoint of backfill %s", b.Name)
+		}
+	}
+
+	for {
+		started := time.Now()
+		var processed int
+		if err := c.Transaction(func(tx *pop.Connection) error {
+			next, n, err := b.Batch(ctx, tx, status.Checkpoint, limit)
+			if err != nil {
+				return err
+			}
+			processed = n
+			return errors.Wrapf(tx.RawQuery(save, next, status.Processed+int64(n), n < limit, time.Now().UTC(), b.Name).Exec(),
+				"unable to save the checkpoint of backfill %s", b.Name)
+		}); err != nil {
+			return errors.Wrapf(err, "backfill %s failed after %d processed rows", b.Name, status.Processed)
+		}
+
+		status, err = m.backfillStatus(ctx, c, b.Name)
+		if err != nil {
+			return err
+		}
+		l.WithField("checkpoint", status.Checkpoint).Debugf("Processed a batch of %d rows.", processed)
+		if status.Completed {
+			l.Infof("Completed the backfill after %d processed rows.", status.Processed)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(b.Interval - time.Since(started)):
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package popx_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	. "github.com/ory/x/popx"
+)
+
+func TestBackfill(t *testing.T) {
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+	c := mb.Connection
+
+	require.NoError(t, c.RawQuery("CREATE TABLE backfill_items (id INTEGER PRIMARY KEY, copied TEXT)").Exec())
+	for i := 1; i <= 25; i++ {
+		require.NoError(t, c.RawQuery("INSERT INTO backfill_items (id) VALUES (?)", i).Exec())
+	}
+
+	var batches int
+	fail := errors.New("interrupted")
+	b := Backfill{
+		Name:      "copy_ids",
+		BatchSize: 10,
+		Batch: func(ctx context.Context, tx *pop.Connection, checkpoint string, limit int) (string, int, error) {
+			batches++
+			if batches == 3 {
+				return "", 0, fail
+			}
+
+			after := 0
+			if checkpoint != "" {
+				after, _ = strconv.Atoi(checkpoint)
+			}
+			var ids []int
+			if err := tx.RawQuery("SELECT id FROM backfill_items WHERE id > ? ORDER BY id LIMIT ?", after, limit).All(&ids); err != nil {
+				return "", 0, err
+			}
+			for _, id := range ids {
+				if err := tx.RawQuery("UPDATE backfill_items SET copied = ? WHERE id = ?", strconv.Itoa(id), id).Exec(); err != nil {
+					return "", 0, err
+				}
+			}
+			if len(ids) == 0 {
+				return checkpoint, 0, nil
+			}
+			return strconv.Itoa(ids[len(ids)-1]), len(ids), nil
+		},
+	}
+
+	err := mb.RunBackfill(ctx, b)
+	require.ErrorIs(t, err, fail)
+
+	status, err := mb.BackfillStatus(ctx, b.Name)
+	require.NoError(t, err)
+	assert.Equal(t, &BackfillStatus{Name: b.Name, Checkpoint: "20", Processed: 20}, status)
+
+	require.NoError(t, mb.RunBackfill(ctx, b))
+	status, err = mb.BackfillStatus(ctx, b.Name)
+	require.NoError(t, err)
+	assert.Equal(t, &BackfillStatus{Name: b.Name, Checkpoint: "25", Processed: 25, Completed: true}, status)
+
+	var missing int
+	require.NoError(t, c.RawQuery("SELECT COUNT(*) FROM backfill_items WHERE copied IS NULL OR copied != CAST(id AS TEXT)").First(&missing))
+	assert.Zero(t, missing)
+
+	t.Run("case=completed backfills are skipped", func(t *testing.T) {
+		before := batches
+		require.NoError(t, mb.RunBackfill(ctx, b))
+		assert.Equal(t, before, batches)
+	})
+
+	t.Run("case=reset backfills start over", func(t *testing.T) {
+		require.NoError(t, mb.ResetBackfill(ctx, b.Name))
+		status, err := mb.BackfillStatus(ctx, b.Name)
+		require.NoError(t, err)
+		assert.Equal(t, &BackfillStatus{Name: b.Name}, status)
+
+		before := batches
+		require.NoError(t, mb.RunBackfill(ctx, b))
+		assert.Equal(t, before+3, batches)
+	})
+
+	t.Run("case=canceled backfills keep the last checkpoint", func(t *testing.T) {
+		require.NoError(t, mb.ResetBackfill(ctx, b.Name))
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		cb := b
+		cb.Batch = func(ctx context.Context, tx *pop.Connection, checkpoint string, limit int) (string, int, error) {
+			if checkpoint != "" {
+				// The batch which is running while the context is canceled is rolled back.
+				cancel()
+				return "20", limit, nil
+			}
+			return "10", limit, nil
+		}
+		require.Error(t, mb.RunBackfill(ctx, cb))
+
+		status, err := mb.BackfillStatus(context.Background(), b.Name)
+		require.NoError(t, err)
+		assert.Equal(t, &BackfillStatus{Name: b.Name, Checkpoint: "10", Processed: 10}, status)
+	})
+}
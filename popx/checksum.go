@@ -0,0 +1,148 @@
+package popx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// ErrChecksumMismatch is returned if the content of a migration changed after it was applied.
+var ErrChecksumMismatch = errors.New("the content of an applied migration changed since it was applied")
+
+func (m *Migrator) checksumTableName(ctx context.Context, c *pop.Connection) string {
+	return m.migrationTableName(ctx, c) + "_checksums"
+}
+
+func (m *Migrator) createChecksumTable(ctx context.Context, c *pop.Connection) error {
+	// #nosec G201 - the table name is derived from the migration table name
+	return errors.Wrap(c.RawQuery(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version VARCHAR(48) NOT NULL PRIMARY KEY, checksum VARCHAR(64) NOT NULL)",
+		m.checksumTableName(ctx, c))).Exec(), "unable to create the migration checksum table")
+}
+
+// migrationChecksum returns the SHA-256 checksum of the migration's SQL, or an empty string if
+// the SQL is not known in advance.
+func migrationChecksum(mi Migration, c *pop.Connection) (string, error) {
+	if mi.Content == nil {
+		return "", nil
+	}
+	content, err := mi.Content(mi, c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (m *Migrator) recordChecksum(ctx context.Context, c *pop.Connection, tx *pop.Tx, mi Migration) error {
+	sum, err := migrationChecksum(mi, c)
+	if err != nil || sum == "" {
+		return err
+	}
+
+	table := m.checksumTableName(ctx, c)
+	// #nosec G201 - the table name is derived from the migration table name
+	if _, err := tx.Exec(tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", table)), mi.Version); err != nil {
+		return errors.Wrapf(err, "problem deleting the checksum of migration version %s", mi.Version)
+	}
+	// #nosec G201 - the table name is derived from the migration table name
+	if _, err := tx.Exec(tx.Rebind(fmt.Sprintf("INSERT INTO %s (version, checksum) VALUES (?, ?)", table)), mi.Version, sum); err != nil {
+		return errors.Wrapf(err, "problem inserting the checksum of migration version %s", mi.Version)
+	}
+	return nil
+}
+
+func (m *Migrator) deleteChecksum(ctx context.Context, c *pop.Connection, tx *pop.Tx, mi Migration) error {
+	// #nosec G201 - the table name is derived from the migration table name
+	_, err := tx.Exec(tx.Rebind(fmt.Sprintf("DELETE FROM %s WHERE version = ?", m.checksumTableName(ctx, c))), mi.Version)
+	return errors.Wrapf(err, "problem deleting the checksum of migration version %s", mi.Version)
+}
+
+// VerifyChecksums returns ErrChecksumMismatch if the SQL of an applied migration changed since
+// it was applied. Migrations applied before checksums were recorded are not verified.
+func (m *Migrator) VerifyChecksums(ctx context.Context) error {
+	c := m.Connection.WithContext(ctx)
+	changed, err := m.changedMigrations(ctx, c)
+	if err != nil {
+		return err
+	}
+	if len(changed) > 0 {
+		return errors.Wrapf(ErrChecksumMismatch, "migrations %s", strings.Join(changed.versions(), ", "))
+	}
+	return nil
+}
+
+type checksumRow struct {
+	Version  string `db:"version"`
+	Checksum string `db:"checksum"`
+}
+
+func (m *Migrator) changedMigrations(ctx context.Context, c *pop.Connection) (Migrations, error) {
+	var rows []checksumRow
+	// #nosec G201 - the table name is derived from the migration table name
+	if err := c.Store.Select(&rows, fmt.Sprintf("SELECT version, checksum FROM %s", m.checksumTableName(ctx, c))); err != nil {
+		if errIsTableNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "unable to load the migration checksums")
+	}
+
+	recorded := make(map[string]string, len(rows))
+	for _, r := range rows {
+		recorded[r.Version] = r.Checksum
+	}
+
+	var changed Migrations
+	for _, mi := range m.Migrations["up"].SortAndFilter(c.Dialect.Name()) {
+		expected, ok := recorded[mi.Version]
+		if !ok {
+			continue
+		}
+		sum, err := migrationChecksum(mi, c)
+		if err != nil {
+			return nil, err
+		}
+		if sum != "" && sum != expected {
+			changed = append(changed, mi)
+		}
+	}
+	return changed, nil
+}
+
+// verifyChecksums fails if applied migrations changed, unless IgnoreChecksumMismatch is set. In
+// that case, the new checksums are recorded.
+func (m *Migrator) verifyChecksums(ctx context.Context, c *pop.Connection) error {
+	changed, err := m.changedMigrations(ctx, c)
+	if err != nil || len(changed) == 0 {
+		return err
+	}
+
+	if !m.IgnoreChecksumMismatch {
+		return errors.Wrapf(ErrChecksumMismatch,
+			"migrations %s were changed after they were applied, restore their original content or explicitly ignore the change",
+			strings.Join(changed.versions(), ", "))
+	}
+
+	for _, mi := range changed {
+		m.l.WithField("version", mi.Version).Warn("The migration was changed after it was applied. Ignoring the change as requested and recording the new checksum.")
+		if err := m.isolatedTransaction(ctx, "checksum", func(tx *pop.Tx) error {
+			return m.recordChecksum(ctx, c, tx, mi)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (mfs Migrations) versions() []string {
+	versions := make([]string, len(mfs))
+	for k, mi := range mfs {
+		versions[k] = mi.Version
+	}
+	return versions
+}
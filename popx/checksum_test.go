@@ -0,0 +1,129 @@
+package popx_test
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+	. "github.com/ory/x/popx"
+)
+
+func copyMigrations(t *testing.T) fstest.MapFS {
+	files := fstest.MapFS{}
+	require.NoError(t, fs.WalkDir(transactionalMigrations, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := fs.ReadFile(transactionalMigrations, p)
+		files[p] = &fstest.MapFile{Data: b}
+		return err
+	}))
+	return files
+}
+
+func TestMigrationChecksums(t *testing.T) {
+	ctx := context.Background()
+	c, err := pop.NewConnection(&pop.ConnectionDetails{
+		URL: "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite") + "?_fk=true",
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+	defer c.Close()
+
+	files := copyMigrations(t)
+	newBox := func() *MigrationBox {
+		mb, err := NewMigrationBox(files, NewMigrator(c, logrusx.New("", ""), nil, 0))
+		require.NoError(t, err)
+		return mb
+	}
+
+	_, err = newBox().UpTo(ctx, 2)
+	require.NoError(t, err)
+	require.NoError(t, newBox().VerifyChecksums(ctx))
+
+	const changed = "stub/migrations/transactional/20191100000001000000_identities.sqlite3.up.sql"
+	files[changed] = &fstest.MapFile{Data: append(files[changed].Data, []byte("\n-- changed")...)}
+
+	mb := newBox()
+	assert.ErrorIs(t, mb.VerifyChecksums(ctx), ErrChecksumMismatch)
+	err = mb.Up(ctx)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Contains(t, err.Error(), "20191100000001000000")
+
+	status, err := mb.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.HasPending(), "no further migrations must be applied")
+
+	mb.IgnoreChecksumMismatch = true
+	require.NoError(t, mb.Up(ctx))
+
+	// The new checksum was recorded.
+	require.NoError(t, newBox().VerifyChecksums(ctx))
+
+	// Rolling back a migration removes its checksum, so it may be changed before it is applied again.
+	require.NoError(t, newBox().Down(ctx, -1))
+	files[changed] = &fstest.MapFile{Data: append(files[changed].Data, []byte("\n-- changed again")...)}
+	require.NoError(t, newBox().Up(ctx))
+}
+
+func TestMigrateDownCommand(t *testing.T) {
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+	require.NoError(t, mb.Up(ctx))
+
+	plan, err := mb.PlanDown(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, plan, 1)
+
+	all, err := mb.PlanDown(ctx, -1)
+	require.NoError(t, err)
+	assert.True(t, all.HasDestructive())
+
+	run := func(stdin string, args ...string) (string, string) {
+		cmd := &cobra.Command{
+			Use: "down",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return MigrateDown(cmd, mb.Migrator)
+			},
+		}
+		RegisterMigrateDownFlags(cmd.Flags())
+		var out, stderr bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&stderr)
+		cmd.SetIn(strings.NewReader(stdin))
+		cmd.SetArgs(args)
+		require.NoError(t, cmd.ExecuteContext(ctx))
+		return out.String(), stderr.String()
+	}
+
+	out, stderr := run("n\n", "--steps", "-1")
+	assert.Contains(t, out, "-- WARNING: This migration drops or deletes data.")
+	assert.Contains(t, stderr, "WARNING: Rolling back these migrations drops or deletes data")
+	assert.Contains(t, out, "Migrations were not rolled back.")
+
+	out, _ = run("", "--steps", "-1", "--dry-run")
+	assert.Contains(t, out, `DROP TABLE "identities"`)
+
+	status, err := mb.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.HasPending())
+
+	out, _ = run("", "--steps", "-1", "--yes")
+	assert.Contains(t, out, "Successfully rolled back")
+
+	status, err = mb.Status(ctx)
+	require.NoError(t, err)
+	for _, s := range status {
+		assert.Equal(t, Pending, s.State, s.Version)
+	}
+}
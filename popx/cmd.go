@@ -0,0 +1,179 @@
+package popx
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/ory/x/cmdx"
+)
+
+const (
+	// FlagDryRun is the name of the flag which prints the SQL of the pending migrations instead
+	// of applying them.
+	FlagDryRun = "dry-run"
+
+	// FlagYes is the name of the flag which skips confirmation prompts.
+	FlagYes = "yes"
+
+	// FlagIgnoreChecksums is the name of the flag which applies migrations even if applied
+	// migrations were changed.
+	FlagIgnoreChecksums = "ignore-checksums"
+
+	// FlagSteps is the name of the flag which sets how many migrations are rolled back.
+	FlagSteps = "steps"
+)
+
+// The exit codes of MigrateStatus, which allow CI/CD pipelines to gate deployments on the state
+// of the migrations. Use ExitCode to map the returned error to one of them.
+const (
+	// ExitCodeUpToDate means that all migrations are applied.
+	ExitCodeUpToDate = 0
+	// ExitCodeError means that the status could not be determined.
+	ExitCodeError = 1
+	// ExitCodePending means that at least one migration is not applied yet.
+	ExitCodePending = 2
+)
+
+// ErrPendingMigrations is returned by MigrateStatus if at least one migration is not applied yet.
+var ErrPendingMigrations = errors.New("there are pending migrations")
+
+func init() {
+	cmdx.RegisterExitCode(ErrPendingMigrations, ExitCodePending)
+}
+
+// ExitCode returns the exit code for an error returned by MigrateStatus.
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return ExitCodeUpToDate
+	case errors.Is(err, ErrPendingMigrations):
+		return ExitCodePending
+	default:
+		return ExitCodeError
+	}
+}
+
+// RegisterMigrateStatusFlags registers the flags used by MigrateStatus.
+func RegisterMigrateStatusFlags(flags *pflag.FlagSet) {
+	cmdx.RegisterFormatFlags(flags)
+}
+
+// MigrateStatus is the body of a "migrate status" command. It prints the status of the
+// migrations in the format set by --format, for example JSON or YAML, and returns
+// ErrPendingMigrations if migrations are pending. Pass the returned error to ExitCode to get
+// the exit code of the command.
+func MigrateStatus(cmd *cobra.Command, m *Migrator) error {
+	status, err := m.Status(cmd.Context())
+	if err != nil {
+		return err
+	}
+	cmdx.PrintTable(cmd, status)
+
+	if status.HasPending() {
+		// Pending migrations are a regular result which is signaled by the exit code only.
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return errors.WithStack(ErrPendingMigrations)
+	}
+	return nil
+}
+
+// RegisterMigrateUpFlags registers the flags used by MigrateUp.
+func RegisterMigrateUpFlags(flags *pflag.FlagSet) {
+	flags.Bool(FlagDryRun, false, "Print the pending migrations and their SQL without applying them.")
+	flags.BoolP(FlagYes, "y", false, "Apply the migrations without asking for confirmation.")
+	flags.Bool(FlagIgnoreChecksums, false, "Apply the migrations even if already applied migrations were changed since. Use with care.")
+}
+
+// RegisterMigrateDownFlags registers the flags used by MigrateDown.
+func RegisterMigrateDownFlags(flags *pflag.FlagSet) {
+	flags.Bool(FlagDryRun, false, "Print the migrations which would be rolled back and their SQL without running them.")
+	flags.BoolP(FlagYes, "y", false, "Roll back the migrations without asking for confirmation.")
+	flags.Int(FlagSteps, 1, "The number of migrations to roll back. Set to -1 to roll back all migrations.")
+}
+
+// MigrateUp is the body of a "migrate up" command. It prints the status of the migrations and
+// applies the pending ones after asking for confirmation, unless --yes is set. With --dry-run,
+// it prints the plan instead.
+func MigrateUp(cmd *cobra.Command, m *Migrator) error {
+	ctx := cmd.Context()
+	m.IgnoreChecksumMismatch, _ = cmd.Flags().GetBool(FlagIgnoreChecksums)
+
+	if dryRun, _ := cmd.Flags().GetBool(FlagDryRun); dryRun {
+		if !m.IgnoreChecksumMismatch {
+			if err := m.VerifyChecksums(ctx); err != nil {
+				return err
+			}
+		}
+		plan, err := m.Plan(ctx)
+		if err != nil {
+			return err
+		}
+		return plan.Write(cmd.OutOrStdout())
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	if err := status.Write(cmd.OutOrStdout()); err != nil {
+		return err
+	}
+
+	if !status.HasPending() {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "All migrations are already applied, there is nothing to do.")
+		return nil
+	}
+
+	if yes, _ := cmd.Flags().GetBool(FlagYes); !yes &&
+		!cmdx.AskForConfirmation("Do you want to apply the pending migrations?", cmd.InOrStdin(), cmd.OutOrStdout()) {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Migrations were not applied.")
+		return nil
+	}
+
+	if err := m.Up(ctx); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Successfully applied all migrations.")
+	return nil
+}
+
+// MigrateDown is the body of a "migrate down" command. It prints the migrations which would be
+// rolled back, warns if they drop or delete data, and runs them after asking for confirmation,
+// unless --yes is set. With --dry-run, it only prints the plan.
+func MigrateDown(cmd *cobra.Command, m *Migrator) error {
+	ctx := cmd.Context()
+	steps, _ := cmd.Flags().GetInt(FlagSteps)
+
+	plan, err := m.PlanDown(ctx, steps)
+	if err != nil {
+		return err
+	}
+	if err := plan.Write(cmd.OutOrStdout()); err != nil {
+		return err
+	}
+	if dryRun, _ := cmd.Flags().GetBool(FlagDryRun); dryRun || len(plan) == 0 {
+		return nil
+	}
+
+	question := "Do you want to roll back these migrations?"
+	if plan.HasDestructive() {
+		_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "WARNING: Rolling back these migrations drops or deletes data which can not be restored. Take a backup first.")
+		question = "Do you want to roll back these migrations and irrecoverably delete data?"
+	}
+
+	if yes, _ := cmd.Flags().GetBool(FlagYes); !yes &&
+		!cmdx.AskForConfirmation(question, cmd.InOrStdin(), cmd.OutOrStdout()) {
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), "Migrations were not rolled back.")
+		return nil
+	}
+
+	if err := m.Down(ctx, steps); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Successfully rolled back %d migrations.\n", len(plan))
+	return nil
+}
@@ -0,0 +1,196 @@
+package popx
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/sqlcon"
+)
+
+// ErrMigrationLocked is returned if the migration lock could not be acquired in time because
+// another process is running migrations.
+var ErrMigrationLocked = errors.New("unable to acquire the migration lock because another process is running migrations")
+
+// LockOptions configures the lock which ensures that only one process runs migrations at a
+// time, for example when several replicas start at the same time.
+//
+// PostgreSQL uses an advisory lock and MySQL uses GET_LOCK. Other databases, for example
+// CockroachDB and SQLite, use a lease stored in the table "<migration table>_lock".
+type LockOptions struct {
+	// Timeout is how long to wait for the lock before failing with ErrMigrationLocked. If zero,
+	// the lock is awaited until the context is done.
+	Timeout time.Duration
+
+	// RetryInterval is how often acquiring the lock is retried. Defaults to one second.
+	RetryInterval time.Duration
+
+	// LeaseDuration is how long a lease is valid unless it is renewed, which happens regularly
+	// while the lock is held. A lease left behind by a crashed process can be taken over once
+	// it expired. Defaults to one minute.
+	LeaseDuration time.Duration
+}
+
+type sqlConner interface {
+	Conn(ctx context.Context) (*sql.Conn, error)
+}
+
+// lock acquires the migration lock if m.Lock is set. The returned function releases it.
+func (m *Migrator) lock(ctx context.Context) (release func(), err error) {
+	if m.Lock == nil {
+		return func() {}, nil
+	}
+
+	interval := m.Lock.RetryInterval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if m.Lock.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.Lock.Timeout)
+		defer cancel()
+	}
+
+	name := m.migrationTableName(ctx, m.Connection) + "_lock"
+	var try func(ctx context.Context) (release func(), ok bool, err error)
+
+	db, ok := m.Connection.Store.(sqlConner)
+	switch dialect := m.Connection.Dialect.Name(); {
+	case ok && dialect == "postgres":
+		try = func(ctx context.Context) (func(), bool, error) {
+			h := fnv.New64a()
+			_, _ = h.Write([]byte(name))
+			key := int64(h.Sum64())
+			return sessionLock(ctx, db,
+				"SELECT pg_try_advisory_lock($1)", "SELECT pg_advisory_unlock($1)", key)
+		}
+	case ok && dialect == "mysql":
+		try = func(ctx context.Context) (func(), bool, error) {
+			return sessionLock(ctx, db,
+				"SELECT COALESCE(GET_LOCK(?, 0), 0)", "SELECT RELEASE_LOCK(?)", name)
+		}
+	default:
+		if err := m.createLeaseTable(ctx, name); err != nil {
+			return nil, err
+		}
+		holder := uuid.Must(uuid.NewV4()).String()
+		try = func(ctx context.Context) (func(), bool, error) {
+			return m.leaseLock(ctx, name, holder)
+		}
+	}
+
+	for {
+		release, ok, err := try(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				// The attempt was canceled because the timeout was reached.
+				return nil, errors.WithStack(ErrMigrationLocked)
+			}
+			return nil, err
+		} else if ok {
+			m.l.Debug("Acquired the migration lock.")
+			return release, nil
+		}
+
+		m.l.Infof("Another process is running migrations, waiting %s for the migration lock.", interval)
+		select {
+		case <-ctx.Done():
+			return nil, errors.WithStack(ErrMigrationLocked)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// sessionLock acquires a lock which is bound to a database session and thus held on a
+// dedicated connection.
+func sessionLock(ctx context.Context, db sqlConner, lockQuery, unlockQuery string, key interface{}) (func(), bool, error) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, false, errors.WithStack(err)
+	}
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, lockQuery, key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, errors.Wrap(err, "unable to acquire the migration lock")
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	return func() {
+		// Closing the connection returns it to the pool without ending the session, so the
+		// lock has to be released explicitly.
+		_, _ = conn.ExecContext(context.Background(), unlockQuery, key)
+		_ = conn.Close()
+	}, true, nil
+}
+
+func (m *Migrator) leaseDuration() time.Duration {
+	if m.Lock.LeaseDuration > 0 {
+		return m.Lock.LeaseDuration
+	}
+	return time.Minute
+}
+
+func (m *Migrator) createLeaseTable(ctx context.Context, table string) error {
+	// #nosec G201 - table is derived from the migration table name
+	return errors.Wrap(m.Connection.WithContext(ctx).RawQuery(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (id VARCHAR(64) NOT NULL PRIMARY KEY, holder VARCHAR(64) NOT NULL, expires_at TIMESTAMP NOT NULL)",
+		table)).Exec(), "unable to create the migration lock table")
+}
+
+func (m *Migrator) leaseLock(ctx context.Context, table, holder string) (func(), bool, error) {
+	c := m.Connection.WithContext(ctx)
+	lease := m.leaseDuration()
+
+	// #nosec G201 - table is derived from the migration table name
+	if err := c.RawQuery(fmt.Sprintf("DELETE FROM %s WHERE id = ? AND expires_at < ?", table),
+		"migrations", time.Now().UTC()).Exec(); err != nil {
+		return nil, false, errors.Wrap(err, "unable to remove an expired migration lock")
+	}
+
+	// #nosec G201 - table is derived from the migration table name
+	if err := c.RawQuery(fmt.Sprintf("INSERT INTO %s (id, holder, expires_at) VALUES (?, ?, ?)", table),
+		"migrations", holder, time.Now().UTC().Add(lease)).Exec(); err != nil {
+		if errors.Is(sqlcon.HandleError(err), sqlcon.ErrUniqueViolation) {
+			return nil, false, nil
+		}
+		return nil, false, errors.Wrap(err, "unable to acquire the migration lock")
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(lease / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				// #nosec G201 - table is derived from the migration table name
+				if err := m.Connection.RawQuery(fmt.Sprintf("UPDATE %s SET expires_at = ? WHERE id = ? AND holder = ?", table),
+					time.Now().UTC().Add(lease), "migrations", holder).Exec(); err != nil {
+					m.l.WithError(err).Warn("Unable to renew the migration lock.")
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+		// #nosec G201 - table is derived from the migration table name
+		if err := m.Connection.RawQuery(fmt.Sprintf("DELETE FROM %s WHERE id = ? AND holder = ?", table),
+			"migrations", holder).Exec(); err != nil {
+			m.l.WithError(err).Warn("Unable to release the migration lock, it is released once it expires.")
+		}
+	}, true, nil
+}
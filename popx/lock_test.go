@@ -0,0 +1,66 @@
+package popx_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+	. "github.com/ory/x/popx"
+)
+
+func TestMigrationLock(t *testing.T) {
+	ctx := context.Background()
+	dsn := "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite") + "?_fk=true"
+
+	newBox := func(t *testing.T) *MigrationBox {
+		c, err := pop.NewConnection(&pop.ConnectionDetails{URL: dsn})
+		require.NoError(t, err)
+		require.NoError(t, c.Open())
+		t.Cleanup(func() { _ = c.Close() })
+
+		mb, err := NewMigrationBox(transactionalMigrations, NewMigrator(c, logrusx.New("", ""), nil, 0))
+		require.NoError(t, err)
+		mb.Lock = &LockOptions{Timeout: 200 * time.Millisecond, RetryInterval: 10 * time.Millisecond}
+		return mb
+	}
+
+	first, second := newBox(t), newBox(t)
+
+	locked := make(chan struct{})
+	proceed := make(chan struct{})
+	first.PreMigrationHooks = []MigrationHook{func(context.Context, Migration) error {
+		close(locked)
+		<-proceed
+		return nil
+	}}
+
+	errs := make(chan error)
+	go func() {
+		_, err := first.UpTo(ctx, 1)
+		errs <- err
+	}()
+
+	<-locked
+	assert.ErrorIs(t, second.Up(ctx), ErrMigrationLocked)
+
+	close(proceed)
+	require.NoError(t, <-errs)
+
+	require.NoError(t, second.Up(ctx))
+	status, err := second.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.HasPending())
+
+	t.Run("case=takes over expired leases", func(t *testing.T) {
+		require.NoError(t, second.Connection.RawQuery(
+			"INSERT INTO schema_migration_lock (id, holder, expires_at) VALUES (?, ?, ?)",
+			"migrations", "crashed", time.Now().UTC().Add(-time.Minute)).Exec())
+		require.NoError(t, second.Up(ctx))
+	})
+}
@@ -57,11 +57,21 @@ func NewMigrationBox(dir fs.FS, m *Migrator, opts ...func(*MigrationBox) *Migrat
 		mb = o(mb)
 	}
 
+	content := func(b []byte) func(Migration, *pop.Connection) (string, error) {
+		return func(mf Migration, c *pop.Connection) (string, error) {
+			content, err := mb.migrationContent(mf, c, b, true)
+			if err != nil {
+				return "", errors.Wrapf(err, "error processing %s", mf.Path)
+			}
+			return content, nil
+		}
+	}
+
 	runner := func(b []byte) func(Migration, *pop.Connection, *pop.Tx) error {
 		return func(mf Migration, c *pop.Connection, tx *pop.Tx) error {
-			content, err := mb.migrationContent(mf, c, b, true)
+			content, err := content(b)(mf, c)
 			if err != nil {
-				return errors.Wrapf(err, "error processing %s", mf.Path)
+				return err
 			}
 			if content == "" {
 				m.l.WithField("migration", mf.Path).Trace("This is usually ok - ignoring migration because content is empty. This is ok!")
@@ -74,7 +84,7 @@ func NewMigrationBox(dir fs.FS, m *Migrator, opts ...func(*MigrationBox) *Migrat
 		}
 	}
 
-	err := mb.findMigrations(runner)
+	err := mb.findMigrations(runner, content)
 	if err != nil {
 		return mb, err
 	}
@@ -82,7 +92,10 @@ func NewMigrationBox(dir fs.FS, m *Migrator, opts ...func(*MigrationBox) *Migrat
 	return mb, nil
 }
 
-func (fm *MigrationBox) findMigrations(runner func([]byte) func(mf Migration, c *pop.Connection, tx *pop.Tx) error) error {
+func (fm *MigrationBox) findMigrations(
+	runner func([]byte) func(mf Migration, c *pop.Connection, tx *pop.Tx) error,
+	content func([]byte) func(mf Migration, c *pop.Connection) (string, error),
+) error {
 	return fs.WalkDir(fm.Dir, ".", func(p string, info fs.DirEntry, err error) error {
 		if err != nil {
 			return errors.WithStack(err)
@@ -110,7 +123,7 @@ func (fm *MigrationBox) findMigrations(runner func([]byte) func(mf Migration, c
 		if err != nil {
 			return errors.WithStack(err)
 		}
-		content, err := io.ReadAll(f)
+		body, err := io.ReadAll(f)
 		if err != nil {
 			return errors.WithStack(err)
 		}
@@ -122,7 +135,8 @@ func (fm *MigrationBox) findMigrations(runner func([]byte) func(mf Migration, c
 			DBType:    match.DBType,
 			Direction: match.Direction,
 			Type:      match.Type,
-			Runner:    runner(content),
+			Runner:    runner(body),
+			Content:   content(body),
 		}
 		fm.Migrations[mf.Direction] = append(fm.Migrations[mf.Direction], mf)
 		mod := sortIdent(fm.Migrations[mf.Direction])
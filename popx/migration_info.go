@@ -23,6 +23,9 @@ type Migration struct {
 	DBType string
 	// Runner function to run/execute the migration
 	Runner func(Migration, *pop.Connection, *pop.Tx) error
+	// Content returns the SQL the Runner executes, if known. It is used to plan migrations
+	// without applying them.
+	Content func(Migration, *pop.Connection) (string, error)
 }
 
 // Run the migration. Returns an error if there is
@@ -65,6 +65,34 @@ type Migrator struct {
 
 	// DumpMigrations if true will dump the migrations to a file called schema.sql
 	DumpMigrations bool
+
+	// PreMigrationHooks are called before a migration is applied or rolled back, for example
+	// to take a backup. If a hook fails, the migration is not run.
+	PreMigrationHooks []MigrationHook
+
+	// PostMigrationHooks are called after a migration was applied or rolled back, for example
+	// to bust a cache.
+	PostMigrationHooks []MigrationHook
+
+	// Lock, if set, ensures that only one process runs migrations at a time.
+	Lock *LockOptions
+
+	// IgnoreChecksumMismatch applies migrations even if migrations which were already applied
+	// have changed since. By default, Up fails with ErrChecksumMismatch.
+	IgnoreChecksumMismatch bool
+}
+
+// MigrationHook is called with the migration which is run. The direction of the migration is
+// available as Migration.Direction.
+type MigrationHook func(ctx context.Context, mi Migration) error
+
+func (m *Migrator) runHooks(ctx context.Context, hooks []MigrationHook, mi Migration) error {
+	for _, h := range hooks {
+		if err := h(ctx, mi); err != nil {
+			return errors.Wrapf(err, "migration hook failed for %s", mi.Path)
+		}
+	}
+	return nil
 }
 
 // MigrationIsCompatible returns true if the migration is compatible with the current database.
@@ -91,6 +119,10 @@ func (m *Migrator) UpTo(ctx context.Context, step int) (applied int, err error)
 	c := m.Connection.WithContext(ctx)
 	err = m.exec(ctx, func() error {
 		mtn := m.migrationTableName(ctx, c)
+		if err := m.verifyChecksums(ctx, c); err != nil {
+			return err
+		}
+
 		mfs := m.Migrations["up"].SortAndFilter(c.Dialect.Name())
 		for _, mi := range mfs {
 			exists, err := c.Where("version = ?", mi.Version).Exists(mtn)
@@ -135,6 +167,10 @@ func (m *Migrator) UpTo(ctx context.Context, step int) (applied int, err error)
 
 			m.l.WithField("version", mi.Version).Debug("Migration has not yet been applied, running migration.")
 
+			if err := m.runHooks(ctx, m.PreMigrationHooks, mi); err != nil {
+				return err
+			}
+
 			if err = m.isolatedTransaction(ctx, "up", func(tx *pop.Tx) error {
 				if err := mi.Run(c, tx); err != nil {
 					return err
@@ -144,11 +180,15 @@ func (m *Migrator) UpTo(ctx context.Context, step int) (applied int, err error)
 				if _, err = tx.Exec(fmt.Sprintf("INSERT INTO %s (version) VALUES ('%s')", mtn, mi.Version)); err != nil {
 					return errors.Wrapf(err, "problem inserting migration version %s", mi.Version)
 				}
-				return nil
+				return m.recordChecksum(ctx, c, tx, mi)
 			}); err != nil {
 				return err
 			}
 
+			if err := m.runHooks(ctx, m.PostMigrationHooks, mi); err != nil {
+				return err
+			}
+
 			m.l.Debugf("> %s", mi.Name)
 			applied++
 			if step > 0 && applied >= step {
@@ -174,18 +214,9 @@ func (m *Migrator) Down(ctx context.Context, step int) error {
 	c := m.Connection.WithContext(ctx)
 	return m.exec(ctx, func() error {
 		mtn := m.migrationTableName(ctx, c)
-		count, err := c.Count(mtn)
+		mfs, err := m.downMigrations(ctx, c, step)
 		if err != nil {
-			return errors.Wrap(err, "migration down: unable count existing migration")
-		}
-		mfs := m.Migrations["down"].SortAndFilter(c.Dialect.Name(), sort.Reverse)
-		// skip all ran migration
-		if len(mfs) > count {
-			mfs = mfs[len(mfs)-count:]
-		}
-		// run only required steps
-		if step > 0 && len(mfs) >= step {
-			mfs = mfs[:step]
+			return err
 		}
 		for _, mi := range mfs {
 			exists, err := c.Where("version = ?", mi.Version).Exists(mtn)
@@ -207,6 +238,14 @@ func (m *Migrator) Down(ctx context.Context, step int) error {
 				return errors.Errorf("migration version %s does not exist", mi.Version)
 			}
 
+			if m.isDestructive(c, mi) {
+				m.l.WithField("version", mi.Version).Warn("Running a destructive down migration which may delete data.")
+			}
+
+			if err := m.runHooks(ctx, m.PreMigrationHooks, mi); err != nil {
+				return err
+			}
+
 			err = m.isolatedTransaction(ctx, "down", func(tx *pop.Tx) error {
 				err := mi.Run(c, tx)
 				if err != nil {
@@ -218,18 +257,40 @@ func (m *Migrator) Down(ctx context.Context, step int) error {
 					return errors.Wrapf(err, "problem deleting migration version %s", mi.Version)
 				}
 
-				return nil
+				return m.deleteChecksum(ctx, c, tx, mi)
 			})
 			if err != nil {
 				return err
 			}
 
+			if err := m.runHooks(ctx, m.PostMigrationHooks, mi); err != nil {
+				return err
+			}
+
 			m.l.Debugf("< %s", mi.Name)
 		}
 		return nil
 	})
 }
 
+// downMigrations returns the "down" migrations which Down runs for step.
+func (m *Migrator) downMigrations(ctx context.Context, c *pop.Connection, step int) (Migrations, error) {
+	count, err := c.Count(m.migrationTableName(ctx, c))
+	if err != nil {
+		return nil, errors.Wrap(err, "migration down: unable count existing migration")
+	}
+	mfs := m.Migrations["down"].SortAndFilter(c.Dialect.Name(), sort.Reverse)
+	// skip all ran migration
+	if len(mfs) > count {
+		mfs = mfs[len(mfs)-count:]
+	}
+	// run only required steps
+	if step > 0 && len(mfs) >= step {
+		mfs = mfs[:step]
+	}
+	return mfs, nil
+}
+
 // Reset the database by running the down migrations followed by the up migrations.
 func (m *Migrator) Reset(ctx context.Context) error {
 	err := m.Down(ctx, -1)
@@ -538,11 +599,21 @@ func (m *Migrator) exec(ctx context.Context, fn func() error) error {
 	}()
 	defer m.printTimer(now)
 
-	err := m.CreateSchemaMigrations(ctx)
+	release, err := m.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	err = m.CreateSchemaMigrations(ctx)
 	if err != nil {
 		return errors.Wrap(err, "migrator: problem creating schema migrations")
 	}
 
+	if err := m.createChecksumTable(ctx, m.Connection.WithContext(ctx)); err != nil {
+		return err
+	}
+
 	if m.Connection.Dialect.Name() == "sqlite3" {
 		if err := m.Connection.RawQuery("PRAGMA foreign_keys=OFF").Exec(); err != nil {
 			return err
@@ -0,0 +1,131 @@
+package popx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+)
+
+// PlannedMigration is a pending migration and the SQL it would execute.
+type PlannedMigration struct {
+	Version string `json:"version"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	// SQL is empty if the migration is not defined by SQL, for example a Go migration.
+	SQL string `json:"sql"`
+	// Destructive is true if the SQL drops or deletes data.
+	Destructive bool `json:"destructive"`
+}
+
+var destructiveStatement = regexp.MustCompile(`(?i)\b(DROP\s+(TABLE|COLUMN|SCHEMA|DATABASE)|TRUNCATE|DELETE\s+FROM|ALTER\s+TABLE\s+\S+\s+DROP)\b`)
+
+// MigrationPlan lists the migrations Up or Down would run, in order.
+type MigrationPlan []PlannedMigration
+
+// Write prints the plan as a SQL script with a comment for every migration.
+func (p MigrationPlan) Write(out io.Writer) error {
+	if len(p) == 0 {
+		_, err := fmt.Fprintln(out, "-- There are no migrations to run.")
+		return errors.WithStack(err)
+	}
+
+	for _, mi := range p {
+		if _, err := fmt.Fprintf(out, "-- Migration %s: %s (%s)\n", mi.Version, mi.Name, mi.Path); err != nil {
+			return errors.WithStack(err)
+		}
+		if mi.Destructive {
+			if _, err := fmt.Fprintln(out, "-- WARNING: This migration drops or deletes data."); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+		sql := strings.TrimSpace(mi.SQL)
+		if sql == "" {
+			sql = "-- The SQL of this migration is not known in advance."
+		}
+		if _, err := fmt.Fprintf(out, "%s\n\n", sql); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
+
+// Plan returns the pending "up" migrations and their SQL without applying them.
+func (m *Migrator) Plan(ctx context.Context) (MigrationPlan, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	c := m.Connection.WithContext(ctx)
+	migrations := m.Migrations["up"].SortAndFilter(c.Dialect.Name())
+
+	plan := MigrationPlan{}
+	for k, mi := range migrations {
+		if statuses[k].State != Pending {
+			continue
+		}
+
+		planned, err := planMigration(c, mi)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, planned)
+	}
+	return plan, nil
+}
+
+// PlanDown returns the "down" migrations which Down would run for step, and their SQL,
+// without running them.
+func (m *Migrator) PlanDown(ctx context.Context, step int) (MigrationPlan, error) {
+	c := m.Connection.WithContext(ctx)
+	migrations, err := m.downMigrations(ctx, c, step)
+	if err != nil {
+		if errIsTableNotFound(errors.Cause(err)) {
+			return MigrationPlan{}, nil
+		}
+		return nil, err
+	}
+
+	plan := MigrationPlan{}
+	for _, mi := range migrations {
+		planned, err := planMigration(c, mi)
+		if err != nil {
+			return nil, err
+		}
+		plan = append(plan, planned)
+	}
+	return plan, nil
+}
+
+// HasDestructive returns true if any migration of the plan drops or deletes data.
+func (p MigrationPlan) HasDestructive() bool {
+	for _, mi := range p {
+		if mi.Destructive {
+			return true
+		}
+	}
+	return false
+}
+
+func planMigration(c *pop.Connection, mi Migration) (PlannedMigration, error) {
+	planned := PlannedMigration{Version: mi.Version, Name: mi.Name, Path: mi.Path}
+	if mi.Content != nil {
+		var err error
+		if planned.SQL, err = mi.Content(mi, c); err != nil {
+			return planned, err
+		}
+		planned.Destructive = destructiveStatement.MatchString(planned.SQL)
+	}
+	return planned, nil
+}
+
+// isDestructive returns true if the SQL of the migration drops tables or columns or deletes rows.
+func (m *Migrator) isDestructive(c *pop.Connection, mi Migration) bool {
+	planned, err := planMigration(c, mi)
+	return err == nil && planned.Destructive
+}
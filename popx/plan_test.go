@@ -0,0 +1,182 @@
+package popx_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gobuffalo/pop/v6"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+	. "github.com/ory/x/popx"
+)
+
+func newSQLiteMigrationBox(t *testing.T) *MigrationBox {
+	c, err := pop.NewConnection(&pop.ConnectionDetails{
+		URL: "sqlite://" + filepath.Join(t.TempDir(), "db.sqlite") + "?_fk=true",
+	})
+	require.NoError(t, err)
+	require.NoError(t, c.Open())
+	t.Cleanup(func() { _ = c.Close() })
+
+	mb, err := NewMigrationBox(transactionalMigrations, NewMigrator(c, logrusx.New("", ""), nil, 0))
+	require.NoError(t, err)
+	return mb
+}
+
+func TestMigrationPlan(t *testing.T) {
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+
+	plan, err := mb.Plan(ctx)
+	require.NoError(t, err)
+	status, err := mb.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, plan, status.Len())
+	assert.Equal(t, "20191100000001000000", plan[0].Version)
+	assert.Contains(t, plan[0].SQL, "CREATE TABLE")
+
+	var out bytes.Buffer
+	require.NoError(t, plan.Write(&out))
+	assert.Contains(t, out.String(), "-- Migration 20191100000001000000: identities")
+
+	_, err = mb.UpTo(ctx, 2)
+	require.NoError(t, err)
+	plan, err = mb.Plan(ctx)
+	require.NoError(t, err)
+	assert.Len(t, plan, status.Len()-2)
+
+	require.NoError(t, mb.Up(ctx))
+	plan, err = mb.Plan(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, plan)
+}
+
+func TestMigrationHooks(t *testing.T) {
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+
+	var calls []string
+	mb.PreMigrationHooks = append(mb.PreMigrationHooks, func(_ context.Context, mi Migration) error {
+		calls = append(calls, "pre "+mi.Direction+" "+mi.Version)
+		return nil
+	})
+	mb.PostMigrationHooks = append(mb.PostMigrationHooks, func(_ context.Context, mi Migration) error {
+		calls = append(calls, "post "+mi.Direction+" "+mi.Version)
+		return nil
+	})
+
+	_, err := mb.UpTo(ctx, 1)
+	require.NoError(t, err)
+	require.NoError(t, mb.Down(ctx, 1))
+	assert.Equal(t, []string{
+		"pre up 20191100000001000000",
+		"post up 20191100000001000000",
+		"pre down 20191100000001000000",
+		"post down 20191100000001000000",
+	}, calls)
+
+	mb.PreMigrationHooks = []MigrationHook{func(context.Context, Migration) error {
+		return errors.New("backup failed")
+	}}
+	_, err = mb.UpTo(ctx, 1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "backup failed")
+
+	status, err := mb.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, Pending, status[0].State)
+}
+
+func TestMigrateUpCommand(t *testing.T) {
+	newCmd := func(mb *MigrationBox) *cobra.Command {
+		cmd := &cobra.Command{
+			Use: "up",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return MigrateUp(cmd, mb.Migrator)
+			},
+		}
+		RegisterMigrateUpFlags(cmd.Flags())
+		return cmd
+	}
+
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+
+	var out bytes.Buffer
+	cmd := newCmd(mb)
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--dry-run"})
+	require.NoError(t, cmd.ExecuteContext(ctx))
+	assert.Contains(t, out.String(), "CREATE TABLE")
+
+	status, err := mb.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, status.HasPending(), "a dry run must not apply migrations")
+
+	out.Reset()
+	cmd = newCmd(mb)
+	cmd.SetOut(&out)
+	cmd.SetIn(strings.NewReader("n\n"))
+	cmd.SetArgs([]string{})
+	require.NoError(t, cmd.ExecuteContext(ctx))
+	assert.Contains(t, out.String(), "Migrations were not applied.")
+
+	out.Reset()
+	cmd = newCmd(mb)
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--yes"})
+	require.NoError(t, cmd.ExecuteContext(ctx))
+	assert.Contains(t, out.String(), "Successfully applied all migrations.")
+
+	status, err = mb.Status(ctx)
+	require.NoError(t, err)
+	assert.False(t, status.HasPending())
+}
+
+func TestMigrateStatusCommand(t *testing.T) {
+	ctx := context.Background()
+	mb := newSQLiteMigrationBox(t)
+
+	run := func(args ...string) (string, error) {
+		cmd := &cobra.Command{
+			Use: "status",
+			RunE: func(cmd *cobra.Command, _ []string) error {
+				return MigrateStatus(cmd, mb.Migrator)
+			},
+		}
+		RegisterMigrateStatusFlags(cmd.Flags())
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		err := cmd.ExecuteContext(ctx)
+		return out.String(), err
+	}
+
+	out, err := run("--format", "json")
+	require.ErrorIs(t, err, ErrPendingMigrations)
+	assert.Equal(t, ExitCodePending, ExitCode(err))
+
+	var statuses MigrationStatuses
+	require.NoError(t, json.Unmarshal([]byte(out), &statuses), out)
+	require.NotEmpty(t, statuses)
+	assert.Equal(t, Pending, statuses[0].State)
+
+	require.NoError(t, mb.Up(ctx))
+
+	out, err = run("--format", "yaml")
+	require.NoError(t, err)
+	assert.Equal(t, ExitCodeUpToDate, ExitCode(err))
+	assert.Contains(t, out, "state: "+Applied)
+	assert.NotContains(t, out, Pending)
+
+	assert.Equal(t, ExitCodeError, ExitCode(errors.New("connection refused")))
+}
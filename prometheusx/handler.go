@@ -14,12 +14,17 @@ const (
 )
 
 // Handler handles HTTP requests to health and version endpoints.
+//
+// Deprecated: use metricsx.Prometheus.SetRoutes or metricsx.Prometheus.Handler instead. Note
+// that metricsx.PrometheusPath is "/metrics".
 type Handler struct {
 	H             herodot.Writer
 	VersionString string
 }
 
 // NewHandler instantiates a handler.
+//
+// Deprecated: use metricsx.NewPrometheus instead.
 func NewHandler(
 	h herodot.Writer,
 	version string,
@@ -1,3 +1,8 @@
+// Package prometheus collects HTTP request metrics and exposes them to Prometheus.
+//
+// Deprecated: use metricsx.NewPrometheus instead, which combines the metrics, the middleware,
+// and the exposition endpoint, uses a registry per instance, and labels requests by route
+// without unbounded cardinality.
 package prometheus
 
 import (
@@ -12,6 +17,8 @@ import (
 )
 
 // Metrics prototypes
+//
+// Deprecated: use metricsx.Prometheus instead.
 type Metrics struct {
 	responseTime    *prometheus.HistogramVec
 	totalRequests   *prometheus.CounterVec
@@ -25,6 +32,8 @@ const HTTPMetrics = "http"
 const GRPCMetrics = "grpc"
 
 // NewMetrics creates new custom Prometheus metrics
+//
+// Deprecated: use metricsx.NewPrometheus instead.
 func NewMetrics(app, metricsPrefix, version, hash, date string) *Metrics {
 	labels := map[string]string{
 		"app":       app,
@@ -7,11 +7,17 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
+// MetricsManager is a middleware which records the request metrics.
+//
+// Deprecated: use metricsx.Prometheus, which implements the same middleware interface.
 type MetricsManager struct {
 	prometheusMetrics *Metrics
 	routers           []*httprouter.Router
 }
 
+// NewMetricsManager creates a MetricsManager.
+//
+// Deprecated: use metricsx.NewPrometheus instead.
 func NewMetricsManager(app, version, hash, buildTime string) *MetricsManager {
 	return NewMetricsManagerWithPrefix(app, "", version, hash, buildTime)
 }
@@ -19,6 +25,8 @@ func NewMetricsManager(app, version, hash, buildTime string) *MetricsManager {
 // NewMetricsManagerWithPrefix creates MetricsManager that uses metricsPrefix parameters as a prefix
 // for all metrics registered within this middleware. Constants HttpMetrics or GrpcMetrics can be used
 // respectively. Setting empty string in metricsPrefix will be equivalent to calling NewMetricsManager.
+//
+// Deprecated: use metricsx.NewPrometheus with the prefix as namespace instead.
 func NewMetricsManagerWithPrefix(app, metricsPrefix, version, hash, buildTime string) *MetricsManager {
 	return &MetricsManager{
 		prometheusMetrics: NewMetrics(app, metricsPrefix, version, hash, buildTime),
@@ -5,6 +5,8 @@ import (
 	"io"
 	"net/http"
 	"net/http/httputil"
+
+	"github.com/ory/x/httpx"
 )
 
 type (
@@ -18,6 +20,7 @@ type (
 		respMiddlewares []RespMiddleware
 		reqMiddlewares  []ReqMiddleware
 		transport       http.RoundTripper
+		forwarded       *httpx.ForwardedParser
 	}
 	HostConfig struct {
 		// CookieDomain is the host under which cookies are set.
@@ -61,17 +64,23 @@ func director(o *options) func(*http.Request) {
 			return
 		}
 
-		if forwardedProto := r.Header.Get("X-Forwarded-Proto"); forwardedProto != "" {
-			c.originalScheme = forwardedProto
-		} else if r.TLS == nil {
-			c.originalScheme = "http"
-		} else {
-			c.originalScheme = "https"
-		}
-		if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
-			c.originalHost = forwardedHost
+		if o.forwarded != nil {
+			in := o.forwarded.Parse(r)
+			c.originalScheme = in.Scheme
+			c.originalHost = in.Host
 		} else {
-			c.originalHost = r.Host
+			if forwardedProto := r.Header.Get("X-Forwarded-Proto"); forwardedProto != "" {
+				c.originalScheme = forwardedProto
+			} else if r.TLS == nil {
+				c.originalScheme = "http"
+			} else {
+				c.originalScheme = "https"
+			}
+			if forwardedHost := r.Header.Get("X-Forwarded-Host"); forwardedHost != "" {
+				c.originalHost = forwardedHost
+			} else {
+				c.originalHost = r.Host
+			}
 		}
 
 		*r = *r.WithContext(context.WithValue(r.Context(), hostConfigKey, c))
@@ -171,6 +180,15 @@ func WithTransport(t http.RoundTripper) Options {
 	}
 }
 
+// WithForwardedParser makes the proxy derive the original scheme and host from forwarding
+// headers only if the request was sent by a trusted proxy. By default, the X-Forwarded-Proto
+// and X-Forwarded-Host headers are always honored.
+func WithForwardedParser(p *httpx.ForwardedParser) Options {
+	return func(o *options) {
+		o.forwarded = p
+	}
+}
+
 // New creates a new Proxy
 // A Proxy sets up a middleware with custom request and response modification handlers
 func New(hostMapper HostMapper, opts ...Options) http.Handler {
@@ -10,6 +10,8 @@ import (
 	"strings"
 
 	"github.com/pkg/errors"
+
+	"github.com/ory/x/urlx"
 )
 
 type compressableBody struct {
@@ -56,7 +58,8 @@ func headerResponseRewrite(resp *http.Response, c *HostConfig) error {
 		if !errors.Is(err, http.ErrNoLocation) {
 			return errors.WithStack(err)
 		}
-	} else if redir.Host == c.TargetHost {
+	} else if urlx.SameOrigin(redir, &url.URL{Scheme: redir.Scheme, Host: c.TargetHost}) {
+		// The scheme is not compared because TargetScheme is optional.
 		redir.Scheme = c.originalScheme
 		redir.Host = c.originalHost
 		redir.Path = path.Join(c.PathPrefix, redir.Path)
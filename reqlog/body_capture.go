@@ -0,0 +1,124 @@
+package reqlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/negroni"
+)
+
+type bodyCapture struct {
+	maxSize      int
+	contentTypes []string
+}
+
+// CaptureBodies logs the request and response bodies of the given content types, for example
+// "application/json" or "text/*", in the "http_request_body" and "http_response_body" fields.
+// Bodies larger than maxSize bytes are truncated. Bodies are captured while the handler reads
+// and writes them, so streaming is not affected.
+//
+// JSON bodies which are not truncated are logged as objects, so that the redaction rules of
+// the logger (see logrusx.WithRedactionRules) apply to their keys as well as their values.
+func (m *Middleware) CaptureBodies(maxSize int, contentTypes ...string) *Middleware {
+	m.capture = &bodyCapture{maxSize: maxSize, contentTypes: contentTypes}
+	return m
+}
+
+func (c *bodyCapture) matches(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, ct := range c.contentTypes {
+		ct = strings.ToLower(ct)
+		if ct == mediaType || (strings.HasSuffix(ct, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(ct, "*"))) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedBuffer keeps the first max bytes written to it.
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) capture(p []byte) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		if room <= 0 {
+			return
+		}
+		p = p[:room]
+	}
+	b.Write(p)
+}
+
+// field returns the captured body as a log field value.
+func (b *limitedBuffer) field(contentType string) map[string]interface{} {
+	field := map[string]interface{}{
+		"content_type": contentType,
+		"truncated":    b.truncated,
+	}
+
+	if mediaType, _, _ := mime.ParseMediaType(contentType); !b.truncated && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		var body interface{}
+		if err := json.Unmarshal(b.Bytes(), &body); err == nil {
+			field["body"] = body
+			return field
+		}
+	}
+
+	field["body"] = b.String()
+	return field
+}
+
+type capturingReadCloser struct {
+	io.ReadCloser
+	b *limitedBuffer
+}
+
+func (r *capturingReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.b.capture(p[:n])
+	return n, err
+}
+
+type capturingResponseWriter struct {
+	negroni.ResponseWriter
+	c       *bodyCapture
+	b       *limitedBuffer
+	checked bool
+}
+
+func (w *capturingResponseWriter) Write(p []byte) (int, error) {
+	if !w.checked {
+		w.checked = true
+		if w.c.matches(w.Header().Get("Content-Type")) {
+			w.b = &limitedBuffer{max: w.c.maxSize}
+		}
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	if w.b != nil {
+		w.b.capture(p[:n])
+	}
+	return n, err
+}
+
+func (w *capturingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("the ResponseWriter doesn't support the Hijacker interface")
+	}
+	return h.Hijack()
+}
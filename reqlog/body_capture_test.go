@@ -0,0 +1,95 @@
+package reqlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/negroni"
+
+	"github.com/ory/x/logrusx"
+)
+
+func TestMiddleware_CaptureBodies(t *testing.T) {
+	serve := func(t *testing.T, mw *Middleware, req *http.Request, h http.HandlerFunc) map[string]interface{} {
+		var b bytes.Buffer
+		mw.Logger.Logger.Out = &b
+		mw.ServeHTTP(negroni.NewResponseWriter(httptest.NewRecorder()), req, h)
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		require.Len(t, lines, 2)
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+		return entry
+	}
+
+	newMiddleware := func(opts ...logrusx.Option) *Middleware {
+		return NewMiddlewareFromLogger(logrusx.New("reqlog", "", append([]logrusx.Option{logrusx.ForceFormat("json")}, opts...)...), "reqlog").
+			CaptureBodies(16, "application/json", "text/*")
+	}
+
+	echo := func(ct string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", ct)
+			_, _ = io.Copy(w, r.Body)
+		}
+	}
+
+	t.Run("case=captures json bodies", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"foo":"bar"}`))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+		entry := serve(t, newMiddleware(), req, echo("application/json"))
+		assert.Equal(t, map[string]interface{}{
+			"body":         map[string]interface{}{"foo": "bar"},
+			"content_type": "application/json; charset=utf-8",
+			"truncated":    false,
+		}, entry["http_request_body"])
+		assert.Equal(t, map[string]interface{}{"foo": "bar"}, entry["http_response_body"].(map[string]interface{})["body"])
+	})
+
+	t.Run("case=truncates large bodies", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", 100)))
+		req.Header.Set("Content-Type", "text/plain")
+
+		var handlerBody []byte
+		entry := serve(t, newMiddleware(), req, func(w http.ResponseWriter, r *http.Request) {
+			handlerBody, _ = io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = w.Write(handlerBody)
+		})
+
+		assert.Len(t, handlerBody, 100)
+		for _, field := range []string{"http_request_body", "http_response_body"} {
+			assert.Equal(t, strings.Repeat("a", 16), entry[field].(map[string]interface{})["body"], field)
+			assert.Equal(t, true, entry[field].(map[string]interface{})["truncated"], field)
+		}
+	})
+
+	t.Run("case=ignores other content types", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("foo"))
+		req.Header.Set("Content-Type", "application/octet-stream")
+
+		entry := serve(t, newMiddleware(), req, echo("image/png"))
+		assert.NotContains(t, entry, "http_request_body")
+		assert.NotContains(t, entry, "http_response_body")
+	})
+
+	t.Run("case=applies redaction rules", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(`{"password":"a"}`))
+		req.Header.Set("Content-Type", "application/json")
+
+		entry := serve(t, newMiddleware(logrusx.WithRedactionRules(logrusx.RedactionRules{
+			Fields: []*regexp.Regexp{regexp.MustCompile("password")},
+		})), req, echo("application/json"))
+		assert.Equal(t, map[string]interface{}{"password": logrusx.Redacted}, entry["http_request_body"].(map[string]interface{})["body"])
+		assert.Equal(t, map[string]interface{}{"password": logrusx.Redacted}, entry["http_response_body"].(map[string]interface{})["body"])
+	})
+}
@@ -7,7 +7,10 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/negroni"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
 
+	"github.com/ory/x/httpx"
 	"github.com/ory/x/logrusx"
 )
 
@@ -44,6 +47,12 @@ type Middleware struct {
 	// Silence log for specific URL paths
 	silencePaths map[string]bool
 
+	forwarded *httpx.ForwardedParser
+
+	capture *bodyCapture
+
+	baggageKeys []string
+
 	sync.RWMutex
 }
 
@@ -99,6 +108,21 @@ func (m *Middleware) ExcludePaths(paths ...string) *Middleware {
 	return m
 }
 
+// TrustForwardedHeaders derives the client IP from the forwarding headers of trusted proxies
+// using p instead of the X-Real-IP header.
+func (m *Middleware) TrustForwardedHeaders(p *httpx.ForwardedParser) *Middleware {
+	m.forwarded = p
+	return m
+}
+
+// LogBaggage adds the OpenTelemetry baggage members with the given keys to the "baggage" field
+// of the request log. Members are read from the request context, or from the baggage header
+// if the context carries no baggage.
+func (m *Middleware) LogBaggage(keys ...string) *Middleware {
+	m.baggageKeys = append(m.baggageKeys, keys...)
+	return m
+}
+
 func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	if m.Before == nil {
 		m.Before = DefaultBefore
@@ -119,24 +143,76 @@ func (m *Middleware) ServeHTTP(rw http.ResponseWriter, r *http.Request, next htt
 
 	// Try to get the real IP
 	remoteAddr := r.RemoteAddr
-	if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
+	if m.forwarded != nil {
+		if ip := m.forwarded.Parse(r).ClientIP; ip != nil {
+			remoteAddr = ip.String()
+		}
+	} else if realIP := r.Header.Get("X-Real-IP"); realIP != "" {
 		remoteAddr = realIP
 	}
 
 	entry := m.Logger.NewEntry()
 
 	entry = m.Before(entry, r, remoteAddr)
+	if fields := m.baggageFields(r); fields != nil {
+		entry = entry.WithField("baggage", fields)
+	}
 
 	if m.logStarting {
 		entry.Log(logLevel, "started handling request")
 	}
 
+	var reqBody *limitedBuffer
+	var resCapture *capturingResponseWriter
+	if m.capture != nil {
+		if r.Body != nil && r.Body != http.NoBody && m.capture.matches(r.Header.Get("Content-Type")) {
+			reqBody = &limitedBuffer{max: m.capture.maxSize}
+			r.Body = &capturingReadCloser{ReadCloser: r.Body, b: reqBody}
+		}
+		if res, ok := rw.(negroni.ResponseWriter); ok {
+			resCapture = &capturingResponseWriter{ResponseWriter: res, c: m.capture}
+			rw = resCapture
+		}
+	}
+
 	next(rw, r)
 
 	latency := m.clock.Since(start)
+	if resCapture != nil {
+		rw = resCapture.ResponseWriter
+	}
 	res := rw.(negroni.ResponseWriter)
 
-	m.After(entry, r, res, latency, m.Name).Log(logLevel, "completed handling request")
+	entry = m.After(entry, r, res, latency, m.Name)
+	if reqBody != nil {
+		entry = entry.WithField("http_request_body", reqBody.field(r.Header.Get("Content-Type")))
+	}
+	if resCapture != nil && resCapture.b != nil {
+		entry = entry.WithField("http_response_body", resCapture.b.field(res.Header().Get("Content-Type")))
+	}
+	entry.Log(logLevel, "completed handling request")
+}
+
+func (m *Middleware) baggageFields(r *http.Request) map[string]string {
+	if len(m.baggageKeys) == 0 {
+		return nil
+	}
+
+	b := baggage.FromContext(r.Context())
+	if b.Len() == 0 {
+		b = baggage.FromContext(propagation.Baggage{}.Extract(r.Context(), propagation.HeaderCarrier(r.Header)))
+	}
+
+	var fields map[string]string
+	for _, k := range m.baggageKeys {
+		if member := b.Member(k); member.Key() != "" {
+			if fields == nil {
+				fields = make(map[string]string, len(m.baggageKeys))
+			}
+			fields[k] = member.Value()
+		}
+	}
+	return fields
 }
 
 // BeforeFunc is the func type used to modify or replace the *logrusx.Logger prior
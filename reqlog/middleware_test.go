@@ -11,8 +11,11 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
 	"github.com/urfave/negroni"
 
+	"github.com/ory/x/httpx"
 	"github.com/ory/x/logrusx"
 )
 
@@ -157,6 +160,48 @@ func TestMiddleware_ServeHTTP_BeforeOverride(t *testing.T) {
 		lines[1], lines[1])
 }
 
+func TestMiddleware_ServeHTTP_TrustForwardedHeaders(t *testing.T) {
+	p, err := httpx.NewForwardedParser("10.0.0.0/8")
+	assert.NoError(t, err)
+
+	for _, tc := range []struct {
+		remote, expected string
+	}{
+		{remote: "10.0.0.1:1234", expected: "203.0.113.1"},
+		{remote: "198.51.100.1:1234", expected: "198.51.100.1"},
+	} {
+		mw, rec, req := setupServeHTTP(t)
+		mw.TrustForwardedHeaders(p)
+		req.RemoteAddr = tc.remote
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+		var remoteAddr string
+		mw.Before = func(entry *logrusx.Logger, _ *http.Request, addr string) *logrusx.Logger {
+			remoteAddr = addr
+			return entry
+		}
+		mw.ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(418)
+		})
+		assert.Equal(t, tc.expected, remoteAddr)
+	}
+}
+
+func TestMiddleware_ServeHTTP_LogBaggage(t *testing.T) {
+	mw, rec, req := setupServeHTTP(t)
+	mw.LogBaggage("tenant", "missing")
+	req.Header.Set("Baggage", "tenant=acme,secret=foo")
+
+	mw.ServeHTTP(rec, req, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(418)
+	})
+	lines := strings.Split(strings.TrimSpace(mw.Logger.Logger.Out.(*bytes.Buffer).String()), "\n")
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Equal(t, `{"tenant":"acme"}`, gjson.Get(line, "baggage").Raw, line)
+	}
+}
+
 func TestMiddleware_ServeHTTP_AfterOverride(t *testing.T) {
 	mw, rec, req := setupServeHTTP(t)
 	mw.After = func(entry *logrusx.Logger, _ *http.Request, _ negroni.ResponseWriter, _ time.Duration, _ string) *logrusx.Logger {
@@ -48,7 +48,8 @@ func handlePostgres(err error, sqlState string) error {
 	switch sqlState {
 	case "23505": // "unique_violation"
 		return errors.Wrap(ErrUniqueViolation, err.Error())
-	case "40001": // "serialization_failure"
+	case "40001", // "serialization_failure", also used by CockroachDB to request a transaction retry
+		"40P01": // "deadlock_detected"
 		return errors.Wrap(ErrConcurrentUpdate, err.Error())
 	case "42P01": // "no such table"
 		return errors.Wrap(ErrNoSuchTable, err.Error())
@@ -81,6 +82,8 @@ func HandleError(err error) error {
 			return errors.Wrap(ErrUniqueViolation, err.Error())
 		case 1146:
 			return errors.Wrap(ErrNoSuchTable, e.Error())
+		case 1205, 1213: // lock wait timeout, deadlock
+			return errors.Wrap(ErrConcurrentUpdate, err.Error())
 		}
 	}
 
@@ -90,3 +93,13 @@ func HandleError(err error) error {
 
 	return errors.WithStack(err)
 }
+
+// IsRetryable returns true if the error signals that the transaction failed because of a
+// concurrent transaction and can be retried, for example a serialization failure in PostgreSQL
+// or CockroachDB, a deadlock in MySQL, or a busy or locked database in SQLite.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return errors.Is(HandleError(err), ErrConcurrentUpdate)
+}
@@ -21,6 +21,8 @@ func handleSqlite(err error) error {
 		}
 
 		switch e.Code {
+		case sqlite3.ErrBusy, sqlite3.ErrLocked:
+			return errors.Wrap(ErrConcurrentUpdate, err.Error())
 		case sqlite3.ErrError:
 			if strings.Contains(err.Error(), "no such table") {
 				return errors.Wrap(ErrNoSuchTable, err.Error())
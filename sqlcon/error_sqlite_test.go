@@ -0,0 +1,18 @@
+//go:build sqlite
+// +build sqlite
+
+package sqlcon
+
+import (
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleSqliteError(t *testing.T) {
+	assert.ErrorIs(t, HandleError(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}), ErrUniqueViolation)
+	assert.True(t, IsRetryable(sqlite3.Error{Code: sqlite3.ErrBusy}))
+	assert.True(t, IsRetryable(sqlite3.Error{Code: sqlite3.ErrLocked}))
+	assert.False(t, IsRetryable(sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintUnique}))
+}
@@ -0,0 +1,37 @@
+package sqlcon
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleError(t *testing.T) {
+	for k, tc := range []struct {
+		err       error
+		expected  error
+		retryable bool
+	}{
+		{err: sql.ErrNoRows, expected: ErrNoRows},
+		{err: &pq.Error{Code: "23505"}, expected: ErrUniqueViolation},
+		{err: &pq.Error{Code: "40001"}, expected: ErrConcurrentUpdate, retryable: true},
+		{err: &pgconn.PgError{Code: "40001", Message: "restart transaction: TransactionRetryWithProtoRefreshError"}, expected: ErrConcurrentUpdate, retryable: true},
+		{err: errors.WithStack(&pgconn.PgError{Code: "40P01"}), expected: ErrConcurrentUpdate, retryable: true},
+		{err: &pgconn.PgError{Code: "42P01"}, expected: ErrNoSuchTable},
+		{err: &mysql.MySQLError{Number: 1062}, expected: ErrUniqueViolation},
+		{err: &mysql.MySQLError{Number: 1213}, expected: ErrConcurrentUpdate, retryable: true},
+		{err: &mysql.MySQLError{Number: 1205}, expected: ErrConcurrentUpdate, retryable: true},
+	} {
+		assert.ErrorIs(t, HandleError(tc.err), tc.expected, "%d", k)
+		assert.Equal(t, tc.retryable, IsRetryable(tc.err), "%d", k)
+	}
+
+	assert.False(t, IsRetryable(nil))
+	assert.False(t, IsRetryable(errors.New("foo")))
+	assert.True(t, IsRetryable(HandleError(&pq.Error{Code: "40001"})))
+}
@@ -0,0 +1,133 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// fakeDriver is a database/sql driver whose connections succeed or fail depending on the
+// DSN, which allows testing connection handling without a database.
+type fakeDriver struct {
+	mu      sync.Mutex
+	failing map[string]error
+	opened  map[string]int
+	queries []string
+}
+
+var fake = &fakeDriver{failing: map[string]error{}, opened: map[string]int{}}
+
+func init() {
+	sql.Register("sqlcon-fake", fake)
+}
+
+func (d *fakeDriver) fail(dsn string, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err == nil {
+		delete(d.failing, dsn)
+		return
+	}
+	d.failing[dsn] = err
+}
+
+func (d *fakeDriver) err(dsn string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.failing[dsn]
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	if err := d.err(dsn); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.opened[dsn]++
+	d.mu.Unlock()
+	return &fakeConn{d: d, dsn: dsn}, nil
+}
+
+type fakeConn struct {
+	d   *fakeDriver
+	dsn string
+}
+
+var (
+	_ driver.Pinger             = (*fakeConn)(nil)
+	_ driver.QueryerContext     = (*fakeConn)(nil)
+	_ driver.ExecerContext      = (*fakeConn)(nil)
+	_ driver.ConnBeginTx        = (*fakeConn)(nil)
+	_ driver.NamedValueChecker  = (*fakeConn)(nil)
+	_ driver.ConnPrepareContext = (*fakeConn)(nil)
+)
+
+func (c *fakeConn) Ping(context.Context) error {
+	return c.d.err(c.dsn)
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements are not supported")
+}
+
+func (c *fakeConn) PrepareContext(_ context.Context, query string) (driver.Stmt, error) {
+	return c.Prepare(query)
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *fakeConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return fakeTx{}, c.d.err(c.dsn)
+}
+
+func (c *fakeConn) CheckNamedValue(*driver.NamedValue) error {
+	return nil
+}
+
+func (c *fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.exec(query); err != nil {
+		return nil, err
+	}
+	return &fakeRows{}, nil
+}
+
+func (c *fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.exec(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) exec(query string) error {
+	c.d.mu.Lock()
+	c.d.queries = append(c.d.queries, c.dsn+": "+query)
+	c.d.mu.Unlock()
+	return c.d.err(c.dsn + ": " + query)
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ done bool }
+
+func (r *fakeRows) Columns() []string { return []string{"value"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	r.done = true
+	dest[0] = int64(1)
+	return nil
+}
@@ -0,0 +1,384 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/logrusx"
+)
+
+const instrumentationName = "github.com/ory/x/sqlcon"
+
+type instrumentation struct {
+	tracer        trace.Tracer
+	system        string
+	l             *logrusx.Logger
+	slowThreshold time.Duration
+}
+
+// InstrumentationOption configures InstrumentDriver and InstrumentConnector.
+type InstrumentationOption func(*instrumentation)
+
+// WithTracerProvider creates spans using p instead of the global tracer provider.
+func WithTracerProvider(p trace.TracerProvider) InstrumentationOption {
+	return func(i *instrumentation) {
+		i.tracer = p.Tracer(instrumentationName)
+	}
+}
+
+// WithDBSystem sets the "db.system" span attribute, for example "postgresql" or "mysql". For
+// "postgresql", "cockroachdb", and "sqlite", double-quoted identifiers are kept in the recorded
+// statements instead of being replaced like strings.
+func WithDBSystem(system string) InstrumentationOption {
+	return func(i *instrumentation) {
+		i.system = system
+	}
+}
+
+// WithSlowQueryLog logs statements which take at least threshold. The values of the statement
+// parameters are never logged.
+func WithSlowQueryLog(l *logrusx.Logger, threshold time.Duration) InstrumentationOption {
+	return func(i *instrumentation) {
+		i.l = l
+		i.slowThreshold = threshold
+	}
+}
+
+func newInstrumentation(opts []InstrumentationOption) *instrumentation {
+	i := new(instrumentation)
+	for _, o := range opts {
+		o(i)
+	}
+	if i.tracer == nil {
+		i.tracer = otel.GetTracerProvider().Tracer(instrumentationName)
+	}
+	return i
+}
+
+// quotedIdentifiers returns true if the database uses double quotes for identifiers instead of
+// strings.
+func (i *instrumentation) quotedIdentifiers() bool {
+	switch i.system {
+	case "postgresql", "cockroachdb", "sqlite":
+		return true
+	}
+	return false
+}
+
+var (
+	sqlNumericLiteral = regexp.MustCompile(`(^|[^\w$.?])-?\d+(?:\.\d+)?`)
+	sqlWhitespace     = regexp.MustCompile(`\s+`)
+)
+
+// SanitizeQuery replaces string and numeric literals in the SQL query with "?", so that it can
+// be recorded without leaking values which were not passed as parameters. Single-quoted,
+// double-quoted (MySQL), and dollar-quoted (PostgreSQL) strings are replaced.
+func SanitizeQuery(query string) string {
+	return sanitizeQuery(query, false)
+}
+
+// sanitizeQuery works like SanitizeQuery, but keeps double-quoted identifiers if the database
+// does not use double quotes for strings.
+func sanitizeQuery(query string, quotedIdentifiers bool) string {
+	var b strings.Builder
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == '\'':
+			i = skipQuoted(query, i, true)
+			b.WriteByte('?')
+		case c == '"' && quotedIdentifiers:
+			end := skipQuoted(query, i, false)
+			b.WriteString(query[i:end])
+			i = end
+		case c == '"':
+			i = skipQuoted(query, i, true)
+			b.WriteByte('?')
+		case c == '$' && dollarTag(query[i:]) != "":
+			tag := dollarTag(query[i:])
+			if end := strings.Index(query[i+len(tag):], tag); end >= 0 {
+				i += len(tag) + end + len(tag)
+			} else {
+				i = len(query)
+			}
+			b.WriteByte('?')
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	query = sqlNumericLiteral.ReplaceAllString(b.String(), "${1}?")
+	return strings.TrimSpace(sqlWhitespace.ReplaceAllString(query, " "))
+}
+
+// skipQuoted returns the index after the quoted string starting at i. Doubled quotes, and
+// backslash escapes if enabled, do not end the string. Unterminated strings end with the query.
+func skipQuoted(query string, i int, backslash bool) int {
+	quote := query[i]
+	for j := i + 1; j < len(query); j++ {
+		switch {
+		case backslash && query[j] == '\\':
+			j++
+		case query[j] == quote && j+1 < len(query) && query[j+1] == quote:
+			j++
+		case query[j] == quote:
+			return j + 1
+		}
+	}
+	return len(query)
+}
+
+// dollarTag returns the opening tag of a dollar-quoted string, like "$$" or "$body$", or "" if
+// the query does not start with one. Positional parameters like "$1" are not tags.
+func dollarTag(query string) string {
+	for j := 1; j < len(query); j++ {
+		c := rune(query[j])
+		switch {
+		case c == '$':
+			return query[:j+1]
+		case c == '_' || unicode.IsLetter(c) || j > 1 && unicode.IsDigit(c):
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+func (i *instrumentation) observe(ctx context.Context, query string, args []driver.NamedValue, fn func(ctx context.Context) error) error {
+	start := time.Now()
+	err := fn(ctx)
+	end := time.Now()
+	took := end.Sub(start)
+
+	if errors.Is(err, driver.ErrSkip) {
+		// The statement is executed again using a prepared statement, which is observed instead.
+		return err
+	}
+
+	sanitized := sanitizeQuery(query, i.quotedIdentifiers())
+	operation := strings.ToUpper(strings.SplitN(sanitized, " ", 2)[0])
+	name := operation
+	if name == "" {
+		name = "sql"
+	}
+
+	attrs := []attribute.KeyValue{semconv.DBStatementKey.String(sanitized)}
+	if operation != "" {
+		attrs = append(attrs, semconv.DBOperationKey.String(operation))
+	}
+	if i.system != "" {
+		attrs = append(attrs, semconv.DBSystemKey.String(i.system))
+	}
+
+	// The span is created once the statement completed, so that no span is recorded for
+	// statements the driver skipped.
+	ctx, span := i.tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+		trace.WithTimestamp(start))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+
+	if i.l != nil && took >= i.slowThreshold {
+		redacted := make([]string, len(args))
+		for k := range redacted {
+			redacted[k] = logrusx.Redacted
+		}
+		l := i.l.WithContext(ctx).
+			WithField("sql_query", sanitized).
+			WithField("sql_args", redacted).
+			WithField("duration", took.String())
+		if err != nil {
+			l = l.WithError(err)
+		}
+		l.Warn("Slow SQL query detected.")
+	}
+	return err
+}
+
+// InstrumentDriver wraps the driver so that every statement creates an OpenTelemetry span with
+// the sanitized SQL query. Register the result to use it with sql.Open:
+//
+//	sql.Register("postgres-instrumented", sqlcon.InstrumentDriver(&pq.Driver{}, sqlcon.WithDBSystem("postgresql")))
+func InstrumentDriver(d driver.Driver, opts ...InstrumentationOption) driver.Driver {
+	return &instrumentedDriver{d: d, i: newInstrumentation(opts)}
+}
+
+// InstrumentConnector wraps the connector like InstrumentDriver, for use with sql.OpenDB.
+func InstrumentConnector(c driver.Connector, opts ...InstrumentationOption) driver.Connector {
+	i := newInstrumentation(opts)
+	return &instrumentedConnector{c: c, d: &instrumentedDriver{d: c.Driver(), i: i}, i: i}
+}
+
+type instrumentedDriver struct {
+	d driver.Driver
+	i *instrumentation
+}
+
+var (
+	_ driver.Driver        = (*instrumentedDriver)(nil)
+	_ driver.DriverContext = (*instrumentedDriver)(nil)
+)
+
+func (d *instrumentedDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.d.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{wrappedConn: wrappedConn{Conn: conn}, i: d.i}, nil
+}
+
+func (d *instrumentedDriver) OpenConnector(dsn string) (driver.Connector, error) {
+	if dc, ok := d.d.(driver.DriverContext); ok {
+		c, err := dc.OpenConnector(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &instrumentedConnector{c: c, d: d, i: d.i}, nil
+	}
+	return &dsnConnector{d: d, dsn: dsn}, nil
+}
+
+type dsnConnector struct {
+	d   driver.Driver
+	dsn string
+}
+
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.d.Open(c.dsn)
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.d
+}
+
+type instrumentedConnector struct {
+	c driver.Connector
+	d driver.Driver
+	i *instrumentation
+}
+
+func (c *instrumentedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.c.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedConn{wrappedConn: wrappedConn{Conn: conn}, i: c.i}, nil
+}
+
+func (c *instrumentedConnector) Driver() driver.Driver {
+	return c.d
+}
+
+type instrumentedConn struct {
+	wrappedConn
+	i *instrumentation
+}
+
+func (c *instrumentedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (res driver.Result, err error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	err = c.i.observe(ctx, query, args, func(ctx context.Context) (err error) {
+		res, err = e.ExecContext(ctx, query, args)
+		return err
+	})
+	return res, err
+}
+
+func (c *instrumentedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (rows driver.Rows, err error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+	err = c.i.observe(ctx, query, args, func(ctx context.Context) (err error) {
+		rows, err = q.QueryContext(ctx, query, args)
+		return err
+	})
+	return rows, err
+}
+
+func (c *instrumentedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	stmt, err := c.wrappedConn.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return &instrumentedStmt{Stmt: stmt, query: query, i: c.i}, nil
+}
+
+type instrumentedStmt struct {
+	driver.Stmt
+	query string
+	i     *instrumentation
+}
+
+var (
+	_ driver.StmtExecContext   = (*instrumentedStmt)(nil)
+	_ driver.StmtQueryContext  = (*instrumentedStmt)(nil)
+	_ driver.NamedValueChecker = (*instrumentedStmt)(nil)
+)
+
+func (s *instrumentedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (res driver.Result, err error) {
+	err = s.i.observe(ctx, s.query, args, func(ctx context.Context) (err error) {
+		if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+			res, err = e.ExecContext(ctx, args)
+			return err
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		res, err = s.Stmt.Exec(values) // nolint:staticcheck
+		return err
+	})
+	return res, err
+}
+
+func (s *instrumentedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (rows driver.Rows, err error) {
+	err = s.i.observe(ctx, s.query, args, func(ctx context.Context) (err error) {
+		if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+			rows, err = q.QueryContext(ctx, args)
+			return err
+		}
+		values, err := namedValuesToValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = s.Stmt.Query(values) // nolint:staticcheck
+		return err
+	})
+	return rows, err
+}
+
+func (s *instrumentedStmt) CheckNamedValue(v *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(v)
+	}
+	return driver.ErrSkip
+}
+
+func namedValuesToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for k, arg := range args {
+		if arg.Name != "" {
+			return nil, errors.New("the driver does not support named parameters")
+		}
+		values[k] = arg.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,111 @@
+package sqlcon
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/ory/x/logrusx"
+)
+
+func TestSanitizeQuery(t *testing.T) {
+	for in, expected := range map[string]string{
+		"SELECT * FROM users WHERE email = 'foo@bar.com' AND age > 21":       "SELECT * FROM users WHERE email = ? AND age > ?",
+		"SELECT * FROM t1 WHERE id = $1 AND name = 'it''s'\n\t LIMIT 10":     "SELECT * FROM t1 WHERE id = $1 AND name = ? LIMIT ?",
+		"UPDATE accounts SET balance = -12.5 WHERE id IN (1, 2)":             "UPDATE accounts SET balance = ? WHERE id IN (?, ?)",
+		"INSERT INTO logs (msg) VALUES (?)":                                  "INSERT INTO logs (msg) VALUES (?)",
+		`SELECT * FROM users WHERE email = "foo@bar.com"`:                    "SELECT * FROM users WHERE email = ?",
+		`SELECT * FROM users WHERE name = 'it\'s' OR name = "say ""hi"""`:    "SELECT * FROM users WHERE name = ? OR name = ?",
+		"SELECT $$it's a secret$$, $body$ $$ nested $$ $body$ WHERE id = $1": "SELECT ?, ? WHERE id = $1",
+		"SELECT 'unterminated secret":                                        "SELECT ?",
+	} {
+		assert.Equal(t, expected, SanitizeQuery(in), in)
+	}
+
+	assert.Equal(t, `SELECT "id" FROM "users" WHERE email = ?`, sanitizeQuery(`SELECT "id" FROM "users" WHERE email = 'foo@bar.com'`, true))
+}
+
+func TestInstrumentDriver(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var out bytes.Buffer
+	l := logrusx.New("sqlcon", "test", logrusx.ForceFormat("json"))
+	l.Logrus().SetOutput(&out)
+
+	sql.Register("sqlcon-fake-instrumented", InstrumentDriver(fake,
+		WithTracerProvider(tp),
+		WithDBSystem("fake"),
+		WithSlowQueryLog(l, 0),
+	))
+	db, err := sql.Open("sqlcon-fake-instrumented", "instrumented")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	_, err = db.ExecContext(ctx, "UPDATE users SET password = 'secret' WHERE id = $1", "user-id")
+	require.NoError(t, err)
+
+	var one int
+	require.NoError(t, db.QueryRowContext(ctx, "SELECT 1").Scan(&one))
+
+	fake.fail("instrumented: DELETE FROM users", errors.New("permission denied"))
+	defer fake.fail("instrumented: DELETE FROM users", nil)
+	_, err = db.ExecContext(ctx, "DELETE FROM users")
+	require.Error(t, err)
+
+	// Statements the driver skips are executed as prepared statements, so they are not recorded.
+	fake.fail("instrumented: SELECT skipped", driver.ErrSkip)
+	defer fake.fail("instrumented: SELECT skipped", nil)
+	_, err = db.ExecContext(ctx, "SELECT skipped")
+	require.Error(t, err)
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 3)
+
+	assert.Equal(t, "UPDATE", spans[0].Name())
+	attrs := map[string]string{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsString()
+	}
+	assert.Equal(t, map[string]string{
+		"db.statement": "UPDATE users SET password = ? WHERE id = $1",
+		"db.operation": "UPDATE",
+		"db.system":    "fake",
+	}, attrs)
+
+	assert.Equal(t, "SELECT", spans[1].Name())
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+	assert.Equal(t, "permission denied", spans[2].Status().Description)
+
+	logs := out.String()
+	assert.Contains(t, logs, "Slow SQL query detected.")
+	assert.Contains(t, logs, `"sql_query":"UPDATE users SET password = ? WHERE id = $1"`)
+	assert.Contains(t, logs, `"sql_args":["`+logrusx.Redacted+`"]`)
+	assert.NotContains(t, logs, "secret")
+	assert.NotContains(t, logs, "user-id")
+}
+
+func TestInstrumentConnector(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	c := InstrumentConnector(NewRotatingConnector(fake, func(context.Context) (string, error) {
+		return "instrumented-connector", nil
+	}), WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))))
+	db := sql.OpenDB(c)
+	defer db.Close()
+
+	_, err := db.Exec("SELECT 1")
+	require.NoError(t, err)
+	require.Len(t, recorder.Ended(), 1)
+	assert.Equal(t, "SELECT", recorder.Ended()[0].Name())
+}
@@ -0,0 +1,67 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/ory/x/healthx"
+)
+
+// WatchPoolStats calls fn with the statistics of the connection pool every interval until ctx
+// is done.
+func WatchPoolStats(ctx context.Context, db *sql.DB, interval time.Duration, fn func(sql.DBStats)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(db.Stats())
+		}
+	}
+}
+
+// NewPoolStatsCollector returns a Prometheus collector exporting the statistics of the
+// connection pool, such as open, in-use and idle connections and the time spent waiting for
+// a connection. The name is added to all metrics as the "db_name" label.
+func NewPoolStatsCollector(db *sql.DB, name string) prometheus.Collector {
+	return collectors.NewDBStatsCollector(db, name)
+}
+
+// NewPoolReadyChecker returns a readiness check which fails if the database does not respond
+// to a ping, or if the pool is saturated: at least the share saturation (between 0 and 1) of
+// the maximum open connections is in use and requests had to wait for a connection since the
+// previous check. Set saturation to zero to only ping the database.
+func NewPoolReadyChecker(db *sql.DB, saturation float64) healthx.ReadyChecker {
+	var (
+		mu        sync.Mutex
+		waitCount int64
+	)
+	return func(r *http.Request) error {
+		if saturation > 0 {
+			stats := db.Stats()
+			mu.Lock()
+			waited := stats.WaitCount > waitCount
+			waitCount = stats.WaitCount
+			mu.Unlock()
+
+			// Check saturation first, because pinging a saturated pool blocks until a connection
+			// is released.
+			if stats.MaxOpenConnections > 0 && waited &&
+				float64(stats.InUse) >= saturation*float64(stats.MaxOpenConnections) {
+				return errors.Errorf("the database connection pool is saturated: %d of %d connections are in use and %d requests waited %s for a connection in total",
+					stats.InUse, stats.MaxOpenConnections, stats.WaitCount, stats.WaitDuration)
+			}
+		}
+
+		return errors.WithStack(db.PingContext(r.Context()))
+	}
+}
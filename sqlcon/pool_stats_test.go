@@ -0,0 +1,79 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolStats(t *testing.T) {
+	db, err := sql.Open("sqlcon-fake", "pool-stats")
+	require.NoError(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	t.Run("func=WatchPoolStats", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		stats := make(chan sql.DBStats, 1)
+		go WatchPoolStats(ctx, db, 5*time.Millisecond, func(s sql.DBStats) {
+			select {
+			case stats <- s:
+			default:
+			}
+		})
+		defer cancel()
+
+		select {
+		case s := <-stats:
+			assert.Equal(t, 1, s.MaxOpenConnections)
+		case <-time.After(time.Second):
+			t.Fatal("the callback was not called")
+		}
+	})
+
+	t.Run("func=NewPoolStatsCollector", func(t *testing.T) {
+		reg := prometheus.NewPedanticRegistry()
+		require.NoError(t, reg.Register(NewPoolStatsCollector(db, "pool-stats")))
+		require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP go_sql_max_open_connections Maximum number of open connections to the database.
+# TYPE go_sql_max_open_connections gauge
+go_sql_max_open_connections{db_name="pool-stats"} 1
+`), "go_sql_max_open_connections"))
+	})
+
+	t.Run("func=NewPoolReadyChecker", func(t *testing.T) {
+		check := NewPoolReadyChecker(db, 0.9)
+		req := httptest.NewRequest("GET", "/health/ready", nil)
+		require.NoError(t, check(req))
+
+		// Hold the only connection so that the next query has to wait.
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			_, _ = db.Exec("SELECT 1")
+		}()
+		assert.Eventually(t, func() bool { return db.Stats().WaitCount > 0 }, time.Second, time.Millisecond)
+
+		assert.Error(t, check(req))
+		require.NoError(t, conn.Close())
+		<-done
+
+		assert.NoError(t, check(req), "no further requests waited")
+
+		fake.fail("pool-stats", errors.New("connection refused"))
+		defer fake.fail("pool-stats", nil)
+		db.SetMaxIdleConns(0)
+		assert.Error(t, NewPoolReadyChecker(db, 0)(req))
+	})
+}
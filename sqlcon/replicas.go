@@ -0,0 +1,208 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/logrusx"
+)
+
+// ReplicaSet splits reads and writes between a primary database and its read replicas.
+// Writes always use the primary. Reads are distributed round-robin across the replicas which
+// are healthy, and fall back to the primary if none is.
+type ReplicaSet struct {
+	primary  *sql.DB
+	replicas []*replica
+	next     uint32
+
+	l             *logrusx.Logger
+	checkInterval time.Duration
+	checkTimeout  time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+type replica struct {
+	db      *sql.DB
+	name    string
+	healthy int32
+}
+
+// ReplicaSetOption configures a ReplicaSet.
+type ReplicaSetOption func(*ReplicaSet)
+
+// WithReplicaHealthCheck pings the replicas every interval and stops reading from replicas
+// which do not respond within timeout until they respond again.
+func WithReplicaHealthCheck(interval, timeout time.Duration) ReplicaSetOption {
+	return func(r *ReplicaSet) {
+		r.checkInterval = interval
+		r.checkTimeout = timeout
+	}
+}
+
+// WithReplicaSetLogger logs replicas whose health changes.
+func WithReplicaSetLogger(l *logrusx.Logger) ReplicaSetOption {
+	return func(r *ReplicaSet) {
+		r.l = l
+	}
+}
+
+// NewReplicaSet creates a replica set of already opened connection pools. All replicas are
+// considered healthy until a health check fails.
+func NewReplicaSet(primary *sql.DB, replicas []*sql.DB, opts ...ReplicaSetOption) *ReplicaSet {
+	r := &ReplicaSet{
+		primary:      primary,
+		checkTimeout: 5 * time.Second,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	for k, db := range replicas {
+		r.replicas = append(r.replicas, &replica{db: db, name: "replica " + strconv.Itoa(k), healthy: 1})
+	}
+	for _, o := range opts {
+		o(r)
+	}
+	if r.l == nil {
+		r.l = logrusx.New("", "")
+	}
+
+	if r.checkInterval > 0 && len(r.replicas) > 0 {
+		go r.watch()
+	} else {
+		close(r.done)
+	}
+	return r
+}
+
+// OpenReplicaSet opens connection pools to the primary and the replicas using the driver. The
+// connection options of each DSN (see ParseConnectionOptions) are applied to its pool.
+func OpenReplicaSet(l *logrusx.Logger, driverName, primaryDSN string, replicaDSNs []string, opts ...ReplicaSetOption) (*ReplicaSet, error) {
+	open := func(dsn string) (*sql.DB, error) {
+		maxConns, maxIdleConns, maxConnLifetime, maxIdleConnTime, cleanedDSN := ParseConnectionOptions(l, dsn)
+		db, err := sql.Open(driverName, cleanedDSN)
+		if err != nil {
+			return nil, errors.Wrapf(err, "unable to open database %s", classifyDSN(dsn))
+		}
+		db.SetMaxOpenConns(maxConns)
+		db.SetMaxIdleConns(maxIdleConns)
+		db.SetConnMaxLifetime(maxConnLifetime)
+		db.SetConnMaxIdleTime(maxIdleConnTime)
+		return db, nil
+	}
+
+	primary, err := open(primaryDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas := make([]*sql.DB, 0, len(replicaDSNs))
+	for _, dsn := range replicaDSNs {
+		db, err := open(dsn)
+		if err != nil {
+			_ = primary.Close()
+			for _, r := range replicas {
+				_ = r.Close()
+			}
+			return nil, err
+		}
+		replicas = append(replicas, db)
+	}
+
+	return NewReplicaSet(primary, replicas, append([]ReplicaSetOption{WithReplicaSetLogger(l)}, opts...)...), nil
+}
+
+// Primary returns the connection pool of the primary, which must be used for writes and for
+// reads which must observe the latest writes.
+func (r *ReplicaSet) Primary() *sql.DB {
+	return r.primary
+}
+
+// Reader returns the connection pool of the next healthy replica, or the primary if there are
+// no healthy replicas.
+func (r *ReplicaSet) Reader() *sql.DB {
+	n := len(r.replicas)
+	if n == 0 {
+		return r.primary
+	}
+
+	start := int(atomic.AddUint32(&r.next, 1))
+	for k := 0; k < n; k++ {
+		if rep := r.replicas[(start+k)%n]; atomic.LoadInt32(&rep.healthy) == 1 {
+			return rep.db
+		}
+	}
+	return r.primary
+}
+
+// Check pings all replicas and updates their health.
+func (r *ReplicaSet) Check(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, rep := range r.replicas {
+		wg.Add(1)
+		go func(rep *replica) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(ctx, r.checkTimeout)
+			defer cancel()
+
+			err := rep.db.PingContext(ctx)
+			if err != nil {
+				if atomic.SwapInt32(&rep.healthy, 0) == 1 {
+					r.l.WithError(err).WithField("replica", rep.name).Warn("Read replica is unhealthy, reading from the other replicas or the primary instead.")
+				}
+				return
+			}
+			if atomic.SwapInt32(&rep.healthy, 1) == 0 {
+				r.l.WithField("replica", rep.name).Info("Read replica is healthy again.")
+			}
+		}(rep)
+	}
+	wg.Wait()
+}
+
+func (r *ReplicaSet) watch() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.checkInterval)
+	defer ticker.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-r.stop
+		cancel()
+	}()
+
+	for {
+		r.Check(ctx)
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close stops the health checks and closes all connection pools.
+func (r *ReplicaSet) Close() error {
+	r.shutdown.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+
+	err := r.primary.Close()
+	for _, rep := range r.replicas {
+		if rerr := rep.db.Close(); err == nil {
+			err = rerr
+		}
+	}
+	return errors.WithStack(err)
+}
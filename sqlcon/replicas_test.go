@@ -0,0 +1,80 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+)
+
+func TestReplicaSet(t *testing.T) {
+	name := func(db *sql.DB) string {
+		var dsn string
+		conn, err := db.Conn(context.Background())
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.Raw(func(c interface{}) error {
+			dsn = c.(*fakeConn).dsn
+			return nil
+		}))
+		return dsn
+	}
+
+	t.Run("case=reads from replicas and falls back to the primary", func(t *testing.T) {
+		r, err := OpenReplicaSet(logrusx.New("", ""), "sqlcon-fake", "rs-primary?max_conns=5", []string{"rs-replica-a", "rs-replica-b"})
+		require.NoError(t, err)
+		defer r.Close()
+
+		assert.Equal(t, "rs-primary?", name(r.Primary()))
+		assert.Equal(t, 5, r.Primary().Stats().MaxOpenConnections)
+
+		seen := map[string]bool{}
+		for i := 0; i < 4; i++ {
+			seen[name(r.Reader())] = true
+		}
+		assert.Equal(t, map[string]bool{"rs-replica-a": true, "rs-replica-b": true}, seen)
+
+		fake.fail("rs-replica-a", errors.New("connection refused"))
+		defer fake.fail("rs-replica-a", nil)
+		r.Check(context.Background())
+		for i := 0; i < 4; i++ {
+			assert.Equal(t, "rs-replica-b", name(r.Reader()))
+		}
+
+		fake.fail("rs-replica-b", errors.New("connection refused"))
+		defer fake.fail("rs-replica-b", nil)
+		r.Check(context.Background())
+		assert.Equal(t, "rs-primary?", name(r.Reader()))
+
+		fake.fail("rs-replica-a", nil)
+		r.Check(context.Background())
+		assert.Equal(t, "rs-replica-a", name(r.Reader()))
+	})
+
+	t.Run("case=checks health periodically", func(t *testing.T) {
+		fake.fail("rs-replica-c", errors.New("connection refused"))
+		defer fake.fail("rs-replica-c", nil)
+
+		r, err := OpenReplicaSet(logrusx.New("", ""), "sqlcon-fake", "rs-primary", []string{"rs-replica-c"},
+			WithReplicaHealthCheck(10*time.Millisecond, time.Second))
+		require.NoError(t, err)
+		defer r.Close()
+
+		assert.Eventually(t, func() bool { return r.Reader() == r.Primary() }, time.Second, 10*time.Millisecond)
+		fake.fail("rs-replica-c", nil)
+		assert.Eventually(t, func() bool { return r.Reader() != r.Primary() }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("case=without replicas", func(t *testing.T) {
+		r, err := OpenReplicaSet(logrusx.New("", ""), "sqlcon-fake", "rs-primary", nil)
+		require.NoError(t, err)
+		assert.Equal(t, r.Primary(), r.Reader())
+		require.NoError(t, r.Close())
+	})
+}
@@ -0,0 +1,182 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DSNProvider returns the current DSN, including up to date credentials.
+type DSNProvider func(ctx context.Context) (string, error)
+
+// RotatingConnector is a driver.Connector which asks a DSNProvider for the DSN whenever it opens
+// a connection, so that rotated credentials are used without a restart. Before a pooled
+// connection is reused, the DSN is checked again at most once per check interval. Once the DSN
+// changes, pooled connections opened with the previous DSN are closed instead of being reused,
+// and connections in use are closed when they are returned to the pool.
+type RotatingConnector struct {
+	d        driver.Driver
+	provider DSNProvider
+	interval time.Duration
+
+	mu        sync.RWMutex
+	dsn       string
+	checkedAt time.Time
+}
+
+// RotatingConnectorOption configures RotatingConnector.
+type RotatingConnectorOption func(*RotatingConnector)
+
+// WithRotationCheckInterval sets how often the DSN is checked before pooled connections are
+// reused. Defaults to 30 seconds.
+func WithRotationCheckInterval(interval time.Duration) RotatingConnectorOption {
+	return func(c *RotatingConnector) {
+		c.interval = interval
+	}
+}
+
+var _ driver.Connector = (*RotatingConnector)(nil)
+
+// NewRotatingConnector returns a connector opening connections using d.
+func NewRotatingConnector(d driver.Driver, provider DSNProvider, opts ...RotatingConnectorOption) *RotatingConnector {
+	c := &RotatingConnector{d: d, provider: provider, interval: 30 * time.Second}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// OpenRotating opens a database using the registered driver and a RotatingConnector.
+func OpenRotating(driverName string, provider DSNProvider, opts ...RotatingConnectorOption) (*sql.DB, error) {
+	db, err := sql.Open(driverName, "")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	d := db.Driver()
+	_ = db.Close()
+
+	return sql.OpenDB(NewRotatingConnector(d, provider, opts...)), nil
+}
+
+// Refresh asks the provider for the DSN. If it changed, connections using the previous DSN are
+// drained.
+func (c *RotatingConnector) Refresh(ctx context.Context) (string, error) {
+	dsn, err := c.provider(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to get the database DSN")
+	}
+
+	c.mu.Lock()
+	c.dsn, c.checkedAt = dsn, time.Now()
+	c.mu.Unlock()
+	return dsn, nil
+}
+
+func (c *RotatingConnector) current() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.dsn
+}
+
+// check refreshes the DSN if it was not checked within the check interval and returns it.
+func (c *RotatingConnector) check(ctx context.Context) string {
+	c.mu.Lock()
+	if time.Since(c.checkedAt) < c.interval {
+		dsn := c.dsn
+		c.mu.Unlock()
+		return dsn
+	}
+	// Concurrent checks use the previous DSN instead of asking the provider again.
+	c.checkedAt = time.Now()
+	c.mu.Unlock()
+
+	// If the provider fails, connections keep working with the credentials they were opened
+	// with, and the next connection which is opened reports the error.
+	_, _ = c.Refresh(ctx)
+	return c.current()
+}
+
+// Connect implements driver.Connector.
+func (c *RotatingConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	dsn, err := c.Refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := openConn(ctx, c.d, dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingConn{wrappedConn: wrappedConn{Conn: conn}, c: c, dsn: dsn}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *RotatingConnector) Driver() driver.Driver {
+	return c.d
+}
+
+// rotatingConn is invalidated once the DSN it was opened with is outdated.
+type rotatingConn struct {
+	wrappedConn
+	c   *RotatingConnector
+	dsn string
+}
+
+func (c *rotatingConn) IsValid() bool {
+	return c.dsn == c.c.current() && c.wrappedConn.IsValid()
+}
+
+func (c *rotatingConn) ResetSession(ctx context.Context) error {
+	if c.dsn != c.c.check(ctx) {
+		return driver.ErrBadConn
+	}
+	return c.wrappedConn.ResetSession(ctx)
+}
+
+// PasswordFromFile returns a DSNProvider which sets the password of the URL-style DSN to the
+// content of the file at path, for example a secret mounted by Kubernetes. The file is read
+// again whenever its modification time changes.
+func PasswordFromFile(dsn, path string) DSNProvider {
+	var (
+		mu      sync.Mutex
+		modTime time.Time
+		result  string
+	)
+	return func(context.Context) (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		if result != "" && fi.ModTime().Equal(modTime) {
+			return result, nil
+		}
+
+		password, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", errors.Wrap(err, "unable to parse the DSN")
+		}
+		if u.User == nil {
+			return "", errors.New("the DSN must contain a user name to set the password")
+		}
+		u.User = url.UserPassword(u.User.Username(), strings.TrimSpace(string(password)))
+
+		modTime, result = fi.ModTime(), u.String()
+		return result, nil
+	}
+}
@@ -0,0 +1,138 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TxRetryOptions configures WithTxRetry.
+type TxRetryOptions struct {
+	// TxOptions are passed to sql.DB.BeginTx.
+	TxOptions *sql.TxOptions
+
+	// MaxAttempts is the maximum number of times the transaction is run. Defaults to 5.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the first retry, which doubles with every retry up to
+	// MaxBackoff. A random jitter of up to the wait is added. Defaults to 20ms and 1s.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+
+	// Metrics counts retries, if set.
+	Metrics *TxRetryMetrics
+}
+
+// TxRetryMetrics counts transaction retries. Register it with a prometheus.Registerer.
+type TxRetryMetrics struct {
+	retries  prometheus.Counter
+	failures prometheus.Counter
+}
+
+var _ prometheus.Collector = (*TxRetryMetrics)(nil)
+
+// NewTxRetryMetrics creates the metrics "<namespace>_sql_tx_retries_total", counting retried
+// transactions, and "<namespace>_sql_tx_retries_exhausted_total", counting transactions which
+// failed with a retryable error after the last attempt.
+func NewTxRetryMetrics(namespace string) *TxRetryMetrics {
+	return &TxRetryMetrics{
+		retries: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sql_tx_retries_total",
+			Help:      "Number of times a transaction was retried because of a serialization failure or deadlock.",
+		}),
+		failures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sql_tx_retries_exhausted_total",
+			Help:      "Number of transactions which still failed with a retryable error after the last attempt.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (m *TxRetryMetrics) Describe(in chan<- *prometheus.Desc) {
+	m.retries.Describe(in)
+	m.failures.Describe(in)
+}
+
+// Collect implements prometheus.Collector.
+func (m *TxRetryMetrics) Collect(in chan<- prometheus.Metric) {
+	m.retries.Collect(in)
+	m.failures.Collect(in)
+}
+
+// WithTxRetry runs fn in a transaction and commits it. If fn or the commit fail with an error
+// for which IsRetryable returns true, for example a serialization failure in CockroachDB, the
+// transaction is rolled back and run again after a backoff until opts.MaxAttempts is reached.
+// Because fn may run several times, it must not have side effects outside the transaction.
+//
+// opts may be nil to use the defaults.
+func WithTxRetry(ctx context.Context, db *sql.DB, opts *TxRetryOptions, fn func(tx *sql.Tx) error) error {
+	if opts == nil {
+		opts = new(TxRetryOptions)
+	}
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	wait := opts.InitialBackoff
+	if wait <= 0 {
+		wait = 20 * time.Millisecond
+	}
+	maxWait := opts.MaxBackoff
+	if maxWait <= 0 {
+		maxWait = time.Second
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, db, opts.TxOptions, fn)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt >= attempts {
+			if opts.Metrics != nil {
+				opts.Metrics.failures.Inc()
+			}
+			return errors.WithMessagef(err, "the transaction failed after %d attempts", attempt)
+		}
+		if opts.Metrics != nil {
+			opts.Metrics.retries.Inc()
+		}
+
+		// #nosec G404 -- the jitter does not need a secure random source
+		backoff := wait + time.Duration(rand.Int63n(int64(wait)))
+		select {
+		case <-ctx.Done():
+			return errors.WithStack(ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		if wait *= 2; wait > maxWait {
+			wait = maxWait
+		}
+	}
+}
+
+func runTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			_ = tx.Rollback()
+			panic(r)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return errors.WithStack(tx.Commit())
+}
@@ -0,0 +1,81 @@
+package sqlcon
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithTxRetry(t *testing.T) {
+	db, err := sql.Open("sqlcon-fake", "tx-retry")
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx := context.Background()
+	serializationFailure := &pq.Error{Code: "40001", Message: "restart transaction"}
+
+	t.Run("case=retries serialization failures", func(t *testing.T) {
+		m := NewTxRetryMetrics("test")
+		var calls int
+		require.NoError(t, WithTxRetry(ctx, db, &TxRetryOptions{InitialBackoff: time.Millisecond, Metrics: m}, func(tx *sql.Tx) error {
+			calls++
+			if calls < 3 {
+				return serializationFailure
+			}
+			_, err := tx.ExecContext(ctx, "SELECT 1")
+			return err
+		}))
+		assert.Equal(t, 3, calls)
+		assert.EqualValues(t, 2, testutil.ToFloat64(m.retries))
+		assert.EqualValues(t, 0, testutil.ToFloat64(m.failures))
+	})
+
+	t.Run("case=gives up after the maximum attempts", func(t *testing.T) {
+		m := NewTxRetryMetrics("test")
+		var calls int
+		err := WithTxRetry(ctx, db, &TxRetryOptions{MaxAttempts: 2, InitialBackoff: time.Millisecond, Metrics: m}, func(tx *sql.Tx) error {
+			calls++
+			return serializationFailure
+		})
+		require.Error(t, err)
+		assert.True(t, IsRetryable(err))
+		assert.Equal(t, 2, calls)
+		assert.EqualValues(t, 1, testutil.ToFloat64(m.retries))
+		assert.EqualValues(t, 1, testutil.ToFloat64(m.failures))
+	})
+
+	t.Run("case=does not retry other errors", func(t *testing.T) {
+		var calls int
+		expected := errors.New("not retryable")
+		err := WithTxRetry(ctx, db, nil, func(tx *sql.Tx) error {
+			calls++
+			return expected
+		})
+		assert.Equal(t, expected, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("case=stops when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ctx)
+		err := WithTxRetry(ctx, db, &TxRetryOptions{InitialBackoff: time.Hour}, func(tx *sql.Tx) error {
+			cancel()
+			return serializationFailure
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("case=rolls back and re-panics", func(t *testing.T) {
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = WithTxRetry(ctx, db, nil, func(tx *sql.Tx) error {
+				panic("boom")
+			})
+		})
+	})
+}
@@ -1,6 +1,9 @@
 package stringsx
 
-import "unicode"
+import (
+	"strings"
+	"unicode"
+)
 
 // ToLowerInitial converts a string's first character to lower case.
 func ToLowerInitial(s string) string {
@@ -21,3 +24,102 @@ func ToUpperInitial(s string) string {
 	a[0] = unicode.ToUpper(a[0])
 	return string(a)
 }
+
+// commonInitialisms are rendered in upper case by ToCamelCase and ToPascalCase, like in Go
+// identifiers.
+var commonInitialisms = map[string]bool{
+	"ACL": true, "API": true, "ASCII": true, "CPU": true, "CSS": true, "DNS": true, "EOF": true,
+	"GUID": true, "HTML": true, "HTTP": true, "HTTPS": true, "ID": true, "IP": true, "JSON": true,
+	"JWT": true, "LHS": true, "OIDC": true, "QPS": true, "RAM": true, "RHS": true, "RPC": true,
+	"SLA": true, "SMTP": true, "SQL": true, "SSH": true, "TCP": true, "TLS": true, "TTL": true,
+	"UDP": true, "UI": true, "UID": true, "URI": true, "URL": true, "UTF8": true, "UUID": true,
+	"VM": true, "XML": true, "XMPP": true, "XSRF": true, "XSS": true,
+}
+
+// Words splits s into words at separators like spaces, dashes, and underscores, and at case
+// changes. Acronyms are kept together, so "parseHTTPRequestID" results in "parse", "HTTP",
+// "Request", and "ID". Digits belong to the preceding word, as in "utf8" or "Base64".
+func Words(s string) []string {
+	var (
+		words []string
+		word  []rune
+	)
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+
+		if len(word) > 0 && unicode.IsUpper(r) {
+			prev := word[len(word)-1]
+			nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			// the plural of an acronym, like "IDs" in "userIDs" or "URLsToFetch"; before another
+			// word only known acronyms count, so that "userIDIsValid" splits before "Is"
+			plural := nextIsLower && runes[i+1] == 's' &&
+				(i+2 == len(runes) || !unicode.IsLetter(runes[i+2]) ||
+					unicode.IsUpper(runes[i+2]) && commonInitialisms[strings.ToUpper(string(word)+string(r))])
+			// "fooBar" and "HTTPServer" split before "B" and "S"
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || unicode.IsUpper(prev) && nextIsLower && !plural {
+				flush()
+			}
+		}
+		word = append(word, r)
+	}
+	flush()
+	return words
+}
+
+func joinWords(s, sep string) string {
+	words := Words(s)
+	for k, w := range words {
+		words[k] = strings.ToLower(w)
+	}
+	return strings.Join(words, sep)
+}
+
+// ToSnakeCase converts s to snake case, for example "http_request_id" for "HTTPRequestID".
+func ToSnakeCase(s string) string {
+	return joinWords(s, "_")
+}
+
+// ToKebabCase converts s to kebab case, for example "http-request-id" for "HTTPRequestID".
+func ToKebabCase(s string) string {
+	return joinWords(s, "-")
+}
+
+// ToPascalCase converts s to Pascal case, also known as upper camel case, for example
+// "HTTPRequestID" for "http_request_id". Common initialisms like ID, URL, or HTTP are upper cased.
+func ToPascalCase(s string) string {
+	var b strings.Builder
+	for _, w := range Words(s) {
+		upper := strings.ToUpper(w)
+		if commonInitialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		if plural := strings.TrimSuffix(upper, "S"); len(plural) < len(upper) && commonInitialisms[plural] {
+			b.WriteString(plural + "s")
+			continue
+		}
+		b.WriteString(ToUpperInitial(strings.ToLower(w)))
+	}
+	return b.String()
+}
+
+// ToCamelCase converts s to camel case, for example "httpRequestID" for "http_request_id". Common
+// initialisms like ID, URL, or HTTP are upper cased, unless they are the first word.
+func ToCamelCase(s string) string {
+	words := Words(s)
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(words[0]) + ToPascalCase(strings.Join(words[1:], " "))
+}
@@ -21,3 +21,53 @@ func TestToUpperInitial(t *testing.T) {
 	assert.Equal(t, "AB", ToUpperInitial("aB"))
 	assert.Equal(t, "Ab", ToUpperInitial("ab"))
 }
+
+func TestWords(t *testing.T) {
+	for in, expected := range map[string][]string{
+		"":                   nil,
+		"foo":                {"foo"},
+		"fooBar":             {"foo", "Bar"},
+		"FooBar":             {"Foo", "Bar"},
+		"parseHTTPRequestID": {"parse", "HTTP", "Request", "ID"},
+		"HTTPServer":         {"HTTP", "Server"},
+		"userIDs":            {"user", "IDs"},
+		"URLsToFetch":        {"URLs", "To", "Fetch"},
+		"userIDIsValid":      {"user", "ID", "Is", "Valid"},
+		"URLIsSet":           {"URL", "Is", "Set"},
+		"SKUs":               {"SKUs"},
+		"foo_bar-baz qux":    {"foo", "bar", "baz", "qux"},
+		"  __foo--bar__  ":   {"foo", "bar"},
+		"base64Encode":       {"base64", "Encode"},
+		"utf8String":         {"utf8", "String"},
+		"ÄrgerÜberÖl":        {"Ärger", "Über", "Öl"},
+		"ALLCAPS":            {"ALLCAPS"},
+	} {
+		assert.Equal(t, expected, Words(in), in)
+	}
+}
+
+func TestCaseConversion(t *testing.T) {
+	for _, tc := range []struct {
+		in, snake, kebab, camel, pascal string
+	}{
+		{in: "", snake: "", kebab: "", camel: "", pascal: ""},
+		{in: "foo", snake: "foo", kebab: "foo", camel: "foo", pascal: "Foo"},
+		{in: "HTTPRequestID", snake: "http_request_id", kebab: "http-request-id", camel: "httpRequestID", pascal: "HTTPRequestID"},
+		{in: "http_request_id", snake: "http_request_id", kebab: "http-request-id", camel: "httpRequestID", pascal: "HTTPRequestID"},
+		{in: "user-ids", snake: "user_ids", kebab: "user-ids", camel: "userIDs", pascal: "UserIDs"},
+		{in: "userIDs", snake: "user_ids", kebab: "user-ids", camel: "userIDs", pascal: "UserIDs"},
+		{in: "userIDIsValid", snake: "user_id_is_valid", kebab: "user-id-is-valid", camel: "userIDIsValid", pascal: "UserIDIsValid"},
+		{in: "URLIsSet", snake: "url_is_set", kebab: "url-is-set", camel: "urlIsSet", pascal: "URLIsSet"},
+		{in: "Api Url", snake: "api_url", kebab: "api-url", camel: "apiURL", pascal: "APIURL"},
+		{in: "created at", snake: "created_at", kebab: "created-at", camel: "createdAt", pascal: "CreatedAt"},
+		{in: "base64_encoded_value", snake: "base64_encoded_value", kebab: "base64-encoded-value", camel: "base64EncodedValue", pascal: "Base64EncodedValue"},
+		{in: "größe_in_mm", snake: "größe_in_mm", kebab: "größe-in-mm", camel: "größeInMm", pascal: "GrößeInMm"},
+	} {
+		t.Run("case="+tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.snake, ToSnakeCase(tc.in))
+			assert.Equal(t, tc.kebab, ToKebabCase(tc.in))
+			assert.Equal(t, tc.camel, ToCamelCase(tc.in))
+			assert.Equal(t, tc.pascal, ToPascalCase(tc.in))
+		})
+	}
+}
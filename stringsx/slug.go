@@ -0,0 +1,47 @@
+package stringsx
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// slugReplacements are letters which do not decompose into a base letter and accents.
+var slugReplacements = map[rune]string{
+	'ß': "ss", 'æ': "ae", 'œ': "oe", 'ø': "o", 'đ': "d", 'ð': "d", 'ł': "l", 'þ': "th", 'ı': "i",
+}
+
+// Slugify returns a URL slug of s, for example "creme-brulee-fur-2-personen" for
+// "Crème brûlée für 2 Personen!". Accents are removed from letters, which are lower cased, and
+// all other characters are replaced by single dashes. Letters of scripts without a Latin
+// transliteration, for example Cyrillic or Han, are kept.
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range norm.NFKD.String(s) {
+		r = unicode.ToLower(r)
+
+		var out string
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// accents of the decomposed letters
+			continue
+		case slugReplacements[r] != "":
+			out = slugReplacements[r]
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			out = string(r)
+		default:
+			// consecutive separators and separators at the start result in a single or no dash
+			dash = b.Len() > 0
+			continue
+		}
+
+		if dash {
+			b.WriteByte('-')
+			dash = false
+		}
+		b.WriteString(out)
+	}
+	return b.String()
+}
@@ -0,0 +1,26 @@
+package stringsx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlugify(t *testing.T) {
+	for in, expected := range map[string]string{
+		"":                              "",
+		"Hello World":                   "hello-world",
+		"Crème brûlée für 2 Personen!":  "creme-brulee-fur-2-personen",
+		"  --Leading and trailing--  ":  "leading-and-trailing",
+		"Straße & Ærø":                  "strasse-aero",
+		"Łódź":                          "lodz",
+		"ﬁle №1":                        "file-no1",
+		"Привет, мир":                   "привет-мир",
+		"日本語のタイトル":                      "日本語のタイトル",
+		"emoji 🎉 party":                 "emoji-party",
+		"already-a-slug":                "already-a-slug",
+		"multiple   spaces\tand\nlines": "multiple-spaces-and-lines",
+	} {
+		assert.Equal(t, expected, Slugify(in), in)
+	}
+}
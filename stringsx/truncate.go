@@ -0,0 +1,124 @@
+package stringsx
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	zeroWidthJoiner = '\u200d'
+	keycapCombiner  = '\u20e3'
+)
+
+// extendsCluster returns true if r belongs to the grapheme cluster of the preceding rune.
+func extendsCluster(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		r == zeroWidthJoiner || r == keycapCombiner ||
+		unicode.Is(unicode.Variation_Selector, r) ||
+		// emoji skin tone modifiers
+		r >= 0x1f3fb && r <= 0x1f3ff ||
+		// emoji tag sequences, for example of subdivision flags
+		r >= 0xe0020 && r <= 0xe007f
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
+
+// graphemeEnds returns the byte offsets at which the grapheme clusters of s end. It covers
+// combining marks, emoji modifiers, ZWJ sequences, flags, and CRLF, which is an approximation of
+// the extended grapheme clusters of Unicode Standard Annex #29 that is sufficient to never split
+// user-perceived characters of common text.
+func graphemeEnds(s string) []int {
+	var (
+		ends []int
+		prev rune
+		// regional indicators form flags in pairs
+		regional int
+	)
+	for i, r := range s {
+		joined := i > 0 && (extendsCluster(r) ||
+			prev == zeroWidthJoiner ||
+			prev == '\r' && r == '\n' ||
+			isRegionalIndicator(r) && regional%2 == 1)
+		if i > 0 && !joined {
+			ends = append(ends, i)
+		}
+
+		if isRegionalIndicator(r) {
+			regional++
+		} else if !extendsCluster(r) {
+			regional = 0
+		}
+		prev = r
+	}
+	if len(s) > 0 {
+		ends = append(ends, len(s))
+	}
+	return ends
+}
+
+// GraphemeCount returns the number of user-perceived characters of s, which differs from the
+// number of runes for example for emoji sequences and decomposed accents.
+func GraphemeCount(s string) int {
+	return len(graphemeEnds(s))
+}
+
+// TruncateRunes returns the first n runes of s. Unlike slicing, it never splits a rune.
+func TruncateRunes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
+
+// TruncateGraphemes returns the first n user-perceived characters of s. Unlike TruncateRunes, it
+// never splits emoji sequences or separates accents from their base character.
+func TruncateGraphemes(s string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+	if ends := graphemeEnds(s); n < len(ends) {
+		return s[:ends[n-1]]
+	}
+	return s
+}
+
+// Ellipsize shortens s to at most n user-perceived characters including the ellipsis, for example
+// "…" or "...". Trailing whitespace is removed before appending the ellipsis. If s is short
+// enough, it is returned unchanged. If the ellipsis does not fit, s is truncated without it.
+func Ellipsize(s string, n int, ellipsis string) string {
+	ends := graphemeEnds(s)
+	if len(ends) <= n {
+		return s
+	}
+
+	keep := n - GraphemeCount(ellipsis)
+	if keep <= 0 {
+		return TruncateGraphemes(s, n)
+	}
+	return strings.TrimRightFunc(s[:ends[keep-1]], unicode.IsSpace) + ellipsis
+}
+
+// TruncateBytes returns the longest prefix of s with at most n bytes which does not split a
+// user-perceived character, for example to fit into a database column measured in bytes.
+func TruncateBytes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	end := 0
+	for _, e := range graphemeEnds(s) {
+		if e > n {
+			break
+		}
+		end = e
+	}
+	return s[:end]
+}
@@ -0,0 +1,109 @@
+package stringsx
+
+import (
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	family     = "👨‍👩‍👧"
+	thumbsUp   = "👍🏽"
+	flagDE     = "🇩🇪"
+	flagFR     = "🇫🇷"
+	decomposed = "é" // é as e and a combining acute accent
+	keycap     = "1️⃣"
+)
+
+func TestGraphemeCount(t *testing.T) {
+	for in, expected := range map[string]int{
+		"":                 0,
+		"abc":              3,
+		"héllo":            5,
+		decomposed:         1,
+		family:             1,
+		thumbsUp:           1,
+		flagDE + flagFR:    2,
+		keycap:             1,
+		"a\r\nb":           3,
+		"x" + family + "y": 3,
+	} {
+		assert.Equal(t, expected, GraphemeCount(in), "%q", in)
+	}
+}
+
+func TestTruncateRunes(t *testing.T) {
+	assert.Equal(t, "", TruncateRunes("héllo", 0))
+	assert.Equal(t, "", TruncateRunes("héllo", -1))
+	assert.Equal(t, "hé", TruncateRunes("héllo", 2))
+	assert.Equal(t, "héllo", TruncateRunes("héllo", 5))
+	assert.Equal(t, "héllo", TruncateRunes("héllo", 10))
+	assert.Equal(t, "日本", TruncateRunes("日本語", 2))
+}
+
+func TestTruncateGraphemes(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		n        int
+		expected string
+	}{
+		{in: "hello", n: 0, expected: ""},
+		{in: "hello", n: 3, expected: "hel"},
+		{in: "hello", n: 10, expected: "hello"},
+		{in: "caf" + decomposed + "s", n: 4, expected: "caf" + decomposed},
+		{in: family + family, n: 1, expected: family},
+		{in: "hi " + thumbsUp + "!", n: 4, expected: "hi " + thumbsUp},
+		{in: flagDE + flagFR, n: 1, expected: flagDE},
+		{in: keycap + "2", n: 1, expected: keycap},
+	} {
+		t.Run("case="+tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.expected, TruncateGraphemes(tc.in, tc.n))
+		})
+	}
+}
+
+func TestEllipsize(t *testing.T) {
+	for _, tc := range []struct {
+		in, ellipsis string
+		n            int
+		expected     string
+	}{
+		{in: "hello world", ellipsis: "…", n: 20, expected: "hello world"},
+		{in: "hello world", ellipsis: "…", n: 11, expected: "hello world"},
+		{in: "hello world", ellipsis: "…", n: 8, expected: "hello w…"},
+		{in: "hello world", ellipsis: "…", n: 7, expected: "hello…"},
+		{in: "hello world", ellipsis: "...", n: 8, expected: "hello..."},
+		{in: "hello world", ellipsis: "...", n: 2, expected: "he"},
+		{in: "hello world", ellipsis: "…", n: 0, expected: ""},
+		{in: family + family + family, ellipsis: "…", n: 2, expected: family + "…"},
+		{in: "caf" + decomposed + " au lait", ellipsis: "…", n: 5, expected: "caf" + decomposed + "…"},
+	} {
+		t.Run("case="+tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Ellipsize(tc.in, tc.n, tc.ellipsis))
+		})
+	}
+}
+
+func TestTruncateBytes(t *testing.T) {
+	for _, tc := range []struct {
+		in       string
+		n        int
+		expected string
+	}{
+		{in: "hello", n: 10, expected: "hello"},
+		{in: "hello", n: 3, expected: "hel"},
+		{in: "hello", n: 0, expected: ""},
+		{in: "héllo", n: 2, expected: "h"},
+		{in: "héllo", n: 3, expected: "hé"},
+		{in: "a" + decomposed, n: 2, expected: "a"},
+		{in: "a" + family, n: len(family), expected: "a"},
+		{in: "a" + family, n: len(family) + 1, expected: "a" + family},
+	} {
+		t.Run("case="+tc.in, func(t *testing.T) {
+			actual := TruncateBytes(tc.in, tc.n)
+			assert.Equal(t, tc.expected, actual)
+			assert.True(t, utf8.ValidString(actual))
+		})
+	}
+}
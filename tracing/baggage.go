@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/ory/x/logrusx"
+)
+
+// BaggagePolicy restricts the OpenTelemetry baggage members which may cross service
+// boundaries to an allowlist of keys.
+type BaggagePolicy struct {
+	allowed map[string]bool
+}
+
+// NewBaggagePolicy returns a policy allowing the given baggage keys.
+func NewBaggagePolicy(allowedKeys ...string) *BaggagePolicy {
+	p := &BaggagePolicy{allowed: make(map[string]bool, len(allowedKeys))}
+	for _, k := range allowedKeys {
+		p.allowed[k] = true
+	}
+	return p
+}
+
+// Allowed returns true if the key may be propagated.
+func (p *BaggagePolicy) Allowed(key string) bool {
+	return p.allowed[key]
+}
+
+// SetBaggage returns a copy of ctx whose baggage contains the member key=value. It fails if
+// the key is not allowed or the member is invalid.
+func (p *BaggagePolicy) SetBaggage(ctx context.Context, key, value string) (context.Context, error) {
+	if !p.Allowed(key) {
+		return nil, errors.Errorf("the baggage key %q is not allowed", key)
+	}
+
+	m, err := baggage.NewMember(key, value)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	b, err := baggage.FromContext(ctx).SetMember(m)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return baggage.ContextWithBaggage(ctx, b), nil
+}
+
+// Baggage returns the value of the allowed baggage member key, or an empty string.
+func (p *BaggagePolicy) Baggage(ctx context.Context, key string) string {
+	if !p.Allowed(key) {
+		return ""
+	}
+	return baggage.FromContext(ctx).Member(key).Value()
+}
+
+// Filter removes all members whose key is not allowed.
+func (p *BaggagePolicy) Filter(b baggage.Baggage) baggage.Baggage {
+	for _, m := range b.Members() {
+		if !p.Allowed(m.Key()) {
+			b = b.DeleteMember(m.Key())
+		}
+	}
+	return b
+}
+
+// Inject adds the allowed baggage members of ctx to the headers of an outgoing request.
+func (p *BaggagePolicy) Inject(ctx context.Context, h http.Header) {
+	ctx = baggage.ContextWithBaggage(ctx, p.Filter(baggage.FromContext(ctx)))
+	propagation.Baggage{}.Inject(ctx, propagation.HeaderCarrier(h))
+}
+
+// Extract returns a copy of ctx containing the allowed baggage members of the headers of an
+// incoming request. Members which are not allowed are discarded.
+func (p *BaggagePolicy) Extract(ctx context.Context, h http.Header) context.Context {
+	ctx = propagation.Baggage{}.Extract(ctx, propagation.HeaderCarrier(h))
+	return baggage.ContextWithBaggage(ctx, p.Filter(baggage.FromContext(ctx)))
+}
+
+// Fields returns the allowed baggage members of ctx.
+func (p *BaggagePolicy) Fields(ctx context.Context) map[string]string {
+	members := p.Filter(baggage.FromContext(ctx)).Members()
+	if len(members) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(members))
+	for _, m := range members {
+		fields[m.Key()] = m.Value()
+	}
+	return fields
+}
+
+// WithBaggage adds the allowed baggage members of ctx to the "baggage" field of the logger.
+func (p *BaggagePolicy) WithBaggage(l *logrusx.Logger, ctx context.Context) *logrusx.Logger {
+	if fields := p.Fields(ctx); fields != nil {
+		return l.WithField("baggage", fields)
+	}
+	return l
+}
+
+// ServeHTTP is a negroni middleware extracting the allowed baggage members of the request
+// into its context.
+func (p *BaggagePolicy) ServeHTTP(rw http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	next(rw, r.WithContext(p.Extract(r.Context(), r.Header)))
+}
@@ -0,0 +1,61 @@
+package tracing_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tidwall/gjson"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/tracing"
+)
+
+func TestBaggagePolicy(t *testing.T) {
+	p := tracing.NewBaggagePolicy("tenant", "region")
+
+	t.Run("case=sets and reads allowed keys", func(t *testing.T) {
+		ctx, err := p.SetBaggage(context.Background(), "tenant", "acme")
+		require.NoError(t, err)
+		assert.Equal(t, "acme", p.Baggage(ctx, "tenant"))
+
+		_, err = p.SetBaggage(ctx, "user_id", "1234")
+		require.Error(t, err)
+	})
+
+	t.Run("case=propagates only allowed keys", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Baggage", "tenant=acme,user_id=1234")
+		ctx := p.Extract(context.Background(), h)
+		assert.Equal(t, map[string]string{"tenant": "acme"}, p.Fields(ctx))
+
+		out := http.Header{}
+		p.Inject(ctx, out)
+		assert.Equal(t, "tenant=acme", out.Get("Baggage"))
+	})
+
+	t.Run("case=adds baggage to log fields", func(t *testing.T) {
+		var b bytes.Buffer
+		l := logrusx.New("ory/x", "1", logrusx.ForceFormat("json"))
+		l.Logger.Out = &b
+
+		ctx := p.Extract(context.Background(), http.Header{"Baggage": {"region=eu"}})
+		p.WithBaggage(l, ctx).Info("hello")
+		assert.Equal(t, `{"region":"eu"}`, gjson.Get(b.String(), "baggage").Raw)
+	})
+
+	t.Run("case=middleware extracts baggage", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Baggage", "region=eu,user_id=1234")
+
+		var fields map[string]string
+		p.ServeHTTP(httptest.NewRecorder(), req, func(_ http.ResponseWriter, r *http.Request) {
+			fields = p.Fields(r.Context())
+		})
+		assert.Equal(t, map[string]string{"region": "eu"}, fields)
+	})
+}
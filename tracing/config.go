@@ -29,11 +29,142 @@ type Config struct {
 	ServiceName string           `json:"service_name"`
 	Provider    string           `json:"provider"`
 	Providers   *ProvidersConfig `json:"providers"`
+	Logs        *LogsConfig      `json:"logs,omitempty"`
+	Spans       *SpansConfig     `json:"spans,omitempty"`
+	Sampling    *SamplingConfig  `json:"sampling,omitempty"`
 }
 
 type ProvidersConfig struct {
 	Jaeger *JaegerConfig `json:"jaeger"`
 	Zipkin *ZipkinConfig `json:"zipkin"`
+	OTLP   *OTLPConfig   `json:"otlp,omitempty"`
+}
+
+// LogsConfig configures exporting log entries using OTLP.
+type LogsConfig struct {
+	// Exporter is either "otlp" or empty, which disables exporting logs.
+	Exporter string      `json:"exporter"`
+	OTLP     *OTLPConfig `json:"otlp,omitempty"`
+
+	// Level is the least severe level which is exported, for example "warn". Defaults to all
+	// levels enabled in the logger.
+	Level string `json:"level,omitempty"`
+
+	// Interval between two exports, for example "5s". Defaults to five seconds.
+	Interval string `json:"interval,omitempty"`
+
+	// BatchSize is the number of entries which triggers an export before the interval has
+	// passed. Defaults to 512.
+	BatchSize int `json:"batch_size,omitempty"`
+
+	// MaxQueueSize is the number of entries which are queued at most, for example while the
+	// collector is unreachable. Further entries are dropped. Defaults to 2048, and is at least
+	// the batch size.
+	MaxQueueSize int `json:"max_queue_size,omitempty"`
+}
+
+// SpansConfig configures which spans are exported by the OpenTelemetry provider. Patterns
+// support the wildcards of path.Match and are matched case-insensitively.
+type SpansConfig struct {
+	// DropNames drops spans whose name matches one of the patterns.
+	DropNames []string `json:"drop_names,omitempty"`
+
+	// DropURLs drops spans whose HTTP route, target or URL path matches one of the patterns,
+	// for example "/health/*".
+	DropURLs []string `json:"drop_urls,omitempty"`
+
+	// RedactAttributes replaces the values of span and event attributes whose key matches one
+	// of the patterns, for example "*password*".
+	RedactAttributes []string `json:"redact_attributes,omitempty"`
+}
+
+// SamplingConfig configures the sampler of the OpenTelemetry provider.
+type SamplingConfig struct {
+	// Ratio of root spans which are sampled if no route matches. Defaults to 1.
+	Ratio *float64 `json:"ratio,omitempty"`
+
+	// Routes overrides the ratio for matching HTTP routes or gRPC methods. The first matching
+	// route applies.
+	Routes []RouteSamplingConfig `json:"routes,omitempty"`
+
+	// KeepErrors exports spans which end with an error status even if they were not sampled.
+	// Every span which is not sampled is then recorded, so the sampling ratio no longer reduces
+	// the cost of creating spans, only the number of exported spans. Error spans are exported
+	// without the spans of their trace which did not fail, so their parents may be missing.
+	KeepErrors bool `json:"keep_errors,omitempty"`
+	// Remote fetches the sampling strategy from a Jaeger compatible sampling endpoint. The
+	// strategy replaces Ratio once it was fetched.
+	Remote *RemoteSamplingConfig `json:"remote,omitempty"`
+}
+
+// RemoteSamplingConfig configures NewRemoteSampler.
+type RemoteSamplingConfig struct {
+	// ServerURL is the address of the sampling endpoint, for example
+	// "http://localhost:5778/sampling".
+	ServerURL string `json:"server_url"`
+
+	// RefreshInterval between two fetches of the strategy, for example "30s". Defaults to one
+	// minute.
+	RefreshInterval string `json:"refresh_interval,omitempty"`
+}
+
+// RouteSamplingConfig configures the sampling ratio of a route.
+type RouteSamplingConfig struct {
+	// Route is matched case-insensitively against the HTTP route or target, the gRPC method
+	// ("service/method") and the span name. It supports the wildcards of path.Match, for
+	// example "/health/*".
+	Route string `json:"route"`
+
+	// Ratio of matching root spans which are sampled.
+	Ratio float64 `json:"ratio"`
+}
+
+// MetricsConfig configures NewMeterProvider.
+type MetricsConfig struct {
+	ServiceName string                  `json:"service_name"`
+	Exporter    string                  `json:"exporter"`
+	Exporters   *MetricsExportersConfig `json:"exporters"`
+	Views       []MetricsView           `json:"views"`
+
+	// DisableRuntimeMetrics disables the Go runtime and process metrics which are registered
+	// by default.
+	DisableRuntimeMetrics bool `json:"disable_runtime_metrics,omitempty"`
+}
+
+// MetricsExportersConfig configures the metrics exporters.
+type MetricsExportersConfig struct {
+	OTLP *OTLPMetricsConfig `json:"otlp"`
+}
+
+// OTLPMetricsConfig configures pushing metrics using OTLP over HTTP.
+type OTLPMetricsConfig struct {
+	OTLPConfig
+
+	// Interval between two exports, for example "30s". Defaults to one minute.
+	Interval string `json:"interval"`
+}
+
+// MetricsView customizes the instruments whose name matches Instrument.
+type MetricsView struct {
+	// Instrument is the name of the instruments this view applies to. It may contain the
+	// wildcards supported by path.Match, for example "http.server.*".
+	Instrument string `json:"instrument"`
+
+	// Name renames the instrument.
+	Name string `json:"name,omitempty"`
+
+	// Description replaces the description of the instrument.
+	Description string `json:"description,omitempty"`
+
+	// Drop discards all measurements of the instrument.
+	Drop bool `json:"drop,omitempty"`
+
+	// Buckets are the bucket boundaries of histograms.
+	Buckets []float64 `json:"buckets,omitempty"`
+
+	// AttributeKeys are the attributes which are kept. All other attributes are removed, which
+	// reduces the cardinality of the metric. If empty, all attributes are kept.
+	AttributeKeys []string `json:"attribute_keys,omitempty"`
 }
 
 //go:embed config.schema.json
@@ -48,3 +179,16 @@ func AddConfigSchema(c interface {
 }) error {
 	return c.AddResource(ConfigSchemaID, bytes.NewBufferString(ConfigSchema))
 }
+
+//go:embed metrics.schema.json
+var MetricsConfigSchema string
+
+const MetricsConfigSchemaID = "ory://metrics-config"
+
+// AddMetricsConfigSchema adds the metrics schema to the compiler.
+// The interface is specified instead of `jsonschema.Compiler` to allow the use of any jsonschema library fork or version.
+func AddMetricsConfigSchema(c interface {
+	AddResource(url string, r io.Reader) error
+}) error {
+	return c.AddResource(MetricsConfigSchemaID, bytes.NewBufferString(MetricsConfigSchema))
+}
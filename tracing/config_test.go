@@ -10,6 +10,8 @@ import (
 	"github.com/tidwall/sjson"
 
 	"github.com/ory/jsonschema/v3"
+
+	"github.com/ory/x/pointerx"
 )
 
 const rootSchema = `{
@@ -43,6 +45,28 @@ func TestConfigSchema(t *testing.T) {
 				Zipkin: &ZipkinConfig{
 					ServerURL: "https://example.com",
 				},
+				OTLP: &OTLPConfig{
+					ServerURL: "https://localhost:4318",
+					TLS:       &OTLPTLSConfig{CAFile: "ca.pem", CertFile: "client.pem", KeyFile: "client-key.pem"},
+				},
+			},
+			Logs: &LogsConfig{
+				Exporter:  "otlp",
+				OTLP:      &OTLPConfig{ServerURL: "http://localhost:4318"},
+				Level:     "info",
+				Interval:  "10s",
+				BatchSize: 100,
+			},
+			Spans: &SpansConfig{
+				DropNames:        []string{"health"},
+				DropURLs:         []string{"/health/*"},
+				RedactAttributes: []string{"*password*"},
+			},
+			Sampling: &SamplingConfig{
+				Ratio:      pointerx.Float64(0.5),
+				Routes:     []RouteSamplingConfig{{Route: "/health/*", Ratio: 0}},
+				KeepErrors: true,
+				Remote:     &RemoteSamplingConfig{ServerURL: "http://localhost:5778/sampling", RefreshInterval: "30s"},
 			},
 		}
 
@@ -54,6 +78,39 @@ func TestConfigSchema(t *testing.T) {
 		schema, err := c.Compile("config")
 		require.NoError(t, err)
 
+		assert.NoError(t, schema.Validate(bytes.NewBufferString(rawConfig)))
+	})
+	t.Run("func=AddMetricsConfigSchema", func(t *testing.T) {
+		c := jsonschema.NewCompiler()
+		require.NoError(t, AddMetricsConfigSchema(c))
+
+		conf := MetricsConfig{
+			ServiceName: "Ory X",
+			Exporter:    "otlp",
+			Exporters: &MetricsExportersConfig{
+				OTLP: &OTLPMetricsConfig{
+					OTLPConfig: OTLPConfig{
+						ServerURL: "http://localhost:4318",
+						Headers:   map[string]string{"Authorization": "Bearer foo"},
+					},
+					Interval: "30s",
+				},
+			},
+			Views: []MetricsView{
+				{Instrument: "http.server.*", Buckets: []float64{5, 10}, AttributeKeys: []string{"route"}},
+				{Instrument: "goroutines", Drop: true},
+			},
+			DisableRuntimeMetrics: true,
+		}
+
+		rawConfig, err := sjson.Set("{}", "tracing", &conf)
+		require.NoError(t, err)
+
+		require.NoError(t, c.AddResource("config", bytes.NewBufferString(fmt.Sprintf(rootSchema, MetricsConfigSchemaID))))
+
+		schema, err := c.Compile("config")
+		require.NoError(t, err)
+
 		assert.NoError(t, schema.Validate(bytes.NewBufferString(rawConfig)))
 	})
 }
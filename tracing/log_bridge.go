@@ -0,0 +1,383 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// LogHook is a logrus hook which exports log entries using OTLP. Entries logged with a context
+// containing a span, or with the "trace_id" and "span_id" fields added by
+// logrusx.Logger.WithContext, are correlated with the trace.
+type LogHook struct {
+	dropped uint64
+
+	levels       []logrus.Level
+	exporter     *LogExporter
+	batchSize    int
+	maxQueueSize int
+	onError      func(error)
+
+	mu      sync.Mutex
+	records []*logspb.LogRecord
+
+	trigger  chan struct{}
+	flush    chan chan error
+	stop     chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+var _ logrus.Hook = (*LogHook)(nil)
+
+// LogExporter sends log records to an OpenTelemetry collector using OTLP over HTTP.
+type LogExporter struct {
+	res     *resource.Resource
+	client  *otlpClient
+	library string
+}
+
+// LogRecord is a log record exported by LogExporter.
+type LogRecord struct {
+	Time    time.Time
+	Level   logrus.Level
+	Name    string
+	Message string
+	Fields  map[string]interface{}
+
+	// Context correlates the record with the span it contains, if any.
+	Context context.Context
+}
+
+// NewLogExporter creates an exporter of the log records of the instrumentation library, for
+// example "github.com/ory/x/metricsx", which describes the records as produced by res.
+func NewLogExporter(c *OTLPConfig, res *resource.Resource, library string) (*LogExporter, error) {
+	client, err := newOTLPClient(c, "/v1/logs")
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		res = resource.Empty()
+	}
+	return &LogExporter{res: res, client: client, library: library}, nil
+}
+
+// Export sends the records in one request.
+func (e *LogExporter) Export(ctx context.Context, records []LogRecord) error {
+	out := make([]*logspb.LogRecord, len(records))
+	for k, r := range records {
+		out[k] = r.otlp()
+	}
+	return e.export(ctx, out)
+}
+
+func (e *LogExporter) export(ctx context.Context, records []*logspb.LogRecord) error {
+	return e.client.export(ctx, &collogspb.ExportLogsServiceRequest{ResourceLogs: []*logspb.ResourceLogs{{
+		Resource:  otlpResource(e.res),
+		SchemaUrl: e.res.SchemaURL(),
+		InstrumentationLibraryLogs: []*logspb.InstrumentationLibraryLogs{{
+			InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: e.library},
+			Logs:                   records,
+		}},
+	}}})
+}
+
+// NewLogHook creates a hook exporting log entries of the service as configured by c. Errors
+// which occur while exporting are passed to onError, if not nil. Because the hook is part of
+// the logging pipeline, it must not log these errors using the same logger.
+func NewLogHook(serviceName string, c *LogsConfig, onError func(error)) (*LogHook, error) {
+	if strings.ToLower(c.Exporter) != "otlp" {
+		return nil, errors.Errorf("unknown logs exporter: %s", c.Exporter)
+	}
+
+	if env := os.Getenv("OTEL_SERVICE_NAME"); env != "" {
+		serviceName = env
+	}
+	res, err := resource.New(context.Background(),
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "new otel resource")
+	}
+
+	exporter, err := NewLogExporter(c.OTLP, res, "github.com/ory/x/logrusx")
+	if err != nil {
+		return nil, err
+	}
+
+	levels := logrus.AllLevels
+	if c.Level != "" {
+		min, err := logrus.ParseLevel(c.Level)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		levels = nil
+		for _, l := range logrus.AllLevels {
+			if l <= min {
+				levels = append(levels, l)
+			}
+		}
+	}
+
+	interval := 5 * time.Second
+	if c.Interval != "" {
+		if interval, err = time.ParseDuration(c.Interval); err != nil || interval <= 0 {
+			return nil, errors.Errorf("the OTLP logs export interval must be a positive duration but got: %s", c.Interval)
+		}
+	}
+
+	batchSize := c.BatchSize
+	if batchSize <= 0 {
+		batchSize = 512
+	}
+
+	maxQueueSize := c.MaxQueueSize
+	if maxQueueSize <= 0 {
+		maxQueueSize = 2048
+	}
+	if maxQueueSize < batchSize {
+		maxQueueSize = batchSize
+	}
+
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	h := &LogHook{
+		levels:       levels,
+		exporter:     exporter,
+		batchSize:    batchSize,
+		maxQueueSize: maxQueueSize,
+		onError:      onError,
+		trigger:      make(chan struct{}, 1),
+		flush:        make(chan chan error),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go h.run(interval)
+	return h, nil
+}
+
+// Levels implements logrus.Hook.
+func (h *LogHook) Levels() []logrus.Level {
+	return h.levels
+}
+
+// Fire implements logrus.Hook. The entry is queued and exported asynchronously. Entries fired
+// after Shutdown, or while the queue is full, are discarded.
+func (h *LogHook) Fire(e *logrus.Entry) error {
+	select {
+	case <-h.stop:
+		return nil
+	default:
+	}
+
+	record := LogRecord{Time: e.Time, Level: e.Level, Message: e.Message, Fields: e.Data, Context: e.Context}.otlp()
+
+	h.mu.Lock()
+	if len(h.records) >= h.maxQueueSize {
+		h.mu.Unlock()
+		atomic.AddUint64(&h.dropped, 1)
+		return nil
+	}
+	h.records = append(h.records, record)
+	full := len(h.records) >= h.batchSize
+	h.mu.Unlock()
+
+	if full {
+		select {
+		case h.trigger <- struct{}{}:
+		default:
+			// An export is already pending and picks up these entries as well.
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of entries which were discarded because the queue was full.
+func (h *LogHook) Dropped() uint64 {
+	return atomic.LoadUint64(&h.dropped)
+}
+
+// Flush exports all queued entries.
+func (h *LogHook) Flush(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case h.flush <- result:
+	case <-h.done:
+		return nil
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+}
+
+// Shutdown exports all queued entries and stops exporting.
+func (h *LogHook) Shutdown(ctx context.Context) error {
+	err := h.Flush(ctx)
+	h.shutdown.Do(func() {
+		close(h.stop)
+	})
+	return err
+}
+
+func (h *LogHook) run(interval time.Duration) {
+	defer close(h.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-h.stop:
+			return
+		case <-ticker.C:
+			if err := h.export(); err != nil {
+				h.onError(err)
+			}
+		case <-h.trigger:
+			if err := h.export(); err != nil {
+				h.onError(err)
+			}
+		case result := <-h.flush:
+			result <- h.export()
+		}
+	}
+}
+
+func (h *LogHook) export() error {
+	h.mu.Lock()
+	records := h.records
+	h.records = nil
+	h.mu.Unlock()
+
+	if len(records) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return h.exporter.export(ctx, records)
+}
+
+func otlpSeverity(l logrus.Level) logspb.SeverityNumber {
+	switch l {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_FATAL
+	case logrus.ErrorLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_ERROR
+	case logrus.WarnLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_WARN
+	case logrus.InfoLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_INFO
+	case logrus.DebugLevel:
+		return logspb.SeverityNumber_SEVERITY_NUMBER_DEBUG
+	}
+	return logspb.SeverityNumber_SEVERITY_NUMBER_TRACE
+}
+
+func (r LogRecord) otlp() *logspb.LogRecord {
+	record := &logspb.LogRecord{
+		TimeUnixNano:   uint64(r.Time.UnixNano()),
+		SeverityNumber: otlpSeverity(r.Level),
+		SeverityText:   strings.ToUpper(r.Level.String()),
+		Name:           r.Name,
+		Body:           &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: r.Message}},
+	}
+
+	var spanCtx trace.SpanContext
+	if r.Context != nil {
+		spanCtx = trace.SpanContextFromContext(r.Context)
+	}
+
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]attribute.KeyValue, 0, len(r.Fields))
+	for _, k := range keys {
+		v := r.Fields[k]
+		switch k {
+		case "trace_id":
+			if id, ok := v.(string); ok && !spanCtx.IsValid() {
+				record.TraceId, _ = hex.DecodeString(id)
+			}
+			continue
+		case "span_id":
+			if id, ok := v.(string); ok && !spanCtx.IsValid() {
+				record.SpanId, _ = hex.DecodeString(id)
+			}
+			continue
+		}
+		attrs = append(attrs, attribute.KeyValue{Key: attribute.Key(k), Value: attributeValue(v)})
+	}
+	record.Attributes = otlpAttributes(attrs)
+
+	if spanCtx.IsValid() {
+		traceID, spanID := spanCtx.TraceID(), spanCtx.SpanID()
+		record.TraceId, record.SpanId = traceID[:], spanID[:]
+		record.Flags = uint32(spanCtx.TraceFlags())
+	}
+	return record
+}
+
+func attributeValue(v interface{}) attribute.Value {
+	switch vv := v.(type) {
+	case string:
+		return attribute.StringValue(vv)
+	case bool:
+		return attribute.BoolValue(vv)
+	case int:
+		return attribute.IntValue(vv)
+	case int64:
+		return attribute.Int64Value(vv)
+	case int32:
+		return attribute.Int64Value(int64(vv))
+	case uint32:
+		return attribute.Int64Value(int64(vv))
+	case uint64:
+		return attribute.Int64Value(int64(vv))
+	case float64:
+		return attribute.Float64Value(vv)
+	case float32:
+		return attribute.Float64Value(float64(vv))
+	case []string:
+		return attribute.StringSliceValue(vv)
+	case error:
+		return attribute.StringValue(vv.Error())
+	case fmt.Stringer:
+		return attribute.StringValue(vv.String())
+	}
+
+	if out, err := json.Marshal(v); err == nil {
+		return attribute.StringValue(string(out))
+	}
+	return attribute.StringValue(fmt.Sprintf("%v", v))
+}
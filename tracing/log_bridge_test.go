@@ -0,0 +1,170 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/tracing"
+)
+
+func TestLogHook(t *testing.T) {
+	received := make(chan *collogspb.ExportLogsServiceRequest, 10)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req collogspb.ExportLogsServiceRequest
+		require.NoError(t, proto.Unmarshal(body, &req))
+		received <- &req
+	}))
+	defer ts.Close()
+
+	collect := func(t *testing.T) []*logspb.LogRecord {
+		select {
+		case req := <-received:
+			require.Len(t, req.ResourceLogs, 1)
+			require.Len(t, req.ResourceLogs[0].InstrumentationLibraryLogs, 1)
+			return req.ResourceLogs[0].InstrumentationLibraryLogs[0].Logs
+		case <-time.After(time.Second):
+			t.Fatal("the collector did not receive logs")
+		}
+		return nil
+	}
+
+	config := &tracing.LogsConfig{
+		Exporter: "otlp",
+		OTLP:     &tracing.OTLPConfig{ServerURL: ts.URL, Headers: map[string]string{"Authorization": "secret"}},
+		Level:    "info",
+		Interval: "1h",
+	}
+
+	t.Run("case=exports entries with trace correlation", func(t *testing.T) {
+		hook, err := tracing.NewLogHook("ORY X", config, nil)
+		require.NoError(t, err)
+
+		l := logrusx.New("ory/x", "1", logrusx.WithHook(hook), logrusx.ForceLevel(logrus.TraceLevel))
+
+		traceID, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+		spanID, _ := trace.SpanIDFromHex("0102030405060708")
+		ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    traceID,
+			SpanID:     spanID,
+			TraceFlags: trace.FlagsSampled,
+		}))
+
+		l.WithContext(ctx).WithField("count", 3).WithError(errors.New("oops")).Error("request failed")
+		l.Info("started")
+		l.Debug("not exported")
+
+		require.NoError(t, hook.Flush(context.Background()))
+		records := collect(t)
+		require.Len(t, records, 2)
+
+		assert.Equal(t, "request failed", records[0].Body.GetStringValue())
+		assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_ERROR, records[0].SeverityNumber)
+		assert.Equal(t, traceID[:], records[0].TraceId)
+		assert.Equal(t, spanID[:], records[0].SpanId)
+
+		attrs := map[string]bool{}
+		for _, kv := range records[0].Attributes {
+			attrs[kv.Key] = true
+		}
+		assert.True(t, attrs["count"])
+		assert.True(t, attrs["error"])
+		assert.False(t, attrs["trace_id"])
+
+		assert.Equal(t, "started", records[1].Body.GetStringValue())
+		assert.Empty(t, records[1].TraceId)
+
+		require.NoError(t, hook.Shutdown(context.Background()))
+		l.Info("after shutdown")
+		select {
+		case <-received:
+			t.Fatal("no entries must be exported after shutdown")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("case=exports full batches", func(t *testing.T) {
+		c := *config
+		c.BatchSize = 2
+		hook, err := tracing.NewLogHook("ORY X", &c, nil)
+		require.NoError(t, err)
+		defer hook.Shutdown(context.Background())
+
+		l := logrusx.New("ory/x", "1", logrusx.WithHook(hook))
+		l.Info("one")
+		l.Info("two")
+
+		assert.Len(t, collect(t), 2)
+	})
+
+	t.Run("case=drops entries while the queue is full", func(t *testing.T) {
+		started, release := make(chan struct{}, 10), make(chan struct{})
+		blocked := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			started <- struct{}{}
+			<-release
+		}))
+		defer blocked.Close()
+
+		hook, err := tracing.NewLogHook("ORY X", &tracing.LogsConfig{
+			Exporter:     "otlp",
+			OTLP:         &tracing.OTLPConfig{ServerURL: blocked.URL},
+			Interval:     "1h",
+			BatchSize:    2,
+			MaxQueueSize: 4,
+		}, nil)
+		require.NoError(t, err)
+
+		l := logrusx.New("ory/x", "1", logrusx.WithHook(hook))
+		l.Info("one")
+		l.Info("two")
+		<-started
+
+		// The export of the first batch is still running.
+		for k := 0; k < 6; k++ {
+			l.Info("queued")
+		}
+		assert.Equal(t, uint64(2), hook.Dropped())
+
+		close(release)
+		require.NoError(t, hook.Shutdown(context.Background()))
+	})
+
+	t.Run("case=is configured by the tracer", func(t *testing.T) {
+		l := logrusx.New("ory/x", "1")
+		tracer, err := tracing.New(l, &tracing.Config{ServiceName: "ORY X", Logs: config})
+		require.NoError(t, err)
+
+		l.Warn("configured")
+		tracer.Close()
+
+		records := collect(t)
+		require.NotEmpty(t, records)
+		assert.Equal(t, "configured", records[len(records)-1].Body.GetStringValue())
+	})
+
+	t.Run("case=rejects invalid configuration", func(t *testing.T) {
+		_, err := tracing.NewLogHook("ORY X", &tracing.LogsConfig{Exporter: "syslog"}, nil)
+		require.Error(t, err)
+
+		_, err = tracing.NewLogHook("ORY X", &tracing.LogsConfig{Exporter: "otlp", Level: "loud"}, nil)
+		require.Error(t, err)
+	})
+}
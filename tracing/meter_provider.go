@@ -0,0 +1,628 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/number"
+	"go.opentelemetry.io/otel/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+
+	"github.com/ory/x/logrusx"
+)
+
+// DefaultHistogramBuckets are the bucket boundaries of histograms without a view configuring
+// them. They match the OpenTelemetry SDK defaults.
+var DefaultHistogramBuckets = []float64{0, 5, 10, 25, 50, 75, 100, 250, 500, 750, 1000, 2500, 5000, 7500, 10000}
+
+// MeterProvider is an OpenTelemetry meter provider which aggregates measurements in memory and
+// exports them either using OTLP or by serving them to Prometheus, see Handler.
+//
+// Prometheus metric names are derived from the instrument names, and the names of counters end
+// with "_total", for example "http.server.requests" becomes "http_server_requests_total".
+// Creating an instrument which would be exported with the name of another instrument fails.
+//
+// TODO: replace the aggregation and the exporters of this file and otlp.go with
+// go.opentelemetry.io/otel/sdk/metric, otlpmetrichttp and the Prometheus exporter at v0.25.0,
+// driven by MetricsConfig. They are not in the module graph yet, because their modules
+// (sdk/export/metric, otlpmetrichttp and exporters/prometheus) could not be resolved when this
+// package was written.
+type MeterProvider struct {
+	c        *MetricsConfig
+	l        *logrusx.Logger
+	res      *resource.Resource
+	start    time.Time
+	registry *prometheus.Registry
+	otlp     *otlpClient
+
+	mu          sync.Mutex
+	instruments []*instrument
+	byName      map[string]*instrument
+	single      []*instrument
+	batch       []sdkapi.AsyncBatchRunner
+	// byPrometheusName detects instruments which would be exported as the same Prometheus
+	// metric.
+	byPrometheusName map[string]*instrument
+
+	stop     chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+var _ metric.MeterProvider = (*MeterProvider)(nil)
+
+// NewMeterProvider creates a meter provider configured by c and registers it globally, the same
+// way New does for the tracer provider. The resource describing the service is detected from
+// the configuration, the host, the process, and the OTEL_RESOURCE_ATTRIBUTES environment
+// variable.
+func NewMeterProvider(l *logrusx.Logger, c *MetricsConfig) (*MeterProvider, error) {
+	ctx := context.Background()
+
+	serviceName := os.Getenv("OTEL_SERVICE_NAME")
+	if serviceName == "" {
+		serviceName = c.ServiceName
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithFromEnv(),
+		resource.WithHost(),
+		resource.WithProcessPID(),
+		resource.WithProcessRuntimeName(),
+		resource.WithProcessRuntimeVersion(),
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(semconv.ServiceNameKey.String(serviceName)),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "new otel resource")
+	}
+
+	p := &MeterProvider{
+		c:        c,
+		l:        l,
+		res:      res,
+		start:    time.Now(),
+		registry: prometheus.NewRegistry(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+
+		byName:           map[string]*instrument{},
+		byPrometheusName: map[string]*instrument{},
+	}
+	if err := p.registry.Register(p); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	switch strings.ToLower(c.Exporter) {
+	case "otlp":
+		var oc *OTLPMetricsConfig
+		if c.Exporters != nil {
+			oc = c.Exporters.OTLP
+		}
+		if oc == nil {
+			oc = new(OTLPMetricsConfig)
+		}
+
+		interval := time.Minute
+		if oc.Interval != "" {
+			if interval, err = time.ParseDuration(oc.Interval); err != nil || interval <= 0 {
+				return nil, errors.Errorf("the OTLP metrics export interval must be a positive duration but got: %s", oc.Interval)
+			}
+		}
+
+		if p.otlp, err = newOTLPClient(&oc.OTLPConfig, "/v1/metrics"); err != nil {
+			return nil, err
+		}
+		go p.push(interval)
+		l.Infof("OTEL metrics exporter configured!")
+	case "prometheus":
+		close(p.done)
+		l.Infof("Prometheus metrics exporter configured!")
+	case "":
+		close(p.done)
+		l.Infof("No metrics exporter configured - metrics are only recorded")
+	default:
+		return nil, errors.Errorf("unknown metrics exporter: %s", c.Exporter)
+	}
+
+	if !c.DisableRuntimeMetrics {
+		if err := registerRuntimeMetrics(p.Meter("github.com/ory/x/tracing/runtime")); err != nil {
+			_ = p.Shutdown(ctx)
+			return nil, err
+		}
+	}
+
+	global.SetMeterProvider(p)
+	return p, nil
+}
+
+// Meter implements metric.MeterProvider.
+func (p *MeterProvider) Meter(instrumentationName string, opts ...metric.MeterOption) metric.Meter {
+	cfg := metric.NewMeterConfig(opts...)
+	return metric.WrapMeterImpl(&meterImpl{p: p, library: instrumentationName, version: cfg.InstrumentationVersion()})
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (p *MeterProvider) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+// ForceFlush exports the current metrics if metrics are pushed using OTLP.
+func (p *MeterProvider) ForceFlush(ctx context.Context) error {
+	if p.otlp == nil {
+		return nil
+	}
+	return p.exportOTLP(ctx)
+}
+
+// Shutdown exports the remaining metrics and stops exporting.
+func (p *MeterProvider) Shutdown(ctx context.Context) error {
+	p.shutdown.Do(func() {
+		close(p.stop)
+	})
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return errors.WithStack(ctx.Err())
+	}
+
+	if p.otlp == nil {
+		return nil
+	}
+	return p.exportOTLP(ctx)
+}
+
+func (p *MeterProvider) push(interval time.Duration) {
+	defer close(p.done)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), interval)
+			if err := p.exportOTLP(ctx); err != nil {
+				p.l.WithError(err).Error("Unable to export metrics.")
+			}
+			cancel()
+		}
+	}
+}
+
+type meterImpl struct {
+	p                *MeterProvider
+	library, version string
+}
+
+var _ sdkapi.MeterImpl = (*meterImpl)(nil)
+
+func (m *meterImpl) RecordBatch(ctx context.Context, labels []attribute.KeyValue, measurements ...sdkapi.Measurement) {
+	for _, ms := range measurements {
+		if i, ok := ms.SyncImpl().Implementation().(*instrument); ok {
+			i.record(ms.Number(), labels)
+		}
+	}
+}
+
+func (m *meterImpl) NewSyncInstrument(desc sdkapi.Descriptor) (sdkapi.SyncImpl, error) {
+	i, err := m.p.newInstrument(m, desc, nil)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (m *meterImpl) NewAsyncInstrument(desc sdkapi.Descriptor, runner sdkapi.AsyncRunner) (sdkapi.AsyncImpl, error) {
+	i, err := m.p.newInstrument(m, desc, runner)
+	if err != nil {
+		return nil, err
+	}
+	return i, nil
+}
+
+func (p *MeterProvider) newInstrument(m *meterImpl, desc sdkapi.Descriptor, runner sdkapi.AsyncRunner) (*instrument, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := m.library + "/" + desc.Name()
+	if existing, ok := p.byName[key]; ok {
+		if existing.desc.InstrumentKind() != desc.InstrumentKind() || existing.desc.NumberKind() != desc.NumberKind() || runner != nil {
+			return nil, errors.Errorf("metric instrument %s is already registered with a different kind", desc.Name())
+		}
+		return existing, nil
+	}
+
+	i := &instrument{
+		library:     m.library,
+		version:     m.version,
+		desc:        desc,
+		name:        desc.Name(),
+		description: desc.Description(),
+		bounds:      DefaultHistogramBuckets,
+		points:      map[attribute.Distinct]*dataPoint{},
+	}
+	if v := p.view(desc.Name()); v != nil {
+		i.drop = v.Drop
+		if v.Name != "" {
+			i.name = v.Name
+		}
+		if v.Description != "" {
+			i.description = v.Description
+		}
+		if len(v.Buckets) > 0 {
+			i.bounds = append([]float64{}, v.Buckets...)
+			sort.Float64s(i.bounds)
+		}
+		if len(v.AttributeKeys) > 0 {
+			i.keys = map[attribute.Key]bool{}
+			for _, k := range v.AttributeKeys {
+				i.keys[attribute.Key(k)] = true
+			}
+		}
+	}
+
+	if !i.drop {
+		i.prometheusName = prometheusMetricName(i.name, desc.InstrumentKind())
+		if i.prometheusName == prometheusTargetInfo {
+			return nil, errors.Errorf("metric instrument %s can not be exported to Prometheus as %s, which is reserved", desc.Name(), i.prometheusName)
+		}
+		if other, ok := p.byPrometheusName[i.prometheusName]; ok {
+			return nil, errors.Errorf("metric instrument %s of %s would be exported to Prometheus as %s, which metric instrument %s of %s already uses; rename one of them with a view", desc.Name(), m.library, i.prometheusName, other.desc.Name(), other.library)
+		}
+	}
+
+	switch r := runner.(type) {
+	case nil:
+	case sdkapi.AsyncSingleRunner:
+		i.runner = r
+		p.single = append(p.single, i)
+	case sdkapi.AsyncBatchRunner:
+		var known bool
+		for _, b := range p.batch {
+			known = known || b == r
+		}
+		if !known {
+			p.batch = append(p.batch, r)
+		}
+	default:
+		return nil, errors.Errorf("unsupported asynchronous runner %T for metric instrument %s", runner, desc.Name())
+	}
+
+	p.byName[key] = i
+	if !i.drop {
+		p.byPrometheusName[i.prometheusName] = i
+	}
+	p.instruments = append(p.instruments, i)
+	return i, nil
+}
+
+func (p *MeterProvider) view(name string) *MetricsView {
+	for k := range p.c.Views {
+		if ok, _ := path.Match(p.c.Views[k].Instrument, name); ok {
+			return &p.c.Views[k]
+		}
+	}
+	return nil
+}
+
+// collect runs the asynchronous instruments and returns a snapshot of all instruments.
+func (p *MeterProvider) collect(ctx context.Context) []instrumentSnapshot {
+	p.mu.Lock()
+	single := append([]*instrument{}, p.single...)
+	batch := append([]sdkapi.AsyncBatchRunner{}, p.batch...)
+	instruments := append([]*instrument{}, p.instruments...)
+	p.mu.Unlock()
+
+	capture := func(labels []attribute.KeyValue, observations ...sdkapi.Observation) {
+		for _, o := range observations {
+			if i, ok := o.AsyncImpl().Implementation().(*instrument); ok {
+				i.record(o.Number(), labels)
+			}
+		}
+	}
+	for _, i := range single {
+		i.runner.Run(ctx, i, capture)
+	}
+	for _, r := range batch {
+		r.Run(ctx, capture)
+	}
+
+	snapshots := make([]instrumentSnapshot, 0, len(instruments))
+	for _, i := range instruments {
+		if !i.drop {
+			snapshots = append(snapshots, i.snapshot())
+		}
+	}
+	return snapshots
+}
+
+type instrument struct {
+	library, version  string
+	desc              sdkapi.Descriptor
+	name, description string
+	prometheusName    string
+	drop              bool
+	bounds            []float64
+	keys              map[attribute.Key]bool
+	runner            sdkapi.AsyncSingleRunner
+
+	mu     sync.Mutex
+	points map[attribute.Distinct]*dataPoint
+}
+
+type dataPoint struct {
+	attrs   attribute.Set
+	value   float64
+	count   uint64
+	buckets []uint64
+}
+
+type instrumentSnapshot struct {
+	*instrument
+	points []dataPoint
+}
+
+var (
+	_ sdkapi.SyncImpl  = (*instrument)(nil)
+	_ sdkapi.AsyncImpl = (*instrument)(nil)
+)
+
+func (i *instrument) Implementation() interface{} {
+	return i
+}
+
+func (i *instrument) Descriptor() sdkapi.Descriptor {
+	return i.desc
+}
+
+func (i *instrument) Bind(labels []attribute.KeyValue) sdkapi.BoundSyncImpl {
+	return &boundInstrument{i: i, labels: labels}
+}
+
+func (i *instrument) RecordOne(_ context.Context, n number.Number, labels []attribute.KeyValue) {
+	i.record(n, labels)
+}
+
+func (i *instrument) record(n number.Number, labels []attribute.KeyValue) {
+	if i.drop {
+		return
+	}
+
+	if i.keys != nil {
+		filtered := make([]attribute.KeyValue, 0, len(i.keys))
+		for _, kv := range labels {
+			if i.keys[kv.Key] {
+				filtered = append(filtered, kv)
+			}
+		}
+		labels = filtered
+	}
+	attrs := attribute.NewSet(labels...)
+	v := n.CoerceToFloat64(i.desc.NumberKind())
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	dp, ok := i.points[attrs.Equivalent()]
+	if !ok {
+		dp = &dataPoint{attrs: attrs}
+		if i.desc.InstrumentKind() == sdkapi.HistogramInstrumentKind {
+			dp.buckets = make([]uint64, len(i.bounds)+1)
+		}
+		i.points[attrs.Equivalent()] = dp
+	}
+
+	switch i.desc.InstrumentKind() {
+	case sdkapi.CounterInstrumentKind, sdkapi.UpDownCounterInstrumentKind:
+		dp.value += v
+	case sdkapi.HistogramInstrumentKind:
+		dp.value += v
+		dp.count++
+		dp.buckets[sort.SearchFloat64s(i.bounds, v)]++
+	default:
+		// Observers report the current or cumulative value.
+		dp.value = v
+	}
+}
+
+func (i *instrument) snapshot() instrumentSnapshot {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	s := instrumentSnapshot{instrument: i, points: make([]dataPoint, 0, len(i.points))}
+	for _, dp := range i.points {
+		c := *dp
+		c.buckets = append([]uint64{}, dp.buckets...)
+		s.points = append(s.points, c)
+	}
+	return s
+}
+
+type boundInstrument struct {
+	i      *instrument
+	labels []attribute.KeyValue
+}
+
+func (b *boundInstrument) RecordOne(_ context.Context, n number.Number) {
+	b.i.record(n, b.labels)
+}
+
+func (b *boundInstrument) Unbind() {}
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+const prometheusTargetInfo = "target_info"
+
+// prometheusMetricName returns the name of the Prometheus metric of an instrument. Following
+// the Prometheus conventions, the names of counters end with "_total".
+func prometheusMetricName(name string, kind sdkapi.InstrumentKind) string {
+	name = prometheusName(name)
+	switch kind {
+	case sdkapi.CounterInstrumentKind, sdkapi.CounterObserverInstrumentKind:
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+	}
+	return name
+}
+
+func prometheusName(name string) string {
+	name = invalidPrometheusChars.ReplaceAllString(name, "_")
+	if name != "" && name[0] >= '0' && name[0] <= '9' {
+		name = "_" + name
+	}
+	return name
+}
+
+// Describe implements prometheus.Collector. No descriptors are sent because instruments are
+// created at runtime, which makes this an unchecked collector.
+func (p *MeterProvider) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (p *MeterProvider) Collect(ch chan<- prometheus.Metric) {
+	var infoKeys, infoValues []string
+	for _, kv := range p.res.Attributes() {
+		infoKeys = append(infoKeys, prometheusName(string(kv.Key)))
+		infoValues = append(infoValues, kv.Value.Emit())
+	}
+	infoDesc := prometheus.NewDesc(prometheusTargetInfo, "Target metadata", infoKeys, nil)
+	if info, err := prometheus.NewConstMetric(infoDesc, prometheus.GaugeValue, 1, infoValues...); err != nil {
+		ch <- prometheus.NewInvalidMetric(infoDesc, err)
+	} else {
+		ch <- info
+	}
+
+	for _, s := range p.collect(context.Background()) {
+		// Prometheus requires all series of a metric to have the same labels.
+		keySet := map[attribute.Key]bool{}
+		for _, dp := range s.points {
+			for _, kv := range dp.attrs.ToSlice() {
+				keySet[kv.Key] = true
+			}
+		}
+		var keys []attribute.Key
+		var labelNames []string
+		for k := range keySet {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(a, b int) bool { return keys[a] < keys[b] })
+		for _, k := range keys {
+			labelNames = append(labelNames, prometheusName(string(k)))
+		}
+
+		desc := prometheus.NewDesc(s.prometheusName, s.description, labelNames, nil)
+		for _, dp := range s.points {
+			values := make([]string, len(keys))
+			for k, key := range keys {
+				if v, ok := dp.attrs.Value(key); ok {
+					values[k] = v.Emit()
+				}
+			}
+
+			var m prometheus.Metric
+			var err error
+			switch s.desc.InstrumentKind() {
+			case sdkapi.HistogramInstrumentKind:
+				buckets := make(map[float64]uint64, len(s.bounds))
+				var cumulative uint64
+				for k, bound := range s.bounds {
+					cumulative += dp.buckets[k]
+					buckets[bound] = cumulative
+				}
+				m, err = prometheus.NewConstHistogram(desc, dp.count, dp.value, buckets, values...)
+			case sdkapi.CounterInstrumentKind, sdkapi.CounterObserverInstrumentKind:
+				m, err = prometheus.NewConstMetric(desc, prometheus.CounterValue, dp.value, values...)
+			default:
+				m, err = prometheus.NewConstMetric(desc, prometheus.GaugeValue, dp.value, values...)
+			}
+			if err != nil {
+				ch <- prometheus.NewInvalidMetric(desc, err)
+				continue
+			}
+			ch <- m
+		}
+	}
+}
+
+func (p *MeterProvider) exportOTLP(ctx context.Context) error {
+	now := uint64(time.Now().UnixNano())
+	start := uint64(p.start.UnixNano())
+
+	libraries := map[string]*metricspb.InstrumentationLibraryMetrics{}
+	var order []string
+	for _, s := range p.collect(ctx) {
+		lib, ok := libraries[s.library]
+		if !ok {
+			lib = &metricspb.InstrumentationLibraryMetrics{
+				InstrumentationLibrary: &commonpb.InstrumentationLibrary{Name: s.library, Version: s.version},
+			}
+			libraries[s.library] = lib
+			order = append(order, s.library)
+		}
+
+		m := &metricspb.Metric{Name: s.name, Description: s.description, Unit: string(s.desc.Unit())}
+		switch kind := s.desc.InstrumentKind(); kind {
+		case sdkapi.HistogramInstrumentKind:
+			h := &metricspb.Histogram{AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE}
+			for _, dp := range s.points {
+				h.DataPoints = append(h.DataPoints, &metricspb.HistogramDataPoint{
+					Attributes:        otlpAttributes(dp.attrs.ToSlice()),
+					StartTimeUnixNano: start,
+					TimeUnixNano:      now,
+					Count:             dp.count,
+					Sum:               dp.value,
+					BucketCounts:      dp.buckets,
+					ExplicitBounds:    s.bounds,
+				})
+			}
+			m.Data = &metricspb.Metric_Histogram{Histogram: h}
+		case sdkapi.GaugeObserverInstrumentKind:
+			m.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: otlpNumberDataPoints(s.points, start, now)}}
+		default:
+			m.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             otlpNumberDataPoints(s.points, start, now),
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            kind.Monotonic(),
+			}}
+		}
+		lib.Metrics = append(lib.Metrics, m)
+	}
+
+	rm := &metricspb.ResourceMetrics{Resource: otlpResource(p.res), SchemaUrl: p.res.SchemaURL()}
+	for _, name := range order {
+		rm.InstrumentationLibraryMetrics = append(rm.InstrumentationLibraryMetrics, libraries[name])
+	}
+	return p.otlp.export(ctx, &colmetricspb.ExportMetricsServiceRequest{ResourceMetrics: []*metricspb.ResourceMetrics{rm}})
+}
+
+func otlpNumberDataPoints(points []dataPoint, start, now uint64) []*metricspb.NumberDataPoint {
+	out := make([]*metricspb.NumberDataPoint, 0, len(points))
+	for _, dp := range points {
+		out = append(out, &metricspb.NumberDataPoint{
+			Attributes:        otlpAttributes(dp.attrs.ToSlice()),
+			StartTimeUnixNano: start,
+			TimeUnixNano:      now,
+			Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: dp.value},
+		})
+	}
+	return out
+}
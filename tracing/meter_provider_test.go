@@ -0,0 +1,207 @@
+package tracing_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	colmetricspb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/tracing"
+)
+
+func TestMeterProvider(t *testing.T) {
+	record := func(t *testing.T, p *tracing.MeterProvider) {
+		m := metric.Must(p.Meter("github.com/ory/x/tracing_test"))
+		requests := m.NewInt64Counter("http.server.requests", metric.WithDescription("Handled requests"))
+		duration := m.NewFloat64Histogram("http.server.duration")
+		m.NewInt64GaugeObserver("goroutines", func(_ context.Context, r metric.Int64ObserverResult) {
+			r.Observe(42)
+		})
+
+		ctx := context.Background()
+		requests.Add(ctx, 2, attribute.String("route", "/foo"), attribute.Int("status", 200))
+		requests.Add(ctx, 1, attribute.String("route", "/bar"), attribute.Int("status", 200))
+		duration.Record(ctx, 7, attribute.String("route", "/foo"))
+		duration.Record(ctx, 300, attribute.String("route", "/foo"))
+	}
+
+	t.Run("exporter=prometheus", func(t *testing.T) {
+		p, err := tracing.NewMeterProvider(logrusx.New("ory/x", "1"), &tracing.MetricsConfig{
+			ServiceName: "ORY X",
+			Exporter:    "prometheus",
+			Views: []tracing.MetricsView{
+				{Instrument: "http.server.requests", AttributeKeys: []string{"route"}},
+				{Instrument: "http.server.duration", Buckets: []float64{10, 100}},
+			},
+		})
+		require.NoError(t, err)
+		record(t, p)
+
+		ts := httptest.NewServer(p.Handler())
+		defer ts.Close()
+
+		res, err := http.Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		require.NoError(t, err)
+
+		assert.Contains(t, string(body), "# HELP http_server_requests_total Handled requests")
+		assert.Contains(t, string(body), `http_server_requests_total{route="/foo"} 2`)
+		assert.Contains(t, string(body), `http_server_requests_total{route="/bar"} 1`)
+		assert.Contains(t, string(body), `http_server_duration_bucket{route="/foo",le="10"} 1`)
+		assert.Contains(t, string(body), `http_server_duration_bucket{route="/foo",le="100"} 1`)
+		assert.Contains(t, string(body), `http_server_duration_bucket{route="/foo",le="+Inf"} 2`)
+		assert.Contains(t, string(body), `http_server_duration_sum{route="/foo"} 307`)
+		assert.Contains(t, string(body), "goroutines 42")
+		assert.Contains(t, string(body), "runtime_go_goroutines ")
+		assert.Contains(t, string(body), "runtime_go_mem_heap_alloc ")
+		assert.Contains(t, string(body), `process_cpu_time_total{state="user"}`)
+		assert.Contains(t, string(body), `service_name="ORY X"`)
+		assert.NotContains(t, string(body), "status")
+
+		require.NoError(t, p.Shutdown(context.Background()))
+	})
+
+	t.Run("exporter=otlp", func(t *testing.T) {
+		received := make(chan *colmetricspb.ExportMetricsServiceRequest, 10)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/metrics", r.URL.Path)
+			assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+			assert.Equal(t, "secret", r.Header.Get("Authorization"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			var req colmetricspb.ExportMetricsServiceRequest
+			require.NoError(t, proto.Unmarshal(body, &req))
+			received <- &req
+		}))
+		defer ts.Close()
+
+		p, err := tracing.NewMeterProvider(logrusx.New("ory/x", "1"), &tracing.MetricsConfig{
+			ServiceName: "ORY X",
+			Exporter:    "otlp",
+			Exporters: &tracing.MetricsExportersConfig{OTLP: &tracing.OTLPMetricsConfig{
+				OTLPConfig: tracing.OTLPConfig{ServerURL: ts.URL, Headers: map[string]string{"Authorization": "secret"}},
+				Interval:   "1h",
+			}},
+			Views: []tracing.MetricsView{
+				{Instrument: "goroutines", Drop: true},
+				{Instrument: "http.server.requests", Name: "requests"},
+			},
+			DisableRuntimeMetrics: true,
+		})
+		require.NoError(t, err)
+		record(t, p)
+		require.NoError(t, p.Shutdown(context.Background()))
+
+		var req *colmetricspb.ExportMetricsServiceRequest
+		select {
+		case req = <-received:
+		case <-time.After(time.Second):
+			t.Fatal("the collector did not receive metrics")
+		}
+
+		require.Len(t, req.ResourceMetrics, 1)
+		var serviceName string
+		for _, kv := range req.ResourceMetrics[0].Resource.Attributes {
+			if kv.Key == "service.name" {
+				serviceName = kv.Value.GetStringValue()
+			}
+		}
+		assert.Equal(t, "ORY X", serviceName)
+
+		libs := req.ResourceMetrics[0].InstrumentationLibraryMetrics
+		require.Len(t, libs, 1)
+		assert.Equal(t, "github.com/ory/x/tracing_test", libs[0].InstrumentationLibrary.Name)
+
+		metrics := map[string]bool{}
+		for _, m := range libs[0].Metrics {
+			metrics[m.Name] = true
+			switch m.Name {
+			case "requests":
+				assert.True(t, m.GetSum().IsMonotonic)
+				assert.Len(t, m.GetSum().DataPoints, 2)
+			case "http.server.duration":
+				require.Len(t, m.GetHistogram().DataPoints, 1)
+				assert.EqualValues(t, 2, m.GetHistogram().DataPoints[0].Count)
+				assert.EqualValues(t, 307, m.GetHistogram().DataPoints[0].Sum)
+			}
+		}
+		assert.Equal(t, map[string]bool{"requests": true, "http.server.duration": true}, metrics)
+	})
+
+	t.Run("case=concurrent collections", func(t *testing.T) {
+		p, err := tracing.NewMeterProvider(logrusx.New("ory/x", "1"), &tracing.MetricsConfig{Exporter: "prometheus"})
+		require.NoError(t, err)
+		defer p.Shutdown(context.Background())
+
+		ts := httptest.NewServer(p.Handler())
+		defer ts.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := http.Get(ts.URL)
+				if !assert.NoError(t, err) {
+					return
+				}
+				defer res.Body.Close()
+				body, err := ioutil.ReadAll(res.Body)
+				assert.NoError(t, err)
+				assert.Contains(t, string(body), "runtime_go_mem_heap_alloc ")
+			}()
+		}
+		wg.Wait()
+	})
+
+	t.Run("case=rejects instruments with the same Prometheus name", func(t *testing.T) {
+		p, err := tracing.NewMeterProvider(logrusx.New("ory/x", "1"), &tracing.MetricsConfig{
+			Exporter:              "prometheus",
+			DisableRuntimeMetrics: true,
+			Views:                 []tracing.MetricsView{{Instrument: "requests.renamed", Name: "http.requests"}},
+		})
+		require.NoError(t, err)
+		defer p.Shutdown(context.Background())
+
+		a := p.Meter("a")
+		_, err = a.NewInt64Counter("http.requests")
+		require.NoError(t, err)
+		_, err = a.NewInt64Counter("http.requests")
+		require.NoError(t, err, "the same instrument may be created twice")
+
+		for _, create := range []func() error{
+			func() error { _, err := p.Meter("b").NewInt64Counter("http.requests"); return err },
+			func() error { _, err := p.Meter("b").NewInt64Counter("http_requests_total"); return err },
+			func() error { _, err := a.NewFloat64Counter("http-requests"); return err },
+			func() error { _, err := a.NewInt64Counter("requests.renamed"); return err },
+		} {
+			assert.Error(t, create())
+		}
+
+		ts := httptest.NewServer(p.Handler())
+		defer ts.Close()
+		res, err := http.Get(ts.URL)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("case=rejects unknown exporters", func(t *testing.T) {
+		_, err := tracing.NewMeterProvider(logrusx.New("ory/x", "1"), &tracing.MetricsConfig{Exporter: "statsd"})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,256 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLPConfig configures an exporter which sends telemetry to an OpenTelemetry collector using
+// OTLP over HTTP.
+type OTLPConfig struct {
+	// ServerURL is the address of the collector, for example "http://localhost:4318". The path
+	// of the signal, for example "/v1/metrics", is appended unless the URL has a path. Defaults
+	// to the OTEL_EXPORTER_OTLP_ENDPOINT environment variable.
+	ServerURL string `json:"server_url"`
+
+	// Headers are sent with every request, for example to authenticate with the collector.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// TLS configures the TLS client, for example to authenticate with a client certificate.
+	TLS *OTLPTLSConfig `json:"tls,omitempty"`
+}
+
+// OTLPTLSConfig configures the TLS client used to connect to the collector.
+type OTLPTLSConfig struct {
+	// CAFile is the path of a PEM encoded bundle of certificate authorities which are trusted
+	// in addition to the system pool.
+	CAFile string `json:"ca_file,omitempty"`
+
+	// CertFile and KeyFile are the paths of a PEM encoded client certificate and its key.
+	CertFile string `json:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty"`
+
+	// InsecureSkipVerify disables verifying the certificate of the collector. Do not use this in
+	// production.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty"`
+}
+
+func (c *OTLPTLSConfig) tlsConfig() (*tls.Config, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	tc := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: c.InsecureSkipVerify, // #nosec G402 -- explicitly configured
+	}
+
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read the OTLP certificate authorities")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("the file %s does not contain any PEM encoded certificates", c.CAFile)
+		}
+		tc.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load the OTLP client certificate")
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+
+	return tc, nil
+}
+
+type otlpClient struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func newOTLPClient(c *OTLPConfig, signalPath string) (*otlpClient, error) {
+	if c == nil {
+		c = new(OTLPConfig)
+	}
+
+	endpoint := c.ServerURL
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	if endpoint == "" {
+		endpoint = "http://localhost:4318"
+	}
+
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the OTLP server URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("the OTLP server URL must use http or https but got: %s", endpoint)
+	}
+	if strings.Trim(u.Path, "/") == "" {
+		u.Path = signalPath
+	}
+
+	tc, err := c.TLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tc
+
+	return &otlpClient{
+		url:     u.String(),
+		headers: c.Headers,
+		client:  &http.Client{Timeout: 10 * time.Second, Transport: transport},
+	}, nil
+}
+
+// otlpTraceOptions configures the OTLP trace exporter. Unset values are read from the
+// environment by the exporter.
+func otlpTraceOptions(c *OTLPConfig) ([]otlptracehttp.Option, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	var opts []otlptracehttp.Option
+	if c.ServerURL != "" {
+		u, err := url.Parse(c.ServerURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse the OTLP server URL")
+		}
+		switch u.Scheme {
+		case "http":
+			opts = append(opts, otlptracehttp.WithInsecure())
+		case "https":
+		default:
+			return nil, errors.Errorf("the OTLP server URL must use http or https but got: %s", c.ServerURL)
+		}
+		opts = append(opts, otlptracehttp.WithEndpoint(u.Host))
+		if strings.Trim(u.Path, "/") != "" {
+			opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+		}
+	}
+
+	if len(c.Headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(c.Headers))
+	}
+
+	tc, err := c.TLS.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	if tc != nil {
+		opts = append(opts, otlptracehttp.WithTLSClientConfig(tc))
+	}
+
+	return opts, nil
+}
+
+func (c *otlpClient) export(ctx context.Context, msg proto.Message) error {
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+
+	res, err := c.client.Do(req)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.Errorf("the OTLP collector at %s responded with status code %d", c.url, res.StatusCode)
+	}
+	return nil
+}
+
+func otlpResource(res *resource.Resource) *resourcepb.Resource {
+	if res == nil {
+		return &resourcepb.Resource{}
+	}
+	return &resourcepb.Resource{Attributes: otlpAttributes(res.Attributes())}
+}
+
+func otlpAttributes(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: otlpValue(kv.Value)})
+	}
+	return out
+}
+
+func otlpValue(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		var values []*commonpb.AnyValue
+		for _, b := range v.AsBoolSlice() {
+			values = append(values, otlpValue(attribute.BoolValue(b)))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case attribute.INT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, i := range v.AsInt64Slice() {
+			values = append(values, otlpValue(attribute.Int64Value(i)))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case attribute.FLOAT64SLICE:
+		var values []*commonpb.AnyValue
+		for _, f := range v.AsFloat64Slice() {
+			values = append(values, otlpValue(attribute.Float64Value(f)))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	case attribute.STRINGSLICE:
+		var values []*commonpb.AnyValue
+		for _, s := range v.AsStringSlice() {
+			values = append(values, otlpValue(attribute.StringValue(s)))
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: fmt.Sprint(v.AsInterface())}}
+}
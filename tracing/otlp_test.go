@@ -0,0 +1,85 @@
+package tracing_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/tlsx"
+	"github.com/ory/x/tracing"
+)
+
+func TestOTLPClientCertificates(t *testing.T) {
+	var clientCerts int
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientCerts = len(r.TLS.PeerCertificates)
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	dir := t.TempDir()
+	write := func(name string, block *pem.Block) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, ioutil.WriteFile(p, pem.EncodeToMemory(block), 0600))
+		return p
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert, err := tlsx.CreateSelfSignedCertificate(key)
+	require.NoError(t, err)
+	keyBlock, err := tlsx.PEMBlockForKey(key)
+	require.NoError(t, err)
+
+	tlsConfig := &tracing.OTLPTLSConfig{
+		CAFile:   write("ca.pem", &pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw}),
+		CertFile: write("client.pem", &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}),
+		KeyFile:  write("client-key.pem", keyBlock),
+	}
+
+	t.Run("case=authenticates with a client certificate", func(t *testing.T) {
+		hook, err := tracing.NewLogHook("ORY X", &tracing.LogsConfig{
+			Exporter: "otlp",
+			OTLP:     &tracing.OTLPConfig{ServerURL: ts.URL, TLS: tlsConfig},
+			Interval: "1h",
+		}, nil)
+		require.NoError(t, err)
+
+		logrusx.New("ory/x", "1", logrusx.WithHook(hook)).Info("hello")
+		require.NoError(t, hook.Shutdown(context.Background()))
+		assert.Equal(t, 1, clientCerts)
+	})
+
+	t.Run("case=fails without a client certificate", func(t *testing.T) {
+		hook, err := tracing.NewLogHook("ORY X", &tracing.LogsConfig{
+			Exporter: "otlp",
+			OTLP:     &tracing.OTLPConfig{ServerURL: ts.URL, TLS: &tracing.OTLPTLSConfig{CAFile: tlsConfig.CAFile}},
+			Interval: "1h",
+		}, nil)
+		require.NoError(t, err)
+
+		logrusx.New("ory/x", "1", logrusx.WithHook(hook)).Info("hello")
+		require.Error(t, hook.Shutdown(context.Background()))
+	})
+
+	t.Run("case=rejects missing certificate files", func(t *testing.T) {
+		_, err := tracing.NewLogHook("ORY X", &tracing.LogsConfig{
+			Exporter: "otlp",
+			OTLP:     &tracing.OTLPConfig{ServerURL: ts.URL, TLS: &tracing.OTLPTLSConfig{CertFile: filepath.Join(dir, "missing.pem")}},
+		}, nil)
+		require.Error(t, err)
+	})
+}
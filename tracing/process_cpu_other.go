@@ -0,0 +1,11 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package tracing
+
+import "time"
+
+// processCPUTime is not supported on this platform.
+func processCPUTime() (user, system time.Duration, ok bool) {
+	return 0, 0, false
+}
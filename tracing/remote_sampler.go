@@ -0,0 +1,270 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RemoteSampler samples root spans according to the strategy of the service which it
+// periodically fetches from a Jaeger compatible sampling endpoint. Probabilistic, rate
+// limiting and per-operation strategies are supported.
+type RemoteSampler struct {
+	url      string
+	client   *http.Client
+	interval time.Duration
+	onError  func(error)
+
+	sampler atomic.Value // holds a *samplerHolder
+
+	stop     chan struct{}
+	done     chan struct{}
+	shutdown sync.Once
+}
+
+type samplerHolder struct {
+	sdktrace.Sampler
+}
+
+var _ sdktrace.Sampler = (*RemoteSampler)(nil)
+
+// NewRemoteSampler creates a sampler which fetches the sampling strategy of the service as
+// configured by c. Until the strategy was fetched successfully, initial is used. Errors which
+// occur while fetching are passed to onError, if not nil.
+func NewRemoteSampler(serviceName string, c *RemoteSamplingConfig, initial sdktrace.Sampler, onError func(error)) (*RemoteSampler, error) {
+	u, err := url.Parse(c.ServerURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to parse the sampling server URL")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("the sampling server URL must use http or https but got: %s", c.ServerURL)
+	}
+	q := u.Query()
+	q.Set("service", serviceName)
+	u.RawQuery = q.Encode()
+
+	interval := time.Minute
+	if c.RefreshInterval != "" {
+		if interval, err = time.ParseDuration(c.RefreshInterval); err != nil || interval <= 0 {
+			return nil, errors.Errorf("the sampling refresh interval must be a positive duration but got: %s", c.RefreshInterval)
+		}
+	}
+
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	s := &RemoteSampler{
+		url:      u.String(),
+		client:   &http.Client{Timeout: 10 * time.Second},
+		interval: interval,
+		onError:  onError,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	s.sampler.Store(&samplerHolder{initial})
+
+	go s.run()
+	return s, nil
+}
+
+// ShouldSample implements sdktrace.Sampler.
+func (s *RemoteSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return s.sampler.Load().(*samplerHolder).ShouldSample(p)
+}
+
+// Description implements sdktrace.Sampler.
+func (s *RemoteSampler) Description() string {
+	return fmt.Sprintf("RemoteSampler{%s}", s.sampler.Load().(*samplerHolder).Description())
+}
+
+// Close stops fetching the sampling strategy.
+func (s *RemoteSampler) Close() {
+	s.shutdown.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+}
+
+func (s *RemoteSampler) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		if err := s.refresh(); err != nil {
+			s.onError(err)
+		}
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *RemoteSampler) refresh() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-s.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "unable to fetch the sampling strategy")
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		return errors.Errorf("the sampling server at %s responded with status code %d: %s", s.url, res.StatusCode, body)
+	}
+
+	var strategy samplingStrategy
+	if err := json.Unmarshal(body, &strategy); err != nil {
+		return errors.Wrap(err, "unable to decode the sampling strategy")
+	}
+
+	sampler, err := strategy.sampler()
+	if err != nil {
+		return err
+	}
+	s.sampler.Store(&samplerHolder{sampler})
+	return nil
+}
+
+// samplingStrategy is the response of the Jaeger sampling endpoint.
+type samplingStrategy struct {
+	StrategyType          interface{}                   `json:"strategyType"`
+	ProbabilisticSampling *probabilisticSamplingConfig  `json:"probabilisticSampling"`
+	RateLimitingSampling  *rateLimitingSamplingConfig   `json:"rateLimitingSampling"`
+	OperationSampling     *perOperationSamplingStrategy `json:"operationSampling"`
+}
+
+type probabilisticSamplingConfig struct {
+	SamplingRate float64 `json:"samplingRate"`
+}
+
+type rateLimitingSamplingConfig struct {
+	MaxTracesPerSecond float64 `json:"maxTracesPerSecond"`
+}
+
+type perOperationSamplingStrategy struct {
+	DefaultSamplingProbability float64 `json:"defaultSamplingProbability"`
+	PerOperationStrategies     []struct {
+		Operation             string                      `json:"operation"`
+		ProbabilisticSampling probabilisticSamplingConfig `json:"probabilisticSampling"`
+	} `json:"perOperationStrategies"`
+}
+
+func (s *samplingStrategy) sampler() (sdktrace.Sampler, error) {
+	if o := s.OperationSampling; o != nil {
+		ps := &perOperationSampler{
+			operations: make(map[string]sdktrace.Sampler, len(o.PerOperationStrategies)),
+			fallback:   sdktrace.TraceIDRatioBased(o.DefaultSamplingProbability),
+		}
+		for _, op := range o.PerOperationStrategies {
+			ps.operations[op.Operation] = sdktrace.TraceIDRatioBased(op.ProbabilisticSampling.SamplingRate)
+		}
+		return ps, nil
+	}
+
+	switch s.StrategyType {
+	case "PROBABILISTIC", float64(0), nil:
+		if s.ProbabilisticSampling == nil {
+			return nil, errors.New("the sampling strategy is probabilistic but does not define a sampling rate")
+		}
+		return sdktrace.TraceIDRatioBased(s.ProbabilisticSampling.SamplingRate), nil
+	case "RATE_LIMITING", float64(1):
+		if s.RateLimitingSampling == nil {
+			return nil, errors.New("the sampling strategy is rate limiting but does not define a rate")
+		}
+		return newRateLimitingSampler(s.RateLimitingSampling.MaxTracesPerSecond), nil
+	}
+	return nil, errors.Errorf("unknown sampling strategy type: %v", s.StrategyType)
+}
+
+type perOperationSampler struct {
+	operations map[string]sdktrace.Sampler
+	fallback   sdktrace.Sampler
+}
+
+func (s *perOperationSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	if sampler, ok := s.operations[p.Name]; ok {
+		return sampler.ShouldSample(p)
+	}
+	return s.fallback.ShouldSample(p)
+}
+
+func (s *perOperationSampler) Description() string {
+	return fmt.Sprintf("PerOperationSampler{operations=%d,default=%s}", len(s.operations), s.fallback.Description())
+}
+
+// rateLimitingSampler samples up to a number of traces per second using a token bucket.
+type rateLimitingSampler struct {
+	rate float64
+	now  func() time.Time
+
+	mu      sync.Mutex
+	balance float64
+	last    time.Time
+}
+
+func newRateLimitingSampler(maxTracesPerSecond float64) *rateLimitingSampler {
+	return &rateLimitingSampler{
+		rate:    maxTracesPerSecond,
+		now:     time.Now,
+		balance: maxTracesPerSecond,
+		last:    time.Now(),
+	}
+}
+
+func (s *rateLimitingSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	s.mu.Lock()
+	now := s.now()
+	s.balance += now.Sub(s.last).Seconds() * s.rate
+	s.last = now
+	if max := math.Max(s.rate, 1); s.balance > max {
+		s.balance = max
+	}
+	decision := sdktrace.Drop
+	if s.balance >= 1 {
+		s.balance--
+		decision = sdktrace.RecordAndSample
+	}
+	s.mu.Unlock()
+
+	return sdktrace.SamplingResult{
+		Decision:   decision,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (s *rateLimitingSampler) Description() string {
+	return fmt.Sprintf("RateLimitingSampler{%g}", s.rate)
+}
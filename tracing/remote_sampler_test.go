@@ -0,0 +1,59 @@
+package tracing_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/tracing"
+)
+
+func TestRemoteSampler(t *testing.T) {
+	var strategy atomic.Value
+	strategy.Store(`{"strategyType":"PROBABILISTIC","probabilisticSampling":{"samplingRate":0}}`)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "ORY X", r.URL.Query().Get("service"))
+		_, _ = fmt.Fprint(w, strategy.Load().(string))
+	}))
+	defer ts.Close()
+
+	s, err := tracing.NewRemoteSampler("ORY X", &tracing.RemoteSamplingConfig{
+		ServerURL:       ts.URL + "/sampling",
+		RefreshInterval: "10ms",
+	}, sdktrace.AlwaysSample(), nil)
+	require.NoError(t, err)
+	defer s.Close()
+
+	sampled := func(name string) func() bool {
+		return func() bool {
+			tid, _ := trace.TraceIDFromHex("0102030405060708090a0b0c0d0e0f10")
+			return s.ShouldSample(sdktrace.SamplingParameters{
+				ParentContext: context.Background(),
+				TraceID:       tid,
+				Name:          name,
+			}).Decision == sdktrace.RecordAndSample
+		}
+	}
+
+	assert.Eventually(t, func() bool { return !sampled("GET")() }, time.Second, 10*time.Millisecond)
+
+	strategy.Store(`{"strategyType":"PROBABILISTIC","operationSampling":{"defaultSamplingProbability":0,"perOperationStrategies":[{"operation":"POST","probabilisticSampling":{"samplingRate":1}}]}}`)
+	assert.Eventually(t, sampled("POST"), time.Second, 10*time.Millisecond)
+	assert.False(t, sampled("GET")())
+
+	strategy.Store(`{"strategyType":1,"rateLimitingSampling":{"maxTracesPerSecond":1}}`)
+	assert.Eventually(t, func() bool { return s.Description() == "RemoteSampler{RateLimitingSampler{1}}" }, time.Second, 10*time.Millisecond)
+
+	strategy.Store(`{"strategyType":"UNKNOWN"}`)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, "RemoteSampler{RateLimitingSampler{1}}", s.Description(), "invalid strategies must not replace the current one")
+}
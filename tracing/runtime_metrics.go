@@ -0,0 +1,98 @@
+package tracing
+
+import (
+	"context"
+	"runtime"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/unit"
+)
+
+// registerRuntimeMetrics registers instruments observing the Go runtime and the process.
+func registerRuntimeMetrics(m metric.Meter) error {
+	start := time.Now()
+	var (
+		uptime        metric.Int64CounterObserver
+		goroutines    metric.Int64GaugeObserver
+		cgoCalls      metric.Int64CounterObserver
+		gcCount       metric.Int64CounterObserver
+		gcPauseTotal  metric.Int64CounterObserver
+		heapAlloc     metric.Int64GaugeObserver
+		heapIdle      metric.Int64GaugeObserver
+		heapInuse     metric.Int64GaugeObserver
+		heapObjects   metric.Int64GaugeObserver
+		heapReleased  metric.Int64GaugeObserver
+		heapSys       metric.Int64GaugeObserver
+		liveObjects   metric.Int64GaugeObserver
+		lookups       metric.Int64CounterObserver
+		cpuTime       metric.Float64CounterObserver
+		userState     = []attribute.KeyValue{attribute.String("state", "user")}
+		systemState   = []attribute.KeyValue{attribute.String("state", "system")}
+		err           error
+		observeErrors []error
+	)
+
+	batch := m.NewBatchObserver(func(_ context.Context, r metric.BatchObserverResult) {
+		// collections can run concurrently, for example when several exporters are scraped
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		r.Observe(nil,
+			uptime.Observation(time.Since(start).Milliseconds()),
+			goroutines.Observation(int64(runtime.NumGoroutine())),
+			cgoCalls.Observation(runtime.NumCgoCall()),
+			gcCount.Observation(int64(memStats.NumGC)),
+			gcPauseTotal.Observation(int64(memStats.PauseTotalNs)),
+			heapAlloc.Observation(int64(memStats.HeapAlloc)),
+			heapIdle.Observation(int64(memStats.HeapIdle)),
+			heapInuse.Observation(int64(memStats.HeapInuse)),
+			heapObjects.Observation(int64(memStats.HeapObjects)),
+			heapReleased.Observation(int64(memStats.HeapReleased)),
+			heapSys.Observation(int64(memStats.HeapSys)),
+			liveObjects.Observation(int64(memStats.Mallocs-memStats.Frees)),
+			lookups.Observation(int64(memStats.Lookups)),
+		)
+
+		if user, system, ok := processCPUTime(); ok {
+			r.Observe(userState, cpuTime.Observation(user.Seconds()))
+			r.Observe(systemState, cpuTime.Observation(system.Seconds()))
+		}
+	})
+
+	int64Counter := func(name, description string, u unit.Unit) (o metric.Int64CounterObserver) {
+		o, err = batch.NewInt64CounterObserver(name, metric.WithDescription(description), metric.WithUnit(u))
+		observeErrors = append(observeErrors, err)
+		return o
+	}
+	int64Gauge := func(name, description string, u unit.Unit) (o metric.Int64GaugeObserver) {
+		o, err = batch.NewInt64GaugeObserver(name, metric.WithDescription(description), metric.WithUnit(u))
+		observeErrors = append(observeErrors, err)
+		return o
+	}
+
+	uptime = int64Counter("runtime.uptime", "Milliseconds since the application was initialized", unit.Milliseconds)
+	goroutines = int64Gauge("runtime.go.goroutines", "Number of goroutines that currently exist", unit.Dimensionless)
+	cgoCalls = int64Counter("runtime.go.cgo.calls", "Number of cgo calls made by the current process", unit.Dimensionless)
+	gcCount = int64Counter("runtime.go.gc.count", "Number of completed garbage collection cycles", unit.Dimensionless)
+	gcPauseTotal = int64Counter("runtime.go.gc.pause_total_ns", "Cumulative nanoseconds in garbage collection stop-the-world pauses", unit.Dimensionless)
+	heapAlloc = int64Gauge("runtime.go.mem.heap_alloc", "Bytes of allocated heap objects", unit.Bytes)
+	heapIdle = int64Gauge("runtime.go.mem.heap_idle", "Bytes in idle (unused) spans", unit.Bytes)
+	heapInuse = int64Gauge("runtime.go.mem.heap_inuse", "Bytes in in-use spans", unit.Bytes)
+	heapObjects = int64Gauge("runtime.go.mem.heap_objects", "Number of allocated heap objects", unit.Dimensionless)
+	heapReleased = int64Gauge("runtime.go.mem.heap_released", "Bytes of idle spans whose physical memory has been returned to the OS", unit.Bytes)
+	heapSys = int64Gauge("runtime.go.mem.heap_sys", "Bytes of heap memory obtained from the OS", unit.Bytes)
+	liveObjects = int64Gauge("runtime.go.mem.live_objects", "Number of live objects is the number of cumulative Mallocs - Frees", unit.Dimensionless)
+	lookups = int64Counter("runtime.go.lookups", "Number of pointer lookups performed by the runtime", unit.Dimensionless)
+
+	cpuTime, err = batch.NewFloat64CounterObserver("process.cpu.time", metric.WithDescription("Total CPU seconds broken down by state"), metric.WithUnit("s"))
+	observeErrors = append(observeErrors, err)
+
+	for _, err := range observeErrors {
+		if err != nil {
+			return errors.Wrap(err, "unable to register runtime metrics")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type routeSampler struct {
+	routes   []RouteSamplingConfig
+	samplers []sdktrace.Sampler
+	fallback sdktrace.Sampler
+	record   bool
+}
+
+// NewSampler returns a sampler which samples root spans with the ratio of the first route
+// matching the span, or with the default ratio. Routes are matched against the HTTP route or
+// target, the gRPC method ("service/method"), and the name of the span. Child spans follow
+// the decision of their parent.
+//
+// If c.KeepErrors is set, spans which are not sampled are still recorded so that
+// NewErrorSpanProcessor can export them if they end with an error status. Recording every span
+// removes most of the overhead sampling saves, only the export is still reduced.
+func NewSampler(c *SamplingConfig) sdktrace.Sampler {
+	return newSampler(c, nil)
+}
+
+// newSampler is NewSampler with a custom sampler for root spans which match no route.
+func newSampler(c *SamplingConfig, fallback sdktrace.Sampler) sdktrace.Sampler {
+	if c == nil {
+		c = new(SamplingConfig)
+	}
+
+	if fallback == nil {
+		fallback = ratioSampler(c)
+	}
+
+	s := &routeSampler{
+		routes:   make([]RouteSamplingConfig, len(c.Routes)),
+		samplers: make([]sdktrace.Sampler, len(c.Routes)),
+		fallback: fallback,
+		record:   c.KeepErrors,
+	}
+	for i, r := range c.Routes {
+		s.routes[i] = RouteSamplingConfig{Route: strings.ToLower(r.Route), Ratio: r.Ratio}
+		s.samplers[i] = sdktrace.TraceIDRatioBased(r.Ratio)
+	}
+
+	if !c.KeepErrors {
+		return sdktrace.ParentBased(s)
+	}
+	return sdktrace.ParentBased(s,
+		sdktrace.WithRemoteParentNotSampled(recordOnlySampler{}),
+		sdktrace.WithLocalParentNotSampled(recordOnlySampler{}),
+	)
+}
+
+func ratioSampler(c *SamplingConfig) sdktrace.Sampler {
+	if c == nil || c.Ratio == nil {
+		return sdktrace.TraceIDRatioBased(1)
+	}
+	return sdktrace.TraceIDRatioBased(*c.Ratio)
+}
+
+func (s *routeSampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	sampler := s.fallback
+	candidates := samplingRoutes(p)
+match:
+	for i, r := range s.routes {
+		for _, c := range candidates {
+			if matchesAny([]string{r.Route}, c) {
+				sampler = s.samplers[i]
+				break match
+			}
+		}
+	}
+
+	res := sampler.ShouldSample(p)
+	if s.record && res.Decision == sdktrace.Drop {
+		res.Decision = sdktrace.RecordOnly
+	}
+	return res
+}
+
+func (s *routeSampler) Description() string {
+	var routes []string
+	for i, r := range s.routes {
+		routes = append(routes, fmt.Sprintf("%s:%s", r.Route, s.samplers[i].Description()))
+	}
+	return fmt.Sprintf("RouteSampler{routes=[%s],default=%s,keepErrors=%t}", strings.Join(routes, ","), s.fallback.Description(), s.record)
+}
+
+func samplingRoutes(p sdktrace.SamplingParameters) []string {
+	var service, method string
+	candidates := make([]string, 0, 3)
+	for _, kv := range p.Attributes {
+		switch kv.Key {
+		case semconv.HTTPRouteKey:
+			candidates = append(candidates, kv.Value.AsString())
+		case semconv.HTTPTargetKey:
+			target := kv.Value.AsString()
+			if i := strings.IndexAny(target, "?#"); i >= 0 {
+				target = target[:i]
+			}
+			candidates = append(candidates, target)
+		case semconv.RPCServiceKey:
+			service = kv.Value.AsString()
+		case semconv.RPCMethodKey:
+			method = kv.Value.AsString()
+		}
+	}
+	if service != "" && method != "" {
+		candidates = append(candidates, service+"/"+method)
+	}
+	return append(candidates, p.Name)
+}
+
+type recordOnlySampler struct{}
+
+func (recordOnlySampler) ShouldSample(p sdktrace.SamplingParameters) sdktrace.SamplingResult {
+	return sdktrace.SamplingResult{
+		Decision:   sdktrace.RecordOnly,
+		Tracestate: trace.SpanContextFromContext(p.ParentContext).TraceState(),
+	}
+}
+
+func (recordOnlySampler) Description() string {
+	return "RecordOnly"
+}
+
+type errorSpanProcessor struct {
+	next sdktrace.SpanProcessor
+}
+
+var _ sdktrace.SpanProcessor = (*errorSpanProcessor)(nil)
+
+// NewErrorSpanProcessor wraps the span processor next so that spans which were recorded but
+// not sampled are passed to next as sampled spans if they end with an error status. All
+// other spans which are not sampled are dropped. Use it together with a sampler created by
+// NewSampler with SamplingConfig.KeepErrors set.
+//
+// Only the failed spans of a trace which was not sampled are exported, so the parent of an
+// exported span is missing unless it failed as well, and tracing backends show the span as the
+// root of an incomplete trace.
+func NewErrorSpanProcessor(next sdktrace.SpanProcessor) sdktrace.SpanProcessor {
+	return &errorSpanProcessor{next: next}
+}
+
+func (p *errorSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *errorSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if s.SpanContext().IsSampled() {
+		p.next.OnEnd(s)
+		return
+	}
+	if s.Status().Code == codes.Error {
+		p.next.OnEnd(&sampledSpan{ReadOnlySpan: s})
+	}
+}
+
+func (p *errorSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *errorSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+// sampledSpan marks the wrapped span as sampled.
+type sampledSpan struct {
+	sdktrace.ReadOnlySpan
+}
+
+func (s *sampledSpan) SpanContext() trace.SpanContext {
+	sc := s.ReadOnlySpan.SpanContext()
+	return sc.WithTraceFlags(sc.TraceFlags().WithSampled(true))
+}
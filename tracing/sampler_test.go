@@ -0,0 +1,87 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/pointerx"
+	"github.com/ory/x/tracing"
+)
+
+func TestSampler(t *testing.T) {
+	newProvider := func(c *tracing.SamplingConfig) (*tracetest.SpanRecorder, trace.Tracer) {
+		recorder := tracetest.NewSpanRecorder()
+		var processor sdktrace.SpanProcessor = recorder
+		if c.KeepErrors {
+			processor = tracing.NewErrorSpanProcessor(processor)
+		}
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSampler(tracing.NewSampler(c)), sdktrace.WithSpanProcessor(processor))
+		return recorder, tp.Tracer("github.com/ory/x/tracing_test")
+	}
+
+	names := func(spans []sdktrace.ReadOnlySpan) (out []string) {
+		for _, s := range spans {
+			out = append(out, s.Name())
+		}
+		return out
+	}
+
+	ctx := context.Background()
+
+	t.Run("case=samples per route", func(t *testing.T) {
+		recorder, tr := newProvider(&tracing.SamplingConfig{
+			Routes: []tracing.RouteSamplingConfig{
+				{Route: "/health/*", Ratio: 0},
+				{Route: "grpc.health.v1.Health/*", Ratio: 0},
+				{Route: "/admin/*", Ratio: 1},
+			},
+			Ratio: pointerx.Float64(0),
+		})
+
+		for _, s := range []struct {
+			name  string
+			attrs []attribute.KeyValue
+		}{
+			{name: "health", attrs: []attribute.KeyValue{attribute.String("http.target", "/health/alive?verbose")}},
+			{name: "grpc", attrs: []attribute.KeyValue{attribute.String("rpc.service", "grpc.health.v1.Health"), attribute.String("rpc.method", "Check")}},
+			{name: "admin", attrs: []attribute.KeyValue{attribute.String("http.route", "/admin/clients")}},
+			{name: "other", attrs: []attribute.KeyValue{attribute.String("http.target", "/self-service/login")}},
+		} {
+			sctx, span := tr.Start(ctx, s.name, trace.WithAttributes(s.attrs...))
+			_, child := tr.Start(sctx, s.name+".child")
+			child.End()
+			span.End()
+		}
+
+		assert.ElementsMatch(t, []string{"admin", "admin.child"}, names(recorder.Ended()))
+	})
+
+	t.Run("case=defaults to sampling everything", func(t *testing.T) {
+		recorder, tr := newProvider(&tracing.SamplingConfig{})
+		_, span := tr.Start(ctx, "GET")
+		span.End()
+		assert.Equal(t, []string{"GET"}, names(recorder.Ended()))
+	})
+
+	t.Run("case=keeps errors", func(t *testing.T) {
+		recorder, tr := newProvider(&tracing.SamplingConfig{Ratio: pointerx.Float64(0), KeepErrors: true})
+
+		sctx, span := tr.Start(ctx, "ok")
+		_, child := tr.Start(sctx, "failed")
+		child.SetStatus(codes.Error, "oops")
+		child.End()
+		span.End()
+
+		spans := recorder.Ended()
+		require.Equal(t, []string{"failed"}, names(spans))
+		assert.True(t, spans[0].SpanContext().IsSampled())
+	})
+}
@@ -0,0 +1,171 @@
+package tracing
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+
+	"github.com/ory/x/logrusx"
+)
+
+// urlAttributes are the span attributes matched against SpansConfig.DropURLs.
+var urlAttributes = []attribute.Key{
+	semconv.HTTPRouteKey,
+	semconv.HTTPTargetKey,
+	semconv.HTTPURLKey,
+}
+
+type filteringSpanProcessor struct {
+	next      sdktrace.SpanProcessor
+	dropNames []string
+	dropURLs  []string
+	redact    []string
+}
+
+var _ sdktrace.SpanProcessor = (*filteringSpanProcessor)(nil)
+
+// NewFilteringSpanProcessor wraps the span processor next. Spans whose name or URL matches
+// one of the patterns in c are dropped, and attributes whose key matches one of the redaction
+// patterns are replaced with logrusx.Redacted before they are passed to next.
+//
+// Patterns support the wildcards of path.Match and are matched case-insensitively.
+func NewFilteringSpanProcessor(next sdktrace.SpanProcessor, c *SpansConfig) sdktrace.SpanProcessor {
+	if c == nil {
+		c = new(SpansConfig)
+	}
+	return &filteringSpanProcessor{
+		next:      next,
+		dropNames: lowerAll(c.DropNames),
+		dropURLs:  lowerAll(c.DropURLs),
+		redact:    lowerAll(c.RedactAttributes),
+	}
+}
+
+func (p *filteringSpanProcessor) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	p.next.OnStart(ctx, s)
+}
+
+func (p *filteringSpanProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	if p.drop(s) {
+		return
+	}
+	p.next.OnEnd(p.redactSpan(s))
+}
+
+func (p *filteringSpanProcessor) Shutdown(ctx context.Context) error {
+	return p.next.Shutdown(ctx)
+}
+
+func (p *filteringSpanProcessor) ForceFlush(ctx context.Context) error {
+	return p.next.ForceFlush(ctx)
+}
+
+func (p *filteringSpanProcessor) drop(s sdktrace.ReadOnlySpan) bool {
+	if matchesAny(p.dropNames, s.Name()) {
+		return true
+	}
+	if len(p.dropURLs) == 0 {
+		return false
+	}
+
+	for _, kv := range s.Attributes() {
+		for _, key := range urlAttributes {
+			if kv.Key != key {
+				continue
+			}
+			u := kv.Value.AsString()
+			if kv.Key == semconv.HTTPURLKey {
+				// Match the path of absolute URLs.
+				if i := strings.Index(u, "://"); i >= 0 {
+					if j := strings.Index(u[i+3:], "/"); j >= 0 {
+						u = u[i+3+j:]
+					}
+				}
+			}
+			if i := strings.IndexAny(u, "?#"); i >= 0 {
+				u = u[:i]
+			}
+			if matchesAny(p.dropURLs, u) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *filteringSpanProcessor) redactSpan(s sdktrace.ReadOnlySpan) sdktrace.ReadOnlySpan {
+	if len(p.redact) == 0 {
+		return s
+	}
+
+	attrs, changed := p.redactAttributes(s.Attributes())
+	events := s.Events()
+	for i, e := range events {
+		if a, ok := p.redactAttributes(e.Attributes); ok {
+			if !changed {
+				events = append([]sdktrace.Event(nil), events...)
+			}
+			events[i].Attributes = a
+			changed = true
+		}
+	}
+
+	if !changed {
+		return s
+	}
+	return &redactedSpan{ReadOnlySpan: s, attrs: attrs, events: events}
+}
+
+func (p *filteringSpanProcessor) redactAttributes(attrs []attribute.KeyValue) ([]attribute.KeyValue, bool) {
+	var out []attribute.KeyValue
+	for i, kv := range attrs {
+		if !matchesAny(p.redact, string(kv.Key)) {
+			continue
+		}
+		if out == nil {
+			out = append([]attribute.KeyValue(nil), attrs...)
+		}
+		out[i] = kv.Key.String(logrusx.Redacted)
+	}
+	if out == nil {
+		return attrs, false
+	}
+	return out, true
+}
+
+// redactedSpan replaces the attributes and events of the wrapped span.
+type redactedSpan struct {
+	sdktrace.ReadOnlySpan
+	attrs  []attribute.KeyValue
+	events []sdktrace.Event
+}
+
+func (s *redactedSpan) Attributes() []attribute.KeyValue {
+	return s.attrs
+}
+
+func (s *redactedSpan) Events() []sdktrace.Event {
+	return s.events
+}
+
+func matchesAny(patterns []string, name string) bool {
+	name = strings.ToLower(name)
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func lowerAll(in []string) []string {
+	out := make([]string, len(in))
+	for i, s := range in {
+		out[i] = strings.ToLower(s)
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package tracing_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/logrusx"
+	"github.com/ory/x/tracing"
+)
+
+func TestFilteringSpanProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(tracing.NewFilteringSpanProcessor(recorder, &tracing.SpansConfig{
+		DropNames:        []string{"db.ping"},
+		DropURLs:         []string{"/health/*"},
+		RedactAttributes: []string{"*password*", "HTTP.Request.Header.Authorization"},
+	})))
+	tr := tp.Tracer("github.com/ory/x/tracing_test")
+	ctx := context.Background()
+
+	_, span := tr.Start(ctx, "db.ping")
+	span.End()
+
+	_, span = tr.Start(ctx, "GET", trace.WithAttributes(attribute.String("http.target", "/health/alive?foo=bar")))
+	span.End()
+
+	_, span = tr.Start(ctx, "GET", trace.WithAttributes(attribute.String("http.url", "https://example.com/health/ready")))
+	span.End()
+
+	_, span = tr.Start(ctx, "POST", trace.WithAttributes(
+		attribute.String("http.target", "/self-service/login"),
+		attribute.String("http.request.header.authorization", "Bearer secret"),
+	))
+	span.SetAttributes(attribute.String("user.Password", "secret"), attribute.Int("status", 200))
+	span.AddEvent("retry", trace.WithAttributes(attribute.String("password", "secret")))
+	span.End()
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+	assert.Equal(t, "POST", spans[0].Name())
+
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	assert.Equal(t, "/self-service/login", attrs["http.target"].AsString())
+	assert.Equal(t, logrusx.Redacted, attrs["http.request.header.authorization"].AsString())
+	assert.Equal(t, logrusx.Redacted, attrs["user.Password"].AsString())
+	assert.EqualValues(t, 200, attrs["status"].AsInt64())
+
+	require.Len(t, spans[0].Events(), 1)
+	assert.Equal(t, logrusx.Redacted, spans[0].Events()[0].Attributes[0].Value.AsString())
+
+	require.NoError(t, tp.Shutdown(ctx))
+}
@@ -2,9 +2,11 @@ package tracing
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"os"
 	"strings"
+	"time"
 
 	instana "github.com/instana/go-sensor"
 	"github.com/uber/jaeger-client-go"
@@ -42,6 +44,10 @@ type Tracer struct {
 	l      *logrusx.Logger
 	tracer opentracing.Tracer
 	closer io.Closer
+	logs   *LogHook
+	spans  *otelSdkTrace.TracerProvider
+	remote *RemoteSampler
+	meters *MeterProvider
 }
 
 func New(l *logrusx.Logger, c *Config) (*Tracer, error) {
@@ -51,6 +57,10 @@ func New(l *logrusx.Logger, c *Config) (*Tracer, error) {
 		return nil, err
 	}
 
+	if err := t.setupLogs(); err != nil {
+		return nil, err
+	}
+
 	return t, nil
 }
 
@@ -188,19 +198,38 @@ func (t *Tracer) setup() error {
 			return errors.Wrap(err, "new otel resource")
 		}
 
-		exporter, err := otlptracehttp.New(ctx)
+		var oc *OTLPConfig
+		if t.Config.Providers != nil {
+			oc = t.Config.Providers.OTLP
+		}
+		opts, err := otlpTraceOptions(oc)
+		if err != nil {
+			return err
+		}
+
+		exporter, err := otlptracehttp.New(ctx, opts...)
 		if err != nil {
 			return errors.Wrap(err, "new otel exporter")
 		}
 
+		sampler, err := t.sampler(serviceName)
+		if err != nil {
+			return err
+		}
+
+		processor := NewFilteringSpanProcessor(otelSdkTrace.NewSimpleSpanProcessor(exporter), t.Config.Spans)
+		if t.Config.Sampling != nil && t.Config.Sampling.KeepErrors {
+			processor = NewErrorSpanProcessor(processor)
+		}
+
 		tp := otelSdkTrace.NewTracerProvider(
 			otelSdkTrace.WithResource(res),
-			otelSdkTrace.WithSpanProcessor(
-				otelSdkTrace.NewSimpleSpanProcessor(exporter),
-			),
+			otelSdkTrace.WithSampler(sampler),
+			otelSdkTrace.WithSpanProcessor(processor),
 		)
 
 		otel.SetTracerProvider(tp)
+		t.spans = tp
 
 		bridge := otelOpentracing.NewBridgeTracer()
 		bridge.SetOpenTelemetryTracer(otel.Tracer(""))
@@ -217,6 +246,45 @@ func (t *Tracer) setup() error {
 	return nil
 }
 
+// sampler returns the sampler of the OpenTelemetry provider.
+func (t *Tracer) sampler(serviceName string) (otelSdkTrace.Sampler, error) {
+	c := t.Config.Sampling
+	if c == nil || c.Remote == nil {
+		return NewSampler(c), nil
+	}
+
+	remote, err := NewRemoteSampler(serviceName, c.Remote, ratioSampler(c), func(err error) {
+		t.l.WithError(err).Warn("Unable to fetch the sampling strategy.")
+	})
+	if err != nil {
+		return nil, err
+	}
+	t.remote = remote
+	return newSampler(c, remote), nil
+}
+
+// setupLogs exports the entries of the logger using OTLP if configured.
+func (t *Tracer) setupLogs() error {
+	if t.Config.Logs == nil || t.Config.Logs.Exporter == "" {
+		return nil
+	}
+
+	hook, err := NewLogHook(t.Config.ServiceName, t.Config.Logs, func(err error) {
+		// Logging the error would feed it back into the hook.
+		_, _ = fmt.Fprintf(os.Stderr, "Unable to export logs: %+v\n", err)
+	})
+	if err != nil {
+		return err
+	}
+
+	t.logs = hook
+	// Added through the logger, so that the component levels and sampling also apply to the
+	// exported entries.
+	t.l.AddHook(hook)
+	t.l.Infof("OTLP log exporter configured!")
+	return nil
+}
+
 // IsLoaded returns true if the tracer has been loaded.
 func (t *Tracer) IsLoaded() bool {
 	if t == nil || t.tracer == nil {
@@ -230,12 +298,81 @@ func (t *Tracer) Tracer() opentracing.Tracer {
 	return t.tracer
 }
 
-// Close closes the tracer.
-func (t *Tracer) Close() {
+// WithMeterProvider makes Flush and Shutdown export the metrics of p as well.
+func (t *Tracer) WithMeterProvider(p *MeterProvider) *Tracer {
+	t.meters = p
+	return t
+}
+
+// Flush exports all pending spans, log entries and metrics. Call it, for example, before a
+// short-lived command exits. Providers which do not support flushing are skipped.
+func (t *Tracer) Flush(ctx context.Context) error {
+	if t.spans != nil {
+		if err := t.spans.ForceFlush(ctx); err != nil {
+			return errors.Wrap(err, "unable to flush spans")
+		}
+	}
+	if t.logs != nil {
+		if err := t.logs.Flush(ctx); err != nil {
+			return errors.Wrap(err, "unable to flush logs")
+		}
+	}
+	if t.meters != nil {
+		if err := t.meters.ForceFlush(ctx); err != nil {
+			return errors.Wrap(err, "unable to flush metrics")
+		}
+	}
+	return nil
+}
+
+// Shutdown exports all pending telemetry and stops the exporters. It returns when all
+// exporters have stopped or when ctx is done, whichever happens first.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	var errs []string
+	if t.remote != nil {
+		t.remote.Close()
+	}
+	if t.spans != nil {
+		if err := t.spans.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to shut down the tracer provider: %s", err))
+		}
+	}
+	if t.logs != nil {
+		if err := t.logs.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to export logs: %s", err))
+		}
+	}
+	if t.meters != nil {
+		if err := t.meters.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Sprintf("unable to export metrics: %s", err))
+		}
+	}
 	if t.closer != nil {
-		err := t.closer.Close()
-		if err != nil {
-			t.l.WithError(err).Error("Unable to close tracer.")
+		closed := make(chan error, 1)
+		go func() {
+			closed <- t.closer.Close()
+		}()
+		select {
+		case err := <-closed:
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("unable to close tracer: %s", err))
+			}
+		case <-ctx.Done():
+			errs = append(errs, fmt.Sprintf("unable to close tracer: %s", ctx.Err()))
 		}
 	}
+
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close shuts down the tracer with a timeout of ten seconds and logs errors.
+func (t *Tracer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := t.Shutdown(ctx); err != nil {
+		t.l.WithError(err).Error("Unable to close tracer.")
+	}
 }
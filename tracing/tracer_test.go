@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"compress/zlib"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -350,3 +352,53 @@ func TestOtlpTracer(t *testing.T) {
 		t.Fatalf("Test server did not receive spans")
 	}
 }
+
+func TestTracerFlushAndShutdown(t *testing.T) {
+	var mu sync.Mutex
+	received := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		received[r.URL.Path]++
+	}))
+	defer ts.Close()
+
+	require.NoError(t, os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", ts.URL))
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+
+	l := logrusx.New("ory/x", "1")
+	tracer, err := tracing.New(l, &tracing.Config{
+		ServiceName: "ORY X",
+		Provider:    "otel",
+		Logs:        &tracing.LogsConfig{Exporter: "otlp", Interval: "1h"},
+	})
+	require.NoError(t, err)
+
+	meters, err := tracing.NewMeterProvider(l, &tracing.MetricsConfig{
+		Exporter:  "otlp",
+		Exporters: &tracing.MetricsExportersConfig{OTLP: &tracing.OTLPMetricsConfig{Interval: "1h"}},
+	})
+	require.NoError(t, err)
+	tracer.WithMeterProvider(meters)
+
+	opentracing.GlobalTracer().StartSpan("testOperation").Finish()
+	l.Info("flushed")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, tracer.Flush(ctx))
+
+	mu.Lock()
+	assert.Equal(t, 1, received["/v1/traces"])
+	assert.Equal(t, 1, received["/v1/logs"])
+	assert.Equal(t, 1, received["/v1/metrics"])
+	mu.Unlock()
+
+	l.Info("shut down")
+	require.NoError(t, tracer.Shutdown(ctx))
+
+	mu.Lock()
+	assert.Equal(t, 2, received["/v1/logs"])
+	assert.Equal(t, 2, received["/v1/metrics"])
+	mu.Unlock()
+}
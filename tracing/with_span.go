@@ -0,0 +1,36 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/ory/x/tracing"
+
+// WithSpan runs fn in a new span with the given name and attributes, using the global tracer
+// provider. If fn returns an error, the error is recorded and the status of the span is set
+// to error. If fn panics, the panic is recorded as well and re-raised after the span ended.
+func WithSpan(ctx context.Context, name string, fn func(context.Context) error, attrs ...attribute.KeyValue) (err error) {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+	defer func() {
+		if r := recover(); r != nil {
+			span.RecordError(fmt.Errorf("panic: %v", r), trace.WithStackTrace(true))
+			span.SetStatus(codes.Error, "panic")
+			span.End()
+			panic(r)
+		}
+
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	return fn(ctx)
+}
@@ -0,0 +1,69 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/ory/x/tracing"
+)
+
+func TestWithSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	defer otel.SetTracerProvider(previous)
+
+	ctx := context.Background()
+
+	t.Run("case=success", func(t *testing.T) {
+		err := tracing.WithSpan(ctx, "success", func(ctx context.Context) error {
+			assert.True(t, trace.SpanContextFromContext(ctx).IsValid())
+			return nil
+		}, attribute.String("foo", "bar"))
+		require.NoError(t, err)
+
+		spans := recorder.Ended()
+		s := spans[len(spans)-1]
+		assert.Equal(t, "success", s.Name())
+		assert.Equal(t, []attribute.KeyValue{attribute.String("foo", "bar")}, s.Attributes())
+		assert.Equal(t, codes.Unset, s.Status().Code)
+	})
+
+	t.Run("case=error", func(t *testing.T) {
+		expected := errors.New("oops")
+		err := tracing.WithSpan(ctx, "error", func(ctx context.Context) error {
+			return expected
+		})
+		assert.Equal(t, expected, err)
+
+		spans := recorder.Ended()
+		s := spans[len(spans)-1]
+		assert.Equal(t, codes.Error, s.Status().Code)
+		assert.Equal(t, "oops", s.Status().Description)
+		require.Len(t, s.Events(), 1)
+		assert.Equal(t, "exception", s.Events()[0].Name)
+	})
+
+	t.Run("case=panic", func(t *testing.T) {
+		assert.PanicsWithValue(t, "boom", func() {
+			_ = tracing.WithSpan(ctx, "panic", func(ctx context.Context) error {
+				panic("boom")
+			})
+		})
+
+		spans := recorder.Ended()
+		s := spans[len(spans)-1]
+		assert.Equal(t, "panic", s.Name())
+		assert.Equal(t, codes.Error, s.Status().Code)
+	})
+}
@@ -0,0 +1,101 @@
+package urlx
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// BuildPart modifies a URL constructed by Build.
+type BuildPart interface {
+	apply(u *url.URL)
+}
+
+type pathPart []interface{}
+
+// Path appends the given segments to the URL's path. Each segment is formatted using fmt.Sprint
+// and escaped, so a segment containing a slash or a question mark remains a single segment.
+func Path(segments ...interface{}) BuildPart {
+	return pathPart(segments)
+}
+
+func (p pathPart) apply(u *url.URL) {
+	segments := make([]string, 0, len(p)+1)
+	segments = append(segments, strings.TrimRight(u.EscapedPath(), "/"))
+	for _, s := range p {
+		segments = append(segments, url.PathEscape(fmt.Sprint(s)))
+	}
+
+	raw := strings.Join(segments, "/")
+	for strings.Contains(raw, "//") {
+		raw = strings.ReplaceAll(raw, "//", "/")
+	}
+	if !strings.HasPrefix(raw, "/") && u.Host != "" {
+		raw = "/" + raw
+	}
+
+	// The segments were escaped above, so unescaping can not fail.
+	u.Path, _ = url.PathUnescape(raw)
+	u.RawPath = raw
+}
+
+// Query sets the given query parameters, replacing parameters with the same name. Values are
+// formatted using fmt.Sprint; a []string value sets multiple values.
+type Query map[string]interface{}
+
+func (q Query) apply(u *url.URL) {
+	values := u.Query()
+	for k, v := range q {
+		if vs, ok := v.([]string); ok {
+			values[k] = vs
+			continue
+		}
+		values.Set(k, fmt.Sprint(v))
+	}
+	u.RawQuery = values.Encode()
+}
+
+type fragmentPart string
+
+// Fragment sets the URL's fragment.
+func Fragment(fragment string) BuildPart {
+	return fragmentPart(fragment)
+}
+
+func (f fragmentPart) apply(u *url.URL) {
+	u.Fragment = string(f)
+}
+
+// Build parses base and applies the given parts in order:
+//
+//	u, err := urlx.Build("https://example.org/api/", urlx.Path("users", id), urlx.Query{"include": "roles"})
+//
+// Path segments and query parameters are escaped, and duplicate slashes are removed from the
+// path.
+func Build(base string, parts ...BuildPart) (*url.URL, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return BuildFrom(u, parts...), nil
+}
+
+// BuildFrom applies the given parts to a copy of base. See Build.
+func BuildFrom(base *url.URL, parts ...BuildPart) *url.URL {
+	u := Copy(base)
+	for _, p := range parts {
+		p.apply(u)
+	}
+	return u
+}
+
+// MustBuild is like Build but panics if base can not be parsed.
+func MustBuild(base string, parts ...BuildPart) *url.URL {
+	u, err := Build(base, parts...)
+	if err != nil {
+		panic(err.Error())
+	}
+	return u
+}
@@ -0,0 +1,62 @@
+package urlx
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuild(t *testing.T) {
+	for k, tc := range []struct {
+		base   string
+		parts  []BuildPart
+		expect string
+	}{
+		{
+			base:   "https://example.org",
+			parts:  []BuildPart{Path("users", 123)},
+			expect: "https://example.org/users/123",
+		},
+		{
+			base:   "https://example.org/api/",
+			parts:  []BuildPart{Path("users", "a/b?c"), Path("roles")},
+			expect: "https://example.org/api/users/a%2Fb%3Fc/roles",
+		},
+		{
+			base:   "https://example.org//api//v1",
+			parts:  []BuildPart{Path("users")},
+			expect: "https://example.org/api/v1/users",
+		},
+		{
+			base:   "https://example.org/search?page=2&q=old",
+			parts:  []BuildPart{Query{"q": "a&b=c", "tag": []string{"x", "y"}}, Fragment("results")},
+			expect: "https://example.org/search?page=2&q=a%26b%3Dc&tag=x&tag=y#results",
+		},
+		{
+			base:   "/relative",
+			parts:  []BuildPart{Path("path with space")},
+			expect: "/relative/path%20with%20space",
+		},
+	} {
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			u, err := Build(tc.base, tc.parts...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, u.String())
+		})
+	}
+
+	t.Run("case=does not modify the base", func(t *testing.T) {
+		base := ParseOrPanic("https://example.org/api?foo=bar")
+		u := BuildFrom(base, Path("users"), Query{"foo": "baz"})
+		assert.Equal(t, "https://example.org/api?foo=bar", base.String())
+		assert.Equal(t, "https://example.org/api/users?foo=baz", u.String())
+	})
+
+	t.Run("case=fails on invalid base", func(t *testing.T) {
+		_, err := Build("://")
+		require.Error(t, err)
+		assert.Panics(t, func() { MustBuild("://") })
+	})
+}
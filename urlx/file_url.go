@@ -0,0 +1,112 @@
+package urlx
+
+import (
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// winDriveRegex matches a drive letter at the beginning of a Windows path.
+	winDriveRegex = regexp.MustCompile("^[A-Za-z]:")
+
+	// winURLDriveRegex matches a drive letter at the beginning of a file URL path.
+	winURLDriveRegex = regexp.MustCompile("^/[A-Za-z]:")
+
+	// uncHostRegex matches the host names supported in UNC paths.
+	uncHostRegex = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+)
+
+// FilePathToURL converts a path of the current operating system to a URL. Absolute paths are
+// converted to file:// URLs, relative paths to URLs without scheme, matching the URLs returned
+// by Parse. On Windows, drive letters and UNC paths (\\host\share\file) are supported.
+//
+// URLToFilePath is the inverse of this function.
+func FilePathToURL(p string) (*url.URL, error) {
+	return filePathToURL(p, runtime.GOOS == "windows")
+}
+
+// URLToFilePath converts a file:// URL or a URL without scheme to a path of the current
+// operating system. Unlike GetURLFilePath, it fails if the URL can not be represented as a
+// path, for example because it has a different scheme or, on POSIX systems, a host.
+//
+// FilePathToURL is the inverse of this function.
+func URLToFilePath(u *url.URL) (string, error) {
+	return urlToFilePath(u, runtime.GOOS == "windows")
+}
+
+// NormalizeFileURL returns a copy of u in canonical form, independent of the operating system
+// the URL was written on: Windows paths use forward slashes and exactly one slash precedes the
+// drive letter. Other URLs are returned unchanged.
+//
+//	file://C:\Users\foo  -> file:///C:/Users/foo
+//	file://////C:/foo    -> file:///C:/foo
+func NormalizeFileURL(u *url.URL) *url.URL {
+	out := Copy(u)
+	if out.Scheme != "file" || out.Host != "" {
+		return out
+	}
+
+	if trimmed := "/" + strings.TrimLeft(out.Path, "/"); winURLDriveRegex.MatchString(trimmed) {
+		out.Path = strings.ReplaceAll(trimmed, "\\", "/")
+		out.RawPath = ""
+	}
+	return out
+}
+
+func filePathToURL(p string, windows bool) (*url.URL, error) {
+	if !windows {
+		if strings.HasPrefix(p, "/") {
+			return &url.URL{Scheme: "file", Path: p}, nil
+		}
+		return &url.URL{Path: p}, nil
+	}
+
+	slashed := strings.ReplaceAll(p, "\\", "/")
+	switch {
+	case strings.HasPrefix(slashed, "//"):
+		host, rest := slashed[2:], ""
+		if i := strings.Index(host, "/"); i >= 0 {
+			host, rest = host[:i], host[i:]
+		}
+		if !uncHostRegex.MatchString(host) {
+			return nil, errors.Errorf("unable to convert UNC path %q to a URL: invalid host %q", p, host)
+		}
+		return &url.URL{Scheme: "file", Host: host, Path: rest}, nil
+	case winDriveRegex.MatchString(slashed):
+		return &url.URL{Scheme: "file", Path: "/" + slashed}, nil
+	case winURLDriveRegex.MatchString(slashed):
+		return nil, errors.Errorf("unable to convert path %q to a URL: unexpected drive letter", p)
+	case strings.HasPrefix(slashed, "/"):
+		return &url.URL{Scheme: "file", Path: slashed}, nil
+	}
+	return &url.URL{Path: slashed}, nil
+}
+
+func urlToFilePath(u *url.URL, windows bool) (string, error) {
+	if u == nil {
+		return "", errors.New("unable to convert URL to a path: URL is nil")
+	}
+	if u.Scheme != "file" && u.Scheme != "" {
+		return "", errors.Errorf("unable to convert URL %q to a path: unsupported scheme %q", u, u.Scheme)
+	}
+
+	if !windows {
+		if u.Host != "" && u.Host != "localhost" {
+			return "", errors.Errorf("unable to convert URL %q to a path: remote hosts are not supported", u)
+		}
+		return u.Path, nil
+	}
+
+	p := u.Path
+	if u.Host != "" {
+		return `\\` + u.Host + strings.ReplaceAll(p, "/", `\`), nil
+	}
+	if winURLDriveRegex.MatchString(p) {
+		p = p[1:]
+	}
+	return strings.ReplaceAll(p, "/", `\`), nil
+}
@@ -0,0 +1,69 @@
+//go:build go1.18
+// +build go1.18
+
+package urlx
+
+import (
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+func FuzzFilePathToURL(f *testing.F) {
+	for _, seed := range []string{
+		"/home/test/file 1.txt",
+		"relative/file?.txt",
+		"../file#1.txt",
+		"/dir/file%20.txt",
+		`C:\Users\test\file.txt`,
+		`C:/Users/mixed\separators.txt`,
+		`\\hostname\share\file.txt`,
+		`\rooted\file.txt`,
+		"",
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, p string) {
+		for _, windows := range []bool{false, true} {
+			u, err := filePathToURL(p, windows)
+			if err != nil {
+				continue
+			}
+
+			expected := p
+			if windows {
+				expected = strings.ReplaceAll(p, "/", `\`)
+			}
+
+			actual, err := urlToFilePath(u, windows)
+			if err != nil {
+				t.Fatalf("unable to convert %#v back to a path (windows=%v): %s", u, windows, err)
+			}
+			if actual != expected {
+				t.Fatalf("expected %q but got %q (windows=%v)", expected, actual, windows)
+			}
+
+			// Relative URLs may gain a "./" prefix when encoded, so compare them cleaned.
+			parsed, err := url.Parse(u.String())
+			if err != nil {
+				t.Fatalf("unable to parse %q (windows=%v): %s", u.String(), windows, err)
+			}
+			actual, err = urlToFilePath(parsed, windows)
+			if err != nil {
+				t.Fatalf("unable to convert %q back to a path (windows=%v): %s", u.String(), windows, err)
+			}
+			if clean(actual, windows) != clean(expected, windows) {
+				t.Fatalf("expected %q but got %q from %q (windows=%v)", expected, actual, u.String(), windows)
+			}
+		}
+	})
+}
+
+func clean(p string, windows bool) string {
+	if windows {
+		return strings.ReplaceAll(path.Clean(strings.ReplaceAll(p, `\`, "/")), "/", `\`)
+	}
+	return path.Clean(p)
+}
@@ -0,0 +1,106 @@
+package urlx
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilePathToURL(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		windows bool
+		url     string
+	}{
+		{path: "/home/test/file 1.txt", url: "file:///home/test/file%201.txt"},
+		{path: "relative/file?.txt", url: "relative/file%3F.txt"},
+		{path: "/dir/file\\ with backslash", url: "file:///dir/file%5C%20with%20backslash"},
+		{path: `C:\Users\test\file.txt`, windows: true, url: "file:///C:/Users/test/file.txt"},
+		{path: `C:/Users/mixed\separators.txt`, windows: true, url: "file:///C:/Users/mixed/separators.txt"},
+		{path: `C:`, windows: true, url: "file:///C:"},
+		{path: `\\hostname\share\file 2.txt`, windows: true, url: "file://hostname/share/file%202.txt"},
+		{path: `\\hostname`, windows: true, url: "file://hostname"},
+		{path: `\rooted\file.txt`, windows: true, url: "file:///rooted/file.txt"},
+		{path: `..\relative\file.txt`, windows: true, url: "../relative/file.txt"},
+	} {
+		t.Run("path="+tc.path, func(t *testing.T) {
+			u, err := filePathToURL(tc.path, tc.windows)
+			require.NoError(t, err)
+			assert.Equal(t, tc.url, u.String())
+
+			actual, err := urlToFilePath(ParseOrPanic(tc.url), tc.windows)
+			require.NoError(t, err)
+
+			expected := tc.path
+			if tc.windows {
+				expected = strings.ReplaceAll(expected, "/", `\`)
+			}
+			assert.Equal(t, expected, actual)
+		})
+	}
+
+	for _, tc := range []struct {
+		path    string
+		windows bool
+	}{
+		{path: `\\host name\share`, windows: true},
+		{path: `\\\share`, windows: true},
+		{path: `/C:/file.txt`, windows: true},
+	} {
+		t.Run("invalid="+tc.path, func(t *testing.T) {
+			_, err := filePathToURL(tc.path, tc.windows)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestURLToFilePath(t *testing.T) {
+	for _, tc := range []struct {
+		url     string
+		windows bool
+		path    string
+		err     bool
+	}{
+		{url: "file:///home/test/file%201.txt", path: "/home/test/file 1.txt"},
+		{url: "file://localhost/home/test", path: "/home/test"},
+		{url: "file://hostname/share/file.txt", err: true},
+		{url: "https://example.org/file.txt", err: true},
+		{url: "https://example.org/file.txt", windows: true, err: true},
+		{url: "file:///c:/file.txt", windows: true, path: `c:\file.txt`},
+		{url: "file://hostname/share/file.txt", windows: true, path: `\\hostname\share\file.txt`},
+	} {
+		t.Run("url="+tc.url, func(t *testing.T) {
+			actual, err := urlToFilePath(ParseOrPanic(tc.url), tc.windows)
+			if tc.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.path, actual)
+		})
+	}
+
+	_, err := URLToFilePath(nil)
+	require.Error(t, err)
+}
+
+func TestNormalizeFileURL(t *testing.T) {
+	for _, tc := range []struct {
+		in, expect string
+	}{
+		{in: `file://C:\Users\foo`, expect: "file:///C:/Users/foo"},
+		{in: `C:\Users\foo bar`, expect: "file:///C:/Users/foo%20bar"},
+		{in: "file://////C:/foo", expect: "file:///C:/foo"},
+		{in: "file:///home/foo\\bar", expect: "file:///home/foo%5Cbar"},
+		{in: `\\hostname\share\foo`, expect: "file://hostname/share/foo"},
+		{in: "https://example.org/C:/foo", expect: "https://example.org/C:/foo"},
+	} {
+		t.Run("url="+tc.in, func(t *testing.T) {
+			u, err := Parse(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, NormalizeFileURL(u).String())
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package urlx
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/idna"
+)
+
+// ParseIDN parses rawURL and converts an internationalized host name to its ASCII (punycode)
+// form, for example "https://bücher.example/" to "https://xn--bcher-kva.example/". The host
+// is validated according to IDNA2008 and lowercased, so the result is safe to compare.
+func ParseIDN(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	host, err := NormalizeHost(u.Host)
+	if err != nil {
+		return nil, err
+	}
+	u.Host = host
+	return u, nil
+}
+
+// NormalizeHost converts host, which may include a port, to its lowercase ASCII (punycode)
+// form. IP addresses are returned unchanged, and ASCII host names which are not punycode
+// encoded are only lowercased, so that names such as "my_service" remain valid.
+func NormalizeHost(host string) (string, error) {
+	name, port := splitHostPort(host)
+	if name == "" || net.ParseIP(strings.Trim(name, "[]")) != nil {
+		return host, nil
+	}
+	if isASCII(name) && !strings.Contains(strings.ToLower(name), "xn--") {
+		return joinHostPort(strings.ToLower(name), port), nil
+	}
+
+	ascii, err := idna.Lookup.ToASCII(name)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid host name %q", name)
+	}
+	return joinHostPort(strings.ToLower(ascii), port), nil
+}
+
+// DisplayHost converts host, which may include a port, to its Unicode form for displaying it to
+// users. Host names which could be confused with other host names (see IsConfusableHost) are
+// returned in their ASCII form instead, like browsers do.
+func DisplayHost(host string) string {
+	name, port := splitHostPort(host)
+	unicodeName, err := idna.Display.ToUnicode(name)
+	if err != nil || IsConfusableHost(unicodeName) {
+		if ascii, err := idna.Display.ToASCII(name); err == nil {
+			return joinHostPort(ascii, port)
+		}
+		return host
+	}
+	return joinHostPort(unicodeName, port)
+}
+
+// DisplayURL returns u as a string with the host converted using DisplayHost.
+func DisplayURL(u *url.URL) string {
+	if u.Host == "" {
+		return u.String()
+	}
+
+	// url.URL.String would percent-encode the Unicode host.
+	rest := Copy(u)
+	rest.Scheme, rest.User, rest.Host = "", nil, ""
+	var userinfo string
+	if u.User != nil {
+		userinfo = u.User.String() + "@"
+	}
+	return u.Scheme + "://" + userinfo + DisplayHost(u.Host) + rest.String()
+}
+
+// confusableLetters are Cyrillic and Greek letters which look like Latin letters.
+const confusableLetters = "аеорсухіјѕԁԛԝѵӏһкмпт" + "АВЕЗІЈКМНОРСТУХЅ" + "οαν" + "ΑΒΕΗΙΚΜΝΟΡΤΥΧΖ"
+
+// IsConfusableHost reports whether a label of host, in Unicode or ASCII form, mixes letters of
+// the Latin script with Cyrillic or Greek letters, or consists entirely of Cyrillic or Greek
+// letters which look like Latin letters (such as "аррӏе", which resembles "apple"). Such host
+// names are commonly used for phishing.
+func IsConfusableHost(host string) bool {
+	name, _ := splitHostPort(host)
+	if unicodeName, err := idna.Display.ToUnicode(name); err == nil {
+		name = unicodeName
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		var latin, other, lookalike, letters int
+		for _, r := range label {
+			if !unicode.IsLetter(r) {
+				continue
+			}
+			letters++
+			switch {
+			case unicode.Is(unicode.Latin, r):
+				latin++
+			case unicode.In(r, unicode.Cyrillic, unicode.Greek):
+				other++
+				if strings.ContainsRune(confusableLetters, r) {
+					lookalike++
+				}
+			}
+		}
+
+		if latin > 0 && other > 0 {
+			return true
+		}
+		if other > 0 && lookalike == letters {
+			return true
+		}
+	}
+	return false
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func splitHostPort(host string) (name, port string) {
+	if h, p, err := net.SplitHostPort(host); err == nil {
+		if strings.Contains(h, ":") {
+			h = "[" + h + "]"
+		}
+		return h, p
+	}
+	return host, ""
+}
+
+func joinHostPort(name, port string) string {
+	if port == "" {
+		return name
+	}
+	return name + ":" + port
+}
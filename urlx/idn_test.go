@@ -0,0 +1,63 @@
+package urlx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIDN(t *testing.T) {
+	for _, tc := range []struct {
+		in, expect string
+	}{
+		{in: "https://bücher.example/foo", expect: "https://xn--bcher-kva.example/foo"},
+		{in: "https://Bücher.Example:8443/", expect: "https://xn--bcher-kva.example:8443/"},
+		{in: "https://xn--bcher-kva.example", expect: "https://xn--bcher-kva.example"},
+		{in: "https://EXAMPLE.org", expect: "https://example.org"},
+		{in: "http://[::1]:4444/", expect: "http://[::1]:4444/"},
+		{in: "/relative", expect: "/relative"},
+		{in: "http://my_service:4444", expect: "http://my_service:4444"},
+	} {
+		t.Run("case="+tc.in, func(t *testing.T) {
+			u, err := ParseIDN(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expect, u.String())
+		})
+	}
+
+	_, err := ParseIDN("https://-bücher.example")
+	require.Error(t, err)
+}
+
+func TestDisplayHost(t *testing.T) {
+	assert.Equal(t, "bücher.example", DisplayHost("xn--bcher-kva.example"))
+	assert.Equal(t, "bücher.example:8443", DisplayHost("xn--bcher-kva.example:8443"))
+	assert.Equal(t, "example.org", DisplayHost("example.org"))
+	assert.Equal(t, "[::1]:80", DisplayHost("[::1]:80"))
+
+	// Cyrillic "аррӏе" resembles "apple" and must not be displayed in Unicode.
+	assert.Equal(t, "xn--80ak6aa92e.com", DisplayHost("аррӏе.com"))
+	assert.Equal(t, "https://xn--80ak6aa92e.com/login", DisplayURL(ParseOrPanic("https://аррӏе.com/login")))
+	assert.Equal(t, "https://user@bücher.example/foo%20bar?baz#qux", DisplayURL(ParseOrPanic("https://user@xn--bcher-kva.example/foo%20bar?baz#qux")))
+}
+
+func TestIsConfusableHost(t *testing.T) {
+	for _, tc := range []struct {
+		host   string
+		expect bool
+	}{
+		{host: "example.org"},
+		{host: "bücher.example"},
+		{host: "пример.рф"},
+		{host: "例え.jp"},
+		{host: "аррӏе.com", expect: true},
+		{host: "xn--80ak6aa92e.com", expect: true},
+		{host: "pаypal.com", expect: true},
+		{host: "οrange.com", expect: true},
+	} {
+		t.Run("case="+tc.host, func(t *testing.T) {
+			assert.Equal(t, tc.expect, IsConfusableHost(tc.host))
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package urlx
+
+import (
+	"net/url"
+	"strings"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// Origin returns the normalized origin of u in the form scheme://host[:port]. The scheme and
+// host are lowercased and default ports are omitted, so "HTTPS://Example.org:443/foo" and
+// "https://example.org" have the same origin.
+func Origin(u *url.URL) string {
+	scheme, host, port := originParts(u)
+	if port != "" {
+		host += ":" + port
+	}
+	return scheme + "://" + host
+}
+
+func originParts(u *url.URL) (scheme, host, port string) {
+	scheme = strings.ToLower(u.Scheme)
+	host = strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	port = u.Port()
+	if port == defaultPorts[scheme] {
+		port = ""
+	}
+	return scheme, host, port
+}
+
+// SameOrigin reports whether a and b have the same scheme, host, and port, treating default
+// ports as equal to no port.
+func SameOrigin(a, b *url.URL) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	return Origin(a) == Origin(b)
+}
+
+// MatchesOrigin reports whether the origin of u matches pattern. The pattern is an origin such
+// as "https://example.org:8080" or "*", which matches all origins. The host of the pattern may
+// start with a wildcard label, so "https://*.example.org" matches "https://foo.example.org" and
+// "https://foo.bar.example.org", but not "https://example.org". Default ports are normalized.
+func MatchesOrigin(pattern string, u *url.URL) bool {
+	if u == nil {
+		return false
+	}
+	if pattern == "*" {
+		return true
+	}
+
+	wildcard := strings.Contains(pattern, "://*.")
+	if wildcard {
+		pattern = strings.Replace(pattern, "://*.", "://", 1)
+	}
+
+	p, err := url.Parse(pattern)
+	if err != nil || p.Host == "" {
+		return false
+	}
+
+	pScheme, pHost, pPort := originParts(p)
+	scheme, host, port := originParts(u)
+	if pScheme != scheme || pPort != port {
+		return false
+	}
+	if !wildcard {
+		return pHost == host
+	}
+	return strings.HasSuffix(host, "."+pHost) && len(host) > len(pHost)+1
+}
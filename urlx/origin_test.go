@@ -0,0 +1,60 @@
+package urlx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrigin(t *testing.T) {
+	assert.Equal(t, "https://example.org", Origin(ParseOrPanic("HTTPS://Example.org:443/foo?bar")))
+	assert.Equal(t, "http://example.org:8080", Origin(ParseOrPanic("http://example.org:8080")))
+	assert.Equal(t, "http://[::1]", Origin(ParseOrPanic("http://[::1]:80/")))
+}
+
+func TestSameOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		a, b   string
+		expect bool
+	}{
+		{a: "https://example.org/foo", b: "https://example.org/bar", expect: true},
+		{a: "https://example.org:443", b: "https://EXAMPLE.org", expect: true},
+		{a: "http://example.org:80", b: "http://example.org", expect: true},
+		{a: "https://example.org.", b: "https://example.org", expect: true},
+		{a: "http://example.org", b: "https://example.org"},
+		{a: "http://example.org:8080", b: "http://example.org"},
+		{a: "http://example.org:443", b: "https://example.org"},
+		{a: "https://foo.example.org", b: "https://example.org"},
+	} {
+		t.Run("case="+tc.a+" "+tc.b, func(t *testing.T) {
+			assert.Equal(t, tc.expect, SameOrigin(ParseOrPanic(tc.a), ParseOrPanic(tc.b)))
+		})
+	}
+	assert.False(t, SameOrigin(nil, ParseOrPanic("https://example.org")))
+}
+
+func TestMatchesOrigin(t *testing.T) {
+	for _, tc := range []struct {
+		pattern, u string
+		expect     bool
+	}{
+		{pattern: "*", u: "https://example.org", expect: true},
+		{pattern: "https://example.org", u: "https://example.org:443/foo", expect: true},
+		{pattern: "https://example.org:443", u: "https://example.org", expect: true},
+		{pattern: "https://example.org", u: "http://example.org"},
+		{pattern: "https://example.org", u: "https://example.org:8443"},
+		{pattern: "https://*.example.org", u: "https://foo.example.org", expect: true},
+		{pattern: "https://*.example.org", u: "https://foo.bar.Example.org", expect: true},
+		{pattern: "https://*.example.org:8443", u: "https://foo.example.org:8443", expect: true},
+		{pattern: "https://*.example.org", u: "https://example.org"},
+		{pattern: "https://*.example.org", u: "https://fooexample.org"},
+		{pattern: "https://*.example.org", u: "https://foo.example.org.evil.com"},
+		{pattern: "https://*.example.org", u: "http://foo.example.org"},
+		{pattern: "example.org", u: "https://example.org"},
+	} {
+		t.Run("case="+tc.pattern+" "+tc.u, func(t *testing.T) {
+			assert.Equal(t, tc.expect, MatchesOrigin(tc.pattern, ParseOrPanic(tc.u)))
+		})
+	}
+	assert.False(t, MatchesOrigin("*", nil))
+}
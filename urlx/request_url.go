@@ -0,0 +1,82 @@
+package urlx
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// GuessRequestURL reconstructs the URL of an incoming request as seen by the client.
+//
+// The scheme is derived from r.TLS and the host from r.Host. If trustForwarded is true, the
+// `Forwarded` (RFC 7239) header, or alternatively the `X-Forwarded-Proto` and `X-Forwarded-Host`
+// headers, take precedence, using the values added by the outermost proxy. The
+// `X-Forwarded-Prefix` header is prepended to the path, for proxies which strip a path prefix
+// before forwarding the request.
+//
+// Only trust the forwarded headers if the service is reachable exclusively through proxies
+// which overwrite them, as they can be set by any client otherwise.
+func GuessRequestURL(r *http.Request, trustForwarded bool) *url.URL {
+	u := Copy(r.URL)
+	u.Scheme = "http"
+	if r.TLS != nil {
+		u.Scheme = "https"
+	}
+	u.Host = r.Host
+	if u.Host == "" {
+		u.Host = r.URL.Host
+	}
+	u.User = nil
+
+	if !trustForwarded {
+		return u
+	}
+
+	proto, host := parseForwardedProtoHost(r.Header.Get("Forwarded"))
+	if proto == "" {
+		proto = firstHeaderValue(r.Header, "X-Forwarded-Proto")
+	}
+	if host == "" {
+		host = firstHeaderValue(r.Header, "X-Forwarded-Host")
+	}
+
+	if proto = strings.ToLower(proto); proto == "http" || proto == "https" {
+		u.Scheme = proto
+	}
+	if host != "" {
+		u.Host = host
+	}
+
+	if prefix := strings.Trim(firstHeaderValue(r.Header, "X-Forwarded-Prefix"), "/"); prefix != "" {
+		u.Path = "/" + prefix + "/" + strings.TrimLeft(u.Path, "/")
+		if u.RawPath != "" {
+			u.RawPath = "/" + prefix + "/" + strings.TrimLeft(u.RawPath, "/")
+		}
+	}
+
+	return u
+}
+
+// parseForwardedProtoHost returns the proto and host of the first element of an RFC 7239
+// Forwarded header.
+func parseForwardedProtoHost(header string) (proto, host string) {
+	first := strings.SplitN(header, ",", 2)[0]
+	for _, pair := range strings.Split(first, ";") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		v := strings.Trim(kv[1], `"`)
+		switch strings.ToLower(kv[0]) {
+		case "proto":
+			proto = v
+		case "host":
+			host = v
+		}
+	}
+	return proto, host
+}
+
+func firstHeaderValue(h http.Header, key string) string {
+	return strings.TrimSpace(strings.SplitN(h.Get(key), ",", 2)[0])
+}
@@ -0,0 +1,73 @@
+package urlx
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuessRequestURL(t *testing.T) {
+	for _, tc := range []struct {
+		d              string
+		target         string
+		tls            bool
+		header         http.Header
+		trustForwarded bool
+		expect         string
+	}{
+		{
+			d:      "plain request",
+			target: "http://example.org/foo?bar=baz",
+			expect: "http://example.org/foo?bar=baz",
+		},
+		{
+			d:      "tls request",
+			target: "https://example.org/foo",
+			tls:    true,
+			expect: "https://example.org/foo",
+		},
+		{
+			d:      "ignores untrusted headers",
+			target: "http://internal:4433/foo",
+			header: http.Header{"X-Forwarded-Proto": {"https"}, "X-Forwarded-Host": {"example.org"}},
+			expect: "http://internal:4433/foo",
+		},
+		{
+			d:              "uses X-Forwarded headers",
+			target:         "http://internal:4433/foo",
+			header:         http.Header{"X-Forwarded-Proto": {"https, http"}, "X-Forwarded-Host": {"example.org"}, "X-Forwarded-Prefix": {"/api/"}},
+			trustForwarded: true,
+			expect:         "https://example.org/api/foo",
+		},
+		{
+			d:              "prefers the Forwarded header",
+			target:         "http://internal:4433/foo",
+			header:         http.Header{"Forwarded": {`for=192.0.2.60;proto=https;host="example.org:8443", for=10.0.0.1;proto=http`}, "X-Forwarded-Host": {"other.org"}},
+			trustForwarded: true,
+			expect:         "https://example.org:8443/foo",
+		},
+		{
+			d:              "ignores invalid schemes",
+			target:         "http://internal/foo",
+			header:         http.Header{"X-Forwarded-Proto": {"javascript"}},
+			trustForwarded: true,
+			expect:         "http://internal/foo",
+		},
+	} {
+		t.Run("case="+tc.d, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.target, nil)
+			if !tc.tls {
+				r.TLS = nil
+			} else if r.TLS == nil {
+				r.TLS = new(tls.ConnectionState)
+			}
+			for k, v := range tc.header {
+				r.Header[k] = v
+			}
+			assert.Equal(t, tc.expect, GuessRequestURL(r, tc.trustForwarded).String())
+		})
+	}
+}
@@ -81,7 +81,7 @@ func WatchChangeFeed(ctx context.Context, cx *sqlx.DB, tableName string, c Event
 		}
 	}
 
-	d := newDispatcher()
+	d := newDispatcher(tableName)
 
 	go func() {
 		for {
@@ -99,6 +99,7 @@ func WatchChangeFeed(ctx context.Context, cx *sqlx.DB, tableName string, c Event
 	done := make(chan struct{})
 	go func() {
 		defer func() {
+			d.status.setConnected(false)
 			done <- struct{}{}
 		}()
 
@@ -107,9 +108,9 @@ func WatchChangeFeed(ctx context.Context, cx *sqlx.DB, tableName string, c Event
 			var table string
 
 			if err := errors.WithStack(rows.Scan(&table, &r.key, &r.value)); err != nil {
-				c <- &ErrorEvent{
+				d.status.send(c, &ErrorEvent{
 					error: err,
-				}
+				})
 				continue
 			}
 
@@ -129,14 +130,14 @@ func WatchChangeFeed(ctx context.Context, cx *sqlx.DB, tableName string, c Event
 
 			after := gjson.Get(r.value, "after")
 			if after.IsObject() {
-				c <- &ChangeEvent{
+				d.status.send(c, &ChangeEvent{
 					data:   []byte(after.Raw),
 					source: source(eventSource),
-				}
+				})
 			} else {
-				c <- &RemoveEvent{
+				d.status.send(c, &RemoveEvent{
 					source: source(eventSource),
-				}
+				})
 			}
 		}
 	}()
@@ -150,16 +151,16 @@ func WatchChangeFeed(ctx context.Context, cx *sqlx.DB, tableName string, c Event
 		}
 
 		if err := rows.Close(); err != nil {
-			c <- &ErrorEvent{
+			d.status.send(c, &ErrorEvent{
 				error: err,
-			}
+			})
 			return
 		}
 
 		if err := cx.Close(); err != nil {
-			c <- &ErrorEvent{
+			d.status.send(c, &ErrorEvent{
 				error: err,
-			}
+			})
 			return
 		}
 		// end close
@@ -0,0 +1,144 @@
+package watcherx
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// DebounceOption configures Debounce.
+	DebounceOption func(d *debouncer)
+
+	debouncer struct {
+		quiet   time.Duration
+		maxWait time.Duration
+	}
+	pendingEvent struct {
+		event    Event
+		first    time.Time
+		last     time.Time
+		sequence int
+	}
+)
+
+// WithDebounceMaxWait forwards an event at the latest after the given duration since the first
+// event of the source was received, even if the source keeps changing. Defaults to no limit.
+func WithDebounceMaxWait(maxWait time.Duration) DebounceOption {
+	return func(d *debouncer) {
+		d.maxWait = maxWait
+	}
+}
+
+// Debounce coalesces rapid events per source: an event is only forwarded once no further event
+// of the same source was received for the quiet duration, and only the last event is forwarded.
+// This way, editors and sync tools which write a file in several steps cause a single event.
+//
+// Error events are forwarded immediately. The returned channel is closed once the input channel
+// is closed, after the pending events were forwarded, or when the context is canceled. Note that
+// the events caused by Watcher.DispatchNow are debounced as well.
+func Debounce(ctx context.Context, in EventChannel, quiet time.Duration, opts ...DebounceOption) EventChannel {
+	d := &debouncer{quiet: quiet}
+	for _, f := range opts {
+		f(d)
+	}
+
+	out := make(EventChannel)
+	go d.run(ctx, in, out)
+	return out
+}
+
+func (d *debouncer) deadline(p *pendingEvent) time.Time {
+	deadline := p.last.Add(d.quiet)
+	if d.maxWait > 0 && p.first.Add(d.maxWait).Before(deadline) {
+		return p.first.Add(d.maxWait)
+	}
+	return deadline
+}
+
+// next returns the pending event which has to be forwarded first.
+func (d *debouncer) next(pending map[string]*pendingEvent) *pendingEvent {
+	var next *pendingEvent
+	for _, p := range pending {
+		if next == nil {
+			next = p
+			continue
+		}
+		if dp, dn := d.deadline(p), d.deadline(next); dp.Before(dn) || (dp.Equal(dn) && p.sequence < next.sequence) {
+			next = p
+		}
+	}
+	return next
+}
+
+func (d *debouncer) run(ctx context.Context, in EventChannel, out EventChannel) {
+	defer close(out)
+
+	var (
+		pending  = map[string]*pendingEvent{}
+		sequence int
+		timer    = time.NewTimer(time.Hour)
+	)
+	defer timer.Stop()
+
+	send := func(e Event) bool {
+		select {
+		case out <- e:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	for {
+		var fire <-chan time.Time
+		if next := d.next(pending); next != nil {
+			if !timer.Stop() {
+				select {
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(time.Until(d.deadline(next)))
+			fire = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-in:
+			if !ok {
+				for next := d.next(pending); next != nil; next = d.next(pending) {
+					delete(pending, next.event.Source())
+					if !send(next.event) {
+						return
+					}
+				}
+				return
+			}
+
+			if _, isError := e.(*ErrorEvent); isError {
+				if !send(e) {
+					return
+				}
+				continue
+			}
+
+			now := time.Now()
+			sequence++
+			if p, ok := pending[e.Source()]; ok {
+				p.event, p.last, p.sequence = e, now, sequence
+				continue
+			}
+			pending[e.Source()] = &pendingEvent{event: e, first: now, last: now, sequence: sequence}
+		case <-fire:
+			next := d.next(pending)
+			if time.Now().Before(d.deadline(next)) {
+				continue
+			}
+			delete(pending, next.event.Source())
+			if !send(next.event) {
+				return
+			}
+		}
+	}
+}
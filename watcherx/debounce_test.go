@@ -0,0 +1,120 @@
+package watcherx
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebounce(t *testing.T) {
+	t.Run("case=coalesces events per source", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		in := make(EventChannel)
+		out := Debounce(ctx, in, 50*time.Millisecond)
+
+		in <- &ChangeEvent{data: []byte("1"), source: "a"}
+		in <- &ChangeEvent{data: []byte("1"), source: "b"}
+		in <- &ChangeEvent{data: []byte("2"), source: "a"}
+		in <- &RemoveEvent{source: "b"}
+		in <- &ChangeEvent{data: []byte("3"), source: "a"}
+
+		assertRemove(t, receive(t, out), "b")
+		assertChange(t, receive(t, out), "3", "a")
+
+		select {
+		case e := <-out:
+			t.Fatalf("unexpected event: %s", e)
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("case=forwards errors immediately", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		in := make(EventChannel)
+		out := Debounce(ctx, in, time.Hour)
+
+		in <- &ChangeEvent{data: []byte("1"), source: "a"}
+		in <- &ErrorEvent{error: errors.New("some error"), source: "a"}
+		e := receive(t, out)
+		require.IsType(t, &ErrorEvent{}, e)
+		assert.EqualError(t, e.(*ErrorEvent), "some error")
+	})
+
+	t.Run("case=forwards pending events when the input is closed", func(t *testing.T) {
+		in := make(EventChannel)
+		out := Debounce(context.Background(), in, time.Hour)
+
+		in <- &ChangeEvent{data: []byte("1"), source: "a"}
+		in <- &ChangeEvent{data: []byte("1"), source: "b"}
+		close(in)
+
+		assertChange(t, receive(t, out), "1", "a")
+		assertChange(t, receive(t, out), "1", "b")
+		_, ok := <-out
+		assert.False(t, ok)
+	})
+
+	t.Run("case=forwards events after the maximum wait", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		in := make(EventChannel)
+		out := Debounce(ctx, in, 100*time.Millisecond, WithDebounceMaxWait(200*time.Millisecond))
+
+		start := time.Now()
+		go func() {
+			for {
+				select {
+				case in <- &ChangeEvent{data: []byte("last"), source: "a"}:
+				case <-ctx.Done():
+					return
+				}
+				time.Sleep(20 * time.Millisecond)
+			}
+		}()
+
+		assertChange(t, receive(t, out), "last", "a")
+		assert.True(t, time.Since(start) >= 200*time.Millisecond, "%s", time.Since(start))
+	})
+
+	t.Run("case=debounces multi-step file writes", func(t *testing.T) {
+		ctx, c, dir, cancel := setup(t)
+		defer cancel()
+
+		file := filepath.Join(dir, "config.yaml")
+		_, err := WatchFile(ctx, file, c)
+		require.NoError(t, err)
+		out := Debounce(ctx, c, 100*time.Millisecond)
+
+		f, err := os.Create(file)
+		require.NoError(t, err)
+		for _, part := range []string{"foo: ", "bar\n", "baz: qux\n"} {
+			_, err = f.WriteString(part)
+			require.NoError(t, err)
+			require.NoError(t, f.Sync())
+		}
+		require.NoError(t, f.Close())
+
+		e := receive(t, out)
+		data, err := ioutil.ReadAll(e.Reader())
+		require.NoError(t, err)
+		assert.Equal(t, "foo: bar\nbaz: qux\n", string(data))
+
+		select {
+		case e := <-out:
+			t.Fatalf("unexpected event: %s", e)
+		case <-time.After(300 * time.Millisecond):
+		}
+	})
+}
@@ -21,6 +21,7 @@ type (
 	dispatcher struct {
 		trigger chan struct{}
 		done    chan int
+		status  *statusTracker
 	}
 )
 
@@ -39,10 +40,11 @@ func (e *errSchemeUnknown) Error() string {
 	return fmt.Sprintf("unknown scheme '%s' to watch", e.scheme)
 }
 
-func newDispatcher() *dispatcher {
+func newDispatcher(src string) *dispatcher {
 	return &dispatcher{
 		trigger: make(chan struct{}),
 		done:    make(chan int),
+		status:  newStatusTracker(src),
 	}
 }
 
@@ -61,6 +63,10 @@ func Watch(ctx context.Context, u *url.URL, c EventChannel) (Watcher, error) {
 		return WatchFile(ctx, u.Path, c)
 	case "ws":
 		return WatchWebsocket(ctx, u, c)
+	case "kubernetes":
+		return WatchKubernetes(ctx, u, c)
+	case "s3", "gs", "azblob":
+		return WatchObjectStorage(ctx, u, c)
 	}
 	return nil, &errSchemeUnknown{u.Scheme}
 }
@@ -4,132 +4,253 @@ import (
 	"context"
 	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
-	"time"
+	"sort"
+	"strings"
 
+	"github.com/bmatcuk/doublestar/v2"
 	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
 )
 
-func WatchDirectory(ctx context.Context, dir string, c EventChannel) (Watcher, error) {
+type (
+	// DirectoryOption configures WatchDirectory.
+	DirectoryOption func(w *directoryWatcher)
+
+	directoryWatcher struct {
+		dir     string
+		include []string
+		exclude []string
+
+		w      *fsnotify.Watcher
+		c      EventChannel
+		status *statusTracker
+
+		// dirs and files are the watched directories and files.
+		dirs  map[string]bool
+		files map[string]bool
+	}
+)
+
+// WithDirectoryInclude only sends events for files whose path relative to the watched directory
+// matches at least one of the glob patterns, for example `*.yaml` or `**/*.jsonnet`. Patterns
+// use forward slashes and support `**` to match any number of directories. Defaults to all
+// files.
+func WithDirectoryInclude(patterns ...string) DirectoryOption {
+	return func(w *directoryWatcher) {
+		w.include = append(w.include, patterns...)
+	}
+}
+
+// WithDirectoryExclude does not send events for files and does not watch directories whose path
+// relative to the watched directory matches one of the glob patterns, for example `**/.git` or
+// `**/*.swp`. Exclude patterns take precedence over include patterns.
+func WithDirectoryExclude(patterns ...string) DirectoryOption {
+	return func(w *directoryWatcher) {
+		w.exclude = append(w.exclude, patterns...)
+	}
+}
+
+// WatchDirectory watches the directory tree recursively and sends an event per file. Created
+// subdirectories are watched as well, and a ChangeEvent is sent for the files they already
+// contain. A RemoveEvent is sent for every file of a removed or renamed subdirectory.
+func WatchDirectory(ctx context.Context, dir string, c EventChannel, opts ...DirectoryOption) (Watcher, error) {
+	dw := &directoryWatcher{
+		dir:   filepath.Clean(dir),
+		c:     c,
+		dirs:  map[string]bool{},
+		files: map[string]bool{},
+	}
+	for _, f := range opts {
+		f(dw)
+	}
+	for _, pattern := range append(dw.include, dw.exclude...) {
+		// doublestar only parses the parts of a pattern it needs for matching
+		for _, component := range strings.Split(pattern, "/") {
+			if _, err := path.Match(component, ""); err != nil {
+				return nil, errors.Wrapf(err, "invalid glob pattern %q", pattern)
+			}
+		}
+	}
+
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
-	var subDirs []string
-	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			subDirs = append(subDirs, path)
-		}
+	dw.w = w
+
+	d := newDispatcher(dw.dir)
+	dw.status = d.status
+	if err := dw.walk(dw.dir, func(path string) error {
+		dw.files[path] = true
 		return nil
 	}); err != nil {
-		return nil, errors.WithStack(err)
+		_ = w.Close()
+		return nil, err
 	}
-	for _, d := range append(subDirs, dir) {
-		if err := w.Add(d); err != nil {
-			return nil, errors.WithStack(err)
+
+	go dw.streamEvents(ctx, d.trigger, d.done)
+	return d, nil
+}
+
+func (dw *directoryWatcher) matches(patterns []string, path string) bool {
+	rel, err := filepath.Rel(dw.dir, path)
+	if err != nil {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		// the patterns were validated in WatchDirectory
+		if ok, _ := doublestar.Match(pattern, rel); ok {
+			return true
 		}
 	}
+	return false
+}
 
-	d := newDispatcher()
-	go streamDirectoryEvents(ctx, w, c, d.trigger, d.done, dir)
-	return d, nil
+func (dw *directoryWatcher) isIncluded(file string) bool {
+	return !dw.matches(dw.exclude, file) && (len(dw.include) == 0 || dw.matches(dw.include, file))
 }
 
-func handleEvent(e fsnotify.Event, w *fsnotify.Watcher, c EventChannel) {
-	if e.Op&fsnotify.Remove != 0 {
-		// We cannot figure out anymore if it was a file or directory.
-		// If it was a directory it was added to the watchers as well as it's parent.
-		// Therefore we will get two consecutive remove events from inotify (REMOVE and REMOVE_SELF).
-		// Sometimes the second event has an empty name (no specific reason for that).
-		// If there is no second event (timeout 1ms) we assume it was a file that got deleted.
-		// This means that file deletion events are delayed by 1ms.
-		select {
-		case <-time.After(time.Millisecond):
-			c <- &RemoveEvent{
-				source: source(e.Name),
-			}
-			return
-		case secondE := <-w.Events:
-			if (secondE.Name != "" && secondE.Name != e.Name) || secondE.Op&fsnotify.Remove == 0 {
-				// this is NOT the unix.IN_DELETE_SELF event => we have to handle the first explicitly
-				// and the second recursively because it might be the first event of a directory deletion
-				c <- &RemoveEvent{
-					source: source(e.Name),
-				}
-				handleEvent(secondE, w, c)
-			} // else we do not want any event on deletion of a folder
+// walk watches the directory tree at root and calls fn for every included file.
+func (dw *directoryWatcher) walk(root string, fn func(path string) error) error {
+	return filepath.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.WithStack(err)
 		}
-	} else if e.Op&(fsnotify.Write|fsnotify.Create) != 0 {
-		if stats, err := os.Stat(e.Name); err != nil {
-			c <- &ErrorEvent{
-				error:  errors.WithStack(err),
-				source: source(e.Name),
+		if info.IsDir() {
+			if name != dw.dir && dw.matches(dw.exclude, name) {
+				return filepath.SkipDir
 			}
-			return
-		} else if stats.IsDir() {
-			if err := w.Add(e.Name); err != nil {
-				c <- &ErrorEvent{
-					error:  errors.WithStack(err),
-					source: source(e.Name),
+			if !dw.dirs[name] {
+				if err := dw.w.Add(name); err != nil {
+					return errors.WithStack(err)
 				}
+				dw.dirs[name] = true
 			}
+			return nil
+		}
+		if !dw.isIncluded(name) {
+			return nil
+		}
+		return fn(name)
+	})
+}
+
+func (dw *directoryWatcher) sendChange(path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		dw.status.send(dw.c, &ErrorEvent{
+			error:  errors.WithStack(err),
+			source: source(path),
+		})
+		return
+	}
+	dw.files[path] = true
+	dw.status.send(dw.c, &ChangeEvent{
+		data:   data,
+		source: source(path),
+	})
+}
+
+// remove sends a RemoveEvent for the file, or for every file in the directory.
+func (dw *directoryWatcher) remove(path string) {
+	if dw.files[path] {
+		delete(dw.files, path)
+		dw.status.send(dw.c, &RemoveEvent{source(path)})
+		return
+	}
+	if !dw.dirs[path] {
+		return
+	}
+
+	prefix := path + string(filepath.Separator)
+	var removed []string
+	for file := range dw.files {
+		if strings.HasPrefix(file, prefix) {
+			removed = append(removed, file)
+		}
+	}
+	sort.Strings(removed)
+	for _, file := range removed {
+		delete(dw.files, file)
+		dw.status.send(dw.c, &RemoveEvent{source(file)})
+	}
+
+	for dir := range dw.dirs {
+		if dir == path || strings.HasPrefix(dir, prefix) {
+			delete(dw.dirs, dir)
+			// the directory might have been moved, in which case it is still watched
+			_ = dw.w.Remove(dir)
+		}
+	}
+}
+
+func (dw *directoryWatcher) handleEvent(e fsnotify.Event) {
+	if e.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		dw.remove(e.Name)
+		return
+	}
+	if e.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+		return
+	}
+
+	stats, err := os.Stat(e.Name)
+	if os.IsNotExist(err) {
+		// the file was removed again, we will receive the remove event
+		return
+	} else if err != nil {
+		dw.status.send(dw.c, &ErrorEvent{
+			error:  errors.WithStack(err),
+			source: source(e.Name),
+		})
+		return
+	}
+
+	if stats.IsDir() {
+		if dw.dirs[e.Name] || dw.matches(dw.exclude, e.Name) {
 			return
 		}
-		data, err := ioutil.ReadFile(e.Name)
-		if err != nil {
-			c <- &ErrorEvent{
+		// files might have been created before the directory was watched
+		if err := dw.walk(e.Name, func(path string) error {
+			dw.sendChange(path)
+			return nil
+		}); err != nil {
+			dw.status.send(dw.c, &ErrorEvent{
 				error:  err,
 				source: source(e.Name),
-			}
-		} else {
-			c <- &ChangeEvent{
-				data:   data,
-				source: source(e.Name),
-			}
+			})
 		}
+		return
+	}
+
+	if dw.isIncluded(e.Name) {
+		dw.sendChange(e.Name)
 	}
 }
 
-func streamDirectoryEvents(ctx context.Context, w *fsnotify.Watcher, c EventChannel, sendNow <-chan struct{}, sendNowDone chan<- int, dir string) {
+func (dw *directoryWatcher) streamEvents(ctx context.Context, sendNow <-chan struct{}, sendNowDone chan<- int) {
+	defer dw.status.setConnected(false)
 	for {
 		select {
 		case <-ctx.Done():
-			_ = w.Close()
+			_ = dw.w.Close()
 			return
-		case e := <-w.Events:
-			handleEvent(e, w, c)
+		case e := <-dw.w.Events:
+			dw.handleEvent(e)
 		case <-sendNow:
 			var eventsSent int
 
-			if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					return err
-				}
-				if !info.IsDir() {
-					data, err := ioutil.ReadFile(path)
-					if err != nil {
-						c <- &ErrorEvent{
-							error:  err,
-							source: source(path),
-						}
-					} else {
-						c <- &ChangeEvent{
-							data:   data,
-							source: source(path),
-						}
-					}
-					eventsSent++
-				}
+			if err := dw.walk(dw.dir, func(path string) error {
+				dw.sendChange(path)
+				eventsSent++
 				return nil
 			}); err != nil {
-				c <- &ErrorEvent{
+				dw.status.send(dw.c, &ErrorEvent{
 					error:  err,
-					source: source(dir),
-				}
+					source: source(dw.dir),
+				})
 				eventsSent++
 			}
 
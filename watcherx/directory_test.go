@@ -1,6 +1,7 @@
 package watcherx
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -14,6 +15,19 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// receiveUntilQuiet returns the events received until no event was received for 50ms.
+func receiveUntilQuiet(c EventChannel) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-c:
+			events = append(events, e)
+		case <-time.After(50 * time.Millisecond):
+			return events
+		}
+	}
+}
+
 func TestWatchDirectory(t *testing.T) {
 	t.Run("case=notifies about file creation in directory", func(t *testing.T) {
 		ctx, c, dir, cancel := setup(t)
@@ -191,4 +205,100 @@ func TestWatchDirectory(t *testing.T) {
 		assertChange(t, <-c, files["c"], filepath.Join(dir, "c"))
 		assertChange(t, <-c, files[filepath.Join("d", "a")], filepath.Join(dir, "d", "a"))
 	})
+
+	t.Run("case=filters files with glob patterns", func(t *testing.T) {
+		ctx, c, dir, cancel := setup(t)
+		defer cancel()
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "policies", ".git"), 0777))
+		_, err := WatchDirectory(ctx, dir, c,
+			WithDirectoryInclude("**/*.yaml"),
+			WithDirectoryExclude("**/.git", "**/ignored.yaml"),
+		)
+		require.NoError(t, err)
+
+		for _, fn := range []string{
+			filepath.Join("policies", ".git", "config.yaml"),
+			filepath.Join("policies", "ignored.yaml"),
+			filepath.Join("policies", "readme.md"),
+			filepath.Join("policies", "admin.yaml"),
+		} {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, fn), []byte(fn), 0600))
+		}
+
+		// writing a file causes a create and a write event
+		events := receiveUntilQuiet(c)
+		require.NotEmpty(t, events)
+		for _, e := range events {
+			assert.Equal(t, filepath.Join(dir, "policies", "admin.yaml"), e.Source())
+		}
+		assertChange(t, events[len(events)-1], filepath.Join("policies", "admin.yaml"), filepath.Join(dir, "policies", "admin.yaml"))
+	})
+
+	t.Run("case=notifies about files of a moved in directory", func(t *testing.T) {
+		ctx, c, dir, cancel := setup(t)
+		defer cancel()
+
+		watched := filepath.Join(dir, "watched")
+		require.NoError(t, os.Mkdir(watched, 0777))
+		other := filepath.Join(dir, "other")
+		require.NoError(t, os.MkdirAll(filepath.Join(other, "sub"), 0777))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(other, "a"), []byte("a"), 0600))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(other, "sub", "b"), []byte("b"), 0600))
+
+		_, err := WatchDirectory(ctx, watched, c)
+		require.NoError(t, err)
+
+		moved := filepath.Join(watched, "templates")
+		require.NoError(t, os.Rename(other, moved))
+		assertChange(t, <-c, "a", filepath.Join(moved, "a"))
+		assertChange(t, <-c, "b", filepath.Join(moved, "sub", "b"))
+
+		// the moved directory is watched
+		require.NoError(t, ioutil.WriteFile(filepath.Join(moved, "sub", "c"), []byte("c"), 0600))
+		events := receiveUntilQuiet(c)
+		require.NotEmpty(t, events)
+		assertChange(t, events[len(events)-1], "c", filepath.Join(moved, "sub", "c"))
+
+		// moving it away removes all files
+		require.NoError(t, os.Rename(moved, other))
+		var removed []string
+		for i := 0; i < 3; i++ {
+			e := <-c
+			require.IsType(t, &RemoveEvent{}, e)
+			removed = append(removed, e.Source())
+		}
+		assert.ElementsMatch(t, []string{
+			filepath.Join(moved, "a"),
+			filepath.Join(moved, "sub", "b"),
+			filepath.Join(moved, "sub", "c"),
+		}, removed)
+
+		// and it is not watched anymore
+		require.NoError(t, ioutil.WriteFile(filepath.Join(other, "sub", "d"), []byte("d"), 0600))
+		assert.Empty(t, receiveUntilQuiet(c))
+	})
+
+	t.Run("case=sends filtered events when requested", func(t *testing.T) {
+		ctx, _, dir, cancel := setup(t)
+		defer cancel()
+		c := make(EventChannel, 4)
+
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "vendor"), 0700))
+		for _, fn := range []string{"a.json", "b.yaml", filepath.Join("vendor", "c.json")} {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, fn), []byte(fn), 0600))
+		}
+
+		d, err := WatchDirectory(ctx, dir, c, WithDirectoryInclude("**/*.json"), WithDirectoryExclude("vendor"))
+		require.NoError(t, err)
+		done, err := d.DispatchNow()
+		require.NoError(t, err)
+		assert.Equal(t, 1, <-done)
+		assertChange(t, <-c, "a.json", filepath.Join(dir, "a.json"))
+	})
+
+	t.Run("case=rejects invalid glob patterns", func(t *testing.T) {
+		_, err := WatchDirectory(context.Background(), t.TempDir(), make(EventChannel), WithDirectoryInclude("**/[a-"))
+		assert.Error(t, err)
+	})
 }
@@ -39,15 +39,16 @@ func WatchFile(ctx context.Context, file string, c EventChannel) (Watcher, error
 			return nil, errors.WithStack(err)
 		}
 	}
-	d := newDispatcher()
-	go streamFileEvents(ctx, watcher, c, d.trigger, d.done, file, resolvedFile)
+	d := newDispatcher(file)
+	go streamFileEvents(ctx, watcher, c, d.trigger, d.done, d.status, file, resolvedFile)
 	return d, nil
 }
 
 // streamFileEvents watches for file changes and supports symlinks which requires several workarounds due to limitations of fsnotify.
 // Argument `resolvedFile` is the resolved symlink path of the file, or it is the watchedFile name itself. If `resolvedFile` is empty, then the watchedFile does not exist.
-func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventChannel, sendNow <-chan struct{}, sendNowDone chan<- int, watchedFile, resolvedFile string) {
+func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventChannel, sendNow <-chan struct{}, sendNowDone chan<- int, status *statusTracker, watchedFile, resolvedFile string) {
 	defer close(c)
+	defer status.setConnected(false)
 	eventSource := source(watchedFile)
 	removeDirectFileWatcher := func() {
 		_ = watcher.Remove(watchedFile)
@@ -57,10 +58,10 @@ func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventCha
 		// if it does not the dir watcher will notify us when it gets created
 		if _, err := os.Lstat(watchedFile); err == nil {
 			if err := watcher.Add(watchedFile); err != nil {
-				c <- &ErrorEvent{
+				status.send(c, &ErrorEvent{
 					error:  errors.WithStack(err),
 					source: eventSource,
-				}
+				})
 			}
 		}
 	}
@@ -72,21 +73,21 @@ func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventCha
 		case <-sendNow:
 			if resolvedFile == "" {
 				// The file does not exist. Announce this by sending a RemoveEvent.
-				c <- &RemoveEvent{eventSource}
+				status.send(c, &RemoveEvent{eventSource})
 			} else {
 				// The file does exist. Announce the current content by sending a ChangeEvent.
 				data, err := ioutil.ReadFile(watchedFile)
 				if err != nil {
-					c <- &ErrorEvent{
+					status.send(c, &ErrorEvent{
 						error:  errors.WithStack(err),
 						source: eventSource,
-					}
+					})
 					continue
 				}
-				c <- &ChangeEvent{
+				status.send(c, &ChangeEvent{
 					data:   data,
 					source: eventSource,
-				}
+				})
 			}
 
 			// in any of the above cases we send exactly one event
@@ -103,14 +104,14 @@ func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventCha
 				if err != nil {
 					// check if the watchedFile (or the file behind the symlink) was removed
 					if _, ok := err.(*os.PathError); ok {
-						c <- &RemoveEvent{eventSource}
+						status.send(c, &RemoveEvent{eventSource})
 						removeDirectFileWatcher()
 						continue
 					}
-					c <- &ErrorEvent{
+					status.send(c, &ErrorEvent{
 						error:  errors.WithStack(err),
 						source: eventSource,
-					}
+					})
 					continue
 				}
 				// This catches following three cases:
@@ -128,16 +129,16 @@ func streamFileEvents(ctx context.Context, watcher *fsnotify.Watcher, c EventCha
 				case e.Op&(fsnotify.Write|fsnotify.Create) != 0:
 					data, err := ioutil.ReadFile(watchedFile)
 					if err != nil {
-						c <- &ErrorEvent{
+						status.send(c, &ErrorEvent{
 							error:  errors.WithStack(err),
 							source: eventSource,
-						}
+						})
 						continue
 					}
-					c <- &ChangeEvent{
+					status.send(c, &ChangeEvent{
 						data:   data,
 						source: eventSource,
-					}
+					})
 				}
 			}
 		}
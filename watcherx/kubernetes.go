@@ -0,0 +1,485 @@
+package watcherx
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+	kubernetesResourceConfigMaps = "configmaps"
+	kubernetesResourceSecrets    = "secrets"
+)
+
+var errKubernetesResourceVersionGone = errors.New("the resource version is too old")
+
+type (
+	// KubernetesOption configures WatchKubernetes.
+	KubernetesOption func(w *kubernetesWatcher)
+
+	kubernetesWatcher struct {
+		server       *url.URL
+		client       *http.Client
+		token        func() (string, error)
+		minBackoff   time.Duration
+		maxBackoff   time.Duration
+		watchTimeout time.Duration
+
+		u         url.URL
+		namespace string
+		resource  string
+		name      string
+		key       string
+
+		c      EventChannel
+		status *statusTracker
+		cache  map[string][]byte
+	}
+	kubernetesMetadata struct {
+		Name            string `json:"name"`
+		ResourceVersion string `json:"resourceVersion"`
+	}
+	kubernetesObject struct {
+		Metadata   kubernetesMetadata `json:"metadata"`
+		Data       map[string]string  `json:"data"`
+		BinaryData map[string][]byte  `json:"binaryData"`
+
+		// Code and Message are set if the object is a Status.
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	kubernetesList struct {
+		Metadata kubernetesMetadata `json:"metadata"`
+		Items    []kubernetesObject `json:"items"`
+	}
+	kubernetesWatchEvent struct {
+		Type   string           `json:"type"`
+		Object kubernetesObject `json:"object"`
+	}
+	// kubernetesUpdate is the state of the object after a change, or an error.
+	kubernetesUpdate struct {
+		values map[string][]byte
+		err    error
+	}
+)
+
+// WithKubernetesAPIServer sets the URL of the Kubernetes API server. Defaults to the in-cluster
+// API server from the environment variables KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT.
+func WithKubernetesAPIServer(server *url.URL) KubernetesOption {
+	return func(w *kubernetesWatcher) {
+		w.server = server
+	}
+}
+
+// WithKubernetesHTTPClient sets the HTTP client used to talk to the Kubernetes API server.
+// Defaults to a client which trusts the in-cluster service account CA.
+func WithKubernetesHTTPClient(client *http.Client) KubernetesOption {
+	return func(w *kubernetesWatcher) {
+		w.client = client
+	}
+}
+
+// WithKubernetesToken sets the bearer token. Defaults to the in-cluster service account token,
+// which is read again for every request because it is rotated. No token is sent if the service
+// account token does not exist, for example when using `kubectl proxy`.
+func WithKubernetesToken(token string) KubernetesOption {
+	return func(w *kubernetesWatcher) {
+		w.token = func() (string, error) {
+			return token, nil
+		}
+	}
+}
+
+// WithKubernetesReconnectBackoff sets the minimum and maximum time to wait before reconnecting
+// after an error. The time doubles with every failed attempt. Defaults to one second and one
+// minute.
+func WithKubernetesReconnectBackoff(min, max time.Duration) KubernetesOption {
+	return func(w *kubernetesWatcher) {
+		w.minBackoff, w.maxBackoff = min, max
+	}
+}
+
+// WithKubernetesWatchTimeout sets after how long the Kubernetes API server ends a watch, after
+// which the watcher watches again. A watch which ends earlier without any event is treated as an
+// error. Defaults to five minutes.
+func WithKubernetesWatchTimeout(timeout time.Duration) KubernetesOption {
+	return func(w *kubernetesWatcher) {
+		w.watchTimeout = timeout
+	}
+}
+
+// WatchKubernetes watches a ConfigMap or Secret using the Kubernetes API, so that it can be
+// watched without mounting it as a volume. The URL has the format
+//
+//	kubernetes://<namespace>/<configmaps|secrets>/<name>[/<key>]
+//
+// If the namespace is empty, the namespace of the in-cluster service account is used. Every key
+// is a separate source, for example `kubernetes://default/configmaps/kratos/kratos.yml`. If the
+// key is omitted, events are sent for all keys.
+//
+// The watcher caches the object and only sends events for keys which changed. A ChangeEvent is
+// sent for created and changed keys, and a RemoveEvent for removed keys and all keys of a
+// removed object. Connection errors are sent as ErrorEvent and the watcher reconnects.
+func WatchKubernetes(ctx context.Context, u *url.URL, c EventChannel, opts ...KubernetesOption) (Watcher, error) {
+	w := &kubernetesWatcher{
+		minBackoff:   time.Second,
+		maxBackoff:   time.Minute,
+		watchTimeout: 5 * time.Minute,
+		u:            *u,
+		c:            c,
+		cache:        map[string][]byte{},
+	}
+	for _, f := range opts {
+		f(w)
+	}
+
+	if err := w.parseURL(); err != nil {
+		return nil, err
+	}
+	if err := w.setDefaults(); err != nil {
+		return nil, err
+	}
+
+	d := newDispatcher(w.source(w.key).Source())
+	w.status = d.status
+	// the watcher is connected once the object was listed
+	w.status.setConnected(false)
+	updates := make(chan kubernetesUpdate)
+	go w.sync(ctx, updates)
+	go w.streamEvents(ctx, updates, d.trigger, d.done)
+	return d, nil
+}
+
+func (w *kubernetesWatcher) parseURL() error {
+	parts := strings.Split(strings.Trim(w.u.Path, "/"), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+		return errors.Errorf("expected a URL like kubernetes://<namespace>/<configmaps|secrets>/<name>[/<key>] but got: %s", w.u.String())
+	}
+
+	w.resource, w.name = parts[0], parts[1]
+	if len(parts) == 3 {
+		w.key = parts[2]
+	}
+	if w.resource != kubernetesResourceConfigMaps && w.resource != kubernetesResourceSecrets {
+		return errors.Errorf("expected the resource to be %q or %q but got: %s", kubernetesResourceConfigMaps, kubernetesResourceSecrets, w.resource)
+	}
+
+	w.namespace = w.u.Host
+	if w.namespace == "" {
+		namespace, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "namespace"))
+		if err != nil {
+			return errors.Wrap(err, "unable to determine the namespace of the service account")
+		}
+		w.namespace = strings.TrimSpace(string(namespace))
+		w.u.Host = w.namespace
+	}
+	return nil
+}
+
+func (w *kubernetesWatcher) setDefaults() error {
+	if w.server == nil {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return errors.New("unable to determine the Kubernetes API server because KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+		}
+		w.server = &url.URL{Scheme: "https", Host: net.JoinHostPort(host, port)}
+	}
+
+	if w.client == nil {
+		w.client = http.DefaultClient
+		if ca, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "ca.crt")); err == nil {
+			pool := x509.NewCertPool()
+			pool.AppendCertsFromPEM(ca)
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+			w.client = &http.Client{Transport: transport}
+		}
+	}
+
+	if w.token == nil {
+		w.token = func() (string, error) {
+			token, err := ioutil.ReadFile(path.Join(kubernetesServiceAccountDir, "token"))
+			if errors.Is(err, os.ErrNotExist) {
+				return "", nil
+			} else if err != nil {
+				return "", errors.WithStack(err)
+			}
+			return strings.TrimSpace(string(token)), nil
+		}
+	}
+	return nil
+}
+
+func (w *kubernetesWatcher) source(key string) source {
+	u := w.u
+	u.Path = "/" + path.Join(w.resource, w.name, key)
+	return source(u.String())
+}
+
+// streamEvents compares every update with the cache and sends the events.
+func (w *kubernetesWatcher) streamEvents(ctx context.Context, updates <-chan kubernetesUpdate, sendNow <-chan struct{}, sendNowDone chan<- int) {
+	defer close(w.c)
+	defer w.status.setConnected(false)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case u := <-updates:
+			if u.err != nil {
+				w.status.send(w.c, &ErrorEvent{
+					error:  u.err,
+					source: w.source(w.key),
+				})
+				continue
+			}
+
+			for _, key := range sortedKeys(w.cache) {
+				if _, ok := u.values[key]; !ok {
+					w.status.send(w.c, &RemoveEvent{w.source(key)})
+				}
+			}
+			for _, key := range sortedKeys(u.values) {
+				if old, ok := w.cache[key]; !ok || !bytes.Equal(old, u.values[key]) {
+					w.status.send(w.c, &ChangeEvent{
+						data:   u.values[key],
+						source: w.source(key),
+					})
+				}
+			}
+			w.cache = u.values
+		case <-sendNow:
+			if w.key != "" && len(w.cache) == 0 {
+				w.status.send(w.c, &RemoveEvent{w.source(w.key)})
+				sendNowDone <- 1
+				continue
+			}
+
+			keys := sortedKeys(w.cache)
+			for _, key := range keys {
+				w.status.send(w.c, &ChangeEvent{
+					data:   w.cache[key],
+					source: w.source(key),
+				})
+			}
+			sendNowDone <- len(keys)
+		}
+	}
+}
+
+func sortedKeys(values map[string][]byte) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sync lists the object and watches it for changes. It watches again from the last resource
+// version if the watch ends, lists again if the resource version is too old, and reconnects with
+// an exponential backoff on errors. The backoff is reset only once a watch received an event, so
+// that a watch which fails right away does not reconnect in a tight loop.
+func (w *kubernetesWatcher) sync(ctx context.Context, updates chan<- kubernetesUpdate) {
+	backoff := w.minBackoff
+	for {
+		resourceVersion, err := w.list(ctx, updates)
+		if err == nil {
+			w.status.setConnected(true)
+		}
+		for err == nil {
+			var events int
+			resourceVersion, events, err = w.watch(ctx, resourceVersion, updates)
+			if events > 0 {
+				backoff = w.minBackoff
+			}
+		}
+
+		if ctx.Err() != nil {
+			return
+		} else if errors.Is(err, errKubernetesResourceVersionGone) {
+			continue
+		}
+		w.status.setConnected(false)
+
+		select {
+		case updates <- kubernetesUpdate{err: err}:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return
+		}
+		if backoff *= 2; backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+		w.status.reconnect()
+	}
+}
+
+func (w *kubernetesWatcher) request(ctx context.Context, query url.Values) (*http.Response, error) {
+	u := *w.server
+	u.Path = path.Join(u.Path, "api", "v1", "namespaces", w.namespace, w.resource)
+	query.Set("fieldSelector", "metadata.name="+w.name)
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	token, err := w.token()
+	if err != nil {
+		return nil, err
+	} else if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		body, _ := ioutil.ReadAll(res.Body)
+		if res.StatusCode == http.StatusGone {
+			return nil, errors.WithStack(errKubernetesResourceVersionGone)
+		}
+		return nil, errors.Errorf("expected status code %d from the Kubernetes API server but got %d: %s", http.StatusOK, res.StatusCode, body)
+	}
+	return res, nil
+}
+
+func (w *kubernetesWatcher) list(ctx context.Context, updates chan<- kubernetesUpdate) (string, error) {
+	res, err := w.request(ctx, url.Values{})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var list kubernetesList
+	if err := json.NewDecoder(res.Body).Decode(&list); err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	values := map[string][]byte{}
+	for _, item := range list.Items {
+		if item.Metadata.Name == w.name {
+			if values, err = w.values(&item); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return list.Metadata.ResourceVersion, w.send(ctx, updates, values)
+}
+
+// watch watches the object from the resource version until the watch ends, and returns the last
+// resource version and the number of received events.
+func (w *kubernetesWatcher) watch(ctx context.Context, resourceVersion string, updates chan<- kubernetesUpdate) (_ string, events int, _ error) {
+	start := time.Now()
+	res, err := w.request(ctx, url.Values{
+		"watch":               {"true"},
+		"resourceVersion":     {resourceVersion},
+		"allowWatchBookmarks": {"true"},
+		"timeoutSeconds":      {strconv.Itoa(int(w.watchTimeout.Seconds()))},
+	})
+	if err != nil {
+		return resourceVersion, events, err
+	}
+	defer res.Body.Close()
+
+	dec := json.NewDecoder(res.Body)
+	for {
+		var e kubernetesWatchEvent
+		if err := dec.Decode(&e); err != nil {
+			if ctx.Err() != nil {
+				return resourceVersion, events, ctx.Err()
+			}
+			if errors.Is(err, io.EOF) && (events > 0 || time.Since(start) >= w.watchTimeout) {
+				// The API server ends watches after the timeout, in which case we just watch again.
+				return resourceVersion, events, nil
+			}
+			return resourceVersion, events, errors.Wrapf(err, "the watch of the Kubernetes API server ended unexpectedly after %d events", events)
+		}
+		events++
+
+		switch e.Type {
+		case "ERROR":
+			if e.Object.Code == http.StatusGone {
+				return resourceVersion, events, errors.WithStack(errKubernetesResourceVersionGone)
+			}
+			return resourceVersion, events, errors.Errorf("the Kubernetes API server returned an error while watching: %s", e.Object.Message)
+		case "ADDED", "MODIFIED":
+			values, err := w.values(&e.Object)
+			if err != nil {
+				return resourceVersion, events, err
+			}
+			if err := w.send(ctx, updates, values); err != nil {
+				return resourceVersion, events, err
+			}
+		case "DELETED":
+			if err := w.send(ctx, updates, map[string][]byte{}); err != nil {
+				return resourceVersion, events, err
+			}
+		}
+		resourceVersion = e.Object.Metadata.ResourceVersion
+	}
+}
+
+func (w *kubernetesWatcher) send(ctx context.Context, updates chan<- kubernetesUpdate, values map[string][]byte) error {
+	select {
+	case updates <- kubernetesUpdate{values: values}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// values returns the values of the watched keys. Values of Secrets are base64 encoded.
+func (w *kubernetesWatcher) values(o *kubernetesObject) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(o.Data)+len(o.BinaryData))
+	for k, v := range o.Data {
+		if w.resource == kubernetesResourceSecrets {
+			decoded, err := base64.StdEncoding.DecodeString(v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "unable to decode key %q of secret %q", k, w.name)
+			}
+			values[k] = decoded
+			continue
+		}
+		values[k] = []byte(v)
+	}
+	for k, v := range o.BinaryData {
+		values[k] = v
+	}
+
+	if w.key != "" {
+		if v, ok := values[w.key]; ok {
+			return map[string][]byte{w.key: v}, nil
+		}
+		return map[string][]byte{}, nil
+	}
+	return values, nil
+}
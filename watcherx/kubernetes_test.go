@@ -0,0 +1,261 @@
+package watcherx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKubernetesAPI lists the current object and streams the watch events sent to the events
+// channel. Sending an empty string ends the watch.
+type fakeKubernetesAPI struct {
+	sync.Mutex
+	object     map[string]interface{}
+	listStatus int
+	requests   []url.Values
+	events     chan string
+	// watchBody, if set, is the response to all watches.
+	watchBody string
+}
+
+func newFakeKubernetesAPI(t *testing.T, object map[string]interface{}) (*fakeKubernetesAPI, *url.URL) {
+	api := &fakeKubernetesAPI{object: object, events: make(chan string)}
+	ts := httptest.NewServer(api)
+	t.Cleanup(ts.Close)
+	u, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	return api, u
+}
+
+func (f *fakeKubernetesAPI) setObject(object map[string]interface{}) {
+	f.Lock()
+	defer f.Unlock()
+	f.object = object
+}
+
+func (f *fakeKubernetesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	f.requests = append(f.requests, r.URL.Query())
+	object, status, watchBody := f.object, f.listStatus, f.watchBody
+	f.listStatus = 0
+	f.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer secret-token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if r.URL.Query().Get("watch") != "true" {
+		if status != 0 {
+			w.WriteHeader(status)
+			return
+		}
+		items := []interface{}{}
+		if object != nil {
+			items = append(items, object)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"metadata": map[string]string{"resourceVersion": "1"},
+			"items":    items,
+		})
+		return
+	}
+
+	if watchBody != "" {
+		_, _ = fmt.Fprint(w, watchBody)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e := <-f.events:
+			if e == "" {
+				return
+			}
+			_, _ = fmt.Fprintln(w, e)
+			w.(http.Flusher).Flush()
+		}
+	}
+}
+
+func configMap(resourceVersion string, data map[string]string) map[string]interface{} {
+	return map[string]interface{}{
+		"metadata": map[string]string{"name": "app", "resourceVersion": resourceVersion},
+		"data":     data,
+	}
+}
+
+func watchEvent(t *testing.T, eventType string, object map[string]interface{}) string {
+	e, err := json.Marshal(map[string]interface{}{"type": eventType, "object": object})
+	require.NoError(t, err)
+	return string(e)
+}
+
+func receive(t *testing.T, c EventChannel) Event {
+	select {
+	case e := <-c:
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return nil
+	}
+}
+
+func TestWatchKubernetes(t *testing.T) {
+	t.Run("case=sends events for changed keys of a config map", func(t *testing.T) {
+		api, server := newFakeKubernetesAPI(t, configMap("1", map[string]string{"a": "1", "b": "2"}))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := make(EventChannel)
+		u := &url.URL{Scheme: "kubernetes", Host: "default", Path: "/configmaps/app"}
+		d, err := WatchKubernetes(ctx, u, c,
+			WithKubernetesAPIServer(server),
+			WithKubernetesToken("secret-token"),
+			WithKubernetesReconnectBackoff(time.Millisecond, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		assertChange(t, receive(t, c), "1", "kubernetes://default/configmaps/app/a")
+		assertChange(t, receive(t, c), "2", "kubernetes://default/configmaps/app/b")
+
+		api.events <- watchEvent(t, "MODIFIED", configMap("2", map[string]string{"a": "1", "b": "3", "c": "4"}))
+		assertChange(t, receive(t, c), "3", "kubernetes://default/configmaps/app/b")
+		assertChange(t, receive(t, c), "4", "kubernetes://default/configmaps/app/c")
+
+		api.events <- watchEvent(t, "MODIFIED", configMap("3", map[string]string{"b": "3", "c": "4"}))
+		assertRemove(t, receive(t, c), "kubernetes://default/configmaps/app/a")
+
+		// the watch ends and is resumed from the last resource version
+		api.events <- ""
+		api.events <- watchEvent(t, "BOOKMARK", configMap("4", nil))
+		api.events <- watchEvent(t, "DELETED", configMap("5", nil))
+		assertRemove(t, receive(t, c), "kubernetes://default/configmaps/app/b")
+		assertRemove(t, receive(t, c), "kubernetes://default/configmaps/app/c")
+
+		// the resource version is too old so that the object is listed again
+		api.setObject(configMap("6", map[string]string{"a": "5"}))
+		api.events <- `{"type": "ERROR", "object": {"kind": "Status", "code": 410, "message": "too old resource version"}}`
+		assertChange(t, receive(t, c), "5", "kubernetes://default/configmaps/app/a")
+
+		done, err := d.DispatchNow()
+		require.NoError(t, err)
+		assertChange(t, receive(t, c), "5", "kubernetes://default/configmaps/app/a")
+		assert.Equal(t, 1, <-done)
+
+		// the object is watched again from the listed resource version
+		assert.Eventually(t, func() bool {
+			api.Lock()
+			defer api.Unlock()
+			var watches []string
+			for _, q := range api.requests {
+				if q.Get("fieldSelector") != "metadata.name=app" {
+					return false
+				}
+				if q.Get("watch") == "true" {
+					watches = append(watches, q.Get("resourceVersion"))
+				}
+			}
+			return fmt.Sprintf("%v", watches) == "[1 3 1]"
+		}, 5*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("case=watches a key of a secret and reconnects after errors", func(t *testing.T) {
+		api, server := newFakeKubernetesAPI(t, nil)
+		api.listStatus = http.StatusInternalServerError
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := make(EventChannel)
+		u := &url.URL{Scheme: "kubernetes", Host: "default", Path: "/secrets/app/password"}
+		d, err := WatchKubernetes(ctx, u, c,
+			WithKubernetesAPIServer(server),
+			WithKubernetesToken("secret-token"),
+			WithKubernetesReconnectBackoff(time.Millisecond, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		e := receive(t, c)
+		require.IsType(t, &ErrorEvent{}, e)
+		assert.Contains(t, e.(*ErrorEvent).Error(), "got 500")
+		assert.Equal(t, "kubernetes://default/secrets/app/password", e.Source())
+
+		done, err := d.DispatchNow()
+		require.NoError(t, err)
+		assertRemove(t, receive(t, c), "kubernetes://default/secrets/app/password")
+		assert.Equal(t, 1, <-done)
+
+		api.events <- watchEvent(t, "ADDED", map[string]interface{}{
+			"metadata": map[string]string{"name": "app", "resourceVersion": "2"},
+			"data":     map[string]string{"password": "c2VjcmV0", "username": "Zm9v"},
+		})
+		assertChange(t, receive(t, c), "secret", "kubernetes://default/secrets/app/password")
+
+		cancel()
+		_, ok := <-c
+		assert.False(t, ok, "the channel is closed when the context is canceled")
+	})
+
+	t.Run("case=backs off if watches end without events", func(t *testing.T) {
+		for _, body := range []string{"\n", "<html>proxy error</html>"} {
+			t.Run("body="+body, func(t *testing.T) {
+				api, server := newFakeKubernetesAPI(t, configMap("1", map[string]string{"a": "1"}))
+				api.watchBody = body
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				c := make(EventChannel)
+				u := &url.URL{Scheme: "kubernetes", Host: "default", Path: "/configmaps/app"}
+				d, err := WatchKubernetes(ctx, u, c,
+					WithKubernetesAPIServer(server),
+					WithKubernetesToken("secret-token"),
+					WithKubernetesReconnectBackoff(50*time.Millisecond, time.Hour),
+				)
+				require.NoError(t, err)
+
+				assertChange(t, receive(t, c), "1", "kubernetes://default/configmaps/app/a")
+				e := receive(t, c)
+				require.IsType(t, &ErrorEvent{}, e)
+				assert.Contains(t, e.(*ErrorEvent).Error(), "ended unexpectedly after 0 events")
+
+				// the backoff doubles: 50ms, 100ms, 200ms, 400ms, ...
+				go func() {
+					for range c {
+					}
+				}()
+				time.Sleep(time.Second)
+				api.Lock()
+				requests := len(api.requests)
+				api.Unlock()
+				assert.LessOrEqual(t, requests, 12, "the watcher must not reconnect in a tight loop")
+				assert.GreaterOrEqual(t, d.(StatusReporter).Status().Reconnects, 2)
+			})
+		}
+	})
+
+	t.Run("case=rejects invalid URLs", func(t *testing.T) {
+		for _, u := range []string{
+			"kubernetes://default/configmaps",
+			"kubernetes://default/pods/app",
+			"kubernetes://default/configmaps/app/key/more",
+		} {
+			parsed, err := url.Parse(u)
+			require.NoError(t, err)
+			_, err = WatchKubernetes(context.Background(), parsed, make(EventChannel), WithKubernetesAPIServer(&url.URL{}))
+			assert.Error(t, err, u)
+		}
+	})
+}
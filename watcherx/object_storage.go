@@ -0,0 +1,250 @@
+package watcherx
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/objectstoragex"
+)
+
+// DefaultObjectStorageMaxSize is the default size limit of watched objects.
+const DefaultObjectStorageMaxSize = 16 << 20
+
+type (
+	// ObjectStorageOption configures WatchObjectStorage.
+	ObjectStorageOption func(w *objectStorageWatcher)
+
+	objectStorageWatcher struct {
+		interval time.Duration
+		client   *http.Client
+		endpoint *url.URL
+		resolver *objectstoragex.Resolver
+		maxSize  int64
+
+		u      *url.URL
+		c      EventChannel
+		status *statusTracker
+
+		etag   string
+		data   []byte
+		exists bool
+	}
+)
+
+// WithObjectStoragePollInterval sets how often the object is polled. Defaults to 30 seconds.
+func WithObjectStoragePollInterval(interval time.Duration) ObjectStorageOption {
+	return func(w *objectStorageWatcher) {
+		w.interval = interval
+	}
+}
+
+// WithObjectStorageHTTPClient sets the HTTP client. Defaults to http.DefaultClient.
+func WithObjectStorageHTTPClient(client *http.Client) ObjectStorageOption {
+	return func(w *objectStorageWatcher) {
+		w.client = client
+	}
+}
+
+// WithObjectStorageEndpoint sets the endpoint of S3 compatible storage or a storage emulator.
+// Objects are requested from `<endpoint>/<bucket>/<key>`. It is ignored if
+// WithObjectStorageResolver is used.
+func WithObjectStorageEndpoint(endpoint *url.URL) ObjectStorageOption {
+	return func(w *objectStorageWatcher) {
+		w.endpoint = endpoint
+	}
+}
+
+// WithObjectStorageResolver sets the resolver which authorizes the requests of s3, gs, and azblob
+// objects, for example the one shared with fetcher.WithObjectStorageResolver. The resolver must
+// allow the bucket of the watched object. Defaults to a resolver which allows only the bucket of
+// the watched object and uses the ambient credentials of the environment.
+func WithObjectStorageResolver(r *objectstoragex.Resolver) ObjectStorageOption {
+	return func(w *objectStorageWatcher) {
+		w.resolver = r
+	}
+}
+
+// WithObjectStorageMaxSize sets the size limit of the object. Larger objects are reported as
+// ErrorEvent. Defaults to DefaultObjectStorageMaxSize.
+func WithObjectStorageMaxSize(maxSize int64) ObjectStorageOption {
+	return func(w *objectStorageWatcher) {
+		w.maxSize = maxSize
+	}
+}
+
+// WatchObjectStorage polls an object and sends a ChangeEvent if its ETag changed, or a
+// RemoveEvent if it was removed. Supported are URLs like
+//
+//	s3://<bucket>/<key>
+//	gs://<bucket>/<key>
+//	azblob://<container>/<blob>
+//	https://example.com/config.yaml
+//
+// Object storage URLs are resolved and authorized with the ambient credentials of the
+// environment like in objectstoragex. If the server does not send an ETag, the checksum of the
+// content is compared instead.
+//
+// The object is requested once before WatchObjectStorage returns, so that errors like missing
+// permissions are returned right away. A missing object is not an error.
+func WatchObjectStorage(ctx context.Context, u *url.URL, c EventChannel, opts ...ObjectStorageOption) (Watcher, error) {
+	w := &objectStorageWatcher{
+		interval: 30 * time.Second,
+		client:   http.DefaultClient,
+		maxSize:  DefaultObjectStorageMaxSize,
+		u:        u,
+		c:        c,
+	}
+	for _, f := range opts {
+		f(w)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+	default:
+		if !objectstoragex.IsObjectStorageURL(u.String()) {
+			return nil, &errSchemeUnknown{u.Scheme}
+		}
+		if w.resolver == nil {
+			w.resolver = w.defaultResolver()
+		}
+	}
+
+	if _, err := w.poll(ctx); err != nil {
+		return nil, err
+	}
+
+	d := newDispatcher(u.String())
+	w.status = d.status
+	go w.streamEvents(ctx, d.trigger, d.done)
+	return d, nil
+}
+
+// defaultResolver returns a resolver which allows only the bucket of the watched object.
+func (w *objectStorageWatcher) defaultResolver() *objectstoragex.Resolver {
+	opts := []objectstoragex.Option{
+		objectstoragex.WithBuckets(w.u.Scheme, w.u.Host),
+		objectstoragex.WithTokenClient(w.client),
+	}
+	if w.endpoint != nil {
+		opts = append(opts, objectstoragex.WithEndpoint(w.u.Scheme, w.endpoint))
+	}
+	return objectstoragex.NewResolver(opts...)
+}
+
+// newRequest returns the request of the object. Object storage requests are signed again for
+// every poll, because signatures expire.
+func (w *objectStorageWatcher) newRequest(ctx context.Context) (*http.Request, error) {
+	if w.resolver == nil {
+		req, err := http.NewRequestWithContext(ctx, "GET", w.u.String(), nil)
+		return req, errors.WithStack(err)
+	}
+	return w.resolver.NewRequest(ctx, w.u.String())
+}
+
+// poll requests the object and returns the event if it changed, or nil.
+func (w *objectStorageWatcher) poll(ctx context.Context) (Event, error) {
+	req, err := w.newRequest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if w.exists && w.etag != "" {
+		req.Header.Set("If-None-Match", w.etag)
+	}
+
+	res, err := w.client.Do(req)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return nil, nil
+	case http.StatusNotFound:
+		if !w.exists {
+			return nil, nil
+		}
+		w.exists, w.etag, w.data = false, "", nil
+		return &RemoveEvent{source(w.u.String())}, nil
+	case http.StatusOK:
+	default:
+		return nil, errors.Errorf("expected status code %d when requesting %s but got %d", http.StatusOK, w.u.String(), res.StatusCode)
+	}
+
+	if res.ContentLength > w.maxSize {
+		return nil, errors.Errorf("the object %s exceeds the limit of %d bytes", w.u.String(), w.maxSize)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(res.Body, w.maxSize+1))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if int64(len(data)) > w.maxSize {
+		return nil, errors.Errorf("the object %s exceeds the limit of %d bytes", w.u.String(), w.maxSize)
+	}
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		etag = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	}
+	if w.exists && etag == w.etag {
+		return nil, nil
+	}
+
+	w.exists, w.etag, w.data = true, etag, data
+	return &ChangeEvent{
+		data:   data,
+		source: source(w.u.String()),
+	}, nil
+}
+
+func (w *objectStorageWatcher) streamEvents(ctx context.Context, sendNow <-chan struct{}, sendNowDone chan<- int) {
+	defer close(w.c)
+	defer w.status.setConnected(false)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !w.status.get().Connected {
+				w.status.reconnect()
+			}
+
+			e, err := w.poll(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				w.status.setConnected(false)
+				e = &ErrorEvent{
+					error:  err,
+					source: source(w.u.String()),
+				}
+			} else {
+				w.status.setConnected(true)
+			}
+			if e != nil {
+				w.status.send(w.c, e)
+			}
+		case <-sendNow:
+			if w.exists {
+				w.status.send(w.c, &ChangeEvent{
+					data:   w.data,
+					source: source(w.u.String()),
+				})
+			} else {
+				w.status.send(w.c, &RemoveEvent{source(w.u.String())})
+			}
+			sendNowDone <- 1
+		}
+	}
+}
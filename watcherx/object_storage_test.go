@@ -0,0 +1,209 @@
+package watcherx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ory/x/objectstoragex"
+)
+
+type fakeObjectStorage struct {
+	sync.Mutex
+	objects map[string]string
+	status  int
+	etags   bool
+}
+
+func (f *fakeObjectStorage) set(key, value string) {
+	f.Lock()
+	defer f.Unlock()
+	f.objects[key] = value
+}
+
+func (f *fakeObjectStorage) remove(key string) {
+	f.Lock()
+	defer f.Unlock()
+	delete(f.objects, key)
+}
+
+func (f *fakeObjectStorage) setStatus(status int) {
+	f.Lock()
+	defer f.Unlock()
+	f.status = status
+}
+
+func (f *fakeObjectStorage) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+	object, ok := f.objects[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if f.etags {
+		etag := fmt.Sprintf(`"%x"`, object)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+	}
+	_, _ = w.Write([]byte(object))
+}
+
+func TestWatchObjectStorage(t *testing.T) {
+	for _, etags := range []bool{true, false} {
+		t.Run(fmt.Sprintf("etags=%v", etags), func(t *testing.T) {
+			storage := &fakeObjectStorage{objects: map[string]string{"/bucket/config.yaml": "a"}, etags: etags}
+			ts := httptest.NewServer(storage)
+			t.Cleanup(ts.Close)
+			endpoint, err := url.Parse(ts.URL)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			c := make(EventChannel)
+			u := &url.URL{Scheme: "s3", Host: "bucket", Path: "/config.yaml"}
+			d, err := WatchObjectStorage(ctx, u, c,
+				WithObjectStorageEndpoint(endpoint),
+				WithObjectStoragePollInterval(10*time.Millisecond),
+			)
+			require.NoError(t, err)
+
+			done, err := d.DispatchNow()
+			require.NoError(t, err)
+			assertChange(t, receive(t, c), "a", "s3://bucket/config.yaml")
+			assert.Equal(t, 1, <-done)
+
+			storage.set("/bucket/config.yaml", "b")
+			assertChange(t, receive(t, c), "b", "s3://bucket/config.yaml")
+
+			storage.remove("/bucket/config.yaml")
+			assertRemove(t, receive(t, c), "s3://bucket/config.yaml")
+
+			storage.set("/bucket/config.yaml", "b")
+			assertChange(t, receive(t, c), "b", "s3://bucket/config.yaml")
+
+			storage.setStatus(http.StatusForbidden)
+			e := receive(t, c)
+			require.IsType(t, &ErrorEvent{}, e)
+			assert.Contains(t, e.(*ErrorEvent).Error(), "got 403")
+
+			// the object did not change while the requests failed
+			storage.setStatus(0)
+			select {
+			case e := <-c:
+				if _, ok := e.(*ErrorEvent); !ok {
+					t.Fatalf("got unexpected event %T: %+v", e, e)
+				}
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			cancel()
+			for range c {
+			}
+		})
+	}
+
+	t.Run("case=returns initial errors", func(t *testing.T) {
+		storage := &fakeObjectStorage{status: http.StatusForbidden}
+		ts := httptest.NewServer(storage)
+		t.Cleanup(ts.Close)
+
+		u, err := url.Parse(ts.URL + "/config.yaml")
+		require.NoError(t, err)
+		_, err = WatchObjectStorage(context.Background(), u, make(EventChannel))
+		assert.Error(t, err)
+	})
+
+	t.Run("case=sends remove event for missing objects when requested", func(t *testing.T) {
+		ts := httptest.NewServer(&fakeObjectStorage{objects: map[string]string{}})
+		t.Cleanup(ts.Close)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		u, err := url.Parse(ts.URL + "/config.yaml")
+		require.NoError(t, err)
+		c := make(EventChannel)
+		d, err := WatchObjectStorage(ctx, u, c)
+		require.NoError(t, err)
+
+		done, err := d.DispatchNow()
+		require.NoError(t, err)
+		assertRemove(t, receive(t, c), u.String())
+		assert.Equal(t, 1, <-done)
+	})
+
+	t.Run("case=resolves object URLs", func(t *testing.T) {
+		requests := make(chan *http.Request, 1)
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+			_, _ = w.Write([]byte("a"))
+		}))
+		t.Cleanup(ts.Close)
+		endpoint, err := url.Parse(ts.URL)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		resolver := objectstoragex.NewResolver(objectstoragex.WithBuckets("azblob", "container"), objectstoragex.WithEndpoint("azblob", endpoint))
+		u := &url.URL{Scheme: "azblob", Host: "container", Path: "/config.yaml"}
+		_, err = WatchObjectStorage(ctx, u, make(EventChannel), WithObjectStorageResolver(resolver))
+		require.NoError(t, err)
+		assert.Equal(t, "/container/config.yaml", (<-requests).URL.Path)
+
+		u = &url.URL{Scheme: "azblob", Host: "other", Path: "/config.yaml"}
+		_, err = WatchObjectStorage(ctx, u, make(EventChannel), WithObjectStorageResolver(resolver))
+		assert.True(t, errors.Is(err, objectstoragex.ErrNotAllowed), "%+v", err)
+
+		for _, raw := range []string{"s3://bucket", "gs:///key", "ftp://example.com/a.yaml"} {
+			u, err := url.Parse(raw)
+			require.NoError(t, err)
+			_, err = WatchObjectStorage(ctx, u, make(EventChannel), WithObjectStorageEndpoint(endpoint))
+			assert.Error(t, err, raw)
+		}
+		assert.Empty(t, requests)
+	})
+
+	t.Run("case=limits the object size", func(t *testing.T) {
+		storage := &fakeObjectStorage{objects: map[string]string{"/config.yaml": "abc"}}
+		ts := httptest.NewServer(storage)
+		t.Cleanup(ts.Close)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		u, err := url.Parse(ts.URL + "/config.yaml")
+		require.NoError(t, err)
+		_, err = WatchObjectStorage(ctx, u, make(EventChannel), WithObjectStorageMaxSize(2))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "exceeds the limit of 2 bytes")
+
+		c := make(EventChannel)
+		_, err = WatchObjectStorage(ctx, u, c, WithObjectStorageMaxSize(3), WithObjectStoragePollInterval(10*time.Millisecond))
+		require.NoError(t, err)
+
+		storage.set("/config.yaml", "abcd")
+		e := receive(t, c)
+		require.IsType(t, &ErrorEvent{}, e)
+		assert.Contains(t, e.(*ErrorEvent).Error(), "exceeds the limit of 3 bytes")
+	})
+}
@@ -0,0 +1,128 @@
+package watcherx
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ory/x/healthx"
+)
+
+type (
+	// Status describes the state of a watcher, for example to detect watchers which stopped
+	// silently.
+	Status struct {
+		// Source is the watched file, directory, or URL.
+		Source string `json:"source"`
+
+		// Connected is true while the watcher is running and, for remote sources, the last
+		// request or connection succeeded.
+		Connected bool `json:"connected"`
+
+		// Started is when the watcher was started.
+		Started time.Time `json:"started"`
+
+		// LastEvent is when the last event was received from the event channel. It is zero if
+		// no event was received yet.
+		LastEvent time.Time `json:"last_event,omitempty"`
+
+		// PendingSince is when the watcher started to send the event which was not received
+		// from the event channel yet. It is zero if no event is pending, and a value long in the
+		// past means that the receiver of the events is stuck.
+		PendingSince time.Time `json:"pending_since,omitempty"`
+
+		// Errors is the number of error events which were sent.
+		Errors int `json:"errors"`
+
+		// LastError is the message of the last error event.
+		LastError string `json:"last_error,omitempty"`
+
+		// Reconnects is the number of attempts to reconnect to a remote source after an error.
+		Reconnects int `json:"reconnects"`
+	}
+
+	// StatusReporter is implemented by all watchers of this package.
+	StatusReporter interface {
+		// Status returns the current status of the watcher.
+		Status() Status
+	}
+
+	statusTracker struct {
+		sync.RWMutex
+		status Status
+	}
+)
+
+// ErrWatcherNotConnected is returned by the probe of a watcher which is not connected.
+var ErrWatcherNotConnected = errors.New("watcher is not connected")
+
+func newStatusTracker(src string) *statusTracker {
+	return &statusTracker{status: Status{Source: src, Connected: true, Started: time.Now()}}
+}
+
+func (s *statusTracker) get() Status {
+	s.RLock()
+	defer s.RUnlock()
+	return s.status
+}
+
+// send records the event and sends it. The event is recorded as delivered once it was received.
+func (s *statusTracker) send(c EventChannel, e Event) {
+	s.Lock()
+	s.status.PendingSince = time.Now()
+	if err, ok := e.(*ErrorEvent); ok {
+		s.status.Errors++
+		s.status.LastError = err.Error()
+	}
+	s.Unlock()
+
+	c <- e
+
+	s.Lock()
+	s.status.LastEvent = time.Now()
+	s.status.PendingSince = time.Time{}
+	s.Unlock()
+}
+
+func (s *statusTracker) setConnected(connected bool) {
+	s.Lock()
+	defer s.Unlock()
+	s.status.Connected = connected
+}
+
+func (s *statusTracker) reconnect() {
+	s.Lock()
+	defer s.Unlock()
+	s.status.Reconnects++
+}
+
+// Status returns the current status of the watcher.
+func (d *dispatcher) Status() Status {
+	return d.status.get()
+}
+
+// Probe returns a readiness probe which fails if the watcher is not connected, so that it can
+// be added to the health handler with healthx.WithProbes. The watcher must implement
+// StatusReporter, which all watchers of this package do.
+func Probe(name string, w Watcher) healthx.Probe {
+	return healthx.Probe{
+		Name: name,
+		Check: func(context.Context) error {
+			r, ok := w.(StatusReporter)
+			if !ok {
+				return errors.Errorf("watcher %T does not report its status", w)
+			}
+
+			status := r.Status()
+			if status.Connected {
+				return nil
+			}
+			if status.LastError != "" {
+				return errors.Wrapf(ErrWatcherNotConnected, "%s: %s", status.Source, status.LastError)
+			}
+			return errors.Wrap(ErrWatcherNotConnected, status.Source)
+		},
+	}
+}
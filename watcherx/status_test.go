@@ -0,0 +1,103 @@
+package watcherx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type statuslessWatcher struct{}
+
+func (statuslessWatcher) DispatchNow() (<-chan int, error) {
+	return nil, nil
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("case=file watcher", func(t *testing.T) {
+		ctx, c, dir, cancel := setup(t)
+		defer cancel()
+
+		file := filepath.Join(dir, "config.yaml")
+		w, err := WatchFile(ctx, file, c)
+		require.NoError(t, err)
+		probe := Probe("config", w)
+
+		status := w.(StatusReporter).Status()
+		assert.Equal(t, file, status.Source)
+		assert.True(t, status.Connected)
+		assert.False(t, status.Started.IsZero())
+		assert.True(t, status.LastEvent.IsZero())
+		assert.NoError(t, probe.Check(context.Background()))
+
+		require.NoError(t, ioutil.WriteFile(file, []byte("foo"), 0600))
+		// The event is pending until it is received.
+		assert.Eventually(t, func() bool {
+			return !w.(StatusReporter).Status().PendingSince.IsZero()
+		}, time.Second, 10*time.Millisecond)
+		assert.True(t, w.(StatusReporter).Status().LastEvent.IsZero())
+
+		<-c
+		assert.Eventually(t, func() bool {
+			return !w.(StatusReporter).Status().LastEvent.IsZero()
+		}, time.Second, 10*time.Millisecond)
+
+		cancel()
+		for range c {
+		}
+		assert.False(t, w.(StatusReporter).Status().Connected)
+		err = probe.Check(context.Background())
+		assert.True(t, errors.Is(err, ErrWatcherNotConnected), "%+v", err)
+	})
+
+	t.Run("case=remote watcher", func(t *testing.T) {
+		storage := &fakeObjectStorage{objects: map[string]string{"/config.yaml": "a"}, etags: true}
+		ts := httptest.NewServer(storage)
+		t.Cleanup(ts.Close)
+		u, err := url.Parse(ts.URL + "/config.yaml")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		c := make(EventChannel)
+		w, err := WatchObjectStorage(ctx, u, c, WithObjectStoragePollInterval(10*time.Millisecond))
+		require.NoError(t, err)
+		probe := Probe("templates", w)
+
+		storage.setStatus(http.StatusServiceUnavailable)
+		receive(t, c)
+		receive(t, c)
+
+		status := w.(StatusReporter).Status()
+		assert.False(t, status.Connected)
+		assert.GreaterOrEqual(t, status.Errors, 2)
+		assert.GreaterOrEqual(t, status.Reconnects, 1)
+		assert.Contains(t, status.LastError, "got 503")
+		err = probe.Check(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "got 503")
+
+		storage.setStatus(0)
+		assert.Eventually(t, func() bool {
+			select {
+			case <-c:
+			default:
+			}
+			return w.(StatusReporter).Status().Connected
+		}, time.Second, 5*time.Millisecond)
+		assert.NoError(t, probe.Check(context.Background()))
+	})
+
+	t.Run("case=watcher without status", func(t *testing.T) {
+		assert.Error(t, Probe("custom", statuslessWatcher{}).Check(context.Background()))
+	})
+}
@@ -17,24 +17,25 @@ func WatchWebsocket(ctx context.Context, u *url.URL, c EventChannel) (Watcher, e
 		return nil, errors.WithStack(err)
 	}
 
-	wsClosed := make(chan struct{})
-	go cleanupOnDone(ctx, conn, c, wsClosed)
+	d := newDispatcher(u.String())
 
-	d := newDispatcher()
+	wsClosed := make(chan struct{})
+	go cleanupOnDone(ctx, conn, c, wsClosed, d.status)
 
-	go forwardWebsocketEvents(conn, c, u, wsClosed, d.done)
+	go forwardWebsocketEvents(conn, c, u, wsClosed, d.done, d.status)
 
-	go forwardDispatchNow(ctx, conn, c, d.trigger, u.String())
+	go forwardDispatchNow(ctx, conn, c, d.trigger, d.status, u.String())
 
 	return d, nil
 }
 
-func cleanupOnDone(ctx context.Context, conn *websocket.Conn, c EventChannel, wsClosed <-chan struct{}) {
+func cleanupOnDone(ctx context.Context, conn *websocket.Conn, c EventChannel, wsClosed <-chan struct{}, status *statusTracker) {
 	// wait for one of the events to occur
 	select {
 	case <-ctx.Done():
 	case <-wsClosed:
 	}
+	status.setConnected(false)
 
 	// clean up channel
 	close(c)
@@ -44,7 +45,7 @@ func cleanupOnDone(ctx context.Context, conn *websocket.Conn, c EventChannel, ws
 	_ = conn.Close()
 }
 
-func forwardWebsocketEvents(ws *websocket.Conn, c EventChannel, u *url.URL, wsClosed chan<- struct{}, sendNowDone chan<- int) {
+func forwardWebsocketEvents(ws *websocket.Conn, c EventChannel, u *url.URL, wsClosed chan<- struct{}, sendNowDone chan<- int, status *statusTracker) {
 	serverURL := source(u.String())
 
 	defer func() {
@@ -63,10 +64,10 @@ func forwardWebsocketEvents(ws *websocket.Conn, c EventChannel, u *url.URL, wsCl
 			if opErr, ok := err.(*net.OpError); ok && opErr.Op == "read" && strings.Contains(opErr.Err.Error(), "closed") {
 				return
 			}
-			c <- &ErrorEvent{
+			status.send(c, &ErrorEvent{
 				error:  errors.WithStack(err),
 				source: serverURL,
-			}
+			})
 			return
 		}
 
@@ -79,20 +80,20 @@ func forwardWebsocketEvents(ws *websocket.Conn, c EventChannel, u *url.URL, wsCl
 
 		e, err := unmarshalEvent(msg)
 		if err != nil {
-			c <- &ErrorEvent{
+			status.send(c, &ErrorEvent{
 				error:  err,
 				source: serverURL,
-			}
+			})
 			continue
 		}
 		localURL := *u
 		localURL.Path = e.Source()
 		e.setSource(localURL.String())
-		c <- e
+		status.send(c, e)
 	}
 }
 
-func forwardDispatchNow(ctx context.Context, ws *websocket.Conn, c EventChannel, sendNow <-chan struct{}, serverURL string) {
+func forwardDispatchNow(ctx context.Context, ws *websocket.Conn, c EventChannel, sendNow <-chan struct{}, status *statusTracker, serverURL string) {
 	for {
 		select {
 		case <-ctx.Done():
@@ -103,10 +104,10 @@ func forwardDispatchNow(ctx context.Context, ws *websocket.Conn, c EventChannel,
 			}
 
 			if err := ws.WriteMessage(websocket.TextMessage, []byte(messageSendNow)); err != nil {
-				c <- &ErrorEvent{
+				status.send(c, &ErrorEvent{
 					source: source(serverURL),
 					error:  err,
-				}
+				})
 			}
 		}
 	}